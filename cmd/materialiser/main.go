@@ -0,0 +1,47 @@
+// Command materialiser saves today's scheduled state as an entry for every
+// user who has opted in and whose evening has started in their timezone, then
+// exits. It is designed to run as a scheduled Cloud Run Job (hourly via Cloud
+// Scheduler, so each user's evening is reached).
+//
+// The standalone server fills in scheduled days itself and doesn't need it.
+package main
+
+import (
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/baely/officetracker/internal/config"
+	"github.com/baely/officetracker/internal/database"
+	v1 "github.com/baely/officetracker/internal/implementation/v1"
+	"github.com/baely/officetracker/internal/report"
+	"github.com/baely/officetracker/internal/util"
+)
+
+func main() {
+	util.LoadEnv()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	slog.SetDefault(logger)
+
+	cfg, err := config.LoadIntegratedApp()
+	if err != nil {
+		slog.Error("failed to load config", "error", err.Error())
+		os.Exit(1)
+	}
+
+	db, err := database.NewPostgres(cfg.Postgres)
+	if err != nil {
+		slog.Error("failed to connect to database", "error", err.Error())
+		os.Exit(1)
+	}
+
+	service := v1.New(db, report.New(db))
+	saved, err := service.MaterialiseScheduledDays(time.Now())
+	if err != nil {
+		slog.Error("materialising scheduled days failed", "error", err.Error())
+		os.Exit(1)
+	}
+
+	slog.Info("materialising scheduled days complete", "saved", saved)
+}
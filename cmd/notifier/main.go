@@ -0,0 +1,94 @@
+// Command notifier sends the email and push notifications due to every
+// opted-in user at the current time in their timezone, then exits. It is
+// designed to run as a scheduled Cloud Run Job (hourly via Cloud Scheduler, so
+// each user's evening and morning are reached); notifications already sent are
+// skipped. Email is sent when SMTP is configured, browser notifications when a
+// VAPID key pair is, and mobile app notifications when an Expo push URL is.
+//
+// Run with -generate-vapid-keys to print a new key pair for WEBPUSH_PUBLIC_KEY
+// and WEBPUSH_PRIVATE_KEY.
+//
+// The standalone server sends notifications itself and doesn't need it.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/baely/officetracker/internal/config"
+	"github.com/baely/officetracker/internal/database"
+	v1 "github.com/baely/officetracker/internal/implementation/v1"
+	"github.com/baely/officetracker/internal/mail"
+	"github.com/baely/officetracker/internal/push"
+	"github.com/baely/officetracker/internal/report"
+	"github.com/baely/officetracker/internal/util"
+)
+
+func main() {
+	generate := flag.Bool("generate-vapid-keys", false, "print a new VAPID key pair for push notifications and exit")
+	flag.Parse()
+	if *generate {
+		public, private, err := push.GenerateKeys()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("WEBPUSH_PUBLIC_KEY=%s\nWEBPUSH_PRIVATE_KEY=%s\n", public, private)
+		return
+	}
+
+	util.LoadEnv()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	slog.SetDefault(logger)
+
+	cfg, err := config.LoadIntegratedApp()
+	if err != nil {
+		slog.Error("failed to load config", "error", err.Error())
+		os.Exit(1)
+	}
+
+	var notifiers v1.Notifiers
+	if cfg.SMTP.Host != "" {
+		notifiers.Mail, err = mail.NewSMTP(cfg.SMTP)
+		if err != nil {
+			slog.Error("failed to configure mail", "error", err.Error())
+			os.Exit(1)
+		}
+	}
+	if cfg.WebPush.PrivateKey != "" {
+		// Endpoints come from browsers, so like webhooks they mustn't reach
+		// the internal network.
+		notifiers.Push, err = push.NewVAPID(cfg.WebPush, v1.NewWebhookClient(false))
+		if err != nil {
+			slog.Error("failed to configure push notifications", "error", err.Error())
+			os.Exit(1)
+		}
+	}
+	if cfg.Expo.PushURL != "" {
+		notifiers.Mobile = push.NewExpo(cfg.Expo, http.DefaultClient)
+	}
+	if notifiers.Mail == nil && notifiers.Push == nil && notifiers.Mobile == nil {
+		slog.Error("none of SMTP, Web Push and Expo is configured")
+		os.Exit(1)
+	}
+
+	db, err := database.NewPostgres(cfg.Postgres)
+	if err != nil {
+		slog.Error("failed to connect to database", "error", err.Error())
+		os.Exit(1)
+	}
+
+	service := v1.New(db, report.New(db))
+	sent, err := service.SendNotifications(notifiers, util.BaseUri(cfg), time.Now())
+	if err != nil {
+		slog.Error("sending notifications failed", "error", err.Error())
+		os.Exit(1)
+	}
+
+	slog.Info("sending notifications complete", "sent", sent)
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/baely/officetracker/pkg/model"
+)
+
+// rgb is a terminal colour.
+type rgb struct{ r, g, b uint8 }
+
+// stateColours match the state classes in themes.css.
+var stateColours = map[model.State]rgb{
+	model.StateWorkFromHome:   {0x4C, 0xAF, 0x50},
+	model.StateWorkFromOffice: {0xF4, 0x43, 0x36},
+	model.StateOther:          {0x21, 0x96, 0xF3},
+}
+
+// scheduledStates maps the scheduled states to the state they plan for. The
+// web form shows them in a lighter shade of that state's colour.
+var scheduledStates = map[model.State]model.State{
+	model.StateScheduledWorkFromHome:   model.StateWorkFromHome,
+	model.StateScheduledWorkFromOffice: model.StateWorkFromOffice,
+	model.StateScheduledOther:          model.StateOther,
+}
+
+var stateNames = map[model.State]string{
+	model.StateUntracked:      "Untracked",
+	model.StateWorkFromHome:   "Home",
+	model.StateWorkFromOffice: "Office",
+	model.StateOther:          "Other",
+}
+
+// stateName names s, including the user's custom states.
+func stateName(s model.State, custom model.CustomStates) string {
+	if planned, ok := scheduledStates[s]; ok {
+		return stateNames[planned] + " (scheduled)"
+	}
+	if cs, ok := custom.Get(s); ok {
+		return cs.Name
+	}
+	if name, ok := stateNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("State %d", s)
+}
+
+// palette colours terminal output, or leaves it plain.
+type palette struct {
+	enabled bool
+	custom  model.CustomStates
+}
+
+// colour returns s's colour and whether it is a scheduled state.
+func (p palette) colour(s model.State) (rgb, bool) {
+	scheduled := false
+	if planned, ok := scheduledStates[s]; ok {
+		s, scheduled = planned, true
+	}
+	if c, ok := stateColours[s]; ok {
+		return c, scheduled
+	}
+	if cs, ok := p.custom.Get(s); ok {
+		if c, ok := parseHex(cs.Color); ok {
+			return c, scheduled
+		}
+	}
+	return rgb{}, false
+}
+
+// paint shows text on s's colour. Scheduled states, which the form shows in a
+// lighter shade, are shown in the colour instead of on it.
+func (p palette) paint(text string, s model.State) string {
+	if !p.enabled {
+		return text
+	}
+	c, scheduled := p.colour(s)
+	if c == (rgb{}) {
+		return text
+	}
+	if scheduled {
+		return fmt.Sprintf("\x1b[38;2;%d;%d;%dm%s\x1b[0m", c.r, c.g, c.b, text)
+	}
+	return fmt.Sprintf("\x1b[48;2;%d;%d;%dm\x1b[97m%s\x1b[0m", c.r, c.g, c.b, text)
+}
+
+// marker is a letter standing in for s's colour in plain output: the initial
+// of its name, lower case for a scheduled state.
+func (p palette) marker(s model.State) string {
+	if p.enabled || s == model.StateUntracked {
+		return " "
+	}
+	if planned, ok := scheduledStates[s]; ok {
+		return strings.ToLower(p.marker(planned))
+	}
+	return strings.ToUpper(stateName(s, p.custom)[:1])
+}
+
+// swatch is a small block of s's colour for legends.
+func (p palette) swatch(s model.State) string {
+	if !p.enabled {
+		return p.marker(s)
+	}
+	c, scheduled := p.colour(s)
+	block := "██"
+	if scheduled {
+		block = "░░"
+	}
+	return fmt.Sprintf("\x1b[38;2;%d;%d;%dm%s\x1b[0m", c.r, c.g, c.b, block)
+}
+
+// parseHex parses a CSS hex colour such as #9C27B0.
+func parseHex(s string) (rgb, bool) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	if len(s) != 6 {
+		return rgb{}, false
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return rgb{}, false
+	}
+	return rgb{uint8(v >> 16), uint8(v >> 8), uint8(v)}, true
+}
+
+// renderMonth draws a month as a calendar with weeks starting on Monday, each
+// day coloured by its state. Today, if it's in the month, is marked.
+func renderMonth(w io.Writer, year int, month time.Month, days map[int]model.DayState, p palette, today time.Time) {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	fmt.Fprintf(w, "%s\n", centre(first.Format("January 2006"), 28))
+	fmt.Fprintln(w, " Mo  Tu  We  Th  Fr  Sa  Su")
+
+	offset := (int(first.Weekday()) + 6) % 7
+	fmt.Fprint(w, strings.Repeat("    ", offset))
+	last := first.AddDate(0, 1, -1).Day()
+	for day := 1; day <= last; day++ {
+		state := days[day].State
+		cell := fmt.Sprintf("%3d%s", day, p.marker(state))
+		if today.Year() == year && today.Month() == month && today.Day() == day {
+			cell = fmt.Sprintf(">%2d%s", day, p.marker(state))
+		}
+		fmt.Fprint(w, p.paint(cell, state))
+		if (offset+day)%7 == 0 && day != last {
+			fmt.Fprintln(w)
+		}
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w)
+	renderLegend(w, days, p)
+}
+
+// renderLegend lists the states used in a month with how many days each.
+func renderLegend(w io.Writer, days map[int]model.DayState, p palette) {
+	counts := map[model.State]int{}
+	for _, d := range days {
+		if d.State != model.StateUntracked {
+			counts[d.State]++
+		}
+	}
+	var states []model.State
+	for s := range counts {
+		states = append(states, s)
+	}
+	slices.Sort(states)
+	for _, s := range states {
+		fmt.Fprintf(w, "%s %s: %d\n", p.swatch(s), stateName(s, p.custom), counts[s])
+	}
+}
+
+func centre(s string, width int) string {
+	if len(s) >= width {
+		return s
+	}
+	return strings.Repeat(" ", (width-len(s))/2) + s
+}
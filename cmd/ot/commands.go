@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/baely/officetracker/internal/util"
+	"github.com/baely/officetracker/pkg/client"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+const dateLayout = "2006-01-02"
+
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+// login checks the server and token work, then saves them.
+func (a *app) login(ctx context.Context, args []string) error {
+	fs := newFlagSet("login")
+	server := fs.String("server", a.cfg.Server, "server URL")
+	token := fs.String("token", "", "API token")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 {
+		return errUsage
+	}
+
+	cfg := config{Server: *server, Token: *token}
+	if cfg.Token == "" {
+		fmt.Fprint(a.stdout, "API token (leave empty for a standalone server): ")
+		line, err := bufio.NewReader(a.stdin).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("failed to read token: %w", err)
+		}
+		cfg.Token = strings.TrimSpace(line)
+	}
+	a.cfg = cfg
+
+	c, err := a.client()
+	if err != nil {
+		return err
+	}
+	if cfg.Token != "" {
+		_, err = c.ValidateAuth(ctx, model.ValidateAuthRequest{})
+	} else {
+		// Only standalone servers answer without a token.
+		_, err = c.GetSettings(ctx, model.GetSettingsRequest{})
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't sign in to %s: %w", cfg.Server, err)
+	}
+
+	if err = saveConfig(a.configPath, cfg); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stdout, "Signed in to %s. Saved to %s\n", cfg.Server, a.configPath)
+	return nil
+}
+
+// today returns the current time in the user's timezone, with their
+// settings.
+func today(ctx context.Context, c *client.Client) (time.Time, model.GetSettingsResponse, error) {
+	settings, err := c.GetSettings(ctx, model.GetSettingsRequest{})
+	if err != nil {
+		return time.Time{}, settings, err
+	}
+	return time.Now().In(util.Location(settings.CalendarPreferences.Timezone)), settings, nil
+}
+
+// record sets a day's state, today unless a date is given.
+func (a *app) record(ctx context.Context, state model.State, args []string) error {
+	fs := newFlagSet("record")
+	note := fs.String("note", "", "note for the day")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 1 {
+		return errUsage
+	}
+	c, err := a.client()
+	if err != nil {
+		return err
+	}
+
+	var day time.Time
+	if fs.NArg() == 1 {
+		if day, err = time.Parse(dateLayout, fs.Arg(0)); err != nil {
+			return fmt.Errorf("invalid date %q: want YYYY-MM-DD", fs.Arg(0))
+		}
+	} else if day, _, err = today(ctx, c); err != nil {
+		return err
+	}
+
+	_, err = c.PutDay(ctx, model.PutDayRequest{
+		Meta: model.PutDayRequestMeta{Year: day.Year(), Month: int(day.Month()), Day: day.Day()},
+		Data: model.DayState{State: state, Note: *note},
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stdout, "%s: %s\n", day.Format("Mon 2 Jan 2006"), stateName(state, nil))
+	return nil
+}
+
+var weekdayNames = []string{"Mo", "Tu", "We", "Th", "Fr", "Sa", "Su"}
+
+// status shows today, the current week and progress against the target.
+func (a *app) status(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return errUsage
+	}
+	c, err := a.client()
+	if err != nil {
+		return err
+	}
+	now, settings, err := today(ctx, c)
+	if err != nil {
+		return err
+	}
+	isoYear, isoWeek := now.ISOWeek()
+	week, err := c.GetWeek(ctx, model.GetWeekRequest{Meta: model.GetWeekRequestMeta{ISOYear: isoYear, Week: isoWeek}})
+	if err != nil {
+		return err
+	}
+
+	p := palette{enabled: a.colour, custom: settings.CustomStates}
+	todayState := week.Data.Days[util.ISOWeekday(now)].State
+	fmt.Fprintf(a.stdout, "Today, %s: %s\n", now.Format("Mon 2 Jan"), stateName(todayState, p.custom))
+
+	var cells []string
+	for i, name := range weekdayNames {
+		state := week.Data.Days[i+1].State
+		cells = append(cells, p.paint(" "+name+p.marker(state), state))
+	}
+	fmt.Fprintf(a.stdout, "Week %s:%s  %d in office\n", week.Data.Week, strings.Join(cells, ""), week.Data.OfficeDays)
+
+	if !settings.TargetPreferences.IsSet() {
+		return nil
+	}
+	compliance, err := c.GetCompliance(ctx, model.GetComplianceRequest{Date: now.Format(dateLayout)})
+	if err != nil || compliance.Data == nil {
+		return err
+	}
+	fmt.Fprintln(a.stdout, describeCompliance(*compliance.Data))
+	return nil
+}
+
+// describeCompliance summarises progress against the target in a line.
+func describeCompliance(c model.Compliance) string {
+	amount := func(v float64) string {
+		switch c.Policy {
+		case model.TargetPolicyDaysPerWeek:
+			return fmt.Sprintf("%.1f days a week", v)
+		case model.TargetPolicyDaysPerMonth:
+			return fmt.Sprintf("%.1f days a month", v)
+		}
+		return fmt.Sprintf("%.0f%%", v)
+	}
+	line := fmt.Sprintf("Target %s from %s to %s: %s so far (%d of %d days in office)",
+		amount(c.Target), c.Start, c.End, amount(c.Actual), c.Present, c.Total)
+	if c.Met {
+		return line + ", met"
+	}
+	return line + fmt.Sprintf(", %d more office days needed", c.Remaining)
+}
+
+// month shows a month as a calendar, this month unless one is given.
+func (a *app) month(ctx context.Context, args []string) error {
+	if len(args) > 1 {
+		return errUsage
+	}
+	c, err := a.client()
+	if err != nil {
+		return err
+	}
+	now, settings, err := today(ctx, c)
+	if err != nil {
+		return err
+	}
+	year, month := now.Year(), now.Month()
+	if len(args) == 1 {
+		t, err := time.Parse("2006-01", args[0])
+		if err != nil {
+			return fmt.Errorf("invalid month %q: want YYYY-MM", args[0])
+		}
+		year, month = t.Year(), t.Month()
+	}
+
+	// The year view fills in scheduled days, which the month view doesn't.
+	startMonth := settings.CalendarPreferences.TrackingYearStartMonth
+	resp, err := c.GetYear(ctx, model.GetYearRequest{
+		Meta: model.GetYearRequestMeta{Year: util.TrackingYear(int(month), year, startMonth)},
+	})
+	if err != nil {
+		return err
+	}
+	p := palette{enabled: a.colour, custom: settings.CustomStates}
+	renderMonth(a.stdout, year, month, resp.Data.Months[int(month)].Days, p, now)
+	return nil
+}
+
+// report downloads the PDF or CSV report for a tracking year, the current
+// one unless one is given.
+func (a *app) report(ctx context.Context, args []string) error {
+	fs := newFlagSet("report")
+	pdf := fs.Bool("pdf", false, "download the PDF report (the default)")
+	csv := fs.Bool("csv", false, "download the CSV export")
+	year := fs.Int("year", 0, "tracking year")
+	out := fs.String("o", "", "file to write, or - for stdout")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 || *pdf && *csv {
+		return errUsage
+	}
+	c, err := a.client()
+	if err != nil {
+		return err
+	}
+	if *year == 0 {
+		now, settings, err := today(ctx, c)
+		if err != nil {
+			return err
+		}
+		*year = util.TrackingYear(int(now.Month()), now.Year(), settings.CalendarPreferences.TrackingYearStartMonth)
+	}
+
+	var resp model.Response
+	ext := "pdf"
+	if *csv {
+		ext = "csv"
+		resp, err = c.GetReportCSV(ctx, model.GetReportCSVRequest{Meta: model.GetReportCSVRequestMeta{Year: *year}})
+	} else {
+		resp, err = c.GetReport(ctx, model.GetReportRequest{Meta: model.GetReportRequestMeta{Year: *year}})
+	}
+	if err != nil {
+		return err
+	}
+	data := resp.Data.([]byte)
+
+	if *out == "-" {
+		_, err = a.stdout.Write(data)
+		return err
+	}
+	if *out == "" {
+		*out = fmt.Sprintf("%d-attendance.%s", *year, ext)
+	}
+	if err = os.WriteFile(*out, data, 0o644); err != nil {
+		return fmt.Errorf("failed to save report: %w", err)
+	}
+	fmt.Fprintf(a.stdout, "Saved %s\n", *out)
+	return nil
+}
+
+// token lists, creates and revokes API tokens.
+func (a *app) token(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	c, err := a.client()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		var resp model.ListTokensResponse
+		resp, err = c.ListTokens(ctx, model.ListTokensRequest{})
+		if err == nil {
+			tw := tabwriter.NewWriter(a.stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "ID\tNAME\tCREATED")
+			for _, t := range resp.Tokens {
+				fmt.Fprintf(tw, "%d\t%s\t%s\n", t.TokenID, t.Name, t.CreatedAt)
+			}
+			err = tw.Flush()
+		}
+	case args[0] == "create" && len(args) == 2:
+		var resp model.PostSecretResponse
+		resp, err = c.PostSecret(ctx, model.PostSecretRequest{Data: model.PostSecretRequestData{Name: args[1]}})
+		if err == nil {
+			fmt.Fprintf(a.stdout, "%s\nKeep this token safe; it won't be shown again.\n", resp.Secret)
+		}
+	case args[0] == "revoke" && len(args) == 2:
+		id, convErr := strconv.Atoi(args[1])
+		if convErr != nil {
+			return fmt.Errorf("invalid token ID %q", args[1])
+		}
+		_, err = c.RevokeToken(ctx, model.RevokeTokenRequest{Meta: model.RevokeTokenRequestMeta{TokenID: id}})
+		if err == nil {
+			fmt.Fprintf(a.stdout, "Revoked token %d\n", id)
+		}
+	default:
+		return errUsage
+	}
+
+	if client.IsCode(err, model.ErrorCodeForbidden) {
+		return errors.New("API tokens can only be managed on a hosted server, signed in with a token")
+	}
+	return err
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// defaultServer is where a standalone server listens by default.
+	defaultServer = "http://localhost:8080"
+
+	serverEnv = "OT_SERVER"
+	tokenEnv  = "OT_TOKEN"
+)
+
+// config is what ot remembers between runs. The server and token can also be
+// given as $OT_SERVER and $OT_TOKEN, which take precedence.
+type config struct {
+	Server string `json:"server"`
+	// Token is the API secret. Standalone servers don't need one.
+	Token string `json:"token,omitempty"`
+}
+
+// defaultConfigPath is ot's config file in the user's config directory, e.g.
+// ~/.config/officetracker/ot.json.
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = "."
+	}
+	return filepath.Join(dir, "officetracker", "ot.json")
+}
+
+// loadConfig reads the config file, if there is one, and applies the
+// environment on top.
+func loadConfig(path string) (config, error) {
+	cfg := config{Server: defaultServer}
+	b, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return config{}, fmt.Errorf("failed to read config: %w", err)
+	default:
+		if err = json.Unmarshal(b, &cfg); err != nil {
+			return config{}, fmt.Errorf("failed to parse config %s: %w", path, err)
+		}
+	}
+	if server := os.Getenv(serverEnv); server != "" {
+		cfg.Server = server
+	}
+	if token := os.Getenv(tokenEnv); token != "" {
+		cfg.Token = token
+	}
+	return cfg, nil
+}
+
+// saveConfig writes the config file. It holds the API secret, so only the
+// user can read it.
+func saveConfig(path string, cfg config) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	b, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err = os.WriteFile(path, append(b, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	// WriteFile keeps the mode of an existing file.
+	if err = os.Chmod(path, 0o600); err != nil {
+		return fmt.Errorf("failed to restrict config permissions: %w", err)
+	}
+	return nil
+}
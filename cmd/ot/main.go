@@ -0,0 +1,131 @@
+// Command ot is a terminal client for Officetracker. It talks to the v1 API
+// of a hosted or standalone server.
+//
+// Usage:
+//
+//	ot [-config file] <command> [arguments]
+//
+// Run ot help for the commands.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/baely/officetracker/pkg/client"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+const usage = `Usage: ot [-config file] <command> [arguments]
+
+Commands:
+  login [-server url] [-token secret]   save the server and API token to use
+  in [-note text] [YYYY-MM-DD]          record a day in the office (today by default)
+  home [-note text] [YYYY-MM-DD]        record a day working from home
+  other [-note text] [YYYY-MM-DD]       record a day as other
+  status                                show today, this week and the attendance target
+  month [YYYY-MM]                       show a month as a calendar
+  report [-pdf | -csv] [-year n] [-o file]
+                                        download the attendance report for a tracking year
+  token list                            list API tokens
+  token create <name>                   create an API token
+  token revoke <id>                     revoke an API token
+
+The server and token can also be set with $OT_SERVER and $OT_TOKEN. Standalone
+servers don't need a token.
+`
+
+// errUsage is returned for bad arguments, after which usage is shown.
+var errUsage = errors.New("invalid usage")
+
+// app is a run of ot.
+type app struct {
+	configPath string
+	cfg        config
+	stdin      io.Reader
+	stdout     io.Writer
+	// colour is whether stdout is a terminal that should be coloured.
+	colour bool
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	a := &app{stdin: os.Stdin, stdout: os.Stdout, colour: colourTerminal(os.Stdout)}
+	err := a.run(ctx, os.Args[1:])
+	switch {
+	case errors.Is(err, errUsage):
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "ot: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func (a *app) run(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("ot", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&a.configPath, "config", defaultConfigPath(), "config file")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() == 0 {
+		return errUsage
+	}
+
+	cfg, err := loadConfig(a.configPath)
+	if err != nil {
+		return err
+	}
+	a.cfg = cfg
+
+	cmd, args := fs.Arg(0), fs.Args()[1:]
+	switch cmd {
+	case "login":
+		return a.login(ctx, args)
+	case "in":
+		return a.record(ctx, model.StateWorkFromOffice, args)
+	case "home":
+		return a.record(ctx, model.StateWorkFromHome, args)
+	case "other":
+		return a.record(ctx, model.StateOther, args)
+	case "status":
+		return a.status(ctx, args)
+	case "month":
+		return a.month(ctx, args)
+	case "report":
+		return a.report(ctx, args)
+	case "token":
+		return a.token(ctx, args)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(a.stdout, usage)
+		return nil
+	}
+	return fmt.Errorf("unknown command %q; run ot help for the list", cmd)
+}
+
+// client returns an API client for the configured server.
+func (a *app) client() (*client.Client, error) {
+	var opts []client.Option
+	if a.cfg.Token != "" {
+		opts = append(opts, client.WithSecret(a.cfg.Token))
+	}
+	return client.New(a.cfg.Server, opts...)
+}
+
+// colourTerminal reports whether f is a terminal that wants colour. See
+// https://no-color.org for NO_COLOR.
+func colourTerminal(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
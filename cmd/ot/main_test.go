@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	appconfig "github.com/baely/officetracker/internal/config"
+	"github.com/baely/officetracker/internal/database/dbtest"
+	"github.com/baely/officetracker/internal/report"
+	"github.com/baely/officetracker/internal/server"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+func TestRenderMonthPlain(t *testing.T) {
+	days := map[int]model.DayState{
+		1: {State: model.StateWorkFromOffice},
+		2: {State: model.StateWorkFromHome},
+		4: {State: model.StateScheduledWorkFromOffice},
+	}
+	var b bytes.Buffer
+	today := time.Date(2024, time.March, 2, 0, 0, 0, 0, time.UTC)
+	renderMonth(&b, 2024, time.March, days, palette{}, today)
+
+	want := `         March 2024
+ Mo  Tu  We  Th  Fr  Sa  Su
+                  1O> 2H  3
+  4o  5   6   7   8   9  10
+ 11  12  13  14  15  16  17
+ 18  19  20  21  22  23  24
+ 25  26  27  28  29  30  31
+
+H Home: 1
+O Office: 1
+o Office (scheduled): 1
+`
+	// Untracked days leave their marker column blank.
+	got := regexp.MustCompile(` +\n`).ReplaceAllString(b.String(), "\n")
+	if got != want {
+		t.Errorf("renderMonth =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestPaletteColours(t *testing.T) {
+	p := palette{enabled: true, custom: model.CustomStates{{ID: 10, Name: "Travel", Color: "#9C27B0"}}}
+	if got := p.paint("x", model.StateWorkFromOffice); got != "\x1b[48;2;244;67;54m\x1b[97mx\x1b[0m" {
+		t.Errorf("paint(office) = %q", got)
+	}
+	if got := p.paint("x", model.StateScheduledWorkFromHome); got != "\x1b[38;2;76;175;80mx\x1b[0m" {
+		t.Errorf("paint(scheduled home) = %q", got)
+	}
+	if c, _ := p.colour(10); c != (rgb{0x9C, 0x27, 0xB0}) {
+		t.Errorf("colour(custom) = %v", c)
+	}
+	if got := p.paint("x", model.StateUntracked); got != "x" {
+		t.Errorf("paint(untracked) = %q", got)
+	}
+}
+
+func TestConfigRoundTrip(t *testing.T) {
+	t.Setenv(serverEnv, "")
+	t.Setenv(tokenEnv, "")
+	path := filepath.Join(t.TempDir(), "officetracker", "ot.json")
+
+	cfg, err := loadConfig(path)
+	if err != nil || cfg != (config{Server: defaultServer}) {
+		t.Fatalf("loadConfig(missing) = %+v, %v", cfg, err)
+	}
+
+	want := config{Server: "https://example.com", Token: "secret"}
+	if err = saveConfig(path, want); err != nil {
+		t.Fatalf("saveConfig: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0o600 {
+		t.Errorf("config mode = %v, want 0600", mode)
+	}
+	if cfg, err = loadConfig(path); err != nil || cfg != want {
+		t.Errorf("loadConfig = %+v, %v; want %+v", cfg, err, want)
+	}
+
+	t.Setenv(tokenEnv, "other")
+	if cfg, _ = loadConfig(path); cfg.Token != "other" {
+		t.Errorf("token = %q, want $%s to take precedence", cfg.Token, tokenEnv)
+	}
+}
+
+func TestStandalone(t *testing.T) {
+	t.Setenv(serverEnv, "")
+	t.Setenv(tokenEnv, "")
+	db := dbtest.New()
+	srv, err := server.NewServer(appconfig.StandaloneApp{}, db, nil, report.New(db))
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	ts := httptest.NewServer(srv.Handler)
+	t.Cleanup(ts.Close)
+
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "ot.json")
+	run := func(stdin string, args ...string) (string, error) {
+		var out bytes.Buffer
+		a := &app{stdin: strings.NewReader(stdin), stdout: &out}
+		err := a.run(context.Background(), append([]string{"-config", configPath}, args...))
+		return out.String(), err
+	}
+
+	if _, err = run("\n", "login", "-server", ts.URL); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if out, err := run("", "in", "-note", "planning", "2024-03-05"); err != nil || out != "Tue 5 Mar 2024: Office\n" {
+		t.Fatalf("in = %q, %v", out, err)
+	}
+	day, err := db.GetDay(1, 5, 3, 2024)
+	if err != nil || day.State != model.StateWorkFromOffice || day.Note != "planning" {
+		t.Errorf("stored day = %+v, %v", day, err)
+	}
+
+	out, err := run("", "month", "2024-03")
+	if err != nil {
+		t.Fatalf("month: %v", err)
+	}
+	if !strings.Contains(out, "  5O") || !strings.Contains(out, "O Office: 1") {
+		t.Errorf("month output missing the office day:\n%s", out)
+	}
+
+	if _, err = run("", "status"); err != nil {
+		t.Errorf("status: %v", err)
+	}
+
+	csvPath := filepath.Join(dir, "report.csv")
+	if _, err = run("", "report", "-csv", "-year", "2024", "-o", csvPath); err != nil {
+		t.Fatalf("report: %v", err)
+	}
+	if b, err := os.ReadFile(csvPath); err != nil || !bytes.Contains(b, []byte("2024-03-05")) {
+		t.Errorf("report = %q, %v", b, err)
+	}
+
+	if _, err = run("", "token", "list"); err == nil || !strings.Contains(err.Error(), "hosted server") {
+		t.Errorf("token list = %v, want the standalone explanation", err)
+	}
+	if _, err = run("", "token"); !errors.Is(err, errUsage) {
+		t.Errorf("token = %v, want errUsage", err)
+	}
+}
@@ -3,11 +3,13 @@
 package main
 
 import (
+	"context"
 	"log/slog"
 	"os"
 
 	"github.com/baely/officetracker/internal/config"
 	"github.com/baely/officetracker/internal/database"
+	v1 "github.com/baely/officetracker/internal/implementation/v1"
 	"github.com/baely/officetracker/internal/report"
 	"github.com/baely/officetracker/internal/util"
 
@@ -37,6 +39,10 @@ func main() {
 
 	reporter := report.New(db)
 
+	// Deliveries are queued in the database, so any instance can send them and
+	// ones queued while no instance is running go out on the next start.
+	go v1.New(db, reporter).DeliverWebhooks(context.Background(), v1.NewWebhookClient(false))
+
 	s, err := server.NewServer(cfg, db, redis, reporter)
 	if err != nil {
 		panic(err)
@@ -0,0 +1,38 @@
+// Package chat verifies and parses slash commands sent by Slack and Microsoft
+// Teams, and formats the replies they expect.
+//
+// Slack signs each request with the app's signing secret; see ParseSlack.
+// Teams signs outgoing webhook messages with the security token shown when the
+// webhook is created; see ParseTeams. Neither is trusted until its signature
+// checks out.
+package chat
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"time"
+
+	"github.com/baely/officetracker/pkg/model"
+)
+
+// Tolerance is how far a Slack request's timestamp may be from now before
+// ParseSlack rejects it, to limit replays.
+const Tolerance = 5 * time.Minute
+
+// ErrInvalidSignature is returned for a missing, malformed, stale or wrong
+// signature.
+var ErrInvalidSignature = errors.New("chat: invalid signature")
+
+// Command is a verified slash command: who sent it and what they typed after
+// the command itself.
+type Command struct {
+	Account model.ChatAccount
+	Text    string
+}
+
+func mac(key, msg []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(msg)
+	return h.Sum(nil)
+}
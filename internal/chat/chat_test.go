@@ -0,0 +1,155 @@
+package chat
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/baely/officetracker/pkg/model"
+)
+
+// The example request from Slack's "Verifying requests from Slack" guide.
+const (
+	slackSecret = "8f742231b10e8888abcd99yyyzzz85a5"
+	slackBody   = "token=xyzz0WbapA4vBCDEFasx0q6G&team_id=T1DC2JH3J&team_domain=testteamnow&channel_id=G8PSS9T3V&channel_name=foobar&user_id=U2CERLKJA&user_name=roadrunner&command=%2Fwebhook-collect&text=&response_url=https%3A%2F%2Fhooks.slack.com%2Fcommands%2FT1DC2JH3J%2F397700885554%2F96rGlfmibIGlgcZRskXaIFfN&trigger_id=398738663015.47445629121.803a0bc887a14d10d2c447fce8b6703c"
+	slackSig    = "v0=a2114d57b48eac39b9ad189dd8316235a7b4a8d21a10bd27519666489c69b503"
+)
+
+var slackSent = time.Unix(1531420618, 0)
+
+func slackHeader(ts time.Time, sig string) http.Header {
+	h := http.Header{}
+	h.Set(SlackTimestampHeader, strconv.FormatInt(ts.Unix(), 10))
+	h.Set(SlackSignatureHeader, sig)
+	return h
+}
+
+func TestSignSlack(t *testing.T) {
+	if got := SignSlack(slackSecret, slackSent, []byte(slackBody)); got != slackSig {
+		t.Errorf("SignSlack = %s, want %s", got, slackSig)
+	}
+}
+
+func TestParseSlack(t *testing.T) {
+	cmd, err := ParseSlack(slackSecret, slackHeader(slackSent, slackSig), []byte(slackBody), slackSent.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("ParseSlack: %v", err)
+	}
+	want := model.ChatAccount{Platform: model.ChatPlatformSlack, TeamID: "T1DC2JH3J", UserID: "U2CERLKJA", Name: "roadrunner"}
+	if cmd.Account != want || cmd.Text != "" {
+		t.Errorf("ParseSlack = %+v, want account %+v and no text", cmd, want)
+	}
+
+	body := "team_id=T1&user_id=U1&user_name=alex&command=%2Fot&text=+in+monday+"
+	cmd, err = ParseSlack("s", slackHeader(slackSent, SignSlack("s", slackSent, []byte(body))), []byte(body), slackSent)
+	if err != nil {
+		t.Fatalf("ParseSlack: %v", err)
+	}
+	if cmd.Text != "in monday" {
+		t.Errorf("Text = %q, want %q", cmd.Text, "in monday")
+	}
+}
+
+func TestParseSlackRejects(t *testing.T) {
+	for name, tc := range map[string]struct {
+		secret string
+		header http.Header
+		body   string
+		now    time.Time
+	}{
+		"wrong secret":    {"other", slackHeader(slackSent, slackSig), slackBody, slackSent},
+		"tampered body":   {slackSecret, slackHeader(slackSent, slackSig), slackBody + "x", slackSent},
+		"stale":           {slackSecret, slackHeader(slackSent, slackSig), slackBody, slackSent.Add(Tolerance + time.Second)},
+		"future":          {slackSecret, slackHeader(slackSent, slackSig), slackBody, slackSent.Add(-Tolerance - time.Second)},
+		"no signature":    {slackSecret, slackHeader(slackSent, ""), slackBody, slackSent},
+		"no timestamp":    {slackSecret, http.Header{SlackSignatureHeader: {slackSig}}, slackBody, slackSent},
+		"wrong version":   {slackSecret, slackHeader(slackSent, "v1="+slackSig[3:]), slackBody, slackSent},
+		"moved timestamp": {slackSecret, slackHeader(slackSent.Add(time.Second), slackSig), slackBody, slackSent},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseSlack(tc.secret, tc.header, []byte(tc.body), tc.now)
+			if !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("ParseSlack = %v, want ErrInvalidSignature", err)
+			}
+		})
+	}
+}
+
+func TestSlackReply(t *testing.T) {
+	if got, want := string(SlackReply("hi\nthere")), `{"response_type":"ephemeral","text":"hi\nthere"}`; got != want {
+		t.Errorf("SlackReply = %s, want %s", got, want)
+	}
+}
+
+const (
+	teamsSecret = "b2ZmaWNldHJhY2tlci10ZWFtcy10ZXN0LWtleS0zMmI="
+	teamsBody   = `{"type":"message","text":"<at>Officetracker</at>&nbsp;home tomorrow","from":{"id":"29:1abc","name":"Alex Doe","aadObjectId":"6f1c0e1a-0000-4000-8000-00000000a1ce"},"channelData":{"tenant":{"id":"72f988bf-0000-4000-8000-0000000000db"}}}`
+	teamsSig    = "HMAC nfdZL4hReT/2NQrC9JJZIQnDXZUyHzcxFZMLyy0Kjg4="
+)
+
+func TestSignTeams(t *testing.T) {
+	got, err := SignTeams(teamsSecret, []byte(teamsBody))
+	if err != nil || got != teamsSig {
+		t.Errorf("SignTeams = %s, %v, want %s", got, err, teamsSig)
+	}
+	if _, err := SignTeams("not base64!", nil); err == nil {
+		t.Error("SignTeams with a bad secret succeeded")
+	}
+}
+
+func TestParseTeams(t *testing.T) {
+	cmd, err := ParseTeams(teamsSecret, http.Header{TeamsAuthorizationHeader: {teamsSig}}, []byte(teamsBody))
+	if err != nil {
+		t.Fatalf("ParseTeams: %v", err)
+	}
+	want := Command{
+		Account: model.ChatAccount{
+			Platform: model.ChatPlatformTeams,
+			TeamID:   "72f988bf-0000-4000-8000-0000000000db",
+			UserID:   "6f1c0e1a-0000-4000-8000-00000000a1ce",
+			Name:     "Alex Doe",
+		},
+		Text: "home tomorrow",
+	}
+	if cmd != want {
+		t.Errorf("ParseTeams = %+v, want %+v", cmd, want)
+	}
+}
+
+func TestParseTeamsRejects(t *testing.T) {
+	for name, header := range map[string]string{
+		"tampered":  "HMAC " + teamsSig[6:] + "A",
+		"no scheme": teamsSig[5:],
+		"missing":   "",
+		"other":     "HMAC AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseTeams(teamsSecret, http.Header{TeamsAuthorizationHeader: {header}}, []byte(teamsBody))
+			if !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("ParseTeams = %v, want ErrInvalidSignature", err)
+			}
+		})
+	}
+}
+
+func TestTeamsText(t *testing.T) {
+	for in, want := range map[string]string{
+		"<at>Officetracker</at> in":                      "in",
+		"<at>Officetracker</at>&nbsp;home&nbsp;monday\n": "home monday",
+		"<p><at>Office tracker</at> <b>status</b></p>":   "status",
+		"clear 2024-05-06":                               "clear 2024-05-06",
+		"<at>Officetracker</at>":                         "",
+	} {
+		if got := teamsText(in); got != want {
+			t.Errorf("teamsText(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestTeamsReply(t *testing.T) {
+	if got, want := string(TeamsReply("a\nb")), `{"type":"message","text":"a\n\nb"}`; got != want {
+		t.Errorf("TeamsReply = %s, want %s", got, want)
+	}
+}
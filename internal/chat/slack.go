@@ -0,0 +1,81 @@
+package chat
+
+import (
+	"crypto/hmac"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/baely/officetracker/pkg/model"
+)
+
+const (
+	// SlackSignatureHeader carries "v0=" and the hex HMAC-SHA256 of
+	// "v0:<timestamp>:<body>" keyed with the signing secret.
+	SlackSignatureHeader = "X-Slack-Signature"
+	// SlackTimestampHeader carries when Slack sent the request, in Unix seconds.
+	SlackTimestampHeader = "X-Slack-Request-Timestamp"
+)
+
+// SignSlack returns the signature header Slack would send for body at t. It's
+// the counterpart of ParseSlack, for tests and local stand-ins.
+func SignSlack(secret string, t time.Time, body []byte) string {
+	return "v0=" + hex.EncodeToString(mac([]byte(secret), slackBase(t.Unix(), body)))
+}
+
+// ParseSlack verifies a slash command request from Slack and returns the
+// command it carries. body is the raw form-encoded request body.
+func ParseSlack(secret string, header http.Header, body []byte, now time.Time) (Command, error) {
+	ts, err := strconv.ParseInt(header.Get(SlackTimestampHeader), 10, 64)
+	if err != nil {
+		return Command{}, fmt.Errorf("%w: malformed timestamp", ErrInvalidSignature)
+	}
+	if age := now.Sub(time.Unix(ts, 0)); age > Tolerance || age < -Tolerance {
+		return Command{}, fmt.Errorf("%w: sent %s ago", ErrInvalidSignature, age.Round(time.Second))
+	}
+	sig, ok := strings.CutPrefix(header.Get(SlackSignatureHeader), "v0=")
+	got, err := hex.DecodeString(sig)
+	if !ok || err != nil {
+		return Command{}, fmt.Errorf("%w: malformed header", ErrInvalidSignature)
+	}
+	if !hmac.Equal(got, mac([]byte(secret), slackBase(ts, body))) {
+		return Command{}, ErrInvalidSignature
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return Command{}, fmt.Errorf("failed to parse slack command: %w", err)
+	}
+	cmd := Command{
+		Account: model.ChatAccount{
+			Platform: model.ChatPlatformSlack,
+			TeamID:   form.Get("team_id"),
+			UserID:   form.Get("user_id"),
+			Name:     form.Get("user_name"),
+		},
+		Text: strings.TrimSpace(form.Get("text")),
+	}
+	if cmd.Account.TeamID == "" || cmd.Account.UserID == "" {
+		return Command{}, fmt.Errorf("slack command is missing team_id or user_id")
+	}
+	return cmd, nil
+}
+
+// SlackReply is the response body for a slash command. It's only shown to the
+// user who sent the command.
+func SlackReply(text string) []byte {
+	b, _ := json.Marshal(struct {
+		ResponseType string `json:"response_type"`
+		Text         string `json:"text"`
+	}{"ephemeral", text})
+	return b
+}
+
+func slackBase(ts int64, body []byte) []byte {
+	return append([]byte(fmt.Sprintf("v0:%d:", ts)), body...)
+}
@@ -0,0 +1,104 @@
+package chat
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/baely/officetracker/pkg/model"
+)
+
+// TeamsAuthorizationHeader carries "HMAC " and the base64 HMAC-SHA256 of the
+// body, keyed with the base64-decoded security token.
+const TeamsAuthorizationHeader = "Authorization"
+
+var (
+	// Teams includes the @mention of the webhook in the message text.
+	teamsMention = regexp.MustCompile(`(?s)<at>.*?</at>`)
+	teamsTag     = regexp.MustCompile(`(?s)<[^>]*>`)
+)
+
+// SignTeams returns the Authorization header Teams would send for body. It's
+// the counterpart of ParseTeams, for tests and local stand-ins.
+func SignTeams(secret string, body []byte) (string, error) {
+	key, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil {
+		return "", fmt.Errorf("teams secret is not base64: %w", err)
+	}
+	return "HMAC " + base64.StdEncoding.EncodeToString(mac(key, body)), nil
+}
+
+// ParseTeams verifies a message from a Teams outgoing webhook and returns the
+// command it carries, without the @mention. body is the raw JSON activity.
+func ParseTeams(secret string, header http.Header, body []byte) (Command, error) {
+	key, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil {
+		return Command{}, fmt.Errorf("teams secret is not base64: %w", err)
+	}
+	sig, ok := strings.CutPrefix(header.Get(TeamsAuthorizationHeader), "HMAC ")
+	got, err := base64.StdEncoding.DecodeString(sig)
+	if !ok || err != nil {
+		return Command{}, fmt.Errorf("%w: malformed header", ErrInvalidSignature)
+	}
+	if !hmac.Equal(got, mac(key, body)) {
+		return Command{}, ErrInvalidSignature
+	}
+
+	var activity struct {
+		Text string `json:"text"`
+		From struct {
+			ID          string `json:"id"`
+			AADObjectID string `json:"aadObjectId"`
+			Name        string `json:"name"`
+		} `json:"from"`
+		ChannelData struct {
+			Tenant struct {
+				ID string `json:"id"`
+			} `json:"tenant"`
+		} `json:"channelData"`
+	}
+	if err := json.Unmarshal(body, &activity); err != nil {
+		return Command{}, fmt.Errorf("failed to parse teams activity: %w", err)
+	}
+	// The Entra object ID is stable across channels; the Teams ID is not
+	// always present alongside it.
+	userID := activity.From.AADObjectID
+	if userID == "" {
+		userID = activity.From.ID
+	}
+	cmd := Command{
+		Account: model.ChatAccount{
+			Platform: model.ChatPlatformTeams,
+			TeamID:   activity.ChannelData.Tenant.ID,
+			UserID:   userID,
+			Name:     activity.From.Name,
+		},
+		Text: teamsText(activity.Text),
+	}
+	if cmd.Account.TeamID == "" || cmd.Account.UserID == "" {
+		return Command{}, fmt.Errorf("teams activity is missing tenant or sender")
+	}
+	return cmd, nil
+}
+
+// TeamsReply is the response body for an outgoing webhook message.
+func TeamsReply(text string) []byte {
+	// Teams renders the reply as markdown, where single newlines are dropped.
+	b, _ := json.Marshal(struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}{"message", strings.ReplaceAll(text, "\n", "\n\n")})
+	return b
+}
+
+func teamsText(s string) string {
+	s = teamsMention.ReplaceAllString(s, " ")
+	s = teamsTag.ReplaceAllString(s, " ")
+	// Fields also splits on the non-breaking spaces Teams pads mentions with.
+	return strings.Join(strings.Fields(html.UnescapeString(s)), " ")
+}
@@ -15,6 +15,10 @@ type IntegratedApp struct {
 	Redis      Redis    `envconfig:"REDIS"`
 	Github     Github   `envconfig:"GITHUB"`
 	Auth0      Auth0    `envconfig:"AUTH0"`
+	Chat       Chat     `envconfig:"CHAT"`
+	SMTP       SMTP     `envconfig:"SMTP"`
+	WebPush    WebPush  `envconfig:"WEBPUSH"`
+	Expo       Expo     `envconfig:"EXPO"`
 	SigningKey string   `envconfig:"SIGNING_KEY"`
 }
 
@@ -23,8 +27,12 @@ func (a IntegratedApp) GetApp() App {
 }
 
 type StandaloneApp struct {
-	App    App    `envconfig:"APP"`
-	SQLite SQLite `envconfig:"SQLITE"`
+	App     App     `envconfig:"APP"`
+	SQLite  SQLite  `envconfig:"SQLITE"`
+	Chat    Chat    `envconfig:"CHAT"`
+	SMTP    SMTP    `envconfig:"SMTP"`
+	WebPush WebPush `envconfig:"WEBPUSH"`
+	Expo    Expo    `envconfig:"EXPO"`
 }
 
 func (a StandaloneApp) GetApp() App {
@@ -34,6 +42,11 @@ func (a StandaloneApp) GetApp() App {
 type App struct {
 	Env  string `envconfig:"ENV"`
 	Port string `envconfig:"PORT"`
+	// TrustProxy matches office networks against the rightmost
+	// X-Forwarded-For entry rather than the connection's address. Only set it
+	// behind a proxy that appends one, like Cloud Run's front end; otherwise
+	// clients can send any address.
+	TrustProxy bool `envconfig:"TRUST_PROXY"`
 }
 
 type Domain struct {
@@ -76,6 +89,48 @@ type Auth0 struct {
 	NativeClientID string `envconfig:"NATIVE_CLIENT_ID"`
 }
 
+// Chat holds the secrets chat platforms sign slash commands with. A platform
+// without a secret is disabled.
+type Chat struct {
+	SlackSigningSecret string `envconfig:"SLACK_SIGNING_SECRET"`
+	// TeamsSecret is the base64 security token Teams shows when the outgoing
+	// webhook is created.
+	TeamsSecret string `envconfig:"TEAMS_SECRET"`
+}
+
+// SMTP is the mail server notifications are sent through. Notifications are
+// disabled without a host.
+type SMTP struct {
+	Host string `envconfig:"HOST"`
+	// Port defaults to 587, the submission port.
+	Port     string `envconfig:"PORT"`
+	Username string `envconfig:"USERNAME"`
+	Password string `envconfig:"PASSWORD"`
+	// From is the address notifications are sent from.
+	From string `envconfig:"FROM"`
+}
+
+// WebPush is the VAPID key pair push notifications are signed with, both
+// base64url encoded: the uncompressed P-256 public key and the raw private
+// key. Push notifications are disabled without keys.
+type WebPush struct {
+	PublicKey  string `envconfig:"PUBLIC_KEY"`
+	PrivateKey string `envconfig:"PRIVATE_KEY"`
+	// Subject is a mailto: or https: contact the push services can reach the
+	// sender at.
+	Subject string `envconfig:"SUBJECT"`
+}
+
+// Expo is the push service the mobile app's notifications are sent through.
+// They are disabled without a URL.
+type Expo struct {
+	// PushURL is Expo's send endpoint, https://exp.host/--/api/v2/push/send.
+	PushURL string `envconfig:"PUSH_URL"`
+	// AccessToken is needed once push security is turned on for the Expo
+	// project.
+	AccessToken string `envconfig:"ACCESS_TOKEN"`
+}
+
 func LoadIntegratedApp() (IntegratedApp, error) {
 	var cfg IntegratedApp
 	err := envconfig.Process("", &cfg)
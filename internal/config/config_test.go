@@ -26,6 +26,13 @@ func TestLoadIntegratedApp(t *testing.T) {
 	t.Setenv("AUTH0_CLIENT_ID", "cid")
 	t.Setenv("AUTH0_CLIENT_SECRET", "csecret")
 	t.Setenv("AUTH0_NATIVE_CLIENT_ID", "native-cid")
+	t.Setenv("CHAT_SLACK_SIGNING_SECRET", "slack-secret")
+	t.Setenv("CHAT_TEAMS_SECRET", "dGVhbXM=")
+	t.Setenv("SMTP_HOST", "smtp.example.com")
+	t.Setenv("SMTP_FROM", "Officetracker <noreply@example.com>")
+	t.Setenv("WEBPUSH_PUBLIC_KEY", "BPub")
+	t.Setenv("WEBPUSH_SUBJECT", "mailto:ops@example.com")
+	t.Setenv("EXPO_PUSH_URL", "https://exp.host/--/api/v2/push/send")
 	t.Setenv("SIGNING_KEY", "super-secret-key")
 
 	cfg, err := LoadIntegratedApp()
@@ -49,6 +56,13 @@ func TestLoadIntegratedApp(t *testing.T) {
 		{"Redis.DB", cfg.Redis.DB, 3},
 		{"Auth0.Domain", cfg.Auth0.Domain, "example.auth0.com"},
 		{"Auth0.NativeClientID", cfg.Auth0.NativeClientID, "native-cid"},
+		{"Chat.SlackSigningSecret", cfg.Chat.SlackSigningSecret, "slack-secret"},
+		{"Chat.TeamsSecret", cfg.Chat.TeamsSecret, "dGVhbXM="},
+		{"SMTP.Host", cfg.SMTP.Host, "smtp.example.com"},
+		{"SMTP.From", cfg.SMTP.From, "Officetracker <noreply@example.com>"},
+		{"WebPush.PublicKey", cfg.WebPush.PublicKey, "BPub"},
+		{"WebPush.Subject", cfg.WebPush.Subject, "mailto:ops@example.com"},
+		{"Expo.PushURL", cfg.Expo.PushURL, "https://exp.host/--/api/v2/push/send"},
 		{"SigningKey", cfg.SigningKey, "super-secret-key"},
 	}
 	for _, c := range checks {
@@ -11,6 +11,7 @@ const (
 	CtxKey           = "ctx"
 	CtxUserIDKey     = "userID"
 	CtxAuthMethodKey = "auth"
+	CtxTokenIDKey    = "tokenID"
 )
 
 func MapCtx(ctx context.Context) CtxValue {
@@ -1,16 +1,43 @@
 package database
 
 import (
+	"database/sql"
+	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/baely/officetracker/internal/util"
 	"github.com/baely/officetracker/pkg/model"
 )
 
 var (
-	ErrNoUser = fmt.Errorf("no user found")
+	ErrNoUser         = fmt.Errorf("no user found")
+	ErrNoCustomState  = fmt.Errorf("no custom state found")
+	ErrNoLocation     = fmt.Errorf("no location found")
+	ErrNoScheduleRule = fmt.Errorf("no schedule rule found")
+	ErrNoNetworkRule  = fmt.Errorf("no network rule found")
+	ErrNoToken        = fmt.Errorf("no active token found")
+	ErrNoWebhook      = fmt.Errorf("no webhook found")
+	ErrNoChatAccount  = fmt.Errorf("no linked chat account found")
+	ErrNoChatLink     = fmt.Errorf("no chat link found")
+	ErrNoPushSub      = fmt.Errorf("no push subscription found")
+	ErrNoPushToken    = fmt.Errorf("no push token found")
+	ErrInvalidEntry   = fmt.Errorf("invalid entry")
 )
 
+// checkEntry keeps dates that don't exist and states that can't be stored out
+// of the entries table, whichever path the write came through.
+func checkEntry(day, month, year int, state model.State) error {
+	if err := util.ValidDate(year, month, day); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidEntry, err)
+	}
+	if !state.Valid() {
+		return fmt.Errorf("%w: unknown state %d", ErrInvalidEntry, state)
+	}
+	return nil
+}
+
 type TokenMetadata struct {
 	TokenID   int
 	Name      string
@@ -18,6 +45,35 @@ type TokenMetadata struct {
 	Active    bool
 }
 
+// DayChange is a row in the append-only attendance history: a single write that
+// changed the stored state of a day, and who made it.
+type DayChange struct {
+	Day        int
+	Month      int
+	Year       int
+	OldState   model.State
+	NewState   model.State
+	ChangedAt  time.Time
+	AuthMethod string
+	TokenID    int
+	Via        string
+}
+
+// Entry is a day's state with its date, for writes that span months.
+type Entry struct {
+	Day   int
+	Month int
+	Year  int
+	State model.DayState
+}
+
+// WebhookDelivery is a queued or attempted webhook delivery with the user it
+// belongs to.
+type WebhookDelivery struct {
+	UserID int
+	model.WebhookDelivery
+}
+
 type Databaser interface {
 	SaveDay(userID int, day int, month int, year int, state model.DayState) error
 	GetDay(userID int, day int, month int, year int) (model.DayState, error)
@@ -28,8 +84,19 @@ type Databaser interface {
 	GetNote(userID int, month int, year int) (model.Note, error)
 	GetNotes(userID int, year int, startMonth int) (map[int]model.Note, error)
 
+	// Attendance history. Changes are only ever appended, by SaveEntries,
+	// never updated or deleted, so the log can be used to show when a day
+	// was last edited.
+	GetDayHistory(userID int, day int, month int, year int) ([]DayChange, error)
+
+	// SaveEntries saves entries across any number of months together with
+	// the history changes they make, in a single transaction: either all of
+	// them are written or none are.
+	SaveEntries(userID int, entries []Entry, changes []DayChange) error
+
 	GetUserByGHID(ghID string) (int, error)
 	GetUserBySecret(secret string) (int, error)
+	GetTokenIDBySecret(secret string) (int, error)
 	GetUserLinkedAccounts(userID int) ([]model.LinkedAccount, error)
 
 	GetUserByAuth0Sub(sub string) (int, error)
@@ -45,13 +112,131 @@ type Databaser interface {
 	SaveCalendarPreferences(userID int, prefs model.CalendarPreferences) error
 	GetTargetPreferences(userID int) (model.TargetPreferences, error)
 	SaveTargetPreferences(userID int, prefs model.TargetPreferences) error
+	GetMaterialisePreferences(userID int) (model.MaterialisePreferences, error)
+	SaveMaterialisePreferences(userID int, prefs model.MaterialisePreferences) error
+	// ListMaterialiseUsers returns the users who have opted in to having
+	// scheduled days saved as entries.
+	ListMaterialiseUsers() ([]int, error)
+	GetNotificationPreferences(userID int) (model.NotificationPreferences, error)
+	SaveNotificationPreferences(userID int, prefs model.NotificationPreferences) error
+	// ListNotificationUsers returns the users who have switched on any email
+	// or push notification, or registered a device for the reminder.
+	ListNotificationUsers() ([]int, error)
+
+	// Custom states. GetCustomStates includes archived states so old entries
+	// can still be described. SaveCustomState assigns the next free ID (from
+	// model.CustomStateBase) when state.ID is zero, and otherwise updates the
+	// existing state, returning ErrNoCustomState if there is none. IDs are
+	// never reused, so archiving is the only way to remove a state.
+	GetCustomStates(userID int) (model.CustomStates, error)
+	SaveCustomState(userID int, state model.CustomState) (model.CustomState, error)
+
+	// Locations work like custom states: GetLocations includes archived
+	// locations, and SaveLocation assigns the next ID (from 1) when location.ID
+	// is zero or updates the existing location, returning ErrNoLocation if
+	// there is none.
+	GetLocations(userID int) (model.Locations, error)
+	SaveLocation(userID int, location model.Location) (model.Location, error)
+
+	// Schedule rules come back in ID order, which is the order they apply.
+	// SaveScheduleRule assigns the next ID (from 1) when rule.ID is zero and
+	// otherwise updates the existing rule. Rules don't appear on entries, so
+	// DeleteScheduleRule removes them outright. Both return ErrNoScheduleRule
+	// for an unknown rule.
+	GetScheduleRules(userID int) (model.ScheduleRules, error)
+	SaveScheduleRule(userID int, rule model.ScheduleRule) (model.ScheduleRule, error)
+	DeleteScheduleRule(userID int, ruleID int) error
+
+	// Network rules work like schedule rules: they come back in ID order,
+	// SaveNetworkRule assigns the next ID (from 1) when rule.ID is zero and
+	// otherwise updates the existing rule, and DeleteNetworkRule removes it.
+	// Both return ErrNoNetworkRule for an unknown rule.
+	GetNetworkRules(userID int) (model.NetworkRules, error)
+	SaveNetworkRule(userID int, rule model.NetworkRule) (model.NetworkRule, error)
+	DeleteNetworkRule(userID int, ruleID int) error
+
+	// GetScheduleHistory returns the user's schedule versions oldest first,
+	// with the open-ended version (no effective date) before the rest.
+	// SaveScheduleVersions saves versions, each replacing any with the same
+	// effective date, and stores latest as the user's schedule preferences,
+	// all in a single transaction.
+	GetScheduleHistory(userID int) (model.ScheduleHistory, error)
+	SaveScheduleVersions(userID int, latest model.SchedulePreferences, versions ...model.ScheduleVersion) error
 
 	SaveSecret(userID int, secret string, name string) error
 	ListActiveTokens(userID int) ([]TokenMetadata, error)
+	// RevokeToken deactivates a token, returning ErrNoToken if the user has no
+	// active token with that ID.
 	RevokeToken(userID int, tokenID int) error
 	// RevokeSecretByValue deactivates the secret with the given value.
 	RevokeSecretByValue(secret string) error
 
+	// Webhooks are stored with their secrets. SaveWebhook assigns the next ID
+	// (from 1) when webhook.ID is zero, and otherwise updates the URL, events
+	// and disabled flag, keeping the secret. DeleteWebhook also removes the
+	// webhook's deliveries. Both return ErrNoWebhook for an unknown webhook.
+	GetWebhooks(userID int) (model.Webhooks, error)
+	SaveWebhook(userID int, webhook model.Webhook) (model.Webhook, error)
+	DeleteWebhook(userID int, webhookID int) error
+
+	// Push subscriptions are stored with their keys. SavePushSubscription
+	// assigns the next ID (from 1) and sets CreatedAt, replacing any
+	// subscription with the same endpoint, whichever user it belonged to, as
+	// a browser only has one. DeletePushSubscription returns ErrNoPushSub for
+	// an unknown subscription.
+	GetPushSubscriptions(userID int) (model.PushSubscriptions, error)
+	SavePushSubscription(userID int, sub model.PushSubscription, now time.Time) (model.PushSubscription, error)
+	DeletePushSubscription(userID int, subscriptionID int) error
+
+	// Push tokens are the mobile app's devices. SavePushToken records the API
+	// token (0 for none) the device registered with, assigns the next ID
+	// (from 1) and sets CreatedAt, replacing any registration of the same
+	// token. Revoking an API token deletes the devices registered with it.
+	// DeletePushToken returns ErrNoPushToken for an unknown device.
+	GetPushTokens(userID int) (model.PushTokens, error)
+	SavePushToken(userID int, authTokenID int, token model.PushToken, now time.Time) (model.PushToken, error)
+	DeletePushToken(userID int, pushTokenID int) error
+
+	// Webhook deliveries form both the delivery queue and the delivery log.
+	// QueueWebhookDeliveries adds pending deliveries, due at their
+	// CreatedAt. ClaimWebhookDeliveries returns up to limit pending deliveries
+	// due at now, oldest first, and pushes their next attempt back to until so
+	// no other worker sends them in the meantime. SaveWebhookAttempt records
+	// the outcome of an attempt: the status, attempts, next attempt, response
+	// code and error.
+	QueueWebhookDeliveries(deliveries []WebhookDelivery) error
+	ClaimWebhookDeliveries(now, until time.Time, limit int) ([]WebhookDelivery, error)
+	SaveWebhookAttempt(delivery WebhookDelivery) error
+	// GetWebhookDeliveries returns a webhook's latest deliveries, newest
+	// first.
+	GetWebhookDeliveries(userID int, webhookID int, limit int) ([]model.WebhookDelivery, error)
+	// PruneWebhookDeliveries deletes delivered and failed deliveries created
+	// before before, returning how many it deleted.
+	PruneWebhookDeliveries(before time.Time) (int, error)
+
+	// Chat accounts are linked to users with a single-use code, sent to the
+	// chat user and opened while signed in. SaveChatLink stores a code for
+	// account until expires, clearing out expired codes. GetChatLink returns
+	// the account of an unexpired code and LinkChatAccount links it to the
+	// user, using the code up; both return ErrNoChatLink for an unknown or
+	// expired code. GetChatUser and UnlinkChatAccount return ErrNoChatAccount
+	// for an account that isn't linked.
+	GetChatUser(account model.ChatAccount) (int, error)
+	SaveChatLink(code string, account model.ChatAccount, expires time.Time) error
+	GetChatLink(code string, now time.Time) (model.ChatAccount, error)
+	LinkChatAccount(userID int, code string, now time.Time) (model.ChatAccount, error)
+	UnlinkChatAccount(account model.ChatAccount) error
+
+	// Sent notifications are logged by kind and period, such as a date or
+	// month, so each goes out once however often the job runs.
+	// ClaimNotification logs one as sent at now, reporting false if it
+	// already was. ReleaseNotification removes it again after a failed send
+	// so the next run retries it. PruneNotifications deletes ones sent before
+	// the given time.
+	ClaimNotification(userID int, kind string, period string, now time.Time) (bool, error)
+	ReleaseNotification(userID int, kind string, period string) error
+	PruneNotifications(before time.Time) (int, error)
+
 	IsUserSuspended(userID int) (bool, error)
 
 	// Stats dashboard snapshots.
@@ -62,4 +247,165 @@ type Databaser interface {
 	// non-user-identifiable aggregates for the public dashboard.
 	CountTrackedDays() (int, error)
 	CountEntriesByState() (map[model.State]int, error)
+	// CountCustomEntriesByAttendance counts entries using a custom state,
+	// grouped by how their owner's state counts towards attendance.
+	CountCustomEntriesByAttendance() (map[model.Attendance]int, error)
+}
+
+// LoadSchedule loads the user's weekly schedule, its history and schedule
+// rules.
+func LoadSchedule(db Databaser, userID int) (util.Schedule, error) {
+	prefs, err := db.GetSchedulePreferences(userID)
+	if err != nil {
+		return util.Schedule{}, fmt.Errorf("failed to get schedule preferences: %w", err)
+	}
+
+	history, err := db.GetScheduleHistory(userID)
+	if err != nil {
+		return util.Schedule{}, fmt.Errorf("failed to get schedule history: %w", err)
+	}
+
+	rules, err := db.GetScheduleRules(userID)
+	if err != nil {
+		return util.Schedule{}, fmt.Errorf("failed to get schedule rules: %w", err)
+	}
+
+	return util.NewSchedule(prefs, history, rules), nil
+}
+
+// scanDayState reads an entry's state, source, updated_at, note, location and
+// unconfirmed columns, in that order, via scan. Source and updated_at are NULL
+// for entries written before they were recorded.
+func scanDayState(scan func(dest ...any) error) (model.DayState, error) {
+	var state model.DayState
+	var source sql.NullString
+	var updatedAt sql.NullTime
+	if err := scan(&state.State, &source, &updatedAt, &state.Note, &state.LocationID, &state.Unconfirmed); err != nil {
+		return model.DayState{}, err
+	}
+	state.Source = model.Source(source.String)
+	if updatedAt.Valid {
+		state.UpdatedAt = updatedAt.Time.UTC()
+	}
+	return state, nil
+}
+
+// scanLocation reads a location's id, name, latitude, longitude, radius and
+// archived flag.
+func scanLocation(scan func(dest ...any) error) (model.Location, error) {
+	var location model.Location
+	var lat, lng sql.NullFloat64
+	if err := scan(&location.ID, &location.Name, &lat, &lng, &location.Radius, &location.Archived); err != nil {
+		return model.Location{}, err
+	}
+	if lat.Valid && lng.Valid {
+		location.Latitude, location.Longitude = &lat.Float64, &lng.Float64
+	}
+	return location, nil
+}
+
+// scanScheduleRule reads a rule's id, state, rrule and start and end dates as
+// YYYY-MM-DD, where a NULL end means the rule is open-ended.
+func scanScheduleRule(scan func(dest ...any) error) (model.ScheduleRule, error) {
+	var rule model.ScheduleRule
+	var end sql.NullString
+	if err := scan(&rule.ID, &rule.State, &rule.RRule, &rule.Start, &end); err != nil {
+		return model.ScheduleRule{}, err
+	}
+	rule.End = end.String
+	return rule, nil
+}
+
+// scanNetworkRule reads a rule's id, kind, value and location id.
+func scanNetworkRule(scan func(dest ...any) error) (model.NetworkRule, error) {
+	var rule model.NetworkRule
+	if err := scan(&rule.ID, &rule.Kind, &rule.Value, &rule.LocationID); err != nil {
+		return model.NetworkRule{}, err
+	}
+	return rule, nil
+}
+
+// scanScheduleVersion reads a version's effective date, which may be NULL,
+// followed by its Monday to Sunday states.
+func scanScheduleVersion(scan func(dest ...any) error) (model.ScheduleVersion, error) {
+	var v model.ScheduleVersion
+	var from sql.NullString
+	s := &v.Schedule
+	if err := scan(&from, &s.Monday, &s.Tuesday, &s.Wednesday, &s.Thursday, &s.Friday, &s.Saturday, &s.Sunday); err != nil {
+		return model.ScheduleVersion{}, err
+	}
+	v.EffectiveFrom = from.String
+	return v, nil
+}
+
+// scanWebhookDelivery reads a delivery's id, user id, webhook id, event,
+// payload, status, attempts, next attempt (NULL once it's finished), response
+// code, error and creation time.
+func scanWebhookDelivery(scan func(dest ...any) error) (WebhookDelivery, error) {
+	var d WebhookDelivery
+	var payload string
+	var next sql.NullTime
+	if err := scan(&d.ID, &d.UserID, &d.WebhookID, &d.Event, &payload, &d.Status, &d.Attempts, &next, &d.ResponseCode, &d.Error, &d.CreatedAt); err != nil {
+		return WebhookDelivery{}, err
+	}
+	d.Payload = json.RawMessage(payload)
+	if next.Valid {
+		d.NextAttemptAt = next.Time.UTC()
+	}
+	d.CreatedAt = d.CreatedAt.UTC()
+	return d, nil
+}
+
+// scanPushSubscription reads a subscription's id, endpoint, keys, device,
+// expiry (NULL if the push service didn't give one) and creation time.
+func scanPushSubscription(scan func(dest ...any) error) (model.PushSubscription, error) {
+	var sub model.PushSubscription
+	var expires sql.NullTime
+	if err := scan(&sub.ID, &sub.Endpoint, &sub.Keys.P256dh, &sub.Keys.Auth, &sub.Device, &expires, &sub.CreatedAt); err != nil {
+		return model.PushSubscription{}, err
+	}
+	if expires.Valid {
+		sub.ExpiresAt = expires.Time.UTC()
+	}
+	sub.CreatedAt = sub.CreatedAt.UTC()
+	return sub, nil
+}
+
+// joinEvents and splitEvents store a webhook's events as a comma-separated
+// list.
+func joinEvents(events []model.WebhookEvent) string {
+	s := make([]string, len(events))
+	for i, e := range events {
+		s[i] = string(e)
+	}
+	return strings.Join(s, ",")
+}
+
+func splitEvents(s string) []model.WebhookEvent {
+	var events []model.WebhookEvent
+	for _, e := range strings.Split(s, ",") {
+		if e != "" {
+			events = append(events, model.WebhookEvent(e))
+		}
+	}
+	return events
+}
+
+func nullFloat(f *float64) sql.NullFloat64 {
+	if f == nil {
+		return sql.NullFloat64{}
+	}
+	return sql.NullFloat64{Float64: *f, Valid: true}
+}
+
+func nullSource(source model.Source) sql.NullString {
+	return sql.NullString{String: string(source), Valid: source != ""}
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t.UTC(), Valid: !t.IsZero()}
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
 }
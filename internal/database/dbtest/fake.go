@@ -9,6 +9,9 @@
 package dbtest
 
 import (
+	"maps"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/baely/officetracker/internal/database"
@@ -33,13 +36,47 @@ type monthKey struct {
 // preferences and an October tracking-year start. Callers may seed data with
 // the Save* methods and inject errors via Errs.
 type Fake struct {
-	days  map[dayKey]model.DayState
-	notes map[monthKey]model.Note
-
-	theme  model.ThemePreferences
-	sched  model.SchedulePreferences
-	cal    model.CalendarPreferences
-	target model.TargetPreferences
+	days    map[dayKey]model.DayState
+	notes   map[monthKey]model.Note
+	history []database.DayChange
+
+	theme    model.ThemePreferences
+	sched    model.SchedulePreferences
+	cal      model.CalendarPreferences
+	target   model.TargetPreferences
+	custom   model.CustomStates
+	places   model.Locations
+	rules    model.ScheduleRules
+	ruleID   int
+	networks model.NetworkRules
+	netID    int
+	versions model.ScheduleHistory
+	mat      model.MaterialisePreferences
+	notify   model.NotificationPreferences
+	hooks    model.Webhooks
+	hookID   int
+	sendID   int
+
+	// Deliveries holds every queued webhook delivery, in the order queued.
+	Deliveries []database.WebhookDelivery
+
+	// ChatUsers maps linked chat accounts, without their names, to users.
+	ChatUsers map[model.ChatAccount]int
+	chatLinks map[string]chatLink
+
+	// PushSubs holds the push subscriptions, in ID order.
+	PushSubs model.PushSubscriptions
+	pushID   int
+
+	// PushTokens holds the mobile devices, in ID order. pushTokenAuth maps
+	// each to the API token it registered with.
+	PushTokens    model.PushTokens
+	pushTokenAuth map[int]int
+	pushTokenID   int
+
+	// Notifications maps the kind and period of each logged notification to
+	// when it was sent.
+	Notifications map[[2]string]time.Time
 
 	// LinkedAccounts is returned verbatim by GetUserLinkedAccounts.
 	LinkedAccounts []model.LinkedAccount
@@ -55,6 +92,7 @@ type Fake struct {
 	// User-resolution hooks. When nil a sensible default is used
 	// (see the individual methods).
 	GetUserBySecretFn    func(secret string) (int, error)
+	GetTokenIDBySecretFn func(secret string) (int, error)
 	GetUserByGHIDFn      func(ghID string) (int, error)
 	GetUserByAuth0SubFn  func(sub string) (int, error)
 	SaveUserByAuth0SubFn func(sub, profile string) (int, error)
@@ -72,6 +110,11 @@ type Fake struct {
 	Errs map[string]error
 }
 
+type chatLink struct {
+	account model.ChatAccount
+	expires time.Time
+}
+
 // SavedSecret records a SaveSecret call.
 type SavedSecret struct {
 	UserID int
@@ -105,6 +148,11 @@ func New() *Fake {
 		notes: make(map[monthKey]model.Note),
 		theme: model.ThemePreferences{Theme: "default"},
 		cal:   model.CalendarPreferences{TrackingYearStartMonth: model.DefaultTrackingYearStartMonth},
+
+		ChatUsers: make(map[model.ChatAccount]int),
+		chatLinks: make(map[string]chatLink),
+
+		Notifications: make(map[[2]string]time.Time),
 	}
 }
 
@@ -217,6 +265,30 @@ func (f *Fake) GetNotes(_ int, year, startMonth int) (map[int]model.Note, error)
 	return out, nil
 }
 
+func (f *Fake) SaveEntries(_ int, entries []database.Entry, changes []database.DayChange) error {
+	if err := f.fail("SaveEntries"); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		f.days[dayKey{e.Year, e.Month, e.Day}] = e.State
+	}
+	f.history = append(f.history, changes...)
+	return nil
+}
+
+func (f *Fake) GetDayHistory(_ int, day, month, year int) ([]database.DayChange, error) {
+	if err := f.fail("GetDayHistory"); err != nil {
+		return nil, err
+	}
+	var out []database.DayChange
+	for _, c := range f.history {
+		if c.Year == year && c.Month == month && c.Day == day {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
 func (f *Fake) GetUserByGHID(ghID string) (int, error) {
 	if err := f.fail("GetUserByGHID"); err != nil {
 		return 0, err
@@ -237,6 +309,16 @@ func (f *Fake) GetUserBySecret(secret string) (int, error) {
 	return 0, database.ErrNoUser
 }
 
+func (f *Fake) GetTokenIDBySecret(secret string) (int, error) {
+	if err := f.fail("GetTokenIDBySecret"); err != nil {
+		return 0, err
+	}
+	if f.GetTokenIDBySecretFn != nil {
+		return f.GetTokenIDBySecretFn(secret)
+	}
+	return 0, database.ErrNoUser
+}
+
 func (f *Fake) GetUserLinkedAccounts(_ int) ([]model.LinkedAccount, error) {
 	if err := f.fail("GetUserLinkedAccounts"); err != nil {
 		return nil, err
@@ -329,7 +411,7 @@ func (f *Fake) GetTargetPreferences(_ int) (model.TargetPreferences, error) {
 	if err := f.fail("GetTargetPreferences"); err != nil {
 		return model.TargetPreferences{}, err
 	}
-	return f.target, nil
+	return util.NormaliseTargetPreferences(f.target), nil
 }
 
 func (f *Fake) SaveTargetPreferences(_ int, prefs model.TargetPreferences) error {
@@ -340,6 +422,217 @@ func (f *Fake) SaveTargetPreferences(_ int, prefs model.TargetPreferences) error
 	return nil
 }
 
+func (f *Fake) GetMaterialisePreferences(_ int) (model.MaterialisePreferences, error) {
+	if err := f.fail("GetMaterialisePreferences"); err != nil {
+		return model.MaterialisePreferences{}, err
+	}
+	return f.mat, nil
+}
+
+func (f *Fake) SaveMaterialisePreferences(_ int, prefs model.MaterialisePreferences) error {
+	if err := f.fail("SaveMaterialisePreferences"); err != nil {
+		return err
+	}
+	f.mat = prefs
+	return nil
+}
+
+// ListMaterialiseUsers returns user 1 when materialising is switched on.
+func (f *Fake) ListMaterialiseUsers() ([]int, error) {
+	if err := f.fail("ListMaterialiseUsers"); err != nil {
+		return nil, err
+	}
+	if f.mat.Mode == model.MaterialiseOff {
+		return nil, nil
+	}
+	return []int{1}, nil
+}
+
+func (f *Fake) GetNotificationPreferences(_ int) (model.NotificationPreferences, error) {
+	if err := f.fail("GetNotificationPreferences"); err != nil {
+		return model.NotificationPreferences{}, err
+	}
+	return f.notify, nil
+}
+
+func (f *Fake) SaveNotificationPreferences(_ int, prefs model.NotificationPreferences) error {
+	if err := f.fail("SaveNotificationPreferences"); err != nil {
+		return err
+	}
+	f.notify = prefs
+	return nil
+}
+
+// ListNotificationUsers returns user 1 when any email or push notification
+// is switched on, or a device is registered for the reminder.
+func (f *Fake) ListNotificationUsers() ([]int, error) {
+	if err := f.fail("ListNotificationUsers"); err != nil {
+		return nil, err
+	}
+	reminders := slices.ContainsFunc(f.PushTokens, func(t model.PushToken) bool { return t.Reminder })
+	if !(f.notify.Email != "" && f.notify.Enabled()) && !f.notify.PushEnabled() && !reminders {
+		return nil, nil
+	}
+	return []int{1}, nil
+}
+
+func (f *Fake) GetCustomStates(_ int) (model.CustomStates, error) {
+	if err := f.fail("GetCustomStates"); err != nil {
+		return nil, err
+	}
+	return slices.Clone(f.custom), nil
+}
+
+func (f *Fake) SaveCustomState(_ int, state model.CustomState) (model.CustomState, error) {
+	if err := f.fail("SaveCustomState"); err != nil {
+		return model.CustomState{}, err
+	}
+	if state.ID == 0 {
+		state.ID = model.CustomStateBase
+		for _, existing := range f.custom {
+			if existing.ID >= state.ID {
+				state.ID = existing.ID + 1
+			}
+		}
+		f.custom = append(f.custom, state)
+		return state, nil
+	}
+	for i, existing := range f.custom {
+		if existing.ID == state.ID {
+			f.custom[i] = state
+			return state, nil
+		}
+	}
+	return model.CustomState{}, database.ErrNoCustomState
+}
+
+func (f *Fake) GetLocations(_ int) (model.Locations, error) {
+	if err := f.fail("GetLocations"); err != nil {
+		return nil, err
+	}
+	return slices.Clone(f.places), nil
+}
+
+func (f *Fake) SaveLocation(_ int, location model.Location) (model.Location, error) {
+	if err := f.fail("SaveLocation"); err != nil {
+		return model.Location{}, err
+	}
+	if location.ID == 0 {
+		location.ID = len(f.places) + 1
+		f.places = append(f.places, location)
+		return location, nil
+	}
+	for i, existing := range f.places {
+		if existing.ID == location.ID {
+			f.places[i] = location
+			return location, nil
+		}
+	}
+	return model.Location{}, database.ErrNoLocation
+}
+
+func (f *Fake) GetScheduleRules(_ int) (model.ScheduleRules, error) {
+	if err := f.fail("GetScheduleRules"); err != nil {
+		return nil, err
+	}
+	return slices.Clone(f.rules), nil
+}
+
+func (f *Fake) SaveScheduleRule(_ int, rule model.ScheduleRule) (model.ScheduleRule, error) {
+	if err := f.fail("SaveScheduleRule"); err != nil {
+		return model.ScheduleRule{}, err
+	}
+	if rule.ID == 0 {
+		f.ruleID++
+		rule.ID = f.ruleID
+		f.rules = append(f.rules, rule)
+		return rule, nil
+	}
+	for i, existing := range f.rules {
+		if existing.ID == rule.ID {
+			f.rules[i] = rule
+			return rule, nil
+		}
+	}
+	return model.ScheduleRule{}, database.ErrNoScheduleRule
+}
+
+func (f *Fake) DeleteScheduleRule(_ int, ruleID int) error {
+	if err := f.fail("DeleteScheduleRule"); err != nil {
+		return err
+	}
+	for i, existing := range f.rules {
+		if existing.ID == ruleID {
+			f.rules = slices.Delete(f.rules, i, i+1)
+			return nil
+		}
+	}
+	return database.ErrNoScheduleRule
+}
+
+func (f *Fake) GetNetworkRules(_ int) (model.NetworkRules, error) {
+	if err := f.fail("GetNetworkRules"); err != nil {
+		return nil, err
+	}
+	return slices.Clone(f.networks), nil
+}
+
+func (f *Fake) SaveNetworkRule(_ int, rule model.NetworkRule) (model.NetworkRule, error) {
+	if err := f.fail("SaveNetworkRule"); err != nil {
+		return model.NetworkRule{}, err
+	}
+	if rule.ID == 0 {
+		f.netID++
+		rule.ID = f.netID
+		f.networks = append(f.networks, rule)
+		return rule, nil
+	}
+	for i, existing := range f.networks {
+		if existing.ID == rule.ID {
+			f.networks[i] = rule
+			return rule, nil
+		}
+	}
+	return model.NetworkRule{}, database.ErrNoNetworkRule
+}
+
+func (f *Fake) DeleteNetworkRule(_ int, ruleID int) error {
+	if err := f.fail("DeleteNetworkRule"); err != nil {
+		return err
+	}
+	for i, existing := range f.networks {
+		if existing.ID == ruleID {
+			f.networks = slices.Delete(f.networks, i, i+1)
+			return nil
+		}
+	}
+	return database.ErrNoNetworkRule
+}
+
+func (f *Fake) GetScheduleHistory(_ int) (model.ScheduleHistory, error) {
+	if err := f.fail("GetScheduleHistory"); err != nil {
+		return nil, err
+	}
+	return slices.Clone(f.versions), nil
+}
+
+func (f *Fake) SaveScheduleVersions(_ int, latest model.SchedulePreferences, versions ...model.ScheduleVersion) error {
+	if err := f.fail("SaveScheduleVersions"); err != nil {
+		return err
+	}
+	for _, version := range versions {
+		f.versions = slices.DeleteFunc(f.versions, func(v model.ScheduleVersion) bool {
+			return v.EffectiveFrom == version.EffectiveFrom
+		})
+		f.versions = append(f.versions, version)
+	}
+	slices.SortFunc(f.versions, func(a, b model.ScheduleVersion) int {
+		return strings.Compare(a.EffectiveFrom, b.EffectiveFrom)
+	})
+	f.sched = latest
+	return nil
+}
+
 func (f *Fake) SaveSecret(userID int, secret, name string) error {
 	if err := f.fail("SaveSecret"); err != nil {
 		return err
@@ -360,6 +653,9 @@ func (f *Fake) RevokeToken(userID, tokenID int) error {
 		return err
 	}
 	f.RevokedTokens = append(f.RevokedTokens, RevokedToken{UserID: userID, TokenID: tokenID})
+	f.PushTokens = slices.DeleteFunc(f.PushTokens, func(t model.PushToken) bool {
+		return f.pushTokenAuth[t.ID] == tokenID
+	})
 	return nil
 }
 
@@ -367,6 +663,282 @@ func (f *Fake) RevokeSecretByValue(_ string) error {
 	return f.fail("RevokeSecretByValue")
 }
 
+func (f *Fake) GetWebhooks(_ int) (model.Webhooks, error) {
+	if err := f.fail("GetWebhooks"); err != nil {
+		return nil, err
+	}
+	return slices.Clone(f.hooks), nil
+}
+
+func (f *Fake) SaveWebhook(_ int, webhook model.Webhook) (model.Webhook, error) {
+	if err := f.fail("SaveWebhook"); err != nil {
+		return model.Webhook{}, err
+	}
+	if webhook.ID == 0 {
+		f.hookID++
+		webhook.ID = f.hookID
+		f.hooks = append(f.hooks, webhook)
+		return webhook, nil
+	}
+	for i, existing := range f.hooks {
+		if existing.ID == webhook.ID {
+			webhook.Secret = existing.Secret
+			f.hooks[i] = webhook
+			return webhook, nil
+		}
+	}
+	return model.Webhook{}, database.ErrNoWebhook
+}
+
+func (f *Fake) DeleteWebhook(_ int, webhookID int) error {
+	if err := f.fail("DeleteWebhook"); err != nil {
+		return err
+	}
+	for i, existing := range f.hooks {
+		if existing.ID == webhookID {
+			f.hooks = slices.Delete(f.hooks, i, i+1)
+			f.Deliveries = slices.DeleteFunc(f.Deliveries, func(d database.WebhookDelivery) bool {
+				return d.WebhookID == webhookID
+			})
+			return nil
+		}
+	}
+	return database.ErrNoWebhook
+}
+
+func (f *Fake) GetPushSubscriptions(_ int) (model.PushSubscriptions, error) {
+	if err := f.fail("GetPushSubscriptions"); err != nil {
+		return nil, err
+	}
+	return slices.Clone(f.PushSubs), nil
+}
+
+func (f *Fake) SavePushSubscription(_ int, sub model.PushSubscription, now time.Time) (model.PushSubscription, error) {
+	if err := f.fail("SavePushSubscription"); err != nil {
+		return model.PushSubscription{}, err
+	}
+	f.PushSubs = slices.DeleteFunc(f.PushSubs, func(existing model.PushSubscription) bool {
+		return existing.Endpoint == sub.Endpoint
+	})
+	f.pushID++
+	sub.ID = f.pushID
+	sub.CreatedAt = now.UTC()
+	f.PushSubs = append(f.PushSubs, sub)
+	return sub, nil
+}
+
+func (f *Fake) DeletePushSubscription(_ int, subscriptionID int) error {
+	if err := f.fail("DeletePushSubscription"); err != nil {
+		return err
+	}
+	for i, existing := range f.PushSubs {
+		if existing.ID == subscriptionID {
+			f.PushSubs = slices.Delete(f.PushSubs, i, i+1)
+			return nil
+		}
+	}
+	return database.ErrNoPushSub
+}
+
+func (f *Fake) GetPushTokens(_ int) (model.PushTokens, error) {
+	if err := f.fail("GetPushTokens"); err != nil {
+		return nil, err
+	}
+	return slices.Clone(f.PushTokens), nil
+}
+
+func (f *Fake) SavePushToken(_ int, authTokenID int, token model.PushToken, now time.Time) (model.PushToken, error) {
+	if err := f.fail("SavePushToken"); err != nil {
+		return model.PushToken{}, err
+	}
+	f.PushTokens = slices.DeleteFunc(f.PushTokens, func(existing model.PushToken) bool {
+		return existing.Token == token.Token
+	})
+	f.pushTokenID++
+	token.ID = f.pushTokenID
+	token.CreatedAt = now.UTC()
+	f.PushTokens = append(f.PushTokens, token)
+	if f.pushTokenAuth == nil {
+		f.pushTokenAuth = make(map[int]int)
+	}
+	f.pushTokenAuth[token.ID] = authTokenID
+	return token, nil
+}
+
+func (f *Fake) DeletePushToken(_ int, pushTokenID int) error {
+	if err := f.fail("DeletePushToken"); err != nil {
+		return err
+	}
+	for i, existing := range f.PushTokens {
+		if existing.ID == pushTokenID {
+			f.PushTokens = slices.Delete(f.PushTokens, i, i+1)
+			return nil
+		}
+	}
+	return database.ErrNoPushToken
+}
+
+func (f *Fake) QueueWebhookDeliveries(deliveries []database.WebhookDelivery) error {
+	if err := f.fail("QueueWebhookDeliveries"); err != nil {
+		return err
+	}
+	for _, d := range deliveries {
+		f.sendID++
+		d.ID = f.sendID
+		d.Status = model.WebhookDeliveryPending
+		d.NextAttemptAt = d.CreatedAt
+		f.Deliveries = append(f.Deliveries, d)
+	}
+	return nil
+}
+
+func (f *Fake) ClaimWebhookDeliveries(now, until time.Time, limit int) ([]database.WebhookDelivery, error) {
+	if err := f.fail("ClaimWebhookDeliveries"); err != nil {
+		return nil, err
+	}
+	var claimed []database.WebhookDelivery
+	for i, d := range f.Deliveries {
+		if len(claimed) == limit {
+			break
+		}
+		if d.Status != model.WebhookDeliveryPending || d.NextAttemptAt.After(now) {
+			continue
+		}
+		claimed = append(claimed, d)
+		f.Deliveries[i].NextAttemptAt = until
+	}
+	return claimed, nil
+}
+
+func (f *Fake) SaveWebhookAttempt(delivery database.WebhookDelivery) error {
+	if err := f.fail("SaveWebhookAttempt"); err != nil {
+		return err
+	}
+	for i, d := range f.Deliveries {
+		if d.ID == delivery.ID {
+			d.Status, d.Attempts, d.NextAttemptAt = delivery.Status, delivery.Attempts, delivery.NextAttemptAt
+			d.ResponseCode, d.Error = delivery.ResponseCode, delivery.Error
+			f.Deliveries[i] = d
+		}
+	}
+	return nil
+}
+
+func (f *Fake) GetWebhookDeliveries(_ int, webhookID int, limit int) ([]model.WebhookDelivery, error) {
+	if err := f.fail("GetWebhookDeliveries"); err != nil {
+		return nil, err
+	}
+	var deliveries []model.WebhookDelivery
+	for _, d := range slices.Backward(f.Deliveries) {
+		if d.WebhookID == webhookID && len(deliveries) < limit {
+			deliveries = append(deliveries, d.WebhookDelivery)
+		}
+	}
+	return deliveries, nil
+}
+
+func (f *Fake) PruneWebhookDeliveries(before time.Time) (int, error) {
+	if err := f.fail("PruneWebhookDeliveries"); err != nil {
+		return 0, err
+	}
+	n := len(f.Deliveries)
+	f.Deliveries = slices.DeleteFunc(f.Deliveries, func(d database.WebhookDelivery) bool {
+		return d.Status != model.WebhookDeliveryPending && d.CreatedAt.Before(before)
+	})
+	return n - len(f.Deliveries), nil
+}
+
+// chatKey drops the display name, which isn't part of an account's identity.
+func chatKey(account model.ChatAccount) model.ChatAccount {
+	account.Name = ""
+	return account
+}
+
+func (f *Fake) GetChatUser(account model.ChatAccount) (int, error) {
+	if err := f.fail("GetChatUser"); err != nil {
+		return 0, err
+	}
+	userID, ok := f.ChatUsers[chatKey(account)]
+	if !ok {
+		return 0, database.ErrNoChatAccount
+	}
+	return userID, nil
+}
+
+func (f *Fake) SaveChatLink(code string, account model.ChatAccount, expires time.Time) error {
+	if err := f.fail("SaveChatLink"); err != nil {
+		return err
+	}
+	f.chatLinks[code] = chatLink{account: account, expires: expires}
+	return nil
+}
+
+func (f *Fake) GetChatLink(code string, now time.Time) (model.ChatAccount, error) {
+	if err := f.fail("GetChatLink"); err != nil {
+		return model.ChatAccount{}, err
+	}
+	link, ok := f.chatLinks[code]
+	if !ok || !link.expires.After(now) {
+		return model.ChatAccount{}, database.ErrNoChatLink
+	}
+	return link.account, nil
+}
+
+func (f *Fake) LinkChatAccount(userID int, code string, now time.Time) (model.ChatAccount, error) {
+	if err := f.fail("LinkChatAccount"); err != nil {
+		return model.ChatAccount{}, err
+	}
+	link, ok := f.chatLinks[code]
+	if !ok || !link.expires.After(now) {
+		return model.ChatAccount{}, database.ErrNoChatLink
+	}
+	delete(f.chatLinks, code)
+	f.ChatUsers[chatKey(link.account)] = userID
+	return link.account, nil
+}
+
+func (f *Fake) UnlinkChatAccount(account model.ChatAccount) error {
+	if err := f.fail("UnlinkChatAccount"); err != nil {
+		return err
+	}
+	if _, ok := f.ChatUsers[chatKey(account)]; !ok {
+		return database.ErrNoChatAccount
+	}
+	delete(f.ChatUsers, chatKey(account))
+	return nil
+}
+
+func (f *Fake) ClaimNotification(_ int, kind string, period string, now time.Time) (bool, error) {
+	if err := f.fail("ClaimNotification"); err != nil {
+		return false, err
+	}
+	key := [2]string{kind, period}
+	if _, ok := f.Notifications[key]; ok {
+		return false, nil
+	}
+	f.Notifications[key] = now
+	return true, nil
+}
+
+func (f *Fake) ReleaseNotification(_ int, kind string, period string) error {
+	if err := f.fail("ReleaseNotification"); err != nil {
+		return err
+	}
+	delete(f.Notifications, [2]string{kind, period})
+	return nil
+}
+
+func (f *Fake) PruneNotifications(before time.Time) (int, error) {
+	if err := f.fail("PruneNotifications"); err != nil {
+		return 0, err
+	}
+	n := len(f.Notifications)
+	maps.DeleteFunc(f.Notifications, func(_ [2]string, sent time.Time) bool {
+		return sent.Before(before)
+	})
+	return n - len(f.Notifications), nil
+}
+
 func (f *Fake) IsUserSuspended(_ int) (bool, error) {
 	if err := f.fail("IsUserSuspended"); err != nil {
 		return false, err
@@ -414,3 +986,16 @@ func (f *Fake) CountEntriesByState() (map[model.State]int, error) {
 	}
 	return out, nil
 }
+
+func (f *Fake) CountCustomEntriesByAttendance() (map[model.Attendance]int, error) {
+	if err := f.fail("CountCustomEntriesByAttendance"); err != nil {
+		return nil, err
+	}
+	out := make(map[model.Attendance]int)
+	for _, v := range f.days {
+		if custom, ok := f.custom.Get(v.State); ok {
+			out[custom.Attendance]++
+		}
+	}
+	return out, nil
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strings"
 	"time"
 
@@ -42,19 +43,23 @@ func NewPostgres(cfg config.Postgres) (Databaser, error) {
 }
 
 func (p *postgres) SaveDay(userID int, day int, month int, year int, state model.DayState) error {
-	q := `INSERT INTO entries (user_id, day, month, year, state) VALUES ($1, $2, $3, $4, $5) ON CONFLICT(user_id, day, month, year) DO UPDATE SET state=EXCLUDED.state;`
+	if err := checkEntry(day, month, year, state.State); err != nil {
+		return err
+	}
+	q := `INSERT INTO entries (user_id, day, month, year, state, source, updated_at, note, location_id, unconfirmed) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) ON CONFLICT(user_id, day, month, year) DO UPDATE SET state=EXCLUDED.state, source=EXCLUDED.source, updated_at=EXCLUDED.updated_at, note=EXCLUDED.note, location_id=EXCLUDED.location_id, unconfirmed=EXCLUDED.unconfirmed;`
 	return p.readWriteTransaction(func(tx *sql.Tx) error {
-		_, err := tx.Exec(q, userID, day, month, year, state.State)
+		_, err := tx.Exec(q, userID, day, month, year, state.State, nullSource(state.Source), nullTime(state.UpdatedAt), state.Note, state.LocationID, state.Unconfirmed)
 		return err
 	})
 }
 
 func (p *postgres) GetDay(userID int, day int, month int, year int) (model.DayState, error) {
-	q := `SELECT state FROM entries WHERE user_id = $1 AND day = $2 AND month = $3 AND year = $4;`
+	q := `SELECT state, source, updated_at, note, location_id, unconfirmed FROM entries WHERE user_id = $1 AND day = $2 AND month = $3 AND year = $4;`
 	var state model.DayState
 	err := p.readOnlyTransaction(func(tx *sql.Tx) error {
 		row := tx.QueryRow(q, userID, day, month, year)
-		err := row.Scan(&(state.State))
+		var err error
+		state, err = scanDayState(row.Scan)
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil
 		}
@@ -71,12 +76,15 @@ func (p *postgres) SaveMonth(userID int, month int, year int, state model.MonthS
 	var tuples []string
 	var args []interface{}
 	for day, dayState := range state.Days {
-		tuples = append(tuples, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d)", argNum(), argNum(), argNum(), argNum(), argNum()))
-		args = append(args, userID, day, month, year, dayState.State)
+		if err := checkEntry(day, month, year, dayState.State); err != nil {
+			return err
+		}
+		tuples = append(tuples, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)", argNum(), argNum(), argNum(), argNum(), argNum(), argNum(), argNum(), argNum(), argNum(), argNum()))
+		args = append(args, userID, day, month, year, dayState.State, nullSource(dayState.Source), nullTime(dayState.UpdatedAt), dayState.Note, dayState.LocationID, dayState.Unconfirmed)
 	}
-	q := `INSERT INTO entries (user_id, day, month, year, state) VALUES ` +
+	q := `INSERT INTO entries (user_id, day, month, year, state, source, updated_at, note, location_id, unconfirmed) VALUES ` +
 		strings.Join(tuples, ", ") +
-		" ON CONFLICT(user_id, day, month, year) DO UPDATE SET state=EXCLUDED.state;"
+		" ON CONFLICT(user_id, day, month, year) DO UPDATE SET state=EXCLUDED.state, source=EXCLUDED.source, updated_at=EXCLUDED.updated_at, note=EXCLUDED.note, location_id=EXCLUDED.location_id, unconfirmed=EXCLUDED.unconfirmed;"
 	err := p.readWriteTransaction(func(tx *sql.Tx) error {
 		_, err := tx.Exec(q, args...)
 		return err
@@ -85,7 +93,7 @@ func (p *postgres) SaveMonth(userID int, month int, year int, state model.MonthS
 }
 
 func (p *postgres) GetMonth(userID int, month int, year int) (model.MonthState, error) {
-	q := `SELECT day, state FROM entries WHERE user_id = $1 AND month = $2 AND year = $3;`
+	q := `SELECT day, state, source, updated_at, note, location_id, unconfirmed FROM entries WHERE user_id = $1 AND month = $2 AND year = $3;`
 	var monthState model.MonthState
 	err := p.readOnlyTransaction(func(tx *sql.Tx) error {
 		rows, err := tx.Query(q, userID, month, year)
@@ -96,8 +104,9 @@ func (p *postgres) GetMonth(userID int, month int, year int) (model.MonthState,
 		monthState.Days = make(map[int]model.DayState)
 		for rows.Next() {
 			var day int
-			var dayState model.DayState
-			err = rows.Scan(&day, &dayState.State)
+			dayState, err := scanDayState(func(dest ...any) error {
+				return rows.Scan(append([]any{&day}, dest...)...)
+			})
 			if err != nil {
 				return err
 			}
@@ -111,7 +120,7 @@ func (p *postgres) GetMonth(userID int, month int, year int) (model.MonthState,
 func (p *postgres) GetYear(userID int, year int, startMonth int) (model.YearState, error) {
 	startMonth = util.NormaliseStartMonth(startMonth)
 	firstYear, secondYear := util.TrackingYearCalendarYears(year, startMonth)
-	q := `SELECT month, day, state FROM entries WHERE user_id = $1 AND ((year = $2 AND month >= $4) OR (year = $3 AND month < $4));`
+	q := `SELECT month, day, state, source, updated_at, note, location_id, unconfirmed FROM entries WHERE user_id = $1 AND ((year = $2 AND month >= $4) OR (year = $3 AND month < $4));`
 	yearState := model.YearState{
 		Months: make(map[int]model.MonthState),
 	}
@@ -123,8 +132,9 @@ func (p *postgres) GetYear(userID int, year int, startMonth int) (model.YearStat
 		defer rows.Close()
 		for rows.Next() {
 			var month, day int
-			var dayState model.DayState
-			err = rows.Scan(&month, &day, &dayState.State)
+			dayState, err := scanDayState(func(dest ...any) error {
+				return rows.Scan(append([]any{&month, &day}, dest...)...)
+			})
 			if err != nil {
 				return err
 			}
@@ -189,6 +199,70 @@ func (p *postgres) GetNotes(userID int, year int, startMonth int) (map[int]model
 
 }
 
+func appendDayChanges(tx *sql.Tx, userID int, changes []DayChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	argNum := incrementer(1)
+	var tuples []string
+	var args []interface{}
+	for _, c := range changes {
+		tuples = append(tuples, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)",
+			argNum(), argNum(), argNum(), argNum(), argNum(), argNum(), argNum(), argNum(), argNum(), argNum()))
+		tokenID := sql.NullInt64{Int64: int64(c.TokenID), Valid: c.TokenID != 0}
+		args = append(args, userID, c.Day, c.Month, c.Year, c.OldState, c.NewState, c.ChangedAt, c.AuthMethod, tokenID, c.Via)
+	}
+	q := `INSERT INTO entry_history (user_id, day, month, year, old_state, new_state, changed_at, auth_method, token_id, via) VALUES ` +
+		strings.Join(tuples, ", ") + ";"
+	_, err := tx.Exec(q, args...)
+	return err
+}
+
+func (p *postgres) SaveEntries(userID int, entries []Entry, changes []DayChange) error {
+	for _, e := range entries {
+		if err := checkEntry(e.Day, e.Month, e.Year, e.State.State); err != nil {
+			return err
+		}
+	}
+	q := `INSERT INTO entries (user_id, day, month, year, state, source, updated_at, note, location_id, unconfirmed) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) ON CONFLICT(user_id, day, month, year) DO UPDATE SET state=EXCLUDED.state, source=EXCLUDED.source, updated_at=EXCLUDED.updated_at, note=EXCLUDED.note, location_id=EXCLUDED.location_id, unconfirmed=EXCLUDED.unconfirmed;`
+	return p.readWriteTransaction(func(tx *sql.Tx) error {
+		for _, e := range entries {
+			state := e.State
+			if _, err := tx.Exec(q, userID, e.Day, e.Month, e.Year, state.State, nullSource(state.Source), nullTime(state.UpdatedAt), state.Note, state.LocationID, state.Unconfirmed); err != nil {
+				return err
+			}
+		}
+		return appendDayChanges(tx, userID, changes)
+	})
+}
+
+func (p *postgres) GetDayHistory(userID int, day int, month int, year int) ([]DayChange, error) {
+	q := `SELECT old_state, new_state, changed_at, auth_method, token_id, via
+	      FROM entry_history
+	      WHERE user_id = $1 AND day = $2 AND month = $3 AND year = $4
+	      ORDER BY changed_at, id;`
+	var history []DayChange
+	err := p.readOnlyTransaction(func(tx *sql.Tx) error {
+		rows, err := tx.Query(q, userID, day, month, year)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			c := DayChange{Day: day, Month: month, Year: year}
+			var tokenID sql.NullInt64
+			err = rows.Scan(&c.OldState, &c.NewState, &c.ChangedAt, &c.AuthMethod, &tokenID, &c.Via)
+			if err != nil {
+				return err
+			}
+			c.TokenID = int(tokenID.Int64)
+			history = append(history, c)
+		}
+		return rows.Err()
+	})
+	return history, err
+}
+
 func (p *postgres) SaveSecret(userID int, secret string, name string) error {
 	q := `INSERT INTO secrets (user_id, secret, name, active, created_at) VALUES ($1, $2, $3, true, NOW());`
 	err := p.readWriteTransaction(func(tx *sql.Tx) error {
@@ -228,6 +302,7 @@ func (p *postgres) ListActiveTokens(userID int) ([]TokenMetadata, error) {
 func (p *postgres) RevokeToken(userID int, tokenID int) error {
 	q := `UPDATE secrets SET active = false
 	      WHERE user_id = $1 AND token_id = $2 AND active = true;`
+	devices := `DELETE FROM push_tokens WHERE auth_token_id = $1;`
 	return p.readWriteTransaction(func(tx *sql.Tx) error {
 		result, err := tx.Exec(q, userID, tokenID)
 		if err != nil {
@@ -238,16 +313,21 @@ func (p *postgres) RevokeToken(userID int, tokenID int) error {
 			return err
 		}
 		if rowsAffected == 0 {
-			return fmt.Errorf("token not found or already revoked")
+			return ErrNoToken
 		}
-		return nil
+		_, err = tx.Exec(devices, tokenID)
+		return err
 	})
 }
 
 func (p *postgres) RevokeSecretByValue(secret string) error {
 	q := `UPDATE secrets SET active = false WHERE secret = $1 AND active = true;`
+	devices := `DELETE FROM push_tokens WHERE auth_token_id IN (SELECT token_id FROM secrets WHERE secret = $1);`
 	return p.readWriteTransaction(func(tx *sql.Tx) error {
-		_, err := tx.Exec(q, secret)
+		if _, err := tx.Exec(q, secret); err != nil {
+			return err
+		}
+		_, err := tx.Exec(devices, secret)
 		return err
 	})
 }
@@ -280,6 +360,20 @@ func (p *postgres) GetUserBySecret(secret string) (int, error) {
 	return id, err
 }
 
+func (p *postgres) GetTokenIDBySecret(secret string) (int, error) {
+	q := `SELECT token_id FROM secrets WHERE secret = $1 AND active;`
+	var id int
+	err := p.readOnlyTransaction(func(tx *sql.Tx) error {
+		row := tx.QueryRow(q, secret)
+		err := row.Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoUser
+		}
+		return err
+	})
+	return id, err
+}
+
 func (p *postgres) GetUserLinkedAccounts(userID int) ([]model.LinkedAccount, error) {
 	q := `SELECT sub, profile FROM auth0_users WHERE user_id = $1 ORDER BY sub;`
 	var accounts []model.LinkedAccount
@@ -507,28 +601,31 @@ func (p *postgres) GetSchedulePreferences(userID int) (model.SchedulePreferences
 }
 
 func (p *postgres) SaveSchedulePreferences(userID int, prefs model.SchedulePreferences) error {
+	return p.readWriteTransaction(func(tx *sql.Tx) error {
+		return upsertSchedulePreferences(tx, userID, prefs)
+	})
+}
+
+func upsertSchedulePreferences(tx *sql.Tx, userID int, prefs model.SchedulePreferences) error {
 	q := `INSERT INTO user_preferences (user_id, schedule_monday_state, schedule_tuesday_state, schedule_wednesday_state, 
 		         schedule_thursday_state, schedule_friday_state, schedule_saturday_state, schedule_sunday_state)
 		  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		  ON CONFLICT (user_id)
 		  DO UPDATE SET schedule_monday_state = $2, schedule_tuesday_state = $3, schedule_wednesday_state = $4,
 		                schedule_thursday_state = $5, schedule_friday_state = $6, schedule_saturday_state = $7, schedule_sunday_state = $8;`
-
-	return p.readWriteTransaction(func(tx *sql.Tx) error {
-		_, err := tx.Exec(q, userID, int(prefs.Monday), int(prefs.Tuesday), int(prefs.Wednesday),
-			int(prefs.Thursday), int(prefs.Friday), int(prefs.Saturday), int(prefs.Sunday))
-		return err
-	})
+	_, err := tx.Exec(q, userID, int(prefs.Monday), int(prefs.Tuesday), int(prefs.Wednesday),
+		int(prefs.Thursday), int(prefs.Friday), int(prefs.Saturday), int(prefs.Sunday))
+	return err
 }
 
 func (p *postgres) GetCalendarPreferences(userID int) (model.CalendarPreferences, error) {
-	q := `SELECT tracking_year_start_month FROM user_preferences WHERE user_id = $1;`
+	q := `SELECT tracking_year_start_month, timezone FROM user_preferences WHERE user_id = $1;`
 	prefs := model.CalendarPreferences{TrackingYearStartMonth: model.DefaultTrackingYearStartMonth}
 
 	err := p.readOnlyTransaction(func(tx *sql.Tx) error {
 		row := tx.QueryRow(q, userID)
 		var startMonth sql.NullInt64
-		err := row.Scan(&startMonth)
+		err := row.Scan(&startMonth, &prefs.Timezone)
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil
 		}
@@ -546,51 +643,784 @@ func (p *postgres) GetCalendarPreferences(userID int) (model.CalendarPreferences
 
 func (p *postgres) SaveCalendarPreferences(userID int, prefs model.CalendarPreferences) error {
 	startMonth := util.NormaliseStartMonth(prefs.TrackingYearStartMonth)
-	q := `INSERT INTO user_preferences (user_id, tracking_year_start_month)
-		  VALUES ($1, $2)
+	q := `INSERT INTO user_preferences (user_id, tracking_year_start_month, timezone)
+		  VALUES ($1, $2, $3)
 		  ON CONFLICT (user_id)
-		  DO UPDATE SET tracking_year_start_month = $2;`
+		  DO UPDATE SET tracking_year_start_month = $2, timezone = $3;`
 
 	return p.readWriteTransaction(func(tx *sql.Tx) error {
-		_, err := tx.Exec(q, userID, startMonth)
+		_, err := tx.Exec(q, userID, startMonth, prefs.Timezone)
 		return err
 	})
 }
 
 func (p *postgres) GetTargetPreferences(userID int) (model.TargetPreferences, error) {
-	q := `SELECT target_percent FROM user_preferences WHERE user_id = $1;`
+	q := `SELECT target_percent, target_policy, target_days, target_window, target_window_weeks FROM user_preferences WHERE user_id = $1;`
 	var prefs model.TargetPreferences
 
 	err := p.readOnlyTransaction(func(tx *sql.Tx) error {
 		row := tx.QueryRow(q, userID)
-		var target sql.NullInt64
-		err := row.Scan(&target)
+		err := row.Scan(&prefs.TargetPercent, &prefs.Policy, &prefs.TargetDays, &prefs.Window, &prefs.WindowWeeks)
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil
 		}
+		return err
+	})
+
+	return util.NormaliseTargetPreferences(prefs), err
+}
+
+func (p *postgres) SaveTargetPreferences(userID int, prefs model.TargetPreferences) error {
+	prefs = util.NormaliseTargetPreferences(prefs)
+	q := `INSERT INTO user_preferences (user_id, target_percent, target_policy, target_days, target_window, target_window_weeks)
+		  VALUES ($1, $2, $3, $4, $5, $6)
+		  ON CONFLICT (user_id)
+		  DO UPDATE SET target_percent = $2, target_policy = $3, target_days = $4, target_window = $5, target_window_weeks = $6;`
+
+	return p.readWriteTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(q, userID, prefs.TargetPercent, prefs.Policy, prefs.TargetDays, prefs.Window, prefs.WindowWeeks)
+		return err
+	})
+}
+
+func (p *postgres) GetMaterialisePreferences(userID int) (model.MaterialisePreferences, error) {
+	q := `SELECT materialise_mode FROM user_preferences WHERE user_id = $1;`
+	var prefs model.MaterialisePreferences
+	err := p.readOnlyTransaction(func(tx *sql.Tx) error {
+		err := tx.QueryRow(q, userID).Scan(&prefs.Mode)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	})
+	return prefs, err
+}
+
+func (p *postgres) SaveMaterialisePreferences(userID int, prefs model.MaterialisePreferences) error {
+	q := `INSERT INTO user_preferences (user_id, materialise_mode) VALUES ($1, $2)
+		  ON CONFLICT (user_id) DO UPDATE SET materialise_mode = $2;`
+	return p.readWriteTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(q, userID, prefs.Mode)
+		return err
+	})
+}
+
+func (p *postgres) ListMaterialiseUsers() ([]int, error) {
+	q := `SELECT user_id FROM user_preferences WHERE materialise_mode <> '' ORDER BY user_id;`
+	var users []int
+	err := p.readOnlyTransaction(func(tx *sql.Tx) error {
+		rows, err := tx.Query(q)
 		if err != nil {
 			return err
 		}
-		if target.Valid {
-			prefs.TargetPercent = util.ClampTargetPercent(int(target.Int64))
+		defer rows.Close()
+		for rows.Next() {
+			var userID int
+			if err := rows.Scan(&userID); err != nil {
+				return err
+			}
+			users = append(users, userID)
 		}
-		return nil
+		return rows.Err()
 	})
+	return users, err
+}
 
+func (p *postgres) GetNotificationPreferences(userID int) (model.NotificationPreferences, error) {
+	q := `SELECT notification_email, daily_reminder, weekly_digest, monthly_summary, push_reminder, push_target_warning
+		FROM user_preferences WHERE user_id = $1;`
+	var prefs model.NotificationPreferences
+	err := p.readOnlyTransaction(func(tx *sql.Tx) error {
+		err := tx.QueryRow(q, userID).Scan(&prefs.Email, &prefs.DailyReminder, &prefs.WeeklyDigest, &prefs.MonthlySummary,
+			&prefs.PushReminder, &prefs.PushTargetWarning)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	})
 	return prefs, err
 }
 
-func (p *postgres) SaveTargetPreferences(userID int, prefs model.TargetPreferences) error {
-	target := util.ClampTargetPercent(prefs.TargetPercent)
-	q := `INSERT INTO user_preferences (user_id, target_percent)
-		  VALUES ($1, $2)
+func (p *postgres) SaveNotificationPreferences(userID int, prefs model.NotificationPreferences) error {
+	q := `INSERT INTO user_preferences (user_id, notification_email, daily_reminder, weekly_digest, monthly_summary, push_reminder, push_target_warning)
+		  VALUES ($1, $2, $3, $4, $5, $6, $7)
 		  ON CONFLICT (user_id)
-		  DO UPDATE SET target_percent = $2;`
+		  DO UPDATE SET notification_email = $2, daily_reminder = $3, weekly_digest = $4, monthly_summary = $5,
+		  push_reminder = $6, push_target_warning = $7;`
+	return p.readWriteTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(q, userID, prefs.Email, prefs.DailyReminder, prefs.WeeklyDigest, prefs.MonthlySummary,
+			prefs.PushReminder, prefs.PushTargetWarning)
+		return err
+	})
+}
+
+func (p *postgres) ListNotificationUsers() ([]int, error) {
+	q := `SELECT user_id FROM user_preferences
+		  WHERE (notification_email <> '' AND (daily_reminder OR weekly_digest OR monthly_summary))
+		  OR push_reminder OR push_target_warning
+		  UNION SELECT user_id FROM push_tokens WHERE reminder
+		  ORDER BY user_id;`
+	var users []int
+	err := p.readOnlyTransaction(func(tx *sql.Tx) error {
+		rows, err := tx.Query(q)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var userID int
+			if err := rows.Scan(&userID); err != nil {
+				return err
+			}
+			users = append(users, userID)
+		}
+		return rows.Err()
+	})
+	return users, err
+}
+
+func (p *postgres) GetCustomStates(userID int) (model.CustomStates, error) {
+	q := `SELECT state_id, name, color, attendance, archived FROM custom_states WHERE user_id = $1 ORDER BY state_id;`
+	var states model.CustomStates
+	err := p.readOnlyTransaction(func(tx *sql.Tx) error {
+		rows, err := tx.Query(q, userID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var state model.CustomState
+			if err := rows.Scan(&state.ID, &state.Name, &state.Color, &state.Attendance, &state.Archived); err != nil {
+				return err
+			}
+			states = append(states, state)
+		}
+		return rows.Err()
+	})
+	return states, err
+}
+
+func (p *postgres) SaveCustomState(userID int, state model.CustomState) (model.CustomState, error) {
+	insert := `INSERT INTO custom_states (user_id, state_id, name, color, attendance, archived)
+		SELECT $1, GREATEST(COALESCE(MAX(state_id) + 1, $2), $2), $3, $4, $5, $6 FROM custom_states WHERE user_id = $1
+		RETURNING state_id;`
+	update := `UPDATE custom_states SET name = $3, color = $4, attendance = $5, archived = $6 WHERE user_id = $1 AND state_id = $2;`
+	err := p.readWriteTransaction(func(tx *sql.Tx) error {
+		if state.ID == 0 {
+			return tx.QueryRow(insert, userID, int(model.CustomStateBase), state.Name, state.Color, state.Attendance, state.Archived).Scan(&state.ID)
+		}
+		res, err := tx.Exec(update, userID, int(state.ID), state.Name, state.Color, state.Attendance, state.Archived)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrNoCustomState
+		}
+		return nil
+	})
+	return state, err
+}
+
+func (p *postgres) GetLocations(userID int) (model.Locations, error) {
+	q := `SELECT location_id, name, latitude, longitude, radius, archived FROM locations WHERE user_id = $1 ORDER BY location_id;`
+	var locations model.Locations
+	err := p.readOnlyTransaction(func(tx *sql.Tx) error {
+		rows, err := tx.Query(q, userID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			location, err := scanLocation(rows.Scan)
+			if err != nil {
+				return err
+			}
+			locations = append(locations, location)
+		}
+		return rows.Err()
+	})
+	return locations, err
+}
+
+func (p *postgres) SaveLocation(userID int, location model.Location) (model.Location, error) {
+	insert := `INSERT INTO locations (user_id, location_id, name, latitude, longitude, radius, archived)
+		SELECT $1, COALESCE(MAX(location_id), 0) + 1, $2, $3, $4, $5, $6 FROM locations WHERE user_id = $1
+		RETURNING location_id;`
+	update := `UPDATE locations SET name = $3, latitude = $4, longitude = $5, radius = $6, archived = $7 WHERE user_id = $1 AND location_id = $2;`
+	lat, lng := nullFloat(location.Latitude), nullFloat(location.Longitude)
+	err := p.readWriteTransaction(func(tx *sql.Tx) error {
+		if location.ID == 0 {
+			return tx.QueryRow(insert, userID, location.Name, lat, lng, location.Radius, location.Archived).Scan(&location.ID)
+		}
+		res, err := tx.Exec(update, userID, location.ID, location.Name, lat, lng, location.Radius, location.Archived)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrNoLocation
+		}
+		return nil
+	})
+	return location, err
+}
+
+func (p *postgres) GetScheduleRules(userID int) (model.ScheduleRules, error) {
+	q := `SELECT rule_id, state, rrule, to_char(start_date, 'YYYY-MM-DD'), to_char(end_date, 'YYYY-MM-DD')
+		FROM schedule_rules WHERE user_id = $1 ORDER BY rule_id;`
+	var rules model.ScheduleRules
+	err := p.readOnlyTransaction(func(tx *sql.Tx) error {
+		rows, err := tx.Query(q, userID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			rule, err := scanScheduleRule(rows.Scan)
+			if err != nil {
+				return err
+			}
+			rules = append(rules, rule)
+		}
+		return rows.Err()
+	})
+	return rules, err
+}
+
+func (p *postgres) SaveScheduleRule(userID int, rule model.ScheduleRule) (model.ScheduleRule, error) {
+	insert := `INSERT INTO schedule_rules (user_id, rule_id, state, rrule, start_date, end_date)
+		SELECT $1, COALESCE(MAX(rule_id), 0) + 1, $2, $3, $4, $5 FROM schedule_rules WHERE user_id = $1
+		RETURNING rule_id;`
+	update := `UPDATE schedule_rules SET state = $3, rrule = $4, start_date = $5, end_date = $6 WHERE user_id = $1 AND rule_id = $2;`
+	end := nullString(rule.End)
+	err := p.readWriteTransaction(func(tx *sql.Tx) error {
+		if rule.ID == 0 {
+			return tx.QueryRow(insert, userID, rule.State, rule.RRule, rule.Start, end).Scan(&rule.ID)
+		}
+		res, err := tx.Exec(update, userID, rule.ID, rule.State, rule.RRule, rule.Start, end)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrNoScheduleRule
+		}
+		return nil
+	})
+	return rule, err
+}
+
+func (p *postgres) DeleteScheduleRule(userID int, ruleID int) error {
+	q := `DELETE FROM schedule_rules WHERE user_id = $1 AND rule_id = $2;`
+	return p.readWriteTransaction(func(tx *sql.Tx) error {
+		res, err := tx.Exec(q, userID, ruleID)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrNoScheduleRule
+		}
+		return nil
+	})
+}
+
+func (p *postgres) GetNetworkRules(userID int) (model.NetworkRules, error) {
+	q := `SELECT rule_id, kind, value, location_id FROM network_rules WHERE user_id = $1 ORDER BY rule_id;`
+	var rules model.NetworkRules
+	err := p.readOnlyTransaction(func(tx *sql.Tx) error {
+		rows, err := tx.Query(q, userID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			rule, err := scanNetworkRule(rows.Scan)
+			if err != nil {
+				return err
+			}
+			rules = append(rules, rule)
+		}
+		return rows.Err()
+	})
+	return rules, err
+}
+
+func (p *postgres) SaveNetworkRule(userID int, rule model.NetworkRule) (model.NetworkRule, error) {
+	insert := `INSERT INTO network_rules (user_id, rule_id, kind, value, location_id)
+		SELECT $1, COALESCE(MAX(rule_id), 0) + 1, $2, $3, $4 FROM network_rules WHERE user_id = $1
+		RETURNING rule_id;`
+	update := `UPDATE network_rules SET kind = $3, value = $4, location_id = $5 WHERE user_id = $1 AND rule_id = $2;`
+	err := p.readWriteTransaction(func(tx *sql.Tx) error {
+		if rule.ID == 0 {
+			return tx.QueryRow(insert, userID, rule.Kind, rule.Value, rule.LocationID).Scan(&rule.ID)
+		}
+		res, err := tx.Exec(update, userID, rule.ID, rule.Kind, rule.Value, rule.LocationID)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrNoNetworkRule
+		}
+		return nil
+	})
+	return rule, err
+}
+
+func (p *postgres) DeleteNetworkRule(userID int, ruleID int) error {
+	q := `DELETE FROM network_rules WHERE user_id = $1 AND rule_id = $2;`
+	return p.readWriteTransaction(func(tx *sql.Tx) error {
+		res, err := tx.Exec(q, userID, ruleID)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrNoNetworkRule
+		}
+		return nil
+	})
+}
+
+func (p *postgres) GetScheduleHistory(userID int) (model.ScheduleHistory, error) {
+	q := `SELECT to_char(effective_from, 'YYYY-MM-DD'), monday_state, tuesday_state, wednesday_state,
+		         thursday_state, friday_state, saturday_state, sunday_state
+		FROM schedule_history WHERE user_id = $1 ORDER BY effective_from NULLS FIRST;`
+	var history model.ScheduleHistory
+	err := p.readOnlyTransaction(func(tx *sql.Tx) error {
+		rows, err := tx.Query(q, userID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			version, err := scanScheduleVersion(rows.Scan)
+			if err != nil {
+				return err
+			}
+			history = append(history, version)
+		}
+		return rows.Err()
+	})
+	return history, err
+}
 
+func (p *postgres) SaveScheduleVersions(userID int, latest model.SchedulePreferences, versions ...model.ScheduleVersion) error {
+	del := `DELETE FROM schedule_history WHERE user_id = $1 AND effective_from IS NOT DISTINCT FROM $2::date;`
+	insert := `INSERT INTO schedule_history (user_id, effective_from, monday_state, tuesday_state, wednesday_state,
+		         thursday_state, friday_state, saturday_state, sunday_state)
+		  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9);`
 	return p.readWriteTransaction(func(tx *sql.Tx) error {
-		_, err := tx.Exec(q, userID, target)
+		for _, version := range versions {
+			from := nullString(version.EffectiveFrom)
+			s := version.Schedule
+			if _, err := tx.Exec(del, userID, from); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(insert, userID, from, int(s.Monday), int(s.Tuesday), int(s.Wednesday),
+				int(s.Thursday), int(s.Friday), int(s.Saturday), int(s.Sunday)); err != nil {
+				return err
+			}
+		}
+		return upsertSchedulePreferences(tx, userID, latest)
+	})
+}
+
+func (p *postgres) GetWebhooks(userID int) (model.Webhooks, error) {
+	q := `SELECT webhook_id, url, events, secret, disabled FROM webhooks WHERE user_id = $1 ORDER BY webhook_id;`
+	var webhooks model.Webhooks
+	err := p.readOnlyTransaction(func(tx *sql.Tx) error {
+		rows, err := tx.Query(q, userID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var webhook model.Webhook
+			var events string
+			if err := rows.Scan(&webhook.ID, &webhook.URL, &events, &webhook.Secret, &webhook.Disabled); err != nil {
+				return err
+			}
+			webhook.Events = splitEvents(events)
+			webhooks = append(webhooks, webhook)
+		}
+		return rows.Err()
+	})
+	return webhooks, err
+}
+
+func (p *postgres) SaveWebhook(userID int, webhook model.Webhook) (model.Webhook, error) {
+	insert := `INSERT INTO webhooks (user_id, webhook_id, url, events, secret, disabled)
+		SELECT $1, COALESCE(MAX(webhook_id), 0) + 1, $2, $3, $4, $5 FROM webhooks WHERE user_id = $1
+		RETURNING webhook_id;`
+	update := `UPDATE webhooks SET url = $3, events = $4, disabled = $5 WHERE user_id = $1 AND webhook_id = $2 RETURNING secret;`
+	events := joinEvents(webhook.Events)
+	err := p.readWriteTransaction(func(tx *sql.Tx) error {
+		if webhook.ID == 0 {
+			return tx.QueryRow(insert, userID, webhook.URL, events, webhook.Secret, webhook.Disabled).Scan(&webhook.ID)
+		}
+		err := tx.QueryRow(update, userID, webhook.ID, webhook.URL, events, webhook.Disabled).Scan(&webhook.Secret)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoWebhook
+		}
 		return err
 	})
+	return webhook, err
+}
+
+func (p *postgres) DeleteWebhook(userID int, webhookID int) error {
+	// Deliveries go with it through the foreign key.
+	q := `DELETE FROM webhooks WHERE user_id = $1 AND webhook_id = $2;`
+	return p.readWriteTransaction(func(tx *sql.Tx) error {
+		res, err := tx.Exec(q, userID, webhookID)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrNoWebhook
+		}
+		return nil
+	})
+}
+
+func (p *postgres) GetPushSubscriptions(userID int) (model.PushSubscriptions, error) {
+	q := `SELECT subscription_id, endpoint, p256dh, auth, device, expires_at, created_at
+		FROM push_subscriptions WHERE user_id = $1 ORDER BY subscription_id;`
+	var subs model.PushSubscriptions
+	err := p.readOnlyTransaction(func(tx *sql.Tx) error {
+		rows, err := tx.Query(q, userID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			sub, err := scanPushSubscription(rows.Scan)
+			if err != nil {
+				return err
+			}
+			subs = append(subs, sub)
+		}
+		return rows.Err()
+	})
+	return subs, err
+}
+
+func (p *postgres) SavePushSubscription(userID int, sub model.PushSubscription, now time.Time) (model.PushSubscription, error) {
+	remove := `DELETE FROM push_subscriptions WHERE endpoint = $1;`
+	insert := `INSERT INTO push_subscriptions (user_id, subscription_id, endpoint, p256dh, auth, device, expires_at, created_at)
+		SELECT $1, COALESCE(MAX(subscription_id), 0) + 1, $2, $3, $4, $5, $6, $7 FROM push_subscriptions WHERE user_id = $1
+		RETURNING subscription_id;`
+	sub.CreatedAt = now.UTC()
+	err := p.readWriteTransaction(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(remove, sub.Endpoint); err != nil {
+			return err
+		}
+		return tx.QueryRow(insert, userID, sub.Endpoint, sub.Keys.P256dh, sub.Keys.Auth, sub.Device, nullTime(sub.ExpiresAt), sub.CreatedAt).Scan(&sub.ID)
+	})
+	return sub, err
+}
+
+func (p *postgres) DeletePushSubscription(userID int, subscriptionID int) error {
+	q := `DELETE FROM push_subscriptions WHERE user_id = $1 AND subscription_id = $2;`
+	return p.readWriteTransaction(func(tx *sql.Tx) error {
+		res, err := tx.Exec(q, userID, subscriptionID)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrNoPushSub
+		}
+		return nil
+	})
+}
+
+func (p *postgres) GetPushTokens(userID int) (model.PushTokens, error) {
+	q := `SELECT push_token_id, token, device, reminder, check_in, created_at
+		FROM push_tokens WHERE user_id = $1 ORDER BY push_token_id;`
+	var tokens model.PushTokens
+	err := p.readOnlyTransaction(func(tx *sql.Tx) error {
+		rows, err := tx.Query(q, userID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var t model.PushToken
+			if err := rows.Scan(&t.ID, &t.Token, &t.Device, &t.Reminder, &t.CheckIn, &t.CreatedAt); err != nil {
+				return err
+			}
+			t.CreatedAt = t.CreatedAt.UTC()
+			tokens = append(tokens, t)
+		}
+		return rows.Err()
+	})
+	return tokens, err
+}
+
+func (p *postgres) SavePushToken(userID int, authTokenID int, token model.PushToken, now time.Time) (model.PushToken, error) {
+	remove := `DELETE FROM push_tokens WHERE token = $1;`
+	insert := `INSERT INTO push_tokens (user_id, push_token_id, token, auth_token_id, device, reminder, check_in, created_at)
+		SELECT $1, COALESCE(MAX(push_token_id), 0) + 1, $2, $3, $4, $5, $6, $7 FROM push_tokens WHERE user_id = $1
+		RETURNING push_token_id;`
+	token.CreatedAt = now.UTC()
+	authToken := sql.NullInt64{Int64: int64(authTokenID), Valid: authTokenID != 0}
+	err := p.readWriteTransaction(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(remove, token.Token); err != nil {
+			return err
+		}
+		return tx.QueryRow(insert, userID, token.Token, authToken, token.Device, token.Reminder, token.CheckIn, token.CreatedAt).Scan(&token.ID)
+	})
+	return token, err
+}
+
+func (p *postgres) DeletePushToken(userID int, pushTokenID int) error {
+	q := `DELETE FROM push_tokens WHERE user_id = $1 AND push_token_id = $2;`
+	return p.readWriteTransaction(func(tx *sql.Tx) error {
+		res, err := tx.Exec(q, userID, pushTokenID)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrNoPushToken
+		}
+		return nil
+	})
+}
+
+func (p *postgres) QueueWebhookDeliveries(deliveries []WebhookDelivery) error {
+	q := `INSERT INTO webhook_deliveries (user_id, webhook_id, event, payload, status, next_attempt_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $6);`
+	return p.readWriteTransaction(func(tx *sql.Tx) error {
+		for _, d := range deliveries {
+			if _, err := tx.Exec(q, d.UserID, d.WebhookID, d.Event, string(d.Payload), model.WebhookDeliveryPending, d.CreatedAt.UTC()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (p *postgres) ClaimWebhookDeliveries(now, until time.Time, limit int) ([]WebhookDelivery, error) {
+	// SKIP LOCKED lets several servers claim deliveries at once without
+	// taking the same ones.
+	q := `UPDATE webhook_deliveries SET next_attempt_at = $1
+		WHERE delivery_id IN (
+			SELECT delivery_id FROM webhook_deliveries
+			WHERE status = $2 AND next_attempt_at <= $3
+			ORDER BY next_attempt_at, delivery_id LIMIT $4
+			FOR UPDATE SKIP LOCKED)
+		RETURNING delivery_id, user_id, webhook_id, event, payload, status, attempts, next_attempt_at, response_code, error, created_at;`
+	var deliveries []WebhookDelivery
+	err := p.readWriteTransaction(func(tx *sql.Tx) error {
+		rows, err := tx.Query(q, until.UTC(), model.WebhookDeliveryPending, now.UTC(), limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			d, err := scanWebhookDelivery(rows.Scan)
+			if err != nil {
+				return err
+			}
+			deliveries = append(deliveries, d)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	// RETURNING gives no order.
+	slices.SortFunc(deliveries, func(a, b WebhookDelivery) int { return a.ID - b.ID })
+	return deliveries, nil
+}
+
+func (p *postgres) SaveWebhookAttempt(d WebhookDelivery) error {
+	q := `UPDATE webhook_deliveries SET status = $2, attempts = $3, next_attempt_at = $4, response_code = $5, error = $6 WHERE delivery_id = $1;`
+	return p.readWriteTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(q, d.ID, d.Status, d.Attempts, nullTime(d.NextAttemptAt), d.ResponseCode, d.Error)
+		return err
+	})
+}
+
+func (p *postgres) GetWebhookDeliveries(userID int, webhookID int, limit int) ([]model.WebhookDelivery, error) {
+	q := `SELECT delivery_id, user_id, webhook_id, event, payload, status, attempts, next_attempt_at, response_code, error, created_at
+		FROM webhook_deliveries WHERE user_id = $1 AND webhook_id = $2 ORDER BY delivery_id DESC LIMIT $3;`
+	var deliveries []model.WebhookDelivery
+	err := p.readOnlyTransaction(func(tx *sql.Tx) error {
+		rows, err := tx.Query(q, userID, webhookID, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			d, err := scanWebhookDelivery(rows.Scan)
+			if err != nil {
+				return err
+			}
+			deliveries = append(deliveries, d.WebhookDelivery)
+		}
+		return rows.Err()
+	})
+	return deliveries, err
+}
+
+func (p *postgres) PruneWebhookDeliveries(before time.Time) (int, error) {
+	q := `DELETE FROM webhook_deliveries WHERE status <> $1 AND created_at < $2;`
+	var n int64
+	err := p.readWriteTransaction(func(tx *sql.Tx) error {
+		res, err := tx.Exec(q, model.WebhookDeliveryPending, before.UTC())
+		if err != nil {
+			return err
+		}
+		n, err = res.RowsAffected()
+		return err
+	})
+	return int(n), err
+}
+
+func (p *postgres) GetChatUser(account model.ChatAccount) (int, error) {
+	q := `SELECT user_id FROM chat_accounts WHERE platform = $1 AND team_id = $2 AND chat_user_id = $3;`
+	var userID int
+	err := p.readOnlyTransaction(func(tx *sql.Tx) error {
+		return tx.QueryRow(q, account.Platform, account.TeamID, account.UserID).Scan(&userID)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNoChatAccount
+	}
+	return userID, err
+}
+
+func (p *postgres) SaveChatLink(code string, account model.ChatAccount, expires time.Time) error {
+	insert := `INSERT INTO chat_links (code, platform, team_id, chat_user_id, name, expires_at) VALUES ($1, $2, $3, $4, $5, $6);`
+	return p.readWriteTransaction(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM chat_links WHERE expires_at <= NOW();`); err != nil {
+			return err
+		}
+		_, err := tx.Exec(insert, code, account.Platform, account.TeamID, account.UserID, account.Name, expires.UTC())
+		return err
+	})
+}
+
+func (p *postgres) GetChatLink(code string, now time.Time) (model.ChatAccount, error) {
+	q := `SELECT platform, team_id, chat_user_id, name FROM chat_links WHERE code = $1 AND expires_at > $2;`
+	var account model.ChatAccount
+	err := p.readOnlyTransaction(func(tx *sql.Tx) error {
+		return tx.QueryRow(q, code, now.UTC()).Scan(&account.Platform, &account.TeamID, &account.UserID, &account.Name)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.ChatAccount{}, ErrNoChatLink
+	}
+	return account, err
+}
+
+func (p *postgres) LinkChatAccount(userID int, code string, now time.Time) (model.ChatAccount, error) {
+	claim := `DELETE FROM chat_links WHERE code = $1 AND expires_at > $2 RETURNING platform, team_id, chat_user_id, name;`
+	// Linking an account again moves it to the new user.
+	upsert := `INSERT INTO chat_accounts (platform, team_id, chat_user_id, user_id, name) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (platform, team_id, chat_user_id) DO UPDATE SET user_id = EXCLUDED.user_id, name = EXCLUDED.name, linked_at = NOW();`
+	var account model.ChatAccount
+	err := p.readWriteTransaction(func(tx *sql.Tx) error {
+		err := tx.QueryRow(claim, code, now.UTC()).Scan(&account.Platform, &account.TeamID, &account.UserID, &account.Name)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoChatLink
+		}
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(upsert, account.Platform, account.TeamID, account.UserID, userID, account.Name)
+		return err
+	})
+	if err != nil {
+		return model.ChatAccount{}, err
+	}
+	return account, nil
+}
+
+func (p *postgres) UnlinkChatAccount(account model.ChatAccount) error {
+	q := `DELETE FROM chat_accounts WHERE platform = $1 AND team_id = $2 AND chat_user_id = $3;`
+	return p.readWriteTransaction(func(tx *sql.Tx) error {
+		res, err := tx.Exec(q, account.Platform, account.TeamID, account.UserID)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrNoChatAccount
+		}
+		return nil
+	})
+}
+
+func (p *postgres) ClaimNotification(userID int, kind string, period string, now time.Time) (bool, error) {
+	q := `INSERT INTO notification_log (user_id, kind, period, sent_at) VALUES ($1, $2, $3, $4)
+		  ON CONFLICT DO NOTHING;`
+	var n int64
+	err := p.readWriteTransaction(func(tx *sql.Tx) error {
+		res, err := tx.Exec(q, userID, kind, period, now.UTC())
+		if err != nil {
+			return err
+		}
+		n, err = res.RowsAffected()
+		return err
+	})
+	return n == 1, err
+}
+
+func (p *postgres) ReleaseNotification(userID int, kind string, period string) error {
+	q := `DELETE FROM notification_log WHERE user_id = $1 AND kind = $2 AND period = $3;`
+	return p.readWriteTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(q, userID, kind, period)
+		return err
+	})
+}
+
+func (p *postgres) PruneNotifications(before time.Time) (int, error) {
+	q := `DELETE FROM notification_log WHERE sent_at < $1;`
+	var n int64
+	err := p.readWriteTransaction(func(tx *sql.Tx) error {
+		res, err := tx.Exec(q, before.UTC())
+		if err != nil {
+			return err
+		}
+		n, err = res.RowsAffected()
+		return err
+	})
+	return int(n), err
 }
 
 func (p *postgres) IsUserSuspended(userID int) (bool, error) {
@@ -676,3 +1506,27 @@ func (p *postgres) CountEntriesByState() (map[model.State]int, error) {
 	})
 	return result, err
 }
+
+func (p *postgres) CountCustomEntriesByAttendance() (map[model.Attendance]int, error) {
+	q := `SELECT c.attendance, COUNT(*) FROM entries e
+		JOIN custom_states c ON c.user_id = e.user_id AND c.state_id = e.state
+		GROUP BY c.attendance;`
+	result := make(map[model.Attendance]int)
+	err := p.readOnlyTransaction(func(tx *sql.Tx) error {
+		rows, err := tx.Query(q)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var attendance string
+			var count int
+			if err := rows.Scan(&attendance, &count); err != nil {
+				return err
+			}
+			result[model.Attendance(attendance)] = count
+		}
+		return rows.Err()
+	})
+	return result, err
+}
@@ -0,0 +1,18 @@
+-- Append-only history of attendance changes. A row is written for every write
+-- that changes the stored state of a day, recording the previous and new state
+-- and how the writer authenticated. Rows are never updated or deleted.
+CREATE TABLE IF NOT EXISTS "entry_history" (
+    "id"          SERIAL PRIMARY KEY,
+    "user_id"     INTEGER NOT NULL REFERENCES "users" ("user_id"),
+    "day"         INTEGER NOT NULL,
+    "month"       INTEGER NOT NULL,
+    "year"        INTEGER NOT NULL,
+    "old_state"   INTEGER NOT NULL,
+    "new_state"   INTEGER NOT NULL,
+    "changed_at"  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    "auth_method" TEXT NOT NULL DEFAULT '',
+    "token_id"    INTEGER,
+    "via"         TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX ON "entry_history" ("user_id", "year", "month", "day");
@@ -0,0 +1,7 @@
+-- Entry provenance: where each entry came from and when it was last written.
+-- Both are NULL for entries written before they were recorded.
+ALTER TABLE "entries"
+ADD COLUMN IF NOT EXISTS "source" TEXT;
+
+ALTER TABLE "entries"
+ADD COLUMN IF NOT EXISTS "updated_at" TIMESTAMPTZ;
@@ -0,0 +1,4 @@
+-- Per-day notes, stored alongside the day's entry. Monthly notes stay in the
+-- "notes" table.
+ALTER TABLE "entries"
+ADD COLUMN IF NOT EXISTS "note" TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,14 @@
+-- User-defined attendance states. IDs start at 100 (model.CustomStateBase) and
+-- are never reused: removing a state archives it so entries using it can
+-- still be described.
+CREATE TABLE IF NOT EXISTS "custom_states" (
+    "user_id" INTEGER NOT NULL,
+    "state_id" INTEGER NOT NULL,
+    "name" TEXT NOT NULL,
+    "color" TEXT NOT NULL,
+    "attendance" TEXT NOT NULL,
+    "archived" BOOLEAN NOT NULL DEFAULT FALSE,
+    PRIMARY KEY ("user_id", "state_id")
+);
+
+ALTER TABLE "custom_states" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("user_id");
@@ -0,0 +1,18 @@
+-- Named places a user works from. IDs count up from 1 per user; removing a
+-- location archives it so entries that reference it can still be described.
+CREATE TABLE IF NOT EXISTS "locations" (
+    "user_id" INTEGER NOT NULL,
+    "location_id" INTEGER NOT NULL,
+    "name" TEXT NOT NULL,
+    "latitude" DOUBLE PRECISION,
+    "longitude" DOUBLE PRECISION,
+    "radius" INTEGER NOT NULL DEFAULT 0,
+    "archived" BOOLEAN NOT NULL DEFAULT FALSE,
+    PRIMARY KEY ("user_id", "location_id")
+);
+
+ALTER TABLE "locations" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("user_id");
+
+-- The location an entry was spent at; 0 means none.
+ALTER TABLE "entries"
+ADD COLUMN IF NOT EXISTS "location_id" INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,8 @@
+-- Attendance target policies: how the target is measured (percent, days per
+-- week or days per month) and the window it's measured over. Existing targets
+-- keep meaning a percentage per calendar month.
+ALTER TABLE "user_preferences"
+ADD COLUMN IF NOT EXISTS "target_policy" TEXT NOT NULL DEFAULT 'percent',
+ADD COLUMN IF NOT EXISTS "target_days" DOUBLE PRECISION NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS "target_window" TEXT NOT NULL DEFAULT 'month',
+ADD COLUMN IF NOT EXISTS "target_window_weeks" INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,13 @@
+-- Recurring schedule rules layered over the weekly schedule. IDs count up from
+-- 1 per user and give the order rules apply in.
+CREATE TABLE IF NOT EXISTS "schedule_rules" (
+    "user_id" INTEGER NOT NULL,
+    "rule_id" INTEGER NOT NULL,
+    "state" INTEGER NOT NULL,
+    "rrule" TEXT NOT NULL,
+    "start_date" DATE NOT NULL,
+    "end_date" DATE,
+    PRIMARY KEY ("user_id", "rule_id")
+);
+
+ALTER TABLE "schedule_rules" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("user_id");
@@ -0,0 +1,18 @@
+-- Versions of the weekly schedule, so past days keep the schedule that was in
+-- force at the time. A NULL effective_from is the schedule from before
+-- versions were kept and covers all earlier days.
+CREATE TABLE IF NOT EXISTS "schedule_history" (
+    "user_id" INTEGER NOT NULL,
+    "effective_from" DATE,
+    "monday_state" INTEGER NOT NULL DEFAULT 0,
+    "tuesday_state" INTEGER NOT NULL DEFAULT 0,
+    "wednesday_state" INTEGER NOT NULL DEFAULT 0,
+    "thursday_state" INTEGER NOT NULL DEFAULT 0,
+    "friday_state" INTEGER NOT NULL DEFAULT 0,
+    "saturday_state" INTEGER NOT NULL DEFAULT 0,
+    "sunday_state" INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS "schedule_history_user" ON "schedule_history" ("user_id", "effective_from");
+
+ALTER TABLE "schedule_history" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("user_id");
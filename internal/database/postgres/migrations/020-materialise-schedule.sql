@@ -0,0 +1,7 @@
+-- Opt-in saving of scheduled days as entries. Entries saved this way can be
+-- flagged as unconfirmed until the user confirms them.
+ALTER TABLE "user_preferences"
+ADD COLUMN IF NOT EXISTS "materialise_mode" TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE "entries"
+ADD COLUMN IF NOT EXISTS "unconfirmed" BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,3 @@
+-- Per-user timezone for working out "today". Empty uses the server's timezone.
+ALTER TABLE "user_preferences"
+ADD COLUMN IF NOT EXISTS "timezone" TEXT NOT NULL DEFAULT '';
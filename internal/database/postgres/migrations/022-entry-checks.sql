@@ -0,0 +1,10 @@
+-- Keep impossible dates and unstorable states out of entries. The schedule
+-- states (4-6) are only ever computed for display. NOT VALID skips checking
+-- rows written before the constraints existed.
+ALTER TABLE "entries" DROP CONSTRAINT IF EXISTS "entries_date_check";
+ALTER TABLE "entries"
+ADD CONSTRAINT "entries_date_check" CHECK ("month" BETWEEN 1 AND 12 AND "day" BETWEEN 1 AND 31 AND "year" BETWEEN 1 AND 9999) NOT VALID;
+
+ALTER TABLE "entries" DROP CONSTRAINT IF EXISTS "entries_state_check";
+ALTER TABLE "entries"
+ADD CONSTRAINT "entries_state_check" CHECK ("state" BETWEEN 0 AND 3 OR "state" >= 100) NOT VALID;
@@ -0,0 +1,31 @@
+-- Outbound webhooks. IDs count up from 1 per user. Events are stored as a
+-- comma-separated list.
+CREATE TABLE IF NOT EXISTS "webhooks" (
+    "user_id"    INTEGER NOT NULL REFERENCES "users" ("user_id"),
+    "webhook_id" INTEGER NOT NULL,
+    "url"        TEXT NOT NULL,
+    "events"     TEXT NOT NULL,
+    "secret"     TEXT NOT NULL,
+    "disabled"   BOOLEAN NOT NULL DEFAULT FALSE,
+    PRIMARY KEY ("user_id", "webhook_id")
+);
+
+-- Webhook deliveries are both the queue the delivery worker reads and the log
+-- shown on the developer page. Finished deliveries are pruned after a while.
+CREATE TABLE IF NOT EXISTS "webhook_deliveries" (
+    "delivery_id"     SERIAL PRIMARY KEY,
+    "user_id"         INTEGER NOT NULL,
+    "webhook_id"      INTEGER NOT NULL,
+    "event"           TEXT NOT NULL,
+    "payload"         TEXT NOT NULL,
+    "status"          TEXT NOT NULL,
+    "attempts"        INTEGER NOT NULL DEFAULT 0,
+    "next_attempt_at" TIMESTAMPTZ,
+    "response_code"   INTEGER NOT NULL DEFAULT 0,
+    "error"           TEXT NOT NULL DEFAULT '',
+    "created_at"      TIMESTAMPTZ NOT NULL,
+    FOREIGN KEY ("user_id", "webhook_id") REFERENCES "webhooks" ("user_id", "webhook_id") ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS "webhook_deliveries_due" ON "webhook_deliveries" ("next_attempt_at") WHERE "status" = 'pending';
+CREATE INDEX IF NOT EXISTS "webhook_deliveries_webhook" ON "webhook_deliveries" ("user_id", "webhook_id", "delivery_id");
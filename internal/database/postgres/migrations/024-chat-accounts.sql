@@ -0,0 +1,22 @@
+-- Chat users linked to Officetracker users, for slash commands. The team is
+-- the Slack workspace or Teams tenant.
+CREATE TABLE IF NOT EXISTS "chat_accounts" (
+    "platform"     TEXT NOT NULL,
+    "team_id"      TEXT NOT NULL,
+    "chat_user_id" TEXT NOT NULL,
+    "user_id"      INTEGER NOT NULL REFERENCES "users" ("user_id"),
+    "name"         TEXT NOT NULL DEFAULT '',
+    "linked_at"    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY ("platform", "team_id", "chat_user_id")
+);
+
+-- Single-use codes a chat user opens to link their account. They expire after
+-- a few minutes.
+CREATE TABLE IF NOT EXISTS "chat_links" (
+    "code"         TEXT PRIMARY KEY,
+    "platform"     TEXT NOT NULL,
+    "team_id"      TEXT NOT NULL,
+    "chat_user_id" TEXT NOT NULL,
+    "name"         TEXT NOT NULL DEFAULT '',
+    "expires_at"   TIMESTAMPTZ NOT NULL
+);
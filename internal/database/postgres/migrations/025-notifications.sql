@@ -0,0 +1,19 @@
+-- Opt-in email notifications. Auth providers don't share an email address, so
+-- users enter the one to send to.
+ALTER TABLE "user_preferences"
+ADD COLUMN IF NOT EXISTS "notification_email" TEXT NOT NULL DEFAULT '',
+ADD COLUMN IF NOT EXISTS "daily_reminder" BOOLEAN NOT NULL DEFAULT FALSE,
+ADD COLUMN IF NOT EXISTS "weekly_digest" BOOLEAN NOT NULL DEFAULT FALSE,
+ADD COLUMN IF NOT EXISTS "monthly_summary" BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Notifications already sent, so the hourly job sends each once. The period is
+-- the day, week or month the notification is about.
+CREATE TABLE IF NOT EXISTS "notification_log" (
+    "user_id" INTEGER NOT NULL REFERENCES "users" ("user_id"),
+    "kind"    TEXT NOT NULL,
+    "period"  TEXT NOT NULL,
+    "sent_at" TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY ("user_id", "kind", "period")
+);
+
+CREATE INDEX IF NOT EXISTS "notification_log_sent_at" ON "notification_log" ("sent_at");
@@ -0,0 +1,17 @@
+-- Browsers subscribed to Web Push notifications. IDs count up from 1 per user.
+-- An endpoint belongs to one browser, so it is only ever subscribed once.
+CREATE TABLE IF NOT EXISTS "push_subscriptions" (
+    "user_id"         INTEGER NOT NULL REFERENCES "users" ("user_id"),
+    "subscription_id" INTEGER NOT NULL,
+    "endpoint"        TEXT NOT NULL UNIQUE,
+    "p256dh"          TEXT NOT NULL,
+    "auth"            TEXT NOT NULL,
+    "device"          TEXT NOT NULL DEFAULT '',
+    "expires_at"      TIMESTAMPTZ,
+    "created_at"      TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY ("user_id", "subscription_id")
+);
+
+ALTER TABLE "user_preferences"
+ADD COLUMN IF NOT EXISTS "push_reminder" BOOLEAN NOT NULL DEFAULT FALSE,
+ADD COLUMN IF NOT EXISTS "push_target_warning" BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,16 @@
+-- Mobile app devices registered for push notifications through Expo. IDs count
+-- up from 1 per user. Each is tied to the API token the app signed in with,
+-- if any, and deleted when that is revoked.
+CREATE TABLE IF NOT EXISTS "push_tokens" (
+    "user_id"       INTEGER NOT NULL REFERENCES "users" ("user_id"),
+    "push_token_id" INTEGER NOT NULL,
+    "token"         TEXT NOT NULL UNIQUE,
+    "auth_token_id" INTEGER REFERENCES "secrets" ("token_id"),
+    "device"        TEXT NOT NULL DEFAULT '',
+    "reminder"      BOOLEAN NOT NULL DEFAULT FALSE,
+    "check_in"      BOOLEAN NOT NULL DEFAULT FALSE,
+    "created_at"    TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY ("user_id", "push_token_id")
+);
+
+CREATE INDEX ON "push_tokens" ("auth_token_id");
@@ -0,0 +1,11 @@
+-- Networks a user's check-ins count as in the office from: Wi-Fi names, IP
+-- ranges and VPN hostnames. IDs count up from 1 per user. The location the
+-- network belongs to is 0 for none.
+CREATE TABLE IF NOT EXISTS "network_rules" (
+    "user_id" INTEGER NOT NULL REFERENCES "users" ("user_id"),
+    "rule_id" INTEGER NOT NULL,
+    "kind" TEXT NOT NULL,
+    "value" TEXT NOT NULL,
+    "location_id" INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY ("user_id", "rule_id")
+);
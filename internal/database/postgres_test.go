@@ -2,8 +2,11 @@ package database
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"sync"
 	"testing"
@@ -110,7 +113,7 @@ func truncateAll(cfg config.Postgres) error {
 		return err
 	}
 	defer db.Close()
-	_, err = db.Exec(`TRUNCATE entries, notes, secrets, auth0_users, gh_users, user_preferences, stats_snapshots, users RESTART IDENTITY CASCADE;`)
+	_, err = db.Exec(`TRUNCATE entries, entry_history, notes, secrets, auth0_users, gh_users, user_preferences, stats_snapshots, custom_states, locations, users RESTART IDENTITY CASCADE;`)
 	return err
 }
 
@@ -180,6 +183,127 @@ func TestPostgresPerUserIsolation(t *testing.T) {
 	}
 }
 
+func TestPostgresEntrySource(t *testing.T) {
+	db := pgTestDB(t)
+	uid := seedUser(t, pgCfg)
+
+	at := time.Date(2024, 3, 5, 8, 30, 0, 0, time.UTC)
+	db.SaveDay(uid, 5, 3, 2024, model.DayState{State: model.StateWorkFromOffice, Source: model.SourceGeofence, UpdatedAt: at})
+	db.SaveMonth(uid, 3, 2024, model.MonthState{Days: map[int]model.DayState{
+		6: {State: model.StateWorkFromHome, Source: model.SourceImport, UpdatedAt: at, Note: "client site"},
+		7: {State: model.StateOther},
+	}})
+
+	day, err := db.GetDay(uid, 5, 3, 2024)
+	if err != nil {
+		t.Fatalf("GetDay: %v", err)
+	}
+	if day.Source != model.SourceGeofence || !day.UpdatedAt.Equal(at) {
+		t.Errorf("GetDay = %+v, want geofence at %v", day, at)
+	}
+
+	month, _ := db.GetMonth(uid, 3, 2024)
+	if month.Days[6].Source != model.SourceImport || month.Days[6].Note != "client site" {
+		t.Errorf("GetMonth day 6 = %+v, want import with note", month.Days[6])
+	}
+	if month.Days[7].Source != "" || !month.Days[7].UpdatedAt.IsZero() {
+		t.Errorf("GetMonth day 7 = %+v, want no source or timestamp", month.Days[7])
+	}
+
+	year, _ := db.GetYear(uid, 2024, 1)
+	if year.Months[3].Days[5].Source != model.SourceGeofence {
+		t.Errorf("GetYear day 5 source = %q, want geofence", year.Months[3].Days[5].Source)
+	}
+}
+
+// History rows are scoped per user and returned oldest first, with the token
+// the write was made with.
+func TestPostgresDayHistory(t *testing.T) {
+	db := pgTestDB(t)
+	u1 := seedUser(t, pgCfg)
+	u2 := seedUser(t, pgCfg)
+
+	at := time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC)
+	err := db.SaveEntries(u1, nil, []DayChange{
+		{Day: 5, Month: 3, Year: 2024, OldState: model.StateWorkFromOffice, NewState: model.StateWorkFromHome, ChangedAt: at.Add(time.Hour), AuthMethod: "secret", TokenID: 3, Via: "mcp"},
+		{Day: 5, Month: 3, Year: 2024, OldState: model.StateUntracked, NewState: model.StateWorkFromOffice, ChangedAt: at, AuthMethod: "sso", Via: "api"},
+	})
+	if err != nil {
+		t.Fatalf("SaveEntries: %v", err)
+	}
+
+	got, err := db.GetDayHistory(u1, 5, 3, 2024)
+	if err != nil {
+		t.Fatalf("GetDayHistory: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d changes, want 2", len(got))
+	}
+	if got[0].AuthMethod != "sso" || got[0].TokenID != 0 {
+		t.Errorf("first change = %+v, want sso with no token", got[0])
+	}
+	if got[1].AuthMethod != "secret" || got[1].TokenID != 3 || got[1].Via != "mcp" {
+		t.Errorf("second change = %+v, want secret token 3 via mcp", got[1])
+	}
+
+	if other, _ := db.GetDayHistory(u2, 5, 3, 2024); len(other) != 0 {
+		t.Errorf("user 2 sees %d of user 1's changes", len(other))
+	}
+}
+
+func TestPostgresSaveEntries(t *testing.T) {
+	db := pgTestDB(t)
+	u1 := seedUser(t, pgCfg)
+
+	at := time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{Day: 29, Month: 2, Year: 2024, State: model.DayState{State: model.StateWorkFromOffice, Source: model.SourceManual, Note: "leap"}},
+		{Day: 1, Month: 3, Year: 2024, State: model.DayState{State: model.StateWorkFromHome}},
+	}
+	changes := []DayChange{
+		{Day: 29, Month: 2, Year: 2024, NewState: model.StateWorkFromOffice, ChangedAt: at, AuthMethod: "sso", Via: "api"},
+	}
+	if err := db.SaveEntries(u1, entries, changes); err != nil {
+		t.Fatalf("SaveEntries: %v", err)
+	}
+
+	if got, _ := db.GetDay(u1, 29, 2, 2024); got.State != model.StateWorkFromOffice || got.Note != "leap" || got.Source != model.SourceManual {
+		t.Errorf("29 February = %+v", got)
+	}
+	if got, _ := db.GetDay(u1, 1, 3, 2024); got.State != model.StateWorkFromHome {
+		t.Errorf("1 March = %+v", got)
+	}
+	if history, _ := db.GetDayHistory(u1, 29, 2, 2024); len(history) != 1 {
+		t.Errorf("29 February history = %+v, want one change", history)
+	}
+}
+
+func TestPostgresRejectsInvalidEntries(t *testing.T) {
+	db := pgTestDB(t)
+	uid := seedUser(t, pgCfg)
+
+	office := model.DayState{State: model.StateWorkFromOffice}
+	if err := db.SaveDay(uid, 31, 2, 2024, office); !errors.Is(err, ErrInvalidEntry) {
+		t.Errorf("SaveDay(31 February) = %v, want ErrInvalidEntry", err)
+	}
+	if err := db.SaveDay(uid, 1, 3, 2024, model.DayState{State: model.StateScheduledWorkFromOffice}); !errors.Is(err, ErrInvalidEntry) {
+		t.Errorf("SaveDay(scheduled state) = %v, want ErrInvalidEntry", err)
+	}
+	if err := db.SaveMonth(uid, 13, 2024, model.MonthState{Days: map[int]model.DayState{1: office}}); !errors.Is(err, ErrInvalidEntry) {
+		t.Errorf("SaveMonth(month 13) = %v, want ErrInvalidEntry", err)
+	}
+	entries := []Entry{
+		{Day: 28, Month: 2, Year: 2023, State: office},
+		{Day: 29, Month: 2, Year: 2023, State: office},
+	}
+	if err := db.SaveEntries(uid, entries, nil); !errors.Is(err, ErrInvalidEntry) {
+		t.Errorf("SaveEntries(29 February 2023) = %v, want ErrInvalidEntry", err)
+	}
+	if got, _ := db.GetDay(uid, 28, 2, 2023); got.State != model.StateUntracked {
+		t.Errorf("28 February 2023 = %+v, want nothing saved", got)
+	}
+}
+
 func TestPostgresMonthRoundTrip(t *testing.T) {
 	db := pgTestDB(t)
 	uid := seedUser(t, pgCfg)
@@ -316,6 +440,11 @@ func TestPostgresPreferences(t *testing.T) {
 	if cal.TrackingYearStartMonth != 10 {
 		t.Errorf("calendar out-of-range = %d, want 10", cal.TrackingYearStartMonth)
 	}
+	db.SaveCalendarPreferences(uid, model.CalendarPreferences{TrackingYearStartMonth: 7, Timezone: "Europe/London"})
+	cal, _ = db.GetCalendarPreferences(uid)
+	if cal.TrackingYearStartMonth != 7 || cal.Timezone != "Europe/London" {
+		t.Errorf("calendar timezone round-trip = %+v", cal)
+	}
 
 	// Attendance target: no target before any save.
 	target, err := db.GetTargetPreferences(uid)
@@ -339,6 +468,15 @@ func TestPostgresPreferences(t *testing.T) {
 	if target.TargetPercent != 0 {
 		t.Errorf("cleared target = %d, want 0", target.TargetPercent)
 	}
+	// Day-based policies and windows round-trip.
+	rolling := model.TargetPreferences{
+		Policy: model.TargetPolicyDaysPerWeek, TargetDays: 2.5, Window: model.TargetWindowRollingWeeks, WindowWeeks: 8,
+	}
+	db.SaveTargetPreferences(uid, rolling)
+	target, _ = db.GetTargetPreferences(uid)
+	if target != rolling {
+		t.Errorf("policy round-trip = %+v, want %+v", target, rolling)
+	}
 }
 
 // Secrets/tokens: save, list active, look up by value, revoke.
@@ -484,6 +622,60 @@ func TestPostgresAggregates(t *testing.T) {
 	}
 }
 
+func TestPostgresCustomStates(t *testing.T) {
+	db := pgTestDB(t)
+	uid := seedUser(t, pgCfg)
+
+	created, err := db.SaveCustomState(uid, model.CustomState{Name: "Client site", Color: "#ff9800", Attendance: model.AttendancePresent})
+	if err != nil {
+		t.Fatalf("SaveCustomState create: %v", err)
+	}
+	if created.ID != model.CustomStateBase {
+		t.Errorf("first custom state ID = %d, want %d", created.ID, model.CustomStateBase)
+	}
+	leave, err := db.SaveCustomState(uid, model.CustomState{Name: "Leave", Color: "#9e9e9e", Attendance: model.AttendanceExcluded})
+	if err != nil {
+		t.Fatalf("SaveCustomState create: %v", err)
+	}
+	if leave.ID != model.CustomStateBase+1 {
+		t.Errorf("second custom state ID = %d, want %d", leave.ID, model.CustomStateBase+1)
+	}
+
+	leave.Archived = true
+	if _, err := db.SaveCustomState(uid, leave); err != nil {
+		t.Fatalf("SaveCustomState update: %v", err)
+	}
+	if _, err := db.SaveCustomState(uid, model.CustomState{ID: 150, Name: "Ghost"}); !errors.Is(err, ErrNoCustomState) {
+		t.Errorf("updating a missing state = %v, want ErrNoCustomState", err)
+	}
+
+	states, err := db.GetCustomStates(uid)
+	if err != nil {
+		t.Fatalf("GetCustomStates: %v", err)
+	}
+	if len(states) != 2 || states[0] != created || !states[1].Archived {
+		t.Errorf("GetCustomStates = %+v", states)
+	}
+
+	db.SaveDay(uid, 1, 1, 2024, model.DayState{State: created.ID})
+	db.SaveDay(uid, 2, 1, 2024, model.DayState{State: created.ID})
+	db.SaveDay(uid, 3, 1, 2024, model.DayState{State: leave.ID})
+	db.SaveDay(uid, 4, 1, 2024, model.DayState{State: model.StateWorkFromOffice})
+	byAttendance, err := db.CountCustomEntriesByAttendance()
+	if err != nil {
+		t.Fatalf("CountCustomEntriesByAttendance: %v", err)
+	}
+	if byAttendance[model.AttendancePresent] != 2 || byAttendance[model.AttendanceExcluded] != 1 || len(byAttendance) != 2 {
+		t.Errorf("CountCustomEntriesByAttendance = %v", byAttendance)
+	}
+
+	// IDs are allocated per user.
+	other := seedUser(t, pgCfg)
+	if got, _ := db.SaveCustomState(other, model.CustomState{Name: "Travel", Color: "#000000", Attendance: model.AttendanceAbsent}); got.ID != model.CustomStateBase {
+		t.Errorf("other user's first ID = %d, want %d", got.ID, model.CustomStateBase)
+	}
+}
+
 // Stats snapshots persist and read back the latest widgets with a timestamp.
 func TestPostgresStatsSnapshot(t *testing.T) {
 	db := pgTestDB(t)
@@ -512,3 +704,471 @@ func TestPostgresStatsSnapshot(t *testing.T) {
 		t.Errorf("snapshot timestamp looks wrong: %v", ts)
 	}
 }
+
+func TestPostgresLocations(t *testing.T) {
+	db := pgTestDB(t)
+	uid := seedUser(t, pgCfg)
+
+	lat, lng := -37.8136, 144.9631
+	office, err := db.SaveLocation(uid, model.Location{Name: "Melbourne", Latitude: &lat, Longitude: &lng, Radius: 150})
+	if err != nil {
+		t.Fatalf("SaveLocation create: %v", err)
+	}
+	if office.ID != 1 {
+		t.Errorf("first location ID = %d, want 1", office.ID)
+	}
+	remote, err := db.SaveLocation(uid, model.Location{Name: "Sydney"})
+	if err != nil {
+		t.Fatalf("SaveLocation create: %v", err)
+	}
+	if remote.ID != 2 {
+		t.Errorf("second location ID = %d, want 2", remote.ID)
+	}
+
+	remote.Archived = true
+	if _, err := db.SaveLocation(uid, remote); err != nil {
+		t.Fatalf("SaveLocation update: %v", err)
+	}
+	if _, err := db.SaveLocation(uid, model.Location{ID: 9, Name: "Ghost"}); !errors.Is(err, ErrNoLocation) {
+		t.Errorf("updating a missing location = %v, want ErrNoLocation", err)
+	}
+
+	locations, err := db.GetLocations(1)
+	if err != nil {
+		t.Fatalf("GetLocations: %v", err)
+	}
+	if len(locations) != 2 || locations[0].Latitude == nil || *locations[0].Latitude != lat || locations[0].Radius != 150 ||
+		locations[1].Latitude != nil || !locations[1].Archived {
+		t.Errorf("GetLocations = %+v", locations)
+	}
+
+	if err := db.SaveDay(uid, 2, 1, 2024, model.DayState{State: model.StateWorkFromOffice, LocationID: office.ID}); err != nil {
+		t.Fatalf("SaveDay: %v", err)
+	}
+	if day, _ := db.GetDay(uid, 2, 1, 2024); day.LocationID != office.ID {
+		t.Errorf("GetDay location = %d, want %d", day.LocationID, office.ID)
+	}
+	if month, _ := db.GetMonth(uid, 1, 2024); month.Days[2].LocationID != office.ID {
+		t.Errorf("GetMonth location = %d, want %d", month.Days[2].LocationID, office.ID)
+	}
+
+	// IDs are allocated per user.
+	other := seedUser(t, pgCfg)
+	if got, _ := db.SaveLocation(other, model.Location{Name: "Brisbane"}); got.ID != 1 {
+		t.Errorf("other user's first ID = %d, want 1", got.ID)
+	}
+}
+
+func TestPostgresWebhooks(t *testing.T) {
+	db := pgTestDB(t)
+	uid := seedUser(t, pgCfg)
+
+	events := []model.WebhookEvent{model.WebhookEventDayUpdated, model.WebhookEventTargetMissed}
+	hook, err := db.SaveWebhook(uid, model.Webhook{URL: "https://example.com/a", Events: events, Secret: "whsec_a"})
+	if err != nil {
+		t.Fatalf("SaveWebhook create: %v", err)
+	}
+	if hook.ID != 1 {
+		t.Errorf("first webhook ID = %d, want 1", hook.ID)
+	}
+	hook.Disabled, hook.Secret = true, ""
+	if _, err := db.SaveWebhook(uid, hook); err != nil {
+		t.Fatalf("SaveWebhook update: %v", err)
+	}
+	if _, err := db.SaveWebhook(uid, model.Webhook{ID: 9, URL: "https://example.com"}); !errors.Is(err, ErrNoWebhook) {
+		t.Errorf("updating a missing webhook = %v, want ErrNoWebhook", err)
+	}
+	if webhooks, _ := db.GetWebhooks(uid); len(webhooks) != 1 || !webhooks[0].Disabled || webhooks[0].Secret != "whsec_a" ||
+		!slices.Equal(webhooks[0].Events, events) {
+		t.Errorf("GetWebhooks = %+v", webhooks)
+	}
+
+	now := time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC)
+	err = db.QueueWebhookDeliveries([]WebhookDelivery{
+		{UserID: uid, WebhookDelivery: model.WebhookDelivery{WebhookID: hook.ID, Event: model.WebhookEventDayUpdated, CreatedAt: now, Payload: []byte(`{}`)}},
+		{UserID: uid, WebhookDelivery: model.WebhookDelivery{WebhookID: hook.ID, Event: model.WebhookEventDayUpdated, CreatedAt: now.Add(time.Hour), Payload: []byte(`{}`)}},
+	})
+	if err != nil {
+		t.Fatalf("QueueWebhookDeliveries: %v", err)
+	}
+	claimed, err := db.ClaimWebhookDeliveries(now, now.Add(5*time.Minute), 10)
+	if err != nil || len(claimed) != 1 || claimed[0].UserID != uid {
+		t.Fatalf("ClaimWebhookDeliveries = (%+v, %v), want the due delivery", claimed, err)
+	}
+	if again, _ := db.ClaimWebhookDeliveries(now, now.Add(5*time.Minute), 10); len(again) != 0 {
+		t.Errorf("claimed again = %+v, want none until the lease ends", again)
+	}
+
+	d := claimed[0]
+	d.Status, d.Attempts, d.ResponseCode = model.WebhookDeliveryDelivered, 1, 204
+	if err := db.SaveWebhookAttempt(d); err != nil {
+		t.Fatalf("SaveWebhookAttempt: %v", err)
+	}
+	log, err := db.GetWebhookDeliveries(uid, hook.ID, 10)
+	if err != nil || len(log) != 2 || log[1].Status != model.WebhookDeliveryDelivered || !log[1].NextAttemptAt.IsZero() {
+		t.Errorf("GetWebhookDeliveries = (%+v, %v), want newest first with the attempt saved", log, err)
+	}
+	if n, err := db.PruneWebhookDeliveries(now.Add(2 * time.Hour)); err != nil || n != 1 {
+		t.Errorf("PruneWebhookDeliveries = (%d, %v), want 1", n, err)
+	}
+
+	if err := db.DeleteWebhook(uid, hook.ID); err != nil {
+		t.Fatalf("DeleteWebhook: %v", err)
+	}
+	if log, _ := db.GetWebhookDeliveries(uid, hook.ID, 10); len(log) != 0 {
+		t.Errorf("deliveries of a deleted webhook = %+v", log)
+	}
+}
+
+func TestPostgresChatAccounts(t *testing.T) {
+	db := pgTestDB(t)
+	uid := seedUser(t, pgCfg)
+	other := seedUser(t, pgCfg)
+	account := model.ChatAccount{Platform: model.ChatPlatformTeams, TeamID: "tenant", UserID: "aad-1", Name: "Alex"}
+	now := time.Now()
+
+	if _, err := db.GetChatUser(account); !errors.Is(err, ErrNoChatAccount) {
+		t.Errorf("GetChatUser before linking = %v, want ErrNoChatAccount", err)
+	}
+	if err := db.SaveChatLink("code", account, now.Add(10*time.Minute)); err != nil {
+		t.Fatalf("SaveChatLink: %v", err)
+	}
+	if got, err := db.GetChatLink("code", now); err != nil || got != account {
+		t.Errorf("GetChatLink = (%+v, %v), want %+v", got, err, account)
+	}
+	if _, err := db.GetChatLink("code", now.Add(time.Hour)); !errors.Is(err, ErrNoChatLink) {
+		t.Errorf("GetChatLink after expiry = %v, want ErrNoChatLink", err)
+	}
+	if got, err := db.LinkChatAccount(uid, "code", now); err != nil || got != account {
+		t.Fatalf("LinkChatAccount = (%+v, %v), want %+v", got, err, account)
+	}
+	if _, err := db.LinkChatAccount(uid, "code", now); !errors.Is(err, ErrNoChatLink) {
+		t.Errorf("reusing a code = %v, want ErrNoChatLink", err)
+	}
+	if userID, err := db.GetChatUser(account); err != nil || userID != uid {
+		t.Errorf("GetChatUser = (%d, %v), want %d", userID, err, uid)
+	}
+
+	// Linking the account from another user moves it.
+	db.SaveChatLink("move", account, now.Add(time.Minute))
+	if _, err := db.LinkChatAccount(other, "move", now); err != nil {
+		t.Fatalf("LinkChatAccount to another user: %v", err)
+	}
+	if userID, _ := db.GetChatUser(account); userID != other {
+		t.Errorf("GetChatUser after moving = %d, want %d", userID, other)
+	}
+
+	if err := db.UnlinkChatAccount(account); err != nil {
+		t.Fatalf("UnlinkChatAccount: %v", err)
+	}
+	if err := db.UnlinkChatAccount(account); !errors.Is(err, ErrNoChatAccount) {
+		t.Errorf("unlinking again = %v, want ErrNoChatAccount", err)
+	}
+}
+
+func TestPostgresNetworkRules(t *testing.T) {
+	db := pgTestDB(t)
+	uid := seedUser(t, pgCfg)
+
+	first, err := db.SaveNetworkRule(uid, model.NetworkRule{Kind: model.NetworkRuleSSID, Value: "Office Wi-Fi", LocationID: 1})
+	if err != nil {
+		t.Fatalf("SaveNetworkRule create: %v", err)
+	}
+	second, err := db.SaveNetworkRule(uid, model.NetworkRule{Kind: model.NetworkRuleCIDR, Value: "203.0.113.0/24"})
+	if err != nil {
+		t.Fatalf("SaveNetworkRule create: %v", err)
+	}
+	if first.ID != 1 || second.ID != 2 {
+		t.Errorf("rule IDs = %d, %d, want 1, 2", first.ID, second.ID)
+	}
+
+	first.Value = "Office Wi-Fi 5G"
+	if _, err := db.SaveNetworkRule(uid, first); err != nil {
+		t.Fatalf("SaveNetworkRule update: %v", err)
+	}
+	if _, err := db.SaveNetworkRule(uid, model.NetworkRule{ID: 9, Kind: model.NetworkRuleSSID, Value: "x"}); !errors.Is(err, ErrNoNetworkRule) {
+		t.Errorf("updating a missing rule = %v, want ErrNoNetworkRule", err)
+	}
+
+	rules, err := db.GetNetworkRules(uid)
+	if err != nil {
+		t.Fatalf("GetNetworkRules: %v", err)
+	}
+	if len(rules) != 2 || rules[0] != first || rules[1] != second {
+		t.Errorf("GetNetworkRules = %+v", rules)
+	}
+
+	if err := db.DeleteNetworkRule(uid, first.ID); err != nil {
+		t.Fatalf("DeleteNetworkRule: %v", err)
+	}
+	if err := db.DeleteNetworkRule(uid, first.ID); !errors.Is(err, ErrNoNetworkRule) {
+		t.Errorf("deleting a missing rule = %v, want ErrNoNetworkRule", err)
+	}
+	if rules, _ := db.GetNetworkRules(uid); len(rules) != 1 || rules[0] != second {
+		t.Errorf("rules after delete = %+v", rules)
+	}
+}
+
+func TestPostgresScheduleRules(t *testing.T) {
+	db := pgTestDB(t)
+	uid := seedUser(t, pgCfg)
+
+	first, err := db.SaveScheduleRule(uid, model.ScheduleRule{State: model.StateWorkFromOffice, RRule: "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO", Start: "2024-01-01"})
+	if err != nil {
+		t.Fatalf("SaveScheduleRule create: %v", err)
+	}
+	second, err := db.SaveScheduleRule(uid, model.ScheduleRule{State: model.StateWorkFromHome, RRule: "FREQ=MONTHLY;BYDAY=-1FR", Start: "2024-01-01", End: "2024-06-30"})
+	if err != nil {
+		t.Fatalf("SaveScheduleRule create: %v", err)
+	}
+	if first.ID != 1 || second.ID != 2 {
+		t.Errorf("rule IDs = %d, %d, want 1, 2", first.ID, second.ID)
+	}
+
+	first.End = "2024-03-31"
+	if _, err := db.SaveScheduleRule(uid, first); err != nil {
+		t.Fatalf("SaveScheduleRule update: %v", err)
+	}
+	if _, err := db.SaveScheduleRule(uid, model.ScheduleRule{ID: 9, RRule: "FREQ=DAILY", Start: "2024-01-01"}); !errors.Is(err, ErrNoScheduleRule) {
+		t.Errorf("updating a missing rule = %v, want ErrNoScheduleRule", err)
+	}
+
+	rules, err := db.GetScheduleRules(uid)
+	if err != nil {
+		t.Fatalf("GetScheduleRules: %v", err)
+	}
+	if len(rules) != 2 || rules[0] != first || rules[1] != second {
+		t.Errorf("GetScheduleRules = %+v", rules)
+	}
+
+	if err := db.DeleteScheduleRule(uid, first.ID); err != nil {
+		t.Fatalf("DeleteScheduleRule: %v", err)
+	}
+	if err := db.DeleteScheduleRule(uid, first.ID); !errors.Is(err, ErrNoScheduleRule) {
+		t.Errorf("deleting a missing rule = %v, want ErrNoScheduleRule", err)
+	}
+
+	// Rules belong to their user.
+	other := seedUser(t, pgCfg)
+	if rules, _ := db.GetScheduleRules(other); len(rules) != 0 {
+		t.Errorf("other user's rules = %+v, want none", rules)
+	}
+}
+
+func TestPostgresScheduleHistory(t *testing.T) {
+	db := pgTestDB(t)
+	uid := seedUser(t, pgCfg)
+
+	office := model.SchedulePreferences{Monday: model.StateWorkFromOffice, Sunday: model.StateOther}
+	home := model.SchedulePreferences{Monday: model.StateWorkFromHome}
+	for _, v := range []model.ScheduleVersion{
+		{EffectiveFrom: "2024-03-01", Schedule: office},
+		{Schedule: home},
+		{EffectiveFrom: "2024-01-01", Schedule: home},
+		{EffectiveFrom: "2024-01-01", Schedule: office},
+	} {
+		if err := db.SaveScheduleVersions(uid, home, v); err != nil {
+			t.Fatalf("SaveScheduleVersions: %v", err)
+		}
+	}
+	// Saving the open-ended version again replaces it too, and several
+	// versions can be saved at once.
+	if err := db.SaveScheduleVersions(uid, office, model.ScheduleVersion{Schedule: office}, model.ScheduleVersion{EffectiveFrom: "2024-03-01", Schedule: office}); err != nil {
+		t.Fatalf("SaveScheduleVersions: %v", err)
+	}
+	if prefs, err := db.GetSchedulePreferences(uid); err != nil || prefs != office {
+		t.Errorf("GetSchedulePreferences = (%+v, %v), want the latest schedule saved alongside", prefs, err)
+	}
+
+	history, err := db.GetScheduleHistory(uid)
+	if err != nil {
+		t.Fatalf("GetScheduleHistory: %v", err)
+	}
+	want := model.ScheduleHistory{
+		{Schedule: office},
+		{EffectiveFrom: "2024-01-01", Schedule: office},
+		{EffectiveFrom: "2024-03-01", Schedule: office},
+	}
+	if !slices.Equal(history, want) {
+		t.Errorf("GetScheduleHistory = %+v, want %+v", history, want)
+	}
+
+	other := seedUser(t, pgCfg)
+	if history, _ := db.GetScheduleHistory(other); len(history) != 0 {
+		t.Errorf("other user's history = %+v, want none", history)
+	}
+}
+
+func TestPostgresMaterialise(t *testing.T) {
+	db := pgTestDB(t)
+	uid := seedUser(t, pgCfg)
+
+	db.SaveDay(uid, 4, 3, 2024, model.DayState{State: model.StateWorkFromOffice, Source: model.SourceSchedule, Unconfirmed: true})
+	db.SaveMonth(uid, 3, 2024, model.MonthState{Days: map[int]model.DayState{
+		5: {State: model.StateWorkFromHome, Source: model.SourceSchedule, Unconfirmed: true},
+		6: {State: model.StateWorkFromHome},
+	}})
+
+	if day, _ := db.GetDay(uid, 4, 3, 2024); !day.Unconfirmed {
+		t.Errorf("GetDay = %+v, want unconfirmed", day)
+	}
+	month, _ := db.GetMonth(uid, 3, 2024)
+	if !month.Days[5].Unconfirmed || month.Days[6].Unconfirmed {
+		t.Errorf("GetMonth = %+v, want only day 5 unconfirmed", month.Days)
+	}
+	if year, _ := db.GetYear(uid, 2024, 1); !year.Months[3].Days[4].Unconfirmed {
+		t.Errorf("GetYear day 4 = %+v, want unconfirmed", year.Months[3].Days[4])
+	}
+
+	other := seedUser(t, pgCfg)
+	if err := db.SaveMaterialisePreferences(uid, model.MaterialisePreferences{Mode: model.MaterialiseEntry}); err != nil {
+		t.Fatalf("SaveMaterialisePreferences: %v", err)
+	}
+	if prefs, err := db.GetMaterialisePreferences(uid); err != nil || prefs.Mode != model.MaterialiseEntry {
+		t.Errorf("GetMaterialisePreferences = (%+v, %v), want entry", prefs, err)
+	}
+	if prefs, _ := db.GetMaterialisePreferences(other); prefs.Mode != model.MaterialiseOff {
+		t.Errorf("other user's mode = %q, want off", prefs.Mode)
+	}
+	users, err := db.ListMaterialiseUsers()
+	if err != nil {
+		t.Fatalf("ListMaterialiseUsers: %v", err)
+	}
+	if !slices.Contains(users, uid) || slices.Contains(users, other) {
+		t.Errorf("ListMaterialiseUsers = %v, want %d and not %d", users, uid, other)
+	}
+}
+
+func TestPostgresNotifications(t *testing.T) {
+	db := pgTestDB(t)
+	uid := seedUser(t, pgCfg)
+	other := seedUser(t, pgCfg)
+
+	prefs := model.NotificationPreferences{Email: "alex@example.com", DailyReminder: true, MonthlySummary: true}
+	if err := db.SaveNotificationPreferences(uid, prefs); err != nil {
+		t.Fatalf("SaveNotificationPreferences: %v", err)
+	}
+	if got, err := db.GetNotificationPreferences(uid); err != nil || got != prefs {
+		t.Errorf("GetNotificationPreferences = (%+v, %v), want %+v", got, err, prefs)
+	}
+	if got, _ := db.GetNotificationPreferences(other); got.Enabled() {
+		t.Errorf("other user's preferences = %+v, want none", got)
+	}
+	users, err := db.ListNotificationUsers()
+	if err != nil {
+		t.Fatalf("ListNotificationUsers: %v", err)
+	}
+	if !slices.Contains(users, uid) || slices.Contains(users, other) {
+		t.Errorf("ListNotificationUsers = %v, want %d and not %d", users, uid, other)
+	}
+
+	now := time.Now()
+	if ok, err := db.ClaimNotification(uid, "daily", "2024-05-07", now); err != nil || !ok {
+		t.Fatalf("ClaimNotification = (%v, %v), want claimed", ok, err)
+	}
+	if ok, _ := db.ClaimNotification(uid, "daily", "2024-05-07", now); ok {
+		t.Error("claimed the same notification twice")
+	}
+	if ok, _ := db.ClaimNotification(other, "daily", "2024-05-07", now); !ok {
+		t.Error("another user's notification was already claimed")
+	}
+	if err := db.ReleaseNotification(uid, "daily", "2024-05-07"); err != nil {
+		t.Fatalf("ReleaseNotification: %v", err)
+	}
+	if ok, _ := db.ClaimNotification(uid, "daily", "2024-05-07", now.Add(-48*time.Hour)); !ok {
+		t.Error("couldn't claim a released notification")
+	}
+	if _, err := db.PruneNotifications(now.Add(-time.Hour)); err != nil {
+		t.Fatalf("PruneNotifications: %v", err)
+	}
+	if ok, _ := db.ClaimNotification(uid, "daily", "2024-05-07", now); !ok {
+		t.Error("an old notification wasn't pruned")
+	}
+	if ok, _ := db.ClaimNotification(other, "daily", "2024-05-07", now); ok {
+		t.Error("pruned a recent notification")
+	}
+}
+
+func TestPostgresPushSubscriptions(t *testing.T) {
+	db := pgTestDB(t)
+	uid := seedUser(t, pgCfg)
+	other := seedUser(t, pgCfg)
+	now := time.Date(2024, time.May, 7, 9, 0, 0, 0, time.UTC)
+
+	sub := model.PushSubscription{
+		Endpoint:  "https://push.example/a",
+		Keys:      model.PushKeys{P256dh: "BKey", Auth: "secret"},
+		ExpiresAt: now.AddDate(0, 1, 0),
+	}
+	saved, err := db.SavePushSubscription(uid, sub, now)
+	if err != nil || saved.ID != 1 {
+		t.Fatalf("SavePushSubscription = (%+v, %v), want ID 1", saved, err)
+	}
+	subs, err := db.GetPushSubscriptions(uid)
+	if err != nil || len(subs) != 1 || subs[0].Keys != sub.Keys || !subs[0].ExpiresAt.Equal(sub.ExpiresAt) {
+		t.Fatalf("GetPushSubscriptions = (%+v, %v)", subs, err)
+	}
+
+	// The browser signing in as another user moves its subscription.
+	if _, err := db.SavePushSubscription(other, sub, now); err != nil {
+		t.Fatalf("SavePushSubscription for another user: %v", err)
+	}
+	if subs, _ := db.GetPushSubscriptions(uid); len(subs) != 0 {
+		t.Errorf("first user's subscriptions = %+v, want none", subs)
+	}
+	if err := db.DeletePushSubscription(uid, 1); !errors.Is(err, ErrNoPushSub) {
+		t.Errorf("DeletePushSubscription of a moved subscription = %v, want ErrNoPushSub", err)
+	}
+	if err := db.DeletePushSubscription(other, 1); err != nil {
+		t.Errorf("DeletePushSubscription: %v", err)
+	}
+
+	prefs := model.NotificationPreferences{PushReminder: true}
+	if err := db.SaveNotificationPreferences(uid, prefs); err != nil {
+		t.Fatalf("SaveNotificationPreferences: %v", err)
+	}
+	if got, _ := db.GetNotificationPreferences(uid); got != prefs {
+		t.Errorf("GetNotificationPreferences = %+v, want %+v", got, prefs)
+	}
+	if users, _ := db.ListNotificationUsers(); !slices.Contains(users, uid) {
+		t.Errorf("ListNotificationUsers = %v, want %d with only push on", users, uid)
+	}
+}
+
+func TestPostgresPushTokens(t *testing.T) {
+	db := pgTestDB(t)
+	uid := seedUser(t, pgCfg)
+	now := time.Date(2024, time.May, 7, 9, 0, 0, 0, time.UTC)
+
+	secret := fmt.Sprintf("officetracker:phone-%d", uid)
+	if err := db.SaveSecret(uid, secret, "Office Tracker mobile app"); err != nil {
+		t.Fatalf("SaveSecret: %v", err)
+	}
+	tokenID, err := db.GetTokenIDBySecret(secret)
+	if err != nil {
+		t.Fatalf("GetTokenIDBySecret: %v", err)
+	}
+
+	token := model.PushToken{Token: fmt.Sprintf("ExponentPushToken[%d]", uid), Device: "Pixel 8", Reminder: true}
+	saved, err := db.SavePushToken(uid, tokenID, token, now)
+	if err != nil || saved.ID != 1 {
+		t.Fatalf("SavePushToken = (%+v, %v), want ID 1", saved, err)
+	}
+	if tokens, err := db.GetPushTokens(uid); err != nil || len(tokens) != 1 || tokens[0] != saved {
+		t.Fatalf("GetPushTokens = (%+v, %v), want [%+v]", tokens, err, saved)
+	}
+	if users, _ := db.ListNotificationUsers(); !slices.Contains(users, uid) {
+		t.Errorf("ListNotificationUsers = %v, want %d with a reminder device", users, uid)
+	}
+
+	// Signing out revokes the app's API token, and its device with it.
+	if err := db.RevokeSecretByValue(secret); err != nil {
+		t.Fatalf("RevokeSecretByValue: %v", err)
+	}
+	if tokens, _ := db.GetPushTokens(uid); len(tokens) != 0 {
+		t.Errorf("devices after sign out = %+v, want none", tokens)
+	}
+	if err := db.DeletePushToken(uid, saved.ID); !errors.Is(err, ErrNoPushToken) {
+		t.Errorf("DeletePushToken = %v, want ErrNoPushToken", err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"log/slog"
 	"os"
 	"path"
+	"slices"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -59,16 +60,18 @@ func NewSQLiteClient(cfg config.SQLite) (Databaser, error) {
 }
 
 func (s *sqliteClient) SaveDay(_ int, day int, month int, year int, state model.DayState) error {
-	q := `INSERT OR REPLACE INTO entries (Day, Month, Year, State) VALUES (?, ?, ?, ?);`
-	_, err := s.db.Exec(q, day, month, year, state.State)
+	if err := checkEntry(day, month, year, state.State); err != nil {
+		return err
+	}
+	q := `INSERT OR REPLACE INTO entries (Day, Month, Year, State, Source, UpdatedAt, Note, LocationID, Unconfirmed) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	_, err := s.db.Exec(q, day, month, year, state.State, nullSource(state.Source), nullTime(state.UpdatedAt), state.Note, state.LocationID, state.Unconfirmed)
 	return err
 }
 
 func (s *sqliteClient) GetDay(_ int, day int, month int, year int) (model.DayState, error) {
-	q := `SELECT State FROM entries WHERE Day = ? AND Month = ? AND Year = ?;`
+	q := `SELECT State, Source, UpdatedAt, Note, LocationID, Unconfirmed FROM entries WHERE Day = ? AND Month = ? AND Year = ?;`
 	row := s.db.QueryRow(q, day, month, year)
-	var state model.DayState
-	err := row.Scan(&state.State)
+	state, err := scanDayState(row.Scan)
 	if errors.Is(err, sql.ErrNoRows) {
 		return model.DayState{}, nil
 	}
@@ -76,9 +79,14 @@ func (s *sqliteClient) GetDay(_ int, day int, month int, year int) (model.DaySta
 }
 
 func (s *sqliteClient) SaveMonth(_ int, month int, year int, state model.MonthState) error {
-	q := `INSERT OR REPLACE INTO entries (Day, Month, Year, State) VALUES (?, ?, ?, ?);`
+	q := `INSERT OR REPLACE INTO entries (Day, Month, Year, State, Source, UpdatedAt, Note, LocationID, Unconfirmed) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);`
 	for day, dayState := range state.Days {
-		_, err := s.db.Exec(q, day, month, year, dayState.State)
+		if err := checkEntry(day, month, year, dayState.State); err != nil {
+			return err
+		}
+	}
+	for day, dayState := range state.Days {
+		_, err := s.db.Exec(q, day, month, year, dayState.State, nullSource(dayState.Source), nullTime(dayState.UpdatedAt), dayState.Note, dayState.LocationID, dayState.Unconfirmed)
 		if err != nil {
 			return err
 		}
@@ -87,7 +95,7 @@ func (s *sqliteClient) SaveMonth(_ int, month int, year int, state model.MonthSt
 }
 
 func (s *sqliteClient) GetMonth(_ int, month int, year int) (model.MonthState, error) {
-	q := `SELECT Day, State FROM entries WHERE Month = ? AND Year = ?;`
+	q := `SELECT Day, State, Source, UpdatedAt, Note, LocationID, Unconfirmed FROM entries WHERE Month = ? AND Year = ?;`
 	rows, err := s.db.Query(q, month, year)
 	if err != nil {
 		return model.MonthState{}, err
@@ -98,8 +106,9 @@ func (s *sqliteClient) GetMonth(_ int, month int, year int) (model.MonthState, e
 	}
 	for rows.Next() {
 		var day int
-		var state model.DayState
-		err = rows.Scan(&day, &state.State)
+		state, err := scanDayState(func(dest ...any) error {
+			return rows.Scan(append([]any{&day}, dest...)...)
+		})
 		if err != nil {
 			return model.MonthState{}, err
 		}
@@ -111,7 +120,7 @@ func (s *sqliteClient) GetMonth(_ int, month int, year int) (model.MonthState, e
 func (s *sqliteClient) GetYear(_ int, year int, startMonth int) (model.YearState, error) {
 	startMonth = util.NormaliseStartMonth(startMonth)
 	firstYear, secondYear := util.TrackingYearCalendarYears(year, startMonth)
-	q := `SELECT Day, Month, State FROM entries WHERE ((Year = ? AND Month >= ?) OR (Year = ? AND Month < ?));`
+	q := `SELECT Day, Month, State, Source, UpdatedAt, Note, LocationID, Unconfirmed FROM entries WHERE ((Year = ? AND Month >= ?) OR (Year = ? AND Month < ?));`
 	rows, err := s.db.Query(q, firstYear, startMonth, secondYear, startMonth)
 	if err != nil {
 		return model.YearState{}, err
@@ -123,8 +132,9 @@ func (s *sqliteClient) GetYear(_ int, year int, startMonth int) (model.YearState
 	for rows.Next() {
 		var month int
 		var day int
-		var state model.DayState
-		err = rows.Scan(&day, &month, &state.State)
+		state, err := scanDayState(func(dest ...any) error {
+			return rows.Scan(append([]any{&day, &month}, dest...)...)
+		})
 		if err != nil {
 			return model.YearState{}, err
 		}
@@ -177,6 +187,53 @@ func (s *sqliteClient) GetNotes(_ int, year int, startMonth int) (map[int]model.
 	return notes, nil
 }
 
+func (s *sqliteClient) SaveEntries(_ int, entries []Entry, changes []DayChange) error {
+	for _, e := range entries {
+		if err := checkEntry(e.Day, e.Month, e.Year, e.State.State); err != nil {
+			return err
+		}
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	q := `INSERT OR REPLACE INTO entries (Day, Month, Year, State, Source, UpdatedAt, Note, LocationID, Unconfirmed) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	for _, e := range entries {
+		state := e.State
+		if _, err := tx.Exec(q, e.Day, e.Month, e.Year, state.State, nullSource(state.Source), nullTime(state.UpdatedAt), state.Note, state.LocationID, state.Unconfirmed); err != nil {
+			return err
+		}
+	}
+	q = `INSERT INTO entry_history (Day, Month, Year, OldState, NewState, ChangedAt, AuthMethod, TokenID, Via) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	for _, c := range changes {
+		if _, err := tx.Exec(q, c.Day, c.Month, c.Year, c.OldState, c.NewState, c.ChangedAt.UTC(), c.AuthMethod, c.TokenID, c.Via); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteClient) GetDayHistory(_ int, day int, month int, year int) ([]DayChange, error) {
+	q := `SELECT OldState, NewState, ChangedAt, AuthMethod, TokenID, Via FROM entry_history WHERE Day = ? AND Month = ? AND Year = ? ORDER BY ChangedAt, rowid;`
+	rows, err := s.db.Query(q, day, month, year)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var history []DayChange
+	for rows.Next() {
+		c := DayChange{Day: day, Month: month, Year: year}
+		err = rows.Scan(&c.OldState, &c.NewState, &c.ChangedAt, &c.AuthMethod, &c.TokenID, &c.Via)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, c)
+	}
+	return history, rows.Err()
+}
+
 func (s *sqliteClient) GetUserLinkedAccounts(userID int) ([]model.LinkedAccount, error) {
 	// Standalone mode doesn't use linked accounts
 	return []model.LinkedAccount{}, nil
@@ -212,6 +269,11 @@ func (s *sqliteClient) GetUserBySecret(_ string) (int, error) {
 	return 1, nil
 }
 
+func (s *sqliteClient) GetTokenIDBySecret(_ string) (int, error) {
+	// Standalone mode doesn't use secrets
+	return 0, nil
+}
+
 func (s *sqliteClient) GetUserByAuth0Sub(_ string) (int, error) {
 	// Auth0 not supported in standalone mode
 	return 0, fmt.Errorf("Auth0 authentication not supported in standalone mode")
@@ -368,25 +430,29 @@ func (s *sqliteClient) GetSchedulePreferences(_ int) (model.SchedulePreferences,
 }
 
 func (s *sqliteClient) SaveSchedulePreferences(_ int, prefs model.SchedulePreferences) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := saveSchedulePreferences(tx, prefs); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// saveSchedulePreferences stores the weekly schedule in tx, adding the
+// schedule columns first if they don't exist yet.
+func saveSchedulePreferences(tx *sql.Tx, prefs model.SchedulePreferences) error {
 	// First make sure schedule columns exist
-	q := `ALTER TABLE user_preferences ADD COLUMN schedule_monday_state INTEGER DEFAULT 0;`
-	s.db.Exec(q) // Ignore error in case column already exists
-	q = `ALTER TABLE user_preferences ADD COLUMN schedule_tuesday_state INTEGER DEFAULT 0;`
-	s.db.Exec(q)
-	q = `ALTER TABLE user_preferences ADD COLUMN schedule_wednesday_state INTEGER DEFAULT 0;`
-	s.db.Exec(q)
-	q = `ALTER TABLE user_preferences ADD COLUMN schedule_thursday_state INTEGER DEFAULT 0;`
-	s.db.Exec(q)
-	q = `ALTER TABLE user_preferences ADD COLUMN schedule_friday_state INTEGER DEFAULT 0;`
-	s.db.Exec(q)
-	q = `ALTER TABLE user_preferences ADD COLUMN schedule_saturday_state INTEGER DEFAULT 0;`
-	s.db.Exec(q)
-	q = `ALTER TABLE user_preferences ADD COLUMN schedule_sunday_state INTEGER DEFAULT 0;`
-	s.db.Exec(q)
+	for _, day := range []string{"monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday"} {
+		// Ignore error in case column already exists
+		tx.Exec(`ALTER TABLE user_preferences ADD COLUMN schedule_` + day + `_state INTEGER DEFAULT 0;`)
+	}
 
 	// Check if any preferences exist
-	q = `SELECT COUNT(*) FROM user_preferences;`
-	row := s.db.QueryRow(q)
+	q := `SELECT COUNT(*) FROM user_preferences;`
+	row := tx.QueryRow(q)
 	var count int
 	err := row.Scan(&count)
 	if err != nil {
@@ -406,12 +472,12 @@ func (s *sqliteClient) SaveSchedulePreferences(_ int, prefs model.SchedulePrefer
 		q = `INSERT INTO user_preferences (schedule_monday_state, schedule_tuesday_state, schedule_wednesday_state, 
 		     schedule_thursday_state, schedule_friday_state, schedule_saturday_state, schedule_sunday_state) 
              VALUES (?, ?, ?, ?, ?, ?, ?);`
-		_, err = s.db.Exec(q, monday, tuesday, wednesday, thursday, friday, saturday, sunday)
+		_, err = tx.Exec(q, monday, tuesday, wednesday, thursday, friday, saturday, sunday)
 	} else {
 		// Update existing preferences
 		q = `UPDATE user_preferences SET schedule_monday_state = ?, schedule_tuesday_state = ?, schedule_wednesday_state = ?,
 		     schedule_thursday_state = ?, schedule_friday_state = ?, schedule_saturday_state = ?, schedule_sunday_state = ?;`
-		_, err = s.db.Exec(q, monday, tuesday, wednesday, thursday, friday, saturday, sunday)
+		_, err = tx.Exec(q, monday, tuesday, wednesday, thursday, friday, saturday, sunday)
 	}
 
 	return err
@@ -427,17 +493,20 @@ func (s *sqliteClient) GetCalendarPreferences(_ int) (model.CalendarPreferences,
 		return prefs, nil
 	}
 
-	// Make sure the column exists (ignore error if it already does).
+	// Make sure the columns exist (ignore errors if they already do).
 	s.db.Exec(`ALTER TABLE user_preferences ADD COLUMN tracking_year_start_month INTEGER DEFAULT 10;`)
+	s.db.Exec(`ALTER TABLE user_preferences ADD COLUMN timezone TEXT DEFAULT '';`)
 
-	q = `SELECT COALESCE(tracking_year_start_month, 10) FROM user_preferences LIMIT 1;`
+	q = `SELECT COALESCE(tracking_year_start_month, 10), COALESCE(timezone, '') FROM user_preferences LIMIT 1;`
 	var startMonth int
-	if err := s.db.QueryRow(q).Scan(&startMonth); err != nil {
+	var timezone string
+	if err := s.db.QueryRow(q).Scan(&startMonth, &timezone); err != nil {
 		// No row yet (or other read issue) - fall back to defaults.
 		return prefs, nil
 	}
 
 	prefs.TrackingYearStartMonth = util.NormaliseStartMonth(startMonth)
+	prefs.Timezone = timezone
 	return prefs, nil
 }
 
@@ -455,6 +524,7 @@ func (s *sqliteClient) SaveCalendarPreferences(_ int, prefs model.CalendarPrefer
 		return err
 	}
 	s.db.Exec(`ALTER TABLE user_preferences ADD COLUMN tracking_year_start_month INTEGER DEFAULT 10;`)
+	s.db.Exec(`ALTER TABLE user_preferences ADD COLUMN timezone TEXT DEFAULT '';`)
 
 	q = `SELECT COUNT(*) FROM user_preferences;`
 	var count int
@@ -463,10 +533,10 @@ func (s *sqliteClient) SaveCalendarPreferences(_ int, prefs model.CalendarPrefer
 	}
 
 	if count == 0 {
-		_, err := s.db.Exec(`INSERT INTO user_preferences (tracking_year_start_month) VALUES (?);`, startMonth)
+		_, err := s.db.Exec(`INSERT INTO user_preferences (tracking_year_start_month, timezone) VALUES (?, ?);`, startMonth, prefs.Timezone)
 		return err
 	}
-	_, err := s.db.Exec(`UPDATE user_preferences SET tracking_year_start_month = ?;`, startMonth)
+	_, err := s.db.Exec(`UPDATE user_preferences SET tracking_year_start_month = ?, timezone = ?;`, startMonth, prefs.Timezone)
 	return err
 }
 
@@ -477,25 +547,34 @@ func (s *sqliteClient) GetTargetPreferences(_ int) (model.TargetPreferences, err
 	q := `SELECT name FROM sqlite_master WHERE type='table' AND name='user_preferences';`
 	var tableName string
 	if err := s.db.QueryRow(q).Scan(&tableName); errors.Is(err, sql.ErrNoRows) {
-		return prefs, nil
+		return util.NormaliseTargetPreferences(prefs), nil
 	}
 
-	// Make sure the column exists (ignore error if it already does).
-	s.db.Exec(`ALTER TABLE user_preferences ADD COLUMN target_percent INTEGER DEFAULT 0;`)
+	// Make sure the columns exist (ignore errors if they already do).
+	s.addTargetColumns()
 
-	q = `SELECT COALESCE(target_percent, 0) FROM user_preferences LIMIT 1;`
-	var target int
-	if err := s.db.QueryRow(q).Scan(&target); err != nil {
+	q = `SELECT COALESCE(target_percent, 0), COALESCE(target_policy, ''), COALESCE(target_days, 0),
+		COALESCE(target_window, ''), COALESCE(target_window_weeks, 0) FROM user_preferences LIMIT 1;`
+	err := s.db.QueryRow(q).Scan(&prefs.TargetPercent, &prefs.Policy, &prefs.TargetDays, &prefs.Window, &prefs.WindowWeeks)
+	if err != nil {
 		// No row yet (or other read issue) - fall back to defaults.
-		return prefs, nil
+		return util.NormaliseTargetPreferences(model.TargetPreferences{}), nil
 	}
+	return util.NormaliseTargetPreferences(prefs), nil
+}
 
-	prefs.TargetPercent = util.ClampTargetPercent(target)
-	return prefs, nil
+// addTargetColumns adds the attendance target columns to user_preferences,
+// ignoring the errors from ones that already exist.
+func (s *sqliteClient) addTargetColumns() {
+	s.db.Exec(`ALTER TABLE user_preferences ADD COLUMN target_percent INTEGER DEFAULT 0;`)
+	s.db.Exec(`ALTER TABLE user_preferences ADD COLUMN target_policy TEXT DEFAULT 'percent';`)
+	s.db.Exec(`ALTER TABLE user_preferences ADD COLUMN target_days REAL DEFAULT 0;`)
+	s.db.Exec(`ALTER TABLE user_preferences ADD COLUMN target_window TEXT DEFAULT 'month';`)
+	s.db.Exec(`ALTER TABLE user_preferences ADD COLUMN target_window_weeks INTEGER DEFAULT 0;`)
 }
 
 func (s *sqliteClient) SaveTargetPreferences(_ int, prefs model.TargetPreferences) error {
-	target := util.ClampTargetPercent(prefs.TargetPercent)
+	prefs = util.NormaliseTargetPreferences(prefs)
 
 	// Make sure the table and column exist.
 	q := `CREATE TABLE IF NOT EXISTS user_preferences (
@@ -507,7 +586,7 @@ func (s *sqliteClient) SaveTargetPreferences(_ int, prefs model.TargetPreference
 	if _, err := s.db.Exec(q); err != nil {
 		return err
 	}
-	s.db.Exec(`ALTER TABLE user_preferences ADD COLUMN target_percent INTEGER DEFAULT 0;`)
+	s.addTargetColumns()
 
 	q = `SELECT COUNT(*) FROM user_preferences;`
 	var count int
@@ -516,13 +595,670 @@ func (s *sqliteClient) SaveTargetPreferences(_ int, prefs model.TargetPreference
 	}
 
 	if count == 0 {
-		_, err := s.db.Exec(`INSERT INTO user_preferences (target_percent) VALUES (?);`, target)
+		q = `INSERT INTO user_preferences (target_percent, target_policy, target_days, target_window, target_window_weeks) VALUES (?, ?, ?, ?, ?);`
+	} else {
+		q = `UPDATE user_preferences SET target_percent = ?, target_policy = ?, target_days = ?, target_window = ?, target_window_weeks = ?;`
+	}
+	_, err := s.db.Exec(q, prefs.TargetPercent, prefs.Policy, prefs.TargetDays, prefs.Window, prefs.WindowWeeks)
+	return err
+}
+
+func (s *sqliteClient) GetMaterialisePreferences(_ int) (model.MaterialisePreferences, error) {
+	var prefs model.MaterialisePreferences
+	q := `SELECT COALESCE(materialise_mode, '') FROM user_preferences LIMIT 1;`
+	err := s.db.QueryRow(q).Scan(&prefs.Mode)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.MaterialisePreferences{}, nil
+	}
+	return prefs, err
+}
+
+func (s *sqliteClient) SaveMaterialisePreferences(_ int, prefs model.MaterialisePreferences) error {
+	var count int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM user_preferences;`).Scan(&count); err != nil {
+		return err
+	}
+	q := `UPDATE user_preferences SET materialise_mode = ?;`
+	if count == 0 {
+		q = `INSERT INTO user_preferences (materialise_mode) VALUES (?);`
+	}
+	_, err := s.db.Exec(q, prefs.Mode)
+	return err
+}
+
+// ListMaterialiseUsers returns the standalone user when they have opted in.
+func (s *sqliteClient) ListMaterialiseUsers() ([]int, error) {
+	prefs, err := s.GetMaterialisePreferences(1)
+	if err != nil || prefs.Mode == model.MaterialiseOff {
+		return nil, err
+	}
+	return []int{1}, nil
+}
+
+func (s *sqliteClient) GetNotificationPreferences(_ int) (model.NotificationPreferences, error) {
+	var prefs model.NotificationPreferences
+	q := `SELECT COALESCE(notification_email, ''), COALESCE(daily_reminder, 0), COALESCE(weekly_digest, 0),
+		COALESCE(monthly_summary, 0), COALESCE(push_reminder, 0), COALESCE(push_target_warning, 0) FROM user_preferences LIMIT 1;`
+	err := s.db.QueryRow(q).Scan(&prefs.Email, &prefs.DailyReminder, &prefs.WeeklyDigest, &prefs.MonthlySummary,
+		&prefs.PushReminder, &prefs.PushTargetWarning)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.NotificationPreferences{}, nil
+	}
+	return prefs, err
+}
+
+func (s *sqliteClient) SaveNotificationPreferences(_ int, prefs model.NotificationPreferences) error {
+	var count int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM user_preferences;`).Scan(&count); err != nil {
+		return err
+	}
+	q := `UPDATE user_preferences SET notification_email = ?, daily_reminder = ?, weekly_digest = ?, monthly_summary = ?,
+		push_reminder = ?, push_target_warning = ?;`
+	if count == 0 {
+		q = `INSERT INTO user_preferences (notification_email, daily_reminder, weekly_digest, monthly_summary, push_reminder, push_target_warning)
+			VALUES (?, ?, ?, ?, ?, ?);`
+	}
+	_, err := s.db.Exec(q, prefs.Email, prefs.DailyReminder, prefs.WeeklyDigest, prefs.MonthlySummary, prefs.PushReminder, prefs.PushTargetWarning)
+	return err
+}
+
+// ListNotificationUsers returns the standalone user when they have switched
+// on any email or push notification, or registered a device for the reminder.
+func (s *sqliteClient) ListNotificationUsers() ([]int, error) {
+	prefs, err := s.GetNotificationPreferences(1)
+	if err != nil {
+		return nil, err
+	}
+	if !(prefs.Email != "" && prefs.Enabled()) && !prefs.PushEnabled() {
+		var reminders int
+		if err := s.db.QueryRow(`SELECT COUNT(*) FROM push_tokens WHERE Reminder;`).Scan(&reminders); err != nil || reminders == 0 {
+			return nil, err
+		}
+	}
+	return []int{1}, nil
+}
+
+func (s *sqliteClient) GetCustomStates(_ int) (model.CustomStates, error) {
+	q := `SELECT StateID, Name, Color, Attendance, Archived FROM custom_states ORDER BY StateID;`
+	rows, err := s.db.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var states model.CustomStates
+	for rows.Next() {
+		var state model.CustomState
+		if err := rows.Scan(&state.ID, &state.Name, &state.Color, &state.Attendance, &state.Archived); err != nil {
+			return nil, err
+		}
+		states = append(states, state)
+	}
+	return states, rows.Err()
+}
+
+func (s *sqliteClient) SaveCustomState(_ int, state model.CustomState) (model.CustomState, error) {
+	if state.ID == 0 {
+		q := `INSERT INTO custom_states (StateID, Name, Color, Attendance, Archived)
+			SELECT MAX(COALESCE(MAX(StateID) + 1, ?), ?), ?, ?, ?, ? FROM custom_states
+			RETURNING StateID;`
+		err := s.db.QueryRow(q, model.CustomStateBase, model.CustomStateBase, state.Name, state.Color, state.Attendance, state.Archived).Scan(&state.ID)
+		return state, err
+	}
+	q := `UPDATE custom_states SET Name = ?, Color = ?, Attendance = ?, Archived = ? WHERE StateID = ?;`
+	res, err := s.db.Exec(q, state.Name, state.Color, state.Attendance, state.Archived, state.ID)
+	if err != nil {
+		return model.CustomState{}, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return model.CustomState{}, err
+	}
+	if n == 0 {
+		return model.CustomState{}, ErrNoCustomState
+	}
+	return state, nil
+}
+
+func (s *sqliteClient) GetLocations(_ int) (model.Locations, error) {
+	q := `SELECT LocationID, Name, Latitude, Longitude, Radius, Archived FROM locations ORDER BY LocationID;`
+	rows, err := s.db.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var locations model.Locations
+	for rows.Next() {
+		location, err := scanLocation(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		locations = append(locations, location)
+	}
+	return locations, rows.Err()
+}
+
+func (s *sqliteClient) SaveLocation(_ int, location model.Location) (model.Location, error) {
+	lat, lng := nullFloat(location.Latitude), nullFloat(location.Longitude)
+	if location.ID == 0 {
+		q := `INSERT INTO locations (Name, Latitude, Longitude, Radius, Archived) VALUES (?, ?, ?, ?, ?) RETURNING LocationID;`
+		err := s.db.QueryRow(q, location.Name, lat, lng, location.Radius, location.Archived).Scan(&location.ID)
+		return location, err
+	}
+	q := `UPDATE locations SET Name = ?, Latitude = ?, Longitude = ?, Radius = ?, Archived = ? WHERE LocationID = ?;`
+	res, err := s.db.Exec(q, location.Name, lat, lng, location.Radius, location.Archived, location.ID)
+	if err != nil {
+		return model.Location{}, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return model.Location{}, err
+	}
+	if n == 0 {
+		return model.Location{}, ErrNoLocation
+	}
+	return location, nil
+}
+
+func (s *sqliteClient) GetScheduleRules(_ int) (model.ScheduleRules, error) {
+	q := `SELECT RuleID, State, RRule, StartDate, EndDate FROM schedule_rules ORDER BY RuleID;`
+	rows, err := s.db.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var rules model.ScheduleRules
+	for rows.Next() {
+		rule, err := scanScheduleRule(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func (s *sqliteClient) SaveScheduleRule(_ int, rule model.ScheduleRule) (model.ScheduleRule, error) {
+	end := nullString(rule.End)
+	if rule.ID == 0 {
+		q := `INSERT INTO schedule_rules (State, RRule, StartDate, EndDate) VALUES (?, ?, ?, ?) RETURNING RuleID;`
+		err := s.db.QueryRow(q, rule.State, rule.RRule, rule.Start, end).Scan(&rule.ID)
+		return rule, err
+	}
+	q := `UPDATE schedule_rules SET State = ?, RRule = ?, StartDate = ?, EndDate = ? WHERE RuleID = ?;`
+	res, err := s.db.Exec(q, rule.State, rule.RRule, rule.Start, end, rule.ID)
+	if err != nil {
+		return model.ScheduleRule{}, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return model.ScheduleRule{}, err
+	}
+	if n == 0 {
+		return model.ScheduleRule{}, ErrNoScheduleRule
+	}
+	return rule, nil
+}
+
+func (s *sqliteClient) DeleteScheduleRule(_ int, ruleID int) error {
+	res, err := s.db.Exec(`DELETE FROM schedule_rules WHERE RuleID = ?;`, ruleID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoScheduleRule
+	}
+	return nil
+}
+
+func (s *sqliteClient) GetNetworkRules(_ int) (model.NetworkRules, error) {
+	rows, err := s.db.Query(`SELECT RuleID, Kind, Value, LocationID FROM network_rules ORDER BY RuleID;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var rules model.NetworkRules
+	for rows.Next() {
+		rule, err := scanNetworkRule(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func (s *sqliteClient) SaveNetworkRule(_ int, rule model.NetworkRule) (model.NetworkRule, error) {
+	if rule.ID == 0 {
+		q := `INSERT INTO network_rules (Kind, Value, LocationID) VALUES (?, ?, ?) RETURNING RuleID;`
+		err := s.db.QueryRow(q, rule.Kind, rule.Value, rule.LocationID).Scan(&rule.ID)
+		return rule, err
+	}
+	q := `UPDATE network_rules SET Kind = ?, Value = ?, LocationID = ? WHERE RuleID = ?;`
+	res, err := s.db.Exec(q, rule.Kind, rule.Value, rule.LocationID, rule.ID)
+	if err != nil {
+		return model.NetworkRule{}, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return model.NetworkRule{}, err
+	}
+	if n == 0 {
+		return model.NetworkRule{}, ErrNoNetworkRule
+	}
+	return rule, nil
+}
+
+func (s *sqliteClient) DeleteNetworkRule(_ int, ruleID int) error {
+	res, err := s.db.Exec(`DELETE FROM network_rules WHERE RuleID = ?;`, ruleID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoNetworkRule
+	}
+	return nil
+}
+
+func (s *sqliteClient) GetScheduleHistory(_ int) (model.ScheduleHistory, error) {
+	q := `SELECT EffectiveFrom, Monday, Tuesday, Wednesday, Thursday, Friday, Saturday, Sunday
+		FROM schedule_history ORDER BY EffectiveFrom IS NOT NULL, EffectiveFrom;`
+	rows, err := s.db.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var history model.ScheduleHistory
+	for rows.Next() {
+		version, err := scanScheduleVersion(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, version)
+	}
+	return history, rows.Err()
+}
+
+func (s *sqliteClient) SaveScheduleVersions(_ int, latest model.SchedulePreferences, versions ...model.ScheduleVersion) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	q := `INSERT INTO schedule_history (EffectiveFrom, Monday, Tuesday, Wednesday, Thursday, Friday, Saturday, Sunday)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?);`
+	for _, version := range versions {
+		from := nullString(version.EffectiveFrom)
+		if _, err := tx.Exec(`DELETE FROM schedule_history WHERE EffectiveFrom IS ?;`, from); err != nil {
+			return err
+		}
+		sched := version.Schedule
+		if _, err := tx.Exec(q, from, int(sched.Monday), int(sched.Tuesday), int(sched.Wednesday),
+			int(sched.Thursday), int(sched.Friday), int(sched.Saturday), int(sched.Sunday)); err != nil {
+			return err
+		}
+	}
+	if err := saveSchedulePreferences(tx, latest); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteClient) GetWebhooks(_ int) (model.Webhooks, error) {
+	rows, err := s.db.Query(`SELECT WebhookID, URL, Events, Secret, Disabled FROM webhooks ORDER BY WebhookID;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var webhooks model.Webhooks
+	for rows.Next() {
+		var webhook model.Webhook
+		var events string
+		if err := rows.Scan(&webhook.ID, &webhook.URL, &events, &webhook.Secret, &webhook.Disabled); err != nil {
+			return nil, err
+		}
+		webhook.Events = splitEvents(events)
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+func (s *sqliteClient) SaveWebhook(_ int, webhook model.Webhook) (model.Webhook, error) {
+	events := joinEvents(webhook.Events)
+	if webhook.ID == 0 {
+		q := `INSERT INTO webhooks (URL, Events, Secret, Disabled) VALUES (?, ?, ?, ?) RETURNING WebhookID;`
+		err := s.db.QueryRow(q, webhook.URL, events, webhook.Secret, webhook.Disabled).Scan(&webhook.ID)
+		return webhook, err
+	}
+	q := `UPDATE webhooks SET URL = ?, Events = ?, Disabled = ? WHERE WebhookID = ? RETURNING Secret;`
+	err := s.db.QueryRow(q, webhook.URL, events, webhook.Disabled, webhook.ID).Scan(&webhook.Secret)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Webhook{}, ErrNoWebhook
+	}
+	if err != nil {
+		return model.Webhook{}, err
+	}
+	return webhook, nil
+}
+
+func (s *sqliteClient) DeleteWebhook(_ int, webhookID int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`DELETE FROM webhooks WHERE WebhookID = ?;`, webhookID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoWebhook
+	}
+	if _, err := tx.Exec(`DELETE FROM webhook_deliveries WHERE WebhookID = ?;`, webhookID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteClient) GetPushSubscriptions(_ int) (model.PushSubscriptions, error) {
+	rows, err := s.db.Query(`SELECT SubscriptionID, Endpoint, P256dh, Auth, Device, ExpiresAt, CreatedAt
+		FROM push_subscriptions ORDER BY SubscriptionID;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var subs model.PushSubscriptions
+	for rows.Next() {
+		sub, err := scanPushSubscription(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+func (s *sqliteClient) SavePushSubscription(_ int, sub model.PushSubscription, now time.Time) (model.PushSubscription, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return model.PushSubscription{}, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM push_subscriptions WHERE Endpoint = ?;`, sub.Endpoint); err != nil {
+		return model.PushSubscription{}, err
+	}
+	sub.CreatedAt = now.UTC()
+	q := `INSERT INTO push_subscriptions (Endpoint, P256dh, Auth, Device, ExpiresAt, CreatedAt) VALUES (?, ?, ?, ?, ?, ?) RETURNING SubscriptionID;`
+	if err := tx.QueryRow(q, sub.Endpoint, sub.Keys.P256dh, sub.Keys.Auth, sub.Device, nullTime(sub.ExpiresAt), sub.CreatedAt).Scan(&sub.ID); err != nil {
+		return model.PushSubscription{}, err
+	}
+	return sub, tx.Commit()
+}
+
+func (s *sqliteClient) DeletePushSubscription(_ int, subscriptionID int) error {
+	res, err := s.db.Exec(`DELETE FROM push_subscriptions WHERE SubscriptionID = ?;`, subscriptionID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoPushSub
+	}
+	return nil
+}
+
+func (s *sqliteClient) GetPushTokens(_ int) (model.PushTokens, error) {
+	rows, err := s.db.Query(`SELECT PushTokenID, Token, Device, Reminder, CheckIn, CreatedAt
+		FROM push_tokens ORDER BY PushTokenID;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var tokens model.PushTokens
+	for rows.Next() {
+		var t model.PushToken
+		if err := rows.Scan(&t.ID, &t.Token, &t.Device, &t.Reminder, &t.CheckIn, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		t.CreatedAt = t.CreatedAt.UTC()
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// SavePushToken ignores authTokenID, as standalone mode has no API tokens.
+func (s *sqliteClient) SavePushToken(_ int, _ int, token model.PushToken, now time.Time) (model.PushToken, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return model.PushToken{}, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM push_tokens WHERE Token = ?;`, token.Token); err != nil {
+		return model.PushToken{}, err
+	}
+	token.CreatedAt = now.UTC()
+	q := `INSERT INTO push_tokens (Token, Device, Reminder, CheckIn, CreatedAt) VALUES (?, ?, ?, ?, ?) RETURNING PushTokenID;`
+	if err := tx.QueryRow(q, token.Token, token.Device, token.Reminder, token.CheckIn, token.CreatedAt).Scan(&token.ID); err != nil {
+		return model.PushToken{}, err
+	}
+	return token, tx.Commit()
+}
+
+func (s *sqliteClient) DeletePushToken(_ int, pushTokenID int) error {
+	res, err := s.db.Exec(`DELETE FROM push_tokens WHERE PushTokenID = ?;`, pushTokenID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoPushToken
+	}
+	return nil
+}
+
+func (s *sqliteClient) QueueWebhookDeliveries(deliveries []WebhookDelivery) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	q := `INSERT INTO webhook_deliveries (UserID, WebhookID, Event, Payload, Status, NextAttemptAt, CreatedAt)
+		VALUES (?, ?, ?, ?, ?, ?, ?);`
+	for _, d := range deliveries {
+		created := d.CreatedAt.UTC()
+		if _, err := tx.Exec(q, d.UserID, d.WebhookID, d.Event, string(d.Payload), model.WebhookDeliveryPending, created, created); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteClient) ClaimWebhookDeliveries(now, until time.Time, limit int) ([]WebhookDelivery, error) {
+	q := `UPDATE webhook_deliveries SET NextAttemptAt = ?
+		WHERE DeliveryID IN (
+			SELECT DeliveryID FROM webhook_deliveries
+			WHERE Status = ? AND NextAttemptAt <= ?
+			ORDER BY NextAttemptAt, DeliveryID LIMIT ?)
+		RETURNING DeliveryID, UserID, WebhookID, Event, Payload, Status, Attempts, NextAttemptAt, ResponseCode, Error, CreatedAt;`
+	rows, err := s.db.Query(q, until.UTC(), model.WebhookDeliveryPending, now.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var deliveries []WebhookDelivery
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// RETURNING gives no order.
+	slices.SortFunc(deliveries, func(a, b WebhookDelivery) int { return a.ID - b.ID })
+	return deliveries, nil
+}
+
+func (s *sqliteClient) SaveWebhookAttempt(d WebhookDelivery) error {
+	q := `UPDATE webhook_deliveries SET Status = ?, Attempts = ?, NextAttemptAt = ?, ResponseCode = ?, Error = ? WHERE DeliveryID = ?;`
+	_, err := s.db.Exec(q, d.Status, d.Attempts, nullTime(d.NextAttemptAt), d.ResponseCode, d.Error, d.ID)
+	return err
+}
+
+func (s *sqliteClient) GetWebhookDeliveries(_ int, webhookID int, limit int) ([]model.WebhookDelivery, error) {
+	q := `SELECT DeliveryID, UserID, WebhookID, Event, Payload, Status, Attempts, NextAttemptAt, ResponseCode, Error, CreatedAt
+		FROM webhook_deliveries WHERE WebhookID = ? ORDER BY DeliveryID DESC LIMIT ?;`
+	rows, err := s.db.Query(q, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var deliveries []model.WebhookDelivery
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d.WebhookDelivery)
+	}
+	return deliveries, rows.Err()
+}
+
+func (s *sqliteClient) PruneWebhookDeliveries(before time.Time) (int, error) {
+	res, err := s.db.Exec(`DELETE FROM webhook_deliveries WHERE Status <> ? AND CreatedAt < ?;`, model.WebhookDeliveryPending, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// Standalone has the one user, so linked chat accounts all belong to it.
+func (s *sqliteClient) GetChatUser(account model.ChatAccount) (int, error) {
+	q := `SELECT 1 FROM chat_accounts WHERE Platform = ? AND TeamID = ? AND ChatUserID = ?;`
+	var userID int
+	err := s.db.QueryRow(q, account.Platform, account.TeamID, account.UserID).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNoChatAccount
+	}
+	return userID, err
+}
+
+func (s *sqliteClient) SaveChatLink(code string, account model.ChatAccount, expires time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM chat_links WHERE ExpiresAt <= ?;`, time.Now().UTC()); err != nil {
+		return err
+	}
+	q := `INSERT INTO chat_links (Code, Platform, TeamID, ChatUserID, Name, ExpiresAt) VALUES (?, ?, ?, ?, ?, ?);`
+	if _, err := tx.Exec(q, code, account.Platform, account.TeamID, account.UserID, account.Name, expires.UTC()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteClient) GetChatLink(code string, now time.Time) (model.ChatAccount, error) {
+	q := `SELECT Platform, TeamID, ChatUserID, Name FROM chat_links WHERE Code = ? AND ExpiresAt > ?;`
+	var account model.ChatAccount
+	err := s.db.QueryRow(q, code, now.UTC()).Scan(&account.Platform, &account.TeamID, &account.UserID, &account.Name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.ChatAccount{}, ErrNoChatLink
+	}
+	return account, err
+}
+
+func (s *sqliteClient) LinkChatAccount(_ int, code string, now time.Time) (model.ChatAccount, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return model.ChatAccount{}, err
+	}
+	defer tx.Rollback()
+
+	q := `DELETE FROM chat_links WHERE Code = ? AND ExpiresAt > ? RETURNING Platform, TeamID, ChatUserID, Name;`
+	var account model.ChatAccount
+	err = tx.QueryRow(q, code, now.UTC()).Scan(&account.Platform, &account.TeamID, &account.UserID, &account.Name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.ChatAccount{}, ErrNoChatLink
+	}
+	if err != nil {
+		return model.ChatAccount{}, err
+	}
+	upsert := `INSERT INTO chat_accounts (Platform, TeamID, ChatUserID, Name) VALUES (?, ?, ?, ?)
+		ON CONFLICT (Platform, TeamID, ChatUserID) DO UPDATE SET Name = excluded.Name;`
+	if _, err := tx.Exec(upsert, account.Platform, account.TeamID, account.UserID, account.Name); err != nil {
+		return model.ChatAccount{}, err
+	}
+	return account, tx.Commit()
+}
+
+func (s *sqliteClient) UnlinkChatAccount(account model.ChatAccount) error {
+	q := `DELETE FROM chat_accounts WHERE Platform = ? AND TeamID = ? AND ChatUserID = ?;`
+	res, err := s.db.Exec(q, account.Platform, account.TeamID, account.UserID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
 		return err
 	}
-	_, err := s.db.Exec(`UPDATE user_preferences SET target_percent = ?;`, target)
+	if n == 0 {
+		return ErrNoChatAccount
+	}
+	return nil
+}
+
+func (s *sqliteClient) ClaimNotification(_ int, kind string, period string, now time.Time) (bool, error) {
+	q := `INSERT OR IGNORE INTO notification_log (Kind, Period, SentAt) VALUES (?, ?, ?);`
+	res, err := s.db.Exec(q, kind, period, now.UTC())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (s *sqliteClient) ReleaseNotification(_ int, kind string, period string) error {
+	_, err := s.db.Exec(`DELETE FROM notification_log WHERE Kind = ? AND Period = ?;`, kind, period)
 	return err
 }
 
+func (s *sqliteClient) PruneNotifications(before time.Time) (int, error) {
+	res, err := s.db.Exec(`DELETE FROM notification_log WHERE SentAt < ?;`, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
 func (s *sqliteClient) IsUserSuspended(_ int) (bool, error) {
 	// Standalone mode doesn't support suspension
 	return false, nil
@@ -563,6 +1299,25 @@ func (s *sqliteClient) CountEntriesByState() (map[model.State]int, error) {
 	return result, rows.Err()
 }
 
+func (s *sqliteClient) CountCustomEntriesByAttendance() (map[model.Attendance]int, error) {
+	q := `SELECT c.Attendance, COUNT(*) FROM entries e JOIN custom_states c ON c.StateID = e.State GROUP BY c.Attendance;`
+	rows, err := s.db.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := make(map[model.Attendance]int)
+	for rows.Next() {
+		var attendance string
+		var count int
+		if err := rows.Scan(&attendance, &count); err != nil {
+			return nil, err
+		}
+		result[model.Attendance(attendance)] = count
+	}
+	return result, rows.Err()
+}
+
 func (s *sqliteClient) initConnection() error {
 	slog.Info(fmt.Sprintf("Connecting to sqlite database: %s", s.cfg.Location))
 	db, err := sql.Open("sqlite3", s.cfg.Location)
@@ -575,6 +1330,11 @@ func (s *sqliteClient) initConnection() error {
     Month INTEGER,
     Year INTEGER,
     State INTEGER,
+    Source TEXT,
+    UpdatedAt TIMESTAMP,
+    Note TEXT NOT NULL DEFAULT '',
+    LocationID INTEGER NOT NULL DEFAULT 0,
+    Unconfirmed INTEGER NOT NULL DEFAULT 0,
     PRIMARY KEY (Day, Month, Year)
 );
 
@@ -590,11 +1350,150 @@ CREATE TABLE IF NOT EXISTS user_preferences (
     weather_enabled INTEGER DEFAULT 0,
     time_based_enabled INTEGER DEFAULT 0,
     location TEXT DEFAULT NULL
+);
+
+CREATE TABLE IF NOT EXISTS entry_history (
+    Day INTEGER,
+    Month INTEGER,
+    Year INTEGER,
+    OldState INTEGER,
+    NewState INTEGER,
+    ChangedAt TIMESTAMP,
+    AuthMethod TEXT,
+    TokenID INTEGER,
+    Via TEXT
+);
+
+CREATE INDEX IF NOT EXISTS entry_history_day ON entry_history (Year, Month, Day);
+
+CREATE TABLE IF NOT EXISTS custom_states (
+    StateID INTEGER PRIMARY KEY,
+    Name TEXT NOT NULL,
+    Color TEXT NOT NULL,
+    Attendance TEXT NOT NULL,
+    Archived INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS locations (
+    LocationID INTEGER PRIMARY KEY,
+    Name TEXT NOT NULL,
+    Latitude REAL,
+    Longitude REAL,
+    Radius INTEGER NOT NULL DEFAULT 0,
+    Archived INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS schedule_rules (
+    RuleID INTEGER PRIMARY KEY,
+    State INTEGER NOT NULL,
+    RRule TEXT NOT NULL,
+    StartDate TEXT NOT NULL,
+    EndDate TEXT
+);
+
+CREATE TABLE IF NOT EXISTS schedule_history (
+    EffectiveFrom TEXT,
+    Monday INTEGER NOT NULL DEFAULT 0,
+    Tuesday INTEGER NOT NULL DEFAULT 0,
+    Wednesday INTEGER NOT NULL DEFAULT 0,
+    Thursday INTEGER NOT NULL DEFAULT 0,
+    Friday INTEGER NOT NULL DEFAULT 0,
+    Saturday INTEGER NOT NULL DEFAULT 0,
+    Sunday INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS webhooks (
+    WebhookID INTEGER PRIMARY KEY,
+    URL TEXT NOT NULL,
+    Events TEXT NOT NULL,
+    Secret TEXT NOT NULL,
+    Disabled INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    DeliveryID INTEGER PRIMARY KEY,
+    UserID INTEGER NOT NULL,
+    WebhookID INTEGER NOT NULL,
+    Event TEXT NOT NULL,
+    Payload TEXT NOT NULL,
+    Status TEXT NOT NULL,
+    Attempts INTEGER NOT NULL DEFAULT 0,
+    NextAttemptAt TIMESTAMP,
+    ResponseCode INTEGER NOT NULL DEFAULT 0,
+    Error TEXT NOT NULL DEFAULT '',
+    CreatedAt TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook ON webhook_deliveries (WebhookID, DeliveryID);
+
+CREATE TABLE IF NOT EXISTS chat_accounts (
+    Platform TEXT NOT NULL,
+    TeamID TEXT NOT NULL,
+    ChatUserID TEXT NOT NULL,
+    Name TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (Platform, TeamID, ChatUserID)
+);
+
+CREATE TABLE IF NOT EXISTS chat_links (
+    Code TEXT PRIMARY KEY,
+    Platform TEXT NOT NULL,
+    TeamID TEXT NOT NULL,
+    ChatUserID TEXT NOT NULL,
+    Name TEXT NOT NULL DEFAULT '',
+    ExpiresAt TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS notification_log (
+    Kind TEXT NOT NULL,
+    Period TEXT NOT NULL,
+    SentAt TIMESTAMP NOT NULL,
+    PRIMARY KEY (Kind, Period)
+);
+
+CREATE TABLE IF NOT EXISTS push_subscriptions (
+    SubscriptionID INTEGER PRIMARY KEY,
+    Endpoint TEXT NOT NULL UNIQUE,
+    P256dh TEXT NOT NULL,
+    Auth TEXT NOT NULL,
+    Device TEXT NOT NULL DEFAULT '',
+    ExpiresAt TIMESTAMP,
+    CreatedAt TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS push_tokens (
+    PushTokenID INTEGER PRIMARY KEY,
+    Token TEXT NOT NULL UNIQUE,
+    Device TEXT NOT NULL DEFAULT '',
+    Reminder INTEGER NOT NULL DEFAULT 0,
+    CheckIn INTEGER NOT NULL DEFAULT 0,
+    CreatedAt TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS network_rules (
+    RuleID INTEGER PRIMARY KEY,
+    Kind TEXT NOT NULL,
+    Value TEXT NOT NULL,
+    LocationID INTEGER NOT NULL DEFAULT 0
 );`
 
 	if _, err = db.Exec(sqlCreate); err != nil {
 		return err
 	}
+	// Databases created before entry provenance, day notes, locations and
+	// unconfirmed entries lack these columns; the errors from re-adding them to
+	// newer databases are expected.
+	db.Exec(`ALTER TABLE entries ADD COLUMN Source TEXT;`)
+	db.Exec(`ALTER TABLE entries ADD COLUMN UpdatedAt TIMESTAMP;`)
+	db.Exec(`ALTER TABLE entries ADD COLUMN Note TEXT NOT NULL DEFAULT '';`)
+	db.Exec(`ALTER TABLE entries ADD COLUMN LocationID INTEGER NOT NULL DEFAULT 0;`)
+	db.Exec(`ALTER TABLE entries ADD COLUMN Unconfirmed INTEGER NOT NULL DEFAULT 0;`)
+	db.Exec(`ALTER TABLE user_preferences ADD COLUMN materialise_mode TEXT DEFAULT '';`)
+	db.Exec(`ALTER TABLE user_preferences ADD COLUMN notification_email TEXT DEFAULT '';`)
+	db.Exec(`ALTER TABLE user_preferences ADD COLUMN daily_reminder INTEGER DEFAULT 0;`)
+	db.Exec(`ALTER TABLE user_preferences ADD COLUMN weekly_digest INTEGER DEFAULT 0;`)
+	db.Exec(`ALTER TABLE user_preferences ADD COLUMN monthly_summary INTEGER DEFAULT 0;`)
+	db.Exec(`ALTER TABLE user_preferences ADD COLUMN push_reminder INTEGER DEFAULT 0;`)
+	db.Exec(`ALTER TABLE user_preferences ADD COLUMN push_target_warning INTEGER DEFAULT 0;`)
 	s.db = db
 
 	return nil
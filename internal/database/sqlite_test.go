@@ -1,8 +1,11 @@
 package database
 
 import (
+	"errors"
 	"path/filepath"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/baely/officetracker/internal/config"
 	"github.com/baely/officetracker/pkg/model"
@@ -122,6 +125,126 @@ func TestSQLiteMonthRoundTrip(t *testing.T) {
 	}
 }
 
+// Source, update time and note round-trip through every read path. Entries saved
+// without them (as before provenance was recorded) read back empty.
+func TestSQLiteEntrySource(t *testing.T) {
+	db := newTestDB(t)
+
+	at := time.Date(2024, 3, 5, 8, 30, 0, 0, time.UTC)
+	db.SaveDay(1, 5, 3, 2024, model.DayState{State: model.StateWorkFromOffice, Source: model.SourceGeofence, UpdatedAt: at, Note: "client site"})
+	db.SaveDay(1, 6, 3, 2024, model.DayState{State: model.StateWorkFromHome})
+
+	day, err := db.GetDay(1, 5, 3, 2024)
+	if err != nil {
+		t.Fatalf("GetDay: %v", err)
+	}
+	if day.Source != model.SourceGeofence || !day.UpdatedAt.Equal(at) {
+		t.Errorf("GetDay = %+v, want geofence at %v", day, at)
+	}
+	if day.Note != "client site" {
+		t.Errorf("GetDay note = %q, want client site", day.Note)
+	}
+
+	month, _ := db.GetMonth(1, 3, 2024)
+	if month.Days[5].Source != model.SourceGeofence || !month.Days[5].UpdatedAt.Equal(at) {
+		t.Errorf("GetMonth day 5 = %+v", month.Days[5])
+	}
+	if month.Days[6].Source != "" || !month.Days[6].UpdatedAt.IsZero() {
+		t.Errorf("GetMonth day 6 = %+v, want no source or timestamp", month.Days[6])
+	}
+
+	year, _ := db.GetYear(1, 2024, 1)
+	if year.Months[3].Days[5].Source != model.SourceGeofence || year.Months[3].Days[5].Note != "client site" {
+		t.Errorf("GetYear day 5 = %+v, want geofence with note", year.Months[3].Days[5])
+	}
+}
+
+func TestSQLiteDayHistory(t *testing.T) {
+	db := newTestDB(t)
+
+	at := time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC)
+	changes := []DayChange{
+		{Day: 5, Month: 3, Year: 2024, OldState: model.StateUntracked, NewState: model.StateWorkFromOffice, ChangedAt: at, AuthMethod: "excluded", Via: "api"},
+		{Day: 5, Month: 3, Year: 2024, OldState: model.StateWorkFromOffice, NewState: model.StateWorkFromHome, ChangedAt: at.Add(time.Hour), AuthMethod: "excluded", Via: "mcp"},
+		{Day: 6, Month: 3, Year: 2024, OldState: model.StateUntracked, NewState: model.StateOther, ChangedAt: at, AuthMethod: "excluded", Via: "api"},
+	}
+	if err := db.SaveEntries(1, nil, changes); err != nil {
+		t.Fatalf("SaveEntries: %v", err)
+	}
+
+	got, err := db.GetDayHistory(1, 5, 3, 2024)
+	if err != nil {
+		t.Fatalf("GetDayHistory: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d changes, want 2", len(got))
+	}
+	if got[0].NewState != model.StateWorkFromOffice || got[1].NewState != model.StateWorkFromHome {
+		t.Errorf("history out of order: %+v", got)
+	}
+	if !got[1].ChangedAt.Equal(at.Add(time.Hour)) || got[1].Via != "mcp" {
+		t.Errorf("second change = %+v", got[1])
+	}
+
+	if none, _ := db.GetDayHistory(1, 7, 3, 2024); len(none) != 0 {
+		t.Errorf("untouched day has %d changes, want 0", len(none))
+	}
+}
+
+// SaveEntries writes days across months with their history.
+func TestSQLiteSaveEntries(t *testing.T) {
+	db := newTestDB(t)
+
+	at := time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{Day: 29, Month: 2, Year: 2024, State: model.DayState{State: model.StateWorkFromOffice, Source: model.SourceManual, Note: "leap"}},
+		{Day: 1, Month: 3, Year: 2024, State: model.DayState{State: model.StateWorkFromHome}},
+	}
+	changes := []DayChange{
+		{Day: 29, Month: 2, Year: 2024, NewState: model.StateWorkFromOffice, ChangedAt: at, AuthMethod: "excluded", Via: "api"},
+	}
+	if err := db.SaveEntries(1, entries, changes); err != nil {
+		t.Fatalf("SaveEntries: %v", err)
+	}
+
+	if got, _ := db.GetDay(1, 29, 2, 2024); got.State != model.StateWorkFromOffice || got.Note != "leap" || got.Source != model.SourceManual {
+		t.Errorf("29 February = %+v", got)
+	}
+	if got, _ := db.GetDay(1, 1, 3, 2024); got.State != model.StateWorkFromHome {
+		t.Errorf("1 March = %+v", got)
+	}
+	if history, _ := db.GetDayHistory(1, 29, 2, 2024); len(history) != 1 {
+		t.Errorf("29 February history = %+v, want one change", history)
+	}
+}
+
+// Writes with impossible dates or unstorable states are refused before they
+// reach the table.
+func TestSQLiteRejectsInvalidEntries(t *testing.T) {
+	db := newTestDB(t)
+
+	office := model.DayState{State: model.StateWorkFromOffice}
+	if err := db.SaveDay(1, 31, 2, 2024, office); !errors.Is(err, ErrInvalidEntry) {
+		t.Errorf("SaveDay(31 February) = %v, want ErrInvalidEntry", err)
+	}
+	if err := db.SaveDay(1, 1, 3, 2024, model.DayState{State: model.StateScheduledWorkFromOffice}); !errors.Is(err, ErrInvalidEntry) {
+		t.Errorf("SaveDay(scheduled state) = %v, want ErrInvalidEntry", err)
+	}
+	if err := db.SaveMonth(1, 13, 2024, model.MonthState{Days: map[int]model.DayState{1: office}}); !errors.Is(err, ErrInvalidEntry) {
+		t.Errorf("SaveMonth(month 13) = %v, want ErrInvalidEntry", err)
+	}
+	entries := []Entry{
+		{Day: 28, Month: 2, Year: 2023, State: office},
+		{Day: 29, Month: 2, Year: 2023, State: office},
+	}
+	if err := db.SaveEntries(1, entries, nil); !errors.Is(err, ErrInvalidEntry) {
+		t.Errorf("SaveEntries(29 February 2023) = %v, want ErrInvalidEntry", err)
+	}
+	if got, _ := db.GetDay(1, 28, 2, 2023); got.State != model.StateUntracked {
+		t.Errorf("28 February 2023 = %+v, want nothing saved", got)
+	}
+}
+
 // GetYear applies the tracking-year window: for an October start, tracking year
 // 2024 spans Oct 2023 through Sep 2024. Entries outside that window must be
 // excluded even though they share a calendar year with included ones.
@@ -316,6 +439,13 @@ func TestSQLiteCalendarPreferences(t *testing.T) {
 	if got.TrackingYearStartMonth != 10 {
 		t.Errorf("out-of-range start month normalised to %d, want 10", got.TrackingYearStartMonth)
 	}
+
+	if err := db.SaveCalendarPreferences(1, model.CalendarPreferences{TrackingYearStartMonth: 7, Timezone: "Europe/London"}); err != nil {
+		t.Fatalf("SaveCalendarPreferences timezone: %v", err)
+	}
+	if got, _ = db.GetCalendarPreferences(1); got.Timezone != "Europe/London" {
+		t.Errorf("timezone = %q, want Europe/London", got.Timezone)
+	}
 }
 
 func TestSQLiteTargetPreferences(t *testing.T) {
@@ -355,6 +485,383 @@ func TestSQLiteTargetPreferences(t *testing.T) {
 	if got.TargetPercent != 0 {
 		t.Errorf("cleared target = %d, want 0", got.TargetPercent)
 	}
+
+	// Day-based policies and windows round-trip.
+	rolling := model.TargetPreferences{
+		Policy: model.TargetPolicyDaysPerWeek, TargetDays: 2.5, Window: model.TargetWindowRollingWeeks, WindowWeeks: 8,
+	}
+	if err := db.SaveTargetPreferences(1, rolling); err != nil {
+		t.Fatalf("SaveTargetPreferences rolling: %v", err)
+	}
+	got, _ = db.GetTargetPreferences(1)
+	if got != rolling {
+		t.Errorf("policy round-trip = %+v, want %+v", got, rolling)
+	}
+}
+
+// Custom states get IDs from CustomStateBase, are updated in place and can be
+// counted by attendance for the stats dashboard.
+func TestSQLiteCustomStates(t *testing.T) {
+	db := newTestDB(t)
+
+	created, err := db.SaveCustomState(1, model.CustomState{Name: "Client site", Color: "#ff9800", Attendance: model.AttendancePresent})
+	if err != nil {
+		t.Fatalf("SaveCustomState create: %v", err)
+	}
+	if created.ID != model.CustomStateBase {
+		t.Errorf("first custom state ID = %d, want %d", created.ID, model.CustomStateBase)
+	}
+	leave, err := db.SaveCustomState(1, model.CustomState{Name: "Leave", Color: "#9e9e9e", Attendance: model.AttendanceExcluded})
+	if err != nil {
+		t.Fatalf("SaveCustomState create: %v", err)
+	}
+	if leave.ID != model.CustomStateBase+1 {
+		t.Errorf("second custom state ID = %d, want %d", leave.ID, model.CustomStateBase+1)
+	}
+
+	leave.Archived = true
+	if _, err := db.SaveCustomState(1, leave); err != nil {
+		t.Fatalf("SaveCustomState update: %v", err)
+	}
+	if _, err := db.SaveCustomState(1, model.CustomState{ID: 150, Name: "Ghost"}); !errors.Is(err, ErrNoCustomState) {
+		t.Errorf("updating a missing state = %v, want ErrNoCustomState", err)
+	}
+
+	states, err := db.GetCustomStates(1)
+	if err != nil {
+		t.Fatalf("GetCustomStates: %v", err)
+	}
+	if len(states) != 2 || states[0] != created || !states[1].Archived {
+		t.Errorf("GetCustomStates = %+v", states)
+	}
+
+	db.SaveDay(1, 1, 1, 2024, model.DayState{State: created.ID})
+	db.SaveDay(1, 2, 1, 2024, model.DayState{State: created.ID})
+	db.SaveDay(1, 3, 1, 2024, model.DayState{State: leave.ID})
+	db.SaveDay(1, 4, 1, 2024, model.DayState{State: model.StateWorkFromOffice})
+	byAttendance, err := db.CountCustomEntriesByAttendance()
+	if err != nil {
+		t.Fatalf("CountCustomEntriesByAttendance: %v", err)
+	}
+	if byAttendance[model.AttendancePresent] != 2 || byAttendance[model.AttendanceExcluded] != 1 || len(byAttendance) != 2 {
+		t.Errorf("CountCustomEntriesByAttendance = %v", byAttendance)
+	}
+}
+
+func TestSQLiteLocations(t *testing.T) {
+	db := newTestDB(t)
+
+	lat, lng := -37.8136, 144.9631
+	office, err := db.SaveLocation(1, model.Location{Name: "Melbourne", Latitude: &lat, Longitude: &lng, Radius: 150})
+	if err != nil {
+		t.Fatalf("SaveLocation create: %v", err)
+	}
+	if office.ID != 1 {
+		t.Errorf("first location ID = %d, want 1", office.ID)
+	}
+	remote, err := db.SaveLocation(1, model.Location{Name: "Sydney"})
+	if err != nil {
+		t.Fatalf("SaveLocation create: %v", err)
+	}
+	if remote.ID != 2 {
+		t.Errorf("second location ID = %d, want 2", remote.ID)
+	}
+
+	remote.Archived = true
+	if _, err := db.SaveLocation(1, remote); err != nil {
+		t.Fatalf("SaveLocation update: %v", err)
+	}
+	if _, err := db.SaveLocation(1, model.Location{ID: 9, Name: "Ghost"}); !errors.Is(err, ErrNoLocation) {
+		t.Errorf("updating a missing location = %v, want ErrNoLocation", err)
+	}
+
+	locations, err := db.GetLocations(1)
+	if err != nil {
+		t.Fatalf("GetLocations: %v", err)
+	}
+	if len(locations) != 2 || locations[0].Latitude == nil || *locations[0].Latitude != lat || locations[0].Radius != 150 ||
+		locations[1].Latitude != nil || !locations[1].Archived {
+		t.Errorf("GetLocations = %+v", locations)
+	}
+
+	if err := db.SaveDay(1, 2, 1, 2024, model.DayState{State: model.StateWorkFromOffice, LocationID: office.ID}); err != nil {
+		t.Fatalf("SaveDay: %v", err)
+	}
+	if day, _ := db.GetDay(1, 2, 1, 2024); day.LocationID != office.ID {
+		t.Errorf("GetDay location = %d, want %d", day.LocationID, office.ID)
+	}
+	if month, _ := db.GetMonth(1, 1, 2024); month.Days[2].LocationID != office.ID {
+		t.Errorf("GetMonth location = %d, want %d", month.Days[2].LocationID, office.ID)
+	}
+}
+
+func TestSQLiteWebhooks(t *testing.T) {
+	db := newTestDB(t)
+
+	events := []model.WebhookEvent{model.WebhookEventDayUpdated, model.WebhookEventTargetMissed}
+	hook, err := db.SaveWebhook(1, model.Webhook{URL: "https://example.com/a", Events: events, Secret: "whsec_a"})
+	if err != nil {
+		t.Fatalf("SaveWebhook create: %v", err)
+	}
+	if hook.ID != 1 {
+		t.Errorf("first webhook ID = %d, want 1", hook.ID)
+	}
+	other, err := db.SaveWebhook(1, model.Webhook{URL: "https://example.com/b", Events: events[:1], Secret: "whsec_b"})
+	if err != nil {
+		t.Fatalf("SaveWebhook create: %v", err)
+	}
+
+	hook.URL, hook.Disabled, hook.Secret = "https://example.com/c", true, ""
+	if _, err := db.SaveWebhook(1, hook); err != nil {
+		t.Fatalf("SaveWebhook update: %v", err)
+	}
+	if _, err := db.SaveWebhook(1, model.Webhook{ID: 9, URL: "https://example.com"}); !errors.Is(err, ErrNoWebhook) {
+		t.Errorf("updating a missing webhook = %v, want ErrNoWebhook", err)
+	}
+	webhooks, err := db.GetWebhooks(1)
+	if err != nil {
+		t.Fatalf("GetWebhooks: %v", err)
+	}
+	if len(webhooks) != 2 || webhooks[0].URL != "https://example.com/c" || !webhooks[0].Disabled ||
+		webhooks[0].Secret != "whsec_a" || !slices.Equal(webhooks[0].Events, events) {
+		t.Errorf("GetWebhooks = %+v", webhooks)
+	}
+
+	now := time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC)
+	queue := func(webhookID int, created time.Time) {
+		t.Helper()
+		err := db.QueueWebhookDeliveries([]WebhookDelivery{{UserID: 1, WebhookDelivery: model.WebhookDelivery{
+			WebhookID: webhookID, Event: model.WebhookEventDayUpdated, CreatedAt: created, Payload: []byte(`{"event":"day.updated"}`),
+		}}})
+		if err != nil {
+			t.Fatalf("QueueWebhookDeliveries: %v", err)
+		}
+	}
+	queue(hook.ID, now.Add(-time.Minute))
+	queue(other.ID, now)
+	queue(other.ID, now.Add(time.Minute))
+
+	// Only due deliveries are claimed, and not again until the lease ends.
+	claimed, err := db.ClaimWebhookDeliveries(now, now.Add(5*time.Minute), 10)
+	if err != nil {
+		t.Fatalf("ClaimWebhookDeliveries: %v", err)
+	}
+	if len(claimed) != 2 || claimed[0].WebhookID != hook.ID || claimed[0].UserID != 1 || string(claimed[0].Payload) != `{"event":"day.updated"}` {
+		t.Fatalf("claimed = %+v, want the 2 due deliveries", claimed)
+	}
+	if again, _ := db.ClaimWebhookDeliveries(now.Add(time.Minute), now.Add(5*time.Minute), 10); len(again) != 1 {
+		t.Errorf("claimed again = %+v, want only the newly due delivery", again)
+	}
+
+	d := claimed[1]
+	d.Status, d.Attempts, d.ResponseCode, d.Error, d.NextAttemptAt = model.WebhookDeliveryPending, 1, 500, "unexpected status", now.Add(time.Hour)
+	if err := db.SaveWebhookAttempt(d); err != nil {
+		t.Fatalf("SaveWebhookAttempt: %v", err)
+	}
+	log, err := db.GetWebhookDeliveries(1, other.ID, 10)
+	if err != nil {
+		t.Fatalf("GetWebhookDeliveries: %v", err)
+	}
+	if len(log) != 2 || log[1].ID != d.ID || log[1].Attempts != 1 || log[1].ResponseCode != 500 || !log[1].NextAttemptAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("GetWebhookDeliveries = %+v, want newest first with the attempt saved", log)
+	}
+
+	d.Status, d.NextAttemptAt = model.WebhookDeliveryFailed, time.Time{}
+	if err := db.SaveWebhookAttempt(d); err != nil {
+		t.Fatalf("SaveWebhookAttempt: %v", err)
+	}
+	// Only finished deliveries are pruned.
+	if n, err := db.PruneWebhookDeliveries(now.Add(time.Hour)); err != nil || n != 1 {
+		t.Errorf("PruneWebhookDeliveries = (%d, %v), want 1", n, err)
+	}
+
+	if err := db.DeleteWebhook(1, hook.ID); err != nil {
+		t.Fatalf("DeleteWebhook: %v", err)
+	}
+	if err := db.DeleteWebhook(1, hook.ID); !errors.Is(err, ErrNoWebhook) {
+		t.Errorf("deleting again = %v, want ErrNoWebhook", err)
+	}
+	if log, _ := db.GetWebhookDeliveries(1, hook.ID, 10); len(log) != 0 {
+		t.Errorf("deliveries of a deleted webhook = %+v", log)
+	}
+}
+
+func TestSQLiteChatAccounts(t *testing.T) {
+	db := newTestDB(t)
+	account := model.ChatAccount{Platform: model.ChatPlatformSlack, TeamID: "T1", UserID: "U1", Name: "alex"}
+	now := time.Now()
+
+	if _, err := db.GetChatUser(account); !errors.Is(err, ErrNoChatAccount) {
+		t.Errorf("GetChatUser before linking = %v, want ErrNoChatAccount", err)
+	}
+	if err := db.SaveChatLink("code", account, now.Add(10*time.Minute)); err != nil {
+		t.Fatalf("SaveChatLink: %v", err)
+	}
+	if err := db.SaveChatLink("stale", account, now.Add(-time.Minute)); err != nil {
+		t.Fatalf("SaveChatLink: %v", err)
+	}
+	if got, err := db.GetChatLink("code", now); err != nil || got != account {
+		t.Errorf("GetChatLink = (%+v, %v), want %+v", got, err, account)
+	}
+	if _, err := db.GetChatLink("stale", now); !errors.Is(err, ErrNoChatLink) {
+		t.Errorf("GetChatLink of an expired code = %v, want ErrNoChatLink", err)
+	}
+	if _, err := db.GetChatLink("code", now.Add(time.Hour)); !errors.Is(err, ErrNoChatLink) {
+		t.Errorf("GetChatLink after expiry = %v, want ErrNoChatLink", err)
+	}
+
+	if got, err := db.LinkChatAccount(1, "code", now); err != nil || got != account {
+		t.Fatalf("LinkChatAccount = (%+v, %v), want %+v", got, err, account)
+	}
+	if _, err := db.LinkChatAccount(1, "code", now); !errors.Is(err, ErrNoChatLink) {
+		t.Errorf("reusing a code = %v, want ErrNoChatLink", err)
+	}
+	// The display name isn't part of the account's identity.
+	if userID, err := db.GetChatUser(model.ChatAccount{Platform: model.ChatPlatformSlack, TeamID: "T1", UserID: "U1"}); err != nil || userID != 1 {
+		t.Errorf("GetChatUser = (%d, %v), want 1", userID, err)
+	}
+	if _, err := db.GetChatUser(model.ChatAccount{Platform: model.ChatPlatformTeams, TeamID: "T1", UserID: "U1"}); !errors.Is(err, ErrNoChatAccount) {
+		t.Errorf("GetChatUser on another platform = %v, want ErrNoChatAccount", err)
+	}
+
+	// Linking again, e.g. after a name change, keeps one account.
+	db.SaveChatLink("again", model.ChatAccount{Platform: model.ChatPlatformSlack, TeamID: "T1", UserID: "U1", Name: "alexandra"}, now.Add(time.Minute))
+	if _, err := db.LinkChatAccount(1, "again", now); err != nil {
+		t.Fatalf("LinkChatAccount again: %v", err)
+	}
+
+	if err := db.UnlinkChatAccount(account); err != nil {
+		t.Fatalf("UnlinkChatAccount: %v", err)
+	}
+	if err := db.UnlinkChatAccount(account); !errors.Is(err, ErrNoChatAccount) {
+		t.Errorf("unlinking again = %v, want ErrNoChatAccount", err)
+	}
+	if _, err := db.GetChatUser(account); !errors.Is(err, ErrNoChatAccount) {
+		t.Errorf("GetChatUser after unlinking = %v, want ErrNoChatAccount", err)
+	}
+}
+
+func TestSQLiteScheduleRules(t *testing.T) {
+	db := newTestDB(t)
+
+	first, err := db.SaveScheduleRule(1, model.ScheduleRule{State: model.StateWorkFromOffice, RRule: "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO", Start: "2024-01-01"})
+	if err != nil {
+		t.Fatalf("SaveScheduleRule create: %v", err)
+	}
+	second, err := db.SaveScheduleRule(1, model.ScheduleRule{State: model.StateWorkFromHome, RRule: "FREQ=MONTHLY;BYDAY=-1FR", Start: "2024-01-01", End: "2024-06-30"})
+	if err != nil {
+		t.Fatalf("SaveScheduleRule create: %v", err)
+	}
+	if first.ID != 1 || second.ID != 2 {
+		t.Errorf("rule IDs = %d, %d, want 1, 2", first.ID, second.ID)
+	}
+
+	first.End = "2024-03-31"
+	if _, err := db.SaveScheduleRule(1, first); err != nil {
+		t.Fatalf("SaveScheduleRule update: %v", err)
+	}
+	if _, err := db.SaveScheduleRule(1, model.ScheduleRule{ID: 9, RRule: "FREQ=DAILY", Start: "2024-01-01"}); !errors.Is(err, ErrNoScheduleRule) {
+		t.Errorf("updating a missing rule = %v, want ErrNoScheduleRule", err)
+	}
+
+	rules, err := db.GetScheduleRules(1)
+	if err != nil {
+		t.Fatalf("GetScheduleRules: %v", err)
+	}
+	if len(rules) != 2 || rules[0] != first || rules[1] != second {
+		t.Errorf("GetScheduleRules = %+v", rules)
+	}
+
+	if err := db.DeleteScheduleRule(1, first.ID); err != nil {
+		t.Fatalf("DeleteScheduleRule: %v", err)
+	}
+	if err := db.DeleteScheduleRule(1, first.ID); !errors.Is(err, ErrNoScheduleRule) {
+		t.Errorf("deleting a missing rule = %v, want ErrNoScheduleRule", err)
+	}
+	if rules, _ := db.GetScheduleRules(1); len(rules) != 1 || rules[0] != second {
+		t.Errorf("rules after delete = %+v", rules)
+	}
+}
+
+func TestSQLiteNetworkRules(t *testing.T) {
+	db := newTestDB(t)
+
+	first, err := db.SaveNetworkRule(1, model.NetworkRule{Kind: model.NetworkRuleSSID, Value: "Office Wi-Fi", LocationID: 1})
+	if err != nil {
+		t.Fatalf("SaveNetworkRule create: %v", err)
+	}
+	second, err := db.SaveNetworkRule(1, model.NetworkRule{Kind: model.NetworkRuleCIDR, Value: "203.0.113.0/24"})
+	if err != nil {
+		t.Fatalf("SaveNetworkRule create: %v", err)
+	}
+	if first.ID != 1 || second.ID != 2 {
+		t.Errorf("rule IDs = %d, %d, want 1, 2", first.ID, second.ID)
+	}
+
+	first.Value = "Office Wi-Fi 5G"
+	if _, err := db.SaveNetworkRule(1, first); err != nil {
+		t.Fatalf("SaveNetworkRule update: %v", err)
+	}
+	if _, err := db.SaveNetworkRule(1, model.NetworkRule{ID: 9, Kind: model.NetworkRuleSSID, Value: "x"}); !errors.Is(err, ErrNoNetworkRule) {
+		t.Errorf("updating a missing rule = %v, want ErrNoNetworkRule", err)
+	}
+
+	rules, err := db.GetNetworkRules(1)
+	if err != nil {
+		t.Fatalf("GetNetworkRules: %v", err)
+	}
+	if len(rules) != 2 || rules[0] != first || rules[1] != second {
+		t.Errorf("GetNetworkRules = %+v", rules)
+	}
+
+	if err := db.DeleteNetworkRule(1, first.ID); err != nil {
+		t.Fatalf("DeleteNetworkRule: %v", err)
+	}
+	if err := db.DeleteNetworkRule(1, first.ID); !errors.Is(err, ErrNoNetworkRule) {
+		t.Errorf("deleting a missing rule = %v, want ErrNoNetworkRule", err)
+	}
+	if rules, _ := db.GetNetworkRules(1); len(rules) != 1 || rules[0] != second {
+		t.Errorf("rules after delete = %+v", rules)
+	}
+}
+
+func TestSQLiteScheduleHistory(t *testing.T) {
+	db := newTestDB(t)
+	uid := 1
+
+	office := model.SchedulePreferences{Monday: model.StateWorkFromOffice, Sunday: model.StateOther}
+	home := model.SchedulePreferences{Monday: model.StateWorkFromHome}
+	for _, v := range []model.ScheduleVersion{
+		{EffectiveFrom: "2024-03-01", Schedule: office},
+		{Schedule: home},
+		{EffectiveFrom: "2024-01-01", Schedule: home},
+		{EffectiveFrom: "2024-01-01", Schedule: office},
+	} {
+		if err := db.SaveScheduleVersions(uid, home, v); err != nil {
+			t.Fatalf("SaveScheduleVersions: %v", err)
+		}
+	}
+	// Saving the open-ended version again replaces it too, and several
+	// versions can be saved at once.
+	if err := db.SaveScheduleVersions(uid, office, model.ScheduleVersion{Schedule: office}, model.ScheduleVersion{EffectiveFrom: "2024-03-01", Schedule: office}); err != nil {
+		t.Fatalf("SaveScheduleVersions: %v", err)
+	}
+	if prefs, err := db.GetSchedulePreferences(uid); err != nil || prefs != office {
+		t.Errorf("GetSchedulePreferences = (%+v, %v), want the latest schedule saved alongside", prefs, err)
+	}
+
+	history, err := db.GetScheduleHistory(uid)
+	if err != nil {
+		t.Fatalf("GetScheduleHistory: %v", err)
+	}
+	want := model.ScheduleHistory{
+		{Schedule: office},
+		{EffectiveFrom: "2024-01-01", Schedule: office},
+		{EffectiveFrom: "2024-03-01", Schedule: office},
+	}
+	if !slices.Equal(history, want) {
+		t.Errorf("GetScheduleHistory = %+v, want %+v", history, want)
+	}
 }
 
 // CountTrackedDays and CountEntriesByState both exclude untracked entries and
@@ -441,3 +948,158 @@ func keysOf(m map[int]model.MonthState) []int {
 	}
 	return out
 }
+
+// Unconfirmed days round-trip through every read, and opting in lists the
+// standalone user for the evening run.
+func TestSQLiteMaterialise(t *testing.T) {
+	db := newTestDB(t)
+
+	db.SaveDay(1, 4, 3, 2024, model.DayState{State: model.StateWorkFromOffice, Source: model.SourceSchedule, Unconfirmed: true})
+	db.SaveMonth(1, 3, 2024, model.MonthState{Days: map[int]model.DayState{
+		5: {State: model.StateWorkFromHome, Source: model.SourceSchedule, Unconfirmed: true},
+		6: {State: model.StateWorkFromHome},
+	}})
+
+	if day, _ := db.GetDay(1, 4, 3, 2024); !day.Unconfirmed {
+		t.Errorf("GetDay = %+v, want unconfirmed", day)
+	}
+	month, _ := db.GetMonth(1, 3, 2024)
+	if !month.Days[5].Unconfirmed || month.Days[6].Unconfirmed {
+		t.Errorf("GetMonth = %+v, want only day 5 unconfirmed", month.Days)
+	}
+	if year, _ := db.GetYear(1, 2024, 1); !year.Months[3].Days[4].Unconfirmed {
+		t.Errorf("GetYear day 4 = %+v, want unconfirmed", year.Months[3].Days[4])
+	}
+
+	if users, err := db.ListMaterialiseUsers(); err != nil || len(users) != 0 {
+		t.Errorf("ListMaterialiseUsers = (%v, %v), want none before opting in", users, err)
+	}
+	if err := db.SaveMaterialisePreferences(1, model.MaterialisePreferences{Mode: model.MaterialiseUnconfirmed}); err != nil {
+		t.Fatalf("SaveMaterialisePreferences: %v", err)
+	}
+	if prefs, err := db.GetMaterialisePreferences(1); err != nil || prefs.Mode != model.MaterialiseUnconfirmed {
+		t.Errorf("GetMaterialisePreferences = (%+v, %v), want unconfirmed", prefs, err)
+	}
+	if users, err := db.ListMaterialiseUsers(); err != nil || !slices.Equal(users, []int{1}) {
+		t.Errorf("ListMaterialiseUsers = (%v, %v), want [1]", users, err)
+	}
+}
+
+func TestSQLiteNotifications(t *testing.T) {
+	db := newTestDB(t)
+
+	if users, err := db.ListNotificationUsers(); err != nil || len(users) != 0 {
+		t.Errorf("ListNotificationUsers before opting in = (%v, %v), want none", users, err)
+	}
+	prefs := model.NotificationPreferences{Email: "alex@example.com", WeeklyDigest: true}
+	if err := db.SaveNotificationPreferences(1, prefs); err != nil {
+		t.Fatalf("SaveNotificationPreferences: %v", err)
+	}
+	if got, err := db.GetNotificationPreferences(1); err != nil || got != prefs {
+		t.Errorf("GetNotificationPreferences = (%+v, %v), want %+v", got, err, prefs)
+	}
+	if users, _ := db.ListNotificationUsers(); !slices.Equal(users, []int{1}) {
+		t.Errorf("ListNotificationUsers = %v, want [1]", users)
+	}
+
+	now := time.Now()
+	if ok, err := db.ClaimNotification(1, "daily", "2024-05-07", now); err != nil || !ok {
+		t.Fatalf("ClaimNotification = (%v, %v), want claimed", ok, err)
+	}
+	if ok, _ := db.ClaimNotification(1, "daily", "2024-05-07", now); ok {
+		t.Error("claimed the same notification twice")
+	}
+	if err := db.ReleaseNotification(1, "daily", "2024-05-07"); err != nil {
+		t.Fatalf("ReleaseNotification: %v", err)
+	}
+	if ok, _ := db.ClaimNotification(1, "daily", "2024-05-07", now.Add(-48*time.Hour)); !ok {
+		t.Error("couldn't claim a released notification")
+	}
+	db.ClaimNotification(1, "weekly", "2024-W19", now)
+	if n, err := db.PruneNotifications(now.Add(-time.Hour)); err != nil || n != 1 {
+		t.Errorf("PruneNotifications = (%d, %v), want 1", n, err)
+	}
+	if ok, _ := db.ClaimNotification(1, "weekly", "2024-W19", now); ok {
+		t.Error("pruned a recent notification")
+	}
+}
+
+func TestSQLitePushSubscriptions(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Date(2024, time.May, 7, 9, 0, 0, 0, time.UTC)
+
+	sub := model.PushSubscription{
+		Endpoint:  "https://push.example/a",
+		Keys:      model.PushKeys{P256dh: "BKey", Auth: "secret"},
+		Device:    "Firefox on Linux",
+		ExpiresAt: now.AddDate(0, 1, 0),
+	}
+	saved, err := db.SavePushSubscription(1, sub, now)
+	if err != nil || saved.ID == 0 {
+		t.Fatalf("SavePushSubscription = (%+v, %v)", saved, err)
+	}
+	// Subscribing the same endpoint again replaces it.
+	sub.Keys.Auth = "rotated"
+	sub.ExpiresAt = time.Time{}
+	again, err := db.SavePushSubscription(1, sub, now)
+	if err != nil {
+		t.Fatalf("SavePushSubscription again: %v", err)
+	}
+	subs, err := db.GetPushSubscriptions(1)
+	if err != nil || len(subs) != 1 {
+		t.Fatalf("GetPushSubscriptions = (%+v, %v), want one", subs, err)
+	}
+	if got := subs[0]; got.ID != again.ID || got.Keys.Auth != "rotated" || got.Device != "Firefox on Linux" ||
+		!got.ExpiresAt.IsZero() || !got.CreatedAt.Equal(now) {
+		t.Errorf("subscription = %+v", got)
+	}
+
+	if err := db.DeletePushSubscription(1, again.ID); err != nil {
+		t.Fatalf("DeletePushSubscription: %v", err)
+	}
+	if err := db.DeletePushSubscription(1, again.ID); !errors.Is(err, ErrNoPushSub) {
+		t.Errorf("DeletePushSubscription twice = %v, want ErrNoPushSub", err)
+	}
+
+	prefs := model.NotificationPreferences{PushTargetWarning: true}
+	db.SaveNotificationPreferences(1, prefs)
+	if got, _ := db.GetNotificationPreferences(1); got != prefs {
+		t.Errorf("GetNotificationPreferences = %+v, want %+v", got, prefs)
+	}
+	if users, _ := db.ListNotificationUsers(); !slices.Equal(users, []int{1}) {
+		t.Errorf("ListNotificationUsers with only push on = %v, want [1]", users)
+	}
+}
+
+func TestSQLitePushTokens(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Date(2024, time.May, 7, 9, 0, 0, 0, time.UTC)
+
+	if users, _ := db.ListNotificationUsers(); len(users) != 0 {
+		t.Errorf("ListNotificationUsers = %v, want none", users)
+	}
+	token := model.PushToken{Token: "ExponentPushToken[abc]", Device: "Pixel 8", CheckIn: true}
+	if _, err := db.SavePushToken(1, 0, token, now); err != nil {
+		t.Fatalf("SavePushToken: %v", err)
+	}
+	// Registering the same token again replaces it.
+	token.Reminder = true
+	saved, err := db.SavePushToken(1, 0, token, now)
+	if err != nil {
+		t.Fatalf("SavePushToken again: %v", err)
+	}
+	tokens, err := db.GetPushTokens(1)
+	if err != nil || len(tokens) != 1 || tokens[0] != saved || !saved.CreatedAt.Equal(now) || !saved.Reminder {
+		t.Fatalf("GetPushTokens = (%+v, %v), want [%+v]", tokens, err, saved)
+	}
+	if users, _ := db.ListNotificationUsers(); !slices.Equal(users, []int{1}) {
+		t.Errorf("ListNotificationUsers with a reminder device = %v, want [1]", users)
+	}
+
+	if err := db.DeletePushToken(1, saved.ID); err != nil {
+		t.Fatalf("DeletePushToken: %v", err)
+	}
+	if err := db.DeletePushToken(1, saved.ID); !errors.Is(err, ErrNoPushToken) {
+		t.Errorf("DeletePushToken twice = %v, want ErrNoPushToken", err)
+	}
+}
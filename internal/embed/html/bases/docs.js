@@ -0,0 +1,211 @@
+// Renders the OpenAPI document as a list of operations that can be tried out.
+const apiDocs = document.getElementById("api-docs");
+const apiToken = document.getElementById("api-token");
+
+loadDocs();
+
+async function loadDocs() {
+    try {
+        const response = await fetch("/api/v1/openapi.json");
+        if (!response.ok) {
+            throw new Error(`status ${response.status}`);
+        }
+        renderDocs(await response.json());
+    } catch (error) {
+        apiDocs.innerHTML = "";
+        apiDocs.appendChild(el("p", "api-error", `Failed to load the API description: ${error.message}`));
+    }
+}
+
+function renderDocs(spec) {
+    const base = (spec.servers && spec.servers[0] && spec.servers[0].url) || "";
+    const byTag = new Map();
+    for (const [path, item] of Object.entries(spec.paths)) {
+        for (const [method, op] of Object.entries(item)) {
+            const tag = (op.tags && op.tags[0]) || "API";
+            if (!byTag.has(tag)) {
+                byTag.set(tag, []);
+            }
+            byTag.get(tag).push({ path, method, op });
+        }
+    }
+
+    apiDocs.innerHTML = "";
+    for (const tag of [...byTag.keys()].sort()) {
+        apiDocs.appendChild(el("h3", "api-tag", tag));
+        const ops = byTag.get(tag).sort((a, b) => a.path.localeCompare(b.path) || methodOrder(a.method) - methodOrder(b.method));
+        for (const { path, method, op } of ops) {
+            apiDocs.appendChild(renderOperation(spec, base, path, method, op));
+        }
+    }
+}
+
+function methodOrder(method) {
+    return ["get", "post", "put", "patch", "delete"].indexOf(method);
+}
+
+function renderOperation(spec, base, path, method, op) {
+    const details = el("details", "api-op");
+    const summary = el("summary");
+    summary.appendChild(el("span", `api-method ${method}`, method.toUpperCase()));
+    summary.appendChild(el("span", "api-path", path));
+    summary.appendChild(el("span", "api-summary", op.summary || ""));
+    details.appendChild(summary);
+
+    const body = el("div", "api-op-body");
+    if (op.description) {
+        body.appendChild(el("p", "", op.description));
+    }
+    if (op.security && op.security.length === 0) {
+        body.appendChild(el("p", "", "No authentication needed."));
+    }
+
+    const inputs = [];
+    for (const param of op.parameters || []) {
+        const label = el("label");
+        label.appendChild(el("span", "", `${param.name}${param.required ? "*" : ""}`));
+        const input = el("input");
+        input.placeholder = `${param.in}${param.schema && param.schema.pattern ? " " + param.schema.pattern : ""}`;
+        input.value = exampleParam(param);
+        label.appendChild(input);
+        body.appendChild(label);
+        inputs.push({ param, input });
+    }
+
+    let textarea = null;
+    const bodySchema = op.requestBody && op.requestBody.content["application/json"];
+    if (bodySchema) {
+        textarea = el("textarea");
+        textarea.value = JSON.stringify(example(spec, bodySchema.schema, 0), null, 2);
+        body.appendChild(textarea);
+    }
+
+    const send = el("button", "", "Send");
+    const output = el("pre", "api-response");
+    output.hidden = true;
+    send.addEventListener("click", () => tryOperation(base, path, method, inputs, textarea, output));
+    body.appendChild(send);
+    body.appendChild(output);
+
+    details.appendChild(body);
+    return details;
+}
+
+async function tryOperation(base, path, method, inputs, textarea, output) {
+    let url = path;
+    const query = new URLSearchParams();
+    for (const { param, input } of inputs) {
+        const value = input.value.trim();
+        if (param.in === "path") {
+            url = url.replace(`{${param.name}}`, encodeURIComponent(value));
+        } else if (value !== "") {
+            for (const part of value.split(",")) {
+                query.append(param.name, part.trim());
+            }
+        }
+    }
+    if ([...query].length > 0) {
+        url += `?${query}`;
+    }
+
+    const headers = {};
+    const token = apiToken.value.trim();
+    if (token) {
+        headers["Authorization"] = `Bearer ${token}`;
+    }
+    const init = { method: method.toUpperCase(), headers, credentials: token ? "omit" : "same-origin" };
+    if (textarea) {
+        headers["Content-Type"] = "application/json";
+        init.body = textarea.value;
+    }
+
+    output.hidden = false;
+    output.classList.remove("api-error");
+    output.textContent = "Sending…";
+    try {
+        const response = await fetch(base + url, init);
+        const contentType = response.headers.get("Content-Type") || "";
+        let text;
+        if (contentType.includes("application/json")) {
+            text = JSON.stringify(await response.json(), null, 2);
+        } else if (contentType.startsWith("text/")) {
+            text = await response.text();
+        } else {
+            const blob = await response.blob();
+            text = `${blob.size} bytes of ${contentType}`;
+        }
+        output.textContent = `${response.status} ${response.statusText}\n\n${text}`;
+        if (!response.ok) {
+            output.classList.add("api-error");
+        }
+    } catch (error) {
+        output.classList.add("api-error");
+        output.textContent = `Request failed: ${error.message}`;
+    }
+}
+
+function exampleParam(param) {
+    const now = new Date();
+    switch (param.name) {
+        case "year":
+        case "iso_year":
+            return String(now.getFullYear());
+        case "month":
+            return String(now.getMonth() + 1);
+        case "day":
+            return String(now.getDate());
+        case "week":
+            return "1";
+    }
+    return "";
+}
+
+// example builds a sample value for a schema to start a request body from.
+function example(spec, schema, depth) {
+    if (!schema || depth > 6) {
+        return null;
+    }
+    if (schema.$ref) {
+        const name = schema.$ref.split("/").pop();
+        return example(spec, spec.components.schemas[name], depth + 1);
+    }
+    if (schema.enum && schema.enum.length > 0) {
+        return schema.enum.find((value) => value !== "") ?? schema.enum[0];
+    }
+    switch (schema.type) {
+        case "boolean":
+            return false;
+        case "integer":
+        case "number":
+            return 0;
+        case "string":
+            return schema.format === "date-time" ? new Date().toISOString() : "";
+        case "array":
+            return [example(spec, schema.items, depth + 1)];
+        case "object":
+            if (schema.properties) {
+                const value = {};
+                for (const [name, property] of Object.entries(schema.properties)) {
+                    value[name] = example(spec, property, depth + 1);
+                }
+                return value;
+            }
+            if (schema.additionalProperties) {
+                const key = schema.propertyNames ? "1" : "key";
+                return { [key]: example(spec, schema.additionalProperties, depth + 1) };
+            }
+            return {};
+    }
+    return null;
+}
+
+function el(tag, className, text) {
+    const node = document.createElement(tag);
+    if (className) {
+        node.className = className;
+    }
+    if (text !== undefined) {
+        node.textContent = text;
+    }
+    return node;
+}
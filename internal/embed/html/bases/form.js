@@ -5,8 +5,17 @@ const monthNames = ["January", "February", "March", "April", "May", "June", "Jul
 // The month (1-12) the tracking year starts on, configurable per user.
 let trackingStartMonth = {{ .TrackingStartMonth }} || 10;
 
-// The user's monthly attendance target percentage (0 = no target set).
-let targetPercent = {{ .TargetPercent }} || 0;
+// The user's attendance target ({target_percent, policy, target_days, window,
+// window_weeks}). Progress against it is worked out by the server.
+let target = {{ .Target }} || {};
+
+// The user's custom states ({id, name, color, attendance, archived}). Archived
+// states still render on existing days but are skipped when cycling.
+const customStates = {{ .CustomStates }} || [];
+
+// The user's office locations ({id, name, archived, ...}). Archived locations
+// still show on existing days but can't be picked for new ones.
+const locations = {{ .Locations }} || [];
 
 // trackingYearForMonth0 maps a 0-indexed calendar month + calendar year to its
 // tracking-year label (mirrors util.TrackingYear in Go).
@@ -21,47 +30,114 @@ function trackingMonthOrder(month1) {
     return (month1 - trackingStartMonth + 12) % 12;
 }
 
+// targetStatus builds the progress sentences from a model.Compliance, which is
+// null when no target is set.
+function targetStatus(c) {
+    if (!c) { return "No attendance target set."; }
+    const period = `${c.start} to ${c.end}`;
+    const neededLine = c.met
+        ? `Target met for ${period}.`
+        : `<span class="num">${c.remaining}</span> more office day${c.remaining === 1 ? "" : "s"} needed for ${period}.`;
+    let progressLine;
+    if (c.policy === "days_per_week" || c.policy === "days_per_month") {
+        const unit = c.policy === "days_per_week" ? "week" : "month";
+        progressLine = `Averaging <span class="num">${c.actual}</span> of <span class="num">${c.target}</span> days per ${unit} ` +
+            `(<span class="num">${c.present}</span> of <span class="num">${c.required}</span> office days).`;
+    } else {
+        progressLine = `In office <span class="num">${c.present}</span> of <span class="num">${c.total}</span> tracked days ` +
+            `(<span class="num">${c.actual.toFixed(1)}%</span> of <span class="num">${c.target}%</span>).`;
+    }
+    return neededLine + "<br>" + progressLine;
+}
+
+// forecastStatus describes a model.Forecast with office days still needed:
+// whether the days already planned meet the target, and if not whether it can
+// still be met.
+function forecastStatus(f) {
+    const planned = `<span class="num">${f.planned}</span> office day${f.planned === 1 ? "" : "s"} planned ` +
+        `of <span class="num">${f.remaining_work_days}</span> work days left (projected <span class="num">${f.projected_percent.toFixed(1)}%</span>)`;
+    if (!f.achievable) {
+        return planned + "; the target can no longer be met by " + f.end + ".";
+    }
+    if (f.projected_met) {
+        return planned + "; on track to meet the target.";
+    }
+    return planned + `; plan <span class="num">${f.additional}</span> more to meet the target.`;
+}
+
+// isoDate formats a date as YYYY-MM-DD in local time.
+function isoDate(d) {
+    return d.getFullYear() + "-" + String(d.getMonth() + 1).padStart(2, "0") + "-" + String(d.getDate()).padStart(2, "0");
+}
+
 class Data {
     static titleDOM = document.getElementById("month-year");
     static calendarDOM = document.getElementById("calendar");
     static notesDOM = document.getElementById("notes");
     static targetDOM = document.getElementById("target-progress");
-
-    constructor(state, notes) {
+    static historyTitleDOM = document.getElementById("history-title");
+    static historyTableDOM = document.getElementById("history-table");
+    static dayNoteDOM = document.getElementById("day-note");
+    static dayLocationDOM = document.getElementById("day-location");
+    static unconfirmedDOM = document.getElementById("unconfirmed");
+    static unconfirmedListDOM = document.getElementById("unconfirmed-list");
+
+    constructor(state, sources, dayNotes, dayLocations, notes) {
         this.state = state;
+        this.sources = sources;
+        this.dayNotes = dayNotes;
+        this.dayLocations = dayLocations;
         this.notes = notes;
         this.updateDate(true, false);
         this.refreshDOM();
         Data.notesDOM.addEventListener("blur", () => { this.updateNote() });
+        Data.dayNoteDOM.addEventListener("change", () => { this.updateDayNote() });
+        Data.dayLocationDOM.addEventListener("change", () => { this.updateDayLocation() });
         document.getElementById("prev-month").addEventListener("click", () => this.updateMonth(-1));
         document.getElementById("next-month").addEventListener("click", () => this.updateMonth(1));
+        document.getElementById("confirm-all").addEventListener("click", () => this.confirmDays());
+        this.drawUnconfirmed();
         window.addEventListener("popstate", this.updateDate);
     }
 
     cycleState(dayDOM, direction) {
         let originalState = parseInt(dayDOM.dataset.state);
-        let previousState = originalState;
-        
-        // If this is a scheduled state, convert it to untracked first
-        if (previousState >= 4) { // Scheduled states are 4, 5, 6
-            previousState = 0; // Convert to untracked
-        }
-        
-        // Only cycle through the first 4 states (0-3), not scheduled states
-        let currentState = (previousState + direction + 4) % 4;
+
+        // Cycle through the built-in states (0-3) then the active custom
+        // states. Scheduled and archived states restart from untracked.
+        const order = cycleOrder();
+        let index = order.indexOf(originalState);
+        if (index < 0) { index = 0; }
+        let currentState = order[(index + direction + order.length) % order.length];
         let date = dayDOM.textContent;
-        
+
         // Remove all state classes (use original state for correct class removal)
-        dayDOM.classList.remove(getClassForState(states[originalState]));
-        
-        dayDOM.classList.add(getClassForState(states[currentState]));
+        dayDOM.classList.remove(classForState(originalState));
+
+        dayDOM.classList.add(classForState(currentState));
+        dayDOM.style.backgroundColor = colorForState(currentState);
         dayDOM.dataset.state = currentState;
+        // Days set here are manual, so drop any auto-detected badge.
+        delete dayDOM.dataset.sourceBadge;
+        if (this.currentMonth+1 in this.sources) {
+            delete this.sources[this.currentMonth+1][date];
+        }
+        // The server drops a day's location when its state changes.
+        if (this.currentMonth+1 in this.dayLocations) {
+            delete this.dayLocations[this.currentMonth+1][date];
+        }
         this.updateState(date, currentState);
+        const selected = this.selectedDay;
+        if (selected && selected.year === this.currentYear && selected.month === this.currentMonth + 1 &&
+            selected.day === parseInt(date, 10)) {
+            this.drawDayLocation();
+        }
     }
 
     drawCalendar() {
-        let calendarDOM = generateCalendar(this.currentMonth, this.currentYear, this.state,
-            (dayDOM, direction) => this.cycleState(dayDOM, direction)
+        let calendarDOM = generateCalendar(this.currentMonth, this.currentYear, this.state, this.sources, this.dayNotes, this.dayLocations,
+            (dayDOM, direction) => this.cycleState(dayDOM, direction),
+            (day) => this.drawHistory(day)
         );
         Data.calendarDOM.removeAttribute("id");
         calendarDOM.id = "calendar";
@@ -76,83 +152,140 @@ class Data {
         Data.notesDOM.value = this.notes[this.currentMonth+1];
     }
 
-    // drawTarget renders the monthly attendance target: progress so far this
-    // month, how many more office days are needed to meet the target, and an
-    // inline picker to set/adjust the target; saving updates the projection
-    // immediately.
+    // drawTarget renders the attendance target: progress over the target's
+    // window as worked out by the server, how many more office days are
+    // needed, and an inline input to adjust the target amount. Viewing a past
+    // month measures up to its last day.
     drawTarget() {
         const elem = Data.targetDOM;
-        let status;
-        if (targetPercent <= 0) {
-            status = "No monthly attendance target set.";
-        } else {
-            status = this.targetStatus();
+        const perDay = target.policy === "days_per_week" || target.policy === "days_per_month";
+        const unit = target.policy === "days_per_week" ? "days per week"
+            : target.policy === "days_per_month" ? "days per month" : "%";
+
+        const render = (status) => {
+            elem.innerHTML = status + "<br>" +
+                'Target: <input type="number" id="target-inline" min="0" ' +
+                (perDay ? 'step="0.5"' : 'max="100" step="10"') + '> ' + unit;
+            const input = document.getElementById("target-inline");
+            const current = perDay ? target.target_days : target.target_percent;
+            if (current > 0) { input.value = current; }
+            input.addEventListener("change", () => {
+                let value = parseFloat(input.value);
+                if (isNaN(value) || value < 0) { value = 0; } // 0 = no target
+                if (perDay) {
+                    target.target_days = value;
+                } else {
+                    target.target_percent = Math.min(100, Math.round(value));
+                }
+                fetch("/api/v1/settings/target", {
+                    method: 'PUT',
+                    headers: {
+                        'Content-Type': 'application/json',
+                    },
+                    body: JSON.stringify({data: target}),
+                    credentials: "include"
+                }).then(() => this.drawTarget());
+            });
+        };
+
+        // The current and later months also get a forecast to the end of the
+        // month from today, counting the schedule and days entered ahead.
+        const now = new Date();
+        const today = new Date(now.getFullYear(), now.getMonth(), now.getDate());
+        const monthEnd = new Date(this.currentYear, this.currentMonth + 1, 0);
+        const currentMonth = this.currentYear === today.getFullYear() && this.currentMonth === today.getMonth();
+        const asOf = currentMonth ? today : monthEnd;
+        const requests = [
+            fetch("/api/v1/settings/target/compliance?date=" + isoDate(asOf), {credentials: "include"}).then(r => r.json())
+        ];
+        if (monthEnd >= today) {
+            requests.push(fetch("/api/v1/settings/target/forecast?date=" + isoDate(today) + "&until=" + isoDate(monthEnd),
+                {credentials: "include"}).then(r => r.json()));
         }
+        Promise.all(requests)
+            .then(([compliance, forecast]) => {
+                let status = targetStatus(compliance.data);
+                if (forecast && forecast.data && forecast.data.needed > 0) {
+                    status += "<br>" + forecastStatus(forecast.data);
+                }
+                render(status);
+            })
+            .catch(() => render("Couldn't load attendance target progress."));
+    }
 
-        elem.innerHTML = status + "<br>" +
-            'Target: <input type="number" id="target-inline" min="0" max="100" step="10"> %';
-        const input = document.getElementById("target-inline");
-        if (targetPercent > 0) { input.value = targetPercent; }
-        input.addEventListener("change", () => {
-            let value = parseInt(input.value, 10);
-            if (isNaN(value) || value < 0) { value = 0; } // 0 = no target
-            if (value > 100) { value = 100; }
-            fetch("/api/v1/settings/target", {
-                method: 'PUT',
-                headers: {
-                    'Content-Type': 'application/json',
-                },
-                body: JSON.stringify({
-                    data: {
-                        target_percent: value
-                    }
-                }),
-                credentials: "include"
+    // drawUnconfirmed lists the past week's days that were filled in from the
+    // schedule and still need confirming, hiding the panel when there are none.
+    drawUnconfirmed() {
+        fetch("/api/v1/state/unconfirmed?date=" + isoDate(new Date()), {credentials: "include"})
+            .then(r => r.json())
+            .then(payload => {
+                this.unconfirmed = payload.data || [];
+                Data.unconfirmedListDOM.innerHTML = "";
+                this.unconfirmed.forEach(day => {
+                    const item = document.createElement("li");
+                    const [y, m, d] = day.date.split("-").map(Number);
+                    const date = new Date(y, m - 1, d);
+                    item.textContent = date.toLocaleDateString(undefined, {weekday: "short", day: "numeric", month: "short"}) +
+                        ": " + stateLabel(day.state);
+                    Data.unconfirmedListDOM.appendChild(item);
+                });
+                Data.unconfirmedDOM.hidden = this.unconfirmed.length === 0;
             });
-            targetPercent = value;
-            this.drawTarget();
-        });
     }
 
-    // targetStatus builds the progress sentences for the current month against
-    // the set target.
-    targetStatus() {
-        // Count this month's work days the same way the yearly report does:
-        // present = office (actual + scheduled), total = WFH + office.
-        const days = this.state[this.currentMonth + 1] || {};
-        let present = 0, total = 0;
-        for (const day in days) {
-            const state = days[day];
-            if (state === 2 || state === 5) {
-                present++;
-                total++;
-            } else if (state === 1 || state === 4) {
-                total++;
-            }
-        }
-
-        // Project the month-end total: work days tracked so far plus remaining
-        // weekdays that aren't tracked yet (assume they will be work days).
-        let projectedTotal = total;
-        const now = new Date();
-        const startOfToday = new Date(now.getFullYear(), now.getMonth(), now.getDate());
-        const daysInMonth = new Date(this.currentYear, this.currentMonth + 1, 0).getDate();
-        for (let day = 1; day <= daysInMonth; day++) {
-            if ((days[day] || 0) !== 0) { continue; } // already tracked
-            const date = new Date(this.currentYear, this.currentMonth, day);
-            if (date < startOfToday) { continue; } // past untracked days don't count
-            const dow = date.getDay();
-            if (dow === 0 || dow === 6) { continue; } // weekends
-            projectedTotal++;
-        }
+    confirmDays() {
+        const dates = (this.unconfirmed || []).map(day => day.date);
+        fetch("/api/v1/state/confirm", {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json',
+            },
+            body: JSON.stringify({data: {dates: dates}}),
+            credentials: "include"
+        }).then(() => this.drawUnconfirmed());
+    }
 
-        const percentage = total > 0 ? ((present / total) * 100).toFixed(1) : "0.0";
-        const needed = Math.max(0, Math.ceil(targetPercent / 100 * projectedTotal) - present);
-        const neededLine = needed > 0
-            ? `<span class="num">${needed}</span> more office day${needed === 1 ? "" : "s"} needed this month.`
-            : "Target met for this month.";
-        const progressLine = `In office <span class="num">${present}</span> of <span class="num">${total}</span> tracked days (<span class="num">${percentage}%</span>).`;
-        return neededLine + "<br>" + progressLine;
+    // drawHistory shows the given day of the current month's note for editing
+    // and lists every recorded change to the day, oldest first, with the
+    // client that made it.
+    drawHistory(day) {
+        const month = this.currentMonth + 1;
+        const year = this.currentYear;
+        this.selectedDay = {year: year, month: month, day: day};
+        Data.dayNoteDOM.value = (this.dayNotes[month] || {})[day] || "";
+        Data.dayNoteDOM.hidden = false;
+        this.drawDayLocation();
+        fetch("/api/v1/state/" + year + "/" + month + "/" + day + "/history")
+            .then(r => r.json())
+            .then(payload => {
+                const changes = payload.data || [];
+                Data.historyTitleDOM.textContent = changes.length > 0
+                    ? `Changes to ${day} ${monthNames[this.currentMonth]} ${year}:`
+                    : `No changes recorded for ${day} ${monthNames[this.currentMonth]} ${year}.`;
+                Data.historyTableDOM.replaceChildren();
+                if (changes.length === 0) { return; }
+                const header = document.createElement("tr");
+                ["Changed", "From", "To", "Client"].forEach(label => {
+                    const th = document.createElement("th");
+                    th.textContent = label;
+                    header.appendChild(th);
+                });
+                Data.historyTableDOM.appendChild(header);
+                changes.forEach(change => {
+                    const row = document.createElement("tr");
+                    [
+                        new Date(change.changed_at).toLocaleString(),
+                        stateLabel(change.old_state),
+                        stateLabel(change.new_state),
+                        describeClient(change),
+                    ].forEach(value => {
+                        const td = document.createElement("td");
+                        td.textContent = value;
+                        row.appendChild(td);
+                    });
+                    Data.historyTableDOM.appendChild(row);
+                });
+            });
     }
 
     fetchData() {
@@ -161,6 +294,9 @@ class Data {
             .then(r => r.json())
             .then(payload => {
                 this.state = mapState(payload);
+                this.sources = mapSources(payload);
+                this.dayNotes = mapDayNotes(payload);
+                this.dayLocations = mapDayLocations(payload);
                 this.refreshDOM();
             });
     }
@@ -195,7 +331,7 @@ class Data {
                 "state": thisState
             }
         };
-        fetch("/api/v1/state/" + year + "/" + month + "/" + day, {
+        return fetch("/api/v1/state/" + year + "/" + month + "/" + day, {
             method: 'PUT',
             headers: {
                 'Content-Type': 'application/json',
@@ -225,6 +361,93 @@ class Data {
         });
     }
 
+    // updateDayNote saves the note for the day selected with shift-click.
+    updateDayNote() {
+        if (!this.selectedDay) { return; }
+        const {year, month, day} = this.selectedDay;
+        const note = Data.dayNoteDOM.value;
+        if (!(month in this.dayNotes)) {
+            this.dayNotes[month] = {};
+        }
+        if (note) {
+            this.dayNotes[month][day] = note;
+        } else {
+            delete this.dayNotes[month][day];
+        }
+        fetch("/api/v1/note/" + year + "/" + month + "/" + day, {
+            method: 'PUT',
+            headers: {
+                'Content-Type': 'application/json',
+            },
+            body: JSON.stringify({
+                data: {
+                    note: note
+                }
+            }),
+            credentials: "include"
+        });
+        if (year === this.currentYear && month === this.currentMonth + 1) {
+            this.drawCalendar();
+        }
+    }
+
+    // drawDayLocation offers the active locations for the selected day when it
+    // counts as an office day, keeping its current location even if archived.
+    drawDayLocation() {
+        const elem = Data.dayLocationDOM;
+        const {month, day} = this.selectedDay;
+        const dayState = (this.state[month] || {})[day] || 0;
+        const current = (this.dayLocations[month] || {})[day] || 0;
+        elem.replaceChildren();
+        if (locations.length === 0 || attendanceFor(dayState) !== "present") {
+            elem.hidden = true;
+            return;
+        }
+        const none = document.createElement("option");
+        none.value = "0";
+        none.textContent = "No location";
+        elem.appendChild(none);
+        locations.forEach(location => {
+            if (location.archived && location.id !== current) { return; }
+            const option = document.createElement("option");
+            option.value = location.id;
+            option.textContent = location.name;
+            elem.appendChild(option);
+        });
+        elem.value = current;
+        elem.hidden = false;
+    }
+
+    // updateDayLocation saves the location picked for the selected day.
+    updateDayLocation() {
+        if (!this.selectedDay) { return; }
+        const {year, month, day} = this.selectedDay;
+        const locationID = parseInt(Data.dayLocationDOM.value, 10) || 0;
+        if (!(month in this.dayLocations)) {
+            this.dayLocations[month] = {};
+        }
+        if (locationID) {
+            this.dayLocations[month][day] = locationID;
+        } else {
+            delete this.dayLocations[month][day];
+        }
+        fetch("/api/v1/state/" + year + "/" + month + "/" + day + "/location", {
+            method: 'PUT',
+            headers: {
+                'Content-Type': 'application/json',
+            },
+            body: JSON.stringify({
+                data: {
+                    location_id: locationID
+                }
+            }),
+            credentials: "include"
+        });
+        if (year === this.currentYear && month === this.currentMonth + 1) {
+            this.drawCalendar();
+        }
+    }
+
     updateDate(sameYear = false, refresh = true) {
         const url = window.location.href;
         const urlParts = url.split("/");
@@ -265,7 +488,7 @@ class Data {
             this.state[this.currentMonth+1] = {};
         }
         this.state[this.currentMonth+1][date] = state;
-        this.updateBackend(date);
+        const saved = this.updateBackend(date);
         
         // If setting to untracked, fetch fresh data to get the fallthrough scheduled state
         if (state === 0) {
@@ -273,21 +496,131 @@ class Data {
                 this.fetchData();
             }, 100); // Small delay to ensure backend update completes
         } else {
-            this.drawTarget();
+            saved.then(() => this.drawTarget());
         }
+        // Saving a day confirms it.
+        saved.then(() => this.drawUnconfirmed());
     }
 
     updateTitle() { Data.titleDOM.textContent = monthNames[this.currentMonth] + " " + this.currentYear; }
 }
 
+// WeekView shows one ISO week at a time in place of the month calendar. Days
+// are read and written through the week endpoints, and the server counts the
+// office days recorded in the week.
+class WeekView {
+    static monthViewDOM = document.getElementById("month-view");
+    static viewDOM = document.getElementById("week-view");
+    static titleDOM = document.getElementById("week-title");
+    static calendarDOM = document.getElementById("week-calendar");
+    static countDOM = document.getElementById("week-office-days");
+
+    constructor(data) {
+        this.data = data;
+        document.getElementById("show-week").addEventListener("click", () => this.show(true));
+        document.getElementById("show-month").addEventListener("click", () => this.show(false));
+        document.getElementById("prev-week").addEventListener("click", () => this.updateWeek(-1));
+        document.getElementById("next-week").addEventListener("click", () => this.updateWeek(1));
+    }
+
+    // show switches between the week and month views. The week view opens on
+    // this week when the month shown is the current one, otherwise on the
+    // month's first week. Returning to the month reloads it to pick up changes.
+    show(visible) {
+        WeekView.viewDOM.hidden = !visible;
+        WeekView.monthViewDOM.hidden = visible;
+        if (!visible) {
+            this.data.fetchData();
+            return;
+        }
+        const today = new Date();
+        const month = this.data.currentMonth === today.getMonth() && this.data.currentYear === today.getFullYear()
+            ? today : new Date(this.data.currentYear, this.data.currentMonth, 1);
+        this.start = mondayOf(month);
+        this.fetchWeek();
+    }
+
+    updateWeek(delta) {
+        this.start = new Date(this.start.getFullYear(), this.start.getMonth(), this.start.getDate() + 7 * delta);
+        this.fetchWeek();
+    }
+
+    fetchWeek() {
+        const week = isoWeek(this.start);
+        fetch("/api/v1/state/week/" + week, {credentials: "include"})
+            .then(r => r.json())
+            .then(payload => this.drawWeek(payload.data));
+    }
+
+    drawWeek(week) {
+        const end = new Date(this.start.getFullYear(), this.start.getMonth(), this.start.getDate() + 6);
+        const format = {day: "numeric", month: "short"};
+        WeekView.titleDOM.textContent = week.week + ": " + this.start.toLocaleDateString(undefined, format) +
+            " to " + end.toLocaleDateString(undefined, format);
+        WeekView.countDOM.textContent = `${week.office_days} office day${week.office_days === 1 ? "" : "s"} this week.`;
+
+        const table = document.createElement("table");
+        const header = document.createElement("tr");
+        const row = document.createElement("tr");
+        ["Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"].forEach((name, i) => {
+            const weekday = i + 1;
+            const date = new Date(this.start.getFullYear(), this.start.getMonth(), this.start.getDate() + i);
+            const th = document.createElement("th");
+            th.textContent = name;
+            header.appendChild(th);
+
+            const td = document.createElement("td");
+            const state = (week.days[weekday] || {}).state || 0;
+            td.textContent = date.getDate();
+            td.classList.add("day", classForState(state));
+            td.style.backgroundColor = colorForState(state);
+            if (isoDate(date) === isoDate(new Date())) { td.classList.add("today"); }
+            td.addEventListener("click", () => this.cycleState(weekday, state, 1));
+            td.addEventListener("contextmenu", (event) => {
+                event.preventDefault();
+                this.cycleState(weekday, state, -1);
+            });
+            row.appendChild(td);
+        });
+        table.appendChild(header);
+        table.appendChild(row);
+        WeekView.calendarDOM.replaceChildren(table);
+    }
+
+    // cycleState steps a day through the same states as the month calendar
+    // and saves it, then redraws the week from the server.
+    cycleState(weekday, state, direction) {
+        const order = cycleOrder();
+        let index = order.indexOf(state);
+        if (index < 0) { index = 0; }
+        const next = order[(index + direction + order.length) % order.length];
+        fetch("/api/v1/state/week/" + isoWeek(this.start), {
+            method: 'PUT',
+            headers: {
+                'Content-Type': 'application/json',
+            },
+            body: JSON.stringify({data: {days: {[weekday]: {state: next}}}}),
+            credentials: "include"
+        }).then(() => {
+            this.fetchWeek();
+            this.data.drawUnconfirmed();
+        });
+    }
+}
+
 let rawState = {{ .YearlyState }};
 let rawNotes = {{ .YearlyNotes }};
 let state = mapState(rawState);
+let sources = mapSources(rawState);
+let dayNotes = mapDayNotes(rawState);
+let dayLocations = mapDayLocations(rawState);
 let notes = mapNotes(rawNotes);
 
-let data = new Data(state, notes);
+let data = new Data(state, sources, dayNotes, dayLocations, notes);
+new WeekView(data);
+drawCustomLegend();
 
-function generateCalendar(month, year, currState, callback) {
+function generateCalendar(month, year, currState, currSources, currDayNotes, currDayLocations, callback, historyCallback) {
     let calendar = document.createElement("div");
     let table = document.createElement('table');
     let thead = document.createElement('thead');
@@ -320,10 +653,31 @@ function generateCalendar(month, year, currState, callback) {
                     cellState = currState[month+1][currentDate.getDate()];
                 }
                 td.dataset.state = cellState; // Initial state
-                td.classList.add(getClassForState(states[td.dataset.state]));
+                td.classList.add(classForState(cellState));
+                td.style.backgroundColor = colorForState(cellState);
+                if (month+1 in currSources && currentDate.getDate() in currSources[month+1]) {
+                    const badge = sourceBadges[currSources[month+1][currentDate.getDate()]];
+                    if (badge) { td.dataset.sourceBadge = badge; }
+                }
+                let dayNote = "";
+                if (month+1 in currDayNotes && currentDate.getDate() in currDayNotes[month+1]) {
+                    dayNote = currDayNotes[month+1][currentDate.getDate()];
+                    td.dataset.hasNote = "true";
+                }
+                let dayLocation = "";
+                if (month+1 in currDayLocations && currentDate.getDate() in currDayLocations[month+1]) {
+                    dayLocation = locationName(currDayLocations[month+1][currentDate.getDate()]);
+                }
                 
                 if (currentDate.getTime() === today.getTime()) { td.classList.add('today'); }
-                td.addEventListener('click', function () { callback(this, 1); });
+                let clickedDay = currentDate.getDate();
+                td.addEventListener('click', function (event) {
+                    if (event.shiftKey) {
+                        historyCallback(clickedDay);
+                        return;
+                    }
+                    callback(this, 1);
+                });
                 td.addEventListener('contextmenu', function (event) {
                     event.preventDefault();
                     callback(this, -1);
@@ -332,7 +686,7 @@ function generateCalendar(month, year, currState, callback) {
                 // Add tooltip events for running total
                 let dayNum = currentDate.getDate();
                 td.addEventListener('mouseenter', function(event) {
-                    showTooltip(event, currState, month, year, dayNum);
+                    showTooltip(event, currState, month, year, dayNum, dayNote, dayLocation);
                 });
                 td.addEventListener('mouseleave', hideTooltip);
             }
@@ -367,6 +721,96 @@ function getClassForState(state) {
     }
 }
 
+// classForState maps a state value to its calendar class. Custom states are
+// coloured inline by colorForState instead.
+function classForState(state) {
+    const custom = customStateFor(state);
+    if (custom) { return "custom"; }
+    return getClassForState(states[state]);
+}
+
+function colorForState(state) {
+    const custom = customStateFor(state);
+    return custom ? custom.color : "";
+}
+
+function customStateFor(state) {
+    return customStates.find(custom => custom.id === state);
+}
+
+// cycleOrder lists the states a click steps through.
+function cycleOrder() {
+    const order = [0, 1, 2, 3];
+    customStates.forEach(custom => {
+        if (!custom.archived) { order.push(custom.id); }
+    });
+    return order;
+}
+
+// attendanceFor mirrors model.CustomStates.Attendance: "present" days count as
+// in office, "absent" days as work days away, and the rest are excluded.
+function attendanceFor(state) {
+    if (state === 2 || state === 5) { return "present"; }
+    if (state === 1 || state === 4) { return "absent"; }
+    const custom = customStateFor(state);
+    return custom ? custom.attendance : "excluded";
+}
+
+const stateLabels = ["Untracked", "Work from home", "In office", "Other"];
+
+function stateLabel(state) {
+    const custom = customStateFor(state);
+    if (custom) { return custom.name; }
+    return stateLabels[state] || "Unknown";
+}
+
+// drawCustomLegend adds the active custom states to the legend.
+function drawCustomLegend() {
+    const legend = document.getElementById("legend");
+    customStates.forEach(custom => {
+        if (custom.archived) { return; }
+        const item = document.createElement("div");
+        item.className = "legend-item";
+        const swatch = document.createElement("span");
+        swatch.className = "legend-color";
+        swatch.style.backgroundColor = custom.color;
+        item.appendChild(swatch);
+        item.appendChild(document.createTextNode(" " + custom.name));
+        legend.appendChild(item);
+    });
+}
+
+// describeClient renders where a history entry's write came from.
+function describeClient(change) {
+    let client;
+    switch (change.auth_method) {
+        case "sso":
+            client = "Web";
+            break;
+        case "secret":
+            client = "API token" + (change.token_id ? " #" + change.token_id : "");
+            break;
+        case "excluded":
+            client = "Standalone";
+            break;
+        case "slack":
+            client = "Slack";
+            break;
+        case "teams":
+            client = "Teams";
+            break;
+        default:
+            client = "Unknown";
+    }
+    if (change.via === "mcp") {
+        client += " (MCP)";
+    }
+    if (change.via === "schedule") {
+        client = "Schedule";
+    }
+    return client;
+}
+
 function mapState(payload) {
     let state = {};
     const months = payload.data.months;
@@ -383,6 +827,63 @@ function mapState(payload) {
     return state;
 }
 
+// sourceBadges labels days recorded automatically. Manual and scheduled days
+// get no badge.
+const sourceBadges = {
+    "geofence": "geo",
+    "extension": "ext",
+    "import": "imp",
+    "mcp": "mcp",
+    "chat": "chat",
+};
+
+// mapSources pulls each day's entry source out of a year payload.
+function mapSources(payload) {
+    let sources = {};
+    for (const [month, value] of Object.entries(payload.data.months)) {
+        sources[month] = {};
+        for (const [day, dayVal] of Object.entries(value.days)) {
+            if (dayVal.source) {
+                sources[month][day] = dayVal.source;
+            }
+        }
+    }
+    return sources;
+}
+
+// mapDayNotes pulls each day's note out of a year payload.
+function mapDayNotes(payload) {
+    let dayNotes = {};
+    for (const [month, value] of Object.entries(payload.data.months)) {
+        dayNotes[month] = {};
+        for (const [day, dayVal] of Object.entries(value.days)) {
+            if (dayVal.note) {
+                dayNotes[month][day] = dayVal.note;
+            }
+        }
+    }
+    return dayNotes;
+}
+
+// mapDayLocations pulls each day's location id out of a year payload.
+function mapDayLocations(payload) {
+    let dayLocations = {};
+    for (const [month, value] of Object.entries(payload.data.months)) {
+        dayLocations[month] = {};
+        for (const [day, dayVal] of Object.entries(value.days)) {
+            if (dayVal.location_id) {
+                dayLocations[month][day] = dayVal.location_id;
+            }
+        }
+    }
+    return dayLocations;
+}
+
+function locationName(id) {
+    const location = locations.find(location => location.id === id);
+    return location ? location.name : "";
+}
+
 function mapNotes(payload) {
     let notes = {};
     for (const [key, value] of Object.entries(payload.data)) {
@@ -391,6 +892,19 @@ function mapNotes(payload) {
     return notes;
 }
 
+// mondayOf returns the Monday starting the week d falls in.
+function mondayOf(d) {
+    return new Date(d.getFullYear(), d.getMonth(), d.getDate() - (d.getDay() + 6) % 7);
+}
+
+// isoWeek labels the ISO week d falls in, e.g. "2024-W10" (mirrors
+// util.ISOWeekLabel in Go). The week belongs to the year its Thursday is in.
+function isoWeek(d) {
+    const thursday = new Date(d.getFullYear(), d.getMonth(), d.getDate() + 3 - (d.getDay() + 6) % 7);
+    const days = Math.round((thursday - new Date(thursday.getFullYear(), 0, 1)) / 86400000);
+    return thursday.getFullYear() + "-W" + String(Math.floor(days / 7) + 1).padStart(2, "0");
+}
+
 function formatDate(year, month) { return year + "-" + (month + 1).toString().padStart(2, "0"); }
 
 function calculateRunningTotal(currState, month, year, upToDay) {
@@ -400,14 +914,11 @@ function calculateRunningTotal(currState, month, year, upToDay) {
     // Calculate for all days in the month up to and including the specified day
     for (let day = 1; day <= upToDay; day++) {
         if (month + 1 in currState && day in currState[month + 1]) {
-            let state = currState[month + 1][day];
-            // States 2 and 5 are office days (actual and scheduled)
-            if (state === 2 || state === 5) {
+            let attendance = attendanceFor(currState[month + 1][day]);
+            if (attendance === "present") {
                 presentDays++;
                 totalWorkDays++;
-            }
-            // States 1 and 4 are WFH days (actual and scheduled)
-            else if (state === 1 || state === 4) {
+            } else if (attendance === "absent") {
                 totalWorkDays++;
             }
         }
@@ -438,14 +949,11 @@ function calculateAllTimeTotal(currState, currentMonth, upToDay) {
                 continue;
             }
 
-            let state = days[day];
-            // States 2 and 5 are office days (actual and scheduled)
-            if (state === 2 || state === 5) {
+            let attendance = attendanceFor(days[day]);
+            if (attendance === "present") {
                 presentDays++;
                 totalWorkDays++;
-            }
-            // States 1 and 4 are WFH days (actual and scheduled)
-            else if (state === 1 || state === 4) {
+            } else if (attendance === "absent") {
                 totalWorkDays++;
             }
         }
@@ -460,7 +968,7 @@ function calculateAllTimeTotal(currState, currentMonth, upToDay) {
     return { presentDays, totalWorkDays, percentage };
 }
 
-function showTooltip(event, currState, month, year, day) {
+function showTooltip(event, currState, month, year, day, note, location) {
     // Remove any existing tooltip
     hideTooltip();
 
@@ -473,6 +981,16 @@ function showTooltip(event, currState, month, year, day) {
     tooltip.innerHTML = `<strong>Through ${monthNames[month]} ${day}:</strong><br>` +
         `Month: ${monthTotal.presentDays}/${monthTotal.totalWorkDays} days (${monthTotal.percentage}%)<br>` +
         `Year: ${allTimeTotal.presentDays}/${allTimeTotal.totalWorkDays} days (${allTimeTotal.percentage}%)`;
+    if (note) {
+        let noteLine = document.createElement('div');
+        noteLine.textContent = "Note: " + note;
+        tooltip.appendChild(noteLine);
+    }
+    if (location) {
+        let locationLine = document.createElement('div');
+        locationLine.textContent = "Location: " + location;
+        tooltip.appendChild(locationLine);
+    }
 
     document.body.appendChild(tooltip);
 
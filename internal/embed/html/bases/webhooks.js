@@ -0,0 +1,153 @@
+// Manages the user's webhooks and shows each one's delivery log. Uses el from
+// docs.js.
+const webhookForm = document.getElementById("webhook-form");
+const webhookURL = document.getElementById("webhook-url");
+const webhookError = document.getElementById("webhook-error");
+const webhookSecret = document.getElementById("webhook-secret");
+const webhookSecretValue = document.getElementById("webhook-secret-value");
+const webhooksList = document.getElementById("webhooks-list");
+
+loadWebhooks();
+
+webhookForm.addEventListener("submit", async (event) => {
+    event.preventDefault();
+    const events = [...webhookForm.querySelectorAll("input[name=event]:checked")].map(input => input.value);
+    try {
+        const created = await webhookRequest("POST", "/api/v1/developer/webhooks", { url: webhookURL.value, events });
+        webhookSecretValue.textContent = created.data.secret;
+        webhookSecret.hidden = false;
+        webhookURL.value = "";
+        loadWebhooks();
+    } catch (error) {
+        showWebhookError(error);
+    }
+});
+
+// webhookRequest sends a request to the webhooks API, throwing the API's
+// error message if it fails.
+async function webhookRequest(method, path, data) {
+    webhookError.hidden = true;
+    const init = { method, headers: {} };
+    if (data !== undefined) {
+        init.headers["Content-Type"] = "application/json";
+        init.body = JSON.stringify({ data });
+    }
+    const response = await fetch(path, init);
+    const body = await response.json().catch(() => ({}));
+    if (!response.ok) {
+        const reasons = (body.errors || []).map(e => e.reason);
+        throw new Error(reasons.length ? reasons.join("; ") : body.message || `status ${response.status}`);
+    }
+    return body;
+}
+
+function showWebhookError(error) {
+    webhookError.textContent = error.message;
+    webhookError.hidden = false;
+}
+
+async function loadWebhooks() {
+    try {
+        const body = await webhookRequest("GET", "/api/v1/developer/webhooks");
+        renderWebhooks(body.data || []);
+    } catch (error) {
+        webhooksList.innerHTML = "";
+        webhooksList.appendChild(el("p", "api-error", `Failed to load webhooks: ${error.message}`));
+    }
+}
+
+function renderWebhooks(webhooks) {
+    webhooksList.innerHTML = "";
+    if (webhooks.length === 0) {
+        webhooksList.appendChild(el("p", "", "No webhooks yet."));
+        return;
+    }
+    for (const webhook of webhooks) {
+        webhooksList.appendChild(renderWebhook(webhook));
+    }
+}
+
+function renderWebhook(webhook) {
+    const details = el("details", webhook.disabled ? "webhook disabled" : "webhook");
+    const summary = el("summary");
+    summary.appendChild(el("span", "webhook-url", webhook.url));
+    summary.appendChild(el("span", "webhook-events", webhook.events.join(", ")));
+    details.appendChild(summary);
+
+    const body = el("div", "webhook-body");
+    const toggle = el("button", "", webhook.disabled ? "Enable" : "Disable");
+    toggle.addEventListener("click", () => updateWebhook(webhook, { ...webhook, disabled: !webhook.disabled }));
+    const remove = el("button", "", "Delete");
+    remove.addEventListener("click", () => deleteWebhook(webhook));
+    const refresh = el("button", "", "Refresh log");
+    const log = el("div");
+    refresh.addEventListener("click", () => loadDeliveries(webhook, log));
+    body.append(toggle, remove, refresh, log);
+    details.appendChild(body);
+
+    // Load the log the first time the webhook is opened.
+    details.addEventListener("toggle", () => {
+        if (details.open && !log.hasChildNodes()) {
+            loadDeliveries(webhook, log);
+        }
+    });
+    return details;
+}
+
+async function updateWebhook(webhook, data) {
+    try {
+        await webhookRequest("PUT", `/api/v1/developer/webhooks/${webhook.id}`, data);
+        loadWebhooks();
+    } catch (error) {
+        showWebhookError(error);
+    }
+}
+
+async function deleteWebhook(webhook) {
+    if (!confirm(`Delete the webhook for ${webhook.url}? Its delivery log is deleted too.`)) {
+        return;
+    }
+    try {
+        await webhookRequest("DELETE", `/api/v1/developer/webhooks/${webhook.id}`);
+        loadWebhooks();
+    } catch (error) {
+        showWebhookError(error);
+    }
+}
+
+async function loadDeliveries(webhook, log) {
+    log.innerHTML = "";
+    try {
+        const body = await webhookRequest("GET", `/api/v1/developer/webhooks/${webhook.id}/deliveries`);
+        log.appendChild(renderDeliveries(body.data || []));
+    } catch (error) {
+        log.appendChild(el("p", "api-error", `Failed to load deliveries: ${error.message}`));
+    }
+}
+
+function renderDeliveries(deliveries) {
+    if (deliveries.length === 0) {
+        return el("p", "", "Nothing sent yet.");
+    }
+    const table = el("table", "webhook-log");
+    const head = el("tr");
+    for (const heading of ["Queued", "Event", "Status", "Attempts", "Last response"]) {
+        head.appendChild(el("th", "", heading));
+    }
+    table.appendChild(head);
+    for (const delivery of deliveries) {
+        const row = el("tr");
+        row.appendChild(el("td", "", new Date(delivery.created_at).toLocaleString()));
+        row.appendChild(el("td", "", delivery.event));
+        let status = delivery.status;
+        if (delivery.attempts > 0 && delivery.next_attempt_at) {
+            status += `, retrying ${new Date(delivery.next_attempt_at).toLocaleTimeString()}`;
+        }
+        row.appendChild(el("td", delivery.status, status));
+        row.appendChild(el("td", "", String(delivery.attempts)));
+        const response = [delivery.response_code, delivery.error].filter(Boolean).join(" ");
+        row.appendChild(el("td", "", response));
+        table.appendChild(row);
+    }
+    return table;
+}
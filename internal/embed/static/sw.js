@@ -0,0 +1,68 @@
+// Service worker for officetracker push notifications. The server sends a JSON
+// message with a title, body, the page to open when it's clicked and a tag so a
+// newer reminder replaces an older one.
+
+self.addEventListener('push', event => {
+    let message = {};
+    try {
+        message = event.data ? event.data.json() : {};
+    } catch (err) {
+        message = { body: event.data.text() };
+    }
+    event.waitUntil(self.registration.showNotification(message.title || 'officetracker', {
+        body: message.body || '',
+        tag: message.tag,
+        icon: '/static/office-building.png',
+        data: { url: message.url || '/' }
+    }));
+});
+
+self.addEventListener('notificationclick', event => {
+    event.notification.close();
+    const url = event.notification.data && event.notification.data.url || '/';
+    event.waitUntil(clients.matchAll({ type: 'window', includeUncontrolled: true }).then(windows => {
+        for (const client of windows) {
+            if (client.url === url && 'focus' in client) {
+                return client.focus();
+            }
+        }
+        return clients.openWindow(url);
+    }));
+});
+
+// Browsers rotate subscriptions now and then. Subscribe again with the same
+// key and hand the new one to the server; the old one is deleted the next time
+// a notification to it fails.
+self.addEventListener('pushsubscriptionchange', event => {
+    const options = event.oldSubscription && event.oldSubscription.options;
+    if (!options) {
+        return;
+    }
+    event.waitUntil(self.registration.pushManager.subscribe(options).then(subscription => {
+        const json = subscription.toJSON();
+        return fetch('/api/v1/settings/push/subscriptions', {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json',
+            },
+            body: JSON.stringify({ data: {
+                endpoint: json.endpoint,
+                keys: json.keys,
+                device: deviceName(),
+                expires_at: json.expirationTime ? new Date(json.expirationTime).toISOString() : undefined
+            }}),
+            credentials: "include"
+        });
+    }));
+});
+
+// deviceName describes the browser well enough to tell subscriptions apart in
+// settings.
+function deviceName() {
+    const ua = navigator.userAgent;
+    const browser = /Edg\//.test(ua) ? 'Edge' : /Firefox\//.test(ua) ? 'Firefox' :
+        /Chrome\//.test(ua) ? 'Chrome' : /Safari\//.test(ua) ? 'Safari' : 'Browser';
+    const platform = /Android/.test(ua) ? 'Android' : /iPhone|iPad/.test(ua) ? 'iOS' :
+        /Mac OS X/.test(ua) ? 'macOS' : /Windows/.test(ua) ? 'Windows' : /Linux/.test(ua) ? 'Linux' : '';
+    return platform ? browser + ' on ' + platform : browser;
+}
@@ -19,6 +19,8 @@ var (
 	Suspended = template.Must(template.ParseFS(templates, "html/bases/*", "html/suspended.html"))
 	Error     = template.Must(template.ParseFS(templates, "html/bases/*", "html/error.html"))
 	Stats     = template.Must(template.ParseFS(templates, "html/bases/*", "html/stats.html"))
+	Developer = template.Must(template.ParseFS(templates, "html/bases/*", "html/developer.html"))
+	ChatLink  = template.Must(template.ParseFS(templates, "html/bases/*", "html/chatlink.html"))
 )
 
 // static files
@@ -35,6 +37,9 @@ var (
 	//go:embed static/skyline.svg
 	SkylineSVG []byte
 
+	//go:embed static/sw.js
+	ServiceWorker []byte
+
 	//go:embed html/setup_old.html
 	Setup []byte
 )
@@ -0,0 +1,274 @@
+package v1
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/baely/officetracker/internal/database"
+	"github.com/baely/officetracker/internal/util"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+// chatLinkTTL is how long a chat user has to open their link.
+const chatLinkTTL = 10 * time.Minute
+
+const chatUsage = `Usage:
+  in [day]       record an office day
+  home [day]     record a home day
+  other [day]    record another day
+  <state> [day]  record one of your custom states
+  clear [day]    clear a day
+  week [day]     show the week
+  unlink         unlink this chat account
+A day is today (the default), yesterday, tomorrow, a weekday this week or YYYY-MM-DD.`
+
+// chatStates are the words that record a built-in state.
+var chatStates = map[string]model.State{
+	"in":     model.StateWorkFromOffice,
+	"office": model.StateWorkFromOffice,
+	"home":   model.StateWorkFromHome,
+	"wfh":    model.StateWorkFromHome,
+	"other":  model.StateOther,
+	"clear":  model.StateUntracked,
+}
+
+var chatWeekdays = map[string]int{
+	"mon": 1, "monday": 1,
+	"tue": 2, "tuesday": 2,
+	"wed": 3, "wednesday": 3,
+	"thu": 4, "thursday": 4,
+	"fri": 5, "friday": 5,
+	"sat": 6, "saturday": 6,
+	"sun": 7, "sunday": 7,
+}
+
+// ChatCommand runs a slash command for the chat user who sent it. A chat user
+// who hasn't linked their account is sent a link to do so. Mistakes in the
+// command are explained in the reply rather than returned as errors.
+func (i *Service) ChatCommand(req model.ChatCommandRequest) (model.ChatCommandResponse, error) {
+	account := req.Meta.Account
+	userID, err := i.db.GetChatUser(account)
+	if errors.Is(err, database.ErrNoChatAccount) {
+		return i.chatLinkReply(account, req.Meta.LinkURL)
+	}
+	if err != nil {
+		return model.ChatCommandResponse{}, fmt.Errorf("failed to get chat user: %w", err)
+	}
+	suspended, err := i.db.IsUserSuspended(userID)
+	if err != nil {
+		return model.ChatCommandResponse{}, fmt.Errorf("failed to check suspension: %w", err)
+	}
+	if suspended {
+		return chatReply("Your Officetracker account is suspended.")
+	}
+
+	words := strings.Fields(req.Text)
+	verb := ""
+	if len(words) > 0 {
+		verb = strings.ToLower(words[0])
+	}
+	switch verb {
+	case "help":
+		return chatReply(chatUsage)
+	case "unlink":
+		if err := i.db.UnlinkChatAccount(account); err != nil && !errors.Is(err, database.ErrNoChatAccount) {
+			return model.ChatCommandResponse{}, fmt.Errorf("failed to unlink chat account: %w", err)
+		}
+		return chatReply("Unlinked this chat account from Officetracker.")
+	}
+
+	loc, err := i.location(userID)
+	if err != nil {
+		return model.ChatCommandResponse{}, err
+	}
+	today := util.Today(loc)
+	customStates, err := i.db.GetCustomStates(userID)
+	if err != nil {
+		return model.ChatCommandResponse{}, fmt.Errorf("failed to get custom states: %w", err)
+	}
+
+	if verb == "" || verb == "week" || verb == "status" {
+		day, ok := chatDay(today, words[min(len(words), 1):])
+		if !ok {
+			return chatReply(fmt.Sprintf("I don't know which day %q is.\n%s", strings.Join(words[1:], " "), chatUsage))
+		}
+		summary, err := i.weekSummary(userID, day, customStates)
+		if err != nil {
+			return model.ChatCommandResponse{}, err
+		}
+		return chatReply(summary)
+	}
+
+	state, rest, ok := chatState(words, customStates)
+	if !ok {
+		return chatReply(fmt.Sprintf("Unknown command %q.\n%s", req.Text, chatUsage))
+	}
+	day, ok := chatDay(today, rest)
+	if !ok {
+		return chatReply(fmt.Sprintf("I don't know which day %q is.\n%s", strings.Join(rest, " "), chatUsage))
+	}
+	_, err = i.PutDay(model.PutDayRequest{
+		Meta: model.PutDayRequestMeta{
+			UserID:     userID,
+			Year:       day.Year(),
+			Month:      int(day.Month()),
+			Day:        day.Day(),
+			AuthMethod: string(account.Platform),
+			Via:        viaChat,
+		},
+		Data: model.DayState{State: state, Source: model.SourceChat},
+	})
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		return chatReply("Couldn't record that: " + verr.Error())
+	}
+	if err != nil {
+		return model.ChatCommandResponse{}, err
+	}
+
+	summary, err := i.weekSummary(userID, day, customStates)
+	if err != nil {
+		return model.ChatCommandResponse{}, err
+	}
+	return chatReply(fmt.Sprintf("Recorded %s as %s.\n%s",
+		day.Format("Mon 2 Jan"), stateName(state, customStates), summary))
+}
+
+// chatLinkReply sends an unlinked chat user a single-use link to connect
+// their account.
+func (i *Service) chatLinkReply(account model.ChatAccount, linkURL string) (model.ChatCommandResponse, error) {
+	code := rand.Text()
+	if err := i.db.SaveChatLink(code, account, time.Now().Add(chatLinkTTL)); err != nil {
+		return model.ChatCommandResponse{}, fmt.Errorf("failed to save chat link: %w", err)
+	}
+	return chatReply(fmt.Sprintf("Link this chat account to Officetracker first: %s?code=%s\nThe link works once and expires in %d minutes.",
+		linkURL, code, int(chatLinkTTL.Minutes())))
+}
+
+// GetChatLink returns the chat account a link code is for, so the user can
+// check it before linking.
+func (i *Service) GetChatLink(req model.GetChatLinkRequest) (model.GetChatLinkResponse, error) {
+	account, err := i.db.GetChatLink(req.Meta.Code, time.Now())
+	if errors.Is(err, database.ErrNoChatLink) {
+		return model.GetChatLinkResponse{}, notFound("link not found or expired")
+	}
+	if err != nil {
+		return model.GetChatLinkResponse{}, fmt.Errorf("failed to get chat link: %w", err)
+	}
+
+	return model.GetChatLinkResponse{
+		Data: account,
+	}, nil
+}
+
+// LinkChatAccount links the chat account a code is for to the user. A chat
+// account already linked to someone else moves to this user.
+func (i *Service) LinkChatAccount(req model.LinkChatAccountRequest) (model.LinkChatAccountResponse, error) {
+	account, err := i.db.LinkChatAccount(req.Meta.UserID, req.Meta.Code, time.Now())
+	if errors.Is(err, database.ErrNoChatLink) {
+		return model.LinkChatAccountResponse{}, notFound("link not found or expired")
+	}
+	if err != nil {
+		return model.LinkChatAccountResponse{}, fmt.Errorf("failed to link chat account: %w", err)
+	}
+
+	return model.LinkChatAccountResponse{
+		Data: account,
+	}, nil
+}
+
+// weekSummary summarises the ISO week containing day, a line per day. Weekends
+// are left out unless something is recorded on them.
+func (i *Service) weekSummary(userID int, day time.Time, customStates model.CustomStates) (string, error) {
+	isoYear, isoWeek := day.ISOWeek()
+	week, err := i.GetWeek(model.GetWeekRequest{
+		Meta: model.GetWeekRequestMeta{UserID: userID, ISOYear: isoYear, Week: isoWeek},
+	})
+	if err != nil {
+		return "", err
+	}
+	start := day.AddDate(0, 0, 1-util.ISOWeekday(day))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Week %s: %d in office", week.Data.Week, week.Data.OfficeDays)
+	for weekday := 1; weekday <= 7; weekday++ {
+		state, ok := week.Data.Days[weekday]
+		if weekday > 5 && !ok {
+			continue
+		}
+		fmt.Fprintf(&b, "\n%s  %s", start.AddDate(0, 0, weekday-1).Format("Mon 2 Jan"), stateName(state.State, customStates))
+	}
+	return b.String(), nil
+}
+
+// chatState reads the state a command records and returns the words after
+// it. Custom state names may be several words, e.g. "client site monday".
+func chatState(words []string, customStates model.CustomStates) (model.State, []string, bool) {
+	if state, ok := chatStates[strings.ToLower(words[0])]; ok {
+		return state, words[1:], true
+	}
+	for n := len(words); n > 0; n-- {
+		name := strings.Join(words[:n], " ")
+		for _, custom := range customStates {
+			if !custom.Archived && strings.EqualFold(custom.Name, name) {
+				return custom.ID, words[n:], true
+			}
+		}
+	}
+	return 0, nil, false
+}
+
+// chatDay reads the day a command is for, relative to today.
+func chatDay(today time.Time, words []string) (time.Time, bool) {
+	if len(words) == 0 {
+		return today, true
+	}
+	if len(words) > 1 {
+		return time.Time{}, false
+	}
+	word := strings.ToLower(words[0])
+	switch word {
+	case "today":
+		return today, true
+	case "yesterday":
+		return today.AddDate(0, 0, -1), true
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), true
+	}
+	if weekday, ok := chatWeekdays[word]; ok {
+		return today.AddDate(0, 0, weekday-util.ISOWeekday(today)), true
+	}
+	day, err := time.ParseInLocation(time.DateOnly, word, today.Location())
+	return day, err == nil
+}
+
+// stateName names a state in chat replies and emails.
+func stateName(state model.State, customStates model.CustomStates) string {
+	if custom, ok := customStates.Get(state); ok {
+		return custom.Name
+	}
+	switch state {
+	case model.StateWorkFromOffice:
+		return "Office"
+	case model.StateWorkFromHome:
+		return "Home"
+	case model.StateOther:
+		return "Other"
+	case model.StateScheduledWorkFromOffice:
+		return "Office (scheduled)"
+	case model.StateScheduledWorkFromHome:
+		return "Home (scheduled)"
+	case model.StateScheduledOther:
+		return "Other (scheduled)"
+	}
+	return "-"
+}
+
+func chatReply(text string) (model.ChatCommandResponse, error) {
+	return model.ChatCommandResponse{
+		Text: text,
+	}, nil
+}
@@ -0,0 +1,158 @@
+package v1
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/baely/officetracker/internal/database/dbtest"
+	"github.com/baely/officetracker/internal/util"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+var slackAccount = model.ChatAccount{Platform: model.ChatPlatformSlack, TeamID: "T1", UserID: "U1", Name: "alex"}
+
+// chat sends a slash command from slackAccount and returns the reply.
+func chat(t *testing.T, svc *Service, text string) string {
+	t.Helper()
+	resp, err := svc.ChatCommand(model.ChatCommandRequest{
+		Meta: model.ChatCommandRequestMeta{Account: slackAccount, LinkURL: "https://ot.example/integrations/chat/link"},
+		Text: text,
+	})
+	if err != nil {
+		t.Fatalf("ChatCommand(%q): %v", text, err)
+	}
+	return resp.Text
+}
+
+// linkCode pulls the code out of a link reply.
+func linkCode(t *testing.T, reply string) string {
+	t.Helper()
+	_, rest, ok := strings.Cut(reply, "https://ot.example/integrations/chat/link?code=")
+	if !ok {
+		t.Fatalf("reply %q has no link", reply)
+	}
+	code, _, _ := strings.Cut(rest, "\n")
+	return code
+}
+
+// An unlinked chat user is sent a single-use link; once it's used their
+// commands act on the linked user.
+func TestChatLinkFlow(t *testing.T) {
+	db := dbtest.New()
+	svc := &Service{db: db}
+
+	code := linkCode(t, chat(t, svc, "in"))
+	if n, _ := db.CountTrackedDays(); n != 0 {
+		t.Fatal("an unlinked chat user recorded a day")
+	}
+
+	got, err := svc.GetChatLink(model.GetChatLinkRequest{Meta: model.GetChatLinkRequestMeta{Code: code}})
+	if err != nil || got.Data != slackAccount {
+		t.Fatalf("GetChatLink = (%+v, %v), want %+v", got.Data, err, slackAccount)
+	}
+	linked, err := svc.LinkChatAccount(model.LinkChatAccountRequest{Meta: model.LinkChatAccountRequestMeta{UserID: 1, Code: code}})
+	if err != nil || linked.Data != slackAccount {
+		t.Fatalf("LinkChatAccount = (%+v, %v), want %+v", linked.Data, err, slackAccount)
+	}
+	_, err = svc.LinkChatAccount(model.LinkChatAccountRequest{Meta: model.LinkChatAccountRequestMeta{UserID: 1, Code: code}})
+	if errCode(err) != model.ErrorCodeNotFound {
+		t.Errorf("reusing a link code = %v, want not found", err)
+	}
+
+	reply := chat(t, svc, "in 2024-05-07")
+	for _, want := range []string{"Recorded Tue 7 May as Office.", "Week 2024-W19: 1 in office", "Mon 6 May  -", "Tue 7 May  Office"} {
+		if !strings.Contains(reply, want) {
+			t.Errorf("reply %q doesn't contain %q", reply, want)
+		}
+	}
+	if strings.Contains(reply, "Sat") {
+		t.Errorf("reply %q lists an empty weekend", reply)
+	}
+	day, _ := db.GetDay(1, 7, 5, 2024)
+	if day.State != model.StateWorkFromOffice || day.Source != model.SourceChat {
+		t.Errorf("day = %+v, want an office day from chat", day)
+	}
+	history, _ := db.GetDayHistory(1, 7, 5, 2024)
+	if len(history) != 1 || history[0].AuthMethod != "slack" || history[0].Via != viaChat {
+		t.Errorf("history = %+v, want one change by slack via chat", history)
+	}
+
+	if reply := chat(t, svc, "unlink"); !strings.Contains(reply, "Unlinked") {
+		t.Errorf("unlink reply = %q", reply)
+	}
+	linkCode(t, chat(t, svc, "week"))
+}
+
+func TestChatCommands(t *testing.T) {
+	db := dbtest.New()
+	db.ChatUsers[model.ChatAccount{Platform: model.ChatPlatformSlack, TeamID: "T1", UserID: "U1"}] = 1
+	site, _ := db.SaveCustomState(1, model.CustomState{Name: "Client site", Attendance: model.AttendancePresent})
+	svc := &Service{db: db}
+
+	chat(t, svc, "HOME 2024-05-06")
+	reply := chat(t, svc, "client site 2024-05-11")
+	if !strings.Contains(reply, "Recorded Sat 11 May as Client site.") || !strings.Contains(reply, "Sat 11 May  Client site") {
+		t.Errorf("custom state reply = %q", reply)
+	}
+	if day, _ := db.GetDay(1, 11, 5, 2024); day.State != site.ID {
+		t.Errorf("day = %+v, want the custom state", day)
+	}
+	if day, _ := db.GetDay(1, 6, 5, 2024); day.State != model.StateWorkFromHome {
+		t.Errorf("day = %+v, want a home day", day)
+	}
+
+	chat(t, svc, "clear 2024-05-06")
+	if day, _ := db.GetDay(1, 6, 5, 2024); day.State != model.StateUntracked {
+		t.Errorf("cleared day = %+v", day)
+	}
+
+	reply = chat(t, svc, "week 2024-05-08")
+	if !strings.HasPrefix(reply, "Week 2024-W19: 1 in office") {
+		t.Errorf("week reply = %q", reply)
+	}
+
+	for text, want := range map[string]string{
+		"dance":          `Unknown command "dance"`,
+		"in someday":     `I don't know which day "someday" is`,
+		"in next monday": `I don't know which day "next monday" is`,
+		"in 2024-02-30":  `I don't know which day "2024-02-30" is`,
+		"help":           "Usage:",
+	} {
+		if reply := chat(t, svc, text); !strings.Contains(reply, want) {
+			t.Errorf("reply to %q = %q, want it to contain %q", text, reply, want)
+		}
+	}
+
+	db.Suspended = true
+	if reply := chat(t, svc, "in"); !strings.Contains(reply, "suspended") {
+		t.Errorf("suspended reply = %q", reply)
+	}
+}
+
+func TestChatDay(t *testing.T) {
+	today := time.Date(2024, 5, 8, 0, 0, 0, 0, util.Location("Australia/Melbourne")) // a Wednesday
+	for words, want := range map[string]string{
+		"":           "2024-05-08",
+		"today":      "2024-05-08",
+		"Yesterday":  "2024-05-07",
+		"tomorrow":   "2024-05-09",
+		"mon":        "2024-05-06",
+		"Friday":     "2024-05-10",
+		"sun":        "2024-05-12",
+		"2024-06-01": "2024-06-01",
+	} {
+		day, ok := chatDay(today, strings.Fields(words))
+		if !ok || day.Format(time.DateOnly) != want {
+			t.Errorf("chatDay(%q) = (%v, %v), want %s", words, day, ok, want)
+		}
+		if ok && day.Location() != today.Location() {
+			t.Errorf("chatDay(%q) is in %v, want %v", words, day.Location(), today.Location())
+		}
+	}
+	for _, words := range []string{"someday", "next week", "2024-13-01"} {
+		if _, ok := chatDay(today, strings.Fields(words)); ok {
+			t.Errorf("chatDay(%q) succeeded", words)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package v1
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/baely/officetracker/pkg/model"
+)
+
+// defaultCheckInRadius is the radius in metres of a location that doesn't set
+// one, the same as the mobile app's.
+const defaultCheckInRadius = 200
+
+// earthRadius is the mean radius of the earth in metres.
+const earthRadius = 6371000
+
+// CheckIn records today as in the office when the check-in matches one of the
+// user's locations, or else one of their network rules. A day the user has
+// already entered is left alone; only untracked days and ones filled in from
+// the schedule but not confirmed are recorded. The day is saved as PutDay
+// saves it, so history, webhooks and check-in confirmations follow as usual.
+func (i *Service) CheckIn(req model.CheckInRequest) (model.CheckInResponse, error) {
+	source := req.Data.Source
+	if source == "" {
+		source = model.SourceGeofence
+	}
+	var v validator
+	if source != model.SourceGeofence && source != model.SourceExtension {
+		v.add("data.source", "must be geofence or extension")
+	}
+	lat, lng := req.Data.Latitude, req.Data.Longitude
+	switch {
+	case (lat == nil) != (lng == nil):
+		v.add("data.latitude", "latitude and longitude must be set together")
+	case lat == nil:
+	case *lat < -90 || *lat > 90:
+		v.add("data.latitude", "latitude %v is out of range", *lat)
+	case *lng < -180 || *lng > 180:
+		v.add("data.longitude", "longitude %v is out of range", *lng)
+	}
+	if err := v.err(); err != nil {
+		return model.CheckInResponse{}, err
+	}
+
+	userID := req.Meta.UserID
+	tz, err := i.location(userID)
+	if err != nil {
+		return model.CheckInResponse{}, err
+	}
+	today := time.Now().In(tz)
+	year, month, day := today.Year(), int(today.Month()), today.Day()
+	result := model.CheckInResult{Date: today.Format(time.DateOnly)}
+
+	locations, err := i.db.GetLocations(userID)
+	if err != nil {
+		err = fmt.Errorf("failed to get locations: %w", err)
+		return model.CheckInResponse{}, err
+	}
+	var location model.Location
+	var matched bool
+	if lat != nil {
+		location, matched = nearestLocation(locations, *lat, *lng)
+	}
+	if !matched {
+		rules, err := i.db.GetNetworkRules(userID)
+		if err != nil {
+			err = fmt.Errorf("failed to get network rules: %w", err)
+			return model.CheckInResponse{}, err
+		}
+		var rule model.NetworkRule
+		if rule, matched = i.matchNetwork(rules, strings.TrimSpace(req.Data.SSID), req.Meta.ClientIP); matched {
+			result.Rule = &rule
+			// Days aren't recorded at an archived location.
+			if l, ok := locations.Get(rule.LocationID); ok && !l.Archived {
+				location = l
+			}
+		}
+	}
+	if location.ID != 0 {
+		result.Location = &location
+	}
+
+	result.Day, err = i.db.GetDay(userID, day, month, year)
+	if err != nil {
+		err = fmt.Errorf("failed to get day: %w", err)
+		return model.CheckInResponse{}, err
+	}
+	switch {
+	case !matched:
+		result.Reason = model.CheckInNotAtOffice
+	case result.Day.State != model.StateUntracked && !result.Day.Unconfirmed:
+		result.Reason = model.CheckInAlreadySet
+	default:
+		_, err = i.PutDay(model.PutDayRequest{
+			Meta: model.PutDayRequestMeta{
+				UserID:      userID,
+				Year:        year,
+				Month:       month,
+				Day:         day,
+				AuthMethod:  req.Meta.AuthMethod,
+				AuthTokenID: req.Meta.AuthTokenID,
+				Via:         req.Meta.Via,
+			},
+			Data: model.DayState{State: model.StateWorkFromOffice, Source: source, LocationID: location.ID},
+		})
+		if err != nil {
+			return model.CheckInResponse{}, err
+		}
+		if result.Day, err = i.db.GetDay(userID, day, month, year); err != nil {
+			err = fmt.Errorf("failed to get day: %w", err)
+			return model.CheckInResponse{}, err
+		}
+		result.Recorded = true
+	}
+
+	return model.CheckInResponse{
+		Data: result,
+	}, nil
+}
+
+// nearestLocation returns the closest active location with coordinates whose
+// radius takes in the point at lat, lng.
+func nearestLocation(locations model.Locations, lat, lng float64) (model.Location, bool) {
+	var nearest model.Location
+	best := math.Inf(1)
+	for _, l := range locations {
+		if l.Archived || l.Latitude == nil || l.Longitude == nil {
+			continue
+		}
+		radius := float64(l.Radius)
+		if radius == 0 {
+			radius = defaultCheckInRadius
+		}
+		if d := distance(lat, lng, *l.Latitude, *l.Longitude); d <= radius && d < best {
+			nearest, best = l, d
+		}
+	}
+	return nearest, !math.IsInf(best, 1)
+}
+
+// distance returns the great-circle distance in metres between two points.
+func distance(lat1, lng1, lat2, lng2 float64) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := toRad(lat2 - lat1)
+	dLng := toRad(lng2 - lng1)
+	h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Pow(math.Sin(dLng/2), 2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
@@ -0,0 +1,85 @@
+package v1
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/baely/officetracker/internal/database/dbtest"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+func TestCheckIn(t *testing.T) {
+	db := dbtest.New()
+	svc := &Service{db: db}
+	melbourne, _ := db.SaveLocation(1, model.Location{Name: "Melbourne", Latitude: ptr(-37.8136), Longitude: ptr(144.9631)})
+	db.SaveLocation(1, model.Location{Name: "Sydney", Latitude: ptr(-33.8688), Longitude: ptr(151.2093), Radius: 500})
+	db.SaveLocation(1, model.Location{Name: "Old office", Latitude: ptr(-37.8136), Longitude: ptr(144.9631), Archived: true})
+
+	checkIn := func(lat, lng float64) model.CheckInResult {
+		t.Helper()
+		resp, err := svc.CheckIn(model.CheckInRequest{
+			Meta: model.CheckInRequestMeta{UserID: 1},
+			Data: model.CheckIn{Latitude: ptr(lat), Longitude: ptr(lng)},
+		})
+		if err != nil {
+			t.Fatalf("CheckIn: %v", err)
+		}
+		return resp.Data
+	}
+
+	// Home in Brunswick, about 5km from the Melbourne office.
+	got := checkIn(-37.7670, 144.9610)
+	if got.Recorded || got.Reason != model.CheckInNotAtOffice || got.Location != nil {
+		t.Errorf("away from the office = %+v", got)
+	}
+
+	// About 100m from the Melbourne office, inside the default radius.
+	got = checkIn(-37.8145, 144.9635)
+	if !got.Recorded || got.Location == nil || got.Location.ID != melbourne.ID {
+		t.Fatalf("at the office = %+v", got)
+	}
+	if got.Day.State != model.StateWorkFromOffice || got.Day.Source != model.SourceGeofence || got.Day.LocationID != melbourne.ID {
+		t.Errorf("recorded day = %+v", got.Day)
+	}
+	date, err := time.Parse(time.DateOnly, got.Date)
+	if err != nil {
+		t.Fatalf("date %q: %v", got.Date, err)
+	}
+	y, m, d := date.Date()
+	if day, _ := db.GetDay(1, d, int(m), y); day.State != model.StateWorkFromOffice {
+		t.Errorf("saved day = %+v", day)
+	}
+
+	// A day the user entered themselves is never overwritten.
+	db.SaveDay(1, d, int(m), y, model.DayState{State: model.StateWorkFromHome, Source: model.SourceManual})
+	got = checkIn(-33.8700, 151.2100)
+	if got.Recorded || got.Reason != model.CheckInAlreadySet || got.Location == nil || got.Location.Name != "Sydney" {
+		t.Errorf("already set = %+v", got)
+	}
+	if got.Day.State != model.StateWorkFromHome {
+		t.Errorf("day = %+v, want it left as work from home", got.Day)
+	}
+
+	// A day filled in from the schedule but not confirmed is fair game.
+	db.SaveDay(1, d, int(m), y, model.DayState{State: model.StateWorkFromHome, Source: model.SourceSchedule, Unconfirmed: true})
+	if got = checkIn(-33.8700, 151.2100); !got.Recorded || got.Day.State != model.StateWorkFromOffice || got.Day.Unconfirmed {
+		t.Errorf("unconfirmed = %+v", got)
+	}
+}
+
+func TestCheckInValidation(t *testing.T) {
+	svc := &Service{db: dbtest.New()}
+	for _, data := range []model.CheckIn{
+		{Latitude: ptr(-37.8)},
+		{Latitude: ptr(91), Longitude: ptr(0)},
+		{Latitude: ptr(0), Longitude: ptr(181)},
+		{Latitude: ptr(0), Longitude: ptr(0), Source: model.SourceManual},
+	} {
+		_, err := svc.CheckIn(model.CheckInRequest{Meta: model.CheckInRequestMeta{UserID: 1}, Data: data})
+		var verr *ValidationError
+		if !errors.As(err, &verr) {
+			t.Errorf("CheckIn(%+v) = %v, want a ValidationError", data, err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package v1
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/baely/officetracker/internal/report"
+	"github.com/baely/officetracker/internal/util"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+func (i *Service) GetCompliance(req model.GetComplianceRequest) (model.GetComplianceResponse, error) {
+	loc, err := i.location(req.Meta.UserID)
+	if err != nil {
+		return model.GetComplianceResponse{}, err
+	}
+	asOf := util.Today(loc)
+	if req.Date != "" {
+		asOf, err = time.ParseInLocation("2006-01-02", req.Date, loc)
+		if err != nil {
+			return model.GetComplianceResponse{}, invalid("date", "invalid date %q, want YYYY-MM-DD", req.Date)
+		}
+	}
+
+	compliance, err := i.compliance(req.Meta.UserID, asOf)
+	if err != nil {
+		return model.GetComplianceResponse{}, err
+	}
+
+	return model.GetComplianceResponse{
+		Data: compliance,
+	}, nil
+}
+
+// compliance measures the user's target over the window containing asOf, or
+// returns nil when no target is set.
+func (i *Service) compliance(userID int, asOf time.Time) (*model.Compliance, error) {
+	prefs, err := i.db.GetTargetPreferences(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get target preferences: %w", err)
+	}
+	if !prefs.IsSet() {
+		return nil, nil
+	}
+
+	startMonth, err := i.trackingStartMonth(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	customStates, err := i.db.GetCustomStates(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get custom states: %w", err)
+	}
+
+	start, end := report.TargetWindow(prefs, startMonth, asOf)
+	state, err := i.windowStates(userID, startMonth, start, end)
+	if err != nil {
+		return nil, err
+	}
+	c := report.Compliance(prefs, customStates, start, end, asOf, state)
+	return &c, nil
+}
+
+// windowStates loads the days in [start, end) the way GetYear serves them,
+// with the schedule filled in, and returns a lookup by date.
+func (i *Service) windowStates(userID, startMonth int, start, end time.Time) (func(time.Time) model.DayState, error) {
+	// Load each tracking year the window touches; there are at most two.
+	years := make(map[int]model.YearState)
+	for month := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, start.Location()); month.Before(end); month = month.AddDate(0, 1, 0) {
+		year := util.TrackingYear(int(month.Month()), month.Year(), startMonth)
+		if _, ok := years[year]; ok {
+			continue
+		}
+		resp, err := i.GetYear(model.GetYearRequest{
+			Meta: model.GetYearRequestMeta{UserID: userID, Year: year},
+		})
+		if err != nil {
+			return nil, err
+		}
+		years[year] = resp.Data
+	}
+
+	return func(day time.Time) model.DayState {
+		year := util.TrackingYear(int(day.Month()), day.Year(), startMonth)
+		return years[year].Months[int(day.Month())].Days[day.Day()]
+	}, nil
+}
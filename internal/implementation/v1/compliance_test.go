@@ -0,0 +1,154 @@
+package v1
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/baely/officetracker/internal/database/dbtest"
+	"github.com/baely/officetracker/internal/report"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+// Without a target there is nothing to measure.
+func TestGetComplianceNoTarget(t *testing.T) {
+	svc := &Service{db: dbtest.New()}
+	resp, err := svc.GetCompliance(model.GetComplianceRequest{Meta: model.GetComplianceRequestMeta{UserID: 1}})
+	if err != nil {
+		t.Fatalf("GetCompliance: %v", err)
+	}
+	if resp.Data != nil {
+		t.Errorf("compliance = %+v, want nil", resp.Data)
+	}
+}
+
+func TestGetCompliance(t *testing.T) {
+	db := dbtest.New()
+	for _, d := range []int{4, 5, 6} {
+		db.SaveDay(1, d, 3, 2024, model.DayState{State: model.StateWorkFromOffice})
+	}
+	db.SaveDay(1, 7, 3, 2024, model.DayState{State: model.StateWorkFromHome})
+	db.SaveTargetPreferences(1, model.TargetPreferences{
+		Policy: model.TargetPolicyDaysPerWeek, TargetDays: 2, Window: model.TargetWindowRollingWeeks, WindowWeeks: 4,
+	})
+	svc := &Service{db: db}
+
+	resp, err := svc.GetCompliance(model.GetComplianceRequest{Meta: model.GetComplianceRequestMeta{UserID: 1}, Date: "2024-03-15"})
+	if err != nil {
+		t.Fatalf("GetCompliance: %v", err)
+	}
+	c := resp.Data
+	if c == nil {
+		t.Fatal("compliance = nil, want a result")
+	}
+	if c.Start != "2024-02-17" || c.End != "2024-03-15" || c.Present != 3 || c.Total != 4 || c.Required != 8 || c.Remaining != 5 || c.Met {
+		t.Errorf("compliance = %+v", c)
+	}
+
+	if _, err := svc.GetCompliance(model.GetComplianceRequest{Meta: model.GetComplianceRequestMeta{UserID: 1}, Date: "15/03/2024"}); err == nil {
+		t.Error("expected an error for a malformed date")
+	}
+}
+
+// A rolling window can straddle the start of a tracking year; both years are
+// read.
+func TestGetComplianceAcrossTrackingYears(t *testing.T) {
+	db := dbtest.New()
+	db.SaveDay(1, 30, 9, 2024, model.DayState{State: model.StateWorkFromOffice})
+	db.SaveDay(1, 1, 10, 2024, model.DayState{State: model.StateWorkFromOffice})
+	db.SaveTargetPreferences(1, model.TargetPreferences{
+		Policy: model.TargetPolicyDaysPerWeek, TargetDays: 1, Window: model.TargetWindowRollingWeeks, WindowWeeks: 2,
+	})
+	svc := &Service{db: db}
+
+	resp, err := svc.GetCompliance(model.GetComplianceRequest{Meta: model.GetComplianceRequestMeta{UserID: 1}, Date: "2024-10-10"})
+	if err != nil {
+		t.Fatalf("GetCompliance: %v", err)
+	}
+	if resp.Data == nil || resp.Data.Present != 2 || !resp.Data.Met {
+		t.Errorf("compliance = %+v, want 2 present and met", resp.Data)
+	}
+}
+
+// Unknown policies and windows are rejected rather than silently replaced;
+// a percent-only body still sets a monthly percentage target.
+func TestUpdateTargetPreferences(t *testing.T) {
+	db := dbtest.New()
+	svc := &Service{db: db}
+	meta := model.UpdateTargetPreferencesRequestMeta{UserID: 1}
+
+	for _, prefs := range []model.TargetPreferences{
+		{Policy: "hours_per_week", TargetDays: 3},
+		{Policy: model.TargetPolicyDaysPerWeek, TargetDays: 3, Window: "fortnight"},
+	} {
+		if _, err := svc.UpdateTargetPreferences(model.UpdateTargetPreferencesRequest{Meta: meta, Data: prefs}); err == nil {
+			t.Errorf("UpdateTargetPreferences(%+v) succeeded, want an error", prefs)
+		}
+	}
+
+	if _, err := svc.UpdateTargetPreferences(model.UpdateTargetPreferencesRequest{Meta: meta, Data: model.TargetPreferences{TargetPercent: 60}}); err != nil {
+		t.Fatalf("UpdateTargetPreferences: %v", err)
+	}
+	stored, _ := db.GetTargetPreferences(1)
+	want := model.TargetPreferences{TargetPercent: 60, Policy: model.TargetPolicyPercent, Window: model.TargetWindowMonth}
+	if stored != want {
+		t.Errorf("stored = %+v, want %+v", stored, want)
+	}
+}
+
+func TestMcpGetCompliance(t *testing.T) {
+	db := dbtest.New()
+	svc := &Service{db: db}
+
+	_, out, err := svc.McpGetCompliance(ctxWithUser(1), nil, &model.McpGetComplianceRequest{Date: "2024-03-15"})
+	if err != nil {
+		t.Fatalf("McpGetCompliance: %v", err)
+	}
+	if out.Compliance != nil || out.Summary != "No attendance target is set." {
+		t.Errorf("without a target = %+v", out)
+	}
+
+	db.SaveDay(1, 4, 3, 2024, model.DayState{State: model.StateWorkFromOffice})
+	db.SaveTargetPreferences(1, model.TargetPreferences{Policy: model.TargetPolicyDaysPerMonth, TargetDays: 8, Window: model.TargetWindowMonth})
+	_, out, err = svc.McpGetCompliance(ctxWithUser(1), nil, &model.McpGetComplianceRequest{Date: "2024-03-15"})
+	if err != nil {
+		t.Fatalf("McpGetCompliance: %v", err)
+	}
+	if out.Compliance == nil || !strings.HasPrefix(out.Summary, "Target: 8 days per month. 2024-03-01 to 2024-03-31: ") {
+		t.Errorf("with a target = %+v", out)
+	}
+}
+
+// pdfReporter records the compliance each PDF report is generated with.
+type pdfReporter struct {
+	report.Reporter
+	compliance []*model.Compliance
+}
+
+func (r *pdfReporter) GeneratePDF(_ int, _ string, _, _ time.Time, _ report.Filter, compliance *model.Compliance) ([]byte, error) {
+	r.compliance = append(r.compliance, compliance)
+	return []byte("%PDF"), nil
+}
+
+// A report measures the target as of the end of a finished year, and a year
+// that hasn't started has no target progress to report.
+func TestGetReportCompliance(t *testing.T) {
+	db := dbtest.New()
+	db.SaveTargetPreferences(1, model.TargetPreferences{Policy: model.TargetPolicyDaysPerMonth, TargetDays: 8, Window: model.TargetWindowMonth})
+	reporter := &pdfReporter{}
+	svc := &Service{db: db, reporter: reporter}
+
+	next := time.Now().Year() + 2
+	for _, year := range []int{2024, next} {
+		if _, err := svc.GetReport(model.GetReportRequest{Meta: model.GetReportRequestMeta{UserID: 1, Year: year}}); err != nil {
+			t.Fatalf("GetReport(%d): %v", year, err)
+		}
+	}
+	// Tracking years start in October by default, so 2024's ends in September.
+	if c := reporter.compliance[0]; c == nil || c.End != "2024-09-30" {
+		t.Errorf("2024 compliance = %+v, want September 2024's", c)
+	}
+	if c := reporter.compliance[1]; c != nil {
+		t.Errorf("%d compliance = %+v, want none", next, c)
+	}
+}
@@ -0,0 +1,131 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/baely/officetracker/internal/database"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+var customStateColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+func (i *Service) ListCustomStates(req model.ListCustomStatesRequest) (model.ListCustomStatesResponse, error) {
+	states, err := i.db.GetCustomStates(req.Meta.UserID)
+	if err != nil {
+		err = fmt.Errorf("failed to get custom states: %w", err)
+		return model.ListCustomStatesResponse{}, err
+	}
+
+	return model.ListCustomStatesResponse{
+		Data: states,
+	}, nil
+}
+
+func (i *Service) CreateCustomState(req model.CreateCustomStateRequest) (model.CreateCustomStateResponse, error) {
+	state, err := validateCustomState(req.Data)
+	if err != nil {
+		return model.CreateCustomStateResponse{}, err
+	}
+	state.ID = 0
+	if err = i.checkCustomStateName(req.Meta.UserID, state); err != nil {
+		return model.CreateCustomStateResponse{}, err
+	}
+
+	state, err = i.db.SaveCustomState(req.Meta.UserID, state)
+	if err != nil {
+		err = fmt.Errorf("failed to save custom state: %w", err)
+		return model.CreateCustomStateResponse{}, err
+	}
+
+	return model.CreateCustomStateResponse{
+		Data: state,
+	}, nil
+}
+
+func (i *Service) UpdateCustomState(req model.UpdateCustomStateRequest) (model.UpdateCustomStateResponse, error) {
+	// Saving with ID 0 would create a state rather than update one.
+	if req.Meta.StateID <= 0 {
+		return model.UpdateCustomStateResponse{}, notFound("custom state %d not found", req.Meta.StateID)
+	}
+	state, err := validateCustomState(req.Data)
+	if err != nil {
+		return model.UpdateCustomStateResponse{}, err
+	}
+	state.ID = model.State(req.Meta.StateID)
+	if err = i.checkCustomStateName(req.Meta.UserID, state); err != nil {
+		return model.UpdateCustomStateResponse{}, err
+	}
+
+	state, err = i.db.SaveCustomState(req.Meta.UserID, state)
+	if errors.Is(err, database.ErrNoCustomState) {
+		return model.UpdateCustomStateResponse{}, notFound("custom state %d not found", req.Meta.StateID)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to save custom state: %w", err)
+		return model.UpdateCustomStateResponse{}, err
+	}
+
+	return model.UpdateCustomStateResponse{
+		Data: state,
+	}, nil
+}
+
+// DeleteCustomState archives the state rather than removing it, so days that
+// already use it keep their name, colour and attendance in reports.
+func (i *Service) DeleteCustomState(req model.DeleteCustomStateRequest) (model.DeleteCustomStateResponse, error) {
+	states, err := i.db.GetCustomStates(req.Meta.UserID)
+	if err != nil {
+		err = fmt.Errorf("failed to get custom states: %w", err)
+		return model.DeleteCustomStateResponse{}, err
+	}
+
+	state, ok := states.Get(model.State(req.Meta.StateID))
+	if !ok {
+		return model.DeleteCustomStateResponse{}, notFound("custom state %d not found", req.Meta.StateID)
+	}
+	state.Archived = true
+
+	if _, err = i.db.SaveCustomState(req.Meta.UserID, state); err != nil {
+		err = fmt.Errorf("failed to save custom state: %w", err)
+		return model.DeleteCustomStateResponse{}, err
+	}
+
+	return model.DeleteCustomStateResponse{}, nil
+}
+
+// validateCustomState trims the name and checks the fields a client supplies.
+func validateCustomState(state model.CustomState) (model.CustomState, error) {
+	state.Name = strings.TrimSpace(state.Name)
+	if state.Name == "" {
+		return model.CustomState{}, invalid("data.name", "custom state name is required")
+	}
+	if !customStateColor.MatchString(state.Color) {
+		return model.CustomState{}, invalid("data.color", "custom state color %q must be of the form #RRGGBB", state.Color)
+	}
+	if !state.Attendance.Valid() {
+		return model.CustomState{}, invalid("data.attendance", "unknown attendance %q", state.Attendance)
+	}
+	return state, nil
+}
+
+// checkCustomStateName rejects a name another of the user's active states
+// already has, ignoring case, so the two can't be told apart on the calendar.
+// A state being updated must exist.
+func (i *Service) checkCustomStateName(userID int, state model.CustomState) error {
+	states, err := i.db.GetCustomStates(userID)
+	if err != nil {
+		return fmt.Errorf("failed to get custom states: %w", err)
+	}
+	if _, ok := states.Get(state.ID); state.ID != 0 && !ok {
+		return notFound("custom state %d not found", state.ID)
+	}
+	for _, other := range states {
+		if other.ID != state.ID && !other.Archived && strings.EqualFold(other.Name, state.Name) {
+			return conflict("a custom state named %q already exists", other.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package v1
+
+import (
+	"testing"
+
+	"github.com/baely/officetracker/internal/database/dbtest"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+// Created states get IDs from CustomStateBase upwards and show up in the list
+// and in settings.
+func TestCreateCustomState(t *testing.T) {
+	db := dbtest.New()
+	svc := &Service{db: db}
+
+	first, err := svc.CreateCustomState(model.CreateCustomStateRequest{
+		Meta: model.CreateCustomStateRequestMeta{UserID: 1},
+		Data: model.CustomState{ID: 7, Name: " Client site ", Color: "#ff9800", Attendance: model.AttendancePresent},
+	})
+	if err != nil {
+		t.Fatalf("CreateCustomState: %v", err)
+	}
+	if first.Data.ID != model.CustomStateBase || first.Data.Name != "Client site" {
+		t.Errorf("created = %+v, want ID %d and trimmed name", first.Data, model.CustomStateBase)
+	}
+	second, err := svc.CreateCustomState(model.CreateCustomStateRequest{
+		Meta: model.CreateCustomStateRequestMeta{UserID: 1},
+		Data: model.CustomState{Name: "Leave", Color: "#9E9E9E", Attendance: model.AttendanceExcluded},
+	})
+	if err != nil {
+		t.Fatalf("CreateCustomState: %v", err)
+	}
+	if second.Data.ID != model.CustomStateBase+1 {
+		t.Errorf("second ID = %d, want %d", second.Data.ID, model.CustomStateBase+1)
+	}
+
+	list, err := svc.ListCustomStates(model.ListCustomStatesRequest{Meta: model.ListCustomStatesRequestMeta{UserID: 1}})
+	if err != nil || len(list.Data) != 2 {
+		t.Fatalf("ListCustomStates = (%+v, %v), want 2 states", list.Data, err)
+	}
+	settings, err := svc.GetSettings(model.GetSettingsRequest{Meta: model.GetSettingsRequestMeta{UserID: 1}})
+	if err != nil || len(settings.CustomStates) != 2 {
+		t.Errorf("GetSettings custom states = (%+v, %v), want 2", settings.CustomStates, err)
+	}
+}
+
+func TestCreateCustomStateValidation(t *testing.T) {
+	cases := map[string]model.CustomState{
+		"bad color":      {Name: "Travel", Color: "orange", Attendance: model.AttendancePresent},
+		"bad attendance": {Name: "Travel", Color: "#ffffff", Attendance: "sometimes"},
+	}
+	for name, state := range cases {
+		db := dbtest.New()
+		svc := &Service{db: db}
+		_, err := svc.CreateCustomState(model.CreateCustomStateRequest{Data: state})
+		if err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+		if states, _ := db.GetCustomStates(0); len(states) != 0 {
+			t.Errorf("%s: invalid state was saved", name)
+		}
+	}
+}
+
+func TestUpdateCustomState(t *testing.T) {
+	db := dbtest.New()
+	created, _ := db.SaveCustomState(1, model.CustomState{Name: "Travel", Color: "#000000", Attendance: model.AttendanceAbsent})
+	svc := &Service{db: db}
+
+	resp, err := svc.UpdateCustomState(model.UpdateCustomStateRequest{
+		Meta: model.UpdateCustomStateRequestMeta{UserID: 1, StateID: int(created.ID)},
+		Data: model.CustomState{Name: "Travel", Color: "#123456", Attendance: model.AttendancePresent},
+	})
+	if err != nil {
+		t.Fatalf("UpdateCustomState: %v", err)
+	}
+	if resp.Data.ID != created.ID || resp.Data.Attendance != model.AttendancePresent {
+		t.Errorf("updated = %+v", resp.Data)
+	}
+}
+
+// Deleting archives: the state keeps its definition so existing days still
+// resolve, but it is flagged as archived.
+func TestDeleteCustomStateArchives(t *testing.T) {
+	db := dbtest.New()
+	created, _ := db.SaveCustomState(1, model.CustomState{Name: "Travel", Color: "#000000", Attendance: model.AttendanceAbsent})
+	svc := &Service{db: db}
+
+	if _, err := svc.DeleteCustomState(model.DeleteCustomStateRequest{
+		Meta: model.DeleteCustomStateRequestMeta{UserID: 1, StateID: int(created.ID)},
+	}); err != nil {
+		t.Fatalf("DeleteCustomState: %v", err)
+	}
+	states, _ := db.GetCustomStates(1)
+	if got, ok := states.Get(created.ID); !ok || !got.Archived {
+		t.Errorf("state after delete = (%+v, %v), want archived", got, ok)
+	}
+
+	if _, err := svc.DeleteCustomState(model.DeleteCustomStateRequest{
+		Meta: model.DeleteCustomStateRequestMeta{UserID: 1, StateID: 999},
+	}); errCode(err) != model.ErrorCodeNotFound {
+		t.Errorf("deleting an unknown state = %v, want not found", err)
+	}
+}
+
+// Writes may use defined custom states, including archived ones, but not
+// undefined ones.
+func TestPutDayCustomState(t *testing.T) {
+	db := dbtest.New()
+	created, _ := db.SaveCustomState(1, model.CustomState{Name: "Travel", Color: "#000000", Attendance: model.AttendanceAbsent, Archived: true})
+	svc := &Service{db: db}
+
+	put := func(state model.State) error {
+		_, err := svc.PutDay(model.PutDayRequest{
+			Meta: model.PutDayRequestMeta{UserID: 1, Year: 2024, Month: 3, Day: 5},
+			Data: model.DayState{State: state},
+		})
+		return err
+	}
+	if err := put(created.ID); err != nil {
+		t.Errorf("PutDay with a defined custom state: %v", err)
+	}
+	if err := put(created.ID + 1); err == nil {
+		t.Error("PutDay should reject an undefined custom state")
+	}
+
+	_, err := svc.PutMonth(model.PutMonthRequest{
+		Meta: model.PutMonthRequestMeta{UserID: 1, Year: 2024, Month: 3},
+		Data: model.MonthState{Days: map[int]model.DayState{1: {State: model.StateWorkFromOffice}, 2: {State: 150}}},
+	})
+	if err == nil {
+		t.Error("PutMonth should reject an undefined custom state")
+	}
+}
@@ -1,18 +1,19 @@
 package v1
 
 import (
-	"fmt"
+	"errors"
 	"strings"
 	"time"
 
 	"github.com/baely/officetracker/internal/auth"
+	"github.com/baely/officetracker/internal/database"
 	"github.com/baely/officetracker/pkg/model"
 )
 
 func (i *Service) PostSecret(req model.PostSecretRequest) (model.PostSecretResponse, error) {
 	name := strings.TrimSpace(req.Data.Name)
 	if name == "" {
-		return model.PostSecretResponse{}, fmt.Errorf("token name cannot be empty")
+		return model.PostSecretResponse{}, invalid("data.name", "token name cannot be empty")
 	}
 
 	secret := auth.GenerateSecret()
@@ -48,6 +49,9 @@ func (i *Service) ListTokens(req model.ListTokensRequest) (model.ListTokensRespo
 
 func (i *Service) RevokeToken(req model.RevokeTokenRequest) (model.RevokeTokenResponse, error) {
 	err := i.db.RevokeToken(req.Meta.UserID, req.Meta.TokenID)
+	if errors.Is(err, database.ErrNoToken) {
+		return model.RevokeTokenResponse{Success: false}, notFound("token %d not found", req.Meta.TokenID)
+	}
 	if err != nil {
 		return model.RevokeTokenResponse{Success: false}, err
 	}
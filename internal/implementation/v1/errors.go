@@ -0,0 +1,27 @@
+package v1
+
+import (
+	"fmt"
+
+	"github.com/baely/officetracker/pkg/model"
+)
+
+// Error is a failure the caller can act on, such as naming something that
+// doesn't exist. The server reports it with the status for its code and its
+// message as is. Any other error is reported as an internal error.
+type Error struct {
+	Code    model.ErrorCode
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func notFound(format string, args ...any) error {
+	return &Error{Code: model.ErrorCodeNotFound, Message: fmt.Sprintf(format, args...)}
+}
+
+func conflict(format string, args ...any) error {
+	return &Error{Code: model.ErrorCodeConflict, Message: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,135 @@
+package v1
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/baely/officetracker/internal/report"
+	"github.com/baely/officetracker/internal/util"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+// GetForecast projects the user's target window to its end. Days up to Date
+// count as they were recorded; later days count as scheduled or entered ahead
+// of time, with untracked weekdays treated as work days still to be decided.
+func (i *Service) GetForecast(req model.GetForecastRequest) (model.GetForecastResponse, error) {
+	loc, err := i.location(req.Meta.UserID)
+	if err != nil {
+		return model.GetForecastResponse{}, err
+	}
+	asOf := util.Today(loc)
+	if req.Date != "" {
+		asOf, err = time.ParseInLocation("2006-01-02", req.Date, loc)
+		if err != nil {
+			return model.GetForecastResponse{}, invalid("date", "invalid date %q, want YYYY-MM-DD", req.Date)
+		}
+	}
+
+	until := asOf
+	if req.Until != "" {
+		until, err = time.ParseInLocation("2006-01-02", req.Until, loc)
+		if err != nil {
+			return model.GetForecastResponse{}, invalid("until", "invalid date %q, want YYYY-MM-DD", req.Until)
+		}
+		if until.Before(asOf) {
+			return model.GetForecastResponse{}, invalid("until", "%s is before %s", req.Until, asOf.Format("2006-01-02"))
+		}
+	}
+
+	target, err := i.targetWindow(req.Meta.UserID, until)
+	if err != nil || target == nil {
+		return model.GetForecastResponse{}, err
+	}
+
+	f := target.forecast(asOf)
+	return model.GetForecastResponse{
+		Data: &f,
+	}, nil
+}
+
+// targetWindow is the user's target and the days of the window it is
+// measured over, loaded once so the forecast can be worked out again as the
+// days change.
+type targetWindow struct {
+	prefs        model.TargetPreferences
+	customStates model.CustomStates
+	start, end   time.Time
+	state        func(time.Time) model.DayState
+}
+
+// targetWindow loads the user's target window containing day, or returns nil
+// when no target is set.
+func (i *Service) targetWindow(userID int, day time.Time) (*targetWindow, error) {
+	prefs, err := i.db.GetTargetPreferences(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get target preferences: %w", err)
+	}
+	if !prefs.IsSet() {
+		return nil, nil
+	}
+
+	startMonth, err := i.trackingStartMonth(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	customStates, err := i.db.GetCustomStates(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get custom states: %w", err)
+	}
+
+	start, end := report.TargetWindow(prefs, startMonth, day)
+	state, err := i.windowStates(userID, startMonth, start, end)
+	if err != nil {
+		return nil, err
+	}
+	return &targetWindow{prefs: prefs, customStates: customStates, start: start, end: end, state: state}, nil
+}
+
+func (w *targetWindow) forecast(asOf time.Time) model.Forecast {
+	return forecast(w.prefs, w.customStates, w.start, w.end, asOf, w.state)
+}
+
+// forecast builds on the window's compliance as of asOf by counting the work
+// days after it, and which of those are already planned in the office.
+func forecast(prefs model.TargetPreferences, customStates model.CustomStates, start, end, asOf time.Time, state func(time.Time) model.DayState) model.Forecast {
+	c := report.Compliance(prefs, customStates, start, end, asOf, state)
+
+	f := model.Forecast{
+		Policy:   c.Policy,
+		Window:   c.Window,
+		Start:    c.Start,
+		End:      c.End,
+		AsOf:     asOf.Format("2006-01-02"),
+		Present:  c.Present,
+		Total:    c.Total,
+		Required: c.Required,
+		Needed:   c.Remaining,
+	}
+
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		if !day.After(asOf) {
+			continue
+		}
+		s := state(day).State
+		weekday := day.Weekday() != time.Saturday && day.Weekday() != time.Sunday
+		// Matches the days report.Compliance projects for the percent policy.
+		switch attendance := customStates.Attendance(s); {
+		case attendance == model.AttendancePresent:
+			f.Planned++
+			f.RemainingWorkDays++
+		case attendance != model.AttendanceExcluded || (s == model.StateUntracked && weekday):
+			f.RemainingWorkDays++
+		}
+	}
+
+	f.ProjectedPresent = f.Present + f.Planned
+	if total := f.Total + f.RemainingWorkDays; total > 0 {
+		f.ProjectedPercent = math.Round(float64(f.ProjectedPresent)/float64(total)*10000) / 100
+	}
+	f.Additional = max(0, f.Required-f.ProjectedPresent)
+	f.ProjectedMet = f.Additional == 0
+	f.Achievable = f.Needed <= f.RemainingWorkDays
+	return f
+}
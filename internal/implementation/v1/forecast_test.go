@@ -0,0 +1,110 @@
+package v1
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/baely/officetracker/internal/database/dbtest"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+// forecastDB has office days on 4-6 March 2024 and at home on 7-8 March, a
+// day entered ahead in the office on 20 March, and Mondays and Tuesdays
+// scheduled in the office.
+func forecastDB() *dbtest.Fake {
+	db := dbtest.New()
+	for _, d := range []int{4, 5, 6, 20} {
+		db.SaveDay(1, d, 3, 2024, model.DayState{State: model.StateWorkFromOffice})
+	}
+	for _, d := range []int{7, 8} {
+		db.SaveDay(1, d, 3, 2024, model.DayState{State: model.StateWorkFromHome})
+	}
+	db.SaveSchedulePreferences(1, model.SchedulePreferences{
+		Monday:  model.StateWorkFromOffice,
+		Tuesday: model.StateWorkFromOffice,
+	})
+	return db
+}
+
+func TestGetForecast(t *testing.T) {
+	db := forecastDB()
+	db.SaveTargetPreferences(1, model.TargetPreferences{TargetPercent: 60})
+	svc := &Service{db: db}
+
+	resp, err := svc.GetForecast(model.GetForecastRequest{Meta: model.GetForecastRequestMeta{UserID: 1}, Date: "2024-03-15"})
+	if err != nil {
+		t.Fatalf("GetForecast: %v", err)
+	}
+	// Scheduled 11-12 March count as office days so far; 18, 19, 20, 25 and 26
+	// are planned out of the 10 weekdays left.
+	want := model.Forecast{
+		Policy: model.TargetPolicyPercent, Window: model.TargetWindowMonth,
+		Start: "2024-03-01", End: "2024-03-31", AsOf: "2024-03-15",
+		Present: 5, Total: 7, Required: 11, Needed: 6,
+		RemainingWorkDays: 10, Planned: 5, ProjectedPresent: 10, ProjectedPercent: 58.82,
+		Additional: 1, Achievable: true,
+	}
+	if resp.Data == nil || *resp.Data != want {
+		t.Errorf("forecast = %+v, want %+v", resp.Data, want)
+	}
+}
+
+func TestGetForecastNotAchievable(t *testing.T) {
+	db := forecastDB()
+	db.SaveTargetPreferences(1, model.TargetPreferences{Policy: model.TargetPolicyDaysPerWeek, TargetDays: 5})
+	svc := &Service{db: db}
+
+	resp, err := svc.GetForecast(model.GetForecastRequest{Meta: model.GetForecastRequestMeta{UserID: 1}, Date: "2024-03-15"})
+	if err != nil {
+		t.Fatalf("GetForecast: %v", err)
+	}
+	if f := resp.Data; f == nil || f.Required != 23 || f.Needed != 18 || f.Achievable || f.ProjectedMet {
+		t.Errorf("forecast = %+v, want 18 needed and not achievable", f)
+	}
+}
+
+// Until moves a rolling window forward so there are days left to plan.
+func TestGetForecastRollingUntil(t *testing.T) {
+	db := forecastDB()
+	db.SaveTargetPreferences(1, model.TargetPreferences{
+		Policy: model.TargetPolicyDaysPerWeek, TargetDays: 3, Window: model.TargetWindowRollingWeeks, WindowWeeks: 2,
+	})
+	svc := &Service{db: db}
+	meta := model.GetForecastRequestMeta{UserID: 1}
+
+	resp, err := svc.GetForecast(model.GetForecastRequest{Meta: meta, Date: "2024-03-15", Until: "2024-03-22"})
+	if err != nil {
+		t.Fatalf("GetForecast: %v", err)
+	}
+	f := resp.Data
+	if f == nil || f.Start != "2024-03-09" || f.End != "2024-03-22" || f.Present != 2 || f.Required != 6 ||
+		f.RemainingWorkDays != 5 || f.Planned != 3 || f.Additional != 1 || !f.Achievable {
+		t.Errorf("forecast = %+v", f)
+	}
+
+	if _, err := svc.GetForecast(model.GetForecastRequest{Meta: meta, Date: "2024-03-15", Until: "2024-03-14"}); err == nil {
+		t.Error("expected an error for until before date")
+	}
+}
+
+func TestGetForecastNoTarget(t *testing.T) {
+	svc := &Service{db: forecastDB()}
+	resp, err := svc.GetForecast(model.GetForecastRequest{Meta: model.GetForecastRequestMeta{UserID: 1}, Date: "2024-03-15"})
+	if err != nil || resp.Data != nil {
+		t.Errorf("GetForecast = (%+v, %v), want no data", resp.Data, err)
+	}
+}
+
+func TestMcpGetForecast(t *testing.T) {
+	db := forecastDB()
+	db.SaveTargetPreferences(1, model.TargetPreferences{TargetPercent: 60})
+	svc := &Service{db: db}
+
+	_, out, err := svc.McpGetForecast(ctxWithUser(1), nil, &model.McpGetForecastRequest{Date: "2024-03-15"})
+	if err != nil {
+		t.Fatalf("McpGetForecast: %v", err)
+	}
+	if out.Forecast == nil || !strings.Contains(out.Summary, "6 more needed by 2024-03-31") {
+		t.Errorf("McpGetForecast = %+v", out)
+	}
+}
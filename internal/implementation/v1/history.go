@@ -0,0 +1,67 @@
+package v1
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/baely/officetracker/internal/database"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+const (
+	viaAPI      = "api"
+	viaMCP      = "mcp"
+	viaSchedule = "schedule"
+	viaChat     = "chat"
+)
+
+// changeAuthor identifies who made a write, as recorded in the attendance
+// history.
+type changeAuthor struct {
+	authMethod string
+	tokenID    int
+	via        string
+}
+
+// dayChange builds the history row for a single day write.
+func (a changeAuthor) dayChange(day, month, year int, oldState, newState model.DayState, at time.Time) database.DayChange {
+	via := a.via
+	if via == "" {
+		via = viaAPI
+	}
+	return database.DayChange{
+		Day:        day,
+		Month:      month,
+		Year:       year,
+		OldState:   oldState.State,
+		NewState:   newState.State,
+		ChangedAt:  at,
+		AuthMethod: a.authMethod,
+		TokenID:    a.tokenID,
+		Via:        via,
+	}
+}
+
+func (i *Service) GetDayHistory(req model.GetDayHistoryRequest) (model.GetDayHistoryResponse, error) {
+	history, err := i.db.GetDayHistory(req.Meta.UserID, req.Meta.Day, req.Meta.Month, req.Meta.Year)
+	if err != nil {
+		err = fmt.Errorf("failed to get day history: %w", err)
+		return model.GetDayHistoryResponse{}, err
+	}
+
+	changes := make([]model.DayChange, 0, len(history))
+	for _, c := range history {
+		changes = append(changes, model.DayChange{
+			OldState:   c.OldState,
+			NewState:   c.NewState,
+			ChangedAt:  c.ChangedAt.UTC().Format(time.RFC3339),
+			AuthMethod: c.AuthMethod,
+			TokenID:    c.TokenID,
+			Via:        c.Via,
+		})
+	}
+
+	return model.GetDayHistoryResponse{
+		Data: changes,
+	}, nil
+}
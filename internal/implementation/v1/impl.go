@@ -1,9 +1,12 @@
 package v1
 
 import (
+	"context"
+
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 
 	"github.com/baely/officetracker/internal/database"
+	"github.com/baely/officetracker/internal/push"
 	"github.com/baely/officetracker/internal/report"
 )
 
@@ -11,6 +14,12 @@ type Service struct {
 	db       database.Databaser
 	reporter report.Reporter
 	mcp      *mcp.Server
+	devices  push.DeviceSender
+	// checkIns queues check-in confirmations for ConfirmCheckIns to send.
+	checkIns chan checkInConfirmation
+	// lookupHost resolves VPN hostnames for check-ins. Nil uses the default
+	// resolver.
+	lookupHost func(ctx context.Context, host string) ([]string, error)
 }
 
 func New(db database.Databaser, reporter report.Reporter) *Service {
@@ -34,13 +43,23 @@ func createMcpServer(service *Service) *mcp.Server {
 	mcp.AddTool(server, &mcp.Tool{
 		Name:        "get_month",
 		Title:       "GetMonth",
-		Description: "Fetches the users office attendance for the given month. A missing date is functionally equivalent to 'Untracked' which is to say the user didn't state their office attendance.",
+		Description: "Fetches the users office attendance for the given month, including any note left on a day. Days set to one of the user's custom states are reported by that state's name. A missing date is functionally equivalent to 'Untracked' which is to say the user didn't state their office attendance.",
 	}, service.McpGetMonth)
 	mcp.AddTool(server, &mcp.Tool{
 		Name:        "set_day",
 		Title:       "SetDay",
-		Description: "Sets the users office attendance for a given date. Valid states are 'Untracked', 'WorkFromHome', 'WorkFromOffice' or 'Other'.",
+		Description: "Sets the users office attendance for a given date. Valid states are 'Untracked', 'WorkFromHome', 'WorkFromOffice', 'Other' or the name of one of the user's custom states (e.g. 'Client site'). An optional note (e.g. 'client site', 'sick') is saved against the day; leaving it empty keeps any existing note.",
 	}, service.McpSetDay)
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "get_target_compliance",
+		Title:       "GetTargetCompliance",
+		Description: "Reports how the user is tracking against their office attendance target (a percentage of work days, or days per week or month) over the target's window, such as the calendar month or a rolling number of weeks. Includes office days so far and how many more are needed to meet the target.",
+	}, service.McpGetCompliance)
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "get_target_forecast",
+		Title:       "GetTargetForecast",
+		Description: "Forecasts the user's office attendance target to the end of its window from their entries so far, their weekly schedule and the work days left. Reports how many more office days are needed, the projected attendance percentage and whether the target is still achievable. Pass 'Until' to forecast a later window, e.g. next month, or the day a rolling window should end on.",
+	}, service.McpGetForecast)
 
 	return server
 }
@@ -0,0 +1,165 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/baely/officetracker/internal/database"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+func (i *Service) ListLocations(req model.ListLocationsRequest) (model.ListLocationsResponse, error) {
+	locations, err := i.db.GetLocations(req.Meta.UserID)
+	if err != nil {
+		err = fmt.Errorf("failed to get locations: %w", err)
+		return model.ListLocationsResponse{}, err
+	}
+
+	return model.ListLocationsResponse{
+		Data: locations,
+	}, nil
+}
+
+func (i *Service) CreateLocation(req model.CreateLocationRequest) (model.CreateLocationResponse, error) {
+	location, err := validateLocation(req.Data)
+	if err != nil {
+		return model.CreateLocationResponse{}, err
+	}
+	location.ID = 0
+	if err = i.checkLocationName(req.Meta.UserID, location); err != nil {
+		return model.CreateLocationResponse{}, err
+	}
+
+	location, err = i.db.SaveLocation(req.Meta.UserID, location)
+	if err != nil {
+		err = fmt.Errorf("failed to save location: %w", err)
+		return model.CreateLocationResponse{}, err
+	}
+
+	return model.CreateLocationResponse{
+		Data: location,
+	}, nil
+}
+
+func (i *Service) UpdateLocation(req model.UpdateLocationRequest) (model.UpdateLocationResponse, error) {
+	// Saving with ID 0 would create a location rather than update one.
+	if req.Meta.LocationID <= 0 {
+		return model.UpdateLocationResponse{}, notFound("location %d not found", req.Meta.LocationID)
+	}
+	location, err := validateLocation(req.Data)
+	if err != nil {
+		return model.UpdateLocationResponse{}, err
+	}
+	location.ID = req.Meta.LocationID
+	if err = i.checkLocationName(req.Meta.UserID, location); err != nil {
+		return model.UpdateLocationResponse{}, err
+	}
+
+	location, err = i.db.SaveLocation(req.Meta.UserID, location)
+	if errors.Is(err, database.ErrNoLocation) {
+		return model.UpdateLocationResponse{}, notFound("location %d not found", req.Meta.LocationID)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to save location: %w", err)
+		return model.UpdateLocationResponse{}, err
+	}
+
+	return model.UpdateLocationResponse{
+		Data: location,
+	}, nil
+}
+
+// DeleteLocation archives the location rather than removing it, so days spent
+// there still show up in the location breakdowns.
+func (i *Service) DeleteLocation(req model.DeleteLocationRequest) (model.DeleteLocationResponse, error) {
+	locations, err := i.db.GetLocations(req.Meta.UserID)
+	if err != nil {
+		err = fmt.Errorf("failed to get locations: %w", err)
+		return model.DeleteLocationResponse{}, err
+	}
+
+	location, ok := locations.Get(req.Meta.LocationID)
+	if !ok {
+		return model.DeleteLocationResponse{}, notFound("location %d not found", req.Meta.LocationID)
+	}
+	location.Archived = true
+
+	if _, err = i.db.SaveLocation(req.Meta.UserID, location); err != nil {
+		err = fmt.Errorf("failed to save location: %w", err)
+		return model.DeleteLocationResponse{}, err
+	}
+
+	return model.DeleteLocationResponse{}, nil
+}
+
+// PutDayLocation sets or clears a day's location, leaving the rest of the day
+// untouched.
+func (i *Service) PutDayLocation(req model.PutDayLocationRequest) (model.PutDayLocationResponse, error) {
+	var v validator
+	v.date(req.Meta.Year, req.Meta.Month, req.Meta.Day, "day")
+	if err := v.err(); err != nil {
+		return model.PutDayLocationResponse{}, err
+	}
+	state, err := i.db.GetDay(req.Meta.UserID, req.Meta.Day, req.Meta.Month, req.Meta.Year)
+	if err != nil {
+		err = fmt.Errorf("failed to get day: %w", err)
+		return model.PutDayLocationResponse{}, err
+	}
+	if state.LocationID == req.Data.LocationID {
+		return model.PutDayLocationResponse{}, nil
+	}
+
+	state.LocationID = req.Data.LocationID
+	if err = i.checkDays(req.Meta.UserID, state); err != nil {
+		return model.PutDayLocationResponse{}, err
+	}
+
+	err = i.db.SaveDay(req.Meta.UserID, req.Meta.Day, req.Meta.Month, req.Meta.Year, state)
+	if err != nil {
+		err = fmt.Errorf("failed to save day location: %w", err)
+		return model.PutDayLocationResponse{}, err
+	}
+
+	return model.PutDayLocationResponse{}, nil
+}
+
+// validateLocation trims the name and checks the fields a client supplies.
+// Coordinates are optional but must be given together.
+func validateLocation(location model.Location) (model.Location, error) {
+	location.Name = strings.TrimSpace(location.Name)
+	if location.Name == "" {
+		return model.Location{}, invalid("data.name", "location name is required")
+	}
+	if (location.Latitude == nil) != (location.Longitude == nil) {
+		return model.Location{}, invalid("data.latitude", "location latitude and longitude must be set together")
+	}
+	if location.Latitude != nil && (*location.Latitude < -90 || *location.Latitude > 90) {
+		return model.Location{}, invalid("data.latitude", "location latitude %v is out of range", *location.Latitude)
+	}
+	if location.Longitude != nil && (*location.Longitude < -180 || *location.Longitude > 180) {
+		return model.Location{}, invalid("data.longitude", "location longitude %v is out of range", *location.Longitude)
+	}
+	if location.Radius < 0 {
+		return model.Location{}, invalid("data.radius", "location radius must not be negative")
+	}
+	return location, nil
+}
+
+// checkLocationName rejects a name another of the user's active locations
+// already has, ignoring case. A location being updated must exist.
+func (i *Service) checkLocationName(userID int, location model.Location) error {
+	locations, err := i.db.GetLocations(userID)
+	if err != nil {
+		return fmt.Errorf("failed to get locations: %w", err)
+	}
+	if _, ok := locations.Get(location.ID); location.ID != 0 && !ok {
+		return notFound("location %d not found", location.ID)
+	}
+	for _, other := range locations {
+		if other.ID != location.ID && !other.Archived && strings.EqualFold(other.Name, location.Name) {
+			return conflict("a location named %q already exists", other.Name)
+		}
+	}
+	return nil
+}
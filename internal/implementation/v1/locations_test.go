@@ -0,0 +1,166 @@
+package v1
+
+import (
+	"testing"
+
+	"github.com/baely/officetracker/internal/database/dbtest"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+func ptr(f float64) *float64 { return &f }
+
+// Created locations get IDs from 1 upwards and show up in the list and in
+// settings.
+func TestCreateLocation(t *testing.T) {
+	db := dbtest.New()
+	svc := &Service{db: db}
+
+	first, err := svc.CreateLocation(model.CreateLocationRequest{
+		Meta: model.CreateLocationRequestMeta{UserID: 1},
+		Data: model.Location{ID: 7, Name: " Melbourne ", Latitude: ptr(-37.8136), Longitude: ptr(144.9631), Radius: 150},
+	})
+	if err != nil {
+		t.Fatalf("CreateLocation: %v", err)
+	}
+	if first.Data.ID != 1 || first.Data.Name != "Melbourne" {
+		t.Errorf("created = %+v, want ID 1 and trimmed name", first.Data)
+	}
+	second, err := svc.CreateLocation(model.CreateLocationRequest{
+		Meta: model.CreateLocationRequestMeta{UserID: 1},
+		Data: model.Location{Name: "Sydney"},
+	})
+	if err != nil {
+		t.Fatalf("CreateLocation: %v", err)
+	}
+	if second.Data.ID != 2 {
+		t.Errorf("second ID = %d, want 2", second.Data.ID)
+	}
+
+	list, err := svc.ListLocations(model.ListLocationsRequest{Meta: model.ListLocationsRequestMeta{UserID: 1}})
+	if err != nil || len(list.Data) != 2 {
+		t.Fatalf("ListLocations = (%+v, %v), want 2 locations", list.Data, err)
+	}
+	settings, err := svc.GetSettings(model.GetSettingsRequest{Meta: model.GetSettingsRequestMeta{UserID: 1}})
+	if err != nil || len(settings.Locations) != 2 {
+		t.Errorf("GetSettings locations = (%+v, %v), want 2", settings.Locations, err)
+	}
+}
+
+func TestCreateLocationValidation(t *testing.T) {
+	cases := map[string]model.Location{
+		"latitude only":   {Name: "Office", Latitude: ptr(-37.8)},
+		"bad latitude":    {Name: "Office", Latitude: ptr(91), Longitude: ptr(0)},
+		"bad longitude":   {Name: "Office", Latitude: ptr(0), Longitude: ptr(-181)},
+		"negative radius": {Name: "Office", Radius: -1},
+	}
+	for name, location := range cases {
+		db := dbtest.New()
+		svc := &Service{db: db}
+		_, err := svc.CreateLocation(model.CreateLocationRequest{Data: location})
+		if err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+		if locations, _ := db.GetLocations(0); len(locations) != 0 {
+			t.Errorf("%s: invalid location was saved", name)
+		}
+	}
+}
+
+func TestUpdateLocation(t *testing.T) {
+	db := dbtest.New()
+	created, _ := db.SaveLocation(1, model.Location{Name: "Office"})
+	svc := &Service{db: db}
+
+	resp, err := svc.UpdateLocation(model.UpdateLocationRequest{
+		Meta: model.UpdateLocationRequestMeta{UserID: 1, LocationID: created.ID},
+		Data: model.Location{Name: "Melbourne", Latitude: ptr(-37.8136), Longitude: ptr(144.9631)},
+	})
+	if err != nil {
+		t.Fatalf("UpdateLocation: %v", err)
+	}
+	if resp.Data.ID != created.ID || resp.Data.Name != "Melbourne" || resp.Data.Latitude == nil {
+		t.Errorf("updated = %+v", resp.Data)
+	}
+}
+
+// Deleting archives: days already at the location keep it, but it is flagged
+// as archived.
+func TestDeleteLocationArchives(t *testing.T) {
+	db := dbtest.New()
+	created, _ := db.SaveLocation(1, model.Location{Name: "Office"})
+	svc := &Service{db: db}
+
+	if _, err := svc.DeleteLocation(model.DeleteLocationRequest{
+		Meta: model.DeleteLocationRequestMeta{UserID: 1, LocationID: created.ID},
+	}); err != nil {
+		t.Fatalf("DeleteLocation: %v", err)
+	}
+	locations, _ := db.GetLocations(1)
+	if got, ok := locations.Get(created.ID); !ok || !got.Archived {
+		t.Errorf("location after delete = (%+v, %v), want archived", got, ok)
+	}
+
+	if _, err := svc.DeleteLocation(model.DeleteLocationRequest{
+		Meta: model.DeleteLocationRequestMeta{UserID: 1, LocationID: 99},
+	}); errCode(err) != model.ErrorCodeNotFound {
+		t.Errorf("deleting an unknown location = %v, want not found", err)
+	}
+}
+
+// Office days may reference a defined location; other days and undefined
+// locations are rejected. A write that leaves the location unset keeps it
+// while the state is unchanged and drops it when the state changes.
+func TestPutDayLocation(t *testing.T) {
+	db := dbtest.New()
+	office, _ := db.SaveLocation(1, model.Location{Name: "Office"})
+	svc := &Service{db: db}
+	meta := model.PutDayRequestMeta{UserID: 1, Year: 2024, Month: 3, Day: 5}
+
+	put := func(day model.DayState) error {
+		_, err := svc.PutDay(model.PutDayRequest{Meta: meta, Data: day})
+		return err
+	}
+	if err := put(model.DayState{State: model.StateWorkFromOffice, LocationID: office.ID}); err != nil {
+		t.Fatalf("PutDay with a location: %v", err)
+	}
+	if err := put(model.DayState{State: model.StateWorkFromOffice, LocationID: office.ID + 1}); err == nil {
+		t.Error("PutDay should reject an undefined location")
+	}
+	if err := put(model.DayState{State: model.StateWorkFromHome, LocationID: office.ID}); err == nil {
+		t.Error("PutDay should reject a location on a day at home")
+	}
+
+	if err := put(model.DayState{State: model.StateWorkFromOffice}); err != nil {
+		t.Fatalf("PutDay: %v", err)
+	}
+	if got, _ := db.GetDay(1, 5, 3, 2024); got.LocationID != office.ID {
+		t.Errorf("location after same-state write = %d, want %d", got.LocationID, office.ID)
+	}
+	if err := put(model.DayState{State: model.StateWorkFromHome}); err != nil {
+		t.Fatalf("PutDay: %v", err)
+	}
+	if got, _ := db.GetDay(1, 5, 3, 2024); got.LocationID != 0 {
+		t.Errorf("location after state change = %d, want 0", got.LocationID)
+	}
+
+	// The dedicated endpoint sets and clears the location on an office day.
+	locMeta := model.PutDayLocationRequestMeta{UserID: 1, Year: 2024, Month: 3, Day: 6}
+	if _, err := svc.PutDayLocation(model.PutDayLocationRequest{Meta: locMeta, Data: model.DayLocation{LocationID: office.ID}}); err == nil {
+		t.Error("PutDayLocation should reject an untracked day")
+	}
+	if err := db.SaveDay(1, 6, 3, 2024, model.DayState{State: model.StateWorkFromOffice, Note: "client site"}); err != nil {
+		t.Fatalf("SaveDay: %v", err)
+	}
+	if _, err := svc.PutDayLocation(model.PutDayLocationRequest{Meta: locMeta, Data: model.DayLocation{LocationID: office.ID}}); err != nil {
+		t.Fatalf("PutDayLocation: %v", err)
+	}
+	if got, _ := db.GetDay(1, 6, 3, 2024); got.LocationID != office.ID || got.Note != "client site" {
+		t.Errorf("day after PutDayLocation = %+v", got)
+	}
+	if _, err := svc.PutDayLocation(model.PutDayLocationRequest{Meta: locMeta, Data: model.DayLocation{}}); err != nil {
+		t.Fatalf("PutDayLocation clear: %v", err)
+	}
+	if got, _ := db.GetDay(1, 6, 3, 2024); got.LocationID != 0 {
+		t.Errorf("location after clearing = %d, want 0", got.LocationID)
+	}
+}
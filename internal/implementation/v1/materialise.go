@@ -0,0 +1,224 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/baely/officetracker/internal/database"
+	"github.com/baely/officetracker/internal/util"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+const (
+	// materialiseHour is the hour in the user's timezone from which the day's
+	// scheduled state is saved.
+	materialiseHour = 18
+
+	// confirmWindow is how many days back unconfirmed entries are listed.
+	confirmWindow = 7
+)
+
+func (i *Service) UpdateMaterialisePreferences(req model.UpdateMaterialisePreferencesRequest) (model.UpdateMaterialisePreferencesResponse, error) {
+	if !req.Data.Mode.Valid() {
+		return model.UpdateMaterialisePreferencesResponse{}, invalid("data.mode", "unknown mode %q", req.Data.Mode)
+	}
+	if err := i.db.SaveMaterialisePreferences(req.Meta.UserID, req.Data); err != nil {
+		return model.UpdateMaterialisePreferencesResponse{}, fmt.Errorf("failed to save materialise preferences: %w", err)
+	}
+	return model.UpdateMaterialisePreferencesResponse{}, nil
+}
+
+// MaterialiseScheduledDays saves today's scheduled state as an entry for every
+// user who has opted in and whose evening has started at now in their
+// timezone, returning how many entries were saved. Days saved by an earlier run
+// are skipped, so it can run every hour to reach each user's evening. A failure
+// for one user is logged and doesn't stop the rest.
+func (i *Service) MaterialiseScheduledDays(now time.Time) (int, error) {
+	users, err := i.db.ListMaterialiseUsers()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	var saved int
+	for _, userID := range users {
+		ok, err := i.materialiseDay(userID, now)
+		if err != nil {
+			slog.Error("failed to materialise scheduled day", "userID", userID, "error", err.Error())
+			continue
+		}
+		if ok {
+			saved++
+		}
+	}
+	return saved, nil
+}
+
+// materialiseDay saves the user's scheduled state for their day at now as an
+// entry when they have opted in, it's evening for them and the day hasn't
+// been entered. It reports whether an entry was saved.
+func (i *Service) materialiseDay(userID int, now time.Time) (bool, error) {
+	prefs, err := i.db.GetMaterialisePreferences(userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get materialise preferences: %w", err)
+	}
+	if prefs.Mode == model.MaterialiseOff {
+		return false, nil
+	}
+
+	loc, err := i.location(userID)
+	if err != nil {
+		return false, err
+	}
+	local := now.In(loc)
+	if local.Hour() < materialiseHour {
+		return false, nil
+	}
+	day := util.StartOfDay(local)
+
+	previous, err := i.db.GetDay(userID, day.Day(), int(day.Month()), day.Year())
+	if err != nil {
+		return false, fmt.Errorf("failed to get day: %w", err)
+	}
+	if previous.State != model.StateUntracked {
+		return false, nil
+	}
+
+	schedule, err := i.schedule(userID)
+	if err != nil {
+		return false, err
+	}
+	scheduled := schedule.State(day)
+	if scheduled == model.StateUntracked {
+		return false, nil
+	}
+
+	state := model.DayState{
+		State:       scheduled,
+		Source:      model.SourceSchedule,
+		UpdatedAt:   now.UTC(),
+		Note:        previous.Note,
+		Unconfirmed: prefs.Mode == model.MaterialiseUnconfirmed,
+	}
+	author := changeAuthor{via: viaSchedule}
+	change := author.dayChange(day.Day(), int(day.Month()), day.Year(), previous, state, now)
+	entry := database.Entry{Day: day.Day(), Month: int(day.Month()), Year: day.Year(), State: state}
+	if err := i.db.SaveEntries(userID, []database.Entry{entry}, []database.DayChange{change}); err != nil {
+		return false, fmt.Errorf("failed to save day: %w", err)
+	}
+	return true, nil
+}
+
+// MaterialiseEvenings runs MaterialiseScheduledDays at the start of every hour
+// until ctx is done. The standalone server uses it in place of a scheduled job.
+func (i *Service) MaterialiseEvenings(ctx context.Context) {
+	for {
+		next := time.Now().Truncate(time.Hour).Add(time.Hour)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(next)):
+		}
+
+		saved, err := i.MaterialiseScheduledDays(next)
+		if err != nil {
+			slog.Error("failed to materialise scheduled days", "error", err.Error())
+			continue
+		}
+		slog.Info("materialised scheduled days", "saved", saved)
+	}
+}
+
+// GetUnconfirmedDays lists the unconfirmed entries from the week up to and
+// including Date.
+func (i *Service) GetUnconfirmedDays(req model.GetUnconfirmedDaysRequest) (model.GetUnconfirmedDaysResponse, error) {
+	loc, err := i.location(req.Meta.UserID)
+	if err != nil {
+		return model.GetUnconfirmedDaysResponse{}, err
+	}
+	end := util.Today(loc)
+	if req.Date != "" {
+		end, err = time.ParseInLocation("2006-01-02", req.Date, loc)
+		if err != nil {
+			return model.GetUnconfirmedDaysResponse{}, invalid("date", "invalid date %q, want YYYY-MM-DD", req.Date)
+		}
+	}
+
+	days := []model.UnconfirmedDay{}
+	months := make(map[[2]int]model.MonthState)
+	for day := end.AddDate(0, 0, 1-confirmWindow); !day.After(end); day = day.AddDate(0, 0, 1) {
+		key := [2]int{day.Year(), int(day.Month())}
+		month, ok := months[key]
+		if !ok {
+			month, err = i.db.GetMonth(req.Meta.UserID, int(day.Month()), day.Year())
+			if err != nil {
+				return model.GetUnconfirmedDaysResponse{}, fmt.Errorf("failed to get month: %w", err)
+			}
+			months[key] = month
+		}
+		if state, ok := month.Days[day.Day()]; ok && state.Unconfirmed {
+			days = append(days, model.UnconfirmedDay{Date: day.Format("2006-01-02"), State: state.State})
+		}
+	}
+
+	return model.GetUnconfirmedDaysResponse{
+		Data: days,
+	}, nil
+}
+
+// ConfirmDays clears the unconfirmed flag on the given days, keeping their
+// state. Days that aren't unconfirmed are left alone. The confirmed days are
+// saved in one transaction, each with a history entry recording who
+// confirmed it.
+func (i *Service) ConfirmDays(req model.ConfirmDaysRequest) (model.ConfirmDaysResponse, error) {
+	var v validator
+	if len(req.Data.Dates) > maxRangeDays {
+		v.add("data.dates", "at most %d days can be confirmed at once", maxRangeDays)
+	}
+	dates := make(map[time.Time]bool, len(req.Data.Dates))
+	for n, d := range req.Data.Dates {
+		date, err := parseDate(d, fmt.Sprintf("data.dates[%d]", n))
+		if err != nil {
+			v.merge(err)
+			continue
+		}
+		dates[date] = true
+	}
+	if err := v.err(); err != nil {
+		return model.ConfirmDaysResponse{}, err
+	}
+
+	now := time.Now()
+	author := changeAuthor{authMethod: req.Meta.AuthMethod, tokenID: req.Meta.AuthTokenID, via: req.Meta.Via}
+	months := newMonthCache(i, req.Meta.UserID)
+	var entries []database.Entry
+	var changes []database.DayChange
+	for _, date := range slices.SortedFunc(maps.Keys(dates), time.Time.Compare) {
+		month, err := months.get(date)
+		if err != nil {
+			return model.ConfirmDaysResponse{}, err
+		}
+		previous := month.Days[date.Day()]
+		if !previous.Unconfirmed {
+			continue
+		}
+		state := previous
+		state.Unconfirmed = false
+		state.UpdatedAt = now.UTC()
+		entries = append(entries, database.Entry{Day: date.Day(), Month: int(date.Month()), Year: date.Year(), State: state})
+		changes = append(changes, author.dayChange(date.Day(), int(date.Month()), date.Year(), previous, state, now))
+	}
+
+	if len(entries) > 0 {
+		if err := i.db.SaveEntries(req.Meta.UserID, entries, changes); err != nil {
+			return model.ConfirmDaysResponse{}, fmt.Errorf("failed to save days: %w", err)
+		}
+	}
+
+	return model.ConfirmDaysResponse{
+		Confirmed: len(entries),
+	}, nil
+}
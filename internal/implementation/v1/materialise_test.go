@@ -0,0 +1,215 @@
+package v1
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/baely/officetracker/internal/database/dbtest"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+// 4 March 2024 is a Monday.
+var materialiseDay = time.Date(2024, time.March, 4, 18, 0, 0, 0, time.Local)
+
+func TestMaterialiseScheduledDays(t *testing.T) {
+	cases := map[model.MaterialiseMode]bool{
+		model.MaterialiseEntry:       false,
+		model.MaterialiseUnconfirmed: true,
+	}
+	for mode, unconfirmed := range cases {
+		db := dbtest.New()
+		db.SaveSchedulePreferences(1, model.SchedulePreferences{Monday: model.StateWorkFromOffice})
+		db.SaveMaterialisePreferences(1, model.MaterialisePreferences{Mode: mode})
+		db.SaveDay(1, 4, 3, 2024, model.DayState{Note: "client site"})
+		svc := &Service{db: db}
+
+		saved, err := svc.MaterialiseScheduledDays(materialiseDay)
+		if err != nil || saved != 1 {
+			t.Fatalf("%s: MaterialiseScheduledDays = (%d, %v), want 1", mode, saved, err)
+		}
+		got, _ := db.GetDay(1, 4, 3, 2024)
+		if got.State != model.StateWorkFromOffice || got.Source != model.SourceSchedule ||
+			got.Unconfirmed != unconfirmed || got.Note != "client site" {
+			t.Errorf("%s: day = %+v, want a scheduled office entry keeping the note", mode, got)
+		}
+		history, _ := db.GetDayHistory(1, 4, 3, 2024)
+		if len(history) != 1 || history[0].Via != viaSchedule {
+			t.Errorf("%s: history = %+v, want one schedule change", mode, history)
+		}
+	}
+}
+
+// Days already entered, unscheduled days and users who haven't opted in are
+// left alone.
+func TestMaterialiseScheduledDaysSkips(t *testing.T) {
+	db := dbtest.New()
+	db.SaveSchedulePreferences(1, model.SchedulePreferences{Monday: model.StateWorkFromOffice})
+	svc := &Service{db: db}
+
+	if saved, err := svc.MaterialiseScheduledDays(materialiseDay); err != nil || saved != 0 {
+		t.Errorf("opted out: MaterialiseScheduledDays = (%d, %v), want 0", saved, err)
+	}
+
+	db.SaveMaterialisePreferences(1, model.MaterialisePreferences{Mode: model.MaterialiseEntry})
+	db.SaveDay(1, 4, 3, 2024, model.DayState{State: model.StateWorkFromHome, Source: model.SourceManual})
+	if saved, err := svc.MaterialiseScheduledDays(materialiseDay); err != nil || saved != 0 {
+		t.Errorf("entered: MaterialiseScheduledDays = (%d, %v), want 0", saved, err)
+	}
+	if got, _ := db.GetDay(1, 4, 3, 2024); got.State != model.StateWorkFromHome {
+		t.Errorf("entered day = %+v, want it unchanged", got)
+	}
+
+	if saved, err := svc.MaterialiseScheduledDays(materialiseDay.AddDate(0, 0, 1)); err != nil || saved != 0 {
+		t.Errorf("unscheduled: MaterialiseScheduledDays = (%d, %v), want 0", saved, err)
+	}
+}
+
+// The job saves a day once it's evening in the user's timezone, whatever the
+// time is on the server.
+func TestMaterialiseScheduledDaysTimezone(t *testing.T) {
+	// 06:00 UTC on Monday 4 March is 19:00 in Auckland but 06:00 in London.
+	now := time.Date(2024, time.March, 4, 6, 0, 0, 0, time.UTC)
+	cases := map[string]int{
+		"Pacific/Auckland": 1,
+		"Europe/London":    0,
+	}
+	for zone, want := range cases {
+		db := dbtest.New()
+		db.SaveCalendarPreferences(1, model.CalendarPreferences{TrackingYearStartMonth: 10, Timezone: zone})
+		db.SaveSchedulePreferences(1, model.SchedulePreferences{Monday: model.StateWorkFromOffice})
+		db.SaveMaterialisePreferences(1, model.MaterialisePreferences{Mode: model.MaterialiseEntry})
+		svc := &Service{db: db}
+
+		saved, err := svc.MaterialiseScheduledDays(now)
+		if err != nil || saved != want {
+			t.Errorf("%s: MaterialiseScheduledDays = (%d, %v), want %d", zone, saved, err, want)
+		}
+		if got, _ := db.GetDay(1, 4, 3, 2024); (got.State == model.StateWorkFromOffice) != (want == 1) {
+			t.Errorf("%s: 4 March = %+v", zone, got)
+		}
+	}
+}
+
+func TestUpdateMaterialisePreferences(t *testing.T) {
+	db := dbtest.New()
+	svc := &Service{db: db}
+
+	if _, err := svc.UpdateMaterialisePreferences(model.UpdateMaterialisePreferencesRequest{
+		Meta: model.UpdateMaterialisePreferencesRequestMeta{UserID: 1},
+		Data: model.MaterialisePreferences{Mode: "sometimes"},
+	}); err == nil {
+		t.Error("expected an unknown mode to be rejected")
+	}
+
+	if _, err := svc.UpdateMaterialisePreferences(model.UpdateMaterialisePreferencesRequest{
+		Meta: model.UpdateMaterialisePreferencesRequestMeta{UserID: 1},
+		Data: model.MaterialisePreferences{Mode: model.MaterialiseUnconfirmed},
+	}); err != nil {
+		t.Fatalf("UpdateMaterialisePreferences: %v", err)
+	}
+	settings, err := svc.GetSettings(model.GetSettingsRequest{Meta: model.GetSettingsRequestMeta{UserID: 1}})
+	if err != nil || settings.MaterialisePreferences.Mode != model.MaterialiseUnconfirmed {
+		t.Errorf("GetSettings materialise = (%+v, %v), want unconfirmed", settings.MaterialisePreferences, err)
+	}
+}
+
+func TestUnconfirmedDaysAndConfirm(t *testing.T) {
+	db := dbtest.New()
+	db.SaveDay(1, 26, 2, 2024, model.DayState{State: model.StateWorkFromOffice, Unconfirmed: true}) // outside the week
+	db.SaveDay(1, 28, 2, 2024, model.DayState{State: model.StateWorkFromOffice, Unconfirmed: true})
+	db.SaveDay(1, 1, 3, 2024, model.DayState{State: model.StateWorkFromHome})
+	db.SaveDay(1, 4, 3, 2024, model.DayState{State: model.StateWorkFromHome, Unconfirmed: true})
+	svc := &Service{db: db}
+
+	resp, err := svc.GetUnconfirmedDays(model.GetUnconfirmedDaysRequest{Meta: model.GetUnconfirmedDaysRequestMeta{UserID: 1}, Date: "2024-03-04"})
+	if err != nil {
+		t.Fatalf("GetUnconfirmedDays: %v", err)
+	}
+	want := []model.UnconfirmedDay{
+		{Date: "2024-02-28", State: model.StateWorkFromOffice},
+		{Date: "2024-03-04", State: model.StateWorkFromHome},
+	}
+	if len(resp.Data) != len(want) || resp.Data[0] != want[0] || resp.Data[1] != want[1] {
+		t.Errorf("unconfirmed = %+v, want %+v", resp.Data, want)
+	}
+
+	confirmed, err := svc.ConfirmDays(model.ConfirmDaysRequest{
+		Meta: model.ConfirmDaysRequestMeta{UserID: 1},
+		Data: model.ConfirmDaysRequestData{Dates: []string{"2024-02-28", "2024-03-01", "2024-03-04"}},
+	})
+	if err != nil || confirmed.Confirmed != 2 {
+		t.Fatalf("ConfirmDays = (%+v, %v), want 2 confirmed", confirmed, err)
+	}
+	if got, _ := db.GetDay(1, 28, 2, 2024); got.Unconfirmed || got.State != model.StateWorkFromOffice {
+		t.Errorf("confirmed day = %+v, want it confirmed with its state kept", got)
+	}
+	if resp, _ := svc.GetUnconfirmedDays(model.GetUnconfirmedDaysRequest{Meta: model.GetUnconfirmedDaysRequestMeta{UserID: 1}, Date: "2024-03-04"}); len(resp.Data) != 0 {
+		t.Errorf("unconfirmed after confirming = %+v, want none", resp.Data)
+	}
+
+	if _, err := svc.ConfirmDays(model.ConfirmDaysRequest{Data: model.ConfirmDaysRequestData{Dates: []string{"yesterday"}}}); err == nil {
+		t.Error("expected an invalid date to be rejected")
+	}
+}
+
+// Confirmed days are saved together with a history entry for each, and
+// nothing is confirmed if the save fails.
+func TestConfirmDaysHistory(t *testing.T) {
+	db := dbtest.New()
+	db.SaveDay(1, 29, 2, 2024, model.DayState{State: model.StateWorkFromOffice, Unconfirmed: true})
+	db.SaveDay(1, 1, 3, 2024, model.DayState{State: model.StateWorkFromHome, Unconfirmed: true})
+	svc := &Service{db: db}
+	req := model.ConfirmDaysRequest{
+		Meta: model.ConfirmDaysRequestMeta{UserID: 1, AuthMethod: "sso", Via: viaMCP},
+		Data: model.ConfirmDaysRequestData{Dates: []string{"2024-02-29", "2024-03-01", "2024-03-01"}},
+	}
+
+	db.Errs = map[string]error{"SaveEntries": errInjected}
+	if _, err := svc.ConfirmDays(req); err == nil {
+		t.Fatal("expected ConfirmDays to propagate save error")
+	}
+	if got, _ := db.GetDay(1, 29, 2, 2024); !got.Unconfirmed {
+		t.Errorf("29 February = %+v, want it left unconfirmed", got)
+	}
+
+	db.Errs = nil
+	confirmed, err := svc.ConfirmDays(req)
+	if err != nil || confirmed.Confirmed != 2 {
+		t.Fatalf("ConfirmDays = (%+v, %v), want 2 confirmed", confirmed, err)
+	}
+	history, _ := db.GetDayHistory(1, 1, 3, 2024)
+	if len(history) != 1 || history[0].NewState != model.StateWorkFromHome || history[0].Via != viaMCP || history[0].AuthMethod != "sso" {
+		t.Errorf("1 March history = %+v, want one confirmation via mcp", history)
+	}
+}
+
+func TestConfirmDaysTooMany(t *testing.T) {
+	svc := &Service{db: dbtest.New()}
+	dates := make([]string, maxRangeDays+1)
+	for n := range dates {
+		dates[n] = time.Date(2024, 1, 1+n, 0, 0, 0, 0, time.UTC).Format("2006-01-02")
+	}
+	_, err := svc.ConfirmDays(model.ConfirmDaysRequest{Meta: model.ConfirmDaysRequestMeta{UserID: 1}, Data: model.ConfirmDaysRequestData{Dates: dates}})
+	var verr *ValidationError
+	if !errors.As(err, &verr) || verr.Errors[0].Field != "data.dates" {
+		t.Errorf("ConfirmDays(%d dates) = %v, want a data.dates ValidationError", len(dates), err)
+	}
+}
+
+// Writing a day through the API confirms it.
+func TestPutDayConfirms(t *testing.T) {
+	db := dbtest.New()
+	db.SaveDay(1, 4, 3, 2024, model.DayState{State: model.StateWorkFromOffice, Source: model.SourceSchedule, Unconfirmed: true})
+	svc := &Service{db: db}
+
+	if _, err := svc.PutDay(model.PutDayRequest{
+		Meta: model.PutDayRequestMeta{UserID: 1, Day: 4, Month: 3, Year: 2024},
+		Data: model.DayState{State: model.StateWorkFromHome, Unconfirmed: true},
+	}); err != nil {
+		t.Fatalf("PutDay: %v", err)
+	}
+	if got, _ := db.GetDay(1, 4, 3, 2024); got.Unconfirmed {
+		t.Errorf("day = %+v, want it confirmed", got)
+	}
+}
@@ -4,10 +4,13 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 
+	"github.com/baely/officetracker/internal/auth"
 	otctx "github.com/baely/officetracker/internal/context"
+	"github.com/baely/officetracker/internal/report"
 	"github.com/baely/officetracker/pkg/model"
 )
 
@@ -34,7 +37,12 @@ func (i *Service) McpGetMonth(ctx context.Context, req *mcp.CallToolRequest, in
 		return &mcp.CallToolResult{IsError: true}, nil, err
 	}
 
-	resp := mapGetResp(data)
+	customStates, err := i.db.GetCustomStates(userID)
+	if err != nil {
+		return &mcp.CallToolResult{IsError: true}, nil, err
+	}
+
+	resp := mapGetResp(data, customStates)
 
 	return &mcp.CallToolResult{
 		Content: []mcp.Content{
@@ -54,12 +62,24 @@ func (i *Service) McpSetDay(ctx context.Context, req *mcp.CallToolRequest, in *m
 		return &mcp.CallToolResult{IsError: true}, nil, fmt.Errorf("input is nil")
 	}
 
-	putReq, err := mapPutReq(*in)
+	customStates, err := i.db.GetCustomStates(userID)
+	if err != nil {
+		return &mcp.CallToolResult{IsError: true}, nil, err
+	}
+
+	putReq, err := mapPutReq(*in, customStates)
 	if err != nil {
 		return &mcp.CallToolResult{IsError: true}, nil, err
 	}
 
 	putReq.Meta.UserID = userID
+	putReq.Meta.Via = viaMCP
+	if method, ok := otctx.MapCtx(ctx).Get(otctx.CtxAuthMethodKey).(auth.Method); ok {
+		putReq.Meta.AuthMethod = method.String()
+	}
+	if tokenID, ok := otctx.MapCtx(ctx).Get(otctx.CtxTokenIDKey).(int); ok {
+		putReq.Meta.AuthTokenID = tokenID
+	}
 
 	_, err = i.PutDay(putReq)
 	if err != nil {
@@ -73,11 +93,83 @@ func (i *Service) McpSetDay(ctx context.Context, req *mcp.CallToolRequest, in *m
 	}, &model.McpPutDayResponse{}, nil
 }
 
-func mapGetResp(data model.GetMonthResponse) model.McpGetMonthResponse {
+func (i *Service) McpGetCompliance(ctx context.Context, req *mcp.CallToolRequest, in *model.McpGetComplianceRequest) (*mcp.CallToolResult, *model.McpGetComplianceResponse, error) {
+	userID, ok := otctx.MapCtx(ctx).Get(otctx.CtxUserIDKey).(int)
+	if !ok {
+		return &mcp.CallToolResult{IsError: true}, nil, fmt.Errorf("failed to extract user ID from ctx")
+	}
+
+	var date string
+	if in != nil {
+		date = in.Date
+	}
+	data, err := i.GetCompliance(model.GetComplianceRequest{
+		Meta: model.GetComplianceRequestMeta{UserID: userID},
+		Date: date,
+	})
+	if err != nil {
+		return &mcp.CallToolResult{IsError: true}, nil, err
+	}
+
+	resp := model.McpGetComplianceResponse{Summary: "No attendance target is set."}
+	if data.Data != nil {
+		prefs, err := i.db.GetTargetPreferences(userID)
+		if err != nil {
+			return &mcp.CallToolResult{IsError: true}, nil, err
+		}
+		resp.Summary = fmt.Sprintf("Target: %s. %s to %s: %s.", report.DescribeTarget(prefs), data.Data.Start, data.Data.End, report.DescribeCompliance(*data.Data))
+		resp.Compliance = data.Data
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{Text: resp.Summary},
+		},
+	}, &resp, nil
+}
+
+func (i *Service) McpGetForecast(ctx context.Context, req *mcp.CallToolRequest, in *model.McpGetForecastRequest) (*mcp.CallToolResult, *model.McpGetForecastResponse, error) {
+	userID, ok := otctx.MapCtx(ctx).Get(otctx.CtxUserIDKey).(int)
+	if !ok {
+		return &mcp.CallToolResult{IsError: true}, nil, fmt.Errorf("failed to extract user ID from ctx")
+	}
+
+	var date, until string
+	if in != nil {
+		date, until = in.Date, in.Until
+	}
+	data, err := i.GetForecast(model.GetForecastRequest{
+		Meta:  model.GetForecastRequestMeta{UserID: userID},
+		Date:  date,
+		Until: until,
+	})
+	if err != nil {
+		return &mcp.CallToolResult{IsError: true}, nil, err
+	}
+
+	resp := model.McpGetForecastResponse{Summary: "No attendance target is set."}
+	if data.Data != nil {
+		prefs, err := i.db.GetTargetPreferences(userID)
+		if err != nil {
+			return &mcp.CallToolResult{IsError: true}, nil, err
+		}
+		resp.Summary = fmt.Sprintf("Target: %s. %s to %s: %s.", report.DescribeTarget(prefs), data.Data.Start, data.Data.End, report.DescribeForecast(*data.Data))
+		resp.Forecast = data.Data
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{Text: resp.Summary},
+		},
+	}, &resp, nil
+}
+
+func mapGetResp(data model.GetMonthResponse, customStates model.CustomStates) model.McpGetMonthResponse {
 	resp := model.McpGetMonthResponse{
 		Dates: []struct {
 			Date  int
 			State string
+			Note  string `json:",omitempty"`
 		}{},
 	}
 
@@ -85,17 +177,19 @@ func mapGetResp(data model.GetMonthResponse) model.McpGetMonthResponse {
 		resp.Dates = append(resp.Dates, struct {
 			Date  int
 			State string
+			Note  string `json:",omitempty"`
 		}{
 			Date:  date,
-			State: stateToString(state.State),
+			State: stateToString(state.State, customStates),
+			Note:  state.Note,
 		})
 	}
 
 	return resp
 }
 
-func mapPutReq(req model.McpPutDayRequest) (model.PutDayRequest, error) {
-	state, err := stateFromString(req.State)
+func mapPutReq(req model.McpPutDayRequest, customStates model.CustomStates) (model.PutDayRequest, error) {
+	state, err := stateFromString(req.State, customStates)
 	if err != nil {
 		return model.PutDayRequest{}, err
 	}
@@ -108,13 +202,20 @@ func mapPutReq(req model.McpPutDayRequest) (model.PutDayRequest, error) {
 			Day:    req.Date,
 		},
 		Data: model.DayState{
-			State: state,
+			State:  state,
+			Source: model.SourceMCP,
+			Note:   req.Note,
 		},
 	}, nil
 
 }
 
-func stateToString(state model.State) string {
+// stateToString names a state for MCP clients. Custom states use the name the
+// user gave them.
+func stateToString(state model.State, customStates model.CustomStates) string {
+	if custom, ok := customStates.Get(state); ok {
+		return custom.Name
+	}
 	switch state {
 	case model.StateUntracked:
 		return "Untracked"
@@ -128,7 +229,9 @@ func stateToString(state model.State) string {
 	return "Unknown"
 }
 
-func stateFromString(state string) (model.State, error) {
+// stateFromString parses a state name from an MCP client. Besides the built-in
+// names it accepts the name of any active custom state, ignoring case.
+func stateFromString(state string, customStates model.CustomStates) (model.State, error) {
 	switch state {
 	case "Untracked":
 		return model.StateUntracked, nil
@@ -139,5 +242,10 @@ func stateFromString(state string) (model.State, error) {
 	case "Other":
 		return model.StateOther, nil
 	}
-	return 0, fmt.Errorf("Unknown state '%s'. State must be one of 'Untracked', 'WorkFromHome', 'WorkFromOffice' or 'Other'.", state)
+	for _, custom := range customStates {
+		if !custom.Archived && strings.EqualFold(custom.Name, state) {
+			return custom.ID, nil
+		}
+	}
+	return 0, fmt.Errorf("Unknown state '%s'. State must be one of 'Untracked', 'WorkFromHome', 'WorkFromOffice', 'Other' or the name of a custom state.", state)
 }
@@ -20,27 +20,48 @@ func TestStateStringRoundTrip(t *testing.T) {
 		{model.StateOther, "Other"},
 	}
 	for _, c := range cases {
-		if got := stateToString(c.state); got != c.str {
+		if got := stateToString(c.state, nil); got != c.str {
 			t.Errorf("stateToString(%d) = %q, want %q", c.state, got, c.str)
 		}
-		back, err := stateFromString(c.str)
+		back, err := stateFromString(c.str, nil)
 		if err != nil || back != c.state {
 			t.Errorf("stateFromString(%q) = (%d, %v), want (%d, nil)", c.str, back, err, c.state)
 		}
 	}
 
 	// Scheduled states have no string form.
-	if got := stateToString(model.StateScheduledWorkFromOffice); got != "Unknown" {
+	if got := stateToString(model.StateScheduledWorkFromOffice, nil); got != "Unknown" {
 		t.Errorf("stateToString(scheduled) = %q, want Unknown", got)
 	}
 	// An unrecognised string is rejected.
-	if _, err := stateFromString("Teleporting"); err == nil {
+	if _, err := stateFromString("Teleporting", nil); err == nil {
 		t.Error("stateFromString should reject unknown states")
 	}
 }
 
+// Custom states are named by the user; archived ones can still be read but not
+// set.
+func TestStateStringCustom(t *testing.T) {
+	customs := model.CustomStates{
+		{ID: 100, Name: "Client site", Attendance: model.AttendancePresent},
+		{ID: 101, Name: "Travel", Attendance: model.AttendanceAbsent, Archived: true},
+	}
+	if got := stateToString(100, customs); got != "Client site" {
+		t.Errorf("stateToString(100) = %q, want Client site", got)
+	}
+	if got := stateToString(101, customs); got != "Travel" {
+		t.Errorf("stateToString(101) = %q, want Travel", got)
+	}
+	if got, err := stateFromString("client SITE", customs); err != nil || got != 100 {
+		t.Errorf("stateFromString(client SITE) = (%d, %v), want (100, nil)", got, err)
+	}
+	if _, err := stateFromString("Travel", customs); err == nil {
+		t.Error("stateFromString should reject archived custom states")
+	}
+}
+
 func TestMapPutReq(t *testing.T) {
-	got, err := mapPutReq(model.McpPutDayRequest{Year: 2024, Month: 3, Date: 5, State: "WorkFromOffice"})
+	got, err := mapPutReq(model.McpPutDayRequest{Year: 2024, Month: 3, Date: 5, State: "WorkFromOffice"}, nil)
 	if err != nil {
 		t.Fatalf("mapPutReq: %v", err)
 	}
@@ -51,16 +72,16 @@ func TestMapPutReq(t *testing.T) {
 		t.Errorf("mapPutReq state = %d, want office", got.Data.State)
 	}
 
-	if _, err := mapPutReq(model.McpPutDayRequest{State: "bogus"}); err == nil {
+	if _, err := mapPutReq(model.McpPutDayRequest{State: "bogus"}, nil); err == nil {
 		t.Error("mapPutReq should reject an invalid state string")
 	}
 }
 
 func TestMapGetResp(t *testing.T) {
 	resp := mapGetResp(model.GetMonthResponse{Data: model.MonthState{Days: map[int]model.DayState{
-		1: {State: model.StateWorkFromOffice},
+		1: {State: model.StateWorkFromOffice, Note: "client site"},
 		2: {State: model.StateWorkFromHome},
-	}}})
+	}}}, nil)
 	if len(resp.Dates) != 2 {
 		t.Fatalf("got %d dates, want 2", len(resp.Dates))
 	}
@@ -71,6 +92,11 @@ func TestMapGetResp(t *testing.T) {
 	if byDate[1] != "WorkFromOffice" || byDate[2] != "WorkFromHome" {
 		t.Errorf("mapGetResp dates = %v", byDate)
 	}
+	for _, d := range resp.Dates {
+		if d.Date == 1 && d.Note != "client site" {
+			t.Errorf("mapGetResp note = %q, want client site", d.Note)
+		}
+	}
 }
 
 func ctxWithUser(userID int) context.Context {
@@ -111,7 +137,7 @@ func TestMcpSetDay(t *testing.T) {
 	svc := &Service{db: db}
 
 	_, _, err := svc.McpSetDay(ctxWithUser(1), nil, &model.McpPutDayRequest{
-		Year: 2024, Month: 3, Date: 12, State: "WorkFromHome",
+		Year: 2024, Month: 3, Date: 12, State: "WorkFromHome", Note: "dentist",
 	})
 	if err != nil {
 		t.Fatalf("McpSetDay: %v", err)
@@ -120,6 +146,12 @@ func TestMcpSetDay(t *testing.T) {
 	if got.State != model.StateWorkFromHome {
 		t.Errorf("day not written: %d", got.State)
 	}
+	if got.Source != model.SourceMCP {
+		t.Errorf("source = %q, want mcp", got.Source)
+	}
+	if got.Note != "dentist" {
+		t.Errorf("note = %q, want dentist", got.Note)
+	}
 }
 
 func TestMcpSetDayInvalidState(t *testing.T) {
@@ -139,3 +171,19 @@ func TestMcpSetDayNoUser(t *testing.T) {
 		t.Fatal("expected error when user id absent from context")
 	}
 }
+
+// Writes made through MCP are attributed to it in the day's history.
+func TestMcpSetDayRecordsVia(t *testing.T) {
+	db := dbtest.New()
+	svc := &Service{db: db}
+
+	if _, _, err := svc.McpSetDay(ctxWithUser(1), nil, &model.McpPutDayRequest{
+		Year: 2024, Month: 3, Date: 12, State: "WorkFromOffice",
+	}); err != nil {
+		t.Fatalf("McpSetDay: %v", err)
+	}
+	history, _ := db.GetDayHistory(1, 12, 3, 2024)
+	if len(history) != 1 || history[0].Via != "mcp" {
+		t.Errorf("history = %+v, want one change via mcp", history)
+	}
+}
@@ -0,0 +1,204 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/baely/officetracker/internal/database"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+const (
+	// maxSSIDLength is the longest a Wi-Fi network name can be, in bytes.
+	maxSSIDLength = 32
+
+	// maxNetworkRules caps how many network rules a user can have, which also
+	// bounds the VPN hostnames resolved during a check-in.
+	maxNetworkRules = 20
+)
+
+// lookupTimeout bounds resolving all of the user's VPN hostnames during a
+// check-in.
+var lookupTimeout = 2 * time.Second
+
+// hostname matches a DNS name of letters, digits and hyphens.
+var hostname = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)*[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+func (i *Service) ListNetworkRules(req model.ListNetworkRulesRequest) (model.ListNetworkRulesResponse, error) {
+	rules, err := i.db.GetNetworkRules(req.Meta.UserID)
+	if err != nil {
+		err = fmt.Errorf("failed to get network rules: %w", err)
+		return model.ListNetworkRulesResponse{}, err
+	}
+
+	return model.ListNetworkRulesResponse{
+		Data: rules,
+	}, nil
+}
+
+func (i *Service) CreateNetworkRule(req model.CreateNetworkRuleRequest) (model.CreateNetworkRuleResponse, error) {
+	rules, err := i.db.GetNetworkRules(req.Meta.UserID)
+	if err != nil {
+		err = fmt.Errorf("failed to get network rules: %w", err)
+		return model.CreateNetworkRuleResponse{}, err
+	}
+	if len(rules) >= maxNetworkRules {
+		return model.CreateNetworkRuleResponse{}, conflict("at most %d network rules are allowed", maxNetworkRules)
+	}
+
+	rule, err := i.validateNetworkRule(req.Meta.UserID, req.Data)
+	if err != nil {
+		return model.CreateNetworkRuleResponse{}, err
+	}
+	rule.ID = 0
+
+	rule, err = i.db.SaveNetworkRule(req.Meta.UserID, rule)
+	if err != nil {
+		err = fmt.Errorf("failed to save network rule: %w", err)
+		return model.CreateNetworkRuleResponse{}, err
+	}
+
+	return model.CreateNetworkRuleResponse{
+		Data: rule,
+	}, nil
+}
+
+func (i *Service) UpdateNetworkRule(req model.UpdateNetworkRuleRequest) (model.UpdateNetworkRuleResponse, error) {
+	// Saving with ID 0 would add a rule rather than update one.
+	if req.Meta.RuleID <= 0 {
+		return model.UpdateNetworkRuleResponse{}, notFound("network rule %d not found", req.Meta.RuleID)
+	}
+	rule, err := i.validateNetworkRule(req.Meta.UserID, req.Data)
+	if err != nil {
+		return model.UpdateNetworkRuleResponse{}, err
+	}
+	rule.ID = req.Meta.RuleID
+
+	rule, err = i.db.SaveNetworkRule(req.Meta.UserID, rule)
+	if errors.Is(err, database.ErrNoNetworkRule) {
+		return model.UpdateNetworkRuleResponse{}, notFound("network rule %d not found", req.Meta.RuleID)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to save network rule: %w", err)
+		return model.UpdateNetworkRuleResponse{}, err
+	}
+
+	return model.UpdateNetworkRuleResponse{
+		Data: rule,
+	}, nil
+}
+
+func (i *Service) DeleteNetworkRule(req model.DeleteNetworkRuleRequest) (model.DeleteNetworkRuleResponse, error) {
+	err := i.db.DeleteNetworkRule(req.Meta.UserID, req.Meta.RuleID)
+	if errors.Is(err, database.ErrNoNetworkRule) {
+		return model.DeleteNetworkRuleResponse{}, notFound("network rule %d not found", req.Meta.RuleID)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to delete network rule: %w", err)
+		return model.DeleteNetworkRuleResponse{}, err
+	}
+
+	return model.DeleteNetworkRuleResponse{}, nil
+}
+
+// validateNetworkRule checks the rule's value for its kind and normalises it:
+// IP ranges are stored masked, so a single address becomes a /32 or /128, and
+// hostnames in lower case. The location, if any, must be the user's.
+func (i *Service) validateNetworkRule(userID int, rule model.NetworkRule) (model.NetworkRule, error) {
+	value := strings.TrimSpace(rule.Value)
+	switch rule.Kind {
+	case model.NetworkRuleSSID:
+		if value == "" || len(value) > maxSSIDLength {
+			return model.NetworkRule{}, invalid("data.value", "Wi-Fi network names are 1 to %d bytes long", maxSSIDLength)
+		}
+	case model.NetworkRuleCIDR:
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(value)
+			if addrErr != nil {
+				return model.NetworkRule{}, invalid("data.value", "%q is not an IP range like 203.0.113.0/24", value)
+			}
+			prefix = netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())
+		}
+		value = prefix.Masked().String()
+	case model.NetworkRuleVPNHost:
+		value = strings.TrimSuffix(strings.ToLower(value), ".")
+		if len(value) > 253 || !hostname.MatchString(value) {
+			return model.NetworkRule{}, invalid("data.value", "%q is not a hostname", value)
+		}
+	default:
+		return model.NetworkRule{}, invalid("data.kind", "unknown kind %q", rule.Kind)
+	}
+	rule.Value = value
+
+	if rule.LocationID != 0 {
+		locations, err := i.db.GetLocations(userID)
+		if err != nil {
+			return model.NetworkRule{}, fmt.Errorf("failed to get locations: %w", err)
+		}
+		if _, ok := locations.Get(rule.LocationID); !ok {
+			return model.NetworkRule{}, invalid("data.location_id", "unknown location %d", rule.LocationID)
+		}
+	}
+	return rule, nil
+}
+
+// matchNetwork returns the first rule matching the Wi-Fi network the client
+// reported or the address its request came from. VPN hostnames are resolved
+// only once the other rules have failed to match, all within lookupTimeout,
+// and one that can't be resolved in time just doesn't match.
+func (i *Service) matchNetwork(rules model.NetworkRules, ssid, clientIP string) (model.NetworkRule, bool) {
+	addr, err := netip.ParseAddr(clientIP)
+	if err == nil {
+		addr = addr.Unmap()
+	}
+	for _, rule := range rules {
+		switch rule.Kind {
+		case model.NetworkRuleSSID:
+			if ssid != "" && ssid == rule.Value {
+				return rule, true
+			}
+		case model.NetworkRuleCIDR:
+			if prefix, err := netip.ParsePrefix(rule.Value); err == nil && addr.IsValid() && prefix.Contains(addr) {
+				return rule, true
+			}
+		}
+	}
+	if !addr.IsValid() {
+		return model.NetworkRule{}, false
+	}
+
+	lookup := i.lookupHost
+	if lookup == nil {
+		lookup = net.DefaultResolver.LookupHost
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+	for _, rule := range rules {
+		if rule.Kind != model.NetworkRuleVPNHost {
+			continue
+		}
+		if ctx.Err() != nil {
+			slog.Warn("timed out resolving vpn hosts", "host", rule.Value)
+			break
+		}
+		hosts, err := lookup(ctx, rule.Value)
+		if err != nil {
+			slog.Warn("failed to resolve vpn host", "host", rule.Value, "error", err.Error())
+			continue
+		}
+		for _, host := range hosts {
+			if ip, err := netip.ParseAddr(host); err == nil && ip.Unmap() == addr {
+				return rule, true
+			}
+		}
+	}
+	return model.NetworkRule{}, false
+}
@@ -0,0 +1,205 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/baely/officetracker/internal/database/dbtest"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+func TestCreateNetworkRule(t *testing.T) {
+	db := dbtest.New()
+	svc := &Service{db: db}
+	db.SaveLocation(1, model.Location{Name: "Melbourne"})
+
+	cases := []struct {
+		rule model.NetworkRule
+		want string
+	}{
+		{model.NetworkRule{Kind: model.NetworkRuleSSID, Value: " Office Wi-Fi "}, "Office Wi-Fi"},
+		{model.NetworkRule{Kind: model.NetworkRuleCIDR, Value: "203.0.113.7/24"}, "203.0.113.0/24"},
+		{model.NetworkRule{Kind: model.NetworkRuleCIDR, Value: "198.51.100.4"}, "198.51.100.4/32"},
+		{model.NetworkRule{Kind: model.NetworkRuleCIDR, Value: "2001:db8::1/32"}, "2001:db8::/32"},
+		{model.NetworkRule{Kind: model.NetworkRuleVPNHost, Value: "VPN.Example.com.", LocationID: 1}, "vpn.example.com"},
+	}
+	for _, c := range cases {
+		resp, err := svc.CreateNetworkRule(model.CreateNetworkRuleRequest{Meta: model.CreateNetworkRuleRequestMeta{UserID: 1}, Data: c.rule})
+		if err != nil {
+			t.Errorf("CreateNetworkRule(%+v): %v", c.rule, err)
+			continue
+		}
+		if resp.Data.ID == 0 || resp.Data.Value != c.want {
+			t.Errorf("CreateNetworkRule(%+v) = %+v, want value %q", c.rule, resp.Data, c.want)
+		}
+	}
+
+	for _, rule := range []model.NetworkRule{
+		{Kind: "mac", Value: "x"},
+		{Kind: model.NetworkRuleSSID, Value: " "},
+		{Kind: model.NetworkRuleSSID, Value: "a network name longer than 32 bytes"},
+		{Kind: model.NetworkRuleCIDR, Value: "203.0.113.0/33"},
+		{Kind: model.NetworkRuleVPNHost, Value: "vpn example.com"},
+		{Kind: model.NetworkRuleSSID, Value: "Office", LocationID: 9},
+	} {
+		_, err := svc.CreateNetworkRule(model.CreateNetworkRuleRequest{Meta: model.CreateNetworkRuleRequestMeta{UserID: 1}, Data: rule})
+		var verr *ValidationError
+		if !errors.As(err, &verr) {
+			t.Errorf("CreateNetworkRule(%+v) = %v, want a ValidationError", rule, err)
+		}
+	}
+}
+
+func TestCreateNetworkRuleLimit(t *testing.T) {
+	db := dbtest.New()
+	svc := &Service{db: db}
+	for range maxNetworkRules {
+		db.SaveNetworkRule(1, model.NetworkRule{Kind: model.NetworkRuleSSID, Value: "Office"})
+	}
+
+	_, err := svc.CreateNetworkRule(model.CreateNetworkRuleRequest{
+		Meta: model.CreateNetworkRuleRequestMeta{UserID: 1},
+		Data: model.NetworkRule{Kind: model.NetworkRuleVPNHost, Value: "vpn.example.com"},
+	})
+	if errCode(err) != model.ErrorCodeConflict {
+		t.Errorf("CreateNetworkRule over the limit = %v, want conflict", err)
+	}
+	if rules, _ := db.GetNetworkRules(1); len(rules) != maxNetworkRules {
+		t.Errorf("got %d rules, want %d", len(rules), maxNetworkRules)
+	}
+}
+
+func TestUpdateAndDeleteNetworkRule(t *testing.T) {
+	db := dbtest.New()
+	svc := &Service{db: db}
+	rule, _ := db.SaveNetworkRule(1, model.NetworkRule{Kind: model.NetworkRuleSSID, Value: "Office"})
+
+	update := model.UpdateNetworkRuleRequest{
+		Meta: model.UpdateNetworkRuleRequestMeta{UserID: 1, RuleID: rule.ID},
+		Data: model.NetworkRule{Kind: model.NetworkRuleSSID, Value: "Office 5G"},
+	}
+	if resp, err := svc.UpdateNetworkRule(update); err != nil || resp.Data.ID != rule.ID || resp.Data.Value != "Office 5G" {
+		t.Errorf("UpdateNetworkRule = (%+v, %v)", resp.Data, err)
+	}
+	for _, id := range []int{9, 0} {
+		update.Meta.RuleID = id
+		if _, err := svc.UpdateNetworkRule(update); errCode(err) != model.ErrorCodeNotFound {
+			t.Errorf("UpdateNetworkRule of rule %d = %v, want not found", id, err)
+		}
+	}
+	if rules, _ := db.GetNetworkRules(1); len(rules) != 1 {
+		t.Errorf("rules = %+v, want no rule added", rules)
+	}
+
+	del := model.DeleteNetworkRuleRequest{Meta: model.DeleteNetworkRuleRequestMeta{UserID: 1, RuleID: rule.ID}}
+	if _, err := svc.DeleteNetworkRule(del); err != nil {
+		t.Fatalf("DeleteNetworkRule: %v", err)
+	}
+	if _, err := svc.DeleteNetworkRule(del); errCode(err) != model.ErrorCodeNotFound {
+		t.Errorf("DeleteNetworkRule twice = %v, want not found", err)
+	}
+	if list, _ := svc.ListNetworkRules(model.ListNetworkRulesRequest{Meta: model.ListNetworkRulesRequestMeta{UserID: 1}}); len(list.Data) != 0 {
+		t.Errorf("rules after delete = %+v", list.Data)
+	}
+}
+
+func TestCheckInNetwork(t *testing.T) {
+	svc := &Service{lookupHost: func(_ context.Context, host string) ([]string, error) {
+		if host == "vpn.example.com" {
+			return []string{"198.51.100.10", "2001:db8::10"}, nil
+		}
+		return nil, errors.New("no such host")
+	}}
+	rules := model.NetworkRules{
+		{ID: 1, Kind: model.NetworkRuleVPNHost, Value: "broken.example.com"},
+		{ID: 2, Kind: model.NetworkRuleSSID, Value: "Office"},
+		{ID: 3, Kind: model.NetworkRuleCIDR, Value: "203.0.113.0/24"},
+		{ID: 4, Kind: model.NetworkRuleVPNHost, Value: "vpn.example.com"},
+	}
+
+	cases := []struct {
+		ssid, ip string
+		want     int
+	}{
+		{"Office", "", 2},
+		{"office", "192.0.2.1", 0},
+		{"", "203.0.113.200", 3},
+		{"", "::ffff:203.0.113.200", 3},
+		{"", "198.51.100.10", 4},
+		{"", "2001:db8::10", 4},
+		{"Home", "192.0.2.1", 0},
+		{"", "not an ip", 0},
+	}
+	for _, c := range cases {
+		rule, ok := svc.matchNetwork(rules, c.ssid, c.ip)
+		if ok != (c.want != 0) || rule.ID != c.want {
+			t.Errorf("matchNetwork(%q, %q) = (%+v, %v), want rule %d", c.ssid, c.ip, rule, ok, c.want)
+		}
+	}
+}
+
+// Every VPN hostname is resolved under the same deadline, and once it passes
+// the remaining hostnames aren't looked up.
+func TestCheckInNetworkLookupDeadline(t *testing.T) {
+	timeout := lookupTimeout
+	lookupTimeout = 10 * time.Millisecond
+	t.Cleanup(func() { lookupTimeout = timeout })
+
+	var deadlines []time.Time
+	svc := &Service{lookupHost: func(ctx context.Context, host string) ([]string, error) {
+		deadline, _ := ctx.Deadline()
+		deadlines = append(deadlines, deadline)
+		if host == "slow.example.com" {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return nil, errors.New("no such host")
+	}}
+	rules := model.NetworkRules{
+		{ID: 1, Kind: model.NetworkRuleVPNHost, Value: "broken.example.com"},
+		{ID: 2, Kind: model.NetworkRuleVPNHost, Value: "slow.example.com"},
+		{ID: 3, Kind: model.NetworkRuleVPNHost, Value: "vpn.example.com"},
+	}
+
+	if rule, ok := svc.matchNetwork(rules, "", "198.51.100.10"); ok {
+		t.Errorf("matchNetwork = %+v, want no match", rule)
+	}
+	if len(deadlines) != 2 {
+		t.Fatalf("looked up %d hosts, want 2 before the deadline", len(deadlines))
+	}
+	if deadlines[0].IsZero() || !deadlines[0].Equal(deadlines[1]) {
+		t.Errorf("lookup deadlines = %v, want one shared deadline", deadlines)
+	}
+}
+
+func TestCheckInNetworkRecords(t *testing.T) {
+	db := dbtest.New()
+	svc := &Service{db: db}
+	office, _ := db.SaveLocation(1, model.Location{Name: "Melbourne", Latitude: ptr(-37.8136), Longitude: ptr(144.9631)})
+	db.SaveNetworkRule(1, model.NetworkRule{Kind: model.NetworkRuleCIDR, Value: "203.0.113.0/24", LocationID: office.ID})
+
+	checkIn := func(ip string) model.CheckInResult {
+		t.Helper()
+		resp, err := svc.CheckIn(model.CheckInRequest{
+			Meta: model.CheckInRequestMeta{UserID: 1, ClientIP: ip},
+			Data: model.CheckIn{Source: model.SourceExtension},
+		})
+		if err != nil {
+			t.Fatalf("CheckIn: %v", err)
+		}
+		return resp.Data
+	}
+
+	if got := checkIn("192.0.2.1"); got.Recorded || got.Reason != model.CheckInNotAtOffice || got.Rule != nil {
+		t.Errorf("from another network = %+v", got)
+	}
+	got := checkIn("203.0.113.9")
+	if !got.Recorded || got.Rule == nil || got.Location == nil || got.Location.ID != office.ID {
+		t.Fatalf("from the office network = %+v", got)
+	}
+	if got.Day.State != model.StateWorkFromOffice || got.Day.Source != model.SourceExtension || got.Day.LocationID != office.ID {
+		t.Errorf("recorded day = %+v", got.Day)
+	}
+}
@@ -0,0 +1,432 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	netmail "net/mail"
+	"strings"
+	"time"
+
+	"github.com/baely/officetracker/internal/mail"
+	"github.com/baely/officetracker/internal/push"
+	"github.com/baely/officetracker/internal/report"
+	"github.com/baely/officetracker/internal/util"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+const (
+	// reminderHour is the hour in the user's timezone from which an untracked
+	// weekday is reminded about, before materialiseHour fills it in.
+	reminderHour = 17
+	// digestHour is the hour on Monday, and on the 1st of the month, from
+	// which the weekly digest and monthly summary are sent.
+	digestHour = 8
+
+	// notificationRetention is how long sent notifications stay logged. It
+	// must outlast the longest period, a tracking year target window.
+	notificationRetention = 400 * 24 * time.Hour
+)
+
+// Notification kinds, as logged.
+const (
+	notifyDailyReminder  = "daily_reminder"
+	notifyWeeklyDigest   = "weekly_digest"
+	notifyMonthlySummary = "monthly_summary"
+	notifyPushReminder   = "push_reminder"
+	notifyTargetWarning  = "target_warning"
+	notifyAppReminder    = "app_reminder"
+)
+
+// Notifiers are the ways notifications reach users: email, browsers and the
+// mobile app. Any may be nil when it isn't configured, and notifications sent
+// through it are skipped.
+type Notifiers struct {
+	Mail   mail.Sender
+	Push   push.Sender
+	Mobile push.DeviceSender
+}
+
+// notification is an email, browser or mobile notification due to a user.
+// Each is sent once per kind and period.
+type notification struct {
+	kind   string
+	period string
+	// Only one of email, push and mobile is set, to build the message.
+	email  func() (mail.Message, error)
+	push   func() (push.Message, error)
+	mobile func() (push.Message, error)
+}
+
+// UpdateNotificationPreferences saves which emails the user gets. Switching
+// any on needs an email address to send them to.
+func (i *Service) UpdateNotificationPreferences(req model.UpdateNotificationPreferencesRequest) (model.UpdateNotificationPreferencesResponse, error) {
+	req.Data.Email = strings.TrimSpace(req.Data.Email)
+	if req.Data.Email != "" {
+		addr, err := netmail.ParseAddress(req.Data.Email)
+		if err != nil || addr.Name != "" || addr.Address != req.Data.Email {
+			return model.UpdateNotificationPreferencesResponse{}, invalid("data.email", "invalid email address %q", req.Data.Email)
+		}
+	} else if req.Data.Enabled() {
+		return model.UpdateNotificationPreferencesResponse{}, invalid("data.email", "an email address is needed to send notifications")
+	}
+	if err := i.db.SaveNotificationPreferences(req.Meta.UserID, req.Data); err != nil {
+		return model.UpdateNotificationPreferencesResponse{}, fmt.Errorf("failed to save notification preferences: %w", err)
+	}
+	return model.UpdateNotificationPreferencesResponse{}, nil
+}
+
+// SendNotifications sends every opted-in user the notifications due at now in
+// their timezone through notifiers, returning how many were sent. siteURL,
+// ending in a slash, is linked from each one. Sent notifications are logged,
+// so it can run every hour without repeating one; a failed send is retried on
+// the next run. A failure for one user is logged and doesn't stop the rest.
+func (i *Service) SendNotifications(notifiers Notifiers, siteURL string, now time.Time) (int, error) {
+	if _, err := i.db.PruneNotifications(now.Add(-notificationRetention)); err != nil {
+		slog.Error("failed to prune notifications", "error", err.Error())
+	}
+
+	users, err := i.db.ListNotificationUsers()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	var sent int
+	for _, userID := range users {
+		n, err := i.notifyUser(notifiers, siteURL, userID, now)
+		sent += n
+		if err != nil {
+			slog.Error("failed to send notifications", "userID", userID, "error", err.Error())
+		}
+	}
+	return sent, nil
+}
+
+// notifyUser sends the user the notifications due at now, returning how many
+// were sent.
+func (i *Service) notifyUser(notifiers Notifiers, siteURL string, userID int, now time.Time) (int, error) {
+	prefs, err := i.db.GetNotificationPreferences(userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get notification preferences: %w", err)
+	}
+	// Only notifications with somewhere to go are due.
+	if notifiers.Mail == nil || prefs.Email == "" {
+		prefs.DailyReminder, prefs.WeeklyDigest, prefs.MonthlySummary = false, false, false
+	}
+	var subs model.PushSubscriptions
+	if notifiers.Push != nil && prefs.PushEnabled() {
+		if subs, err = i.pushSubscriptions(userID, now); err != nil {
+			return 0, err
+		}
+	}
+	if len(subs) == 0 {
+		prefs.PushReminder, prefs.PushTargetWarning = false, false
+	}
+	var devices model.PushTokens
+	if notifiers.Mobile != nil {
+		if devices, err = i.pushTokens(userID, func(t model.PushToken) bool { return t.Reminder }); err != nil {
+			return 0, err
+		}
+	}
+	if !prefs.Enabled() && !prefs.PushEnabled() && len(devices) == 0 {
+		return 0, nil
+	}
+	suspended, err := i.db.IsUserSuspended(userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check suspension: %w", err)
+	}
+	if suspended {
+		return 0, nil
+	}
+
+	due, err := i.dueNotifications(userID, prefs, len(devices) > 0, siteURL, now)
+	if err != nil {
+		return 0, err
+	}
+
+	var sent int
+	for _, n := range due {
+		claimed, err := i.db.ClaimNotification(userID, n.kind, n.period, now)
+		if err != nil {
+			return sent, fmt.Errorf("failed to claim %s: %w", n.kind, err)
+		}
+		if !claimed {
+			continue
+		}
+		if err := i.deliver(notifiers, userID, prefs.Email, subs, devices, siteURL, n); err != nil {
+			if err := i.db.ReleaseNotification(userID, n.kind, n.period); err != nil {
+				slog.Error("failed to release notification", "userID", userID, "kind", n.kind, "error", err.Error())
+			}
+			return sent, fmt.Errorf("failed to send %s: %w", n.kind, err)
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// deliver builds n and sends it by email, to each subscribed browser or to each
+// device.
+func (i *Service) deliver(notifiers Notifiers, userID int, email string, subs model.PushSubscriptions, devices model.PushTokens, siteURL string, n notification) error {
+	if n.mobile != nil {
+		msg, err := n.mobile()
+		if err != nil {
+			return err
+		}
+		return i.sendToDevices(notifiers.Mobile, userID, devices, msg)
+	}
+	if n.push != nil {
+		msg, err := n.push()
+		if err != nil {
+			return err
+		}
+		return i.sendPush(notifiers.Push, userID, subs, msg)
+	}
+	msg, err := n.email()
+	if err != nil {
+		return err
+	}
+	msg.To = email
+	msg.Body += notificationFooter(siteURL)
+	return notifiers.Mail.Send(msg)
+}
+
+// dueNotifications lists the notifications the user has switched on that are
+// due at now in their timezone, including the mobile app's reminder when
+// appReminder is set. A target warning is due once per target window; a
+// rolling window moves daily, so it's repeated daily while the target stays
+// out of reach.
+func (i *Service) dueNotifications(userID int, prefs model.NotificationPreferences, appReminder bool, siteURL string, now time.Time) ([]notification, error) {
+	loc, err := i.location(userID)
+	if err != nil {
+		return nil, err
+	}
+	local := now.In(loc)
+	today := util.StartOfDay(local)
+
+	var due []notification
+	if (prefs.DailyReminder || prefs.PushReminder || appReminder) && util.ISOWeekday(today) <= 5 && local.Hour() >= reminderHour {
+		state, err := i.db.GetDay(userID, today.Day(), int(today.Month()), today.Year())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get day: %w", err)
+		}
+		if state.State == model.StateUntracked && prefs.DailyReminder {
+			due = append(due, notification{
+				kind:   notifyDailyReminder,
+				period: today.Format(time.DateOnly),
+				email: func() (mail.Message, error) {
+					return dailyReminder(today, siteURL), nil
+				},
+			})
+		}
+		if state.State == model.StateUntracked && prefs.PushReminder {
+			due = append(due, notification{
+				kind:   notifyPushReminder,
+				period: today.Format(time.DateOnly),
+				push: func() (push.Message, error) {
+					return pushReminder(today, siteURL), nil
+				},
+			})
+		}
+		if state.State == model.StateUntracked && appReminder {
+			due = append(due, notification{
+				kind:   notifyAppReminder,
+				period: today.Format(time.DateOnly),
+				mobile: func() (push.Message, error) {
+					msg := pushReminder(today, siteURL)
+					msg.Tag = notifyAppReminder
+					return msg, nil
+				},
+			})
+		}
+	}
+	if prefs.WeeklyDigest && util.ISOWeekday(today) == 1 && local.Hour() >= digestHour {
+		week := today.AddDate(0, 0, -7)
+		due = append(due, notification{
+			kind:   notifyWeeklyDigest,
+			period: util.ISOWeekLabel(week),
+			email: func() (mail.Message, error) {
+				return i.weeklyDigest(userID, week)
+			},
+		})
+	}
+	if prefs.MonthlySummary && today.Day() == 1 && local.Hour() >= digestHour {
+		month := today.AddDate(0, -1, 0)
+		due = append(due, notification{
+			kind:   notifyMonthlySummary,
+			period: month.Format("2006-01"),
+			email: func() (mail.Message, error) {
+				return i.monthlySummary(userID, month)
+			},
+		})
+	}
+	if prefs.PushTargetWarning {
+		resp, err := i.GetForecast(model.GetForecastRequest{
+			Meta: model.GetForecastRequestMeta{UserID: userID},
+			Date: today.Format(time.DateOnly),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if f := resp.Data; f != nil && !f.Achievable {
+			due = append(due, notification{
+				kind:   notifyTargetWarning,
+				period: f.Start,
+				push: func() (push.Message, error) {
+					return targetWarning(*f, siteURL), nil
+				},
+			})
+		}
+	}
+	return due, nil
+}
+
+func dailyReminder(today time.Time, siteURL string) mail.Message {
+	return mail.Message{
+		Subject: fmt.Sprintf("Where did you work today? %s isn't filled in", today.Format("Mon 2 Jan")),
+		Body:    fmt.Sprintf("You haven't recorded where you worked today, %s.\n\nFill it in: %s\n", today.Format("Monday 2 January"), siteURL),
+	}
+}
+
+func pushReminder(today time.Time, siteURL string) push.Message {
+	return push.Message{
+		Title: "Where did you work today?",
+		Body:  fmt.Sprintf("%s isn't filled in yet.", today.Format("Monday 2 January")),
+		URL:   siteURL,
+		Tag:   notifyPushReminder,
+	}
+}
+
+// targetWarning tells the user their target can't be met by the end of the
+// window f forecasts.
+func targetWarning(f model.Forecast, siteURL string) push.Message {
+	end := f.End
+	if date, err := time.Parse(time.DateOnly, f.End); err == nil {
+		end = date.Format("Mon 2 Jan")
+	}
+	return push.Message{
+		Title: "Your attendance target is out of reach",
+		Body:  fmt.Sprintf("You need %d more office days by %s, but only %d work days are left.", f.Needed, end, f.RemainingWorkDays),
+		URL:   siteURL + "report",
+		Tag:   notifyTargetWarning,
+	}
+}
+
+// weeklyDigest summarises the ISO week starting on monday.
+func (i *Service) weeklyDigest(userID int, monday time.Time) (mail.Message, error) {
+	customStates, err := i.db.GetCustomStates(userID)
+	if err != nil {
+		return mail.Message{}, fmt.Errorf("failed to get custom states: %w", err)
+	}
+	present, counted, err := i.recordedAttendance(userID, monday, monday.AddDate(0, 0, 7), customStates)
+	if err != nil {
+		return mail.Message{}, err
+	}
+	summary, err := i.weekSummary(userID, monday, customStates)
+	if err != nil {
+		return mail.Message{}, err
+	}
+
+	subject := fmt.Sprintf("Week %s: you were in %d/%d days", util.ISOWeekLabel(monday), present, counted)
+	if counted == 0 {
+		subject = fmt.Sprintf("Week %s: no days recorded", util.ISOWeekLabel(monday))
+	}
+	return mail.Message{
+		Subject: subject,
+		Body:    fmt.Sprintf("%s\n\n%s\n", attendanceLine(present, counted, "last week"), summary),
+	}, nil
+}
+
+// monthlySummary attaches the report for the month starting on first.
+func (i *Service) monthlySummary(userID int, first time.Time) (mail.Message, error) {
+	end := first.AddDate(0, 1, 0)
+	customStates, err := i.db.GetCustomStates(userID)
+	if err != nil {
+		return mail.Message{}, fmt.Errorf("failed to get custom states: %w", err)
+	}
+	present, counted, err := i.recordedAttendance(userID, first, end, customStates)
+	if err != nil {
+		return mail.Message{}, err
+	}
+	compliance, err := i.compliance(userID, end.AddDate(0, 0, -1))
+	if err != nil {
+		return mail.Message{}, err
+	}
+	pdf, err := i.reporter.GeneratePDF(userID, "", first, end, report.Filter{}, compliance)
+	if err != nil {
+		return mail.Message{}, fmt.Errorf("failed to generate pdf report: %w", err)
+	}
+
+	name := first.Format("January 2006")
+	body := attendanceLine(present, counted, "in "+name) + "\n"
+	if compliance != nil {
+		body += report.DescribeCompliance(*compliance) + "\n"
+	}
+	body += "\nYour report for the month is attached.\n"
+	return mail.Message{
+		Subject: fmt.Sprintf("Your Officetracker summary for %s", name),
+		Body:    body,
+		Attachments: []mail.Attachment{{
+			Name:        fmt.Sprintf("officetracker-%s.pdf", first.Format("2006-01")),
+			ContentType: "application/pdf",
+			Data:        pdf,
+		}},
+	}, nil
+}
+
+// recordedAttendance counts the days in [start, end) recorded as present, and
+// those recorded as present or absent. Scheduled days aren't counted, as
+// nobody confirmed them.
+func (i *Service) recordedAttendance(userID int, start, end time.Time, customStates model.CustomStates) (present, counted int, err error) {
+	months := make(map[time.Month]model.MonthState)
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		month, ok := months[day.Month()]
+		if !ok {
+			month, err = i.db.GetMonth(userID, int(day.Month()), day.Year())
+			if err != nil {
+				return 0, 0, fmt.Errorf("failed to get month: %w", err)
+			}
+			months[day.Month()] = month
+		}
+		switch customStates.Attendance(month.Days[day.Day()].State) {
+		case model.AttendancePresent:
+			present++
+			counted++
+		case model.AttendanceAbsent:
+			counted++
+		}
+	}
+	return present, counted, nil
+}
+
+// attendanceLine describes present of counted days over a period, such as
+// "last week".
+func attendanceLine(present, counted int, period string) string {
+	if counted == 0 {
+		return fmt.Sprintf("You didn't record any work days %s.", period)
+	}
+	return fmt.Sprintf("You were in the office %d of %d days %s (%d%%).", present, counted, period, present*100/counted)
+}
+
+func notificationFooter(siteURL string) string {
+	return fmt.Sprintf("\n--\nYou're getting this email because you switched it on in Officetracker. Change your email settings: %ssettings\n", siteURL)
+}
+
+// NotifyHourly runs SendNotifications at the start of every hour until ctx is
+// done. The standalone server uses it in place of a scheduled job.
+func (i *Service) NotifyHourly(ctx context.Context, notifiers Notifiers, siteURL string) {
+	for {
+		next := time.Now().Truncate(time.Hour).Add(time.Hour)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(next)):
+		}
+
+		sent, err := i.SendNotifications(notifiers, siteURL, next)
+		if err != nil {
+			slog.Error("failed to send notifications", "error", err.Error())
+			continue
+		}
+		slog.Info("sent notifications", "sent", sent)
+	}
+}
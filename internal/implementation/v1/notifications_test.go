@@ -0,0 +1,208 @@
+package v1
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/baely/officetracker/internal/database/dbtest"
+	"github.com/baely/officetracker/internal/mail"
+	"github.com/baely/officetracker/internal/report"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+// outbox records the messages sent through it, failing while err is set.
+type outbox struct {
+	sent []mail.Message
+	err  error
+}
+
+func (o *outbox) Send(msg mail.Message) error {
+	if o.err != nil {
+		return o.err
+	}
+	o.sent = append(o.sent, msg)
+	return nil
+}
+
+const siteURL = "https://ot.example/"
+
+func newNotifyService(prefs model.NotificationPreferences) (*Service, *dbtest.Fake) {
+	db := dbtest.New()
+	db.SaveNotificationPreferences(1, prefs)
+	return &Service{db: db, reporter: report.New(db)}, db
+}
+
+func TestUpdateNotificationPreferences(t *testing.T) {
+	svc, db := newNotifyService(model.NotificationPreferences{})
+
+	for _, data := range []model.NotificationPreferences{
+		{Email: "not an address", DailyReminder: true},
+		{Email: "Alex <alex@example.com>", DailyReminder: true},
+		{WeeklyDigest: true},
+	} {
+		_, err := svc.UpdateNotificationPreferences(model.UpdateNotificationPreferencesRequest{Meta: model.UpdateNotificationPreferencesRequestMeta{UserID: 1}, Data: data})
+		var verr *ValidationError
+		if !errors.As(err, &verr) || verr.Errors[0].Field != "data.email" {
+			t.Errorf("UpdateNotificationPreferences(%+v) = %v, want a data.email ValidationError", data, err)
+		}
+	}
+
+	_, err := svc.UpdateNotificationPreferences(model.UpdateNotificationPreferencesRequest{
+		Meta: model.UpdateNotificationPreferencesRequestMeta{UserID: 1},
+		Data: model.NotificationPreferences{Email: " alex@example.com ", MonthlySummary: true},
+	})
+	if err != nil {
+		t.Fatalf("UpdateNotificationPreferences: %v", err)
+	}
+	want := model.NotificationPreferences{Email: "alex@example.com", MonthlySummary: true}
+	if got, _ := db.GetNotificationPreferences(1); got != want {
+		t.Errorf("saved %+v, want %+v", got, want)
+	}
+}
+
+// The reminder goes out once on a weekday evening when the day is untracked.
+func TestDailyReminder(t *testing.T) {
+	svc, db := newNotifyService(model.NotificationPreferences{Email: "alex@example.com", DailyReminder: true})
+	// Tuesday 7 May 2024.
+	afternoon := time.Date(2024, time.May, 7, 16, 0, 0, 0, time.Local)
+	evening := afternoon.Add(time.Hour)
+	out := &outbox{}
+
+	if sent, err := svc.SendNotifications(Notifiers{Mail: out}, siteURL, afternoon); err != nil || sent != 0 {
+		t.Errorf("afternoon: SendNotifications = (%d, %v), want 0", sent, err)
+	}
+	if sent, err := svc.SendNotifications(Notifiers{Mail: out}, siteURL, evening); err != nil || sent != 1 {
+		t.Fatalf("evening: SendNotifications = (%d, %v), want 1", sent, err)
+	}
+	msg := out.sent[0]
+	if msg.To != "alex@example.com" || !strings.Contains(msg.Subject, "Tue 7 May") ||
+		!strings.Contains(msg.Body, "Fill it in: https://ot.example/") || !strings.Contains(msg.Body, "https://ot.example/settings") {
+		t.Errorf("reminder = %+v", msg)
+	}
+	if sent, _ := svc.SendNotifications(Notifiers{Mail: out}, siteURL, evening.Add(time.Hour)); sent != 0 {
+		t.Errorf("reminded %d more times the same day", sent)
+	}
+
+	// A tracked day, and the weekend, aren't reminded about.
+	db.SaveDay(1, 8, 5, 2024, model.DayState{State: model.StateWorkFromHome})
+	if sent, _ := svc.SendNotifications(Notifiers{Mail: out}, siteURL, evening.AddDate(0, 0, 1)); sent != 0 {
+		t.Errorf("reminded about a tracked day")
+	}
+	if sent, _ := svc.SendNotifications(Notifiers{Mail: out}, siteURL, evening.AddDate(0, 0, 4)); sent != 0 {
+		t.Errorf("reminded on a Saturday")
+	}
+}
+
+// Emails are sent at the right hour in the user's timezone, whatever the time
+// is on the server.
+func TestNotificationsTimezone(t *testing.T) {
+	// 06:00 UTC on Tuesday 7 May is 18:00 in Auckland but 07:00 in London.
+	now := time.Date(2024, time.May, 7, 6, 0, 0, 0, time.UTC)
+	for zone, want := range map[string]int{"Pacific/Auckland": 1, "Europe/London": 0} {
+		svc, db := newNotifyService(model.NotificationPreferences{Email: "alex@example.com", DailyReminder: true})
+		db.SaveCalendarPreferences(1, model.CalendarPreferences{TrackingYearStartMonth: 10, Timezone: zone})
+		if sent, err := svc.SendNotifications(Notifiers{Mail: &outbox{}}, siteURL, now); err != nil || sent != want {
+			t.Errorf("%s: SendNotifications = (%d, %v), want %d", zone, sent, err, want)
+		}
+	}
+}
+
+func TestWeeklyDigest(t *testing.T) {
+	svc, db := newNotifyService(model.NotificationPreferences{Email: "alex@example.com", WeeklyDigest: true})
+	db.SaveSchedulePreferences(1, model.SchedulePreferences{Friday: model.StateWorkFromOffice})
+	// Week 2024-W19 runs from Monday 6 May: two office days, two at home and
+	// an office day only scheduled.
+	db.SaveMonth(1, 5, 2024, model.MonthState{Days: map[int]model.DayState{
+		6: {State: model.StateWorkFromOffice},
+		7: {State: model.StateWorkFromHome},
+		8: {State: model.StateWorkFromOffice},
+		9: {State: model.StateWorkFromHome},
+	}})
+	out := &outbox{}
+
+	monday := time.Date(2024, time.May, 13, 8, 0, 0, 0, time.Local)
+	if sent, err := svc.SendNotifications(Notifiers{Mail: out}, siteURL, monday.Add(-time.Hour)); err != nil || sent != 0 {
+		t.Errorf("early: SendNotifications = (%d, %v), want 0", sent, err)
+	}
+	if sent, err := svc.SendNotifications(Notifiers{Mail: out}, siteURL, monday); err != nil || sent != 1 {
+		t.Fatalf("SendNotifications = (%d, %v), want 1", sent, err)
+	}
+	msg := out.sent[0]
+	if msg.Subject != "Week 2024-W19: you were in 2/4 days" {
+		t.Errorf("subject = %q", msg.Subject)
+	}
+	for _, want := range []string{"You were in the office 2 of 4 days last week (50%).", "Fri 10 May  Office (scheduled)"} {
+		if !strings.Contains(msg.Body, want) {
+			t.Errorf("body %q doesn't contain %q", msg.Body, want)
+		}
+	}
+	if sent, _ := svc.SendNotifications(Notifiers{Mail: out}, siteURL, monday.AddDate(0, 0, 1)); sent != 0 {
+		t.Errorf("sent %d digests on Tuesday", sent)
+	}
+}
+
+func TestMonthlySummary(t *testing.T) {
+	svc, db := newNotifyService(model.NotificationPreferences{Email: "alex@example.com", MonthlySummary: true})
+	db.SaveTargetPreferences(1, model.TargetPreferences{TargetPercent: 50})
+	db.SaveMonth(1, 4, 2024, model.MonthState{Days: map[int]model.DayState{
+		2: {State: model.StateWorkFromOffice},
+		3: {State: model.StateWorkFromHome},
+		4: {State: model.StateOther},
+	}})
+	out := &outbox{}
+
+	first := time.Date(2024, time.May, 1, 9, 0, 0, 0, time.Local)
+	if sent, err := svc.SendNotifications(Notifiers{Mail: out}, siteURL, first); err != nil || sent != 1 {
+		t.Fatalf("SendNotifications = (%d, %v), want 1", sent, err)
+	}
+	msg := out.sent[0]
+	if msg.Subject != "Your Officetracker summary for April 2024" || !strings.Contains(msg.Body, "You were in the office 1 of 2 days in April 2024 (50%).") {
+		t.Errorf("summary = %q: %q", msg.Subject, msg.Body)
+	}
+	if !strings.Contains(msg.Body, "target met") {
+		t.Errorf("body %q doesn't report the target", msg.Body)
+	}
+	if len(msg.Attachments) != 1 || msg.Attachments[0].Name != "officetracker-2024-04.pdf" ||
+		!strings.HasPrefix(string(msg.Attachments[0].Data), "%PDF") {
+		t.Errorf("attachments = %+v, want the April report", msg.Attachments)
+	}
+	if sent, _ := svc.SendNotifications(Notifiers{Mail: out}, siteURL, first.AddDate(0, 0, 1)); sent != 0 {
+		t.Errorf("sent %d summaries on the 2nd", sent)
+	}
+}
+
+// A failed send is retried on the next run.
+func TestNotificationRetry(t *testing.T) {
+	svc, db := newNotifyService(model.NotificationPreferences{Email: "alex@example.com", DailyReminder: true})
+	evening := time.Date(2024, time.May, 7, 18, 0, 0, 0, time.Local)
+	out := &outbox{err: errors.New("connection refused")}
+
+	if sent, err := svc.SendNotifications(Notifiers{Mail: out}, siteURL, evening); err != nil || sent != 0 {
+		t.Errorf("failing: SendNotifications = (%d, %v), want 0", sent, err)
+	}
+	if len(db.Notifications) != 0 {
+		t.Errorf("logged %v after a failed send", db.Notifications)
+	}
+	out.err = nil
+	if sent, err := svc.SendNotifications(Notifiers{Mail: out}, siteURL, evening.Add(time.Hour)); err != nil || sent != 1 {
+		t.Errorf("retry: SendNotifications = (%d, %v), want 1", sent, err)
+	}
+}
+
+// Suspended users and users without an email enabled get nothing.
+func TestNotificationsSkips(t *testing.T) {
+	evening := time.Date(2024, time.May, 7, 18, 0, 0, 0, time.Local)
+
+	svc, _ := newNotifyService(model.NotificationPreferences{Email: "alex@example.com"})
+	if sent, err := svc.SendNotifications(Notifiers{Mail: &outbox{}}, siteURL, evening); err != nil || sent != 0 {
+		t.Errorf("opted out: SendNotifications = (%d, %v), want 0", sent, err)
+	}
+
+	svc, db := newNotifyService(model.NotificationPreferences{Email: "alex@example.com", DailyReminder: true})
+	db.Suspended = true
+	if sent, err := svc.SendNotifications(Notifiers{Mail: &outbox{}}, siteURL, evening); err != nil || sent != 0 {
+		t.Errorf("suspended: SendNotifications = (%d, %v), want 0", sent, err)
+	}
+}
@@ -0,0 +1,151 @@
+package v1
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/baely/officetracker/internal/database"
+	"github.com/baely/officetracker/internal/push"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+// maxDeviceLength caps the description saved with a push subscription.
+const maxDeviceLength = 100
+
+// ListPushSubscriptions lists the user's subscribed browsers without their
+// keys.
+func (i *Service) ListPushSubscriptions(req model.ListPushSubscriptionsRequest) (model.ListPushSubscriptionsResponse, error) {
+	subs, err := i.db.GetPushSubscriptions(req.Meta.UserID)
+	if err != nil {
+		err = fmt.Errorf("failed to get push subscriptions: %w", err)
+		return model.ListPushSubscriptionsResponse{}, err
+	}
+	for n := range subs {
+		subs[n].Keys = model.PushKeys{}
+	}
+	if subs == nil {
+		subs = model.PushSubscriptions{}
+	}
+
+	return model.ListPushSubscriptionsResponse{
+		Data: subs,
+	}, nil
+}
+
+// CreatePushSubscription saves a browser's subscription, replacing any earlier
+// one for the same endpoint.
+func (i *Service) CreatePushSubscription(req model.CreatePushSubscriptionRequest) (model.CreatePushSubscriptionResponse, error) {
+	sub, err := validatePushSubscription(req.Data)
+	if err != nil {
+		return model.CreatePushSubscriptionResponse{}, err
+	}
+
+	sub, err = i.db.SavePushSubscription(req.Meta.UserID, sub, time.Now())
+	if err != nil {
+		err = fmt.Errorf("failed to save push subscription: %w", err)
+		return model.CreatePushSubscriptionResponse{}, err
+	}
+	sub.Keys = model.PushKeys{}
+
+	return model.CreatePushSubscriptionResponse{
+		Data: sub,
+	}, nil
+}
+
+// DeletePushSubscription unsubscribes a browser.
+func (i *Service) DeletePushSubscription(req model.DeletePushSubscriptionRequest) (model.DeletePushSubscriptionResponse, error) {
+	err := i.db.DeletePushSubscription(req.Meta.UserID, req.Meta.SubscriptionID)
+	if errors.Is(err, database.ErrNoPushSub) {
+		return model.DeletePushSubscriptionResponse{}, notFound("push subscription %d not found", req.Meta.SubscriptionID)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to delete push subscription: %w", err)
+		return model.DeletePushSubscriptionResponse{}, err
+	}
+
+	return model.DeletePushSubscriptionResponse{}, nil
+}
+
+// validatePushSubscription checks the subscription a browser hands over: an
+// https endpoint, its P-256 public key and its 16 byte auth secret.
+func validatePushSubscription(sub model.PushSubscription) (model.PushSubscription, error) {
+	var v validator
+	sub.Endpoint = strings.TrimSpace(sub.Endpoint)
+	if u, err := url.Parse(sub.Endpoint); err != nil || u.Scheme != "https" || u.Host == "" {
+		v.add("data.endpoint", "endpoint must be an absolute https URL")
+	}
+	if key, err := decodePushKey(sub.Keys.P256dh); err != nil || len(key) != 65 || key[0] != 4 {
+		v.add("data.keys.p256dh", "p256dh must be an uncompressed P-256 public key")
+	}
+	if secret, err := decodePushKey(sub.Keys.Auth); err != nil || len(secret) != 16 {
+		v.add("data.keys.auth", "auth must be a 16 byte secret")
+	}
+	if err := v.err(); err != nil {
+		return model.PushSubscription{}, err
+	}
+	sub.ID = 0
+	sub.Device = strings.TrimSpace(sub.Device)
+	if len(sub.Device) > maxDeviceLength {
+		sub.Device = sub.Device[:maxDeviceLength]
+	}
+	return sub, nil
+}
+
+// decodePushKey decodes a browser key, which is base64url encoded with or
+// without padding.
+func decodePushKey(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// pushSubscriptions returns the user's subscriptions that haven't expired at
+// now, deleting those that have.
+func (i *Service) pushSubscriptions(userID int, now time.Time) (model.PushSubscriptions, error) {
+	subs, err := i.db.GetPushSubscriptions(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get push subscriptions: %w", err)
+	}
+	live := subs[:0]
+	for _, sub := range subs {
+		if !sub.ExpiresAt.IsZero() && !sub.ExpiresAt.After(now) {
+			i.dropPushSubscription(userID, sub)
+			continue
+		}
+		live = append(live, sub)
+	}
+	return live, nil
+}
+
+// sendPush sends msg to each of the user's subscriptions, deleting those the
+// push service no longer knows. It fails only when no browser got it and one
+// might on a retry.
+func (i *Service) sendPush(sender push.Sender, userID int, subs model.PushSubscriptions, msg push.Message) error {
+	var delivered int
+	var lastErr error
+	for _, sub := range subs {
+		err := sender.Send(sub, msg)
+		switch {
+		case err == nil:
+			delivered++
+		case errors.Is(err, push.ErrGone):
+			i.dropPushSubscription(userID, sub)
+		default:
+			lastErr = err
+		}
+	}
+	if delivered == 0 {
+		return lastErr
+	}
+	return nil
+}
+
+func (i *Service) dropPushSubscription(userID int, sub model.PushSubscription) {
+	err := i.db.DeletePushSubscription(userID, sub.ID)
+	if err != nil && !errors.Is(err, database.ErrNoPushSub) {
+		slog.Error("failed to delete push subscription", "userID", userID, "subscriptionID", sub.ID, "error", err.Error())
+	}
+}
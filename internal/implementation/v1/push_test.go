@@ -0,0 +1,150 @@
+package v1
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/baely/officetracker/internal/push"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+// pushbox records the messages pushed through it. Endpoints in gone are
+// reported as removed, and every other send fails while err is set.
+type pushbox struct {
+	sent []push.Message
+	gone map[string]bool
+	err  error
+}
+
+func (p *pushbox) Send(sub model.PushSubscription, msg push.Message) error {
+	if p.gone[sub.Endpoint] {
+		return push.ErrGone
+	}
+	if p.err != nil {
+		return p.err
+	}
+	p.sent = append(p.sent, msg)
+	return nil
+}
+
+// A browser's subscription, as PushManager.subscribe returns it.
+var browserKeys = model.PushKeys{
+	P256dh: "BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4",
+	Auth:   "BTBZMqHH6r4Tts7J_aSIgg==",
+}
+
+func TestPushSubscriptions(t *testing.T) {
+	svc, db := newNotifyService(model.NotificationPreferences{})
+	meta := model.CreatePushSubscriptionRequestMeta{UserID: 1}
+
+	for _, data := range []model.PushSubscription{
+		{Endpoint: "http://push.example/a", Keys: browserKeys},
+		{Endpoint: "https://push.example/a", Keys: model.PushKeys{P256dh: "short", Auth: browserKeys.Auth}},
+		{Endpoint: "https://push.example/a", Keys: model.PushKeys{P256dh: browserKeys.P256dh}},
+	} {
+		_, err := svc.CreatePushSubscription(model.CreatePushSubscriptionRequest{Meta: meta, Data: data})
+		var verr *ValidationError
+		if !errors.As(err, &verr) {
+			t.Errorf("CreatePushSubscription(%+v) = %v, want a ValidationError", data, err)
+		}
+	}
+
+	resp, err := svc.CreatePushSubscription(model.CreatePushSubscriptionRequest{Meta: meta, Data: model.PushSubscription{
+		Endpoint: " https://push.example/a ",
+		Keys:     browserKeys,
+		Device:   "Firefox on Linux",
+	}})
+	if err != nil {
+		t.Fatalf("CreatePushSubscription: %v", err)
+	}
+	if resp.Data.ID == 0 || resp.Data.Keys != (model.PushKeys{}) || resp.Data.Endpoint != "https://push.example/a" {
+		t.Errorf("created = %+v, want an ID and no keys", resp.Data)
+	}
+	if len(db.PushSubs) != 1 || db.PushSubs[0].Keys != browserKeys {
+		t.Errorf("saved %+v, want the keys kept", db.PushSubs)
+	}
+
+	list, err := svc.ListPushSubscriptions(model.ListPushSubscriptionsRequest{Meta: model.ListPushSubscriptionsRequestMeta{UserID: 1}})
+	if err != nil || len(list.Data) != 1 || list.Data[0].Keys != (model.PushKeys{}) || list.Data[0].Device != "Firefox on Linux" {
+		t.Errorf("ListPushSubscriptions = (%+v, %v)", list.Data, err)
+	}
+
+	del := model.DeletePushSubscriptionRequest{Meta: model.DeletePushSubscriptionRequestMeta{UserID: 1, SubscriptionID: resp.Data.ID}}
+	if _, err := svc.DeletePushSubscription(del); err != nil {
+		t.Fatalf("DeletePushSubscription: %v", err)
+	}
+	if _, err := svc.DeletePushSubscription(del); errCode(err) != model.ErrorCodeNotFound {
+		t.Errorf("DeletePushSubscription twice = %v, want not found", err)
+	}
+}
+
+func TestPushReminder(t *testing.T) {
+	// The email reminder is on too, but there's no mail server.
+	svc, db := newNotifyService(model.NotificationPreferences{Email: "alex@example.com", DailyReminder: true, PushReminder: true})
+	evening := time.Date(2024, time.May, 7, 17, 0, 0, 0, time.Local)
+	out := &pushbox{}
+
+	if sent, err := svc.SendNotifications(Notifiers{Push: out}, siteURL, evening); err != nil || sent != 0 {
+		t.Errorf("no subscriptions: SendNotifications = (%d, %v), want 0", sent, err)
+	}
+	db.SavePushSubscription(1, model.PushSubscription{Endpoint: "https://push.example/a"}, evening)
+	db.SavePushSubscription(1, model.PushSubscription{Endpoint: "https://push.example/b"}, evening)
+	if sent, err := svc.SendNotifications(Notifiers{Push: out}, siteURL, evening); err != nil || sent != 1 {
+		t.Fatalf("SendNotifications = (%d, %v), want 1", sent, err)
+	}
+	if len(out.sent) != 2 || out.sent[0].Tag != notifyPushReminder || out.sent[0].URL != siteURL ||
+		!strings.Contains(out.sent[0].Body, "Tuesday 7 May") {
+		t.Errorf("pushed %+v, want a reminder to each browser", out.sent)
+	}
+	if sent, _ := svc.SendNotifications(Notifiers{Push: out}, siteURL, evening.Add(time.Hour)); sent != 0 {
+		t.Errorf("reminded %d more times the same day", sent)
+	}
+}
+
+func TestPushTargetWarning(t *testing.T) {
+	svc, db := newNotifyService(model.NotificationPreferences{PushTargetWarning: true})
+	now := time.Date(2024, time.May, 7, 12, 0, 0, 0, time.Local)
+	db.SavePushSubscription(1, model.PushSubscription{Endpoint: "https://push.example/a"}, now)
+	db.SaveTargetPreferences(1, model.TargetPreferences{TargetPercent: 100})
+	out := &pushbox{}
+
+	if sent, err := svc.SendNotifications(Notifiers{Push: out}, siteURL, now); err != nil || sent != 0 {
+		t.Errorf("achievable: SendNotifications = (%d, %v), want 0", sent, err)
+	}
+	db.SaveDay(1, 6, 5, 2024, model.DayState{State: model.StateWorkFromHome})
+	if sent, err := svc.SendNotifications(Notifiers{Push: out}, siteURL, now); err != nil || sent != 1 {
+		t.Fatalf("missed: SendNotifications = (%d, %v), want 1", sent, err)
+	}
+	if msg := out.sent[0]; msg.Tag != notifyTargetWarning || !strings.Contains(msg.Body, "by Fri 31 May") ||
+		msg.URL != siteURL+"report" {
+		t.Errorf("warning = %+v", msg)
+	}
+	// Once per window.
+	if sent, _ := svc.SendNotifications(Notifiers{Push: out}, siteURL, now.AddDate(0, 0, 1)); sent != 0 {
+		t.Errorf("warned %d more times in May", sent)
+	}
+}
+
+// Subscriptions the push service has dropped, or that have expired, are
+// deleted. A failure to reach any browser is retried.
+func TestPushSubscriptionExpiry(t *testing.T) {
+	svc, db := newNotifyService(model.NotificationPreferences{PushReminder: true})
+	evening := time.Date(2024, time.May, 7, 18, 0, 0, 0, time.Local)
+	db.SavePushSubscription(1, model.PushSubscription{Endpoint: "https://push.example/expired", ExpiresAt: evening.Add(-time.Hour)}, evening)
+	db.SavePushSubscription(1, model.PushSubscription{Endpoint: "https://push.example/gone"}, evening)
+	db.SavePushSubscription(1, model.PushSubscription{Endpoint: "https://push.example/live"}, evening)
+	out := &pushbox{gone: map[string]bool{"https://push.example/gone": true}, err: errors.New("connection refused")}
+
+	if sent, err := svc.SendNotifications(Notifiers{Push: out}, siteURL, evening); err != nil || sent != 0 {
+		t.Errorf("failing: SendNotifications = (%d, %v), want 0", sent, err)
+	}
+	if len(db.PushSubs) != 1 || db.PushSubs[0].Endpoint != "https://push.example/live" {
+		t.Errorf("subscriptions = %+v, want only the live one", db.PushSubs)
+	}
+	out.err = nil
+	if sent, err := svc.SendNotifications(Notifiers{Push: out}, siteURL, evening.Add(time.Hour)); err != nil || sent != 1 {
+		t.Errorf("retry: SendNotifications = (%d, %v), want 1", sent, err)
+	}
+}
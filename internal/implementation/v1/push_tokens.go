@@ -0,0 +1,193 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/baely/officetracker/internal/database"
+	"github.com/baely/officetracker/internal/push"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+// notifyCheckIn tags check-in confirmations, so a later one replaces an
+// earlier one on the device.
+const notifyCheckIn = "check_in"
+
+// checkInQueueSize bounds the check-in confirmations waiting to be sent. Once
+// it is full further ones are dropped rather than holding up check-ins.
+const checkInQueueSize = 100
+
+// expoToken matches the push tokens Expo issues the mobile app.
+var expoToken = regexp.MustCompile(`^Expo(nent)?PushToken\[[^\[\]]{1,200}\]$`)
+
+// checkInConfirmation is a check-in waiting to be confirmed on the user's
+// devices.
+type checkInConfirmation struct {
+	userID int
+	date   time.Time
+	state  model.DayState
+}
+
+// SetDeviceSender sends the mobile app's check-in confirmations through
+// sender as days are recorded, once ConfirmCheckIns is running. Without one
+// they aren't sent.
+func (i *Service) SetDeviceSender(sender push.DeviceSender) {
+	i.devices = sender
+	i.checkIns = make(chan checkInConfirmation, checkInQueueSize)
+}
+
+// ConfirmCheckIns sends the check-in confirmations queued as days are
+// recorded, one at a time, until ctx is done.
+func (i *Service) ConfirmCheckIns(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case c := <-i.checkIns:
+			i.confirmCheckIn(c.userID, c.date, c.state)
+		}
+	}
+}
+
+// queueCheckIn queues a check-in confirmation without waiting for it to be
+// sent, dropping it if the queue is full.
+func (i *Service) queueCheckIn(userID int, date time.Time, state model.DayState) {
+	select {
+	case i.checkIns <- checkInConfirmation{userID: userID, date: date, state: state}:
+	default:
+		slog.Warn("check-in confirmation queue full, dropping confirmation", "userID", userID)
+	}
+}
+
+// ListPushTokens lists the devices registered for push notifications.
+func (i *Service) ListPushTokens(req model.ListPushTokensRequest) (model.ListPushTokensResponse, error) {
+	tokens, err := i.db.GetPushTokens(req.Meta.UserID)
+	if err != nil {
+		err = fmt.Errorf("failed to get push tokens: %w", err)
+		return model.ListPushTokensResponse{}, err
+	}
+	if tokens == nil {
+		tokens = model.PushTokens{}
+	}
+
+	return model.ListPushTokensResponse{
+		Data: tokens,
+	}, nil
+}
+
+// CreatePushToken registers the mobile app's Expo push token, tied to the API
+// token the request was made with so signing out removes it.
+func (i *Service) CreatePushToken(req model.CreatePushTokenRequest) (model.CreatePushTokenResponse, error) {
+	token := req.Data
+	token.Token = strings.TrimSpace(token.Token)
+	if !expoToken.MatchString(token.Token) {
+		return model.CreatePushTokenResponse{}, invalid("data.token", "token must be an Expo push token")
+	}
+	token.ID = 0
+	token.Device = strings.TrimSpace(token.Device)
+	if len(token.Device) > maxDeviceLength {
+		token.Device = token.Device[:maxDeviceLength]
+	}
+
+	token, err := i.db.SavePushToken(req.Meta.UserID, req.Meta.AuthTokenID, token, time.Now())
+	if err != nil {
+		err = fmt.Errorf("failed to save push token: %w", err)
+		return model.CreatePushTokenResponse{}, err
+	}
+
+	return model.CreatePushTokenResponse{
+		Data: token,
+	}, nil
+}
+
+// DeletePushToken stops sending push notifications to a device.
+func (i *Service) DeletePushToken(req model.DeletePushTokenRequest) (model.DeletePushTokenResponse, error) {
+	err := i.db.DeletePushToken(req.Meta.UserID, req.Meta.PushTokenID)
+	if errors.Is(err, database.ErrNoPushToken) {
+		return model.DeletePushTokenResponse{}, notFound("push token %d not found", req.Meta.PushTokenID)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to delete push token: %w", err)
+		return model.DeletePushTokenResponse{}, err
+	}
+
+	return model.DeletePushTokenResponse{}, nil
+}
+
+// pushTokens returns the user's devices that want the notification chosen by
+// want.
+func (i *Service) pushTokens(userID int, want func(model.PushToken) bool) (model.PushTokens, error) {
+	tokens, err := i.db.GetPushTokens(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get push tokens: %w", err)
+	}
+	wanted := tokens[:0]
+	for _, t := range tokens {
+		if want(t) {
+			wanted = append(wanted, t)
+		}
+	}
+	return wanted, nil
+}
+
+// sendToDevices sends msg to each device, deleting those Expo no longer knows.
+// Like sendPush, it fails only when no device got it and one might on a retry.
+func (i *Service) sendToDevices(sender push.DeviceSender, userID int, tokens model.PushTokens, msg push.Message) error {
+	var delivered int
+	var lastErr error
+	for _, t := range tokens {
+		err := sender.Send(t.Token, msg)
+		switch {
+		case err == nil:
+			delivered++
+		case errors.Is(err, push.ErrGone):
+			if err := i.db.DeletePushToken(userID, t.ID); err != nil && !errors.Is(err, database.ErrNoPushToken) {
+				slog.Error("failed to delete push token", "userID", userID, "pushTokenID", t.ID, "error", err.Error())
+			}
+		default:
+			lastErr = err
+		}
+	}
+	if delivered == 0 {
+		return lastErr
+	}
+	return nil
+}
+
+// confirmCheckIn tells the user's devices that the geofence recorded date as
+// in the office at state's location.
+func (i *Service) confirmCheckIn(userID int, date time.Time, state model.DayState) {
+	tokens, err := i.pushTokens(userID, func(t model.PushToken) bool { return t.CheckIn })
+	if err != nil {
+		slog.Error("failed to confirm check-in", "userID", userID, "error", err.Error())
+		return
+	}
+	if len(tokens) == 0 {
+		return
+	}
+	office := "the office"
+	if state.LocationID != 0 {
+		locations, err := i.db.GetLocations(userID)
+		if err != nil {
+			slog.Error("failed to get locations", "userID", userID, "error", err.Error())
+		}
+		for _, l := range locations {
+			if l.ID == state.LocationID {
+				office = l.Name
+			}
+		}
+	}
+	msg := push.Message{
+		Title: "Checked in at " + office,
+		Body:  fmt.Sprintf("%s is recorded as in the office.", date.Format("Monday 2 January")),
+		Tag:   notifyCheckIn,
+	}
+	if err := i.sendToDevices(i.devices, userID, tokens, msg); err != nil {
+		slog.Error("failed to confirm check-in", "userID", userID, "error", err.Error())
+	}
+}
@@ -0,0 +1,183 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/baely/officetracker/internal/push"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+// devicebox records the messages sent to each device. Tokens in gone are
+// reported as unregistered, and every other send fails while err is set. When
+// done is set it's signalled after each send.
+type devicebox struct {
+	sent map[string][]push.Message
+	gone map[string]bool
+	err  error
+	done chan struct{}
+}
+
+func (d *devicebox) Send(token string, msg push.Message) error {
+	if d.done != nil {
+		defer func() { d.done <- struct{}{} }()
+	}
+	if d.gone[token] {
+		return push.ErrGone
+	}
+	if d.err != nil {
+		return d.err
+	}
+	if d.sent == nil {
+		d.sent = make(map[string][]push.Message)
+	}
+	d.sent[token] = append(d.sent[token], msg)
+	return nil
+}
+
+func TestPushTokens(t *testing.T) {
+	svc, db := newNotifyService(model.NotificationPreferences{})
+	meta := model.CreatePushTokenRequestMeta{UserID: 1, AuthTokenID: 7}
+
+	for _, token := range []string{"", "abc", "ExponentPushToken[]", "ExponentPushToken[abc"} {
+		_, err := svc.CreatePushToken(model.CreatePushTokenRequest{Meta: meta, Data: model.PushToken{Token: token}})
+		var verr *ValidationError
+		if !errors.As(err, &verr) {
+			t.Errorf("CreatePushToken(%q) = %v, want a ValidationError", token, err)
+		}
+	}
+
+	resp, err := svc.CreatePushToken(model.CreatePushTokenRequest{Meta: meta, Data: model.PushToken{
+		Token:    " ExponentPushToken[xxxxxxxxxxxxxxxxxxxxxx] ",
+		Device:   "Pixel 8",
+		Reminder: true,
+	}})
+	if err != nil {
+		t.Fatalf("CreatePushToken: %v", err)
+	}
+	if resp.Data.ID == 0 || resp.Data.Token != "ExponentPushToken[xxxxxxxxxxxxxxxxxxxxxx]" || !resp.Data.Reminder {
+		t.Errorf("created = %+v", resp.Data)
+	}
+	list, err := svc.ListPushTokens(model.ListPushTokensRequest{Meta: model.ListPushTokensRequestMeta{UserID: 1}})
+	if err != nil || len(list.Data) != 1 || list.Data[0] != resp.Data {
+		t.Errorf("ListPushTokens = (%+v, %v)", list.Data, err)
+	}
+
+	del := model.DeletePushTokenRequest{Meta: model.DeletePushTokenRequestMeta{UserID: 1, PushTokenID: resp.Data.ID}}
+	if _, err := svc.DeletePushToken(del); err != nil {
+		t.Fatalf("DeletePushToken: %v", err)
+	}
+	if _, err := svc.DeletePushToken(del); errCode(err) != model.ErrorCodeNotFound {
+		t.Errorf("DeletePushToken twice = %v, want not found", err)
+	}
+
+	// Revoking the API token the app signed in with removes its device.
+	svc.CreatePushToken(model.CreatePushTokenRequest{Meta: meta, Data: model.PushToken{Token: "ExpoPushToken[abc]"}})
+	if _, err := svc.RevokeToken(model.RevokeTokenRequest{Meta: model.RevokeTokenRequestMeta{UserID: 1, TokenID: 7}}); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+	if len(db.PushTokens) != 0 {
+		t.Errorf("devices after revoking = %+v, want none", db.PushTokens)
+	}
+}
+
+func TestAppReminder(t *testing.T) {
+	svc, db := newNotifyService(model.NotificationPreferences{})
+	evening := time.Date(2024, time.May, 7, 17, 0, 0, 0, time.Local)
+	db.SavePushToken(1, 0, model.PushToken{Token: "ExpoPushToken[a]", Reminder: true}, evening)
+	db.SavePushToken(1, 0, model.PushToken{Token: "ExpoPushToken[gone]", Reminder: true}, evening)
+	db.SavePushToken(1, 0, model.PushToken{Token: "ExpoPushToken[check-in-only]", CheckIn: true}, evening)
+	out := &devicebox{gone: map[string]bool{"ExpoPushToken[gone]": true}}
+
+	if sent, err := svc.SendNotifications(Notifiers{}, siteURL, evening); err != nil || sent != 0 {
+		t.Errorf("no sender: SendNotifications = (%d, %v), want 0", sent, err)
+	}
+	if sent, err := svc.SendNotifications(Notifiers{Mobile: out}, siteURL, evening); err != nil || sent != 1 {
+		t.Fatalf("SendNotifications = (%d, %v), want 1", sent, err)
+	}
+	got := out.sent["ExpoPushToken[a]"]
+	if len(out.sent) != 1 || len(got) != 1 || got[0].Tag != notifyAppReminder || !strings.Contains(got[0].Body, "Tuesday 7 May") {
+		t.Errorf("sent %+v, want a reminder to the one registered device", out.sent)
+	}
+	if len(db.PushTokens) != 2 {
+		t.Errorf("devices = %+v, want the unregistered one deleted", db.PushTokens)
+	}
+	if sent, _ := svc.SendNotifications(Notifiers{Mobile: out}, siteURL, evening.Add(time.Hour)); sent != 0 {
+		t.Errorf("reminded %d more times the same day", sent)
+	}
+
+	// A filled in day isn't reminded about.
+	db.SaveDay(1, 8, 5, 2024, model.DayState{State: model.StateWorkFromHome})
+	if sent, _ := svc.SendNotifications(Notifiers{Mobile: out}, siteURL, evening.AddDate(0, 0, 1)); sent != 0 {
+		t.Errorf("reminded %d times about a filled in day", sent)
+	}
+}
+
+func TestCheckInConfirmation(t *testing.T) {
+	svc, db := newNotifyService(model.NotificationPreferences{})
+	out := &devicebox{done: make(chan struct{}, 1)}
+	svc.SetDeviceSender(out)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go svc.ConfirmCheckIns(ctx)
+	now := time.Now()
+	db.SavePushToken(1, 0, model.PushToken{Token: "ExpoPushToken[a]", CheckIn: true}, now)
+	office, _ := db.SaveLocation(1, model.Location{Name: "Melbourne office"})
+
+	put := func(state model.State, source model.Source, locationID int) {
+		t.Helper()
+		_, err := svc.PutDay(model.PutDayRequest{
+			Meta: model.PutDayRequestMeta{UserID: 1, Year: 2024, Month: 5, Day: 7},
+			Data: model.DayState{State: state, Source: source, LocationID: locationID},
+		})
+		if err != nil {
+			t.Fatalf("PutDay: %v", err)
+		}
+	}
+
+	put(model.StateWorkFromOffice, model.SourceGeofence, office.ID)
+	select {
+	case <-out.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no check-in confirmation sent")
+	}
+	got := out.sent["ExpoPushToken[a]"]
+	if len(got) != 1 || got[0].Title != "Checked in at Melbourne office" || got[0].Body != "Tuesday 7 May is recorded as in the office." {
+		t.Errorf("sent %+v", got)
+	}
+
+	// Only days the geofence changes are confirmed.
+	put(model.StateWorkFromHome, model.SourceManual, 0)
+	put(model.StateWorkFromHome, model.SourceGeofence, 0)
+	select {
+	case <-out.done:
+		t.Errorf("confirmed a day the geofence didn't set to the office")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// Confirmations wait in a bounded queue: when nothing is sending them,
+// check-ins carry on and the extras are dropped.
+func TestCheckInConfirmationQueueFull(t *testing.T) {
+	svc, db := newNotifyService(model.NotificationPreferences{})
+	svc.SetDeviceSender(&devicebox{})
+	db.SavePushToken(1, 0, model.PushToken{Token: "ExpoPushToken[a]", CheckIn: true}, time.Now())
+
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for n := 0; n <= checkInQueueSize; n++ {
+		_, err := svc.PutDay(model.PutDayRequest{
+			Meta: model.PutDayRequestMeta{UserID: 1, Year: day.Year(), Month: int(day.Month()), Day: day.Day()},
+			Data: model.DayState{State: model.StateWorkFromOffice, Source: model.SourceGeofence},
+		})
+		if err != nil {
+			t.Fatalf("PutDay: %v", err)
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	if len(svc.checkIns) != checkInQueueSize {
+		t.Errorf("queued %d confirmations, want %d", len(svc.checkIns), checkInQueueSize)
+	}
+}
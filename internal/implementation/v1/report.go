@@ -3,6 +3,7 @@ package v1
 import (
 	"fmt"
 
+	"github.com/baely/officetracker/internal/report"
 	"github.com/baely/officetracker/internal/util"
 	"github.com/baely/officetracker/pkg/model"
 )
@@ -13,9 +14,31 @@ func (i *Service) GetReport(req model.GetReportRequest) (model.Response, error)
 		return model.Response{}, err
 	}
 
-	start, end := util.TrackingYearRange(req.Meta.Year, startMonth)
+	loc, err := i.location(req.Meta.UserID)
+	if err != nil {
+		return model.Response{}, err
+	}
+	start, end := util.TrackingYearRange(req.Meta.Year, startMonth, loc)
+
+	filter, err := reportFilter(req.Source)
+	if err != nil {
+		return model.Response{}, err
+	}
 
-	report, err := i.reporter.GeneratePDF(req.Meta.UserID, req.Name, start, end)
+	// Report the target as of the last day of the year, or today if it's
+	// still under way. A year that hasn't started has nothing to report.
+	var compliance *model.Compliance
+	if asOf := util.Today(loc); !asOf.Before(start) {
+		if last := end.AddDate(0, 0, -1); last.Before(asOf) {
+			asOf = last
+		}
+		compliance, err = i.compliance(req.Meta.UserID, asOf)
+		if err != nil {
+			return model.Response{}, err
+		}
+	}
+
+	report, err := i.reporter.GeneratePDF(req.Meta.UserID, req.Name, start, end, filter, compliance)
 	if err != nil {
 		err = fmt.Errorf("failed to generate pdf report: %w", err)
 		return model.Response{}, err
@@ -33,9 +56,18 @@ func (i *Service) GetReportCSV(req model.GetReportCSVRequest) (model.Response, e
 		return model.Response{}, err
 	}
 
-	start, end := util.TrackingYearRange(req.Meta.Year, startMonth)
+	loc, err := i.location(req.Meta.UserID)
+	if err != nil {
+		return model.Response{}, err
+	}
+	start, end := util.TrackingYearRange(req.Meta.Year, startMonth, loc)
 
-	report, err := i.reporter.GenerateCSV(req.Meta.UserID, start, end)
+	filter, err := reportFilter(req.Source)
+	if err != nil {
+		return model.Response{}, err
+	}
+
+	report, err := i.reporter.GenerateCSV(req.Meta.UserID, start, end, filter, req.Detail)
 	if err != nil {
 		err = fmt.Errorf("failed to generate csv report: %w", err)
 		return model.Response{}, err
@@ -46,3 +78,13 @@ func (i *Service) GetReportCSV(req model.GetReportCSVRequest) (model.Response, e
 		Data:        report,
 	}, nil
 }
+
+// reportFilter builds the report filter for the requested sources.
+func reportFilter(sources []model.Source) (report.Filter, error) {
+	for _, source := range sources {
+		if !source.Valid() {
+			return report.Filter{}, invalid("source", "unknown source %q", source)
+		}
+	}
+	return report.Filter{Sources: sources}, nil
+}
@@ -0,0 +1,101 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/baely/officetracker/internal/database"
+	"github.com/baely/officetracker/internal/util"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+func (i *Service) ListScheduleRules(req model.ListScheduleRulesRequest) (model.ListScheduleRulesResponse, error) {
+	rules, err := i.db.GetScheduleRules(req.Meta.UserID)
+	if err != nil {
+		err = fmt.Errorf("failed to get schedule rules: %w", err)
+		return model.ListScheduleRulesResponse{}, err
+	}
+
+	return model.ListScheduleRulesResponse{
+		Data: rules,
+	}, nil
+}
+
+func (i *Service) CreateScheduleRule(req model.CreateScheduleRuleRequest) (model.CreateScheduleRuleResponse, error) {
+	rule, err := validateScheduleRule(req.Data)
+	if err != nil {
+		return model.CreateScheduleRuleResponse{}, err
+	}
+	rule.ID = 0
+
+	rule, err = i.db.SaveScheduleRule(req.Meta.UserID, rule)
+	if err != nil {
+		err = fmt.Errorf("failed to save schedule rule: %w", err)
+		return model.CreateScheduleRuleResponse{}, err
+	}
+
+	return model.CreateScheduleRuleResponse{
+		Data: rule,
+	}, nil
+}
+
+func (i *Service) UpdateScheduleRule(req model.UpdateScheduleRuleRequest) (model.UpdateScheduleRuleResponse, error) {
+	// Saving with ID 0 would add a rule rather than update one.
+	if req.Meta.RuleID <= 0 {
+		return model.UpdateScheduleRuleResponse{}, notFound("schedule rule %d not found", req.Meta.RuleID)
+	}
+	rule, err := validateScheduleRule(req.Data)
+	if err != nil {
+		return model.UpdateScheduleRuleResponse{}, err
+	}
+	rule.ID = req.Meta.RuleID
+
+	rule, err = i.db.SaveScheduleRule(req.Meta.UserID, rule)
+	if errors.Is(err, database.ErrNoScheduleRule) {
+		return model.UpdateScheduleRuleResponse{}, notFound("schedule rule %d not found", req.Meta.RuleID)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to save schedule rule: %w", err)
+		return model.UpdateScheduleRuleResponse{}, err
+	}
+
+	return model.UpdateScheduleRuleResponse{
+		Data: rule,
+	}, nil
+}
+
+func (i *Service) DeleteScheduleRule(req model.DeleteScheduleRuleRequest) (model.DeleteScheduleRuleResponse, error) {
+	err := i.db.DeleteScheduleRule(req.Meta.UserID, req.Meta.RuleID)
+	if errors.Is(err, database.ErrNoScheduleRule) {
+		return model.DeleteScheduleRuleResponse{}, notFound("schedule rule %d not found", req.Meta.RuleID)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to delete schedule rule: %w", err)
+		return model.DeleteScheduleRuleResponse{}, err
+	}
+
+	return model.DeleteScheduleRuleResponse{}, nil
+}
+
+func (i *Service) schedule(userID int) (util.Schedule, error) {
+	return database.LoadSchedule(i.db, userID)
+}
+
+// validateScheduleRule normalises the rule's recurrence and checks it can be
+// scheduled. Rules use the same states as the weekly schedule.
+func validateScheduleRule(rule model.ScheduleRule) (model.ScheduleRule, error) {
+	switch rule.State {
+	case model.StateUntracked, model.StateWorkFromHome, model.StateWorkFromOffice, model.StateOther:
+	default:
+		return model.ScheduleRule{}, invalid("data.state", "schedule rules can't use state %d", rule.State)
+	}
+
+	rule.RRule = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(rule.RRule)), "RRULE:")
+	rule.Start = strings.TrimSpace(rule.Start)
+	rule.End = strings.TrimSpace(rule.End)
+	if _, _, _, err := util.ParseScheduleRule(rule); err != nil {
+		return model.ScheduleRule{}, invalid("data", "invalid schedule rule: %v", err)
+	}
+	return rule, nil
+}
@@ -0,0 +1,119 @@
+package v1
+
+import (
+	"testing"
+
+	"github.com/baely/officetracker/internal/database/dbtest"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+// Created rules are normalised, listed and returned with the settings.
+func TestCreateScheduleRule(t *testing.T) {
+	db := dbtest.New()
+	svc := &Service{db: db}
+
+	resp, err := svc.CreateScheduleRule(model.CreateScheduleRuleRequest{
+		Meta: model.CreateScheduleRuleRequestMeta{UserID: 1},
+		Data: model.ScheduleRule{ID: 7, State: model.StateWorkFromOffice, RRule: " rrule:freq=weekly;interval=2;byday=mo ", Start: "2024-01-01 "},
+	})
+	if err != nil {
+		t.Fatalf("CreateScheduleRule: %v", err)
+	}
+	want := model.ScheduleRule{ID: 1, State: model.StateWorkFromOffice, RRule: "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO", Start: "2024-01-01"}
+	if resp.Data != want {
+		t.Errorf("created = %+v, want %+v", resp.Data, want)
+	}
+
+	list, err := svc.ListScheduleRules(model.ListScheduleRulesRequest{Meta: model.ListScheduleRulesRequestMeta{UserID: 1}})
+	if err != nil || len(list.Data) != 1 {
+		t.Fatalf("ListScheduleRules = (%+v, %v), want 1 rule", list.Data, err)
+	}
+	settings, err := svc.GetSettings(model.GetSettingsRequest{Meta: model.GetSettingsRequestMeta{UserID: 1}})
+	if err != nil || len(settings.ScheduleRules) != 1 {
+		t.Errorf("GetSettings schedule rules = (%+v, %v), want 1", settings.ScheduleRules, err)
+	}
+}
+
+func TestCreateScheduleRuleValidation(t *testing.T) {
+	cases := map[string]model.ScheduleRule{
+		"custom state":     {State: 100, RRule: "FREQ=DAILY", Start: "2024-01-01"},
+		"unsupported rule": {State: model.StateWorkFromOffice, RRule: "FREQ=YEARLY", Start: "2024-01-01"},
+		"no start":         {State: model.StateWorkFromOffice, RRule: "FREQ=DAILY"},
+		"end before start": {State: model.StateWorkFromOffice, RRule: "FREQ=DAILY", Start: "2024-02-01", End: "2024-01-01"},
+	}
+	for name, rule := range cases {
+		db := dbtest.New()
+		svc := &Service{db: db}
+		if _, err := svc.CreateScheduleRule(model.CreateScheduleRuleRequest{Data: rule}); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+		if rules, _ := db.GetScheduleRules(0); len(rules) != 0 {
+			t.Errorf("%s: invalid rule was saved", name)
+		}
+	}
+}
+
+func TestUpdateAndDeleteScheduleRule(t *testing.T) {
+	db := dbtest.New()
+	db.SaveScheduleRule(1, model.ScheduleRule{State: model.StateWorkFromOffice, RRule: "FREQ=DAILY", Start: "2024-01-01"})
+	svc := &Service{db: db}
+
+	resp, err := svc.UpdateScheduleRule(model.UpdateScheduleRuleRequest{
+		Meta: model.UpdateScheduleRuleRequestMeta{UserID: 1, RuleID: 1},
+		Data: model.ScheduleRule{State: model.StateWorkFromHome, RRule: "FREQ=MONTHLY;BYDAY=-1FR", Start: "2024-01-01", End: "2024-06-30"},
+	})
+	if err != nil {
+		t.Fatalf("UpdateScheduleRule: %v", err)
+	}
+	if rules, _ := db.GetScheduleRules(1); len(rules) != 1 || rules[0] != resp.Data || rules[0].End != "2024-06-30" {
+		t.Errorf("rules = %+v, want the updated rule", rules)
+	}
+
+	if _, err := svc.UpdateScheduleRule(model.UpdateScheduleRuleRequest{
+		Meta: model.UpdateScheduleRuleRequestMeta{UserID: 1, RuleID: 9},
+		Data: model.ScheduleRule{RRule: "FREQ=DAILY", Start: "2024-01-01"},
+	}); errCode(err) != model.ErrorCodeNotFound {
+		t.Errorf("updating a missing rule = %v, want not found", err)
+	}
+	if _, err := svc.UpdateScheduleRule(model.UpdateScheduleRuleRequest{
+		Meta: model.UpdateScheduleRuleRequestMeta{UserID: 1},
+		Data: model.ScheduleRule{RRule: "FREQ=DAILY", Start: "2024-01-01"},
+	}); errCode(err) != model.ErrorCodeNotFound {
+		t.Errorf("updating rule 0 = %v, want not found", err)
+	}
+	if rules, _ := db.GetScheduleRules(1); len(rules) != 1 {
+		t.Errorf("rules = %+v, want no rule added", rules)
+	}
+
+	if _, err := svc.DeleteScheduleRule(model.DeleteScheduleRuleRequest{Meta: model.DeleteScheduleRuleRequestMeta{UserID: 1, RuleID: 1}}); err != nil {
+		t.Fatalf("DeleteScheduleRule: %v", err)
+	}
+	if rules, _ := db.GetScheduleRules(1); len(rules) != 0 {
+		t.Errorf("rules after delete = %+v", rules)
+	}
+	if _, err := svc.DeleteScheduleRule(model.DeleteScheduleRuleRequest{Meta: model.DeleteScheduleRuleRequestMeta{UserID: 1, RuleID: 1}}); errCode(err) != model.ErrorCodeNotFound {
+		t.Errorf("deleting a missing rule = %v, want not found", err)
+	}
+}
+
+// Rules show up in the year's schedule alongside the weekly template.
+func TestGetYearScheduleRules(t *testing.T) {
+	db := dbtest.New()
+	db.SaveScheduleRule(1, model.ScheduleRule{State: model.StateWorkFromOffice, RRule: "FREQ=MONTHLY;BYDAY=1TH", Start: "2024-02-01", End: "2024-03-31"})
+	svc := &Service{db: db}
+
+	resp, err := svc.GetYear(model.GetYearRequest{Meta: model.GetYearRequestMeta{UserID: 1, Year: 2024}})
+	if err != nil {
+		t.Fatalf("GetYear: %v", err)
+	}
+	for month, days := range map[int][]int{2: {1}, 3: {7}} {
+		for _, d := range days {
+			if got := resp.Data.Months[month].Days[d]; got.State != model.StateScheduledWorkFromOffice {
+				t.Errorf("%d/%d = %+v, want scheduled in office", d, month, got)
+			}
+		}
+	}
+	if got, ok := resp.Data.Months[4].Days[4]; ok {
+		t.Errorf("4/4 = %+v, want nothing scheduled after the rule ends", got)
+	}
+}
@@ -1,6 +1,7 @@
 package v1
 
 import (
+	"errors"
 	"strings"
 	"testing"
 	"time"
@@ -11,6 +12,15 @@ import (
 	"github.com/baely/officetracker/pkg/model"
 )
 
+// errCode returns the code of a typed service error, or "" for any other.
+func errCode(err error) model.ErrorCode {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	return ""
+}
+
 // GetSettings decorates linked accounts with display names: known providers map
 // to a friendly label, unknown ones are title-cased. It also normalises the
 // calendar start month.
@@ -83,6 +93,33 @@ func TestUpdateCalendarPreferencesNormalises(t *testing.T) {
 	}
 }
 
+func TestUpdateCalendarPreferencesTimezone(t *testing.T) {
+	db := dbtest.New()
+	svc := &Service{db: db}
+
+	_, err := svc.UpdateCalendarPreferences(model.UpdateCalendarPreferencesRequest{
+		Meta: model.UpdateCalendarPreferencesRequestMeta{UserID: 1},
+		Data: model.CalendarPreferences{TrackingYearStartMonth: 7, Timezone: "Mars/Olympus"},
+	})
+	var verr *ValidationError
+	if !errors.As(err, &verr) || verr.Errors[0].Field != "data.timezone" {
+		t.Errorf("unknown timezone error = %v, want a data.timezone ValidationError", err)
+	}
+	if stored, _ := db.GetCalendarPreferences(1); stored.Timezone != "" {
+		t.Errorf("stored timezone = %q after a rejected update", stored.Timezone)
+	}
+
+	if _, err := svc.UpdateCalendarPreferences(model.UpdateCalendarPreferencesRequest{
+		Meta: model.UpdateCalendarPreferencesRequestMeta{UserID: 1},
+		Data: model.CalendarPreferences{TrackingYearStartMonth: 7, Timezone: " Europe/London "},
+	}); err != nil {
+		t.Fatalf("UpdateCalendarPreferences: %v", err)
+	}
+	if stored, _ := db.GetCalendarPreferences(1); stored.Timezone != "Europe/London" {
+		t.Errorf("stored timezone = %q, want Europe/London", stored.Timezone)
+	}
+}
+
 func TestUpdateThemeAndSchedulePreferences(t *testing.T) {
 	db := dbtest.New()
 	svc := &Service{db: db}
@@ -291,3 +328,194 @@ func TestHealthAndValidateAuth(t *testing.T) {
 		t.Errorf("ValidateAuth = (%+v, %v)", v, err)
 	}
 }
+
+// Changing the schedule keeps the old one for earlier days: the schedule from
+// before versions were kept becomes the open-ended first version.
+func TestUpdateSchedulePreferencesHistory(t *testing.T) {
+	db := dbtest.New()
+	db.SaveSchedulePreferences(1, model.SchedulePreferences{Monday: model.StateWorkFromHome})
+	svc := &Service{db: db}
+	update := func(from string, prefs model.SchedulePreferences) error {
+		_, err := svc.UpdateSchedulePreferences(model.UpdateSchedulePreferencesRequest{
+			Meta:          model.UpdateSchedulePreferencesRequestMeta{UserID: 1},
+			Data:          prefs,
+			EffectiveFrom: from,
+		})
+		return err
+	}
+
+	if err := update("2024-03-01", model.SchedulePreferences{Monday: model.StateWorkFromOffice}); err != nil {
+		t.Fatalf("UpdateSchedulePreferences: %v", err)
+	}
+	// A backdated change doesn't replace the later schedule as the current one.
+	if err := update("2024-02-01", model.SchedulePreferences{Tuesday: model.StateWorkFromOffice}); err != nil {
+		t.Fatalf("UpdateSchedulePreferences: %v", err)
+	}
+	if err := update("March", model.SchedulePreferences{}); err == nil {
+		t.Error("expected an error for an invalid effective date")
+	}
+
+	resp, err := svc.GetScheduleHistory(model.GetScheduleHistoryRequest{Meta: model.GetScheduleHistoryRequestMeta{UserID: 1}})
+	if err != nil {
+		t.Fatalf("GetScheduleHistory: %v", err)
+	}
+	if h := resp.Data; len(h) != 3 || h[0].EffectiveFrom != "" || h[0].Schedule.Monday != model.StateWorkFromHome ||
+		h[1].EffectiveFrom != "2024-02-01" || h[2].EffectiveFrom != "2024-03-01" {
+		t.Errorf("history = %+v", h)
+	}
+	if sched, _ := db.GetSchedulePreferences(1); sched.Monday != model.StateWorkFromOffice || sched.Tuesday != model.StateUntracked {
+		t.Errorf("current schedule = %+v, want the March version", sched)
+	}
+
+	year, err := svc.GetYear(model.GetYearRequest{Meta: model.GetYearRequestMeta{UserID: 1, Year: 2024}})
+	if err != nil {
+		t.Fatalf("GetYear: %v", err)
+	}
+	checks := []struct {
+		month, day int
+		want       model.State
+	}{
+		{1, 8, model.StateScheduledWorkFromHome},   // Monday under the original schedule
+		{2, 6, model.StateScheduledWorkFromOffice}, // Tuesday under the February version
+		{3, 4, model.StateScheduledWorkFromOffice}, // Monday under the March version
+	}
+	for _, c := range checks {
+		if got := year.Data.Months[c.month].Days[c.day].State; got != c.want {
+			t.Errorf("%d/%d = %d, want %d", c.day, c.month, got, c.want)
+		}
+	}
+	if day, ok := year.Data.Months[3].Days[5]; ok {
+		t.Errorf("5 March should not be scheduled, got %+v", day)
+	}
+}
+
+// A failed save leaves both the history and the current schedule as they were.
+func TestUpdateSchedulePreferencesError(t *testing.T) {
+	db := dbtest.New()
+	db.SaveSchedulePreferences(1, model.SchedulePreferences{Monday: model.StateWorkFromHome})
+	db.Errs = map[string]error{"SaveScheduleVersions": errInjected}
+	svc := &Service{db: db}
+	_, err := svc.UpdateSchedulePreferences(model.UpdateSchedulePreferencesRequest{
+		Meta:          model.UpdateSchedulePreferencesRequestMeta{UserID: 1},
+		Data:          model.SchedulePreferences{Monday: model.StateWorkFromOffice},
+		EffectiveFrom: "2024-03-01",
+	})
+	if err == nil {
+		t.Fatal("expected UpdateSchedulePreferences to propagate the save error")
+	}
+	if h, _ := db.GetScheduleHistory(1); len(h) != 0 {
+		t.Errorf("history = %+v, want none", h)
+	}
+	if sched, _ := db.GetSchedulePreferences(1); sched.Monday != model.StateWorkFromHome {
+		t.Errorf("current schedule = %+v, want it unchanged", sched)
+	}
+}
+
+// namedSetting drives the checks shared by the user's named settings, custom
+// states and locations, through their service methods.
+type namedSetting struct {
+	kind   string
+	seed   func(db *dbtest.Fake, name string, archived bool) int
+	create func(svc *Service, name string) error
+	update func(svc *Service, id int, name string) error
+	count  func(db *dbtest.Fake) int
+}
+
+var namedSettings = []namedSetting{
+	{
+		kind: "custom state",
+		seed: func(db *dbtest.Fake, name string, archived bool) int {
+			state, _ := db.SaveCustomState(1, model.CustomState{Name: name, Color: "#000000", Attendance: model.AttendanceAbsent, Archived: archived})
+			return int(state.ID)
+		},
+		create: func(svc *Service, name string) error {
+			_, err := svc.CreateCustomState(model.CreateCustomStateRequest{
+				Meta: model.CreateCustomStateRequestMeta{UserID: 1},
+				Data: model.CustomState{Name: name, Color: "#123456", Attendance: model.AttendancePresent},
+			})
+			return err
+		},
+		update: func(svc *Service, id int, name string) error {
+			_, err := svc.UpdateCustomState(model.UpdateCustomStateRequest{
+				Meta: model.UpdateCustomStateRequestMeta{UserID: 1, StateID: id},
+				Data: model.CustomState{Name: name, Color: "#123456", Attendance: model.AttendancePresent},
+			})
+			return err
+		},
+		count: func(db *dbtest.Fake) int {
+			states, _ := db.GetCustomStates(1)
+			return len(states)
+		},
+	},
+	{
+		kind: "location",
+		seed: func(db *dbtest.Fake, name string, archived bool) int {
+			location, _ := db.SaveLocation(1, model.Location{Name: name, Archived: archived})
+			return location.ID
+		},
+		create: func(svc *Service, name string) error {
+			_, err := svc.CreateLocation(model.CreateLocationRequest{
+				Meta: model.CreateLocationRequestMeta{UserID: 1},
+				Data: model.Location{Name: name},
+			})
+			return err
+		},
+		update: func(svc *Service, id int, name string) error {
+			_, err := svc.UpdateLocation(model.UpdateLocationRequest{
+				Meta: model.UpdateLocationRequestMeta{UserID: 1, LocationID: id},
+				Data: model.Location{Name: name},
+			})
+			return err
+		},
+		count: func(db *dbtest.Fake) int {
+			locations, _ := db.GetLocations(1)
+			return len(locations)
+		},
+	},
+}
+
+// Named settings need a name, and active ones can't share one, ignoring case,
+// though an archived one's name can be reused. Updates only apply to an
+// existing ID and never create anything.
+func TestNamedSettings(t *testing.T) {
+	cases := []struct {
+		name string
+		run  func(s namedSetting, svc *Service, travel, leave int) error
+		want model.ErrorCode
+		// created is how many settings the case adds to the three seeded.
+		created int
+	}{
+		{"blank name", func(s namedSetting, svc *Service, _, _ int) error { return s.create(svc, "   ") }, model.ErrorCodeValidation, 0},
+		{"duplicate name", func(s namedSetting, svc *Service, _, _ int) error { return s.create(svc, " travel ") }, model.ErrorCodeConflict, 0},
+		{"rename to a taken name", func(s namedSetting, svc *Service, _, leave int) error { return s.update(svc, leave, "Travel") }, model.ErrorCodeConflict, 0},
+		{"re-save own name", func(s namedSetting, svc *Service, travel, _ int) error { return s.update(svc, travel, "TRAVEL") }, "", 0},
+		{"reuse an archived name", func(s namedSetting, svc *Service, _, _ int) error { return s.create(svc, "Training") }, "", 1},
+		{"update an unknown ID", func(s namedSetting, svc *Service, _, _ int) error { return s.update(svc, 999, "Conference") }, model.ErrorCodeNotFound, 0},
+		{"update ID 0", func(s namedSetting, svc *Service, _, _ int) error { return s.update(svc, 0, "Conference") }, model.ErrorCodeNotFound, 0},
+	}
+	for _, s := range namedSettings {
+		for _, c := range cases {
+			db := dbtest.New()
+			travel := s.seed(db, "Travel", false)
+			leave := s.seed(db, "Leave", false)
+			s.seed(db, "Training", true)
+			svc := &Service{db: db}
+
+			err := c.run(s, svc, travel, leave)
+			if c.want == "" && err != nil {
+				t.Errorf("%s, %s: %v", s.kind, c.name, err)
+			}
+			if c.want == model.ErrorCodeValidation {
+				var verr *ValidationError
+				if !errors.As(err, &verr) {
+					t.Errorf("%s, %s = %v, want a ValidationError", s.kind, c.name, err)
+				}
+			} else if c.want != "" && errCode(err) != c.want {
+				t.Errorf("%s, %s = %v, want %s", s.kind, c.name, err, c.want)
+			}
+			if got := s.count(db); got != 3+c.created {
+				t.Errorf("%s, %s: %d saved, want %d", s.kind, c.name, got, 3+c.created)
+			}
+		}
+	}
+}
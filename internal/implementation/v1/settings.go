@@ -1,6 +1,10 @@
 package v1
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 
@@ -58,12 +62,48 @@ func (i *Service) GetSettings(req model.GetSettingsRequest) (model.GetSettingsRe
 		return model.GetSettingsResponse{}, err
 	}
 
+	customStates, err := i.db.GetCustomStates(req.Meta.UserID)
+	if err != nil {
+		return model.GetSettingsResponse{}, err
+	}
+
+	locations, err := i.db.GetLocations(req.Meta.UserID)
+	if err != nil {
+		return model.GetSettingsResponse{}, err
+	}
+
+	scheduleRules, err := i.db.GetScheduleRules(req.Meta.UserID)
+	if err != nil {
+		return model.GetSettingsResponse{}, err
+	}
+
+	networkRules, err := i.db.GetNetworkRules(req.Meta.UserID)
+	if err != nil {
+		return model.GetSettingsResponse{}, err
+	}
+
+	materialisePrefs, err := i.db.GetMaterialisePreferences(req.Meta.UserID)
+	if err != nil {
+		return model.GetSettingsResponse{}, err
+	}
+
+	notificationPrefs, err := i.db.GetNotificationPreferences(req.Meta.UserID)
+	if err != nil {
+		return model.GetSettingsResponse{}, err
+	}
+
 	return model.GetSettingsResponse{
-		LinkedAccounts:      linkedAccounts,
-		ThemePreferences:    themePrefs,
-		SchedulePreferences: schedulePrefs,
-		CalendarPreferences: calendarPrefs,
-		TargetPreferences:   targetPrefs,
+		LinkedAccounts:          linkedAccounts,
+		ThemePreferences:        themePrefs,
+		SchedulePreferences:     schedulePrefs,
+		CalendarPreferences:     calendarPrefs,
+		TargetPreferences:       targetPrefs,
+		CustomStates:            customStates,
+		Locations:               locations,
+		ScheduleRules:           scheduleRules,
+		NetworkRules:            networkRules,
+		MaterialisePreferences:  materialisePrefs,
+		NotificationPreferences: notificationPrefs,
 	}, nil
 }
 
@@ -72,19 +112,83 @@ func (i *Service) UpdateThemePreferences(req model.UpdateThemePreferencesRequest
 	return model.UpdateThemePreferencesResponse{}, err
 }
 
+// UpdateSchedulePreferences saves a new version of the weekly schedule from
+// its effective date, so days before it keep the schedule they had. The
+// schedule a user had before versions were kept becomes the open-ended first
+// version, and the stored preferences always hold the latest version.
 func (i *Service) UpdateSchedulePreferences(req model.UpdateSchedulePreferencesRequest) (model.UpdateSchedulePreferencesResponse, error) {
-	err := i.db.SaveSchedulePreferences(req.Meta.UserID, req.Data)
-	return model.UpdateSchedulePreferencesResponse{}, err
+	userID := req.Meta.UserID
+	loc, err := i.location(userID)
+	if err != nil {
+		return model.UpdateSchedulePreferencesResponse{}, err
+	}
+	from := util.Today(loc).Format("2006-01-02")
+	if req.EffectiveFrom != "" {
+		date, err := time.Parse("2006-01-02", strings.TrimSpace(req.EffectiveFrom))
+		if err != nil {
+			return model.UpdateSchedulePreferencesResponse{}, invalid("effective_from", "invalid date %q, want YYYY-MM-DD", req.EffectiveFrom)
+		}
+		from = date.Format("2006-01-02")
+	}
+
+	history, err := i.db.GetScheduleHistory(userID)
+	if err != nil {
+		return model.UpdateSchedulePreferencesResponse{}, fmt.Errorf("failed to get schedule history: %w", err)
+	}
+	var versions []model.ScheduleVersion
+	if len(history) == 0 {
+		previous, err := i.db.GetSchedulePreferences(userID)
+		if err != nil {
+			return model.UpdateSchedulePreferencesResponse{}, fmt.Errorf("failed to get schedule preferences: %w", err)
+		}
+		versions = append(versions, model.ScheduleVersion{Schedule: previous})
+	}
+	versions = append(versions, model.ScheduleVersion{EffectiveFrom: from, Schedule: req.Data})
+
+	latest := req.Data
+	for _, version := range history {
+		if version.EffectiveFrom > from {
+			latest = version.Schedule
+		}
+	}
+	if err := i.db.SaveScheduleVersions(userID, latest, versions...); err != nil {
+		return model.UpdateSchedulePreferencesResponse{}, fmt.Errorf("failed to save schedule version: %w", err)
+	}
+	return model.UpdateSchedulePreferencesResponse{}, nil
+}
+
+func (i *Service) GetScheduleHistory(req model.GetScheduleHistoryRequest) (model.GetScheduleHistoryResponse, error) {
+	history, err := i.db.GetScheduleHistory(req.Meta.UserID)
+	if err != nil {
+		err = fmt.Errorf("failed to get schedule history: %w", err)
+		return model.GetScheduleHistoryResponse{}, err
+	}
+
+	return model.GetScheduleHistoryResponse{
+		Data: history,
+	}, nil
 }
 
 func (i *Service) UpdateCalendarPreferences(req model.UpdateCalendarPreferencesRequest) (model.UpdateCalendarPreferencesResponse, error) {
 	req.Data.TrackingYearStartMonth = util.NormaliseStartMonth(req.Data.TrackingYearStartMonth)
+	req.Data.Timezone = strings.TrimSpace(req.Data.Timezone)
+	if err := util.ValidateTimezone(req.Data.Timezone); err != nil {
+		return model.UpdateCalendarPreferencesResponse{}, invalid("data.timezone", "unknown timezone %q", req.Data.Timezone)
+	}
 	err := i.db.SaveCalendarPreferences(req.Meta.UserID, req.Data)
 	return model.UpdateCalendarPreferencesResponse{}, err
 }
 
+// UpdateTargetPreferences saves the user's target. Clients that only send
+// target_percent set a percentage per calendar month, as before policies.
 func (i *Service) UpdateTargetPreferences(req model.UpdateTargetPreferencesRequest) (model.UpdateTargetPreferencesResponse, error) {
-	req.Data.TargetPercent = util.ClampTargetPercent(req.Data.TargetPercent)
+	if req.Data.Policy != "" && !req.Data.Policy.Valid() {
+		return model.UpdateTargetPreferencesResponse{}, invalid("data.policy", "unknown target policy %q", req.Data.Policy)
+	}
+	if req.Data.Window != "" && !req.Data.Window.Valid() {
+		return model.UpdateTargetPreferencesResponse{}, invalid("data.window", "unknown target window %q", req.Data.Window)
+	}
+	req.Data = util.NormaliseTargetPreferences(req.Data)
 	err := i.db.SaveTargetPreferences(req.Meta.UserID, req.Data)
 	return model.UpdateTargetPreferencesResponse{}, err
 }
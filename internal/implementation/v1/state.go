@@ -2,8 +2,11 @@ package v1
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 	"time"
 
+	"github.com/baely/officetracker/internal/database"
 	"github.com/baely/officetracker/internal/util"
 	"github.com/baely/officetracker/pkg/model"
 )
@@ -18,6 +21,15 @@ func (i *Service) trackingStartMonth(userID int) (int, error) {
 	return util.NormaliseStartMonth(prefs.TrackingYearStartMonth), nil
 }
 
+// location returns the timezone the user's days start and end in.
+func (i *Service) location(userID int) (*time.Location, error) {
+	prefs, err := i.db.GetCalendarPreferences(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get calendar preferences: %w", err)
+	}
+	return util.Location(prefs.Timezone), nil
+}
+
 func (i *Service) GetDay(req model.GetDayRequest) (model.GetDayResponse, error) {
 	state, err := i.db.GetDay(req.Meta.UserID, req.Meta.Day, req.Meta.Month, req.Meta.Year)
 	if err != nil {
@@ -31,15 +43,123 @@ func (i *Service) GetDay(req model.GetDayRequest) (model.GetDayResponse, error)
 }
 
 func (i *Service) PutDay(req model.PutDayRequest) (model.PutDayResponse, error) {
-	err := i.db.SaveDay(req.Meta.UserID, req.Meta.Day, req.Meta.Month, req.Meta.Year, req.Data)
+	var v validator
+	v.date(req.Meta.Year, req.Meta.Month, req.Meta.Day, "day")
+	v.dayState("data", req.Data)
+	if err := v.err(); err != nil {
+		return model.PutDayResponse{}, err
+	}
+	now := time.Now()
+	state, err := stampDayState(req.Data, now)
+	if err != nil {
+		return model.PutDayResponse{}, err
+	}
+	if err = i.checkDays(req.Meta.UserID, state); err != nil {
+		return model.PutDayResponse{}, err
+	}
+	notify, err := i.webhookNotifier(req.Meta.UserID)
+	if err != nil {
+		return model.PutDayResponse{}, err
+	}
+
+	previous, err := i.db.GetDay(req.Meta.UserID, req.Meta.Day, req.Meta.Month, req.Meta.Year)
+	if err != nil {
+		err = fmt.Errorf("failed to get day: %w", err)
+		return model.PutDayResponse{}, err
+	}
+	if state.Note == "" {
+		state.Note = previous.Note
+	}
+	if state.LocationID == 0 && state.State == previous.State {
+		state.LocationID = previous.LocationID
+	}
+
+	// The day and its history are saved together, so the audit log never
+	// misses a change that was saved.
+	var changes []database.DayChange
+	if previous.State != state.State {
+		author := changeAuthor{authMethod: req.Meta.AuthMethod, tokenID: req.Meta.AuthTokenID, via: req.Meta.Via}
+		changes = append(changes, author.dayChange(req.Meta.Day, req.Meta.Month, req.Meta.Year, previous, state, now))
+	}
+	entry := database.Entry{Day: req.Meta.Day, Month: req.Meta.Month, Year: req.Meta.Year, State: state}
+	err = i.db.SaveEntries(req.Meta.UserID, []database.Entry{entry}, changes)
 	if err != nil {
 		err = fmt.Errorf("failed to save day: %w", err)
 		return model.PutDayResponse{}, err
 	}
 
+	if previous.State != state.State {
+		date := time.Date(req.Meta.Year, time.Month(req.Meta.Month), req.Meta.Day, 0, 0, 0, 0, time.UTC)
+		notify.queue(model.WebhookEventDayUpdated, model.DayUpdatedEvent{
+			Date:     date.Format(time.DateOnly),
+			Previous: previous,
+			State:    state,
+		}, now)
+		notify.checkTarget(map[time.Time]model.DayState{date: state}, now)
+
+		// The app is waiting on the request in the background, so it isn't
+		// held up by Expo.
+		if i.devices != nil && state.Source == model.SourceGeofence && state.State == model.StateWorkFromOffice {
+			i.queueCheckIn(req.Meta.UserID, date, state)
+		}
+	}
+
 	return model.PutDayResponse{}, nil
 }
 
+// stampDayState prepares a day for saving: the source defaults to manual and
+// must be recognised, the update time is set by the server, and a day written
+// through the API counts as confirmed.
+func stampDayState(state model.DayState, now time.Time) (model.DayState, error) {
+	if state.Source == "" {
+		state.Source = model.SourceManual
+	}
+	if !state.Source.Valid() {
+		return model.DayState{}, invalid("source", "unknown source %q", state.Source)
+	}
+	state.UpdatedAt = now.UTC()
+	state.Unconfirmed = false
+	return state, nil
+}
+
+// checkDays rejects writes that use a custom state or location the user has
+// not defined, or that give a location to a day that doesn't count as
+// present. Archived states and locations stay valid so existing days can be
+// re-saved.
+func (i *Service) checkDays(userID int, days ...model.DayState) error {
+	var customs model.CustomStates
+	var locations model.Locations
+	var loaded bool
+	for _, day := range days {
+		if !day.State.IsCustom() && day.LocationID == 0 {
+			continue
+		}
+		if !loaded {
+			var err error
+			if customs, err = i.db.GetCustomStates(userID); err != nil {
+				return fmt.Errorf("failed to get custom states: %w", err)
+			}
+			if locations, err = i.db.GetLocations(userID); err != nil {
+				return fmt.Errorf("failed to get locations: %w", err)
+			}
+			loaded = true
+		}
+		if _, ok := customs.Get(day.State); day.State.IsCustom() && !ok {
+			return invalid("state", "unknown custom state %d", day.State)
+		}
+		if day.LocationID == 0 {
+			continue
+		}
+		if _, ok := locations.Get(day.LocationID); !ok {
+			return invalid("location_id", "unknown location %d", day.LocationID)
+		}
+		if customs.Attendance(day.State) != model.AttendancePresent {
+			return invalid("location_id", "only days in the office can have a location")
+		}
+	}
+	return nil
+}
+
 func (i *Service) GetMonth(req model.GetMonthRequest) (model.GetMonthResponse, error) {
 	state, err := i.db.GetMonth(req.Meta.UserID, req.Meta.Month, req.Meta.Year)
 	if err != nil {
@@ -53,15 +173,98 @@ func (i *Service) GetMonth(req model.GetMonthRequest) (model.GetMonthResponse, e
 }
 
 func (i *Service) PutMonth(req model.PutMonthRequest) (model.PutMonthResponse, error) {
-	err := i.db.SaveMonth(req.Meta.UserID, req.Meta.Month, req.Meta.Year, req.Data)
-	if err != nil {
-		err = fmt.Errorf("failed to save month: %w", err)
+	var v validator
+	if v.month(req.Meta.Year, req.Meta.Month) {
+		for _, day := range slices.Sorted(maps.Keys(req.Data.Days)) {
+			field := fmt.Sprintf("data.days.%d", day)
+			v.date(req.Meta.Year, req.Meta.Month, day, field)
+			v.dayState(field, req.Data.Days[day])
+		}
+	}
+	if err := v.err(); err != nil {
+		return model.PutMonthResponse{}, err
+	}
+
+	now := time.Now()
+	days := make(map[time.Time]model.DayState, len(req.Data.Days))
+	for day, dayState := range req.Data.Days {
+		state, err := stampDayState(dayState, now)
+		if err != nil {
+			return model.PutMonthResponse{}, err
+		}
+		days[time.Date(req.Meta.Year, time.Month(req.Meta.Month), day, 0, 0, 0, 0, time.UTC)] = state
+	}
+	if err := i.checkDays(req.Meta.UserID, slices.Collect(maps.Values(days))...); err != nil {
+		return model.PutMonthResponse{}, err
+	}
+
+	author := changeAuthor{authMethod: req.Meta.AuthMethod, tokenID: req.Meta.AuthTokenID, via: req.Meta.Via}
+	if err := i.saveDays(req.Meta.UserID, author, days, now); err != nil {
 		return model.PutMonthResponse{}, err
 	}
 
 	return model.PutMonthResponse{}, nil
 }
 
+// saveDays saves days that have been stamped and checked, across any number
+// of months, together with their history in one transaction. A day without a
+// note keeps its old one, as does its location if the state is unchanged.
+// Each month with a changed day gets a month.updated webhook event.
+func (i *Service) saveDays(userID int, author changeAuthor, days map[time.Time]model.DayState, now time.Time) error {
+	notify, err := i.webhookNotifier(userID)
+	if err != nil {
+		return err
+	}
+
+	months := newMonthCache(i, userID)
+	var entries []database.Entry
+	var changes []database.DayChange
+	changed := make(map[time.Time]map[int]model.DayState)
+	written := make(map[time.Time]model.DayState)
+	for _, day := range slices.SortedFunc(maps.Keys(days), time.Time.Compare) {
+		month, err := months.get(day)
+		if err != nil {
+			return err
+		}
+		previous := month.Days[day.Day()]
+		state := days[day]
+		if state.Note == "" {
+			state.Note = previous.Note
+		}
+		if state.LocationID == 0 && state.State == previous.State {
+			state.LocationID = previous.LocationID
+		}
+
+		entries = append(entries, database.Entry{Day: day.Day(), Month: int(day.Month()), Year: day.Year(), State: state})
+		if previous.State == state.State {
+			continue
+		}
+		changes = append(changes, author.dayChange(day.Day(), int(day.Month()), day.Year(), previous, state, now))
+		key := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+		if changed[key] == nil {
+			changed[key] = make(map[int]model.DayState)
+		}
+		changed[key][day.Day()] = state
+		written[day] = state
+	}
+
+	if err := i.db.SaveEntries(userID, entries, changes); err != nil {
+		return fmt.Errorf("failed to save entries: %w", err)
+	}
+
+	for _, key := range slices.SortedFunc(maps.Keys(changed), time.Time.Compare) {
+		notify.queue(model.WebhookEventMonthUpdated, model.MonthUpdatedEvent{
+			Year:  key.Year(),
+			Month: int(key.Month()),
+			Days:  changed[key],
+		}, now)
+	}
+	if len(written) > 0 {
+		notify.checkTarget(written, now)
+	}
+	return nil
+}
+
 func (i *Service) GetYear(req model.GetYearRequest) (model.GetYearResponse, error) {
 	startMonth, err := i.trackingStartMonth(req.Meta.UserID)
 	if err != nil {
@@ -74,15 +277,26 @@ func (i *Service) GetYear(req model.GetYearRequest) (model.GetYearResponse, erro
 		return model.GetYearResponse{}, err
 	}
 
-	// Get schedule preferences to merge with actual state
-	schedulePrefs, err := i.db.GetSchedulePreferences(req.Meta.UserID)
+	// Count office days per week before scheduled days are merged in
+	loc, err := i.location(req.Meta.UserID)
+	if err != nil {
+		return model.GetYearResponse{}, err
+	}
+	customStates, err := i.db.GetCustomStates(req.Meta.UserID)
+	if err != nil {
+		return model.GetYearResponse{}, fmt.Errorf("failed to get custom states: %w", err)
+	}
+	weeks := weekSummaries(state, customStates, req.Meta.Year, startMonth, loc)
+
+	// Get the schedule to merge with actual state
+	schedule, err := i.schedule(req.Meta.UserID)
 	if err != nil {
-		err = fmt.Errorf("failed to get schedule preferences: %w", err)
 		return model.GetYearResponse{}, err
 	}
 
-	// Merge schedule preferences with actual state
-	mergedState := i.mergeScheduleWithYear(state, schedulePrefs, req.Meta.Year, startMonth)
+	// Merge the schedule with actual state
+	mergedState := i.mergeScheduleWithYear(state, schedule, req.Meta.Year, startMonth)
+	mergedState.Weeks = weeks
 
 	return model.GetYearResponse{
 		Data: mergedState,
@@ -111,6 +325,44 @@ func (i *Service) PutNote(req model.PutNoteRequest) (model.PutNoteResponse, erro
 	return model.PutNoteResponse{}, nil
 }
 
+func (i *Service) GetDayNote(req model.GetDayNoteRequest) (model.GetDayNoteResponse, error) {
+	state, err := i.db.GetDay(req.Meta.UserID, req.Meta.Day, req.Meta.Month, req.Meta.Year)
+	if err != nil {
+		err = fmt.Errorf("failed to get day: %w", err)
+		return model.GetDayNoteResponse{}, err
+	}
+
+	return model.GetDayNoteResponse{
+		Data: model.Note{Note: state.Note},
+	}, nil
+}
+
+// PutDayNote sets or clears a day's note, leaving the day's state untouched.
+func (i *Service) PutDayNote(req model.PutDayNoteRequest) (model.PutDayNoteResponse, error) {
+	var v validator
+	v.date(req.Meta.Year, req.Meta.Month, req.Meta.Day, "day")
+	if err := v.err(); err != nil {
+		return model.PutDayNoteResponse{}, err
+	}
+	state, err := i.db.GetDay(req.Meta.UserID, req.Meta.Day, req.Meta.Month, req.Meta.Year)
+	if err != nil {
+		err = fmt.Errorf("failed to get day: %w", err)
+		return model.PutDayNoteResponse{}, err
+	}
+	if state.Note == req.Data.Note {
+		return model.PutDayNoteResponse{}, nil
+	}
+
+	state.Note = req.Data.Note
+	err = i.db.SaveDay(req.Meta.UserID, req.Meta.Day, req.Meta.Month, req.Meta.Year, state)
+	if err != nil {
+		err = fmt.Errorf("failed to save day note: %w", err)
+		return model.PutDayNoteResponse{}, err
+	}
+
+	return model.PutDayNoteResponse{}, nil
+}
+
 func (i *Service) GetNotes(req model.GetNotesRequest) (model.GetNotesResponse, error) {
 	startMonth, err := i.trackingStartMonth(req.Meta.UserID)
 	if err != nil {
@@ -128,22 +380,11 @@ func (i *Service) GetNotes(req model.GetNotesRequest) (model.GetNotesResponse, e
 	}, nil
 }
 
-// mergeScheduleWithYear merges schedule preferences with actual state data for a year
-func (i *Service) mergeScheduleWithYear(yearState model.YearState, schedulePrefs model.SchedulePreferences, year int, startMonth int) model.YearState {
+// mergeScheduleWithYear merges the schedule with actual state data for a year
+func (i *Service) mergeScheduleWithYear(yearState model.YearState, schedule util.Schedule, year int, startMonth int) model.YearState {
 	startMonth = util.NormaliseStartMonth(startMonth)
 	firstYear, secondYear := util.TrackingYearCalendarYears(year, startMonth)
 
-	// Create a map for day of week to schedule state
-	dayOfWeekToState := map[time.Weekday]model.State{
-		time.Sunday:    schedulePrefs.Sunday,
-		time.Monday:    schedulePrefs.Monday,
-		time.Tuesday:   schedulePrefs.Tuesday,
-		time.Wednesday: schedulePrefs.Wednesday,
-		time.Thursday:  schedulePrefs.Thursday,
-		time.Friday:    schedulePrefs.Friday,
-		time.Saturday:  schedulePrefs.Saturday,
-	}
-
 	// Process each month
 	for month := 1; month <= 12; month++ {
 		// Determine which calendar year this month belongs to within the tracking year
@@ -170,7 +411,6 @@ func (i *Service) mergeScheduleWithYear(yearState model.YearState, schedulePrefs
 		// Process each day in the month
 		for day := 1; day <= daysInMonth; day++ {
 			date := time.Date(monthYear, time.Month(month), day, 0, 0, 0, 0, time.UTC)
-			dayOfWeek := date.Weekday()
 			
 			// Check if this day has actual state data
 			monthState := yearState.Months[month]
@@ -182,22 +422,8 @@ func (i *Service) mergeScheduleWithYear(yearState model.YearState, schedulePrefs
 			shouldShowScheduled := !hasActualState || (hasActualState && dayState.State == model.StateUntracked)
 			
 			if shouldShowScheduled {
-				// Check if there's a schedule for this day
-				if scheduledState := dayOfWeekToState[dayOfWeek]; scheduledState != model.StateUntracked {
-					// Convert regular state to scheduled state
-					var actualScheduledState model.State
-					switch scheduledState {
-					case model.StateWorkFromHome:
-						actualScheduledState = model.StateScheduledWorkFromHome
-					case model.StateWorkFromOffice:
-						actualScheduledState = model.StateScheduledWorkFromOffice
-					case model.StateOther:
-						actualScheduledState = model.StateScheduledOther
-					default:
-						continue // Skip untracked
-					}
-					// Add/update with scheduled state
-					monthState.Days[day] = model.DayState{State: actualScheduledState}
+				if scheduled, ok := scheduledDay(schedule, date, dayState); ok {
+					monthState.Days[day] = scheduled
 					yearState.Months[month] = monthState
 				}
 			}
@@ -206,3 +432,20 @@ func (i *Service) mergeScheduleWithYear(yearState model.YearState, schedulePrefs
 
 	return yearState
 }
+
+// scheduledDay returns how an untracked day shows when the schedule covers
+// it, keeping its note, and reports whether the schedule covers it.
+func scheduledDay(schedule util.Schedule, date time.Time, day model.DayState) (model.DayState, bool) {
+	var state model.State
+	switch schedule.State(date) {
+	case model.StateWorkFromHome:
+		state = model.StateScheduledWorkFromHome
+	case model.StateWorkFromOffice:
+		state = model.StateScheduledWorkFromOffice
+	case model.StateOther:
+		state = model.StateScheduledOther
+	default:
+		return model.DayState{}, false
+	}
+	return model.DayState{State: state, Source: model.SourceSchedule, Note: day.Note}, true
+}
@@ -2,9 +2,12 @@ package v1
 
 import (
 	"errors"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/baely/officetracker/internal/database/dbtest"
+	"github.com/baely/officetracker/internal/util"
 	"github.com/baely/officetracker/pkg/model"
 )
 
@@ -32,7 +35,7 @@ func TestMergeScheduleWithYear(t *testing.T) {
 		Tuesday:   model.StateUntracked, // untracked schedule contributes nothing
 	}
 
-	got := svc.mergeScheduleWithYear(year, sched, 2024, 1)
+	got := svc.mergeScheduleWithYear(year, util.NewSchedule(sched, nil, nil), 2024, 1)
 	days := got.Months[1].Days
 
 	checks := []struct {
@@ -57,13 +60,50 @@ func TestMergeScheduleWithYear(t *testing.T) {
 	}
 }
 
+// Schedule rules override the weekly schedule within their dates: here a
+// fortnightly office rotation on Mondays and Wednesdays, with the first
+// Friday of each month cleared.
+func TestMergeScheduleWithYearRules(t *testing.T) {
+	svc := &Service{}
+	sched := model.SchedulePreferences{
+		Monday: model.StateWorkFromHome,
+		Friday: model.StateWorkFromHome,
+	}
+	rules := model.ScheduleRules{
+		{State: model.StateWorkFromOffice, RRule: "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE", Start: "2024-01-01", End: "2024-01-31"},
+		{State: model.StateUntracked, RRule: "FREQ=MONTHLY;BYDAY=1FR", Start: "2024-01-01"},
+	}
+
+	got := svc.mergeScheduleWithYear(model.YearState{}, util.NewSchedule(sched, nil, rules), 2024, 1)
+	checks := []struct {
+		month, day int
+		want       model.State
+	}{
+		{1, 1, model.StateScheduledWorkFromOffice},  // week A Monday
+		{1, 3, model.StateScheduledWorkFromOffice},  // week A Wednesday
+		{1, 8, model.StateScheduledWorkFromHome},    // week B Monday falls back to the weekly schedule
+		{1, 15, model.StateScheduledWorkFromOffice}, // week A again
+		{2, 12, model.StateScheduledWorkFromHome},   // week A, but after the rule ends
+		{1, 12, model.StateScheduledWorkFromHome},   // second Friday
+	}
+	for _, c := range checks {
+		if state := got.Months[c.month].Days[c.day].State; state != c.want {
+			t.Errorf("%d/%d = %d, want %d", c.day, c.month, state, c.want)
+		}
+	}
+	// The first Friday of January is cleared.
+	if day, ok := got.Months[1].Days[5]; ok {
+		t.Errorf("Jan 5 should not be scheduled, got %+v", day)
+	}
+}
+
 // The merge must cope with a completely empty year (nil Months map) and still
 // produce scheduled overlays.
 func TestMergeScheduleWithYearEmptyInput(t *testing.T) {
 	svc := &Service{}
 	sched := model.SchedulePreferences{Monday: model.StateWorkFromHome}
 
-	got := svc.mergeScheduleWithYear(model.YearState{}, sched, 2024, 1)
+	got := svc.mergeScheduleWithYear(model.YearState{}, util.NewSchedule(sched, nil, nil), 2024, 1)
 	if got.Months == nil {
 		t.Fatal("merge did not initialise Months map")
 	}
@@ -97,6 +137,123 @@ func TestGetDayPutDay(t *testing.T) {
 	}
 }
 
+// PutDay defaults the source to manual and stamps the write time.
+func TestPutDaySourceAndTimestamp(t *testing.T) {
+	db := dbtest.New()
+	svc := &Service{db: db}
+
+	before := time.Now().UTC()
+	put := func(source model.Source) error {
+		_, err := svc.PutDay(model.PutDayRequest{
+			Meta: model.PutDayRequestMeta{UserID: 1, Year: 2024, Month: 3, Day: 5},
+			Data: model.DayState{State: model.StateWorkFromOffice, Source: source},
+		})
+		return err
+	}
+
+	if err := put(""); err != nil {
+		t.Fatalf("PutDay: %v", err)
+	}
+	got, _ := db.GetDay(1, 5, 3, 2024)
+	if got.Source != model.SourceManual {
+		t.Errorf("source = %q, want manual", got.Source)
+	}
+	if got.UpdatedAt.Before(before) {
+		t.Errorf("UpdatedAt = %v, want at or after %v", got.UpdatedAt, before)
+	}
+
+	// Re-recording the same state from another source updates the source but
+	// isn't a change to the day's history.
+	if err := put(model.SourceGeofence); err != nil {
+		t.Fatalf("PutDay: %v", err)
+	}
+	got, _ = db.GetDay(1, 5, 3, 2024)
+	if got.Source != model.SourceGeofence {
+		t.Errorf("source = %q, want geofence", got.Source)
+	}
+	if history, _ := db.GetDayHistory(1, 5, 3, 2024); len(history) != 1 {
+		t.Errorf("got %d history entries, want 1", len(history))
+	}
+
+	if err := put("carrier-pigeon"); err == nil {
+		t.Error("expected PutDay to reject an unknown source")
+	}
+}
+
+func TestPutMonthRejectsUnknownSource(t *testing.T) {
+	db := dbtest.New()
+	svc := &Service{db: db}
+	_, err := svc.PutMonth(model.PutMonthRequest{
+		Meta: model.PutMonthRequestMeta{UserID: 1, Year: 2024, Month: 4},
+		Data: model.MonthState{Days: map[int]model.DayState{
+			1: {State: model.StateWorkFromHome, Source: "carrier-pigeon"},
+		}},
+	})
+	if err == nil {
+		t.Fatal("expected PutMonth to reject an unknown source")
+	}
+	if m, _ := db.GetMonth(1, 4, 2024); len(m.Days) != 0 {
+		t.Errorf("rejected month was saved: %+v", m.Days)
+	}
+}
+
+// A state write that omits the note keeps the day's existing note, so clients
+// that only send a state don't wipe it.
+func TestPutDayKeepsNote(t *testing.T) {
+	db := dbtest.New()
+	svc := &Service{db: db}
+	meta := model.PutDayRequestMeta{UserID: 1, Year: 2024, Month: 3, Day: 5}
+
+	if _, err := svc.PutDay(model.PutDayRequest{Meta: meta, Data: model.DayState{State: model.StateWorkFromOffice, Note: "client site"}}); err != nil {
+		t.Fatalf("PutDay: %v", err)
+	}
+	if _, err := svc.PutDay(model.PutDayRequest{Meta: meta, Data: model.DayState{State: model.StateWorkFromHome}}); err != nil {
+		t.Fatalf("PutDay: %v", err)
+	}
+	got, _ := db.GetDay(1, 5, 3, 2024)
+	if got.State != model.StateWorkFromHome || got.Note != "client site" {
+		t.Errorf("day = %+v, want WFH with note kept", got)
+	}
+
+	_, err := svc.PutMonth(model.PutMonthRequest{
+		Meta: model.PutMonthRequestMeta{UserID: 1, Year: 2024, Month: 3},
+		Data: model.MonthState{Days: map[int]model.DayState{5: {State: model.StateWorkFromOffice}}},
+	})
+	if err != nil {
+		t.Fatalf("PutMonth: %v", err)
+	}
+	if got, _ := db.GetDay(1, 5, 3, 2024); got.Note != "client site" {
+		t.Errorf("PutMonth dropped the note: %+v", got)
+	}
+}
+
+// The day note endpoint sets and clears a note without touching the state.
+func TestPutDayNote(t *testing.T) {
+	db := dbtest.New()
+	db.SaveDay(1, 5, 3, 2024, model.DayState{State: model.StateWorkFromOffice, Source: model.SourceGeofence})
+	svc := &Service{db: db}
+	meta := model.PutDayNoteRequestMeta{UserID: 1, Year: 2024, Month: 3, Day: 5}
+
+	if _, err := svc.PutDayNote(model.PutDayNoteRequest{Meta: meta, Data: model.Note{Note: "sick"}}); err != nil {
+		t.Fatalf("PutDayNote: %v", err)
+	}
+	resp, err := svc.GetDayNote(model.GetDayNoteRequest{Meta: model.GetDayNoteRequestMeta{UserID: 1, Year: 2024, Month: 3, Day: 5}})
+	if err != nil || resp.Data.Note != "sick" {
+		t.Errorf("GetDayNote = (%+v, %v), want sick", resp.Data, err)
+	}
+	got, _ := db.GetDay(1, 5, 3, 2024)
+	if got.State != model.StateWorkFromOffice || got.Source != model.SourceGeofence {
+		t.Errorf("day = %+v, want state and source untouched", got)
+	}
+
+	if _, err := svc.PutDayNote(model.PutDayNoteRequest{Meta: meta}); err != nil {
+		t.Fatalf("PutDayNote clear: %v", err)
+	}
+	if got, _ := db.GetDay(1, 5, 3, 2024); got.Note != "" {
+		t.Errorf("note = %q, want cleared", got.Note)
+	}
+}
+
 func TestGetDayError(t *testing.T) {
 	db := dbtest.New()
 	db.Errs = map[string]error{"GetDay": errInjected}
@@ -140,6 +297,8 @@ func TestGetYearMergesSchedule(t *testing.T) {
 	db.SaveSchedulePreferences(1, model.SchedulePreferences{Monday: model.StateWorkFromOffice})
 	// A real WFH day on a Wednesday (Jan 3 2024).
 	db.SaveDay(1, 3, 1, 2024, model.DayState{State: model.StateWorkFromHome})
+	// An untracked Monday with a note (Jan 8 2024).
+	db.SaveDay(1, 8, 1, 2024, model.DayState{State: model.StateUntracked, Note: "leave"})
 	svc := &Service{db: db}
 
 	resp, err := svc.GetYear(model.GetYearRequest{
@@ -152,6 +311,12 @@ func TestGetYearMergesSchedule(t *testing.T) {
 	if jan[1].State != model.StateScheduledWorkFromOffice {
 		t.Errorf("Jan 1 (scheduled Monday) = %d, want scheduled office", jan[1].State)
 	}
+	if jan[1].Source != model.SourceSchedule {
+		t.Errorf("Jan 1 source = %q, want schedule", jan[1].Source)
+	}
+	if jan[8].Note != "leave" {
+		t.Errorf("Jan 8 note = %q, want the untracked day's note kept under the schedule", jan[8].Note)
+	}
 	if jan[3].State != model.StateWorkFromHome {
 		t.Errorf("Jan 3 (real WFH) = %d, want WFH preserved", jan[3].State)
 	}
@@ -199,3 +364,196 @@ func TestNotesRoundTrip(t *testing.T) {
 		t.Errorf("GetNotes[6] = %q, want eofy", all.Data[6].Note)
 	}
 }
+
+// Every write that changes a day is appended to the history with the previous
+// state and the writer's auth details; writes that don't change anything are
+// not recorded.
+func TestPutDayRecordsHistory(t *testing.T) {
+	db := dbtest.New()
+	svc := &Service{db: db}
+
+	put := func(state model.State) {
+		t.Helper()
+		_, err := svc.PutDay(model.PutDayRequest{
+			Meta: model.PutDayRequestMeta{UserID: 1, Year: 2024, Month: 3, Day: 5, AuthMethod: "secret", AuthTokenID: 7},
+			Data: model.DayState{State: state},
+		})
+		if err != nil {
+			t.Fatalf("PutDay: %v", err)
+		}
+	}
+	put(model.StateWorkFromOffice)
+	put(model.StateWorkFromOffice) // no-op, not recorded
+	put(model.StateWorkFromHome)
+
+	resp, err := svc.GetDayHistory(model.GetDayHistoryRequest{
+		Meta: model.GetDayHistoryRequestMeta{UserID: 1, Year: 2024, Month: 3, Day: 5},
+	})
+	if err != nil {
+		t.Fatalf("GetDayHistory: %v", err)
+	}
+	if len(resp.Data) != 2 {
+		t.Fatalf("got %d history entries, want 2: %+v", len(resp.Data), resp.Data)
+	}
+	first, second := resp.Data[0], resp.Data[1]
+	if first.OldState != model.StateUntracked || first.NewState != model.StateWorkFromOffice {
+		t.Errorf("first change = %d -> %d, want untracked -> office", first.OldState, first.NewState)
+	}
+	if second.OldState != model.StateWorkFromOffice || second.NewState != model.StateWorkFromHome {
+		t.Errorf("second change = %d -> %d, want office -> home", second.OldState, second.NewState)
+	}
+	if first.AuthMethod != "secret" || first.TokenID != 7 || first.Via != "api" {
+		t.Errorf("author = (%q, %d, %q), want (secret, 7, api)", first.AuthMethod, first.TokenID, first.Via)
+	}
+	if first.ChangedAt == "" {
+		t.Error("ChangedAt should be set")
+	}
+}
+
+func TestPutMonthRecordsHistory(t *testing.T) {
+	db := dbtest.New()
+	db.SaveDay(1, 1, 4, 2024, model.DayState{State: model.StateWorkFromHome})
+	svc := &Service{db: db}
+
+	_, err := svc.PutMonth(model.PutMonthRequest{
+		Meta: model.PutMonthRequestMeta{UserID: 1, Year: 2024, Month: 4, AuthMethod: "sso"},
+		Data: model.MonthState{Days: map[int]model.DayState{
+			1: {State: model.StateWorkFromHome},   // unchanged
+			2: {State: model.StateWorkFromOffice}, // new
+		}},
+	})
+	if err != nil {
+		t.Fatalf("PutMonth: %v", err)
+	}
+
+	unchanged, _ := db.GetDayHistory(1, 1, 4, 2024)
+	if len(unchanged) != 0 {
+		t.Errorf("unchanged day recorded %d changes, want 0", len(unchanged))
+	}
+	changed, _ := db.GetDayHistory(1, 2, 4, 2024)
+	if len(changed) != 1 || changed[0].NewState != model.StateWorkFromOffice || changed[0].AuthMethod != "sso" {
+		t.Errorf("changed day history = %+v", changed)
+	}
+}
+
+// The day and its history are saved together: if the save fails, the day is
+// left unsaved rather than missing from the audit log.
+func TestPutDayHistoryError(t *testing.T) {
+	db := dbtest.New()
+	db.Errs = map[string]error{"SaveEntries": errInjected}
+	svc := &Service{db: db}
+	_, err := svc.PutDay(model.PutDayRequest{
+		Meta: model.PutDayRequestMeta{UserID: 1, Year: 2024, Month: 3, Day: 5},
+		Data: model.DayState{State: model.StateWorkFromOffice},
+	})
+	if err == nil {
+		t.Fatal("expected PutDay to propagate save error")
+	}
+	if day, _ := db.GetDay(1, 5, 3, 2024); day.State != model.StateUntracked {
+		t.Errorf("day = %+v, want it left unsaved", day)
+	}
+}
+
+// Likewise for a month: none of its days are saved.
+func TestPutMonthHistoryError(t *testing.T) {
+	db := dbtest.New()
+	db.Errs = map[string]error{"SaveEntries": errInjected}
+	svc := &Service{db: db}
+	_, err := svc.PutMonth(model.PutMonthRequest{
+		Meta: model.PutMonthRequestMeta{UserID: 1, Year: 2024, Month: 3},
+		Data: model.MonthState{Days: map[int]model.DayState{
+			4: {State: model.StateWorkFromHome},
+			5: {State: model.StateWorkFromOffice},
+		}},
+	})
+	if err == nil {
+		t.Fatal("expected PutMonth to propagate save error")
+	}
+	if month, _ := db.GetMonth(1, 3, 2024); len(month.Days) != 0 {
+		t.Errorf("month = %+v, want nothing saved", month.Days)
+	}
+}
+
+// Invalid input is rejected with the offending fields named and nothing saved.
+func TestPutRejectsInvalidInput(t *testing.T) {
+	office := model.DayState{State: model.StateWorkFromOffice}
+	cases := []struct {
+		name   string
+		put    func(svc *Service) error
+		fields []string
+	}{
+		{"month 13", func(svc *Service) error {
+			_, err := svc.PutDay(model.PutDayRequest{Meta: model.PutDayRequestMeta{UserID: 1, Day: 1, Month: 13, Year: 2024}, Data: office})
+			return err
+		}, []string{"month"}},
+		{"31 February", func(svc *Service) error {
+			_, err := svc.PutDay(model.PutDayRequest{Meta: model.PutDayRequestMeta{UserID: 1, Day: 31, Month: 2, Year: 2024}, Data: office})
+			return err
+		}, []string{"day"}},
+		{"year out of range", func(svc *Service) error {
+			_, err := svc.PutDay(model.PutDayRequest{Meta: model.PutDayRequestMeta{UserID: 1, Day: 1, Month: 1, Year: 20240}, Data: office})
+			return err
+		}, []string{"year"}},
+		{"unknown state", func(svc *Service) error {
+			_, err := svc.PutDay(model.PutDayRequest{Meta: model.PutDayRequestMeta{UserID: 1, Day: 1, Month: 2, Year: 2024}, Data: model.DayState{State: 9}})
+			return err
+		}, []string{"data.state"}},
+		{"scheduled state", func(svc *Service) error {
+			_, err := svc.PutDay(model.PutDayRequest{Meta: model.PutDayRequestMeta{UserID: 1, Day: 1, Month: 2, Year: 2024}, Data: model.DayState{State: model.StateScheduledWorkFromOffice}})
+			return err
+		}, []string{"data.state"}},
+		{"bad days in month", func(svc *Service) error {
+			_, err := svc.PutMonth(model.PutMonthRequest{
+				Meta: model.PutMonthRequestMeta{UserID: 1, Month: 2, Year: 2024},
+				Data: model.MonthState{Days: map[int]model.DayState{
+					1:  office,
+					2:  {State: -1},
+					30: office,
+					3:  {State: model.StateWorkFromHome, Source: "carrier-pigeon"},
+				}},
+			})
+			return err
+		}, []string{"data.days.2.state", "data.days.3.source", "data.days.30"}},
+		{"bad month", func(svc *Service) error {
+			_, err := svc.PutMonth(model.PutMonthRequest{
+				Meta: model.PutMonthRequestMeta{UserID: 1, Month: 0, Year: 2024},
+				Data: model.MonthState{Days: map[int]model.DayState{1: office}},
+			})
+			return err
+		}, []string{"month"}},
+	}
+	for _, c := range cases {
+		db := dbtest.New()
+		svc := &Service{db: db}
+		err := c.put(svc)
+		var verr *ValidationError
+		if !errors.As(err, &verr) {
+			t.Errorf("%s: err = %v, want a ValidationError", c.name, err)
+			continue
+		}
+		var fields []string
+		for _, fe := range verr.Errors {
+			fields = append(fields, fe.Field)
+		}
+		if !slices.Equal(fields, c.fields) {
+			t.Errorf("%s: fields = %v, want %v", c.name, fields, c.fields)
+		}
+		if got, _ := db.GetDay(1, 1, 2, 2024); got.State != model.StateUntracked {
+			t.Errorf("%s: 1 February = %+v, want nothing saved", c.name, got)
+		}
+	}
+}
+
+// Unknown custom states and locations are reported against the field that
+// named them.
+func TestPutDayUnknownReferences(t *testing.T) {
+	svc := &Service{db: dbtest.New()}
+	_, err := svc.PutDay(model.PutDayRequest{
+		Meta: model.PutDayRequestMeta{UserID: 1, Day: 1, Month: 2, Year: 2024},
+		Data: model.DayState{State: model.StateWorkFromOffice, LocationID: 7},
+	})
+	var verr *ValidationError
+	if !errors.As(err, &verr) || verr.Errors[0].Field != "location_id" {
+		t.Errorf("err = %v, want a location_id ValidationError", err)
+	}
+}
@@ -0,0 +1,168 @@
+package v1
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/baely/officetracker/pkg/model"
+)
+
+// maxRangeDays caps how many days a range query or bulk update can cover.
+const maxRangeDays = 366
+
+// GetStateRange lists the entries recorded from From to To, both inclusive,
+// in date order. Unlike GetYear it doesn't fill in scheduled days.
+func (i *Service) GetStateRange(req model.GetStateRangeRequest) (model.GetStateRangeResponse, error) {
+	from, to, err := parseRange(req.From, req.To, "from", "to")
+	if err != nil {
+		return model.GetStateRangeResponse{}, err
+	}
+
+	days := []model.DatedDayState{}
+	months := newMonthCache(i, req.Meta.UserID)
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		month, err := months.get(day)
+		if err != nil {
+			return model.GetStateRangeResponse{}, err
+		}
+		if state, ok := month.Days[day.Day()]; ok {
+			days = append(days, model.DatedDayState{Date: day.Format("2006-01-02"), DayState: state})
+		}
+	}
+
+	return model.GetStateRangeResponse{
+		Data: days,
+	}, nil
+}
+
+// PatchState writes the listed days and fills the range, each day as PutDay
+// would write it. Every day is checked first and all of them are saved in one
+// transaction, so a bad day leaves nothing written. Webhooks get a
+// month.updated event for each month with a changed day.
+func (i *Service) PatchState(req model.PatchStateRequest) (model.PatchStateResponse, error) {
+	var v validator
+	days := make(map[time.Time]model.DayState)
+	add := func(field string, day time.Time, state model.DayState) {
+		if _, ok := days[day]; ok {
+			v.add(field, "date %s given more than once", day.Format("2006-01-02"))
+			return
+		}
+		days[day] = state
+	}
+	for n, entry := range req.Data.Entries {
+		field := fmt.Sprintf("data.entries[%d]", n)
+		v.dayState(field, entry.DayState)
+		day, err := parseDate(entry.Date, field+".date")
+		if err != nil {
+			v.merge(err)
+			continue
+		}
+		add(field+".date", day, entry.DayState)
+	}
+	if r := req.Data.Range; r != nil {
+		v.dayState("data.range", model.DayState{State: r.State})
+		from, to, err := parseRange(r.From, r.To, "data.range.from", "data.range.to")
+		if err != nil {
+			v.merge(err)
+		} else {
+			for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+				if r.WeekdaysOnly && (day.Weekday() == time.Saturday || day.Weekday() == time.Sunday) {
+					continue
+				}
+				add("data.range", day, model.DayState{State: r.State})
+			}
+		}
+	}
+	if len(days) > maxRangeDays {
+		v.add("data", "at most %d days can be updated at once", maxRangeDays)
+	}
+	if err := v.err(); err != nil {
+		return model.PatchStateResponse{}, err
+	}
+
+	now := time.Now()
+	for day, dayState := range days {
+		state, err := stampDayState(dayState, now)
+		if err != nil {
+			return model.PatchStateResponse{}, err
+		}
+		days[day] = state
+	}
+	if err := i.checkDays(req.Meta.UserID, slices.Collect(maps.Values(days))...); err != nil {
+		return model.PatchStateResponse{}, err
+	}
+
+	author := changeAuthor{authMethod: req.Meta.AuthMethod, tokenID: req.Meta.AuthTokenID, via: req.Meta.Via}
+	if err := i.saveDays(req.Meta.UserID, author, days, now); err != nil {
+		return model.PatchStateResponse{}, err
+	}
+
+	return model.PatchStateResponse{
+		Updated: len(days),
+	}, nil
+}
+
+// parseDate parses a YYYY-MM-DD date, rejecting ones that don't exist such as
+// 2024-02-31. Failures are reported as a ValidationError against field.
+func parseDate(s, field string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, invalid(field, "date is required")
+	}
+	day, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return time.Time{}, invalid(field, "invalid date %q, want YYYY-MM-DD", s)
+	}
+	if day.Year() < minYear || day.Year() > maxYear {
+		return time.Time{}, invalid(field, "year must be between %d and %d", minYear, maxYear)
+	}
+	return day, nil
+}
+
+// parseRange parses an inclusive date range of at most maxRangeDays days.
+func parseRange(fromStr, toStr, fromField, toField string) (from, to time.Time, err error) {
+	var v validator
+	from, fromErr := parseDate(fromStr, fromField)
+	to, toErr := parseDate(toStr, toField)
+	v.merge(fromErr)
+	v.merge(toErr)
+	if err := v.err(); err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, invalid(toField, "%s is before %s", toStr, fromStr)
+	}
+	if days := int(to.Sub(from).Hours()/24) + 1; days > maxRangeDays {
+		return time.Time{}, time.Time{}, invalid(toField, "range covers %d days, at most %d allowed", days, maxRangeDays)
+	}
+	return from, to, nil
+}
+
+// monthCache loads each month once when walking day by day through a range.
+type monthCache struct {
+	service *Service
+	userID  int
+	months  map[time.Time]model.MonthState
+}
+
+func newMonthCache(service *Service, userID int) *monthCache {
+	return &monthCache{
+		service: service,
+		userID:  userID,
+		months:  make(map[time.Time]model.MonthState),
+	}
+}
+
+func (c *monthCache) get(day time.Time) (model.MonthState, error) {
+	key := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if month, ok := c.months[key]; ok {
+		return month, nil
+	}
+	month, err := c.service.db.GetMonth(c.userID, int(day.Month()), day.Year())
+	if err != nil {
+		return model.MonthState{}, fmt.Errorf("failed to get month: %w", err)
+	}
+	c.months[key] = month
+	return month, nil
+}
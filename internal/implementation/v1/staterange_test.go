@@ -0,0 +1,140 @@
+package v1
+
+import (
+	"testing"
+
+	"github.com/baely/officetracker/internal/database/dbtest"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+func TestGetStateRange(t *testing.T) {
+	db := dbtest.New()
+	db.SaveDay(1, 28, 2, 2024, model.DayState{State: model.StateWorkFromHome})
+	db.SaveDay(1, 29, 2, 2024, model.DayState{State: model.StateWorkFromOffice, Note: "leap"})
+	db.SaveDay(1, 2, 3, 2024, model.DayState{State: model.StateOther})
+	db.SaveDay(1, 3, 3, 2024, model.DayState{State: model.StateOther}) // after the range
+	svc := &Service{db: db}
+
+	resp, err := svc.GetStateRange(model.GetStateRangeRequest{Meta: model.GetStateRangeRequestMeta{UserID: 1}, From: "2024-02-28", To: "2024-03-02"})
+	if err != nil {
+		t.Fatalf("GetStateRange: %v", err)
+	}
+	want := []string{"2024-02-28", "2024-02-29", "2024-03-02"}
+	if len(resp.Data) != len(want) {
+		t.Fatalf("entries = %+v, want %v", resp.Data, want)
+	}
+	for n, date := range want {
+		if resp.Data[n].Date != date {
+			t.Errorf("entry %d = %s, want %s", n, resp.Data[n].Date, date)
+		}
+	}
+	if resp.Data[1].Note != "leap" || resp.Data[1].State != model.StateWorkFromOffice {
+		t.Errorf("29 February = %+v", resp.Data[1])
+	}
+
+	cases := [][2]string{
+		{"", "2024-03-02"},
+		{"2024-02-30", "2024-03-02"},
+		{"2024-03-02", "2024-02-28"},
+		{"2024-01-01", "2025-01-01"},
+	}
+	for _, c := range cases {
+		if _, err := svc.GetStateRange(model.GetStateRangeRequest{From: c[0], To: c[1]}); err == nil {
+			t.Errorf("GetStateRange(%s, %s) should fail", c[0], c[1])
+		}
+	}
+}
+
+func TestPatchState(t *testing.T) {
+	db := dbtest.New()
+	db.SaveDay(1, 4, 3, 2024, model.DayState{State: model.StateWorkFromOffice, Note: "client site"})
+	svc := &Service{db: db}
+
+	resp, err := svc.PatchState(model.PatchStateRequest{
+		Meta: model.PatchStateRequestMeta{UserID: 1, Via: "api"},
+		Data: model.PatchStateRequestData{
+			Entries: []model.DatedDayState{{Date: "2024-02-29", DayState: model.DayState{State: model.StateWorkFromOffice}}},
+			Range:   &model.RangeState{From: "2024-03-01", To: "2024-03-05", State: model.StateWorkFromHome, WeekdaysOnly: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("PatchState: %v", err)
+	}
+	// 29 February, then Friday 1, Monday 4 and Tuesday 5 March.
+	if resp.Updated != 4 {
+		t.Errorf("updated = %d, want 4", resp.Updated)
+	}
+	if got, _ := db.GetDay(1, 29, 2, 2024); got.State != model.StateWorkFromOffice || got.Source != model.SourceManual {
+		t.Errorf("29 February = %+v, want a manual office day", got)
+	}
+	if got, _ := db.GetDay(1, 2, 3, 2024); got.State != model.StateUntracked {
+		t.Errorf("Saturday 2 March = %+v, want it skipped", got)
+	}
+	if got, _ := db.GetDay(1, 4, 3, 2024); got.State != model.StateWorkFromHome || got.Note != "client site" {
+		t.Errorf("4 March = %+v, want home keeping its note", got)
+	}
+	if history, _ := db.GetDayHistory(1, 4, 3, 2024); len(history) != 1 || history[0].OldState != model.StateWorkFromOffice {
+		t.Errorf("4 March history = %+v, want one change from office", history)
+	}
+}
+
+// A bad day rejects the whole update before anything is saved.
+func TestPatchStateRejects(t *testing.T) {
+	cases := map[string]model.PatchStateRequestData{
+		"impossible date": {Entries: []model.DatedDayState{
+			{Date: "2024-02-28", DayState: model.DayState{State: model.StateWorkFromOffice}},
+			{Date: "2024-02-31", DayState: model.DayState{State: model.StateWorkFromOffice}},
+		}},
+		"duplicate date": {
+			Entries: []model.DatedDayState{{Date: "2024-02-28", DayState: model.DayState{State: model.StateWorkFromOffice}}},
+			Range:   &model.RangeState{From: "2024-02-26", To: "2024-02-28", State: model.StateWorkFromHome},
+		},
+		"custom state": {Entries: []model.DatedDayState{
+			{Date: "2024-02-28", DayState: model.DayState{State: model.StateWorkFromOffice}},
+			{Date: "2024-02-27", DayState: model.DayState{State: 100}},
+		}},
+		"reversed range": {Range: &model.RangeState{From: "2024-02-28", To: "2024-02-26", State: model.StateWorkFromHome}},
+	}
+	for name, data := range cases {
+		db := dbtest.New()
+		svc := &Service{db: db}
+		if _, err := svc.PatchState(model.PatchStateRequest{Meta: model.PatchStateRequestMeta{UserID: 1}, Data: data}); err == nil {
+			t.Errorf("%s: expected PatchState to fail", name)
+		}
+		if got, _ := db.GetDay(1, 28, 2, 2024); got.State != model.StateUntracked {
+			t.Errorf("%s: 28 February = %+v, want nothing saved", name, got)
+		}
+	}
+}
+
+func TestPatchStateSaveError(t *testing.T) {
+	db := dbtest.New()
+	db.Errs = map[string]error{"SaveEntries": errInjected}
+	svc := &Service{db: db}
+	if _, err := svc.PatchState(model.PatchStateRequest{Data: model.PatchStateRequestData{
+		Entries: []model.DatedDayState{{Date: "2024-02-28", DayState: model.DayState{State: model.StateWorkFromOffice}}},
+	}}); err == nil {
+		t.Error("expected PatchState to propagate the save error")
+	}
+}
+
+// Day writes reject dates that don't exist.
+func TestPutDayRejectsImpossibleDate(t *testing.T) {
+	db := dbtest.New()
+	svc := &Service{db: db}
+	if _, err := svc.PutDay(model.PutDayRequest{
+		Meta: model.PutDayRequestMeta{UserID: 1, Day: 31, Month: 2, Year: 2024},
+		Data: model.DayState{State: model.StateWorkFromOffice},
+	}); err == nil {
+		t.Error("expected PutDay to reject 31 February")
+	}
+	if _, err := svc.PutMonth(model.PutMonthRequest{
+		Meta: model.PutMonthRequestMeta{UserID: 1, Month: 2, Year: 2023},
+		Data: model.MonthState{Days: map[int]model.DayState{29: {State: model.StateWorkFromOffice}}},
+	}); err == nil {
+		t.Error("expected PutMonth to reject 29 February 2023")
+	}
+	if got, _ := db.GetDay(1, 31, 2, 2024); got.State != model.StateUntracked {
+		t.Errorf("31 February = %+v, want nothing saved", got)
+	}
+}
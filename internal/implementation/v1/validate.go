@@ -0,0 +1,106 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/baely/officetracker/internal/util"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+// Years outside this range are rejected as typos.
+const (
+	minYear = 1900
+	maxYear = 2100
+)
+
+// ValidationError is returned when a request's input is invalid. The server
+// reports it as a 400 listing each invalid field.
+type ValidationError struct {
+	Errors []model.FieldError
+}
+
+func (e *ValidationError) Error() string {
+	reasons := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		reasons = append(reasons, fe.Field+": "+fe.Reason)
+	}
+	return "invalid request: " + strings.Join(reasons, "; ")
+}
+
+// invalid returns a ValidationError for a single field.
+func invalid(field, reason string, args ...any) error {
+	return &ValidationError{Errors: []model.FieldError{{Field: field, Reason: fmt.Sprintf(reason, args...)}}}
+}
+
+// validator collects every problem with a request so they can be reported
+// together.
+type validator struct {
+	errs []model.FieldError
+}
+
+func (v *validator) add(field, reason string, args ...any) {
+	v.errs = append(v.errs, model.FieldError{Field: field, Reason: fmt.Sprintf(reason, args...)})
+}
+
+// merge adds the problems reported by a helper that returns a
+// ValidationError.
+func (v *validator) merge(err error) {
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		v.errs = append(v.errs, verr.Errors...)
+	}
+}
+
+// err returns the collected problems as a ValidationError, or nil if there
+// were none.
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: v.errs}
+}
+
+// year checks the year is in the supported range.
+func (v *validator) year(field string, year int) bool {
+	if year < minYear || year > maxYear {
+		v.add(field, "must be between %d and %d", minYear, maxYear)
+		return false
+	}
+	return true
+}
+
+// month checks the year and month.
+func (v *validator) month(year, month int) bool {
+	if !v.year("year", year) {
+		return false
+	}
+	if month < 1 || month > 12 {
+		v.add("month", "must be between 1 and 12")
+		return false
+	}
+	return true
+}
+
+// date checks the year, month and day name a real date. dayField names the
+// day, which may come from the URL or the request body.
+func (v *validator) date(year, month, day int, dayField string) {
+	if !v.month(year, month) {
+		return
+	}
+	if util.ValidDate(year, month, day) != nil {
+		v.add(dayField, "%04d-%02d has no day %d", year, month, day)
+	}
+}
+
+// dayState checks a day being written has a state that can be stored and a
+// known source.
+func (v *validator) dayState(field string, state model.DayState) {
+	if !state.State.Valid() {
+		v.add(field+".state", "unknown state %d", state.State)
+	}
+	if state.Source != "" && !state.Source.Valid() {
+		v.add(field+".source", "unknown source %q", state.Source)
+	}
+}
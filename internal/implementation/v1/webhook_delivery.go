@@ -0,0 +1,207 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/baely/officetracker/internal/database"
+	"github.com/baely/officetracker/pkg/model"
+	"github.com/baely/officetracker/pkg/webhook"
+)
+
+const (
+	// webhookPollInterval is how often DeliverWebhooks looks for due
+	// deliveries.
+	webhookPollInterval = 5 * time.Second
+	// webhookBatch is how many deliveries are claimed at once. The lease must
+	// cover sending all of them.
+	webhookBatch   = 20
+	webhookLease   = 5 * time.Minute
+	webhookTimeout = 10 * time.Second
+
+	// webhookRetention is how long delivered and failed deliveries stay in
+	// the log.
+	webhookRetention = 30 * 24 * time.Hour
+
+	// webhookErrorLimit caps the error kept for a failed attempt.
+	webhookErrorLimit = 200
+)
+
+// webhookRetries are the waits before each retry of a failed delivery. A
+// delivery still failing after the last retry is marked failed.
+var webhookRetries = []time.Duration{time.Minute, 5 * time.Minute, 30 * time.Minute, 2 * time.Hour, 12 * time.Hour}
+
+// cgnat is the carrier-grade NAT range, which netip doesn't count as private.
+var cgnat = netip.MustParsePrefix("100.64.0.0/10")
+
+// NewWebhookClient returns the HTTP client webhooks are sent with. It doesn't
+// follow redirects. Unless allowPrivate is set it refuses to connect to
+// loopback, private and link-local addresses, so webhooks on a hosted server
+// can't reach its internal network.
+func NewWebhookClient(allowPrivate bool) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !allowPrivate {
+		dialer := &net.Dialer{
+			Timeout: webhookTimeout,
+			Control: func(_, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip, err := netip.ParseAddr(host); err != nil || !publicAddr(ip) {
+					return fmt.Errorf("webhook address %s is not public", host)
+				}
+				return nil
+			},
+		}
+		transport.DialContext = dialer.DialContext
+		// A proxy would be dialled in place of the webhook's address.
+		transport.Proxy = nil
+	}
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func publicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !cgnat.Contains(ip)
+}
+
+// DeliverWebhooks sends queued webhook deliveries as they fall due, and prunes
+// old deliveries from the log daily, until ctx is done. Deliveries are claimed
+// before they're sent, so several servers can run it at once.
+func (i *Service) DeliverWebhooks(ctx context.Context, client *http.Client) {
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+
+	var pruned time.Time
+	for {
+		now := time.Now()
+		if now.Sub(pruned) >= 24*time.Hour {
+			n, err := i.db.PruneWebhookDeliveries(now.Add(-webhookRetention))
+			if err != nil {
+				slog.Error("failed to prune webhook deliveries", "error", err.Error())
+			} else {
+				pruned = now
+				slog.Info("pruned webhook deliveries", "deleted", n)
+			}
+		}
+		if _, err := i.DeliverDueWebhooks(ctx, client, now); err != nil {
+			slog.Error("failed to deliver webhooks", "error", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDueWebhooks makes one attempt at each delivery due at now, returning
+// how many were delivered. Failed attempts are retried later, following
+// webhookRetries.
+func (i *Service) DeliverDueWebhooks(ctx context.Context, client *http.Client, now time.Time) (int, error) {
+	deliveries, err := i.db.ClaimWebhookDeliveries(now, now.Add(webhookLease), webhookBatch)
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+
+	webhooks := make(map[int]model.Webhooks)
+	var delivered int
+	for _, d := range deliveries {
+		// Unsent deliveries are tried again once their lease runs out.
+		if ctx.Err() != nil {
+			break
+		}
+		userWebhooks, ok := webhooks[d.UserID]
+		if !ok {
+			if userWebhooks, err = i.db.GetWebhooks(d.UserID); err != nil {
+				slog.Error("failed to get webhooks", "userID", d.UserID, "error", err.Error())
+				continue
+			}
+			webhooks[d.UserID] = userWebhooks
+		}
+
+		hook, ok := userWebhooks.Get(d.WebhookID)
+		if ok && !hook.Disabled {
+			d = attemptWebhook(ctx, client, hook, d, now)
+		} else {
+			d.Status, d.Error, d.NextAttemptAt = model.WebhookDeliveryFailed, "webhook is disabled", time.Time{}
+		}
+		if err := i.db.SaveWebhookAttempt(d); err != nil {
+			slog.Error("failed to save webhook attempt", "deliveryID", d.ID, "error", err.Error())
+		}
+		if d.Status == model.WebhookDeliveryDelivered {
+			delivered++
+		}
+	}
+	return delivered, nil
+}
+
+// attemptWebhook sends d to hook and returns it updated with the outcome.
+func attemptWebhook(ctx context.Context, client *http.Client, hook model.Webhook, d database.WebhookDelivery, now time.Time) database.WebhookDelivery {
+	d.Attempts++
+	d.ResponseCode, d.Error, d.NextAttemptAt = 0, "", time.Time{}
+
+	code, err := postWebhook(ctx, client, hook, d.WebhookDelivery, now)
+	d.ResponseCode = code
+	switch {
+	case err == nil:
+		d.Status = model.WebhookDeliveryDelivered
+		return d
+	case d.Attempts > len(webhookRetries):
+		d.Status = model.WebhookDeliveryFailed
+	default:
+		d.Status = model.WebhookDeliveryPending
+		d.NextAttemptAt = now.Add(webhookRetries[d.Attempts-1])
+	}
+	d.Error = err.Error()
+	if len(d.Error) > webhookErrorLimit {
+		d.Error = d.Error[:webhookErrorLimit]
+	}
+	return d
+}
+
+// postWebhook sends a signed delivery, returning the response status. Any
+// status other than 2xx is an error.
+func postWebhook(ctx context.Context, client *http.Client, hook model.Webhook, d model.WebhookDelivery, now time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Officetracker-Webhooks/1.0")
+	req.Header.Set(webhook.EventHeader, string(d.Event))
+	req.Header.Set(webhook.DeliveryHeader, strconv.Itoa(d.ID))
+	req.Header.Set(webhook.SignatureHeader, webhook.Sign(hook.Secret, now, d.Payload))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Drain a little of the body so the connection can be reused.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,256 @@
+package v1
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/baely/officetracker/internal/database"
+	"github.com/baely/officetracker/internal/util"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+// webhookLogSize is how many deliveries ListWebhookDeliveries returns.
+const webhookLogSize = 50
+
+// ListWebhooks lists the user's webhooks without their secrets.
+func (i *Service) ListWebhooks(req model.ListWebhooksRequest) (model.ListWebhooksResponse, error) {
+	webhooks, err := i.db.GetWebhooks(req.Meta.UserID)
+	if err != nil {
+		err = fmt.Errorf("failed to get webhooks: %w", err)
+		return model.ListWebhooksResponse{}, err
+	}
+	for n := range webhooks {
+		webhooks[n].Secret = ""
+	}
+
+	return model.ListWebhooksResponse{
+		Data: webhooks,
+	}, nil
+}
+
+// CreateWebhook saves a webhook with a new signing secret, which is returned
+// this once.
+func (i *Service) CreateWebhook(req model.CreateWebhookRequest) (model.CreateWebhookResponse, error) {
+	webhook, err := validateWebhook(req.Data)
+	if err != nil {
+		return model.CreateWebhookResponse{}, err
+	}
+	webhook.ID = 0
+	webhook.Secret = "whsec_" + rand.Text()
+
+	webhook, err = i.db.SaveWebhook(req.Meta.UserID, webhook)
+	if err != nil {
+		err = fmt.Errorf("failed to save webhook: %w", err)
+		return model.CreateWebhookResponse{}, err
+	}
+
+	return model.CreateWebhookResponse{
+		Data: webhook,
+	}, nil
+}
+
+// UpdateWebhook changes a webhook's URL, events or disabled flag. Its secret
+// stays the same.
+func (i *Service) UpdateWebhook(req model.UpdateWebhookRequest) (model.UpdateWebhookResponse, error) {
+	// Saving with ID 0 would register a new webhook rather than update one.
+	if req.Meta.WebhookID <= 0 {
+		return model.UpdateWebhookResponse{}, notFound("webhook %d not found", req.Meta.WebhookID)
+	}
+	webhook, err := validateWebhook(req.Data)
+	if err != nil {
+		return model.UpdateWebhookResponse{}, err
+	}
+	webhook.ID = req.Meta.WebhookID
+
+	webhook, err = i.db.SaveWebhook(req.Meta.UserID, webhook)
+	if errors.Is(err, database.ErrNoWebhook) {
+		return model.UpdateWebhookResponse{}, notFound("webhook %d not found", req.Meta.WebhookID)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to save webhook: %w", err)
+		return model.UpdateWebhookResponse{}, err
+	}
+	webhook.Secret = ""
+
+	return model.UpdateWebhookResponse{
+		Data: webhook,
+	}, nil
+}
+
+// DeleteWebhook removes a webhook along with its pending deliveries and log.
+func (i *Service) DeleteWebhook(req model.DeleteWebhookRequest) (model.DeleteWebhookResponse, error) {
+	err := i.db.DeleteWebhook(req.Meta.UserID, req.Meta.WebhookID)
+	if errors.Is(err, database.ErrNoWebhook) {
+		return model.DeleteWebhookResponse{}, notFound("webhook %d not found", req.Meta.WebhookID)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to delete webhook: %w", err)
+		return model.DeleteWebhookResponse{}, err
+	}
+
+	return model.DeleteWebhookResponse{}, nil
+}
+
+// ListWebhookDeliveries returns a webhook's latest deliveries, newest first.
+func (i *Service) ListWebhookDeliveries(req model.ListWebhookDeliveriesRequest) (model.ListWebhookDeliveriesResponse, error) {
+	webhooks, err := i.db.GetWebhooks(req.Meta.UserID)
+	if err != nil {
+		err = fmt.Errorf("failed to get webhooks: %w", err)
+		return model.ListWebhookDeliveriesResponse{}, err
+	}
+	if _, ok := webhooks.Get(req.Meta.WebhookID); !ok {
+		return model.ListWebhookDeliveriesResponse{}, notFound("webhook %d not found", req.Meta.WebhookID)
+	}
+
+	deliveries, err := i.db.GetWebhookDeliveries(req.Meta.UserID, req.Meta.WebhookID, webhookLogSize)
+	if err != nil {
+		err = fmt.Errorf("failed to get webhook deliveries: %w", err)
+		return model.ListWebhookDeliveriesResponse{}, err
+	}
+	if deliveries == nil {
+		deliveries = []model.WebhookDelivery{}
+	}
+
+	return model.ListWebhookDeliveriesResponse{
+		Data: deliveries,
+	}, nil
+}
+
+// validateWebhook checks the fields a client supplies: an absolute http or
+// https URL and at least one known event. Repeated events are dropped.
+func validateWebhook(webhook model.Webhook) (model.Webhook, error) {
+	var v validator
+	webhook.URL = strings.TrimSpace(webhook.URL)
+	if u, err := url.Parse(webhook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.add("data.url", "webhook URL must be an absolute http or https URL")
+	}
+	if len(webhook.Events) == 0 {
+		v.add("data.events", "choose at least one event")
+	}
+	for n, event := range webhook.Events {
+		if !event.Valid() {
+			v.add(fmt.Sprintf("data.events[%d]", n), "unknown event %q", event)
+		}
+	}
+	if err := v.err(); err != nil {
+		return model.Webhook{}, err
+	}
+	webhook.Events = slices.Compact(slices.Sorted(slices.Values(webhook.Events)))
+	return webhook, nil
+}
+
+// webhookNotifier queues the deliveries a write fires. It is made before the
+// write so it can tell whether the write put the user's target out of reach.
+type webhookNotifier struct {
+	service  *Service
+	userID   int
+	webhooks model.Webhooks
+	// target is set when a webhook wants target.missed and the target was
+	// achievable before the write. It holds the window as it was then, so
+	// checkTarget lays the written days over it rather than loading it again.
+	target   *targetWindow
+	schedule util.Schedule
+	today    time.Time
+}
+
+func (i *Service) webhookNotifier(userID int) (*webhookNotifier, error) {
+	webhooks, err := i.db.GetWebhooks(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhooks: %w", err)
+	}
+	n := &webhookNotifier{service: i, userID: userID, webhooks: webhooks}
+	if n.subscribed(model.WebhookEventTargetMissed) {
+		if err := n.watchTarget(); err != nil {
+			slog.Error("failed to forecast target for webhooks", "userID", userID, "error", err.Error())
+		}
+	}
+	return n, nil
+}
+
+func (n *webhookNotifier) subscribed(event model.WebhookEvent) bool {
+	return slices.ContainsFunc(n.webhooks, func(w model.Webhook) bool { return w.Subscribes(event) })
+}
+
+// watchTarget loads the user's target window when the target is achievable
+// before the write, along with the schedule that fills in untracked days.
+func (n *webhookNotifier) watchTarget() error {
+	loc, err := n.service.location(n.userID)
+	if err != nil {
+		return err
+	}
+	today := util.Today(loc)
+	target, err := n.service.targetWindow(n.userID, today)
+	if err != nil || target == nil || !target.forecast(today).Achievable {
+		return err
+	}
+	schedule, err := n.service.schedule(n.userID)
+	if err != nil {
+		return err
+	}
+	n.target, n.schedule, n.today = target, schedule, today
+	return nil
+}
+
+// queue queues event for each webhook subscribed to it. The write has already
+// been saved by then, so a failure is logged rather than returned.
+func (n *webhookNotifier) queue(event model.WebhookEvent, data any, now time.Time) {
+	if !n.subscribed(event) {
+		return
+	}
+	payload, err := json.Marshal(model.WebhookPayload{Event: event, CreatedAt: now.UTC(), Data: data})
+	if err != nil {
+		slog.Error("failed to encode webhook payload", "userID", n.userID, "event", event, "error", err.Error())
+		return
+	}
+
+	var deliveries []database.WebhookDelivery
+	for _, webhook := range n.webhooks {
+		if !webhook.Subscribes(event) {
+			continue
+		}
+		deliveries = append(deliveries, database.WebhookDelivery{
+			UserID: n.userID,
+			WebhookDelivery: model.WebhookDelivery{
+				WebhookID: webhook.ID,
+				Event:     event,
+				CreatedAt: now.UTC(),
+				Payload:   payload,
+			},
+		})
+	}
+	if err := n.service.db.QueueWebhookDeliveries(deliveries); err != nil {
+		slog.Error("failed to queue webhook deliveries", "userID", n.userID, "event", event, "error", err.Error())
+	}
+}
+
+// checkTarget queues target.missed, with the forecast, when the target was
+// achievable before the write and no longer is. days are the days the write
+// changed, keyed by their UTC date.
+func (n *webhookNotifier) checkTarget(days map[time.Time]model.DayState, now time.Time) {
+	if n.target == nil {
+		return
+	}
+	target := *n.target
+	target.state = func(day time.Time) model.DayState {
+		date := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+		state, ok := days[date]
+		if !ok {
+			return n.target.state(day)
+		}
+		// Untracked days show as scheduled, as GetYear serves them.
+		if scheduled, ok := scheduledDay(n.schedule, date, state); ok && state.State == model.StateUntracked {
+			return scheduled
+		}
+		return state
+	}
+	if f := target.forecast(n.today); !f.Achievable {
+		n.queue(model.WebhookEventTargetMissed, f, now)
+	}
+}
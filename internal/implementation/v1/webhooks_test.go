@@ -0,0 +1,421 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/baely/officetracker/internal/database/dbtest"
+	"github.com/baely/officetracker/internal/util"
+	"github.com/baely/officetracker/pkg/model"
+	"github.com/baely/officetracker/pkg/webhook"
+)
+
+// addWebhook creates a webhook for user 1 and returns it with its secret.
+func addWebhook(t *testing.T, svc *Service, url string, events ...model.WebhookEvent) model.Webhook {
+	t.Helper()
+	resp, err := svc.CreateWebhook(model.CreateWebhookRequest{
+		Meta: model.CreateWebhookRequestMeta{UserID: 1},
+		Data: model.Webhook{URL: url, Events: events},
+	})
+	if err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+	return resp.Data
+}
+
+// The secret is only returned on create, and survives updates.
+func TestWebhookCRUD(t *testing.T) {
+	db := dbtest.New()
+	svc := &Service{db: db}
+
+	created := addWebhook(t, svc, " https://example.com/hook ", model.WebhookEventMonthUpdated, model.WebhookEventDayUpdated, model.WebhookEventDayUpdated)
+	if created.ID != 1 || created.URL != "https://example.com/hook" || !strings.HasPrefix(created.Secret, "whsec_") {
+		t.Errorf("created = %+v, want ID 1, trimmed URL and a secret", created)
+	}
+	if want := []model.WebhookEvent{model.WebhookEventDayUpdated, model.WebhookEventMonthUpdated}; !slices.Equal(created.Events, want) {
+		t.Errorf("events = %v, want %v", created.Events, want)
+	}
+
+	list, err := svc.ListWebhooks(model.ListWebhooksRequest{Meta: model.ListWebhooksRequestMeta{UserID: 1}})
+	if err != nil || len(list.Data) != 1 || list.Data[0].Secret != "" {
+		t.Fatalf("ListWebhooks = (%+v, %v), want one webhook without its secret", list.Data, err)
+	}
+
+	updated, err := svc.UpdateWebhook(model.UpdateWebhookRequest{
+		Meta: model.UpdateWebhookRequestMeta{UserID: 1, WebhookID: created.ID},
+		Data: model.Webhook{URL: "https://example.com/other", Events: []model.WebhookEvent{model.WebhookEventTargetMissed}, Disabled: true},
+	})
+	if err != nil {
+		t.Fatalf("UpdateWebhook: %v", err)
+	}
+	if updated.Data.URL != "https://example.com/other" || !updated.Data.Disabled || updated.Data.Secret != "" {
+		t.Errorf("updated = %+v", updated.Data)
+	}
+	stored, _ := db.GetWebhooks(1)
+	if len(stored) != 1 || stored[0].Secret != created.Secret {
+		t.Errorf("stored = %+v, want the original secret kept", stored)
+	}
+
+	if _, err := svc.UpdateWebhook(model.UpdateWebhookRequest{
+		Meta: model.UpdateWebhookRequestMeta{UserID: 1, WebhookID: 9},
+		Data: model.Webhook{URL: "https://example.com", Events: []model.WebhookEvent{model.WebhookEventDayUpdated}},
+	}); errCode(err) != model.ErrorCodeNotFound {
+		t.Errorf("UpdateWebhook unknown = %v, want not found", err)
+	}
+	if _, err := svc.UpdateWebhook(model.UpdateWebhookRequest{
+		Meta: model.UpdateWebhookRequestMeta{UserID: 1},
+		Data: model.Webhook{URL: "https://example.com", Events: []model.WebhookEvent{model.WebhookEventDayUpdated}},
+	}); errCode(err) != model.ErrorCodeNotFound {
+		t.Errorf("UpdateWebhook 0 = %v, want not found", err)
+	}
+	if hooks, _ := db.GetWebhooks(1); len(hooks) != 1 {
+		t.Errorf("webhooks = %+v, want none registered", hooks)
+	}
+	if _, err := svc.ListWebhookDeliveries(model.ListWebhookDeliveriesRequest{
+		Meta: model.ListWebhookDeliveriesRequestMeta{UserID: 1, WebhookID: 9},
+	}); errCode(err) != model.ErrorCodeNotFound {
+		t.Errorf("ListWebhookDeliveries unknown = %v, want not found", err)
+	}
+
+	if _, err := svc.DeleteWebhook(model.DeleteWebhookRequest{Meta: model.DeleteWebhookRequestMeta{UserID: 1, WebhookID: created.ID}}); err != nil {
+		t.Fatalf("DeleteWebhook: %v", err)
+	}
+	if _, err := svc.DeleteWebhook(model.DeleteWebhookRequest{Meta: model.DeleteWebhookRequestMeta{UserID: 1, WebhookID: created.ID}}); errCode(err) != model.ErrorCodeNotFound {
+		t.Errorf("DeleteWebhook again = %v, want not found", err)
+	}
+}
+
+func TestCreateWebhookValidation(t *testing.T) {
+	cases := map[string]struct {
+		webhook model.Webhook
+		fields  []string
+	}{
+		"relative url":  {model.Webhook{URL: "/hook", Events: []model.WebhookEvent{model.WebhookEventDayUpdated}}, []string{"data.url"}},
+		"ftp url":       {model.Webhook{URL: "ftp://example.com", Events: []model.WebhookEvent{model.WebhookEventDayUpdated}}, []string{"data.url"}},
+		"no events":     {model.Webhook{URL: "https://example.com"}, []string{"data.events"}},
+		"unknown event": {model.Webhook{URL: "https://example.com", Events: []model.WebhookEvent{model.WebhookEventDayUpdated, "day.deleted"}}, []string{"data.events[1]"}},
+	}
+	for name, c := range cases {
+		db := dbtest.New()
+		svc := &Service{db: db}
+		_, err := svc.CreateWebhook(model.CreateWebhookRequest{Meta: model.CreateWebhookRequestMeta{UserID: 1}, Data: c.webhook})
+		var verr *ValidationError
+		if !errors.As(err, &verr) {
+			t.Errorf("%s: err = %v, want a ValidationError", name, err)
+			continue
+		}
+		var fields []string
+		for _, fe := range verr.Errors {
+			fields = append(fields, fe.Field)
+		}
+		if !slices.Equal(fields, c.fields) {
+			t.Errorf("%s: fields = %v, want %v", name, fields, c.fields)
+		}
+		if hooks, _ := db.GetWebhooks(1); len(hooks) != 0 {
+			t.Errorf("%s: saved %+v", name, hooks)
+		}
+	}
+}
+
+// PutDay queues day.updated for subscribed webhooks only, and only when the
+// state changes.
+func TestPutDayQueuesWebhook(t *testing.T) {
+	db := dbtest.New()
+	svc := &Service{db: db}
+	day := addWebhook(t, svc, "https://example.com/day", model.WebhookEventDayUpdated)
+	addWebhook(t, svc, "https://example.com/month", model.WebhookEventMonthUpdated)
+
+	put := func(state model.State) {
+		t.Helper()
+		if _, err := svc.PutDay(model.PutDayRequest{
+			Meta: model.PutDayRequestMeta{UserID: 1, Day: 5, Month: 3, Year: 2024},
+			Data: model.DayState{State: state},
+		}); err != nil {
+			t.Fatalf("PutDay: %v", err)
+		}
+	}
+	put(model.StateWorkFromOffice)
+	put(model.StateWorkFromOffice)
+
+	if len(db.Deliveries) != 1 {
+		t.Fatalf("deliveries = %+v, want one", db.Deliveries)
+	}
+	d := db.Deliveries[0]
+	if d.UserID != 1 || d.WebhookID != day.ID || d.Event != model.WebhookEventDayUpdated || d.Status != model.WebhookDeliveryPending {
+		t.Errorf("delivery = %+v", d)
+	}
+	var payload struct {
+		Event model.WebhookEvent    `json:"event"`
+		Data  model.DayUpdatedEvent `json:"data"`
+	}
+	if err := json.Unmarshal(d.Payload, &payload); err != nil {
+		t.Fatalf("payload: %v", err)
+	}
+	if payload.Event != model.WebhookEventDayUpdated || payload.Data.Date != "2024-03-05" ||
+		payload.Data.Previous.State != model.StateUntracked || payload.Data.State.State != model.StateWorkFromOffice {
+		t.Errorf("payload = %s", d.Payload)
+	}
+}
+
+// PutMonth sends the days that changed in one month.updated delivery.
+func TestPutMonthQueuesWebhook(t *testing.T) {
+	db := dbtest.New()
+	db.SaveDay(1, 4, 3, 2024, model.DayState{State: model.StateWorkFromOffice})
+	svc := &Service{db: db}
+	addWebhook(t, svc, "https://example.com/month", model.WebhookEventMonthUpdated)
+
+	if _, err := svc.PutMonth(model.PutMonthRequest{
+		Meta: model.PutMonthRequestMeta{UserID: 1, Month: 3, Year: 2024},
+		Data: model.MonthState{Days: map[int]model.DayState{
+			4: {State: model.StateWorkFromOffice},
+			5: {State: model.StateWorkFromHome},
+		}},
+	}); err != nil {
+		t.Fatalf("PutMonth: %v", err)
+	}
+
+	if len(db.Deliveries) != 1 || db.Deliveries[0].Event != model.WebhookEventMonthUpdated {
+		t.Fatalf("deliveries = %+v, want one month.updated", db.Deliveries)
+	}
+	var payload struct {
+		Data model.MonthUpdatedEvent `json:"data"`
+	}
+	if err := json.Unmarshal(db.Deliveries[0].Payload, &payload); err != nil {
+		t.Fatalf("payload: %v", err)
+	}
+	if payload.Data.Year != 2024 || payload.Data.Month != 3 || len(payload.Data.Days) != 1 || payload.Data.Days[5].State != model.StateWorkFromHome {
+		t.Errorf("payload = %s, want only 5 March", db.Deliveries[0].Payload)
+	}
+}
+
+// A bulk update sends one month.updated delivery per month it changed.
+func TestPatchStateQueuesWebhook(t *testing.T) {
+	db := dbtest.New()
+	db.SaveDay(1, 29, 2, 2024, model.DayState{State: model.StateWorkFromOffice})
+	svc := &Service{db: db}
+	addWebhook(t, svc, "https://example.com/bulk", model.WebhookEventMonthUpdated)
+
+	if _, err := svc.PatchState(model.PatchStateRequest{
+		Meta: model.PatchStateRequestMeta{UserID: 1},
+		Data: model.PatchStateRequestData{Range: &model.RangeState{From: "2024-02-29", To: "2024-03-01", State: model.StateWorkFromOffice}},
+	}); err != nil {
+		t.Fatalf("PatchState: %v", err)
+	}
+
+	if len(db.Deliveries) != 1 {
+		t.Fatalf("deliveries = %+v, want one for March only", db.Deliveries)
+	}
+	var payload struct {
+		Data model.MonthUpdatedEvent `json:"data"`
+	}
+	if err := json.Unmarshal(db.Deliveries[0].Payload, &payload); err != nil {
+		t.Fatalf("payload: %v", err)
+	}
+	if payload.Data.Month != 3 || len(payload.Data.Days) != 1 || payload.Data.Days[1].State != model.StateWorkFromOffice {
+		t.Errorf("payload = %s, want only 1 March", db.Deliveries[0].Payload)
+	}
+}
+
+// yearCounter counts the years loaded through it.
+type yearCounter struct {
+	*dbtest.Fake
+	years int
+}
+
+func (c *yearCounter) GetYear(userID, year, startMonth int) (model.YearState, error) {
+	c.years++
+	return c.Fake.GetYear(userID, year, startMonth)
+}
+
+// target.missed fires when a write makes the target unreachable, and not
+// again while it stays unreachable. The target window is loaded once per
+// write.
+func TestPutDayQueuesTargetMissed(t *testing.T) {
+	db := dbtest.New()
+	counter := &yearCounter{Fake: db}
+	svc := &Service{db: counter}
+	loc, err := svc.location(1)
+	if err != nil {
+		t.Fatalf("location: %v", err)
+	}
+	// Every day this month in the office, with a target of every day.
+	today := util.Today(loc)
+	first := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, loc)
+	days := first.AddDate(0, 1, -1).Day()
+	for d := 1; d <= days; d++ {
+		db.SaveDay(1, d, int(today.Month()), today.Year(), model.DayState{State: model.StateWorkFromOffice})
+	}
+	db.SaveTargetPreferences(1, model.TargetPreferences{Policy: model.TargetPolicyDaysPerMonth, TargetDays: float64(days)})
+	addWebhook(t, svc, "https://example.com/target", model.WebhookEventTargetMissed)
+
+	for _, state := range []model.State{model.StateWorkFromHome, model.StateOther} {
+		if _, err := svc.PutDay(model.PutDayRequest{
+			Meta: model.PutDayRequestMeta{UserID: 1, Day: today.Day(), Month: int(today.Month()), Year: today.Year()},
+			Data: model.DayState{State: state},
+		}); err != nil {
+			t.Fatalf("PutDay: %v", err)
+		}
+	}
+
+	if len(db.Deliveries) != 1 || db.Deliveries[0].Event != model.WebhookEventTargetMissed {
+		t.Fatalf("deliveries = %+v, want one target.missed", db.Deliveries)
+	}
+	if counter.years != 2 {
+		t.Errorf("loaded %d years for 2 writes, want 2", counter.years)
+	}
+	var payload struct {
+		Data model.Forecast `json:"data"`
+	}
+	if err := json.Unmarshal(db.Deliveries[0].Payload, &payload); err != nil {
+		t.Fatalf("payload: %v", err)
+	}
+	if payload.Data.Achievable || payload.Data.Required != days {
+		t.Errorf("payload = %s, want the unachievable forecast", db.Deliveries[0].Payload)
+	}
+}
+
+// A webhook that can't be loaded fails the write before anything is saved.
+func TestPutDayWebhookError(t *testing.T) {
+	db := dbtest.New()
+	db.Errs = map[string]error{"GetWebhooks": errors.New("boom")}
+	svc := &Service{db: db}
+	if _, err := svc.PutDay(model.PutDayRequest{
+		Meta: model.PutDayRequestMeta{UserID: 1, Day: 5, Month: 3, Year: 2024},
+		Data: model.DayState{State: model.StateWorkFromOffice},
+	}); err == nil {
+		t.Fatal("PutDay succeeded, want an error")
+	}
+	if got, _ := db.GetDay(1, 5, 3, 2024); got.State != model.StateUntracked {
+		t.Errorf("5 March = %+v, want nothing saved", got)
+	}
+}
+
+// Deliveries are signed, and retried on failure until they run out of
+// retries.
+func TestDeliverDueWebhooks(t *testing.T) {
+	var status int
+	var got []*http.Request
+	var bodies [][]byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = append(got, r)
+		bodies = append(bodies, body)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	db := dbtest.New()
+	svc := &Service{db: db}
+	hook := addWebhook(t, svc, srv.URL, model.WebhookEventDayUpdated)
+	if _, err := svc.PutDay(model.PutDayRequest{
+		Meta: model.PutDayRequestMeta{UserID: 1, Day: 5, Month: 3, Year: 2024},
+		Data: model.DayState{State: model.StateWorkFromOffice},
+	}); err != nil {
+		t.Fatalf("PutDay: %v", err)
+	}
+
+	ctx := context.Background()
+	client := NewWebhookClient(true)
+	now := time.Now()
+	status = http.StatusInternalServerError
+	for attempt := 1; attempt <= len(webhookRetries)+1; attempt++ {
+		if n, err := svc.DeliverDueWebhooks(ctx, client, now); err != nil || n != 0 {
+			t.Fatalf("attempt %d: DeliverDueWebhooks = (%d, %v), want (0, nil)", attempt, n, err)
+		}
+		d := db.Deliveries[0]
+		if len(got) != attempt || d.Attempts != attempt || d.ResponseCode != http.StatusInternalServerError {
+			t.Fatalf("attempt %d: %d requests, delivery %+v", attempt, len(got), d)
+		}
+		if attempt <= len(webhookRetries) {
+			if d.Status != model.WebhookDeliveryPending || !d.NextAttemptAt.Equal(now.Add(webhookRetries[attempt-1])) {
+				t.Fatalf("attempt %d: delivery %+v, want a retry after %s", attempt, d, webhookRetries[attempt-1])
+			}
+			// Not due again until the retry.
+			if n, _ := svc.DeliverDueWebhooks(ctx, client, now); n != 0 || len(got) != attempt {
+				t.Fatalf("attempt %d: retried early", attempt)
+			}
+			now = d.NextAttemptAt
+		} else if d.Status != model.WebhookDeliveryFailed || d.Error == "" {
+			t.Fatalf("last attempt: delivery %+v, want failed", d)
+		}
+	}
+
+	r, body := got[0], bodies[0]
+	if r.Method != http.MethodPost || r.Header.Get(webhook.EventHeader) != "day.updated" || r.Header.Get(webhook.DeliveryHeader) != "1" {
+		t.Errorf("request %s with headers %v", r.Method, r.Header)
+	}
+	if err := webhook.Verify(hook.Secret, r.Header.Get(webhook.SignatureHeader), body, time.Now(), webhook.DefaultTolerance); err != nil {
+		t.Errorf("signature: %v", err)
+	}
+
+	// A second change is delivered first time.
+	status = http.StatusNoContent
+	if _, err := svc.PutDay(model.PutDayRequest{
+		Meta: model.PutDayRequestMeta{UserID: 1, Day: 5, Month: 3, Year: 2024},
+		Data: model.DayState{State: model.StateWorkFromHome},
+	}); err != nil {
+		t.Fatalf("PutDay: %v", err)
+	}
+	if n, err := svc.DeliverDueWebhooks(ctx, client, time.Now()); err != nil || n != 1 {
+		t.Fatalf("DeliverDueWebhooks = (%d, %v), want (1, nil)", n, err)
+	}
+	log, err := svc.ListWebhookDeliveries(model.ListWebhookDeliveriesRequest{
+		Meta: model.ListWebhookDeliveriesRequestMeta{UserID: 1, WebhookID: hook.ID},
+	})
+	if err != nil || len(log.Data) != 2 {
+		t.Fatalf("ListWebhookDeliveries = (%+v, %v), want 2", log.Data, err)
+	}
+	if d := log.Data[0]; d.Status != model.WebhookDeliveryDelivered || d.Attempts != 1 || d.ResponseCode != http.StatusNoContent {
+		t.Errorf("latest delivery = %+v, want delivered", d)
+	}
+}
+
+// Deliveries for a webhook disabled after they were queued aren't sent.
+func TestDeliverDisabledWebhook(t *testing.T) {
+	db := dbtest.New()
+	svc := &Service{db: db}
+	hook := addWebhook(t, svc, "http://127.0.0.1:1/hook", model.WebhookEventDayUpdated)
+	svc.PutDay(model.PutDayRequest{
+		Meta: model.PutDayRequestMeta{UserID: 1, Day: 5, Month: 3, Year: 2024},
+		Data: model.DayState{State: model.StateWorkFromOffice},
+	})
+	hook.Disabled = true
+	if _, err := svc.UpdateWebhook(model.UpdateWebhookRequest{Meta: model.UpdateWebhookRequestMeta{UserID: 1, WebhookID: hook.ID}, Data: hook}); err != nil {
+		t.Fatalf("UpdateWebhook: %v", err)
+	}
+
+	if _, err := svc.DeliverDueWebhooks(context.Background(), NewWebhookClient(true), time.Now()); err != nil {
+		t.Fatalf("DeliverDueWebhooks: %v", err)
+	}
+	if d := db.Deliveries[0]; d.Status != model.WebhookDeliveryFailed || d.Attempts != 0 {
+		t.Errorf("delivery = %+v, want failed without an attempt", d)
+	}
+}
+
+// The hosted client refuses to connect to internal addresses.
+func TestWebhookClientBlocksPrivate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	if _, err := NewWebhookClient(false).Get(srv.URL); err == nil || !strings.Contains(err.Error(), "not public") {
+		t.Errorf("GET loopback = %v, want refused", err)
+	}
+	for addr, want := range map[string]bool{
+		"93.184.215.14": true, "2606:2800:21f:cb07:6820:80da:af6b:8b2c": true,
+		"127.0.0.1": false, "10.1.2.3": false, "192.168.0.1": false, "169.254.169.254": false,
+		"100.64.0.1": false, "0.0.0.0": false, "::1": false, "fd00::1": false, "::ffff:10.0.0.1": false,
+	} {
+		if got := publicAddr(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("publicAddr(%s) = %t, want %t", addr, got, want)
+		}
+	}
+}
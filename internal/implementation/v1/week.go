@@ -0,0 +1,138 @@
+package v1
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/baely/officetracker/internal/report"
+	"github.com/baely/officetracker/internal/util"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+// GetWeek returns the days of an ISO week, with untracked days filled in from
+// the schedule as in GetYear. OfficeDays counts only recorded days.
+func (i *Service) GetWeek(req model.GetWeekRequest) (model.GetWeekResponse, error) {
+	loc, err := i.location(req.Meta.UserID)
+	if err != nil {
+		return model.GetWeekResponse{}, err
+	}
+	start, err := util.ISOWeekStart(req.Meta.ISOYear, req.Meta.Week, loc)
+	if err != nil {
+		return model.GetWeekResponse{}, invalid("week", "%v", err)
+	}
+
+	customStates, err := i.db.GetCustomStates(req.Meta.UserID)
+	if err != nil {
+		return model.GetWeekResponse{}, fmt.Errorf("failed to get custom states: %w", err)
+	}
+	schedule, err := i.schedule(req.Meta.UserID)
+	if err != nil {
+		return model.GetWeekResponse{}, err
+	}
+
+	week := model.WeekState{
+		Week:  util.ISOWeekLabel(start),
+		Start: start.Format("2006-01-02"),
+		Days:  make(map[int]model.DayState),
+	}
+	months := make(map[time.Month]model.MonthState)
+	for weekday := 1; weekday <= 7; weekday++ {
+		day := start.AddDate(0, 0, weekday-1)
+		month, ok := months[day.Month()]
+		if !ok {
+			month, err = i.db.GetMonth(req.Meta.UserID, int(day.Month()), day.Year())
+			if err != nil {
+				return model.GetWeekResponse{}, fmt.Errorf("failed to get month: %w", err)
+			}
+			months[day.Month()] = month
+		}
+
+		state, ok := month.Days[day.Day()]
+		if customStates.Attendance(state.State) == model.AttendancePresent {
+			week.OfficeDays++
+		}
+		if state.State == model.StateUntracked {
+			if scheduled, isScheduled := scheduledDay(schedule, day, state); isScheduled {
+				state, ok = scheduled, true
+			}
+		}
+		if ok {
+			week.Days[weekday] = state
+		}
+	}
+
+	return model.GetWeekResponse{
+		Data: week,
+	}, nil
+}
+
+// PutWeek saves the given days of an ISO week, keyed by ISO weekday. Each day
+// is written as PutMonth would write it. Days are checked first and all of
+// them are saved in one transaction, so a week that straddles two months
+// isn't half written.
+func (i *Service) PutWeek(req model.PutWeekRequest) (model.PutWeekResponse, error) {
+	loc, err := i.location(req.Meta.UserID)
+	if err != nil {
+		return model.PutWeekResponse{}, err
+	}
+	start, err := util.ISOWeekStart(req.Meta.ISOYear, req.Meta.Week, loc)
+	if err != nil {
+		return model.PutWeekResponse{}, invalid("week", "%v", err)
+	}
+
+	var v validator
+	for _, weekday := range slices.Sorted(maps.Keys(req.Data.Days)) {
+		field := fmt.Sprintf("data.days.%d", weekday)
+		if weekday < 1 || weekday > 7 {
+			v.add(field, "weekday must be between 1 and 7")
+		}
+		v.dayState(field, req.Data.Days[weekday])
+	}
+	if err := v.err(); err != nil {
+		return model.PutWeekResponse{}, err
+	}
+
+	now := time.Now()
+	days := make(map[time.Time]model.DayState, len(req.Data.Days))
+	for weekday, dayState := range req.Data.Days {
+		state, err := stampDayState(dayState, now)
+		if err != nil {
+			return model.PutWeekResponse{}, err
+		}
+		day := start.AddDate(0, 0, weekday-1)
+		days[time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)] = state
+	}
+	if err := i.checkDays(req.Meta.UserID, slices.Collect(maps.Values(days))...); err != nil {
+		return model.PutWeekResponse{}, err
+	}
+
+	author := changeAuthor{authMethod: req.Meta.AuthMethod, tokenID: req.Meta.AuthTokenID, via: req.Meta.Via}
+	if err := i.saveDays(req.Meta.UserID, author, days, now); err != nil {
+		return model.PutWeekResponse{}, err
+	}
+
+	return model.PutWeekResponse{}, nil
+}
+
+// weekSummaries counts the office days recorded in each ISO week of the
+// tracking year. Weeks at either end only count their days inside the year.
+func weekSummaries(state model.YearState, customStates model.CustomStates, year, startMonth int, loc *time.Location) []model.WeekSummary {
+	start, end := util.TrackingYearRange(year, startMonth, loc)
+	counter := report.NewWeekCounter(customStates)
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		counter.Add(day, state.Months[int(day.Month())].Days[day.Day()])
+	}
+
+	counts := counter.Counts()
+	weeks := make([]model.WeekSummary, 0, len(counts))
+	for _, count := range counts {
+		weeks = append(weeks, model.WeekSummary{
+			Week:       count.Week,
+			Start:      count.Start.Format("2006-01-02"),
+			OfficeDays: count.OfficeDays,
+		})
+	}
+	return weeks
+}
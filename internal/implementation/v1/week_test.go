@@ -0,0 +1,141 @@
+package v1
+
+import (
+	"testing"
+
+	"github.com/baely/officetracker/internal/database/dbtest"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+// 2024-W05 runs from Monday 29 January to Sunday 4 February.
+func TestGetWeek(t *testing.T) {
+	db := dbtest.New()
+	db.SaveSchedulePreferences(1, model.SchedulePreferences{Monday: model.StateWorkFromOffice, Friday: model.StateWorkFromHome})
+	db.SaveDay(1, 30, 1, 2024, model.DayState{State: model.StateWorkFromOffice})
+	db.SaveDay(1, 1, 2, 2024, model.DayState{State: model.StateWorkFromOffice})
+	db.SaveDay(1, 2, 2, 2024, model.DayState{State: model.StateWorkFromOffice}) // Friday, overrides the schedule
+	svc := &Service{db: db}
+
+	resp, err := svc.GetWeek(model.GetWeekRequest{Meta: model.GetWeekRequestMeta{UserID: 1, ISOYear: 2024, Week: 5}})
+	if err != nil {
+		t.Fatalf("GetWeek: %v", err)
+	}
+	week := resp.Data
+	if week.Week != "2024-W05" || week.Start != "2024-01-29" {
+		t.Errorf("week = %s starting %s, want 2024-W05 starting 2024-01-29", week.Week, week.Start)
+	}
+	if week.Days[1].State != model.StateScheduledWorkFromOffice || week.Days[1].Source != model.SourceSchedule {
+		t.Errorf("Monday = %+v, want scheduled office", week.Days[1])
+	}
+	if week.Days[4].State != model.StateWorkFromOffice || week.Days[5].State != model.StateWorkFromOffice {
+		t.Errorf("Thursday and Friday = %+v, %+v, want office", week.Days[4], week.Days[5])
+	}
+	if _, ok := week.Days[3]; ok {
+		t.Errorf("Wednesday = %+v, want it left out", week.Days[3])
+	}
+	if week.OfficeDays != 3 {
+		t.Errorf("office days = %d, want 3 recorded days", week.OfficeDays)
+	}
+
+	if _, err := svc.GetWeek(model.GetWeekRequest{Meta: model.GetWeekRequestMeta{UserID: 1, ISOYear: 2024, Week: 53}}); err == nil {
+		t.Error("expected 2024-W53 to be rejected")
+	}
+}
+
+func TestPutWeek(t *testing.T) {
+	db := dbtest.New()
+	db.SaveDay(1, 29, 1, 2024, model.DayState{State: model.StateWorkFromHome, Note: "dentist"})
+	svc := &Service{db: db}
+
+	_, err := svc.PutWeek(model.PutWeekRequest{
+		Meta: model.PutWeekRequestMeta{UserID: 1, ISOYear: 2024, Week: 5, Via: "web"},
+		Data: model.WeekState{Days: map[int]model.DayState{
+			1: {State: model.StateWorkFromOffice},
+			5: {State: model.StateWorkFromHome},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("PutWeek: %v", err)
+	}
+	if got, _ := db.GetDay(1, 29, 1, 2024); got.State != model.StateWorkFromOffice || got.Note != "dentist" || got.Source != model.SourceManual {
+		t.Errorf("29 January = %+v, want a manual office day keeping its note", got)
+	}
+	if got, _ := db.GetDay(1, 2, 2, 2024); got.State != model.StateWorkFromHome {
+		t.Errorf("2 February = %+v, want home", got)
+	}
+	if history, _ := db.GetDayHistory(1, 2, 2, 2024); len(history) != 1 || history[0].Via != "web" {
+		t.Errorf("2 February history = %+v, want one change via web", history)
+	}
+}
+
+// A bad day fails the whole week before anything is saved.
+func TestPutWeekRejects(t *testing.T) {
+	cases := map[string]map[int]model.DayState{
+		"weekday":      {1: {State: model.StateWorkFromOffice}, 8: {State: model.StateWorkFromOffice}},
+		"custom state": {1: {State: model.StateWorkFromOffice}, 5: {State: 100}},
+	}
+	for name, days := range cases {
+		db := dbtest.New()
+		svc := &Service{db: db}
+		if _, err := svc.PutWeek(model.PutWeekRequest{
+			Meta: model.PutWeekRequestMeta{UserID: 1, ISOYear: 2024, Week: 5},
+			Data: model.WeekState{Days: days},
+		}); err == nil {
+			t.Errorf("%s: expected PutWeek to fail", name)
+		}
+		if got, _ := db.GetDay(1, 29, 1, 2024); got.State != model.StateUntracked {
+			t.Errorf("%s: 29 January = %+v, want nothing saved", name, got)
+		}
+	}
+}
+
+// A week straddling two months is saved in one go: if the save fails, neither
+// month is written.
+func TestPutWeekSaveError(t *testing.T) {
+	db := dbtest.New()
+	db.Errs = map[string]error{"SaveEntries": errInjected}
+	svc := &Service{db: db}
+	if _, err := svc.PutWeek(model.PutWeekRequest{
+		Meta: model.PutWeekRequestMeta{UserID: 1, ISOYear: 2024, Week: 5},
+		Data: model.WeekState{Days: map[int]model.DayState{
+			1: {State: model.StateWorkFromOffice},
+			5: {State: model.StateWorkFromOffice},
+		}},
+	}); err == nil {
+		t.Fatal("expected PutWeek to fail")
+	}
+	for _, month := range []int{1, 2} {
+		if days, _ := db.GetMonth(1, month, 2024); len(days.Days) != 0 {
+			t.Errorf("month %d = %+v, want nothing saved", month, days.Days)
+		}
+	}
+}
+
+// GetYear counts recorded office days per ISO week, ignoring scheduled days.
+func TestGetYearWeeks(t *testing.T) {
+	db := dbtest.New()
+	db.SaveCalendarPreferences(1, model.CalendarPreferences{TrackingYearStartMonth: 1})
+	db.SaveSchedulePreferences(1, model.SchedulePreferences{Monday: model.StateWorkFromOffice})
+	db.SaveDay(1, 2, 1, 2024, model.DayState{State: model.StateWorkFromOffice})
+	db.SaveDay(1, 3, 1, 2024, model.DayState{State: model.StateWorkFromOffice})
+	db.SaveDay(1, 9, 1, 2024, model.DayState{State: model.StateWorkFromHome})
+	svc := &Service{db: db}
+
+	resp, err := svc.GetYear(model.GetYearRequest{Meta: model.GetYearRequestMeta{UserID: 1, Year: 2024}})
+	if err != nil {
+		t.Fatalf("GetYear: %v", err)
+	}
+	weeks := resp.Data.Weeks
+	if len(weeks) != 53 {
+		t.Fatalf("weeks = %d, want 53 (2024-W01 to 2025-W01)", len(weeks))
+	}
+	if weeks[0] != (model.WeekSummary{Week: "2024-W01", Start: "2024-01-01", OfficeDays: 2}) {
+		t.Errorf("first week = %+v, want 2 office days", weeks[0])
+	}
+	if weeks[1].OfficeDays != 0 {
+		t.Errorf("second week = %+v, want no office days", weeks[1])
+	}
+	if last := weeks[len(weeks)-1]; last.Week != "2025-W01" || last.Start != "2024-12-30" {
+		t.Errorf("last week = %+v, want 2025-W01 starting 2024-12-30", last)
+	}
+}
@@ -0,0 +1,178 @@
+// Package mail sends email notifications. Callers send through a Sender, so the
+// delivery method can be swapped; SMTP delivers through a mail server.
+package mail
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/baely/officetracker/internal/config"
+)
+
+// defaultPort is the mail submission port, used when none is configured.
+const defaultPort = "587"
+
+// Message is a plain text email, with any attachments.
+type Message struct {
+	To          string
+	Subject     string
+	Body        string
+	Attachments []Attachment
+}
+
+// Attachment is a file sent with a message.
+type Attachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// Sender delivers messages.
+type Sender interface {
+	Send(msg Message) error
+}
+
+// SMTP sends messages through a mail server, upgrading to TLS when the server
+// offers it and signing in when a username is configured.
+type SMTP struct {
+	addr string
+	from *mail.Address
+	auth smtp.Auth
+}
+
+// NewSMTP returns an SMTP sender for cfg, which must have a host and a from
+// address.
+func NewSMTP(cfg config.SMTP) (*SMTP, error) {
+	if cfg.Host == "" {
+		return nil, errors.New("mail: no SMTP host")
+	}
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("mail: invalid from address %q: %w", cfg.From, err)
+	}
+	port := cfg.Port
+	if port == "" {
+		port = defaultPort
+	}
+	s := &SMTP{
+		addr: net.JoinHostPort(cfg.Host, port),
+		from: from,
+	}
+	if cfg.Username != "" {
+		s.auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	return s, nil
+}
+
+// Send delivers msg to its recipient.
+func (s *SMTP) Send(msg Message) error {
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("mail: invalid recipient %q: %w", msg.To, err)
+	}
+	data, err := build(s.from, to, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(s.addr, s.auth, s.from.Address, []string{to.Address}, data)
+}
+
+// build formats msg as a MIME message: the body alone when there are no
+// attachments, and otherwise a multipart/mixed message with the body first.
+func build(from, to *mail.Address, msg Message, now time.Time) ([]byte, error) {
+	var b bytes.Buffer
+	header := func(key, value string) {
+		fmt.Fprintf(&b, "%s: %s\r\n", key, value)
+	}
+	header("From", from.String())
+	header("To", to.String())
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", now.Format(time.RFC1123Z))
+	header("Message-ID", messageID(from))
+	header("MIME-Version", "1.0")
+
+	if len(msg.Attachments) == 0 {
+		header("Content-Type", "text/plain; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		b.WriteString("\r\n")
+		if err := writeText(&b, msg.Body); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(&b)
+	header("Content-Type", mime.FormatMediaType("multipart/mixed", map[string]string{"boundary": mw.Boundary()}))
+	b.WriteString("\r\n")
+
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writeText(part, msg.Body); err != nil {
+		return nil, err
+	}
+
+	for _, a := range msg.Attachments {
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {a.ContentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.Name})},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeBase64(part, a.Data); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// writeText writes text quoted-printable encoded, with CRLF line endings.
+func writeText(w io.Writer, text string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(text)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// writeBase64 writes data base64 encoded in lines of 76 characters, the most
+// MIME allows.
+func writeBase64(w io.Writer, data []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 0 {
+		n := min(len(encoded), 76)
+		if _, err := fmt.Fprintf(w, "%s\r\n", encoded[:n]); err != nil {
+			return err
+		}
+		encoded = encoded[n:]
+	}
+	return nil
+}
+
+// messageID makes a unique Message-ID in the sender's domain.
+func messageID(from *mail.Address) string {
+	_, domain, _ := strings.Cut(from.Address, "@")
+	return fmt.Sprintf("<%s@%s>", strings.ToLower(rand.Text()), domain)
+}
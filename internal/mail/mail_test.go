@@ -0,0 +1,220 @@
+package mail
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/baely/officetracker/internal/config"
+)
+
+var (
+	testFrom = &mail.Address{Name: "Officetracker", Address: "noreply@ot.example"}
+	testTo   = &mail.Address{Address: "alex@example.com"}
+)
+
+func readBody(t *testing.T, r io.Reader, encoding string) string {
+	t.Helper()
+	if encoding == "quoted-printable" {
+		r = quotedprintable.NewReader(r)
+	}
+	body, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("reading body: %v", err)
+	}
+	return string(body)
+}
+
+func TestBuild(t *testing.T) {
+	now := time.Date(2024, 5, 31, 18, 0, 0, 0, time.UTC)
+	data, err := build(testFrom, testTo, Message{
+		To:      testTo.Address,
+		Subject: "Week 2024-W22: in 2/5 days — nice",
+		Body:    "Line one\nLine two",
+	}, now)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "Week 2024-W22: in 2/5 days — nice" {
+		t.Errorf("subject = (%q, %v)", subject, err)
+	}
+	if got := msg.Header.Get("From"); got != `"Officetracker" <noreply@ot.example>` {
+		t.Errorf("from = %q", got)
+	}
+	if date, err := msg.Header.Date(); err != nil || !date.Equal(now) {
+		t.Errorf("date = (%v, %v), want %v", date, err, now)
+	}
+	if id := msg.Header.Get("Message-ID"); !strings.HasSuffix(id, "@ot.example>") {
+		t.Errorf("message id = %q", id)
+	}
+	if body := readBody(t, msg.Body, msg.Header.Get("Content-Transfer-Encoding")); body != "Line one\r\nLine two" {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestBuildAttachments(t *testing.T) {
+	pdf := bytes.Repeat([]byte("%PDF-1.3 binary \x00\xff"), 20)
+	data, err := build(testFrom, testTo, Message{
+		To:          testTo.Address,
+		Subject:     "May 2024",
+		Body:        "Attached.",
+		Attachments: []Attachment{{Name: "officetracker-2024-05.pdf", ContentType: "application/pdf", Data: pdf}},
+	}, time.Now())
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("content type = (%q, %v)", mediaType, err)
+	}
+
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	text, err := mr.NextRawPart()
+	if err != nil {
+		t.Fatalf("text part: %v", err)
+	}
+	if body := readBody(t, text, text.Header.Get("Content-Transfer-Encoding")); body != "Attached." {
+		t.Errorf("body = %q", body)
+	}
+
+	file, err := mr.NextRawPart()
+	if err != nil {
+		t.Fatalf("attachment part: %v", err)
+	}
+	if file.FileName() != "officetracker-2024-05.pdf" || file.Header.Get("Content-Type") != "application/pdf" {
+		t.Errorf("attachment header = %v", file.Header)
+	}
+	encoded, _ := io.ReadAll(file)
+	for _, line := range strings.Split(strings.TrimSpace(string(encoded)), "\r\n") {
+		if len(line) > 76 {
+			t.Errorf("base64 line of %d characters", len(line))
+		}
+	}
+	got, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, bytes.NewReader(encoded)))
+	if err != nil || !bytes.Equal(got, pdf) {
+		t.Errorf("attachment = (%q, %v), want %q", got, err, pdf)
+	}
+	if _, err := mr.NextPart(); err != io.EOF {
+		t.Errorf("extra part: %v", err)
+	}
+}
+
+// smtpServer accepts one message, without TLS or authentication, and returns
+// the envelope and data it received.
+func smtpServer(t *testing.T) (addr string, received <-chan []string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	ch := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+		var got []string
+		reply("220 test ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			switch verb, _, _ := strings.Cut(line, " "); strings.ToUpper(verb) {
+			case "EHLO", "HELO":
+				reply("250 test")
+			case "MAIL", "RCPT":
+				got = append(got, line)
+				reply("250 OK")
+			case "DATA":
+				reply("354 go ahead")
+				var data strings.Builder
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				got = append(got, data.String())
+				reply("250 OK")
+			case "QUIT":
+				reply("221 bye")
+				ch <- got
+				return
+			default:
+				reply("502 unsupported")
+			}
+		}
+	}()
+	return ln.Addr().String(), ch
+}
+
+func TestSMTPSend(t *testing.T) {
+	addr, received := smtpServer(t)
+	host, port, _ := net.SplitHostPort(addr)
+	sender, err := NewSMTP(config.SMTP{Host: host, Port: port, From: "Officetracker <noreply@ot.example>"})
+	if err != nil {
+		t.Fatalf("NewSMTP: %v", err)
+	}
+
+	if err := sender.Send(Message{To: "Alex <alex@example.com>", Subject: "Reminder", Body: "Fill in today."}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	select {
+	case got := <-received:
+		if len(got) != 3 || got[0] != "MAIL FROM:<noreply@ot.example>" || got[1] != "RCPT TO:<alex@example.com>" {
+			t.Fatalf("envelope = %q", got)
+		}
+		if !strings.Contains(got[2], "Subject: Reminder\r\n") || !strings.Contains(got[2], "Fill in today.") {
+			t.Errorf("data = %q", got[2])
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+}
+
+func TestNewSMTP(t *testing.T) {
+	for name, cfg := range map[string]config.SMTP{
+		"no host":      {From: "noreply@ot.example"},
+		"no from":      {Host: "smtp.example.com"},
+		"invalid from": {Host: "smtp.example.com", From: "not an address"},
+	} {
+		if _, err := NewSMTP(cfg); err == nil {
+			t.Errorf("%s: NewSMTP succeeded", name)
+		}
+	}
+	s, err := NewSMTP(config.SMTP{Host: "smtp.example.com", From: "noreply@ot.example"})
+	if err != nil || s.addr != "smtp.example.com:587" {
+		t.Errorf("NewSMTP = (%+v, %v), want the submission port", s, err)
+	}
+}
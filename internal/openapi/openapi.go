@@ -0,0 +1,500 @@
+// Package openapi builds an OpenAPI 3.1 document from API routes and the
+// request and response types their handlers map.
+//
+// Request types follow the server's conventions: the struct tagged
+// `meta:"meta"` holds values taken from the URL path (fields named after a
+// path parameter) or from the request context (everything else), fields
+// tagged `schema:"..."` are query parameters, and the remaining fields make up
+// the JSON body.
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Version is the OpenAPI version documents are written in.
+const Version = "3.1.0"
+
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Servers    []Server              `json:"servers,omitempty"`
+	Security   []SecurityRequirement `json:"security,omitempty"`
+	Tags       []Tag                 `json:"tags,omitempty"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components Components            `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type Server struct {
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+}
+
+type Tag struct {
+	Name string `json:"name"`
+}
+
+// PathItem maps lower-case HTTP methods to the operation on a path.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string       `json:"operationId,omitempty"`
+	Summary     string       `json:"summary,omitempty"`
+	Description string       `json:"description,omitempty"`
+	Tags        []string     `json:"tags,omitempty"`
+	Parameters  []Parameter  `json:"parameters,omitempty"`
+	RequestBody *RequestBody `json:"requestBody,omitempty"`
+	// Security overrides the document's requirements. An empty list marks a
+	// public operation.
+	Security  *[]SecurityRequirement `json:"security,omitempty"`
+	Responses map[string]Response    `json:"responses"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas,omitempty"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Description  string `json:"description,omitempty"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+}
+
+// SecurityRequirement maps security scheme names to required scopes.
+type SecurityRequirement map[string][]string
+
+// Schema is the subset of JSON Schema the generator produces.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	PropertyNames        *Schema            `json:"propertyNames,omitempty"`
+}
+
+// Route is an API route to document.
+type Route struct {
+	Method string
+	// Pattern is the chi route pattern, e.g. /state/{year}/{month}.
+	Pattern string
+	// Request and Response are the types the handler maps. Either is nil
+	// when the handler reads the request or writes the response itself.
+	Request  reflect.Type
+	Response reflect.Type
+	// ContentType is the media type of the response, JSON if empty. Only
+	// JSON responses are described by Response.
+	ContentType string
+	OperationID string
+	Summary     string
+	Description string
+	// Tag groups the operation, by default under the first path segment.
+	Tag string
+	// Public routes need no authentication.
+	Public bool
+}
+
+// TypeDoc describes a named type whose Go declaration carries more meaning
+// than its kind, such as a string type with a fixed set of values.
+type TypeDoc struct {
+	Description string
+	Enum        []any
+}
+
+// Generator builds documents.
+type Generator struct {
+	Info    Info
+	Servers []Server
+	// Security applies to every operation that isn't public.
+	Security        []SecurityRequirement
+	SecuritySchemes map[string]SecurityScheme
+	// Error is the type of error bodies, documented as every operation's
+	// default response.
+	Error reflect.Type
+	// Types describes named types, which the generator otherwise documents by
+	// their kind alone.
+	Types map[reflect.Type]TypeDoc
+}
+
+// Build documents routes.
+func (g Generator) Build(routes []Route) *Document {
+	b := &builder{
+		types:   g.Types,
+		schemas: map[string]*Schema{},
+		names:   map[reflect.Type]string{},
+	}
+	doc := &Document{
+		OpenAPI:  Version,
+		Info:     g.Info,
+		Servers:  g.Servers,
+		Security: g.Security,
+		Paths:    map[string]PathItem{},
+		Components: Components{
+			Schemas:         b.schemas,
+			SecuritySchemes: g.SecuritySchemes,
+		},
+	}
+
+	var errSchema *Schema
+	if g.Error != nil {
+		errSchema = b.schema(g.Error)
+	}
+
+	tags := map[string]bool{}
+	for _, route := range routes {
+		path, patterns := ParsePattern(route.Pattern)
+		op := b.operation(route, patterns)
+		if errSchema != nil {
+			op.Responses["default"] = Response{
+				Description: "Error",
+				Content:     map[string]MediaType{"application/json": {Schema: errSchema}},
+			}
+		}
+		if op.Tags == nil {
+			op.Tags = []string{defaultTag(path)}
+		}
+		for _, tag := range op.Tags {
+			tags[tag] = true
+		}
+		item := doc.Paths[path]
+		if item == nil {
+			item = PathItem{}
+			doc.Paths[path] = item
+		}
+		item[strings.ToLower(route.Method)] = op
+	}
+
+	for tag := range tags {
+		doc.Tags = append(doc.Tags, Tag{Name: tag})
+	}
+	sort.Slice(doc.Tags, func(i, j int) bool { return doc.Tags[i].Name < doc.Tags[j].Name })
+	return doc
+}
+
+// ParsePattern converts a chi route pattern to an OpenAPI path, dropping
+// regular expressions from its parameters and any trailing slash. It returns
+// the expressions by parameter name.
+func ParsePattern(pattern string) (string, map[string]string) {
+	var path strings.Builder
+	patterns := map[string]string{}
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '{' {
+			path.WriteByte(pattern[i])
+			continue
+		}
+		// Find the matching brace; expressions may contain their own, as in
+		// {year:[0-9]{4}}.
+		depth, end := 0, len(pattern)
+		for j := i; j < len(pattern); j++ {
+			if pattern[j] == '{' {
+				depth++
+			} else if pattern[j] == '}' {
+				depth--
+				if depth == 0 {
+					end = j
+					break
+				}
+			}
+		}
+		param := pattern[i+1 : min(end, len(pattern))]
+		name, expr, found := strings.Cut(param, ":")
+		if found {
+			patterns[name] = expr
+		}
+		path.WriteString("{" + name + "}")
+		i = end
+	}
+	p := path.String()
+	if len(p) > 1 {
+		p = strings.TrimSuffix(p, "/")
+	}
+	return p, patterns
+}
+
+func defaultTag(path string) string {
+	segment, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	segment = strings.TrimSuffix(segment, ".json")
+	if segment == "" {
+		return "API"
+	}
+	return strings.ToUpper(segment[:1]) + segment[1:]
+}
+
+type builder struct {
+	types   map[reflect.Type]TypeDoc
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+func (b *builder) operation(route Route, patterns map[string]string) *Operation {
+	op := &Operation{
+		OperationID: route.OperationID,
+		Summary:     route.Summary,
+		Description: route.Description,
+		Responses:   map[string]Response{},
+	}
+	if route.Tag != "" {
+		op.Tags = []string{route.Tag}
+	}
+	if route.Public {
+		op.Security = &[]SecurityRequirement{}
+	}
+
+	path, _ := ParsePattern(route.Pattern)
+	if route.Request != nil {
+		b.request(op, derefType(route.Request), path, patterns)
+	}
+
+	ok := Response{Description: "OK"}
+	switch {
+	case route.ContentType != "" && route.ContentType != "application/json":
+		ok.Content = map[string]MediaType{route.ContentType: {Schema: &Schema{Type: "string", Format: "binary"}}}
+	case route.Response != nil:
+		ok.Content = map[string]MediaType{"application/json": {Schema: b.inline(derefType(route.Response))}}
+	}
+	op.Responses["200"] = ok
+	return op
+}
+
+// request adds the parameters and body described by a request type.
+func (b *builder) request(op *Operation, t reflect.Type, path string, patterns map[string]string) {
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	body := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Tag.Get("meta") == "meta" {
+			op.Parameters = append(op.Parameters, b.pathParams(field.Type, path, patterns)...)
+			continue
+		}
+		if name, _, _ := strings.Cut(field.Tag.Get("schema"), ","); name != "" && name != "-" {
+			op.Parameters = append(op.Parameters, Parameter{
+				Name:        name,
+				In:          "query",
+				Description: field.Tag.Get("jsonschema"),
+				Schema:      b.schema(field.Type),
+			})
+			continue
+		}
+		b.field(body, field)
+	}
+	if len(body.Properties) > 0 {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{"application/json": {Schema: body}},
+		}
+	}
+}
+
+// pathParams documents the meta fields filled from the path. Fields for
+// parameters the path doesn't have are filled from the request context.
+func (b *builder) pathParams(meta reflect.Type, path string, patterns map[string]string) []Parameter {
+	var params []Parameter
+	for i := 0; i < meta.NumField(); i++ {
+		name := meta.Field(i).Tag.Get("meta")
+		if name == "" || !strings.Contains(path, "{"+name+"}") {
+			continue
+		}
+		schema := b.schema(meta.Field(i).Type)
+		if expr, ok := patterns[name]; ok {
+			schema = &Schema{Type: "string", Pattern: "^" + expr + "$"}
+		}
+		params = append(params, Parameter{Name: name, In: "path", Required: true, Schema: schema})
+	}
+	return params
+}
+
+// inline describes a struct in place rather than as a component. Request and
+// response wrappers are only used by one operation each.
+func (b *builder) inline(t reflect.Type) *Schema {
+	if t.Kind() != reflect.Struct || t == reflect.TypeFor[time.Time]() {
+		return b.schema(t)
+	}
+	return b.object(t)
+}
+
+// schema describes t, adding named types to the components.
+func (b *builder) schema(t reflect.Type) *Schema {
+	t = derefType(t)
+	if t == reflect.TypeFor[time.Time]() {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	if t == reflect.TypeFor[json.RawMessage]() {
+		// Raw JSON is embedded as is, so can be any value.
+		return &Schema{}
+	}
+	if t.Name() == "" || t.PkgPath() == "" {
+		return b.build(t)
+	}
+
+	name, ok := b.names[t]
+	if !ok {
+		name = b.componentName(t)
+		b.names[t] = name
+		// Register before building so recursive types refer to themselves.
+		b.schemas[name] = &Schema{}
+		s := b.build(t)
+		if doc, ok := b.types[t]; ok {
+			if doc.Description != "" {
+				s.Description = doc.Description
+			}
+			s.Enum = doc.Enum
+		}
+		*b.schemas[name] = *s
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// componentName names t's component after the type, qualified by its package
+// if another package has a type of the same name.
+func (b *builder) componentName(t reflect.Type) string {
+	name := t.Name()
+	for other, taken := range b.names {
+		if taken == name && other != t {
+			pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+			return strings.ToUpper(pkg[:1]) + pkg[1:] + name
+		}
+	}
+	return name
+}
+
+func (b *builder) build(t reflect.Type) *Schema {
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: b.schema(t.Elem())}
+	case reflect.Map:
+		s := &Schema{Type: "object", AdditionalProperties: b.schema(t.Elem())}
+		switch t.Key().Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			s.PropertyNames = &Schema{Pattern: "^-?[0-9]+$"}
+		}
+		return s
+	case reflect.Struct:
+		return b.object(t)
+	case reflect.Pointer:
+		return b.schema(t.Elem())
+	}
+	// Interfaces and anything else can hold any value.
+	return &Schema{}
+}
+
+func (b *builder) object(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for i := 0; i < t.NumField(); i++ {
+		b.field(s, t.Field(i))
+	}
+	if len(s.Properties) == 0 {
+		s.Properties = nil
+	}
+	return s
+}
+
+// field adds a struct field to an object schema the way encoding/json would
+// marshal it.
+func (b *builder) field(s *Schema, field reflect.StructField) {
+	tag := field.Tag.Get("json")
+	if tag == "-" || field.Tag.Get("meta") != "" {
+		return
+	}
+	name, opts, _ := strings.Cut(tag, ",")
+	if field.Anonymous && name == "" && derefType(field.Type).Kind() == reflect.Struct {
+		// Embedded structs are flattened into their parent.
+		embedded := b.object(derefType(field.Type))
+		for prop, schema := range embedded.Properties {
+			s.Properties[prop] = schema
+		}
+		s.Required = append(s.Required, embedded.Required...)
+		return
+	}
+	if !field.IsExported() {
+		return
+	}
+	if name == "" {
+		name = field.Name
+	}
+
+	schema := b.schema(field.Type)
+	if desc := field.Tag.Get("jsonschema"); desc != "" {
+		if schema.Ref != "" {
+			// Siblings of $ref are allowed from OpenAPI 3.1.
+			schema = &Schema{Ref: schema.Ref, Description: desc}
+		} else {
+			schema.Description = desc
+		}
+	}
+	s.Properties[name] = schema
+
+	optional := false
+	for _, opt := range strings.Split(opts, ",") {
+		if opt == "omitempty" || opt == "omitzero" {
+			optional = true
+		}
+	}
+	if !optional && field.Type.Kind() != reflect.Pointer {
+		s.Required = append(s.Required, name)
+	}
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
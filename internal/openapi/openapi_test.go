@@ -0,0 +1,153 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParsePattern(t *testing.T) {
+	cases := []struct {
+		pattern  string
+		path     string
+		patterns map[string]string
+	}{
+		{"/", "/", map[string]string{}},
+		{"/state/", "/state", map[string]string{}},
+		{"/state/{year}/{month}", "/state/{year}/{month}", map[string]string{}},
+		{
+			"/state/week/{iso_year:[0-9]{4}}-W{week:[0-9]{1,2}}",
+			"/state/week/{iso_year}-W{week}",
+			map[string]string{"iso_year": "[0-9]{4}", "week": "[0-9]{1,2}"},
+		},
+	}
+	for _, c := range cases {
+		path, patterns := ParsePattern(c.pattern)
+		if path != c.path || !reflect.DeepEqual(patterns, c.patterns) {
+			t.Errorf("ParsePattern(%q) = %q, %v; want %q, %v", c.pattern, path, patterns, c.path, c.patterns)
+		}
+	}
+}
+
+type testKind string
+
+type testItem struct {
+	ID      int             `json:"id"`
+	Kind    testKind        `json:"kind"`
+	Note    string          `json:"note,omitempty" jsonschema:"free text"`
+	At      time.Time       `json:"at,omitzero"`
+	Extra   json.RawMessage `json:"extra,omitempty"`
+	Parent  *testItem       `json:"parent,omitempty"`
+	private int
+}
+
+type testRequest struct {
+	Meta  testRequestMeta `meta:"meta" json:"-"`
+	Kinds []testKind      `schema:"kind"`
+	Data  testItem        `json:"data"`
+}
+
+type testRequestMeta struct {
+	UserID int `meta:"user_id"`
+	Year   int `meta:"year"`
+	Week   int `meta:"week"`
+}
+
+type testResponse struct {
+	Items map[int]testItem `json:"items"`
+}
+
+type testError struct {
+	Message string `json:"message"`
+}
+
+func TestBuild(t *testing.T) {
+	g := Generator{
+		Info:  Info{Title: "Test", Version: "1"},
+		Error: reflect.TypeFor[testError](),
+		Types: map[reflect.Type]TypeDoc{
+			reflect.TypeFor[testKind](): {Description: "kind of item", Enum: []any{"a", "b"}},
+		},
+	}
+	doc := g.Build([]Route{
+		{
+			Method:   "PUT",
+			Pattern:  "/items/{year}-W{week:[0-9]{1,2}}",
+			Request:  reflect.TypeFor[testRequest](),
+			Response: reflect.TypeFor[testResponse](),
+			Summary:  "Put items",
+		},
+		{Method: "GET", Pattern: "/files/", ContentType: "text/csv", Public: true},
+	})
+
+	if doc.OpenAPI != Version {
+		t.Errorf("openapi = %q, want %q", doc.OpenAPI, Version)
+	}
+	op := doc.Paths["/items/{year}-W{week}"]["put"]
+	if op == nil {
+		t.Fatalf("missing put operation, paths: %v", doc.Paths)
+	}
+	if op.Summary != "Put items" || !slices.Equal(op.Tags, []string{"Items"}) {
+		t.Errorf("summary %q, tags %v", op.Summary, op.Tags)
+	}
+
+	want := []Parameter{
+		{Name: "year", In: "path", Required: true, Schema: &Schema{Type: "integer"}},
+		{Name: "week", In: "path", Required: true, Schema: &Schema{Type: "string", Pattern: "^[0-9]{1,2}$"}},
+		{Name: "kind", In: "query", Schema: &Schema{Type: "array", Items: &Schema{Ref: "#/components/schemas/testKind"}}},
+	}
+	if !reflect.DeepEqual(op.Parameters, want) {
+		got, _ := json.Marshal(op.Parameters)
+		t.Errorf("parameters = %s", got)
+	}
+
+	body := op.RequestBody.Content["application/json"].Schema
+	if len(body.Properties) != 1 || body.Properties["data"].Ref != "#/components/schemas/testItem" {
+		got, _ := json.Marshal(body)
+		t.Errorf("request body = %s", got)
+	}
+
+	resp := op.Responses["200"].Content["application/json"].Schema
+	items := resp.Properties["items"]
+	if items.Type != "object" || items.PropertyNames == nil || items.AdditionalProperties.Ref != "#/components/schemas/testItem" {
+		got, _ := json.Marshal(resp)
+		t.Errorf("response = %s", got)
+	}
+	if op.Responses["default"].Content["application/json"].Schema.Ref != "#/components/schemas/testError" {
+		t.Errorf("default response = %+v", op.Responses["default"])
+	}
+
+	item := doc.Components.Schemas["testItem"]
+	if item == nil {
+		t.Fatalf("missing testItem component: %v", doc.Components.Schemas)
+	}
+	if !slices.Equal(item.Required, []string{"id", "kind"}) {
+		t.Errorf("testItem required = %v", item.Required)
+	}
+	if item.Properties["note"].Description != "free text" || item.Properties["at"].Format != "date-time" {
+		t.Errorf("testItem properties = %+v", item.Properties)
+	}
+	if extra := item.Properties["extra"]; extra == nil || !reflect.DeepEqual(*extra, Schema{}) {
+		t.Errorf("raw JSON field = %+v, want any value", extra)
+	}
+	if item.Properties["parent"].Ref != "#/components/schemas/testItem" {
+		t.Errorf("recursive field = %+v", item.Properties["parent"])
+	}
+	if _, ok := item.Properties["private"]; ok {
+		t.Error("unexported field documented")
+	}
+	kind := doc.Components.Schemas["testKind"]
+	if kind.Type != "string" || kind.Description != "kind of item" || len(kind.Enum) != 2 {
+		t.Errorf("testKind = %+v", kind)
+	}
+
+	files := doc.Paths["/files"]["get"]
+	if files == nil || files.Security == nil || len(*files.Security) != 0 {
+		t.Fatalf("public operation security = %+v", files)
+	}
+	if _, ok := files.Responses["200"].Content["text/csv"]; !ok {
+		t.Errorf("files response = %+v", files.Responses["200"])
+	}
+}
@@ -0,0 +1,124 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/baely/officetracker/internal/config"
+)
+
+// ExpoURL is Expo's push API.
+const ExpoURL = "https://exp.host/--/api/v2/push/send"
+
+// DeviceSender delivers messages to the mobile app on the device with an Expo
+// push token.
+type DeviceSender interface {
+	Send(token string, msg Message) error
+}
+
+// Expo sends messages through Expo's push API, which passes them on to Apple
+// and Google. Only the ticket Expo returns straight away is checked; a device
+// it learns has gone later is reported the next time it's sent to.
+type Expo struct {
+	url         string
+	accessToken string
+	client      *http.Client
+}
+
+// NewExpo returns a sender for cfg's push URL, making requests with client.
+func NewExpo(cfg config.Expo, client *http.Client) *Expo {
+	return &Expo{
+		url:         cfg.PushURL,
+		accessToken: cfg.AccessToken,
+		client:      client,
+	}
+}
+
+// expoMessage is a message as Expo's API takes it. The app reads data when the
+// notification is tapped.
+type expoMessage struct {
+	To    string            `json:"to"`
+	Title string            `json:"title"`
+	Body  string            `json:"body"`
+	Data  map[string]string `json:"data,omitempty"`
+	Sound string            `json:"sound"`
+	TTL   int               `json:"ttl"`
+}
+
+// expoTicket is Expo's answer for each message.
+type expoTicket struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Details struct {
+		Error string `json:"error"`
+	} `json:"details"`
+}
+
+// Send posts msg to Expo for the device with token. It returns ErrGone when
+// Expo reports the device is no longer registered, because the app was
+// uninstalled or its token has changed.
+func (e *Expo) Send(token string, msg Message) error {
+	data := map[string]string{}
+	if msg.URL != "" {
+		data["url"] = msg.URL
+	}
+	if msg.Tag != "" {
+		data["tag"] = msg.Tag
+	}
+	body, err := json.Marshal([]expoMessage{{
+		To:    token,
+		Title: msg.Title,
+		Body:  msg.Body,
+		Data:  data,
+		Sound: "default",
+		TTL:   int(ttl.Seconds()),
+	}})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if e.accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+e.accessToken)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		return fmt.Errorf("push: expo returned %s", resp.Status)
+	}
+
+	var result struct {
+		Data []expoTicket `json:"data"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&result); err != nil {
+		return fmt.Errorf("push: failed to read expo response: %w", err)
+	}
+	if len(result.Data) != 1 {
+		return errors.New("push: expo returned no ticket")
+	}
+	switch ticket := result.Data[0]; {
+	case ticket.Status == "ok":
+		return nil
+	case ticket.Details.Error == "DeviceNotRegistered":
+		return ErrGone
+	default:
+		return fmt.Errorf("push: expo: %s", ticket.Message)
+	}
+}
@@ -0,0 +1,256 @@
+// Package push sends push notifications to browsers and the mobile app.
+// Callers send through a Sender or DeviceSender, so the delivery method can be
+// swapped. VAPID delivers to the push service behind each browser's
+// subscription, encrypting the message for the browser (RFC 8291) and signing
+// the request with the server's key (RFC 8292); Expo delivers to the mobile
+// app through Expo's push API.
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/baely/officetracker/internal/config"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+const (
+	// ttl is how long a push service holds a message for an offline browser.
+	// Reminders are stale by the next day.
+	ttl = 24 * time.Hour
+	// tokenLifetime is how long each signed request is valid for. Push
+	// services refuse more than a day.
+	tokenLifetime = 12 * time.Hour
+	// recordSize is the single aes128gcm record a message is sent in.
+	recordSize = 4096
+	timeout    = 10 * time.Second
+)
+
+// ErrGone is returned when the push service no longer knows the subscription,
+// because it expired or the browser unsubscribed. It should be deleted.
+var ErrGone = errors.New("push: subscription has expired or been removed")
+
+// Message is the notification the service worker shows. URL is opened when
+// it's clicked, and a later message with the same Tag replaces it.
+type Message struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	URL   string `json:"url,omitempty"`
+	Tag   string `json:"tag,omitempty"`
+}
+
+// Sender delivers messages to a browser's subscription.
+type Sender interface {
+	Send(sub model.PushSubscription, msg Message) error
+}
+
+// VAPID sends messages through the push service named by each subscription's
+// endpoint, identifying the server with its VAPID key pair.
+type VAPID struct {
+	key       *ecdsa.PrivateKey
+	publicKey string
+	subject   string
+	client    *http.Client
+}
+
+// NewVAPID returns a sender for cfg's key pair and subject, making requests
+// with client.
+func NewVAPID(cfg config.WebPush, client *http.Client) (*VAPID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cfg.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("push: invalid private key: %w", err)
+	}
+	key, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), raw)
+	if err != nil {
+		return nil, fmt.Errorf("push: invalid private key: %w", err)
+	}
+	public, err := key.PublicKey.Bytes()
+	if err != nil {
+		return nil, fmt.Errorf("push: invalid private key: %w", err)
+	}
+	if encoded := base64.RawURLEncoding.EncodeToString(public); encoded != strings.TrimRight(cfg.PublicKey, "=") {
+		return nil, errors.New("push: public key doesn't match the private key")
+	}
+	if !strings.HasPrefix(cfg.Subject, "mailto:") && !strings.HasPrefix(cfg.Subject, "https:") {
+		return nil, fmt.Errorf("push: subject %q must be a mailto: or https: URL", cfg.Subject)
+	}
+	return &VAPID{
+		key:       key,
+		publicKey: base64.RawURLEncoding.EncodeToString(public),
+		subject:   cfg.Subject,
+		client:    client,
+	}, nil
+}
+
+// GenerateKeys returns a new VAPID key pair, encoded as config.WebPush
+// expects.
+func GenerateKeys() (publicKey, privateKey string, err error) {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()),
+		base64.RawURLEncoding.EncodeToString(key.Bytes()), nil
+}
+
+// Send encrypts msg for the subscription and posts it to its push service.
+func (v *VAPID) Send(sub model.PushSubscription, msg Message) error {
+	endpoint, err := url.Parse(sub.Endpoint)
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return fmt.Errorf("push: invalid endpoint %q", sub.Endpoint)
+	}
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	serverKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	salt := make([]byte, 16)
+	rand.Read(salt)
+	body, err := encrypt(sub.Keys, payload, serverKey, salt)
+	if err != nil {
+		return err
+	}
+	token, err := v.token(endpoint.Scheme+"://"+endpoint.Host, time.Now())
+	if err != nil {
+		return fmt.Errorf("push: failed to sign request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", strconv.Itoa(int(ttl.Seconds())))
+	req.Header.Set("Authorization", fmt.Sprintf("vapid t=%s, k=%s", token, v.publicKey))
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Drain a little of the body so the connection can be reused.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrGone
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return fmt.Errorf("push: unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// token signs the claims identifying the server to the push service at
+// audience, its scheme and host.
+func (v *VAPID) token(audience string, now time.Time) (string, error) {
+	claims := jwt.RegisteredClaims{
+		Audience:  jwt.ClaimStrings{audience},
+		ExpiresAt: jwt.NewNumericDate(now.Add(tokenLifetime)),
+		Subject:   v.subject,
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodES256, claims).SignedString(v.key)
+}
+
+// encrypt encodes payload as a single aes128gcm record (RFC 8188) for the
+// browser's keys, following RFC 8291. serverKey is a key pair used for this
+// message only, and salt 16 random bytes.
+func encrypt(keys model.PushKeys, payload []byte, serverKey *ecdh.PrivateKey, salt []byte) ([]byte, error) {
+	browserPublic, err := decodeKey(keys.P256dh)
+	if err != nil {
+		return nil, fmt.Errorf("push: invalid p256dh key: %w", err)
+	}
+	browserKey, err := ecdh.P256().NewPublicKey(browserPublic)
+	if err != nil {
+		return nil, fmt.Errorf("push: invalid p256dh key: %w", err)
+	}
+	auth, err := decodeKey(keys.Auth)
+	if err != nil || len(auth) != 16 {
+		return nil, errors.New("push: invalid auth secret")
+	}
+	if len(salt) != 16 {
+		return nil, errors.New("push: salt must be 16 bytes")
+	}
+	// The record holds the payload, a delimiter and the 16 byte tag.
+	if len(payload)+17 > recordSize {
+		return nil, fmt.Errorf("push: payload of %d bytes is too large", len(payload))
+	}
+
+	shared, err := serverKey.ECDH(browserKey)
+	if err != nil {
+		return nil, err
+	}
+	serverPublic := serverKey.PublicKey().Bytes()
+
+	prk, err := hkdf.Extract(sha256.New, shared, auth)
+	if err != nil {
+		return nil, err
+	}
+	ikm, err := hkdf.Expand(sha256.New, prk, "WebPush: info\x00"+string(browserPublic)+string(serverPublic), 32)
+	if err != nil {
+		return nil, err
+	}
+	prk, err = hkdf.Extract(sha256.New, ikm, salt)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// The header is the salt, record size and the key the browser needs to
+	// derive the same secret.
+	body := make([]byte, 0, 16+4+1+len(serverPublic)+len(payload)+17)
+	body = append(body, salt...)
+	body = binary.BigEndian.AppendUint32(body, recordSize)
+	body = append(body, byte(len(serverPublic)))
+	body = append(body, serverPublic...)
+	// 2 marks the last record, with no padding after it.
+	return gcm.Seal(body, nonce, append(payload, 2), nil), nil
+}
+
+// decodeKey decodes a browser key, which is base64url encoded with or
+// without padding.
+func decodeKey(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
@@ -0,0 +1,180 @@
+package push
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/baely/officetracker/internal/config"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+func decode(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// The example from RFC 8291, Appendix A.
+func TestEncrypt(t *testing.T) {
+	serverKey, err := ecdh.P256().NewPrivateKey(decode(t, "yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := model.PushKeys{
+		P256dh: "BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4",
+		Auth:   "BTBZMqHH6r4Tts7J_aSIgg",
+	}
+	payload := []byte("When I grow up, I want to be a watermelon")
+
+	body, err := encrypt(keys, payload, serverKey, decode(t, "DGv6ra1nlYgDCS1FRnbzlw"))
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	want := "DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A_yl95bQpu6cVPTpK4Mqgkf1CXztLVBSt2Ks3oZwbuwXPXLWyouBWLVWGNWQexSgSxsj_Qulcy4a-fN"
+	if got := base64.RawURLEncoding.EncodeToString(body); got != want {
+		t.Errorf("encrypt =\n%s\nwant\n%s", got, want)
+	}
+
+	if _, err := encrypt(model.PushKeys{P256dh: keys.P256dh, Auth: "c2hvcnQ"}, payload, serverKey, decode(t, "DGv6ra1nlYgDCS1FRnbzlw")); err == nil {
+		t.Error("expected a short auth secret to be rejected")
+	}
+}
+
+func newTestVAPID(t *testing.T) *VAPID {
+	t.Helper()
+	public, private, err := GenerateKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := NewVAPID(config.WebPush{PublicKey: public, PrivateKey: private, Subject: "mailto:ops@example.com"}, http.DefaultClient)
+	if err != nil {
+		t.Fatalf("NewVAPID: %v", err)
+	}
+	return v
+}
+
+func TestVAPIDSend(t *testing.T) {
+	v := newTestVAPID(t)
+	var req *http.Request
+	var body []byte
+	status := http.StatusCreated
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	browser, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := model.PushSubscription{
+		Endpoint: srv.URL + "/push/abc",
+		Keys: model.PushKeys{
+			P256dh: base64.RawURLEncoding.EncodeToString(browser.PublicKey().Bytes()),
+			Auth:   base64.RawURLEncoding.EncodeToString([]byte("0123456789abcdef")),
+		},
+	}
+	if err := v.Send(sub, Message{Title: "Where did you work today?"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if req.URL.Path != "/push/abc" || req.Header.Get("Content-Encoding") != "aes128gcm" || req.Header.Get("TTL") != "86400" {
+		t.Errorf("request = %s %v", req.URL, req.Header)
+	}
+	// The salt, record size, key length and the 65 byte key, then the record.
+	if len(body) < 86 || body[20] != 65 {
+		t.Errorf("body of %d bytes isn't an aes128gcm record", len(body))
+	}
+
+	auth, ok := strings.CutPrefix(req.Header.Get("Authorization"), "vapid t=")
+	token, key, _ := strings.Cut(auth, ", k=")
+	if !ok || key != v.publicKey {
+		t.Fatalf("Authorization = %q", req.Header.Get("Authorization"))
+	}
+	var claims jwt.RegisteredClaims
+	if _, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (any, error) {
+		return &v.key.PublicKey, nil
+	}, jwt.WithValidMethods([]string{"ES256"}), jwt.WithAudience(srv.URL)); err != nil {
+		t.Errorf("token: %v", err)
+	}
+	if claims.Subject != "mailto:ops@example.com" {
+		t.Errorf("subject = %q", claims.Subject)
+	}
+
+	status = http.StatusGone
+	if err := v.Send(sub, Message{}); !errors.Is(err, ErrGone) {
+		t.Errorf("Send to a removed subscription = %v, want ErrGone", err)
+	}
+	status = http.StatusTooManyRequests
+	if err := v.Send(sub, Message{}); err == nil || errors.Is(err, ErrGone) {
+		t.Errorf("Send when rate limited = %v, want an error", err)
+	}
+}
+
+func TestNewVAPID(t *testing.T) {
+	public, private, err := GenerateKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, _ := GenerateKeys()
+	for _, cfg := range []config.WebPush{
+		{PublicKey: public, Subject: "mailto:ops@example.com"},
+		{PublicKey: other, PrivateKey: private, Subject: "mailto:ops@example.com"},
+		{PublicKey: public, PrivateKey: private, Subject: "ops@example.com"},
+	} {
+		if _, err := NewVAPID(cfg, http.DefaultClient); err == nil {
+			t.Errorf("NewVAPID(%+v) succeeded, want an error", cfg)
+		}
+	}
+	if _, err := NewVAPID(config.WebPush{PublicKey: public, PrivateKey: private, Subject: "https://ot.example"}, http.DefaultClient); err != nil {
+		t.Errorf("NewVAPID: %v", err)
+	}
+}
+
+func TestExpoSend(t *testing.T) {
+	var req *http.Request
+	var sent []map[string]any
+	ticket := `{"status":"ok","id":"XXXX-XXXX"}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		json.NewDecoder(r.Body).Decode(&sent)
+		io.WriteString(w, `{"data":[`+ticket+`]}`)
+	}))
+	defer srv.Close()
+	e := NewExpo(config.Expo{PushURL: srv.URL, AccessToken: "expo-token"}, http.DefaultClient)
+
+	msg := Message{Title: "Checked in", Body: "Tuesday 7 May is recorded as in the office.", Tag: "check_in"}
+	if err := e.Send("ExponentPushToken[abc]", msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if req.Header.Get("Authorization") != "Bearer expo-token" || req.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("headers = %v", req.Header)
+	}
+	if len(sent) != 1 || sent[0]["to"] != "ExponentPushToken[abc]" || sent[0]["title"] != msg.Title ||
+		sent[0]["data"].(map[string]any)["tag"] != "check_in" {
+		t.Errorf("sent %v", sent)
+	}
+
+	ticket = `{"status":"error","message":"\"ExponentPushToken[abc]\" is not a registered push notification recipient","details":{"error":"DeviceNotRegistered"}}`
+	if err := e.Send("ExponentPushToken[abc]", msg); !errors.Is(err, ErrGone) {
+		t.Errorf("Send to an unregistered device = %v, want ErrGone", err)
+	}
+	ticket = `{"status":"error","message":"Message rate exceeded","details":{"error":"MessageRateExceeded"}}`
+	if err := e.Send("ExponentPushToken[abc]", msg); err == nil || errors.Is(err, ErrGone) {
+		t.Errorf("Send when rate limited = %v, want an error", err)
+	}
+}
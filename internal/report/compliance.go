@@ -0,0 +1,205 @@
+package report
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/baely/officetracker/internal/util"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+// TargetWindow returns the half-open [start, end) window of prefs that contains
+// day. Quarters follow the tracking year, so with an October start the first
+// quarter runs October to December. A rolling window ends with day.
+func TargetWindow(prefs model.TargetPreferences, startMonth int, day time.Time) (start, end time.Time) {
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	switch prefs.Window {
+	case model.TargetWindowRollingWeeks:
+		weeks := prefs.WindowWeeks
+		if weeks < 1 {
+			weeks = model.DefaultTargetWindowWeeks
+		}
+		end = day.AddDate(0, 0, 1)
+		start = end.AddDate(0, 0, -7*weeks)
+	case model.TargetWindowQuarter:
+		startMonth = util.NormaliseStartMonth(startMonth)
+		offset := (int(day.Month()) - startMonth + 12) % 3
+		start = time.Date(day.Year(), day.Month()-time.Month(offset), 1, 0, 0, 0, 0, day.Location())
+		end = start.AddDate(0, 3, 0)
+	case model.TargetWindowTrackingYear:
+		startMonth = util.NormaliseStartMonth(startMonth)
+		year := util.TrackingYear(int(day.Month()), day.Year(), startMonth)
+		firstYear, _ := util.TrackingYearCalendarYears(year, startMonth)
+		start = time.Date(firstYear, time.Month(startMonth), 1, 0, 0, 0, 0, day.Location())
+		end = start.AddDate(1, 0, 0)
+	default:
+		start = time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, day.Location())
+		end = start.AddDate(0, 1, 0)
+	}
+	return start, end
+}
+
+// Compliance measures attendance against prefs over [start, end) as of asOf.
+// Days up to and including asOf count towards Present, Total and Actual. For
+// the percent policy, later days that are tracked as work days, or are
+// untracked weekdays, are assumed to be work days when working out how many
+// office days the window needs.
+func Compliance(prefs model.TargetPreferences, customStates model.CustomStates, start, end, asOf time.Time, state func(time.Time) model.DayState) model.Compliance {
+	asOf = time.Date(asOf.Year(), asOf.Month(), asOf.Day(), 0, 0, 0, 0, start.Location())
+
+	var present, total, projected, windowDays, elapsedDays int
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		windowDays++
+		s := state(day).State
+		attendance := customStates.Attendance(s)
+		if day.After(asOf) {
+			weekday := day.Weekday() != time.Saturday && day.Weekday() != time.Sunday
+			if attendance != model.AttendanceExcluded || (s == model.StateUntracked && weekday) {
+				projected++
+			}
+			continue
+		}
+		elapsedDays++
+		switch attendance {
+		case model.AttendancePresent:
+			present++
+			total++
+		case model.AttendanceAbsent:
+			total++
+		}
+	}
+
+	c := model.Compliance{
+		Policy:  prefs.Policy,
+		Window:  prefs.Window,
+		Start:   start.Format("2006-01-02"),
+		End:     end.AddDate(0, 0, -1).Format("2006-01-02"),
+		Present: present,
+		Total:   total,
+	}
+
+	switch prefs.Policy {
+	case model.TargetPolicyDaysPerWeek, model.TargetPolicyDaysPerMonth:
+		units := float64(windowDays) / 7
+		if prefs.Policy == model.TargetPolicyDaysPerMonth {
+			units = windowMonths(prefs.Window, windowDays)
+		}
+		c.Target = prefs.TargetDays
+		c.Required = int(math.Ceil(prefs.TargetDays*units - 1e-9))
+		if elapsedDays > 0 {
+			c.Actual = round2(float64(present) / (units * float64(elapsedDays) / float64(windowDays)))
+		}
+	default:
+		c.Target = float64(prefs.TargetPercent)
+		c.Required = int(math.Ceil(float64(prefs.TargetPercent)/100*float64(total+projected) - 1e-9))
+		if total > 0 {
+			c.Actual = round2(float64(present) / float64(total) * 100)
+		}
+	}
+
+	c.Remaining = max(0, c.Required-present)
+	c.Met = c.Remaining == 0
+	return c
+}
+
+// windowMonths is how many months a window spans, for the days-per-month
+// policy. Rolling windows use the average month length.
+func windowMonths(window model.TargetWindow, days int) float64 {
+	switch window {
+	case model.TargetWindowMonth:
+		return 1
+	case model.TargetWindowQuarter:
+		return 3
+	case model.TargetWindowTrackingYear:
+		return 12
+	default:
+		return float64(days) * 12 / 365.25
+	}
+}
+
+func round2(f float64) float64 {
+	return math.Round(f*100) / 100
+}
+
+// DescribeTarget summarises a target, e.g. "3 days per week over a rolling 12
+// weeks".
+func DescribeTarget(prefs model.TargetPreferences) string {
+	var amount string
+	switch prefs.Policy {
+	case model.TargetPolicyDaysPerWeek:
+		amount = fmt.Sprintf("%s days per week", formatDays(prefs.TargetDays))
+	case model.TargetPolicyDaysPerMonth:
+		amount = fmt.Sprintf("%s days per month", formatDays(prefs.TargetDays))
+	default:
+		amount = fmt.Sprintf("%d%% of work days", prefs.TargetPercent)
+	}
+
+	var window string
+	switch prefs.Window {
+	case model.TargetWindowMonth:
+		if prefs.Policy == model.TargetPolicyDaysPerMonth {
+			return amount
+		}
+		window = "each month"
+	case model.TargetWindowRollingWeeks:
+		window = fmt.Sprintf("over a rolling %d weeks", prefs.WindowWeeks)
+	case model.TargetWindowQuarter:
+		window = "each quarter"
+	case model.TargetWindowTrackingYear:
+		window = "over the tracking year"
+	default:
+		window = "each month"
+	}
+	return amount + " " + window
+}
+
+// DescribeCompliance summarises progress against a target, e.g. "2.5 of 3
+// days per week so far (24 of 36 office days); 12 more needed by 2025-03-31".
+func DescribeCompliance(c model.Compliance) string {
+	var progress string
+	switch c.Policy {
+	case model.TargetPolicyDaysPerWeek:
+		progress = fmt.Sprintf("%s of %s days per week so far", formatDays(c.Actual), formatDays(c.Target))
+	case model.TargetPolicyDaysPerMonth:
+		progress = fmt.Sprintf("%s of %s days per month so far", formatDays(c.Actual), formatDays(c.Target))
+	default:
+		progress = fmt.Sprintf("%.1f%% of %.0f%% so far", c.Actual, c.Target)
+	}
+	progress += fmt.Sprintf(" (%d of %d office days)", c.Present, c.Required)
+
+	switch {
+	case c.Met:
+		return progress + "; target met"
+	case c.Window == model.TargetWindowRollingWeeks:
+		// A rolling window ends today, so there's no "by" date to aim for.
+		return fmt.Sprintf("%s; %d office days short", progress, c.Remaining)
+	default:
+		return fmt.Sprintf("%s; %d more needed by %s", progress, c.Remaining, c.End)
+	}
+}
+
+// formatDays drops trailing zeros so whole day counts read naturally.
+func formatDays(days float64) string {
+	return strconv.FormatFloat(round2(days), 'f', -1, 64)
+}
+
+// DescribeForecast summarises a forecast, e.g. "4 of 12 office days so far; 8
+// more needed by 2025-03-31 with 20 work days left, 6 planned (projected
+// 40.0%); 2 more beyond those planned".
+func DescribeForecast(f model.Forecast) string {
+	if f.Needed == 0 {
+		return fmt.Sprintf("%d of %d office days so far; target already met", f.Present, f.Required)
+	}
+	summary := fmt.Sprintf("%d of %d office days so far; %d more needed by %s with %d work days left, %d planned (projected %.1f%%)",
+		f.Present, f.Required, f.Needed, f.End, f.RemainingWorkDays, f.Planned, f.ProjectedPercent)
+	switch {
+	case !f.Achievable:
+		return summary + "; no longer achievable"
+	case f.ProjectedMet:
+		return summary + "; on track"
+	default:
+		return fmt.Sprintf("%s; %d more beyond those planned", summary, f.Additional)
+	}
+}
@@ -0,0 +1,167 @@
+package report
+
+import (
+	"testing"
+	"time"
+
+	"github.com/baely/officetracker/pkg/model"
+)
+
+func TestTargetWindow(t *testing.T) {
+	day := date(2024, time.March, 15)
+	cases := map[string]struct {
+		prefs      model.TargetPreferences
+		startMonth int
+		start, end string
+	}{
+		"month":               {model.TargetPreferences{Window: model.TargetWindowMonth}, 10, "2024-03-01", "2024-04-01"},
+		"unset window":        {model.TargetPreferences{}, 10, "2024-03-01", "2024-04-01"},
+		"quarter from oct":    {model.TargetPreferences{Window: model.TargetWindowQuarter}, 10, "2024-01-01", "2024-04-01"},
+		"quarter from feb":    {model.TargetPreferences{Window: model.TargetWindowQuarter}, 2, "2024-02-01", "2024-05-01"},
+		"tracking year (oct)": {model.TargetPreferences{Window: model.TargetWindowTrackingYear}, 10, "2023-10-01", "2024-10-01"},
+		"tracking year (jan)": {model.TargetPreferences{Window: model.TargetWindowTrackingYear}, 1, "2024-01-01", "2025-01-01"},
+		"rolling 4 weeks":     {model.TargetPreferences{Window: model.TargetWindowRollingWeeks, WindowWeeks: 4}, 10, "2024-02-17", "2024-03-16"},
+	}
+	for name, c := range cases {
+		start, end := TargetWindow(c.prefs, c.startMonth, day)
+		if got := start.Format("2006-01-02"); got != c.start {
+			t.Errorf("%s: start = %s, want %s", name, got, c.start)
+		}
+		if got := end.Format("2006-01-02"); got != c.end {
+			t.Errorf("%s: end = %s, want %s", name, got, c.end)
+		}
+	}
+}
+
+// marchStates has three office days and two at home in the first half of
+// March 2024, with everything else untracked.
+func marchStates(day time.Time) model.DayState {
+	if day.Year() != 2024 || day.Month() != time.March {
+		return model.DayState{}
+	}
+	switch day.Day() {
+	case 4, 5, 6:
+		return model.DayState{State: model.StateWorkFromOffice}
+	case 7, 8:
+		return model.DayState{State: model.StateWorkFromHome}
+	}
+	return model.DayState{}
+}
+
+func TestCompliance(t *testing.T) {
+	asOf := date(2024, time.March, 15)
+	cases := map[string]struct {
+		prefs model.TargetPreferences
+		want  model.Compliance
+	}{
+		// 5 tracked work days plus 10 untracked weekdays still to come.
+		"percent per month": {
+			model.TargetPreferences{Policy: model.TargetPolicyPercent, TargetPercent: 60, Window: model.TargetWindowMonth},
+			model.Compliance{Policy: model.TargetPolicyPercent, Window: model.TargetWindowMonth, Start: "2024-03-01", End: "2024-03-31",
+				Present: 3, Total: 5, Actual: 60, Target: 60, Required: 9, Remaining: 6},
+		},
+		// 31/7 weeks at 3 days each, with 15 of 31 days elapsed.
+		"days per week over a month": {
+			model.TargetPreferences{Policy: model.TargetPolicyDaysPerWeek, TargetDays: 3, Window: model.TargetWindowMonth},
+			model.Compliance{Policy: model.TargetPolicyDaysPerWeek, Window: model.TargetWindowMonth, Start: "2024-03-01", End: "2024-03-31",
+				Present: 3, Total: 5, Actual: 1.4, Target: 3, Required: 14, Remaining: 11},
+		},
+		// The January to March quarter of an October tracking year.
+		"days per month over a quarter": {
+			model.TargetPreferences{Policy: model.TargetPolicyDaysPerMonth, TargetDays: 8, Window: model.TargetWindowQuarter},
+			model.Compliance{Policy: model.TargetPolicyDaysPerMonth, Window: model.TargetWindowQuarter, Start: "2024-01-01", End: "2024-03-31",
+				Present: 3, Total: 5, Actual: 1.21, Target: 8, Required: 24, Remaining: 21},
+		},
+		"days per week over rolling weeks": {
+			model.TargetPreferences{Policy: model.TargetPolicyDaysPerWeek, TargetDays: 2, Window: model.TargetWindowRollingWeeks, WindowWeeks: 4},
+			model.Compliance{Policy: model.TargetPolicyDaysPerWeek, Window: model.TargetWindowRollingWeeks, Start: "2024-02-17", End: "2024-03-15",
+				Present: 3, Total: 5, Actual: 0.75, Target: 2, Required: 8, Remaining: 5},
+		},
+		"met": {
+			model.TargetPreferences{Policy: model.TargetPolicyDaysPerWeek, TargetDays: 1, Window: model.TargetWindowRollingWeeks, WindowWeeks: 2},
+			model.Compliance{Policy: model.TargetPolicyDaysPerWeek, Window: model.TargetWindowRollingWeeks, Start: "2024-03-02", End: "2024-03-15",
+				Present: 3, Total: 5, Actual: 1.5, Target: 1, Required: 2, Met: true},
+		},
+	}
+	for name, c := range cases {
+		start, end := TargetWindow(c.prefs, 10, asOf)
+		got := Compliance(c.prefs, nil, start, end, asOf, marchStates)
+		if got != c.want {
+			t.Errorf("%s: Compliance = %+v, want %+v", name, got, c.want)
+		}
+	}
+}
+
+// Once the month is over there is nothing left to project, so the percent
+// policy needs exactly its share of the tracked days.
+func TestCompliancePercentMonthEnd(t *testing.T) {
+	prefs := model.TargetPreferences{Policy: model.TargetPolicyPercent, TargetPercent: 50, Window: model.TargetWindowMonth}
+	asOf := date(2024, time.March, 31)
+	start, end := TargetWindow(prefs, 10, asOf)
+	got := Compliance(prefs, nil, start, end, asOf, marchStates)
+	if got.Required != 3 || !got.Met {
+		t.Errorf("Compliance = %+v, want 3 required and met", got)
+	}
+}
+
+func TestDescribeTarget(t *testing.T) {
+	cases := map[string]model.TargetPreferences{
+		"60% of work days each month":             {Policy: model.TargetPolicyPercent, TargetPercent: 60, Window: model.TargetWindowMonth},
+		"3 days per week over a rolling 12 weeks": {Policy: model.TargetPolicyDaysPerWeek, TargetDays: 3, Window: model.TargetWindowRollingWeeks, WindowWeeks: 12},
+		"8.5 days per month each quarter":         {Policy: model.TargetPolicyDaysPerMonth, TargetDays: 8.5, Window: model.TargetWindowQuarter},
+		"2 days per week over the tracking year":  {Policy: model.TargetPolicyDaysPerWeek, TargetDays: 2, Window: model.TargetWindowTrackingYear},
+		"10 days per month":                       {Policy: model.TargetPolicyDaysPerMonth, TargetDays: 10, Window: model.TargetWindowMonth},
+	}
+	for want, prefs := range cases {
+		if got := DescribeTarget(prefs); got != want {
+			t.Errorf("DescribeTarget(%+v) = %q, want %q", prefs, got, want)
+		}
+	}
+}
+
+func TestDescribeCompliance(t *testing.T) {
+	cases := map[string]model.Compliance{
+		"60.0% of 60% so far (3 of 9 office days); 6 more needed by 2024-03-31": {
+			Policy: model.TargetPolicyPercent, Window: model.TargetWindowMonth, End: "2024-03-31",
+			Actual: 60, Target: 60, Present: 3, Required: 9, Remaining: 6,
+		},
+		"0.75 of 2 days per week so far (3 of 8 office days); 5 office days short": {
+			Policy: model.TargetPolicyDaysPerWeek, Window: model.TargetWindowRollingWeeks, End: "2024-03-15",
+			Actual: 0.75, Target: 2, Present: 3, Required: 8, Remaining: 5,
+		},
+		"9 of 8 days per month so far (27 of 24 office days); target met": {
+			Policy: model.TargetPolicyDaysPerMonth, Window: model.TargetWindowQuarter, End: "2024-03-31",
+			Actual: 9, Target: 8, Present: 27, Required: 24, Met: true,
+		},
+	}
+	for want, c := range cases {
+		if got := DescribeCompliance(c); got != want {
+			t.Errorf("DescribeCompliance(%+v) = %q, want %q", c, got, want)
+		}
+	}
+}
+
+func TestDescribeForecast(t *testing.T) {
+	cases := map[string]model.Forecast{
+		"12 of 11 office days so far; target already met": {
+			Present: 12, Required: 11,
+		},
+		"5 of 11 office days so far; 6 more needed by 2024-03-31 with 10 work days left, 5 planned (projected 58.8%); 1 more beyond those planned": {
+			End: "2024-03-31", Present: 5, Required: 11, Needed: 6, RemainingWorkDays: 10, Planned: 5,
+			ProjectedPercent: 58.82, Additional: 1, Achievable: true,
+		},
+		"5 of 11 office days so far; 6 more needed by 2024-03-31 with 10 work days left, 6 planned (projected 64.7%); on track": {
+			End: "2024-03-31", Present: 5, Required: 11, Needed: 6, RemainingWorkDays: 10, Planned: 6,
+			ProjectedPercent: 64.71, ProjectedMet: true, Achievable: true,
+		},
+		"5 of 23 office days so far; 18 more needed by 2024-03-31 with 10 work days left, 5 planned (projected 58.8%); no longer achievable": {
+			End: "2024-03-31", Present: 5, Required: 23, Needed: 18, RemainingWorkDays: 10, Planned: 5,
+			ProjectedPercent: 58.82, Additional: 13,
+		},
+	}
+	for want, f := range cases {
+		if got := DescribeForecast(f); got != want {
+			t.Errorf("DescribeForecast(%+v) = %q, want %q", f, got, want)
+		}
+	}
+}
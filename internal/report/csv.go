@@ -2,28 +2,45 @@ package report
 
 import (
 	"bytes"
+	"encoding/csv"
 	"fmt"
 	"time"
 
+	"github.com/baely/officetracker/internal/util"
 	"github.com/baely/officetracker/pkg/model"
 )
 
 type csvLine struct {
-	Date  string
-	State string
+	Date     string
+	State    string
+	Note     string
+	Location string
+	Week     string
 }
 
-func (r *fileReporter) GenerateCSV(userID int, start, end time.Time) ([]byte, error) {
+// GenerateCSV reports one line per weekday with columns Date and State, and
+// with detail also Note, Location and Week.
+func (r *fileReporter) GenerateCSV(userID int, start, end time.Time, filter Filter, detail bool) ([]byte, error) {
 	report, err := r.Generate(userID, start, end)
 	if err != nil {
 		err = fmt.Errorf("failed to generate report: %w", err)
 		return nil, err
 	}
+	report = filter.apply(report)
 
-	// Fetch schedule preferences
-	schedulePrefs, err := r.db.GetSchedulePreferences(userID)
+	schedule, err := r.schedule(userID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get schedule preferences: %w", err)
+		return nil, err
+	}
+
+	customStates, err := r.db.GetCustomStates(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get custom states: %w", err)
+	}
+
+	locations, err := r.db.GetLocations(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get locations: %w", err)
 	}
 
 	var lines []csvLine
@@ -40,42 +57,31 @@ func (r *fileReporter) GenerateCSV(userID int, start, end time.Time) ([]byte, er
 		}
 
 		// Check if this is a scheduled day that's untracked
-		stateString := getState(state)
-		if state == model.StateUntracked && isScheduledDay(day, schedulePrefs) {
+		stateString := getState(state, customStates)
+		if state == model.StateUntracked && isScheduledDay(day, schedule) {
 			stateString = "Scheduled"
 		}
 
 		lines = append(lines, csvLine{
-			Date:  day.Format("2006-01-02"),
-			State: stateString,
+			Date:     day.Format("2006-01-02"),
+			State:    stateString,
+			Note:     dayState.Note,
+			Location: locationName(locations, dayState.LocationID),
+			Week:     util.ISOWeekLabel(day),
 		})
 	}
 
-	return buildCsv(lines), nil
+	return buildCsv(lines, detail), nil
 }
 
-func isScheduledDay(day time.Time, schedulePrefs model.SchedulePreferences) bool {
-	switch day.Weekday() {
-	case time.Sunday:
-		return schedulePrefs.Sunday != model.StateUntracked
-	case time.Monday:
-		return schedulePrefs.Monday != model.StateUntracked
-	case time.Tuesday:
-		return schedulePrefs.Tuesday != model.StateUntracked
-	case time.Wednesday:
-		return schedulePrefs.Wednesday != model.StateUntracked
-	case time.Thursday:
-		return schedulePrefs.Thursday != model.StateUntracked
-	case time.Friday:
-		return schedulePrefs.Friday != model.StateUntracked
-	case time.Saturday:
-		return schedulePrefs.Saturday != model.StateUntracked
-	default:
-		return false
-	}
+func isScheduledDay(day time.Time, schedule util.Schedule) bool {
+	return schedule.State(day) != model.StateUntracked
 }
 
-func getState(state model.State) string {
+func getState(state model.State, customStates model.CustomStates) string {
+	if custom, ok := customStates.Get(state); ok {
+		return custom.Name
+	}
 	switch state {
 	case model.StateWorkFromHome:
 		return "Home"
@@ -90,11 +96,24 @@ func getState(state model.State) string {
 	}
 }
 
-func buildCsv(lines []csvLine) []byte {
+// buildCsv writes the lines under a Date,State header, the export's original
+// format. The detail columns go after those so existing parsers keep working.
+func buildCsv(lines []csvLine, detail bool) []byte {
 	buf := new(bytes.Buffer)
-	buf.WriteString("Date,State\n")
+	w := csv.NewWriter(buf)
+	header := []string{"Date", "State"}
+	if detail {
+		header = append(header, "Note", "Location", "Week")
+	}
+	w.Write(header)
 	for _, line := range lines {
-		buf.WriteString(fmt.Sprintf("%s,%s\n", line.Date, line.State))
+		record := []string{line.Date, line.State}
+		if detail {
+			// Notes are free text, so the writer handles quoting.
+			record = append(record, line.Note, line.Location, line.Week)
+		}
+		w.Write(record)
 	}
+	w.Flush()
 	return buf.Bytes()
 }
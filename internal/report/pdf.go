@@ -9,14 +9,16 @@ import (
 
 	"github.com/jung-kurt/gofpdf"
 
+	"github.com/baely/officetracker/internal/util"
 	"github.com/baely/officetracker/pkg/model"
 )
 
 // MonthlySummary represents attendance summary for a month
 type MonthlySummary struct {
-	Present int
-	Total   int
-	Percent float64
+	Present   int
+	Total     int
+	Percent   float64
+	Locations []LocationCount
 }
 
 // PDF represents a PDF document with report data
@@ -24,25 +26,49 @@ type PDF struct {
 	*gofpdf.Fpdf
 	report             Report
 	monthlySummaries   map[time.Time]MonthlySummary
-	schedulePreferences model.SchedulePreferences
+	schedule           util.Schedule
+	customStates       model.CustomStates
+	locations          model.Locations
+	locationTotals     []LocationCount
+	weeks              []WeekCount
+	target             model.TargetPreferences
+	compliance         *model.Compliance
 	name               string
 	start, end         time.Time
 }
 
-// GeneratePDF creates a PDF report for the given user and time range
-func (r *fileReporter) GeneratePDF(userID int, name string, start, end time.Time) ([]byte, error) {
+// GeneratePDF creates a PDF report for the given user and time range. When
+// compliance is set the cover page reports progress against the user's target.
+func (r *fileReporter) GeneratePDF(userID int, name string, start, end time.Time, filter Filter, compliance *model.Compliance) ([]byte, error) {
 	report, err := r.Generate(userID, start, end)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate report: %w", err)
 	}
+	report = filter.apply(report)
 
-	// Fetch schedule preferences
-	schedulePrefs, err := r.db.GetSchedulePreferences(userID)
+	schedule, err := r.schedule(userID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get schedule preferences: %w", err)
+		return nil, err
 	}
 
-	p := newPDF(report, schedulePrefs, name, start, end)
+	customStates, err := r.db.GetCustomStates(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get custom states: %w", err)
+	}
+
+	locations, err := r.db.GetLocations(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get locations: %w", err)
+	}
+
+	target, err := r.db.GetTargetPreferences(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get target preferences: %w", err)
+	}
+
+	p := newPDF(report, schedule, customStates, locations, name, start, end)
+	p.target = target
+	p.compliance = compliance
 	p.addCoverPage()
 
 	var buf bytes.Buffer
@@ -58,7 +84,7 @@ func (r *fileReporter) GeneratePDF(userID int, name string, start, end time.Time
 	return buf.Bytes(), nil
 }
 
-func newPDF(report Report, schedulePrefs model.SchedulePreferences, name string, start, end time.Time) *PDF {
+func newPDF(report Report, schedule util.Schedule, customStates model.CustomStates, locations model.Locations, name string, start, end time.Time) *PDF {
 	f := gofpdf.New("P", "mm", "A4", "")
 	f.SetMargins(15, 30, 15)
 	f.AliasNbPages("{pages}")
@@ -75,7 +101,9 @@ func newPDF(report Report, schedulePrefs model.SchedulePreferences, name string,
 	p := &PDF{
 		Fpdf:               f,
 		report:             report,
-		schedulePreferences: schedulePrefs,
+		schedule:           schedule,
+		customStates:       customStates,
+		locations:          locations,
 		name:               name,
 		start:              start,
 		end:                end,
@@ -97,18 +125,30 @@ func (p *PDF) addCoverPage() {
 	}
 
 	// p.end is exclusive (first day after the period); the year of the last day
-	// is the tracking-year label.
-	trackingYear := p.end.AddDate(0, 0, -1).Year()
+	// is the tracking-year label. A single month, as emailed each month, is
+	// labelled by the month instead.
+	period := fmt.Sprintf("Tracking Year %d", p.end.AddDate(0, 0, -1).Year())
+	if p.start.Day() == 1 && p.start.AddDate(0, 1, 0).Equal(p.end) {
+		period = p.start.Format("January 2006")
+	}
 	p.SetFont("Arial", "I", 24)
-	p.Cell(40, 10, fmt.Sprintf("%sTracking Year %d", nameStr, trackingYear))
+	p.Cell(40, 10, nameStr+period)
 	p.Ln(30)
 
 	p.addSummaryTable()
+	if p.compliance != nil {
+		p.addTargetSummary()
+	}
 
 	p.SetY(-45)
 	p.SetFont("Arial", "I", 8)
 	p.Cell(0, 10, "Disclaimer: This report is based on self-reported data submitted through https://officetracker.com.au and has been automatically generated.")
 
+	if len(p.locationTotals) > 0 {
+		p.addLocationPage()
+	}
+	p.addWeekPage()
+
 	for month := range getMonths(p.start, p.end) {
 		p.addMonthPage(month)
 	}
@@ -152,6 +192,90 @@ func (p *PDF) addSummaryTable() {
 	p.CellFormat(40, 10, padString(fmt.Sprintf("%.2f%%", percent), 2, 2), "1", 0, "L", false, 0, "")
 }
 
+// addTargetSummary describes the user's target and their progress in its
+// window below the summary table.
+func (p *PDF) addTargetSummary() {
+	p.Ln(15)
+	p.SetFont("Arial", "B", 12)
+	p.Cell(40, 8, "Attendance Target")
+	p.Ln(8)
+
+	p.SetFont("Arial", "", 10)
+	p.Cell(0, 6, fmt.Sprintf("Target: %s", DescribeTarget(p.target)))
+	p.Ln(6)
+	p.Cell(0, 6, fmt.Sprintf("%s to %s: %s", p.compliance.Start, p.compliance.End, DescribeCompliance(*p.compliance)))
+}
+
+// addLocationPage breaks office days down by location, for the whole period
+// and then month by month.
+func (p *PDF) addLocationPage() {
+	p.AddPage()
+
+	p.SetFont("Arial", "B", 16)
+	p.Cell(40, 10, "Office Locations")
+	p.Ln(15)
+
+	tr := p.UnicodeTranslatorFromDescriptor("")
+	var present int
+	for _, count := range p.locationTotals {
+		present += count.Days
+	}
+
+	p.SetFont("Arial", "B", 12)
+	p.CellFormat(100, 10, padString("Location", 2, 2), "1", 0, "L", false, 0, "")
+	p.CellFormat(40, 10, padString("Days", 2, 2), "1", 0, "L", false, 0, "")
+	p.CellFormat(40, 10, padString("Share", 2, 2), "1", 0, "L", false, 0, "")
+	p.Ln(10)
+
+	p.SetFont("Arial", "", 12)
+	for _, count := range p.locationTotals {
+		p.CellFormat(100, 10, padString(tr(count.Name), 2, 2), "1", 0, "L", false, 0, "")
+		p.CellFormat(40, 10, padString(fmt.Sprintf("%d", count.Days), 2, 2), "1", 0, "L", false, 0, "")
+		p.CellFormat(40, 10, padString(fmt.Sprintf("%.2f%%", float64(count.Days)/float64(present)*100), 2, 2), "1", 0, "L", false, 0, "")
+		p.Ln(10)
+	}
+	p.Ln(10)
+
+	p.SetFont("Arial", "B", 12)
+	p.CellFormat(60, 8, padString("Month", 2, 2), "1", 0, "L", false, 0, "")
+	p.CellFormat(80, 8, padString("Location", 2, 2), "1", 0, "L", false, 0, "")
+	p.CellFormat(40, 8, padString("Days", 2, 2), "1", 0, "L", false, 0, "")
+	p.Ln(8)
+
+	p.SetFont("Arial", "", 10)
+	for month := range getMonths(p.start, p.end) {
+		for _, count := range p.monthlySummaries[month].Locations {
+			p.CellFormat(60, 6, padString(month.Format("January 2006"), 2, 2), "1", 0, "L", false, 0, "")
+			p.CellFormat(80, 6, padString(tr(count.Name), 2, 2), "1", 0, "L", false, 0, "")
+			p.CellFormat(40, 6, padString(fmt.Sprintf("%d", count.Days), 2, 2), "1", 0, "L", false, 0, "")
+			p.Ln(6)
+		}
+	}
+}
+
+// addWeekPage lists the office days in each ISO week of the period.
+func (p *PDF) addWeekPage() {
+	p.AddPage()
+
+	p.SetFont("Arial", "B", 16)
+	p.Cell(40, 10, "Weekly Office Days")
+	p.Ln(15)
+
+	p.SetFont("Arial", "B", 12)
+	p.CellFormat(60, 8, padString("Week", 2, 2), "1", 0, "L", false, 0, "")
+	p.CellFormat(80, 8, padString("Starting", 2, 2), "1", 0, "L", false, 0, "")
+	p.CellFormat(40, 8, padString("Office days", 2, 2), "1", 0, "L", false, 0, "")
+	p.Ln(8)
+
+	p.SetFont("Arial", "", 10)
+	for _, week := range p.weeks {
+		p.CellFormat(60, 6, padString(week.Week, 2, 2), "1", 0, "L", false, 0, "")
+		p.CellFormat(80, 6, padString(week.Start.Format("Monday 2 January 2006"), 2, 2), "1", 0, "L", false, 0, "")
+		p.CellFormat(40, 6, padString(fmt.Sprintf("%d", week.OfficeDays), 2, 2), "1", 0, "L", false, 0, "")
+		p.Ln(6)
+	}
+}
+
 func (p *PDF) addMonthPage(month time.Time) {
 	p.AddPage()
 
@@ -188,34 +312,59 @@ func (p *PDF) addMonthTable(month time.Time) {
 	p.Ln(15)
 
 	p.SetFont("Arial", "B", 10)
-	headers := []string{"Date", "Day of Week", "Status"}
-	for _, header := range headers {
-		p.CellFormat(40, 8, padString(header, 2, 2), "1", 0, "L", false, 0, "")
+	headers := []string{"Date", "Day of Week", "Status", "Note"}
+	for i, header := range headers {
+		p.CellFormat(monthTableWidths[i], 8, padString(header, 2, 2), "1", 0, "L", false, 0, "")
 	}
 	p.Ln(8)
 
+	// Notes are free text; the core fonts only cover cp1252.
+	tr := p.UnicodeTranslatorFromDescriptor("")
 	p.SetFont("Arial", "", 10)
 	for day := range getDays(month, month.AddDate(0, 1, 0)) {
-		status := p.report.Get(day.Month(), day.Year()).Days[day.Day()].State
-		statusStr := getStatusString(status)
-
-		p.CellFormat(40, 6, padString(day.Format("02 January"), 2, 2), "1", 0, "L", false, 0, "")
-		p.CellFormat(40, 6, padString(day.Format("Monday"), 2, 2), "1", 0, "L", false, 0, "")
-		p.CellFormat(40, 6, padString(statusStr, 2, 2), "1", 0, "L", false, 0, "")
+		dayState := p.report.Get(day.Month(), day.Year()).Days[day.Day()]
+		statusStr := tr(getStatusString(dayState.State, p.customStates))
+		note := p.fitString(tr(dayState.Note), monthTableWidths[3]-p.GetStringWidth(padString("", 2, 2)))
+
+		p.CellFormat(monthTableWidths[0], 6, padString(day.Format("02 January"), 2, 2), "1", 0, "L", false, 0, "")
+		p.CellFormat(monthTableWidths[1], 6, padString(day.Format("Monday"), 2, 2), "1", 0, "L", false, 0, "")
+		p.CellFormat(monthTableWidths[2], 6, padString(statusStr, 2, 2), "1", 0, "L", false, 0, "")
+		p.CellFormat(monthTableWidths[3], 6, padString(note, 2, 2), "1", 0, "L", false, 0, "")
 		p.Ln(6)
 	}
 }
 
+// monthTableWidths are the column widths (mm) of the monthly attendance table:
+// date, day of week, status and note.
+var monthTableWidths = []float64{35, 35, 30, 80}
+
+// fitString truncates s with an ellipsis so it fits within width at the
+// current font. s is single-byte encoded, as produced by the font's translator.
+func (p *PDF) fitString(s string, width float64) string {
+	if p.GetStringWidth(s) <= width {
+		return s
+	}
+	for len(s) > 0 && p.GetStringWidth(s+"...") > width {
+		s = s[:len(s)-1]
+	}
+	return s + "..."
+}
+
 func (p *PDF) generateSummaries() {
 	p.monthlySummaries = make(map[time.Time]MonthlySummary)
+	total := NewLocationCounter(p.customStates, p.locations)
 	for month := range getMonths(p.start, p.end) {
 		summary := MonthlySummary{}
+		locations := NewLocationCounter(p.customStates, p.locations)
 
 		for _, state := range p.report.Get(month.Month(), month.Year()).Days {
-			if state.State == model.StateWorkFromOffice {
+			locations.Add(state)
+			total.Add(state)
+			switch p.customStates.Attendance(state.State) {
+			case model.AttendancePresent:
 				summary.Present++
 				summary.Total++
-			} else if state.State == model.StateWorkFromHome {
+			case model.AttendanceAbsent:
 				summary.Total++
 			}
 		}
@@ -227,9 +376,17 @@ func (p *PDF) generateSummaries() {
 		if summary.Total > 0 {
 			summary.Percent = float64(summary.Present) / float64(summary.Total) * 100
 		}
+		summary.Locations = locations.Counts()
 
 		p.monthlySummaries[month] = summary
 	}
+	p.locationTotals = total.Counts()
+
+	weeks := NewWeekCounter(p.customStates)
+	for day := p.start; day.Before(p.end); day = day.AddDate(0, 0, 1) {
+		weeks.Add(day, p.report.Get(day.Month(), day.Year()).Days[day.Day()])
+	}
+	p.weeks = weeks.Counts()
 }
 
 func (p *PDF) countScheduledDays(year int, month time.Month) int {
@@ -239,26 +396,7 @@ func (p *PDF) countScheduledDays(year int, month time.Month) int {
 
 	for day := 1; day <= daysInMonth; day++ {
 		date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
-		dayOfWeek := date.Weekday()
-		
-		// Check if this day is scheduled
-		var isScheduled bool
-		switch dayOfWeek {
-		case time.Sunday:
-			isScheduled = p.schedulePreferences.Sunday != model.StateUntracked
-		case time.Monday:
-			isScheduled = p.schedulePreferences.Monday != model.StateUntracked
-		case time.Tuesday:
-			isScheduled = p.schedulePreferences.Tuesday != model.StateUntracked
-		case time.Wednesday:
-			isScheduled = p.schedulePreferences.Wednesday != model.StateUntracked
-		case time.Thursday:
-			isScheduled = p.schedulePreferences.Thursday != model.StateUntracked
-		case time.Friday:
-			isScheduled = p.schedulePreferences.Friday != model.StateUntracked
-		case time.Saturday:
-			isScheduled = p.schedulePreferences.Saturday != model.StateUntracked
-		}
+		isScheduled := p.schedule.State(date) != model.StateUntracked
 
 		// Check if this day is untracked and scheduled
 		if isScheduled {
@@ -272,7 +410,10 @@ func (p *PDF) countScheduledDays(year int, month time.Month) int {
 	return scheduledCount
 }
 
-func getStatusString(status model.State) string {
+func getStatusString(status model.State, customStates model.CustomStates) string {
+	if custom, ok := customStates.Get(status); ok {
+		return custom.Name
+	}
 	switch status {
 	case model.StateWorkFromHome:
 		return "Home"
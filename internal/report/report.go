@@ -2,9 +2,12 @@ package report
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 	"time"
 
 	"github.com/baely/officetracker/internal/database"
+	"github.com/baely/officetracker/internal/util"
 	"github.com/baely/officetracker/pkg/model"
 )
 
@@ -27,8 +30,40 @@ func (r Report) Get(month time.Month, year int) model.MonthState {
 
 type Reporter interface {
 	Generate(userID int, start, end time.Time) (Report, error)
-	GenerateCSV(userID int, start, end time.Time) ([]byte, error)
-	GeneratePDF(userID int, name string, start, end time.Time) ([]byte, error)
+	GenerateCSV(userID int, start, end time.Time, filter Filter, detail bool) ([]byte, error)
+	GeneratePDF(userID int, name string, start, end time.Time, filter Filter, compliance *model.Compliance) ([]byte, error)
+}
+
+// Filter narrows which entries a report includes. The zero value includes
+// everything.
+type Filter struct {
+	// Sources, when set, keeps only entries from these sources. Other entries
+	// are reported as untracked. Entries recorded before sources were have
+	// none and count as manual.
+	Sources []model.Source
+}
+
+func (f Filter) apply(report Report) Report {
+	if len(f.Sources) == 0 {
+		return report
+	}
+	filtered := Report{
+		Months: make(map[Key]model.MonthState, len(report.Months)),
+	}
+	for key, month := range report.Months {
+		days := make(map[int]model.DayState)
+		for day, state := range month.Days {
+			source := state.Source
+			if source == "" {
+				source = model.SourceManual
+			}
+			if slices.Contains(f.Sources, source) {
+				days[day] = state
+			}
+		}
+		filtered.Months[key] = model.MonthState{Days: days}
+	}
+	return filtered
 }
 
 type fileReporter struct {
@@ -41,6 +76,10 @@ func New(db database.Databaser) Reporter {
 	}
 }
 
+func (r *fileReporter) schedule(userID int) (util.Schedule, error) {
+	return database.LoadSchedule(r.db, userID)
+}
+
 func (r *fileReporter) Generate(userID int, start, end time.Time) (Report, error) {
 	report := Report{
 		Months: make(map[Key]model.MonthState),
@@ -93,3 +132,108 @@ func getDays(start, end time.Time) func(func(time.Time) bool) {
 		}
 	}
 }
+
+// LocationCount is the number of present days spent at a location.
+type LocationCount struct {
+	Name string
+	Days int
+}
+
+// NoLocation labels present days that weren't given a location.
+const NoLocation = "No location"
+
+// LocationCounter tallies present days by location.
+type LocationCounter struct {
+	customStates model.CustomStates
+	locations    model.Locations
+	days         map[int]int
+}
+
+func NewLocationCounter(customStates model.CustomStates, locations model.Locations) *LocationCounter {
+	return &LocationCounter{
+		customStates: customStates,
+		locations:    locations,
+		days:         make(map[int]int),
+	}
+}
+
+// Add counts the day if it counts as present.
+func (c *LocationCounter) Add(state model.DayState) {
+	if c.customStates.Attendance(state.State) != model.AttendancePresent {
+		return
+	}
+	if _, ok := c.locations.Get(state.LocationID); !ok {
+		state.LocationID = 0
+	}
+	c.days[state.LocationID]++
+}
+
+// Counts returns the days at each location in the user's order, followed by
+// the days with no location. It is empty unless at least one day has a
+// location, so users who don't track locations see no breakdown.
+func (c *LocationCounter) Counts() []LocationCount {
+	var counts []LocationCount
+	for _, location := range c.locations {
+		if n := c.days[location.ID]; n > 0 {
+			counts = append(counts, LocationCount{Name: location.Name, Days: n})
+		}
+	}
+	if len(counts) == 0 {
+		return nil
+	}
+	if n := c.days[0]; n > 0 {
+		counts = append(counts, LocationCount{Name: NoLocation, Days: n})
+	}
+	return counts
+}
+
+func locationName(locations model.Locations, id int) string {
+	if location, ok := locations.Get(id); ok {
+		return location.Name
+	}
+	return ""
+}
+
+// WeekCount is the number of present days in an ISO week.
+type WeekCount struct {
+	Week       string
+	Start      time.Time
+	OfficeDays int
+}
+
+// WeekCounter tallies present days by ISO week.
+type WeekCounter struct {
+	customStates model.CustomStates
+	weeks        map[string]*WeekCount
+}
+
+func NewWeekCounter(customStates model.CustomStates) *WeekCounter {
+	return &WeekCounter{
+		customStates: customStates,
+		weeks:        make(map[string]*WeekCount),
+	}
+}
+
+// Add records the day's week, counting the day if it counts as present. Every
+// week a day is added for is listed, so weeks with no office days show as 0.
+func (c *WeekCounter) Add(day time.Time, state model.DayState) {
+	label := util.ISOWeekLabel(day)
+	count, ok := c.weeks[label]
+	if !ok {
+		start := day.AddDate(0, 0, 1-util.ISOWeekday(day))
+		count = &WeekCount{Week: label, Start: time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, day.Location())}
+		c.weeks[label] = count
+	}
+	if c.customStates.Attendance(state.State) == model.AttendancePresent {
+		count.OfficeDays++
+	}
+}
+
+// Counts returns the weeks in order.
+func (c *WeekCounter) Counts() []WeekCount {
+	counts := make([]WeekCount, 0, len(c.weeks))
+	for _, label := range slices.Sorted(maps.Keys(c.weeks)) {
+		counts = append(counts, *c.weeks[label])
+	}
+	return counts
+}
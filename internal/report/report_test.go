@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/baely/officetracker/internal/database/dbtest"
+	"github.com/baely/officetracker/internal/util"
 	"github.com/baely/officetracker/pkg/model"
 )
 
@@ -87,22 +88,25 @@ func TestGetState(t *testing.T) {
 		{model.StateOther, ""},
 		{model.StateUntracked, ""},
 		{model.StateScheduledWorkFromHome, ""}, // scheduled states are not mapped
+		{model.CustomStateBase, "Client site"},
+		{model.CustomStateBase + 1, ""}, // unknown custom states are not mapped
 	}
+	customs := model.CustomStates{{ID: model.CustomStateBase, Name: "Client site"}}
 	for _, c := range cases {
-		if got := getState(c.in); got != c.want {
+		if got := getState(c.in, customs); got != c.want {
 			t.Errorf("getState(%d) = %q, want %q", c.in, got, c.want)
 		}
 	}
 }
 
 func TestGetStatusString(t *testing.T) {
-	if getStatusString(model.StateWorkFromHome) != "Home" {
+	if getStatusString(model.StateWorkFromHome, nil) != "Home" {
 		t.Error("WFH should map to Home")
 	}
-	if getStatusString(model.StateWorkFromOffice) != "Office" {
+	if getStatusString(model.StateWorkFromOffice, nil) != "Office" {
 		t.Error("office should map to Office")
 	}
-	if getStatusString(model.StateOther) != "" || getStatusString(model.StateUntracked) != "" {
+	if getStatusString(model.StateOther, nil) != "" || getStatusString(model.StateUntracked, nil) != "" {
 		t.Error("other/untracked should map to empty")
 	}
 }
@@ -112,20 +116,40 @@ func TestIsScheduledDay(t *testing.T) {
 		Monday:  model.StateWorkFromOffice,
 		Tuesday: model.StateUntracked,
 	}
-	if !isScheduledDay(date(2024, 1, 1), prefs) { // Monday
+	schedule := util.NewSchedule(prefs, nil, nil)
+	if !isScheduledDay(date(2024, 1, 1), schedule) { // Monday
 		t.Error("Monday with office schedule should be scheduled")
 	}
-	if isScheduledDay(date(2024, 1, 2), prefs) { // Tuesday untracked
+	if isScheduledDay(date(2024, 1, 2), schedule) { // Tuesday untracked
 		t.Error("Tuesday untracked should not be scheduled")
 	}
+
+	// A rule schedules the first Tuesday of the month.
+	schedule = util.NewSchedule(prefs, nil, model.ScheduleRules{
+		{State: model.StateWorkFromOffice, RRule: "FREQ=MONTHLY;BYDAY=1TU", Start: "2024-01-01"},
+	})
+	if !isScheduledDay(date(2024, 1, 2), schedule) || isScheduledDay(date(2024, 1, 9), schedule) {
+		t.Error("only the first Tuesday should be scheduled by the rule")
+	}
 }
 
 func TestBuildCsv(t *testing.T) {
-	got := buildCsv([]csvLine{
-		{Date: "2024-01-01", State: "Office"},
+	lines := []csvLine{
+		{Date: "2024-01-01", State: "Office", Week: "2024-W01"},
 		{Date: "2024-01-02", State: ""},
-	})
-	want := "Date,State\n2024-01-01,Office\n2024-01-02,\n"
+		{Date: "2024-01-03", State: "Office", Note: "client site, level 2", Location: "Sydney", Week: "2024-W01"},
+	}
+	want := "Date,State,Note,Location,Week\n2024-01-01,Office,,,2024-W01\n2024-01-02,,,,\n2024-01-03,Office,\"client site, level 2\",Sydney,2024-W01\n"
+	if got := buildCsv(lines, true); string(got) != want {
+		t.Fatalf("buildCsv = %q, want %q", got, want)
+	}
+}
+
+// Without detail the export keeps its original two columns, so existing
+// parsers still read it.
+func TestBuildCsvHeader(t *testing.T) {
+	got := buildCsv([]csvLine{{Date: "2024-01-03", State: "Office", Note: "client site", Location: "Sydney", Week: "2024-W01"}}, false)
+	want := "Date,State\n2024-01-03,Office\n"
 	if string(got) != want {
 		t.Fatalf("buildCsv = %q, want %q", got, want)
 	}
@@ -153,7 +177,7 @@ func TestGenerateSummaries(t *testing.T) {
 		}},
 	}}
 	// No schedule, so no phantom scheduled days.
-	p := newPDF(report, model.SchedulePreferences{}, "", date(2024, 1, 1), date(2024, 2, 1))
+	p := newPDF(report, util.Schedule{}, nil, nil, "", date(2024, 1, 1), date(2024, 2, 1))
 
 	if len(p.monthlySummaries) != 1 {
 		t.Fatalf("expected 1 monthly summary, got %d", len(p.monthlySummaries))
@@ -172,6 +196,57 @@ func TestGenerateSummaries(t *testing.T) {
 	}
 }
 
+// Custom states count towards the summary according to their attendance.
+func TestGenerateSummariesCustomStates(t *testing.T) {
+	customs := model.CustomStates{
+		{ID: 100, Name: "Client site", Attendance: model.AttendancePresent},
+		{ID: 101, Name: "Travel", Attendance: model.AttendanceAbsent},
+		{ID: 102, Name: "Leave", Attendance: model.AttendanceExcluded},
+	}
+	report := Report{Months: map[Key]model.MonthState{
+		{Month: time.January, Year: 2024}: {Days: map[int]model.DayState{
+			2: {State: 100},
+			3: {State: 101},
+			4: {State: 102},
+			5: {State: 103}, // unknown custom state
+		}},
+	}}
+	p := newPDF(report, util.Schedule{}, customs, nil, "", date(2024, 1, 1), date(2024, 2, 1))
+
+	s := p.monthlySummaries[date(2024, 1, 1)]
+	if s.Present != 1 || s.Total != 2 {
+		t.Errorf("summary = %+v, want 1 present of 2", s)
+	}
+}
+
+// Weekly counts cover every ISO week the period touches, including weeks
+// that straddle a month boundary.
+func TestGenerateSummariesWeeks(t *testing.T) {
+	report := Report{Months: map[Key]model.MonthState{
+		{Month: time.January, Year: 2024}: {Days: map[int]model.DayState{
+			2:  {State: model.StateWorkFromOffice},
+			3:  {State: model.StateWorkFromOffice},
+			4:  {State: model.StateWorkFromHome},
+			31: {State: model.StateWorkFromOffice},
+		}},
+		{Month: time.February, Year: 2024}: {Days: map[int]model.DayState{
+			1: {State: model.StateWorkFromOffice},
+		}},
+	}}
+	p := newPDF(report, util.Schedule{}, nil, nil, "", date(2024, 1, 1), date(2024, 2, 5))
+
+	if len(p.weeks) != 5 {
+		t.Fatalf("weeks = %+v, want 5", p.weeks)
+	}
+	first, last := p.weeks[0], p.weeks[4]
+	if first.Week != "2024-W01" || !first.Start.Equal(date(2024, 1, 1)) || first.OfficeDays != 2 {
+		t.Errorf("first week = %+v, want 2024-W01 from 1 January with 2 office days", first)
+	}
+	if last.Week != "2024-W05" || last.OfficeDays != 2 {
+		t.Errorf("last week = %+v, want 2024-W05 with 2 office days", last)
+	}
+}
+
 // countScheduledDays counts weekdays whose schedule is set and whose actual
 // state is missing or untracked. Days already recorded as office are excluded
 // (they are counted as present elsewhere and must not be double-counted).
@@ -184,14 +259,14 @@ func TestCountScheduledDays(t *testing.T) {
 		}},
 	}}
 	prefs := model.SchedulePreferences{Monday: model.StateWorkFromOffice}
-	p := newPDF(report, prefs, "", date(2024, 1, 1), date(2024, 2, 1))
+	p := newPDF(report, util.NewSchedule(prefs, nil, nil), nil, nil, "", date(2024, 1, 1), date(2024, 2, 1))
 
 	if got := p.countScheduledDays(2024, time.January); got != 4 {
 		t.Errorf("countScheduledDays = %d, want 4 (5 Mondays minus the 1 recorded office day)", got)
 	}
 
 	// With no schedule at all, nothing is counted.
-	p2 := newPDF(Report{Months: map[Key]model.MonthState{}}, model.SchedulePreferences{}, "", date(2024, 1, 1), date(2024, 2, 1))
+	p2 := newPDF(Report{Months: map[Key]model.MonthState{}}, util.Schedule{}, nil, nil, "", date(2024, 1, 1), date(2024, 2, 1))
 	if got := p2.countScheduledDays(2024, time.January); got != 0 {
 		t.Errorf("countScheduledDays with no schedule = %d, want 0", got)
 	}
@@ -200,20 +275,20 @@ func TestCountScheduledDays(t *testing.T) {
 // GenerateCSV produces a header plus one line per weekday with the mapped state.
 func TestGenerateCSVIntegration(t *testing.T) {
 	db := dbtest.New()
-	db.SaveDay(1, 2, 1, 2024, model.DayState{State: model.StateWorkFromOffice}) // Tue
-	db.SaveDay(1, 3, 1, 2024, model.DayState{State: model.StateWorkFromHome})   // Wed
+	db.SaveDay(1, 2, 1, 2024, model.DayState{State: model.StateWorkFromOffice})             // Tue
+	db.SaveDay(1, 3, 1, 2024, model.DayState{State: model.StateWorkFromHome, Note: "sick"}) // Wed
 	r := New(db)
 
-	out, err := r.GenerateCSV(1, date(2024, 1, 1), date(2024, 1, 8))
+	out, err := r.GenerateCSV(1, date(2024, 1, 1), date(2024, 1, 8), Filter{}, true)
 	if err != nil {
 		t.Fatalf("GenerateCSV: %v", err)
 	}
-	want := "Date,State\n" +
-		"2024-01-01,\n" + // Mon, no data
-		"2024-01-02,Office\n" +
-		"2024-01-03,Home\n" +
-		"2024-01-04,\n" +
-		"2024-01-05,\n"
+	want := "Date,State,Note,Location,Week\n" +
+		"2024-01-01,,,,2024-W01\n" + // Mon, no data
+		"2024-01-02,Office,,,2024-W01\n" +
+		"2024-01-03,Home,sick,,2024-W01\n" +
+		"2024-01-04,,,,2024-W01\n" +
+		"2024-01-05,,,,2024-W01\n"
 	if string(out) != want {
 		t.Fatalf("GenerateCSV =\n%q\nwant\n%q", out, want)
 	}
@@ -225,21 +300,90 @@ func TestGenerateCSVScheduledDay(t *testing.T) {
 	db.SaveSchedulePreferences(1, model.SchedulePreferences{Thursday: model.StateWorkFromOffice})
 	r := New(db)
 
-	out, err := r.GenerateCSV(1, date(2024, 1, 4), date(2024, 1, 5)) // Thursday only
+	out, err := r.GenerateCSV(1, date(2024, 1, 4), date(2024, 1, 5), Filter{}, true) // Thursday only
 	if err != nil {
 		t.Fatalf("GenerateCSV: %v", err)
 	}
-	want := "Date,State\n2024-01-04,Scheduled\n"
+	want := "Date,State,Note,Location,Week\n2024-01-04,Scheduled,,,2024-W01\n"
 	if string(out) != want {
 		t.Fatalf("scheduled-day CSV = %q, want %q", out, want)
 	}
 }
 
+// Schedule rules add scheduled days on top of the weekly schedule.
+func TestGenerateCSVScheduleRule(t *testing.T) {
+	db := dbtest.New()
+	db.SaveScheduleRule(1, model.ScheduleRule{State: model.StateWorkFromOffice, RRule: "FREQ=WEEKLY;INTERVAL=2;BYDAY=WE", Start: "2024-01-01"})
+	r := New(db)
+
+	out, err := r.GenerateCSV(1, date(2024, 1, 3), date(2024, 1, 11), Filter{}, true)
+	if err != nil {
+		t.Fatalf("GenerateCSV: %v", err)
+	}
+	want := "Date,State,Note,Location,Week\n2024-01-03,Scheduled,,,2024-W01\n2024-01-04,,,,2024-W01\n2024-01-05,,,,2024-W01\n2024-01-08,,,,2024-W02\n2024-01-09,,,,2024-W02\n2024-01-10,,,,2024-W02\n"
+	if string(out) != want {
+		t.Fatalf("rule CSV = %q, want %q", out, want)
+	}
+}
+
+// Each day is labelled by the schedule in force on it.
+func TestGenerateCSVScheduleHistory(t *testing.T) {
+	db := dbtest.New()
+	db.SaveScheduleVersions(1, model.SchedulePreferences{Wednesday: model.StateWorkFromOffice},
+		model.ScheduleVersion{Schedule: model.SchedulePreferences{Tuesday: model.StateWorkFromOffice}},
+		model.ScheduleVersion{EffectiveFrom: "2024-01-08", Schedule: model.SchedulePreferences{Wednesday: model.StateWorkFromOffice}})
+	r := New(db)
+
+	out, err := r.GenerateCSV(1, date(2024, 1, 2), date(2024, 1, 11), Filter{}, true)
+	if err != nil {
+		t.Fatalf("GenerateCSV: %v", err)
+	}
+	want := "Date,State,Note,Location,Week\n2024-01-02,Scheduled,,,2024-W01\n2024-01-03,,,,2024-W01\n2024-01-04,,,,2024-W01\n2024-01-05,,,,2024-W01\n" +
+		"2024-01-08,,,,2024-W02\n2024-01-09,,,,2024-W02\n2024-01-10,Scheduled,,,2024-W02\n"
+	if string(out) != want {
+		t.Fatalf("history CSV = %q, want %q", out, want)
+	}
+}
+
+// A source filter reports only matching entries; the rest read as untracked.
+func TestGenerateCSVSourceFilter(t *testing.T) {
+	db := dbtest.New()
+	db.SaveDay(1, 2, 1, 2024, model.DayState{State: model.StateWorkFromOffice, Source: model.SourceGeofence})
+	db.SaveDay(1, 3, 1, 2024, model.DayState{State: model.StateWorkFromOffice, Source: model.SourceManual})
+	r := New(db)
+
+	out, err := r.GenerateCSV(1, date(2024, 1, 2), date(2024, 1, 4), Filter{Sources: []model.Source{model.SourceGeofence}}, true)
+	if err != nil {
+		t.Fatalf("GenerateCSV: %v", err)
+	}
+	want := "Date,State,Note,Location,Week\n2024-01-02,Office,,,2024-W01\n2024-01-03,,,,2024-W01\n"
+	if string(out) != want {
+		t.Fatalf("filtered CSV = %q, want %q", out, want)
+	}
+}
+
+// Entries from before sources were recorded have none and count as manual.
+func TestGenerateCSVSourceFilterLegacy(t *testing.T) {
+	db := dbtest.New()
+	db.SaveDay(1, 2, 1, 2024, model.DayState{State: model.StateWorkFromOffice})
+	db.SaveDay(1, 3, 1, 2024, model.DayState{State: model.StateWorkFromOffice, Source: model.SourceGeofence})
+	r := New(db)
+
+	out, err := r.GenerateCSV(1, date(2024, 1, 2), date(2024, 1, 4), Filter{Sources: []model.Source{model.SourceManual}}, false)
+	if err != nil {
+		t.Fatalf("GenerateCSV: %v", err)
+	}
+	want := "Date,State\n2024-01-02,Office\n2024-01-03,\n"
+	if string(out) != want {
+		t.Fatalf("filtered CSV = %q, want %q", out, want)
+	}
+}
+
 func TestGenerateCSVScheduleError(t *testing.T) {
 	db := dbtest.New()
 	db.Errs = map[string]error{"GetSchedulePreferences": errFake}
 	r := New(db)
-	if _, err := r.GenerateCSV(1, date(2024, 1, 1), date(2024, 1, 8)); err == nil {
+	if _, err := r.GenerateCSV(1, date(2024, 1, 1), date(2024, 1, 8), Filter{}, true); err == nil {
 		t.Fatal("expected GenerateCSV to propagate schedule-preferences error")
 	}
 }
@@ -253,7 +397,7 @@ func TestGeneratePDFIntegration(t *testing.T) {
 	}})
 	r := New(db)
 
-	out, err := r.GeneratePDF(1, "Alice", date(2024, 1, 1), date(2024, 2, 1))
+	out, err := r.GeneratePDF(1, "Alice", date(2024, 1, 1), date(2024, 2, 1), Filter{}, nil)
 	if err != nil {
 		t.Fatalf("GeneratePDF: %v", err)
 	}
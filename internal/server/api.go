@@ -8,7 +8,9 @@ import (
 	"log/slog"
 	"net/http"
 	"reflect"
+	"runtime"
 	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/gorilla/schema"
@@ -27,10 +29,13 @@ func apiRouter(service *v1.Service) func(chi.Router) {
 		r.Route("/developer", developerRouter(service))
 		r.Route("/report", reportRouter(service))
 		r.Route("/health", healthRouter(service))
+		r.With(AllowedAuthMethods(auth.MethodSSO, auth.MethodSecret, auth.MethodExcluded)).Method(http.MethodPost, "/checkin", wrap(service.CheckIn))
 		// Public, unauthenticated stats endpoint. Returns aggregate-only data.
 		r.Method(http.MethodGet, "/stats", wrap(service.GetStats))
+		// Public OpenAPI document describing the routes registered here.
+		r.Method(http.MethodGet, "/openapi.json", openAPIHandler(r))
 		r.NotFound(func(w http.ResponseWriter, r *http.Request) {
-			writeError(w, "not found", http.StatusNotFound)
+			writeError(w, model.ErrorCodeNotFound, "not found")
 		})
 	}
 }
@@ -38,6 +43,14 @@ func apiRouter(service *v1.Service) func(chi.Router) {
 func stateRouter(service *v1.Service) func(chi.Router) {
 	middlewares := chi.Middlewares{AllowedAuthMethods(auth.MethodSSO, auth.MethodSecret, auth.MethodExcluded)}
 	return func(r chi.Router) {
+		r.With(middlewares...).Method(http.MethodGet, "/", wrap(service.GetStateRange))
+		r.With(middlewares...).Method(http.MethodPatch, "/", wrap(service.PatchState))
+		r.With(middlewares...).Method(http.MethodGet, "/unconfirmed", wrap(service.GetUnconfirmedDays))
+		r.With(middlewares...).Method(http.MethodPost, "/confirm", wrap(service.ConfirmDays))
+		r.With(middlewares...).Method(http.MethodGet, "/week/{iso_year:[0-9]{4}}-W{week:[0-9]{1,2}}", wrap(service.GetWeek))
+		r.With(middlewares...).Method(http.MethodPut, "/week/{iso_year:[0-9]{4}}-W{week:[0-9]{1,2}}", wrap(service.PutWeek))
+		r.With(middlewares...).Method(http.MethodGet, "/{year}/{month}/{day}/history", wrap(service.GetDayHistory))
+		r.With(middlewares...).Method(http.MethodPut, "/{year}/{month}/{day}/location", wrap(service.PutDayLocation))
 		r.With(middlewares...).Method(http.MethodGet, "/{year}/{month}/{day}", wrap(service.GetDay))
 		r.With(middlewares...).Method(http.MethodPut, "/{year}/{month}/{day}", wrap(service.PutDay))
 		r.With(middlewares...).Method(http.MethodGet, "/{year}/{month}", wrap(service.GetMonth))
@@ -49,6 +62,8 @@ func stateRouter(service *v1.Service) func(chi.Router) {
 func noteRouter(service *v1.Service) func(chi.Router) {
 	middlewares := chi.Middlewares{AllowedAuthMethods(auth.MethodSSO, auth.MethodSecret, auth.MethodExcluded)}
 	return func(r chi.Router) {
+		r.With(middlewares...).Method(http.MethodGet, "/{year}/{month}/{day}", wrap(service.GetDayNote))
+		r.With(middlewares...).Method(http.MethodPut, "/{year}/{month}/{day}", wrap(service.PutDayNote))
 		r.With(middlewares...).Method(http.MethodGet, "/{year}/{month}", wrap(service.GetNote))
 		r.With(middlewares...).Method(http.MethodPut, "/{year}/{month}", wrap(service.PutNote))
 		r.With(middlewares...).Method(http.MethodGet, "/{year}", wrap(service.GetNotes))
@@ -61,8 +76,36 @@ func settingsRouter(service *v1.Service) func(router chi.Router) {
 		r.With(middlewares...).Method(http.MethodGet, "/", wrap(service.GetSettings))
 		r.With(middlewares...).Method(http.MethodPut, "/theme", wrap(service.UpdateThemePreferences))
 		r.With(middlewares...).Method(http.MethodPut, "/schedule", wrap(service.UpdateSchedulePreferences))
+		r.With(middlewares...).Method(http.MethodGet, "/schedule/history", wrap(service.GetScheduleHistory))
+		r.With(middlewares...).Method(http.MethodGet, "/schedule/rules", wrap(service.ListScheduleRules))
+		r.With(middlewares...).Method(http.MethodPost, "/schedule/rules", wrap(service.CreateScheduleRule))
+		r.With(middlewares...).Method(http.MethodPut, "/schedule/rules/{rule_id:[0-9]+}", wrap(service.UpdateScheduleRule))
+		r.With(middlewares...).Method(http.MethodDelete, "/schedule/rules/{rule_id:[0-9]+}", wrap(service.DeleteScheduleRule))
+		r.With(middlewares...).Method(http.MethodPut, "/materialise", wrap(service.UpdateMaterialisePreferences))
+		r.With(middlewares...).Method(http.MethodPut, "/notifications", wrap(service.UpdateNotificationPreferences))
+		r.With(middlewares...).Method(http.MethodGet, "/push/subscriptions", wrap(service.ListPushSubscriptions))
+		r.With(middlewares...).Method(http.MethodPost, "/push/subscriptions", wrap(service.CreatePushSubscription))
+		r.With(middlewares...).Method(http.MethodDelete, "/push/subscriptions/{subscription_id}", wrap(service.DeletePushSubscription))
+		r.With(middlewares...).Method(http.MethodGet, "/push/tokens", wrap(service.ListPushTokens))
+		// Devices are tied to the API token the mobile app signed in with.
+		r.With(AllowedAuthMethods(auth.MethodSecret, auth.MethodExcluded)).Method(http.MethodPost, "/push/tokens", wrap(service.CreatePushToken))
+		r.With(middlewares...).Method(http.MethodDelete, "/push/tokens/{push_token_id}", wrap(service.DeletePushToken))
 		r.With(middlewares...).Method(http.MethodPut, "/calendar", wrap(service.UpdateCalendarPreferences))
 		r.With(middlewares...).Method(http.MethodPut, "/target", wrap(service.UpdateTargetPreferences))
+		r.With(middlewares...).Method(http.MethodGet, "/target/compliance", wrap(service.GetCompliance))
+		r.With(middlewares...).Method(http.MethodGet, "/target/forecast", wrap(service.GetForecast))
+		r.With(middlewares...).Method(http.MethodGet, "/states", wrap(service.ListCustomStates))
+		r.With(middlewares...).Method(http.MethodPost, "/states", wrap(service.CreateCustomState))
+		r.With(middlewares...).Method(http.MethodPut, "/states/{state_id:[0-9]+}", wrap(service.UpdateCustomState))
+		r.With(middlewares...).Method(http.MethodDelete, "/states/{state_id:[0-9]+}", wrap(service.DeleteCustomState))
+		r.With(middlewares...).Method(http.MethodGet, "/locations", wrap(service.ListLocations))
+		r.With(middlewares...).Method(http.MethodPost, "/locations", wrap(service.CreateLocation))
+		r.With(middlewares...).Method(http.MethodPut, "/locations/{location_id:[0-9]+}", wrap(service.UpdateLocation))
+		r.With(middlewares...).Method(http.MethodDelete, "/locations/{location_id:[0-9]+}", wrap(service.DeleteLocation))
+		r.With(middlewares...).Method(http.MethodGet, "/networks", wrap(service.ListNetworkRules))
+		r.With(middlewares...).Method(http.MethodPost, "/networks", wrap(service.CreateNetworkRule))
+		r.With(middlewares...).Method(http.MethodPut, "/networks/{network_rule_id:[0-9]+}", wrap(service.UpdateNetworkRule))
+		r.With(middlewares...).Method(http.MethodDelete, "/networks/{network_rule_id:[0-9]+}", wrap(service.DeleteNetworkRule))
 	}
 }
 
@@ -72,6 +115,20 @@ func developerRouter(service *v1.Service) func(chi.Router) {
 		r.With(middlewares...).Method(http.MethodPost, "/secret", wrap(service.PostSecret))
 		r.With(middlewares...).Method(http.MethodGet, "/tokens", wrap(service.ListTokens))
 		r.With(middlewares...).Method(http.MethodDelete, "/tokens/{token_id}", wrap(service.RevokeToken))
+		r.Route("/webhooks", webhookRouter(service))
+	}
+}
+
+// webhookRouter allows standalone requests too, unlike the rest of the
+// developer routes, as webhooks don't depend on API tokens.
+func webhookRouter(service *v1.Service) func(chi.Router) {
+	middlewares := chi.Middlewares{AllowedAuthMethods(auth.MethodSSO, auth.MethodSecret, auth.MethodExcluded)}
+	return func(r chi.Router) {
+		r.With(middlewares...).Method(http.MethodGet, "/", wrap(service.ListWebhooks))
+		r.With(middlewares...).Method(http.MethodPost, "/", wrap(service.CreateWebhook))
+		r.With(middlewares...).Method(http.MethodPut, "/{webhook_id:[0-9]+}", wrap(service.UpdateWebhook))
+		r.With(middlewares...).Method(http.MethodDelete, "/{webhook_id:[0-9]+}", wrap(service.DeleteWebhook))
+		r.With(middlewares...).Method(http.MethodGet, "/{webhook_id:[0-9]+}/deliveries", wrap(service.ListWebhookDeliveries))
 	}
 }
 
@@ -90,7 +147,25 @@ func healthRouter(service *v1.Service) func(chi.Router) {
 	}
 }
 
-func wrapRaw[T any](fn func(T) (model.Response, error)) http.HandlerFunc {
+// apiHandler is an API route's handler. It keeps the types the handler maps
+// so the OpenAPI document can describe them.
+type apiHandler struct {
+	http.HandlerFunc
+	operationID string
+	request     reflect.Type
+	// response is nil for handlers that write their own body.
+	response reflect.Type
+}
+
+func wrapRaw[T any](fn func(T) (model.Response, error)) apiHandler {
+	return apiHandler{
+		HandlerFunc: rawHandler(fn),
+		operationID: funcName(fn),
+		request:     reflect.TypeFor[T](),
+	}
+}
+
+func rawHandler[T any](fn func(T) (model.Response, error)) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		req, err := mapRequest[T](r)
 		if err != nil {
@@ -98,18 +173,27 @@ func wrapRaw[T any](fn func(T) (model.Response, error)) http.HandlerFunc {
 			slog.Error(logErr.Error())
 			// Check if this is an authentication error
 			if errors.Is(err, ErrNoUserInCtx) {
-				writeError(w, "Unauthorized", http.StatusUnauthorized)
+				writeError(w, model.ErrorCodeUnauthorized, "Unauthorized")
 			} else {
-				writeError(w, "Bad request", http.StatusBadRequest)
+				writeError(w, model.ErrorCodeBadRequest, "Bad request")
 			}
 			return
 		}
 
 		resp, err := fn(req)
-		if err != nil {
+		var verr *v1.ValidationError
+		var apiErr *v1.Error
+		switch {
+		case errors.As(err, &verr):
+			writeError(w, model.ErrorCodeValidation, "Invalid request", verr.Errors...)
+			return
+		case errors.As(err, &apiErr):
+			writeError(w, apiErr.Code, apiErr.Message)
+			return
+		case err != nil:
 			err = fmt.Errorf("failed to execute request: %w", err)
 			slog.Error(err.Error())
-			writeError(w, internalErrorMsg, http.StatusInternalServerError)
+			writeError(w, model.ErrorCodeInternal, internalErrorMsg)
 			return
 		}
 
@@ -123,8 +207,8 @@ func wrapRaw[T any](fn func(T) (model.Response, error)) http.HandlerFunc {
 	}
 }
 
-func wrap[T, U any](fn func(T) (U, error)) http.HandlerFunc {
-	return wrapRaw(func(req T) (model.Response, error) {
+func wrap[T, U any](fn func(T) (U, error)) apiHandler {
+	h := wrapRaw(func(req T) (model.Response, error) {
 		resp, err := fn(req)
 		if err != nil {
 			err = fmt.Errorf("failed to execute request: %w", err)
@@ -142,12 +226,27 @@ func wrap[T, U any](fn func(T) (U, error)) http.HandlerFunc {
 			Data:        body,
 		}, nil
 	})
+	h.operationID = funcName(fn)
+	h.response = reflect.TypeFor[U]()
+	return h
+}
+
+// funcName returns the name of a function or method value, e.g. GetDay for
+// service.GetDay.
+func funcName(fn any) string {
+	name := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+	name = strings.TrimSuffix(name, "-fm")
+	return name[strings.LastIndex(name, ".")+1:]
 }
 
-func writeError(w http.ResponseWriter, msg string, code int) {
+// writeError writes an error body with the HTTP status for its code.
+func writeError(w http.ResponseWriter, code model.ErrorCode, msg string, fieldErrs ...model.FieldError) {
+	status := errorStatus(code)
 	errMsg := model.Error{
-		Code:    code,
-		Message: msg,
+		Code:      status,
+		ErrorCode: code,
+		Message:   msg,
+		Errors:    fieldErrs,
 	}
 	b, err := json.Marshal(errMsg)
 	if err != nil {
@@ -156,7 +255,7 @@ func writeError(w http.ResponseWriter, msg string, code int) {
 		return
 	}
 	w.Header().Add("Content-Type", "application/json")
-	http.Error(w, string(b), code)
+	http.Error(w, string(b), status)
 }
 
 func mapRequest[T any](r *http.Request) (T, error) {
@@ -179,6 +278,11 @@ func mapRequest[T any](r *http.Request) (T, error) {
 		return *new(T), err
 	}
 
+	if err = populateRequestMeta(&req, r); err != nil {
+		err = fmt.Errorf("failed to populate auth metadata: %w", err)
+		return *new(T), err
+	}
+
 	if err = populateUrlParams(&req, r); err != nil {
 		err = fmt.Errorf("failed to populate URL params: %w", err)
 		return *new(T), err
@@ -244,6 +348,51 @@ func populateUserID[T any](req *T, r *http.Request) error {
 	return nil
 }
 
+// requestMetaTags are meta fields describing how the request was made. They
+// are populated from the request and its context rather than from URL params.
+var requestMetaTags = map[string]bool{
+	"auth_method":   true,
+	"auth_token_id": true,
+	"client_ip":     true,
+}
+
+// populateRequestMeta fills the auth_method and auth_token_id meta fields, used
+// to attribute writes in the attendance history, and client_ip. Unlike user_id
+// they are optional: a request without them in context is left untouched.
+func populateRequestMeta[T any](req *T, r *http.Request) error {
+	v := reflect.ValueOf(req).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("meta")
+		if tag == "meta" {
+			meta := v.Field(i)
+			metaType := meta.Type()
+			for j := 0; j < metaType.NumField(); j++ {
+				metaField := metaType.Field(j)
+				if !meta.Field(j).CanSet() {
+					continue
+				}
+				switch metaField.Tag.Get("meta") {
+				case "auth_method":
+					if method, err := getAuthMethod(r); err == nil && meta.Field(j).Kind() == reflect.String {
+						meta.Field(j).SetString(method.String())
+					}
+				case "auth_token_id":
+					if tokenID, ok := context.GetCtxValue(r).Get(context.CtxTokenIDKey).(int); ok && meta.Field(j).Kind() == reflect.Int {
+						meta.Field(j).SetInt(int64(tokenID))
+					}
+				case "client_ip":
+					if meta.Field(j).Kind() == reflect.String {
+						meta.Field(j).SetString(networkIP(r))
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
 func populateUrlParams[T any](req *T, r *http.Request) error {
 	ctx := chi.RouteContext(r.Context())
 	v := reflect.ValueOf(req).Elem()
@@ -257,7 +406,7 @@ func populateUrlParams[T any](req *T, r *http.Request) error {
 			for j := 0; j < metaType.NumField(); j++ {
 				metaField := metaType.Field(j)
 				metaFieldTag := metaField.Tag.Get("meta")
-				if metaFieldTag != "user_id" {
+				if metaFieldTag != "user_id" && !requestMetaTags[metaFieldTag] {
 					if meta.Field(j).CanSet() {
 						value := ctx.URLParam(metaFieldTag)
 						switch meta.Field(j).Kind() {
@@ -278,14 +427,34 @@ func populateUrlParams[T any](req *T, r *http.Request) error {
 	return nil
 }
 
+// populateQueryParams decodes the query string into the request. Meta fields
+// only ever come from the URL path and the request context: the decoder would
+// otherwise set them from keys like Meta.AuthTokenID, so they are put back
+// once it is done.
 func populateQueryParams[T any](req *T, r *http.Request) error {
 	u := r.URL
 	v := u.Query()
 	d := schema.NewDecoder()
 	d.IgnoreUnknownKeys(true)
+
+	rv := reflect.ValueOf(req).Elem()
+	t := rv.Type()
+	metas := make(map[int]reflect.Value)
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("meta") == "meta" {
+			meta := reflect.New(t.Field(i).Type).Elem()
+			meta.Set(rv.Field(i))
+			metas[i] = meta
+		}
+	}
+
 	if err := d.Decode(req, v); err != nil {
 		err = fmt.Errorf("failed to decode query params: %w", err)
 		return err
 	}
+
+	for i, meta := range metas {
+		rv.Field(i).Set(meta)
+	}
 	return nil
 }
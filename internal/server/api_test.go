@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
@@ -17,7 +18,7 @@ import (
 
 func TestWriteError(t *testing.T) {
 	w := httptest.NewRecorder()
-	writeError(w, "bad request", 400)
+	writeError(w, model.ErrorCodeBadRequest, "bad request")
 
 	res := w.Result()
 	if res.StatusCode != 400 {
@@ -28,8 +29,8 @@ func TestWriteError(t *testing.T) {
 	if err := json.NewDecoder(res.Body).Decode(&e); err != nil {
 		t.Fatalf("decode error body: %v", err)
 	}
-	if e.Code != 400 || e.Message != "bad request" {
-		t.Errorf("error body = %+v, want {400, bad request}", e)
+	if e.Code != 400 || e.ErrorCode != model.ErrorCodeBadRequest || e.Message != "bad request" {
+		t.Errorf("error body = %+v, want {400, bad_request, bad request}", e)
 	}
 	// Note: writeError sets Content-Type application/json, but the following
 	// http.Error call resets it to text/plain. Lock in the actual served value.
@@ -38,6 +39,27 @@ func TestWriteError(t *testing.T) {
 	}
 }
 
+// Every error code has its own status, and unknown codes fall back to 500.
+func TestErrorStatus(t *testing.T) {
+	cases := map[model.ErrorCode]int{
+		model.ErrorCodeBadRequest:     http.StatusBadRequest,
+		model.ErrorCodeValidation:     http.StatusBadRequest,
+		model.ErrorCodeUnauthorized:   http.StatusUnauthorized,
+		model.ErrorCodeForbidden:      http.StatusForbidden,
+		model.ErrorCodeNotFound:       http.StatusNotFound,
+		model.ErrorCodeConflict:       http.StatusConflict,
+		model.ErrorCodeRateLimited:    http.StatusTooManyRequests,
+		model.ErrorCodeInternal:       http.StatusInternalServerError,
+		model.ErrorCodeNotImplemented: http.StatusNotImplemented,
+		"something_new":               http.StatusInternalServerError,
+	}
+	for code, want := range cases {
+		if got := errorStatus(code); got != want {
+			t.Errorf("errorStatus(%s) = %d, want %d", code, got, want)
+		}
+	}
+}
+
 func TestMapResponse(t *testing.T) {
 	b, err := mapResponse(model.HealthCheckResponse{Status: "ok"})
 	if err != nil {
@@ -150,3 +172,34 @@ func TestMapRequestQueryParams(t *testing.T) {
 		t.Errorf("Year = %d, want 2024", req.Meta.Year)
 	}
 }
+
+// Meta fields can't be set from the query string or the body, so a client
+// can't pose as another user or forge how a write is attributed.
+func TestMapRequestIgnoresClientMeta(t *testing.T) {
+	body := `{"Meta":{"UserID":9,"AuthTokenID":9,"Via":"mcp"},"data":{"state":2}}`
+	r := httptest.NewRequest("PUT", "/state/2024/3/5?Meta.UserID=9&meta.authtokenid=9&Meta.Via=mcp&Meta.Year=1999", strings.NewReader(body))
+
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("year", "2024")
+	rctx.URLParams.Add("month", "3")
+	rctx.URLParams.Add("day", "5")
+
+	val := context2.CtxValue{}
+	val.Set(context2.CtxUserIDKey, 77)
+
+	ctx := context.WithValue(r.Context(), chi.RouteCtxKey, rctx)
+	ctx = context.WithValue(ctx, context2.CtxKey, val)
+	r = r.WithContext(ctx)
+
+	req, err := mapRequest[model.PutDayRequest](r)
+	if err != nil {
+		t.Fatalf("mapRequest: %v", err)
+	}
+	want := model.PutDayRequestMeta{UserID: 77, Year: 2024, Month: 3, Day: 5}
+	if req.Meta != want {
+		t.Errorf("Meta = %+v, want %+v", req.Meta, want)
+	}
+	if req.Data.State != model.StateWorkFromOffice {
+		t.Errorf("body state = %d, want office", req.Data.State)
+	}
+}
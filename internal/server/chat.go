@@ -0,0 +1,204 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/baely/officetracker/internal/chat"
+	"github.com/baely/officetracker/internal/config"
+	v1 "github.com/baely/officetracker/internal/implementation/v1"
+	"github.com/baely/officetracker/internal/util"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+// maxChatBody caps slash command bodies, which are read whole to check their
+// signature.
+const maxChatBody = 64 << 10
+
+// chatLinkPath is where chat users link their account, relative to the site
+// root.
+const chatLinkPath = "integrations/chat/link"
+
+// chatUnavailableMsg replies to a command that failed on our side. Chat
+// platforms show non-2xx responses as a generic failure, so it's sent as a
+// normal reply.
+const chatUnavailableMsg = "Officetracker couldn't handle that just now. Please try again later."
+
+// chatConfig returns the chat settings for either app type.
+func chatConfig(cfg config.AppConfigurer) config.Chat {
+	switch cfg := cfg.(type) {
+	case config.IntegratedApp:
+		return cfg.Chat
+	case config.StandaloneApp:
+		return cfg.Chat
+	}
+	return config.Chat{}
+}
+
+// smtpConfig returns the mail server settings for either app type.
+func smtpConfig(cfg config.AppConfigurer) config.SMTP {
+	switch cfg := cfg.(type) {
+	case config.IntegratedApp:
+		return cfg.SMTP
+	case config.StandaloneApp:
+		return cfg.SMTP
+	}
+	return config.SMTP{}
+}
+
+// webPushConfig returns the push notification keys for either app type.
+func webPushConfig(cfg config.AppConfigurer) config.WebPush {
+	switch cfg := cfg.(type) {
+	case config.IntegratedApp:
+		return cfg.WebPush
+	case config.StandaloneApp:
+		return cfg.WebPush
+	}
+	return config.WebPush{}
+}
+
+// expoConfig returns the mobile push settings for either app type.
+func expoConfig(cfg config.AppConfigurer) config.Expo {
+	switch cfg := cfg.(type) {
+	case config.IntegratedApp:
+		return cfg.Expo
+	case config.StandaloneApp:
+		return cfg.Expo
+	}
+	return config.Expo{}
+}
+
+// chatRouter serves the slash command endpoints of each chat platform with a
+// secret configured. Requests are authenticated by their signature rather than
+// a session.
+func (s *Server) chatRouter(cfg config.Chat) func(chi.Router) {
+	return func(r chi.Router) {
+		if cfg.SlackSigningSecret != "" {
+			r.Post("/integrations/slack/commands", s.chatHandler(func(r *http.Request, body []byte) (chat.Command, error) {
+				return chat.ParseSlack(cfg.SlackSigningSecret, r.Header, body, time.Now())
+			}, chat.SlackReply))
+		}
+		if cfg.TeamsSecret != "" {
+			r.Post("/integrations/teams/messages", s.chatHandler(func(r *http.Request, body []byte) (chat.Command, error) {
+				return chat.ParseTeams(cfg.TeamsSecret, r.Header, body)
+			}, chat.TeamsReply))
+		}
+	}
+}
+
+// chatHandler verifies a slash command with parse, runs it and writes the
+// reply in the platform's format.
+func (s *Server) chatHandler(parse func(*http.Request, []byte) (chat.Command, error), reply func(string) []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxChatBody))
+		if err != nil {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		cmd, err := parse(r, body)
+		if errors.Is(err, chat.ErrInvalidSignature) {
+			slog.Warn(fmt.Sprintf("rejected chat command: %v", err))
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		resp, err := s.v1.ChatCommand(model.ChatCommandRequest{
+			Meta: model.ChatCommandRequestMeta{
+				Account: cmd.Account,
+				LinkURL: s.chatLinkURL(r),
+			},
+			Text: cmd.Text,
+		})
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to run chat command: %v", err), "platform", cmd.Account.Platform)
+			resp.Text = chatUnavailableMsg
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(reply(resp.Text))
+	}
+}
+
+// chatLinkURL is the link page's address. Standalone servers have no
+// configured domain, so it's taken from the host the platform called.
+func (s *Server) chatLinkURL(r *http.Request) string {
+	if cfg, ok := s.cfg.(config.IntegratedApp); ok {
+		return util.BaseUri(cfg) + chatLinkPath
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s/%s", scheme, r.Host, chatLinkPath)
+}
+
+// handleChatLink asks the signed-in user to confirm linking the chat account
+// a code is for.
+func (s *Server) handleChatLink(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if errors.Is(err, ErrNoUserInCtx) || userID == 0 {
+		slog.Info("no user id in context, redirecting to login")
+		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	code := r.URL.Query().Get("code")
+	resp, err := s.v1.GetChatLink(model.GetChatLinkRequest{
+		Meta: model.GetChatLinkRequestMeta{Code: code},
+	})
+	if err != nil {
+		chatLinkError(w, r, err)
+		return
+	}
+	serveChatLink(w, r, chatLinkPage{Account: resp.Data, Code: code})
+}
+
+// handleChatLinkConfirm links the chat account to the signed-in user.
+func (s *Server) handleChatLinkConfirm(w http.ResponseWriter, r *http.Request) {
+	userID, err := getUserID(r)
+	if errors.Is(err, ErrNoUserInCtx) || userID == 0 {
+		slog.Info("no user id in context, redirecting to login")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	// Linking from another site would let someone else act as this user in
+	// chat, so only accept the form from our own page.
+	if origin := r.Header.Get("Origin"); origin != "" && !sameHost(origin, r.Host) {
+		errorPage(w, r, nil, "This link must be confirmed from Officetracker.", http.StatusForbidden)
+		return
+	}
+
+	resp, err := s.v1.LinkChatAccount(model.LinkChatAccountRequest{
+		Meta: model.LinkChatAccountRequestMeta{UserID: userID, Code: r.FormValue("code")},
+	})
+	if err != nil {
+		chatLinkError(w, r, err)
+		return
+	}
+	serveChatLink(w, r, chatLinkPage{Account: resp.Data, Linked: true})
+}
+
+func chatLinkError(w http.ResponseWriter, r *http.Request, err error) {
+	var apiErr *v1.Error
+	if errors.As(err, &apiErr) && apiErr.Code == model.ErrorCodeNotFound {
+		errorPage(w, r, nil, "This link has expired or was already used. Run the command in chat again for a new one.", http.StatusNotFound)
+		return
+	}
+	errorPage(w, r, fmt.Errorf("failed to link chat account: %w", err), internalErrorMsg, http.StatusInternalServerError)
+}
+
+// sameHost reports whether origin, an Origin header, names host.
+func sameHost(origin, host string) bool {
+	_, rest, ok := strings.Cut(origin, "://")
+	return ok && rest == host
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/baely/officetracker/internal/chat"
+	"github.com/baely/officetracker/internal/config"
+	"github.com/baely/officetracker/internal/database/dbtest"
+	"github.com/baely/officetracker/internal/report"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+const (
+	testSlackSecret = "slack-signing-secret"
+	testTeamsSecret = "dGVhbXMtc2VjdXJpdHktdG9rZW4="
+)
+
+func newChatServer(t *testing.T) (http.Handler, *dbtest.Fake) {
+	t.Helper()
+	db := dbtest.New()
+	cfg := config.StandaloneApp{Chat: config.Chat{SlackSigningSecret: testSlackSecret, TeamsSecret: testTeamsSecret}}
+	srv, err := NewServer(cfg, db, nil, report.New(db))
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	return srv.Handler, db
+}
+
+// slackCommand sends text as Slack would for user U1 and returns the reply
+// text.
+func slackCommand(t *testing.T, h http.Handler, secret, text string) (int, string) {
+	t.Helper()
+	body := url.Values{"team_id": {"T1"}, "user_id": {"U1"}, "user_name": {"alex"}, "command": {"/ot"}, "text": {text}}.Encode()
+	r := httptest.NewRequest(http.MethodPost, "/integrations/slack/commands", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	now := time.Now()
+	r.Header.Set(chat.SlackTimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	r.Header.Set(chat.SlackSignatureHeader, chat.SignSlack(secret, now, []byte(body)))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	var reply struct {
+		ResponseType string `json:"response_type"`
+		Text         string `json:"text"`
+	}
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &reply); err != nil || reply.ResponseType != "ephemeral" {
+			t.Fatalf("slack reply %s: %v", w.Body, err)
+		}
+	}
+	return w.Code, reply.Text
+}
+
+// teamsMessage sends text as a Teams outgoing webhook would and returns the
+// reply text.
+func teamsMessage(t *testing.T, h http.Handler, text string) string {
+	t.Helper()
+	body, _ := json.Marshal(map[string]any{
+		"type":        "message",
+		"text":        "<at>Officetracker</at> " + text,
+		"from":        map[string]string{"id": "29:1", "name": "Alex", "aadObjectId": "aad-1"},
+		"channelData": map[string]any{"tenant": map[string]string{"id": "tenant-1"}},
+	})
+	sig, err := chat.SignTeams(testTeamsSecret, body)
+	if err != nil {
+		t.Fatalf("SignTeams: %v", err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/integrations/teams/messages", strings.NewReader(string(body)))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set(chat.TeamsAuthorizationHeader, sig)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("teams status = %d: %s", w.Code, w.Body)
+	}
+
+	var reply struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &reply); err != nil || reply.Type != "message" {
+		t.Fatalf("teams reply %s: %v", w.Body, err)
+	}
+	return reply.Text
+}
+
+// linkChat follows the link in reply through the confirm page.
+func linkChat(t *testing.T, h http.Handler, reply, platform string) {
+	t.Helper()
+	_, link, ok := strings.Cut(reply, "http://example.com/integrations/chat/link?code=")
+	if !ok {
+		t.Fatalf("reply %q has no link", reply)
+	}
+	code, _, _ := strings.Cut(link, "\n")
+
+	res := do(t, h, http.MethodGet, "/integrations/chat/link?code="+url.QueryEscape(code), "")
+	if body := bodyString(t, res); res.StatusCode != http.StatusOK || !strings.Contains(body, "Link the "+platform+" account") {
+		t.Fatalf("link page = %d %s", res.StatusCode, body)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/integrations/chat/link", strings.NewReader(url.Values{"code": {code}}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("Origin", "http://example.com")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "is now linked") {
+		t.Fatalf("confirm = %d %s", w.Code, w.Body)
+	}
+
+	if res := do(t, h, http.MethodGet, "/integrations/chat/link?code="+url.QueryEscape(code), ""); res.StatusCode != http.StatusNotFound {
+		t.Errorf("reused link status = %d, want 404", res.StatusCode)
+	}
+}
+
+func TestSlackCommands(t *testing.T) {
+	h, db := newChatServer(t)
+
+	status, reply := slackCommand(t, h, testSlackSecret, "in 2024-05-07")
+	if status != http.StatusOK {
+		t.Fatalf("status = %d", status)
+	}
+	linkChat(t, h, reply, "Slack")
+
+	_, reply = slackCommand(t, h, testSlackSecret, "in 2024-05-07")
+	if !strings.Contains(reply, "Recorded Tue 7 May as Office.") || !strings.Contains(reply, "Week 2024-W19: 1 in office") {
+		t.Errorf("reply = %q", reply)
+	}
+	if day, _ := db.GetDay(1, 7, 5, 2024); day.State != model.StateWorkFromOffice || day.Source != model.SourceChat {
+		t.Errorf("day = %+v, want an office day from chat", day)
+	}
+
+	if status, _ := slackCommand(t, h, "wrong-secret", "home 2024-05-07"); status != http.StatusUnauthorized {
+		t.Errorf("forged command status = %d, want 401", status)
+	}
+	if day, _ := db.GetDay(1, 7, 5, 2024); day.State != model.StateWorkFromOffice {
+		t.Errorf("forged command changed the day to %+v", day)
+	}
+}
+
+func TestTeamsMessages(t *testing.T) {
+	h, db := newChatServer(t)
+
+	linkChat(t, h, teamsMessage(t, h, "home 2024-05-08"), "Microsoft Teams")
+
+	reply := teamsMessage(t, h, "home 2024-05-08")
+	// Teams gets paragraphs, as it drops single newlines.
+	if !strings.Contains(reply, "Recorded Wed 8 May as Home.\n\nWeek 2024-W19: 0 in office") {
+		t.Errorf("reply = %q", reply)
+	}
+	if day, _ := db.GetDay(1, 8, 5, 2024); day.State != model.StateWorkFromHome {
+		t.Errorf("day = %+v, want a home day", day)
+	}
+	history, _ := db.GetDayHistory(1, 8, 5, 2024)
+	if len(history) != 1 || history[0].AuthMethod != "teams" || history[0].Via != "chat" {
+		t.Errorf("history = %+v, want one change by teams via chat", history)
+	}
+}
+
+// A platform without a secret isn't served at all.
+func TestChatDisabledWithoutSecret(t *testing.T) {
+	h, _ := newStandaloneServer(t)
+	if status, _ := slackCommand(t, h, "", "in"); status != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", status)
+	}
+}
+
+func TestChatLinkRejectsOtherOrigins(t *testing.T) {
+	h, db := newChatServer(t)
+	_, reply := slackCommand(t, h, testSlackSecret, "in")
+	_, link, _ := strings.Cut(reply, "?code=")
+	code, _, _ := strings.Cut(link, "\n")
+
+	r := httptest.NewRequest(http.MethodPost, "/integrations/chat/link", strings.NewReader(url.Values{"code": {code}}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("Origin", "https://evil.example")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want 403", w.Code)
+	}
+	if len(db.ChatUsers) != 0 {
+		t.Errorf("linked %v from another origin", db.ChatUsers)
+	}
+}
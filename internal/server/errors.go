@@ -1,6 +1,11 @@
 package server
 
-import "fmt"
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/baely/officetracker/pkg/model"
+)
 
 const (
 	internalErrorMsg = "Internal server error"
@@ -9,3 +14,25 @@ const (
 var (
 	ErrNoUserInCtx = fmt.Errorf("user ID not in context")
 )
+
+// errorStatuses maps each error code to the HTTP status it's served with.
+var errorStatuses = map[model.ErrorCode]int{
+	model.ErrorCodeBadRequest:     http.StatusBadRequest,
+	model.ErrorCodeValidation:     http.StatusBadRequest,
+	model.ErrorCodeUnauthorized:   http.StatusUnauthorized,
+	model.ErrorCodeForbidden:      http.StatusForbidden,
+	model.ErrorCodeNotFound:       http.StatusNotFound,
+	model.ErrorCodeConflict:       http.StatusConflict,
+	model.ErrorCodeRateLimited:    http.StatusTooManyRequests,
+	model.ErrorCodeInternal:       http.StatusInternalServerError,
+	model.ErrorCodeNotImplemented: http.StatusNotImplemented,
+}
+
+// errorStatus returns the HTTP status for code. Unknown codes are treated as
+// internal errors.
+func errorStatus(code model.ErrorCode) int {
+	if status, ok := errorStatuses[code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/baely/officetracker/internal/config"
 	context2 "github.com/baely/officetracker/internal/context"
 	"github.com/baely/officetracker/internal/database"
+	"github.com/baely/officetracker/pkg/model"
 )
 
 func AllowedAuthMethods(authMethods ...auth.Method) func(http.Handler) http.Handler {
@@ -20,12 +21,19 @@ func AllowedAuthMethods(authMethods ...auth.Method) func(http.Handler) http.Hand
 			authMethod, err := getAuthMethod(r)
 			if err != nil {
 				err = fmt.Errorf("failed to get auth method: %w", err)
-				writeError(w, internalErrorMsg, http.StatusInternalServerError)
+				writeError(w, model.ErrorCodeInternal, internalErrorMsg)
 				return
 			}
 
 			if !slices.Contains(authMethods, authMethod) {
-				writeError(w, "unauthorized", http.StatusUnauthorized)
+				// A signed-in caller using a method this route doesn't take,
+				// such as an API token on an SSO-only route, is forbidden
+				// rather than unauthenticated.
+				if userID, err := getUserID(r); err != nil || userID == 0 {
+					writeError(w, model.ErrorCodeUnauthorized, "unauthorized")
+				} else {
+					writeError(w, model.ErrorCodeForbidden, "this endpoint can't be used with this sign-in method")
+				}
 				return
 			}
 
@@ -78,6 +86,11 @@ func injectAuth(db database.Databaser, cfger config.AppConfigurer) func(http.Han
 					// Don't set userID in context when auth fails
 				} else {
 					val.Set(context2.CtxUserIDKey, userID)
+					if authMethod == auth.MethodSecret {
+						if tokenID, err := db.GetTokenIDBySecret(token); err == nil {
+							val.Set(context2.CtxTokenIDKey, tokenID)
+						}
+					}
 					if authMethod == auth.MethodSSO {
 						auth.MigrateLegacyCookie(cfg, w, r, userID)
 					}
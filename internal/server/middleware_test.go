@@ -19,8 +19,9 @@ func requestWithAuthMethod(m auth.Method) *http.Request {
 }
 
 // AllowedAuthMethods lets a request through only when its auth method is in the
-// allow-list; otherwise it short-circuits with 401 (or 500 if the method is
-// missing from context entirely).
+// allow-list; otherwise it short-circuits with 401 when no user is signed in,
+// 403 when one is (or 500 if the method is missing from context
+// entirely).
 func TestAllowedAuthMethods(t *testing.T) {
 	nextCalled := false
 	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -49,6 +50,20 @@ func TestAllowedAuthMethods(t *testing.T) {
 		}
 	})
 
+	t.Run("signed in with another method is 403", func(t *testing.T) {
+		nextCalled = false
+		w := httptest.NewRecorder()
+		r := requestWithAuthMethod(auth.MethodSecret)
+		context2.GetCtxValue(r).Set(context2.CtxUserIDKey, 7)
+		AllowedAuthMethods(auth.MethodSSO)(next).ServeHTTP(w, r)
+		if nextCalled {
+			t.Error("next should not be called for a disallowed method")
+		}
+		if w.Code != http.StatusForbidden {
+			t.Errorf("code = %d, want 403", w.Code)
+		}
+	})
+
 	t.Run("missing auth method is 500", func(t *testing.T) {
 		nextCalled = false
 		w := httptest.NewRecorder()
@@ -0,0 +1,481 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/baely/officetracker/internal/openapi"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+// apiDoc is the prose for an API route in the OpenAPI document. Parameters
+// and schemas come from the types its handler maps.
+type apiDoc struct {
+	summary     string
+	description string
+	// contentType is the media type of a response that isn't JSON.
+	contentType string
+	// tag overrides grouping by the first path segment.
+	tag    string
+	public bool
+	// operationID and response describe handlers not built with wrap.
+	operationID string
+	response    reflect.Type
+}
+
+// apiDocs documents every route under /api/v1, keyed by method and OpenAPI
+// path. Routes missing from here are left out of the document and fail
+// TestOpenAPICoversRoutes.
+var apiDocs = map[string]apiDoc{
+	"GET /state": {
+		summary:     "List entries in a date range",
+		description: "List the entries recorded from one date to another, both inclusive, in date order. Scheduled days aren't filled in. A range covers at most 366 days.",
+	},
+	"PATCH /state": {
+		summary:     "Update many days",
+		description: "Write a list of dated days, fill a range with one state, or both, across any number of months. Each day is written as PUT /state/{year}/{month}/{day} would write it. All days are checked first and saved in one transaction, so an impossible date, a date given twice or an unknown state leaves nothing written. At most 366 days can be updated at once.",
+	},
+	"GET /state/unconfirmed": {
+		summary:     "List unconfirmed days",
+		description: "List the days filled in from the schedule that still need confirming, from the week up to and including date.",
+	},
+	"POST /state/confirm": {
+		summary:     "Confirm days",
+		description: "Confirm days filled in from the schedule, keeping their state.",
+	},
+	"GET /state/week/{iso_year}-W{week}": {
+		summary:     "Get week state",
+		description: "Retrieve attendance state for an ISO week. Untracked days the schedule covers show as scheduled, as in the year view.",
+	},
+	"PUT /state/week/{iso_year}-W{week}": {
+		summary:     "Update week state",
+		description: "Update days of an ISO week, keyed by ISO weekday. Days left out are unchanged. The week is rejected as a whole if any day is invalid.",
+	},
+	"GET /state/{year}": {
+		summary:     "Get year state",
+		description: "Retrieve attendance state for an entire year.",
+	},
+	"GET /state/{year}/{month}": {
+		summary:     "Get month state",
+		description: "Retrieve attendance state for a specific month.",
+	},
+	"PUT /state/{year}/{month}": {
+		summary:     "Update month state",
+		description: "Update attendance state for an entire month.",
+	},
+	"GET /state/{year}/{month}/{day}": {
+		summary:     "Get day state",
+		description: "Retrieve attendance state for a specific day.",
+	},
+	"PUT /state/{year}/{month}/{day}": {
+		summary:     "Update day state",
+		description: "Update attendance state for a specific day.",
+	},
+	"POST /checkin": {
+		summary:     "Check in",
+		description: "Record today as in the office if the coordinates given fall within one of the user's locations or, failing that, the Wi-Fi network given or the address the request comes from matches one of their network rules. A day the user has already entered is never overwritten; untracked days and unconfirmed days from the schedule are. The response says what was recorded and, if nothing was, why.",
+		tag:         "State",
+	},
+	"GET /state/{year}/{month}/{day}/history": {
+		summary:     "Get day history",
+		description: "List the changes made to a day, oldest first, with how each write was authenticated.",
+	},
+	"PUT /state/{year}/{month}/{day}/location": {
+		summary:     "Update location for a day",
+		description: "Set or clear (with location_id 0) the office location of a day without changing its state. The day must count as in office to set a location.",
+	},
+	"GET /note/{year}": {
+		summary:     "Get all notes for a year",
+		description: "Retrieve all monthly notes for a specific year.",
+	},
+	"GET /note/{year}/{month}": {
+		summary:     "Get note for a month",
+		description: "Retrieve the note for a specific month.",
+	},
+	"PUT /note/{year}/{month}": {
+		summary:     "Update note for a month",
+		description: "Update or create the note for a specific month.",
+	},
+	"GET /note/{year}/{month}/{day}": {
+		summary:     "Get note for a day",
+		description: "Retrieve the note left on a specific day.",
+	},
+	"PUT /note/{year}/{month}/{day}": {
+		summary:     "Update note for a day",
+		description: "Set or clear the note on a specific day without changing its state.",
+	},
+	"GET /settings": {
+		summary:     "Get user settings",
+		description: "Retrieve the user's settings, including linked accounts and preferences.",
+	},
+	"PUT /settings/theme": {
+		summary:     "Update theme preferences",
+		description: "Update the user's theme preferences.",
+	},
+	"PUT /settings/schedule": {
+		summary:     "Update the weekly schedule",
+		description: "Save a new version of the weekly schedule. Days before its effective date keep the schedule that was in force at the time.",
+	},
+	"GET /settings/schedule/history": {
+		summary:     "Get schedule history",
+		description: "List the versions of the user's weekly schedule, oldest first.",
+	},
+	"GET /settings/schedule/rules": {
+		summary:     "List schedule rules",
+		description: "List the user's recurring schedule rules in the order they apply.",
+	},
+	"POST /settings/schedule/rules": {
+		summary:     "Create a schedule rule",
+		description: "Add a recurring schedule rule. It applies after existing rules.",
+	},
+	"PUT /settings/schedule/rules/{rule_id}": {
+		summary:     "Update a schedule rule",
+		description: "Change a rule's state, recurrence or dates.",
+	},
+	"DELETE /settings/schedule/rules/{rule_id}": {
+		summary: "Delete a schedule rule",
+	},
+	"PUT /settings/materialise": {
+		summary:     "Update scheduled day fill-in",
+		description: "Choose whether days with no entry are filled in from the schedule each evening.",
+	},
+	"PUT /settings/notifications": {
+		summary:     "Update notifications",
+		description: "Choose the emails to send and the address to send them to: an evening reminder when a weekday is untracked, a Monday digest of the previous week and a monthly summary with the report attached. An address is required when any email is on. Push notifications can also remind you in the evening, and warn you once your attendance target is out of reach, on each subscribed browser. Nothing is sent unless the server has a mail server or push keys configured.",
+	},
+	"GET /settings/push/subscriptions": {
+		summary:     "List push subscriptions",
+		description: "List the browsers subscribed to push notifications, without their keys.",
+	},
+	"POST /settings/push/subscriptions": {
+		summary:     "Subscribe a browser to push notifications",
+		description: "Save the subscription a browser's PushManager returned, subscribed with the server's VAPID public key. Subscribing the same endpoint again replaces it. Subscriptions are removed once they expire or the push service drops them.",
+	},
+	"DELETE /settings/push/subscriptions/{subscription_id}": {
+		summary:     "Unsubscribe a browser",
+		description: "Stop sending push notifications to a browser.",
+	},
+	"GET /settings/push/tokens": {
+		summary:     "List mobile devices",
+		description: "List the mobile app's devices registered for push notifications.",
+	},
+	"POST /settings/push/tokens": {
+		summary:     "Register a mobile device",
+		description: "Register the mobile app's Expo push token for the evening reminder and geofence check-in confirmations. Requires an API token, and the device is removed when that token is revoked. Registering the same token again replaces it. Devices Expo reports as no longer registered are removed.",
+	},
+	"DELETE /settings/push/tokens/{push_token_id}": {
+		summary:     "Remove a mobile device",
+		description: "Stop sending push notifications to a mobile device.",
+	},
+	"PUT /settings/calendar": {
+		summary:     "Update calendar preferences",
+		description: "Set the tracking year start month and timezone. Unknown timezones are rejected.",
+	},
+	"PUT /settings/target": {
+		summary:     "Update attendance target",
+		description: "Set the attendance target. Unknown policies or windows are rejected; amounts are clamped to their range.",
+	},
+	"GET /settings/target/compliance": {
+		summary:     "Get target compliance",
+		description: "Measure attendance against the target over the window containing the given date.",
+	},
+	"GET /settings/target/forecast": {
+		summary:     "Forecast target",
+		description: "Project the target window to its end from entries so far, the schedule and the work days left.",
+	},
+	"GET /settings/states": {
+		summary:     "List custom states",
+		description: "List the user's custom states, including archived ones.",
+	},
+	"POST /settings/states": {
+		summary:     "Create a custom state",
+		description: "Add a custom state. The server assigns its id.",
+	},
+	"PUT /settings/states/{state_id}": {
+		summary:     "Update a custom state",
+		description: "Change a custom state's name, colour or attendance.",
+	},
+	"DELETE /settings/states/{state_id}": {
+		summary:     "Archive a custom state",
+		description: "Archive a custom state. Days already set to it keep it, but it can no longer be chosen for new entries.",
+	},
+	"GET /settings/locations": {
+		summary:     "List locations",
+		description: "List the user's office locations, including archived ones.",
+	},
+	"POST /settings/locations": {
+		summary:     "Create a location",
+		description: "Add an office location. The server assigns its id.",
+	},
+	"PUT /settings/locations/{location_id}": {
+		summary:     "Update a location",
+		description: "Change a location's name, coordinates or radius.",
+	},
+	"DELETE /settings/locations/{location_id}": {
+		summary:     "Archive a location",
+		description: "Archive a location. Days already at it keep it, but it can no longer be chosen for new entries.",
+	},
+	"GET /settings/networks": {
+		summary:     "List network rules",
+		description: "List the networks the user's check-ins count as in the office from.",
+	},
+	"POST /settings/networks": {
+		summary:     "Create a network rule",
+		description: "Add an office Wi-Fi network, IP range or VPN hostname, optionally tied to one of the user's locations. The server assigns its id.",
+	},
+	"PUT /settings/networks/{network_rule_id}": {
+		summary:     "Update a network rule",
+		description: "Change a network rule's kind, value or location.",
+	},
+	"DELETE /settings/networks/{network_rule_id}": {
+		summary:     "Delete a network rule",
+		description: "Delete a network rule. Days already recorded from it are kept.",
+	},
+	"POST /developer/secret": {
+		summary:     "Create an API token",
+		description: "Create a named API token. The secret is only returned here, so store it straight away.",
+	},
+	"GET /developer/tokens": {
+		summary:     "List API tokens",
+		description: "List the user's active API tokens, without their secrets.",
+	},
+	"DELETE /developer/tokens/{token_id}": {
+		summary:     "Revoke an API token",
+		description: "Revoke an API token. Requests using it are rejected from then on.",
+	},
+	"GET /developer/webhooks": {
+		summary:     "List webhooks",
+		description: "List the user's webhooks, without their secrets.",
+	},
+	"POST /developer/webhooks": {
+		summary:     "Create a webhook",
+		description: "Register a URL to be sent the chosen events. The signing secret is only returned here, so store it straight away.",
+	},
+	"PUT /developer/webhooks/{webhook_id}": {
+		summary:     "Update a webhook",
+		description: "Change a webhook's URL or events, or disable it. The signing secret stays the same.",
+	},
+	"DELETE /developer/webhooks/{webhook_id}": {
+		summary:     "Delete a webhook",
+		description: "Delete a webhook along with its queued deliveries and delivery log.",
+	},
+	"GET /developer/webhooks/{webhook_id}/deliveries": {
+		summary:     "List webhook deliveries",
+		description: "The webhook's latest 50 deliveries, newest first, with the outcome of the last attempt at each.",
+	},
+	"GET /report/pdf/{year}-attendance": {
+		summary:     "Download PDF attendance report",
+		description: "Generate a PDF report of attendance for the tracking year.",
+		contentType: "application/pdf",
+	},
+	"GET /report/csv/{year}-attendance": {
+		summary:     "Download CSV attendance report",
+		description: "Generate a CSV report of attendance for the tracking year, with columns Date and State, and with detail=true also Note, Location and Week.",
+		contentType: "text/csv",
+	},
+	"GET /health/check": {
+		summary:     "Health check",
+		description: "Check that the API is up.",
+		public:      true,
+	},
+	"GET /health/auth": {
+		summary:     "Validate authentication",
+		description: "Check that the API token on the request is valid.",
+	},
+	"GET /stats": {
+		summary:     "Get usage stats",
+		description: "Aggregate usage across all users. Nothing about individual users is returned.",
+		public:      true,
+	},
+	"GET /openapi.json": {
+		summary:     "Get the API description",
+		description: "This OpenAPI document.",
+		tag:         "Developer",
+		public:      true,
+		operationID: "GetOpenAPI",
+	},
+	"GET /account/link": {
+		summary:     "Get an account link URL",
+		description: "Start linking another social login to the account. The URL expires after 10 minutes.",
+		operationID: "GetAccountLinkURL",
+		response: reflect.TypeFor[struct {
+			URL string `json:"url"`
+		}](),
+	},
+	"POST /auth/logout": {
+		summary:     "Log out",
+		description: "Revoke the API token the request was made with. Does nothing for browser sessions.",
+		operationID: "Logout",
+	},
+}
+
+// apiTypes describes model types whose meaning isn't clear from their kind.
+var apiTypes = map[reflect.Type]openapi.TypeDoc{
+	reflect.TypeFor[model.State](): {
+		Description: "Attendance state: 0 untracked, 1 work from home, 2 work from office, 3 other. Days the schedule covers but that haven't been entered read as 4, 5 and 6, the scheduled versions of 1 to 3. 100 and above are the user's custom states.",
+	},
+	reflect.TypeFor[model.Source](): {
+		Description: "Where an entry came from. Writes default to manual.",
+		Enum:        enumOf(model.Sources),
+	},
+	reflect.TypeFor[model.CheckInReason](): {
+		Description: "Why a check-in didn't record the day: it matched none of the user's locations or network rules, or the user had already entered the day.",
+		Enum:        enumOf([]model.CheckInReason{model.CheckInNotAtOffice, model.CheckInAlreadySet}),
+	},
+	reflect.TypeFor[model.NetworkRuleKind](): {
+		Description: "What a network rule matches: the Wi-Fi network name a client reports, an IP range the request comes from, or a hostname whose addresses the request comes from.",
+		Enum:        enumOf(model.NetworkRuleKinds),
+	},
+	reflect.TypeFor[model.Attendance](): {
+		Description: "How a custom state counts towards attendance.",
+		Enum:        enumOf([]model.Attendance{model.AttendancePresent, model.AttendanceAbsent, model.AttendanceExcluded}),
+	},
+	reflect.TypeFor[model.MaterialiseMode](): {
+		Description: "What happens to scheduled days that haven't been entered by the evening: nothing (empty), saved as an entry, or saved as an entry to confirm.",
+		Enum:        enumOf([]model.MaterialiseMode{model.MaterialiseOff, model.MaterialiseEntry, model.MaterialiseUnconfirmed}),
+	},
+	reflect.TypeFor[model.TargetPolicy](): {
+		Description: "How the attendance target is measured. Empty means percent.",
+		Enum:        enumOf([]model.TargetPolicy{"", model.TargetPolicyPercent, model.TargetPolicyDaysPerWeek, model.TargetPolicyDaysPerMonth}),
+	},
+	reflect.TypeFor[model.TargetWindow](): {
+		Description: "The period the attendance target is measured over. Empty means month.",
+		Enum:        enumOf([]model.TargetWindow{"", model.TargetWindowMonth, model.TargetWindowRollingWeeks, model.TargetWindowQuarter, model.TargetWindowTrackingYear}),
+	},
+	reflect.TypeFor[model.WebhookEvent](): {
+		Description: "An event a webhook can be sent. target.missed fires when a change makes the attendance target unreachable.",
+		Enum:        enumOf(model.WebhookEvents),
+	},
+	reflect.TypeFor[model.WebhookDeliveryStatus](): {
+		Description: "Whether a delivery is still being tried, was accepted with a 2xx response, or ran out of retries.",
+		Enum:        enumOf([]model.WebhookDeliveryStatus{model.WebhookDeliveryPending, model.WebhookDeliveryDelivered, model.WebhookDeliveryFailed}),
+	},
+	reflect.TypeFor[model.ErrorCode](): {
+		Description: "What went wrong, in a form that won't change.",
+		Enum:        errorCodes(),
+	},
+}
+
+func enumOf[T any](values []T) []any {
+	enum := make([]any, len(values))
+	for i, v := range values {
+		enum[i] = v
+	}
+	return enum
+}
+
+func errorCodes() []any {
+	var codes []model.ErrorCode
+	for code := range errorStatuses {
+		codes = append(codes, code)
+	}
+	slices.Sort(codes)
+	return enumOf(codes)
+}
+
+var apiDocGenerator = openapi.Generator{
+	Info: openapi.Info{
+		Title:       "OfficeTracker API",
+		Description: "API for tracking office attendance and managing user settings. Create an API token on the settings page and send it as a bearer token.",
+		Version:     "1.0.0",
+	},
+	Servers:  []openapi.Server{{URL: "/api/v1"}},
+	Security: []openapi.SecurityRequirement{{"bearerAuth": {}}, {"cookieAuth": {}}},
+	SecuritySchemes: map[string]openapi.SecurityScheme{
+		"bearerAuth": {
+			Type:        "http",
+			Scheme:      "bearer",
+			Description: "An API token from the settings page.",
+		},
+		"cookieAuth": {
+			Type:        "apiKey",
+			In:          "cookie",
+			Name:        "user",
+			Description: "The browser session. Environments other than production add a suffix to the name.",
+		},
+	},
+	Error: reflect.TypeFor[model.Error](),
+	Types: apiTypes,
+}
+
+// buildOpenAPI documents the routes of the API router. It also returns the
+// routes that have no entry in apiDocs.
+func buildOpenAPI(routes chi.Routes) (*openapi.Document, []string, error) {
+	var docRoutes []openapi.Route
+	var missing []string
+	err := chi.Walk(routes, func(method, route string, handler http.Handler, _ ...func(http.Handler) http.Handler) error {
+		path, _ := openapi.ParsePattern(route)
+		key := method + " " + path
+		doc, ok := apiDocs[key]
+		if !ok {
+			missing = append(missing, key)
+			return nil
+		}
+		r := openapi.Route{
+			Method:      method,
+			Pattern:     route,
+			Response:    doc.response,
+			ContentType: doc.contentType,
+			OperationID: doc.operationID,
+			Summary:     doc.summary,
+			Description: doc.description,
+			Tag:         doc.tag,
+			Public:      doc.public,
+		}
+		if h, ok := handler.(apiHandler); ok {
+			r.OperationID = h.operationID
+			r.Request = h.request
+			r.Response = h.response
+		}
+		docRoutes = append(docRoutes, r)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to walk routes: %w", err)
+	}
+	sort.Slice(docRoutes, func(i, j int) bool {
+		return docRoutes[i].Pattern < docRoutes[j].Pattern ||
+			docRoutes[i].Pattern == docRoutes[j].Pattern && docRoutes[i].Method < docRoutes[j].Method
+	})
+	return apiDocGenerator.Build(docRoutes), missing, nil
+}
+
+// openAPIHandler serves the OpenAPI document for the routes of the API router.
+// The document is built on first use, once every route has been registered.
+func openAPIHandler(routes chi.Routes) http.HandlerFunc {
+	var (
+		once sync.Once
+		body []byte
+		err  error
+	)
+	return func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() {
+			var doc *openapi.Document
+			var missing []string
+			doc, missing, err = buildOpenAPI(routes)
+			if err != nil {
+				return
+			}
+			if len(missing) > 0 {
+				slog.Warn(fmt.Sprintf("routes missing from the API docs: %s", strings.Join(missing, ", ")))
+			}
+			body, err = json.Marshal(doc)
+		})
+		if err != nil {
+			slog.Error(fmt.Sprintf("failed to build OpenAPI document: %v", err))
+			writeError(w, model.ErrorCodeInternal, internalErrorMsg)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}
+}
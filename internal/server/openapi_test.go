@@ -0,0 +1,97 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/baely/officetracker/internal/openapi"
+)
+
+// Every API route needs an entry in apiDocs, and every entry a route, so the
+// document can't fall behind the router.
+func TestOpenAPICoversRoutes(t *testing.T) {
+	h, _ := newStandaloneServer(t)
+	routes, ok := h.(chi.Routes)
+	if !ok {
+		t.Fatalf("handler %T doesn't expose its routes", h)
+	}
+	seen := map[string]bool{}
+	err := chi.Walk(routes, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		rest, ok := strings.CutPrefix(route, "/api/v1/")
+		if !ok {
+			return nil
+		}
+		path, _ := openapi.ParsePattern("/" + rest)
+		key := method + " " + path
+		seen[key] = true
+		if _, ok := apiDocs[key]; !ok {
+			t.Errorf("route %s has no entry in apiDocs", key)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk: %v", err)
+	}
+	for key := range apiDocs {
+		if !seen[key] {
+			t.Errorf("apiDocs entry %s has no route", key)
+		}
+	}
+}
+
+func TestServerOpenAPIDocument(t *testing.T) {
+	h, _ := newStandaloneServer(t)
+	res := do(t, h, http.MethodGet, "/api/v1/openapi.json", "")
+	if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("openapi.json = %d %q", res.StatusCode, res.Header.Get("Content-Type"))
+	}
+	var doc openapi.Document
+	if err := json.NewDecoder(res.Body).Decode(&doc); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if doc.OpenAPI != "3.1.0" {
+		t.Errorf("openapi = %q", doc.OpenAPI)
+	}
+
+	putDay := doc.Paths["/state/{year}/{month}/{day}"]["put"]
+	if putDay == nil {
+		t.Fatalf("missing PUT /state/{year}/{month}/{day}")
+	}
+	if putDay.OperationID != "PutDay" || putDay.Summary != "Update day state" {
+		t.Errorf("operation = %q %q", putDay.OperationID, putDay.Summary)
+	}
+	var params []string
+	for _, p := range putDay.Parameters {
+		params = append(params, p.In+":"+p.Name)
+	}
+	if strings.Join(params, ",") != "path:year,path:month,path:day" {
+		t.Errorf("PUT day parameters = %v", params)
+	}
+	if data := putDay.RequestBody.Content["application/json"].Schema.Properties["data"]; data == nil || data.Ref != "#/components/schemas/DayState" {
+		t.Errorf("PUT day body = %+v", putDay.RequestBody)
+	}
+
+	week := doc.Paths["/state/week/{iso_year}-W{week}"]["get"]
+	if week == nil || len(week.Parameters) != 2 || week.Parameters[0].Schema.Pattern != "^[0-9]{4}$" {
+		t.Errorf("week operation = %+v", week)
+	}
+
+	rangeOp := doc.Paths["/state"]["get"]
+	if rangeOp == nil || len(rangeOp.Parameters) != 2 || rangeOp.Parameters[0].In != "query" {
+		t.Errorf("range operation = %+v", rangeOp)
+	}
+
+	if pdf := doc.Paths["/report/pdf/{year}-attendance"]["get"]; pdf == nil || pdf.Responses["200"].Content["application/pdf"].Schema == nil {
+		t.Errorf("pdf report = %+v", pdf)
+	}
+	if health := doc.Paths["/health/check"]["get"]; health == nil || health.Security == nil || len(*health.Security) != 0 {
+		t.Errorf("health check should be public: %+v", health)
+	}
+	if state := doc.Components.Schemas["Source"]; state == nil || len(state.Enum) == 0 {
+		t.Errorf("Source schema = %+v", state)
+	}
+}
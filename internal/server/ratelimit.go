@@ -15,6 +15,7 @@ import (
 	"golang.org/x/time/rate"
 
 	"github.com/baely/officetracker/internal/database"
+	"github.com/baely/officetracker/pkg/model"
 )
 
 type rateLimits struct {
@@ -78,7 +79,7 @@ func (rl *rateLimiter) middleware(next http.Handler) http.Handler {
 		ok, retryAfter := rl.allow(r.Context(), key, limits, time.Now())
 		if !ok {
 			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
-			writeError(w, "rate limit exceeded", http.StatusTooManyRequests)
+			writeError(w, model.ErrorCodeRateLimited, "rate limit exceeded")
 			return
 		}
 		next.ServeHTTP(w, r)
@@ -166,3 +167,30 @@ func clientIP(r *http.Request) string {
 	}
 	return host
 }
+
+// trustedProxyKey marks a request as having come through a proxy the config
+// trusts to append the client's address to X-Forwarded-For.
+type trustedProxyKey struct{}
+
+// trustProxy marks requests as coming through a trusted proxy. It is only
+// used when the config says the server is behind one.
+func trustProxy(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), trustedProxyKey{}, true)))
+	})
+}
+
+// networkIP returns the client's IP for matching office networks. Unlike
+// clientIP, which only keys rate limits, it ignores X-Forwarded-For unless
+// the request came through a trusted proxy: otherwise a client could claim
+// any address and match any network.
+func networkIP(r *http.Request) string {
+	if trusted, _ := r.Context().Value(trustedProxyKey{}).(bool); trusted {
+		return clientIP(r)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
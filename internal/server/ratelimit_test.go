@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
@@ -36,6 +37,42 @@ func TestClientIP(t *testing.T) {
 	}
 }
 
+// Network matching only takes the address from X-Forwarded-For behind a
+// trusted proxy.
+func TestNetworkIP(t *testing.T) {
+	cases := []struct {
+		name       string
+		trusted    bool
+		xff        string
+		remoteAddr string
+		want       string
+	}{
+		{"trusted proxy, xff rightmost", true, "1.1.1.1, 2.2.2.2, 3.3.3.3", "10.0.0.1:5000", "3.3.3.3"},
+		{"trusted proxy, no xff", true, "", "192.168.1.5:41234", "192.168.1.5"},
+		{"untrusted, xff ignored", false, "203.0.113.7", "10.0.0.1:5000", "10.0.0.1"},
+		{"untrusted, no xff", false, "", "192.168.1.5:41234", "192.168.1.5"},
+		{"untrusted, malformed addr", false, "203.0.113.7", "not-an-addr", "not-an-addr"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = c.remoteAddr
+			if c.xff != "" {
+				r.Header.Set("X-Forwarded-For", c.xff)
+			}
+			var got string
+			var h http.Handler = http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) { got = networkIP(r) })
+			if c.trusted {
+				h = trustProxy(h)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+			if got != c.want {
+				t.Errorf("networkIP = %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
 // A request with an authenticated user is keyed and limited per user; anything
 // else is keyed and limited per client IP.
 func TestRateLimiterClient(t *testing.T) {
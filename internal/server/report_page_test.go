@@ -29,7 +29,7 @@ func TestBuildReportSummary(t *testing.T) {
 		}},
 	}}
 
-	rows, headline := buildReportSummary(state, 2025, 10)
+	rows, locations, headline := buildReportSummary(state, nil, nil, 2025, 10)
 
 	if len(rows) != 2 {
 		t.Fatalf("rows = %d, want 2 (months without work days omitted): %+v", len(rows), rows)
@@ -44,10 +44,42 @@ func TestBuildReportSummary(t *testing.T) {
 	if want := "Present in office for 3 out of 5 days. (60.00%)"; headline != want {
 		t.Errorf("headline = %q, want %q", headline, want)
 	}
+	if len(locations) != 0 {
+		t.Errorf("locations = %+v, want none when no day has a location", locations)
+	}
+}
+
+func TestBuildReportSummaryLocations(t *testing.T) {
+	locations := model.Locations{{ID: 1, Name: "Melbourne"}, {ID: 2, Name: "Sydney", Archived: true}}
+	state := model.YearState{Months: map[int]model.MonthState{
+		10: {Days: map[int]model.DayState{
+			1: {State: model.StateWorkFromOffice, LocationID: 1},
+			2: {State: model.StateWorkFromOffice, LocationID: 2},
+			3: {State: model.StateWorkFromOffice, LocationID: 1},
+			4: {State: model.StateWorkFromOffice},
+			5: {State: model.StateWorkFromHome},
+		}},
+	}}
+
+	_, rows, _ := buildReportSummary(state, nil, locations, 2025, 10)
+
+	want := []locationRow{
+		{Name: "Melbourne", Days: 2, Percent: "50.00%"},
+		{Name: "Sydney", Days: 1, Percent: "25.00%"},
+		{Name: "No location", Days: 1, Percent: "25.00%"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("locations = %+v, want %+v", rows, want)
+	}
+	for i := range want {
+		if rows[i] != want[i] {
+			t.Errorf("locations[%d] = %+v, want %+v", i, rows[i], want[i])
+		}
+	}
 }
 
 func TestBuildReportSummaryEmpty(t *testing.T) {
-	rows, headline := buildReportSummary(model.YearState{}, 2025, 10)
+	rows, _, headline := buildReportSummary(model.YearState{}, nil, nil, 2025, 10)
 	if len(rows) != 0 {
 		t.Errorf("rows = %+v, want none", rows)
 	}
@@ -61,15 +93,18 @@ func TestBuildReportSummaryEmpty(t *testing.T) {
 func TestReportTemplateRenders(t *testing.T) {
 	var buf strings.Builder
 	err := embed.Report.Execute(&buf, reportPage{
-		Year:     2026,
-		Rows:     []reportRow{{Month: "October 2025", Present: 2, Total: 4, Percent: "50.00%"}},
-		Headline: "Present in office for 2 out of 4 days. (50.00%)",
+		Year:       2026,
+		Rows:       []reportRow{{Month: "October 2025", Present: 2, Total: 4, Percent: "50.00%"}},
+		Locations:  []locationRow{{Name: "Melbourne", Days: 2, Percent: "100.00%"}},
+		Headline:   "Present in office for 2 out of 4 days. (50.00%)",
+		Target:     "3 days per week each month",
+		Compliance: "2025-10-01 to 2025-10-31: 1 of 3 days per week so far (4 of 14 office days); 10 more needed by 2025-10-31",
 	})
 	if err != nil {
 		t.Fatalf("failed to execute report template: %v", err)
 	}
 	out := buf.String()
-	for _, want := range []string{"October 2025", "50.00%", "export-csv", "export-pdf", "report-year"} {
+	for _, want := range []string{"October 2025", "50.00%", "export-csv", "export-pdf", "report-year", "Melbourne", "location-table", "target-summary", "3 days per week each month"} {
 		if !strings.Contains(out, want) {
 			t.Errorf("rendered report missing %q", want)
 		}
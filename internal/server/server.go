@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -18,6 +19,7 @@ import (
 	"github.com/baely/officetracker/internal/database"
 	"github.com/baely/officetracker/internal/embed"
 	v1 "github.com/baely/officetracker/internal/implementation/v1"
+	"github.com/baely/officetracker/internal/push"
 	"github.com/baely/officetracker/internal/report"
 	"github.com/baely/officetracker/internal/util"
 	"github.com/baely/officetracker/pkg/model"
@@ -41,6 +43,10 @@ func NewServer(cfg config.AppConfigurer, db database.Databaser, redis *database.
 		cfg:   cfg,
 		v1:    v1.New(db, reporter),
 	}
+	if expo := expoConfig(cfg); expo.PushURL != "" {
+		s.v1.SetDeviceSender(push.NewExpo(expo, http.DefaultClient))
+		go s.v1.ConfirmCheckIns(context.Background())
+	}
 
 	author, err := auth.NewAuth(cfg, db, redis)
 	if err != nil {
@@ -49,7 +55,15 @@ func NewServer(cfg config.AppConfigurer, db database.Databaser, redis *database.
 	s.auth = author
 
 	limiter := newRateLimiter(redis, authedRateLimits, unauthedRateLimits)
-	r := chi.NewMux().With(injectAuth(db, cfg), s.logRequest, limiter.middleware)
+	mux := chi.NewMux()
+	if cfg.GetApp().TrustProxy {
+		mux.Use(trustProxy)
+	}
+	r := mux.With(injectAuth(db, cfg), s.logRequest, limiter.middleware)
+
+	// Chat slash commands arrive from the platforms' shared addresses on
+	// behalf of many users, so they're signed rather than rate limited.
+	s.chatRouter(chatConfig(cfg))(mux.With(s.logRequest))
 
 	// Suspension page (must be accessible to suspended users)
 	r.Get("/suspended", s.handleSuspended)
@@ -75,12 +89,19 @@ func NewServer(cfg config.AppConfigurer, db database.Databaser, redis *database.
 	// Settings available in both standalone and integrated modes
 	r.Get("/settings", s.handleSettings)
 
+	// Linking a chat account, from the link sent in reply to its first command
+	r.Get("/"+chatLinkPath, s.handleChatLink)
+	r.Post("/"+chatLinkPath, s.handleChatLinkConfirm)
+
 	// Yearly report and export page
 	r.Get("/report", s.handleReport)
 
 	// Public stats dashboard (unauthenticated, aggregate-only).
 	r.Get("/stats", s.handleStats)
 
+	// API docs, generated from the API routes.
+	r.Get("/developer", s.handleDeveloper)
+
 	// Integrated app routes
 	switch integratedCfg := cfg.(type) {
 	case config.IntegratedApp:
@@ -88,8 +109,6 @@ func NewServer(cfg config.AppConfigurer, db database.Databaser, redis *database.
 		r.Route("/auth", auth.Router(integratedCfg, s.db, s.auth))
 		r.Get("/login", s.handleLogin)
 		r.Get("/logout", s.handleLogout)
-		// Cool stuff
-		r.Get("/developer", s.handleDeveloper)
 		// Boring stuff
 		r.Get("/tos", s.handleTos)
 		r.Get("/privacy", s.handlePrivacy)
@@ -102,6 +121,14 @@ func NewServer(cfg config.AppConfigurer, db database.Databaser, redis *database.
 		w.Write(embed.OfficeBuilding)
 	})
 
+	// The service worker that shows push notifications is served from the
+	// root so it can control every page.
+	r.Get("/sw.js", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/javascript")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write(embed.ServiceWorker)
+	})
+
 	r.NotFound(s.handleNotFound)
 
 	port := cfg.GetApp().Port
@@ -110,7 +137,7 @@ func NewServer(cfg config.AppConfigurer, db database.Databaser, redis *database.
 	}
 	s.Server = http.Server{
 		Addr:    fmt.Sprintf(":%s", port),
-		Handler: r,
+		Handler: mux,
 	}
 
 	return s, nil
@@ -130,14 +157,14 @@ func (s *Server) Run() error {
 func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 	switch s.cfg.(type) {
 	case config.StandaloneApp:
-		http.Redirect(w, r, fmt.Sprintf("/%s", time.Now().Format("2006-01")), http.StatusTemporaryRedirect)
+		http.Redirect(w, r, s.thisMonthPath(r), http.StatusTemporaryRedirect)
 		return
 	case config.IntegratedApp:
 		method, _ := getAuthMethod(r)
 		var loggedInMethods = []auth.Method{auth.MethodSSO, auth.MethodSecret}
 		if slices.Contains(loggedInMethods, method) {
 			if userID, err := getUserID(r); err == nil && userID != 0 {
-				http.Redirect(w, r, fmt.Sprintf("/%s", time.Now().Format("2006-01")), http.StatusTemporaryRedirect)
+				http.Redirect(w, r, s.thisMonthPath(r), http.StatusTemporaryRedirect)
 				return
 			}
 		}
@@ -148,6 +175,33 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// userLocation returns the signed-in user's timezone, or nil when there's no
+// user or they haven't picked one.
+func (s *Server) userLocation(r *http.Request) *time.Location {
+	userID, err := getUserID(r)
+	if err != nil || userID == 0 {
+		return nil
+	}
+	prefs, err := s.db.GetCalendarPreferences(userID)
+	if err != nil {
+		slog.Error("failed to get calendar preferences", "userID", userID, "error", err.Error())
+		return nil
+	}
+	if prefs.Timezone == "" {
+		return nil
+	}
+	return util.Location(prefs.Timezone)
+}
+
+// thisMonthPath is the form page for the current month in the user's timezone.
+func (s *Server) thisMonthPath(r *http.Request) string {
+	loc := s.userLocation(r)
+	if loc == nil {
+		loc = time.Local
+	}
+	return "/" + time.Now().In(loc).Format("2006-01")
+}
+
 func (s *Server) handleForm(w http.ResponseWriter, r *http.Request) {
 	userID, err := getUserID(r)
 	if errors.Is(err, ErrNoUserInCtx) || userID == 0 {
@@ -164,7 +218,7 @@ func (s *Server) handleForm(w http.ResponseWriter, r *http.Request) {
 	yearStr := chi.URLParam(r, "year")
 	monthStr := chi.URLParam(r, "month")
 	if yearStr == "" || monthStr == "" {
-		http.Redirect(w, r, fmt.Sprintf("/%s", time.Now().Format("2006-01")), http.StatusTemporaryRedirect)
+		http.Redirect(w, r, s.thisMonthPath(r), http.StatusTemporaryRedirect)
 		return
 	}
 	year, err := strconv.Atoi(yearStr)
@@ -236,11 +290,52 @@ func (s *Server) handleForm(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	targetPrefsByte, err := json.Marshal(targetPrefs)
+	if err != nil {
+		err = fmt.Errorf("failed to marshal target preferences: %w", err)
+		errorPage(w, r, err, internalErrorMsg, http.StatusInternalServerError)
+		return
+	}
+
+	customStates, err := s.db.GetCustomStates(userID)
+	if err != nil {
+		err = fmt.Errorf("failed to get custom states: %w", err)
+		errorPage(w, r, err, internalErrorMsg, http.StatusInternalServerError)
+		return
+	}
+	if customStates == nil {
+		customStates = model.CustomStates{}
+	}
+	customStatesByte, err := json.Marshal(customStates)
+	if err != nil {
+		err = fmt.Errorf("failed to marshal custom states: %w", err)
+		errorPage(w, r, err, internalErrorMsg, http.StatusInternalServerError)
+		return
+	}
+
+	locations, err := s.db.GetLocations(userID)
+	if err != nil {
+		err = fmt.Errorf("failed to get locations: %w", err)
+		errorPage(w, r, err, internalErrorMsg, http.StatusInternalServerError)
+		return
+	}
+	if locations == nil {
+		locations = model.Locations{}
+	}
+	locationsByte, err := json.Marshal(locations)
+	if err != nil {
+		err = fmt.Errorf("failed to marshal locations: %w", err)
+		errorPage(w, r, err, internalErrorMsg, http.StatusInternalServerError)
+		return
+	}
+
 	serveForm(w, r, formPage{
 		YearlyState:        template.JS(yearlyDataStr),
 		YearlyNotes:        template.JS(yearlyNotesStr),
 		TrackingStartMonth: startMonth,
-		TargetPercent:      targetPrefs.TargetPercent,
+		Target:             template.JS(targetPrefsByte),
+		CustomStates:       template.JS(customStatesByte),
+		Locations:          template.JS(locationsByte),
 	})
 }
 
@@ -268,7 +363,7 @@ func (s *Server) handleReport(w http.ResponseWriter, r *http.Request) {
 	startMonth := util.NormaliseStartMonth(calendarPrefs.TrackingYearStartMonth)
 
 	// Default to the tracking year containing today, overridable via ?year=.
-	now := time.Now()
+	now := time.Now().In(util.Location(calendarPrefs.Timezone))
 	year := util.TrackingYear(int(now.Month()), now.Year(), startMonth)
 	if q := r.URL.Query().Get("year"); q != "" {
 		if y, err := strconv.Atoi(q); err == nil {
@@ -288,12 +383,54 @@ func (s *Server) handleReport(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rows, headline := buildReportSummary(yearlyData.Data, year, startMonth)
-	serveReport(w, r, reportPage{
-		Year:     year,
-		Rows:     rows,
-		Headline: headline,
+	customStates, err := s.db.GetCustomStates(userID)
+	if err != nil {
+		err = fmt.Errorf("failed to get custom states: %w", err)
+		errorPage(w, r, err, internalErrorMsg, http.StatusInternalServerError)
+		return
+	}
+
+	locations, err := s.db.GetLocations(userID)
+	if err != nil {
+		err = fmt.Errorf("failed to get locations: %w", err)
+		errorPage(w, r, err, internalErrorMsg, http.StatusInternalServerError)
+		return
+	}
+
+	// Show the target as of today, or the last day of a year that's over.
+	_, end := util.TrackingYearRange(year, startMonth, now.Location())
+	asOf := now
+	if last := end.AddDate(0, 0, -1); last.Before(asOf) {
+		asOf = last
+	}
+	compliance, err := s.v1.GetCompliance(model.GetComplianceRequest{
+		Meta: model.GetComplianceRequestMeta{UserID: userID},
+		Date: asOf.Format("2006-01-02"),
 	})
+	if err != nil {
+		err = fmt.Errorf("failed to get target compliance: %w", err)
+		errorPage(w, r, err, internalErrorMsg, http.StatusInternalServerError)
+		return
+	}
+
+	rows, locationRows, headline := buildReportSummary(yearlyData.Data, customStates, locations, year, startMonth)
+	page := reportPage{
+		Year:      year,
+		Rows:      rows,
+		Locations: locationRows,
+		Headline:  headline,
+	}
+	if c := compliance.Data; c != nil {
+		targetPrefs, err := s.db.GetTargetPreferences(userID)
+		if err != nil {
+			err = fmt.Errorf("failed to get target preferences: %w", err)
+			errorPage(w, r, err, internalErrorMsg, http.StatusInternalServerError)
+			return
+		}
+		page.Target = report.DescribeTarget(targetPrefs)
+		page.Compliance = fmt.Sprintf("%s to %s: %s", c.Start, c.End, report.DescribeCompliance(*c))
+	}
+	serveReport(w, r, page)
 }
 
 func (s *Server) handleHero(w http.ResponseWriter, r *http.Request) {
@@ -339,6 +476,22 @@ func (s *Server) handleSettings(w http.ResponseWriter, r *http.Request) {
 		errorPage(w, r, err, internalErrorMsg, http.StatusInternalServerError)
 		return
 	}
+	history, err := s.db.GetScheduleHistory(userID)
+	if err != nil {
+		err = fmt.Errorf("failed to get schedule history: %w", err)
+		errorPage(w, r, err, internalErrorMsg, http.StatusInternalServerError)
+		return
+	}
+	pushSubs, err := s.v1.ListPushSubscriptions(model.ListPushSubscriptionsRequest{
+		Meta: model.ListPushSubscriptionsRequestMeta{
+			UserID: userID,
+		},
+	})
+	if err != nil {
+		err = fmt.Errorf("failed to get push subscriptions: %w", err)
+		errorPage(w, r, err, internalErrorMsg, http.StatusInternalServerError)
+		return
+	}
 
 	// Handle Auth0 auth only for integrated mode
 	var authURL string
@@ -358,12 +511,22 @@ func (s *Server) handleSettings(w http.ResponseWriter, r *http.Request) {
 	}
 
 	serveSettings(w, r, settingsPage{
-		LinkedAccounts:      linkedAccounts,
-		Auth0AuthURL:        authURL,
-		ThemePreferences:    settings.ThemePreferences,
-		SchedulePreferences: settings.SchedulePreferences,
-		CalendarPreferences: settings.CalendarPreferences,
-		TargetPreferences:   settings.TargetPreferences,
+		LinkedAccounts:          linkedAccounts,
+		Auth0AuthURL:            authURL,
+		ThemePreferences:        settings.ThemePreferences,
+		SchedulePreferences:     settings.SchedulePreferences,
+		CalendarPreferences:     settings.CalendarPreferences,
+		TargetPreferences:       settings.TargetPreferences,
+		CustomStates:            settings.CustomStates,
+		Locations:               settings.Locations,
+		NetworkRules:            settings.NetworkRules,
+		ScheduleRules:           settings.ScheduleRules,
+		ScheduleVersions:        buildScheduleVersions(history),
+		MaterialisePreferences:  settings.MaterialisePreferences,
+		NotificationPreferences: settings.NotificationPreferences,
+		EmailAvailable:          smtpConfig(s.cfg).Host != "",
+		PushKey:                 webPushConfig(s.cfg).PublicKey,
+		PushSubscriptions:       pushSubs.Data,
 	})
 }
 
@@ -371,23 +534,23 @@ func (s *Server) handleSettings(w http.ResponseWriter, r *http.Request) {
 // user (expires after 10 minutes).
 func (s *Server) handleAccountLinkURL(w http.ResponseWriter, r *http.Request) {
 	if s.auth == nil {
-		writeError(w, "account linking is not available", http.StatusNotImplemented)
+		writeError(w, model.ErrorCodeNotImplemented, "account linking is not available")
 		return
 	}
 	userID, err := getUserID(r)
 	if err != nil || userID == 0 {
-		writeError(w, "unauthorized", http.StatusUnauthorized)
+		writeError(w, model.ErrorCodeUnauthorized, "unauthorized")
 		return
 	}
 	url, err := s.auth.GenerateAuth0AuthLink(userID)
 	if err != nil {
 		slog.Error(fmt.Sprintf("failed to generate account link url: %v", err))
-		writeError(w, internalErrorMsg, http.StatusInternalServerError)
+		writeError(w, model.ErrorCodeInternal, internalErrorMsg)
 		return
 	}
 	b, err := json.Marshal(map[string]string{"url": url})
 	if err != nil {
-		writeError(w, internalErrorMsg, http.StatusInternalServerError)
+		writeError(w, model.ErrorCodeInternal, internalErrorMsg)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -406,7 +569,7 @@ func (s *Server) handleLogoutToken(w http.ResponseWriter, r *http.Request) {
 	if method == auth.MethodSecret && secret != "" {
 		if err := s.db.RevokeSecretByValue(secret); err != nil {
 			slog.Error(fmt.Sprintf("failed to revoke token on logout: %v", err))
-			writeError(w, internalErrorMsg, http.StatusInternalServerError)
+			writeError(w, model.ErrorCodeInternal, internalErrorMsg)
 			return
 		}
 		slog.Info("revoked token on logout")
@@ -415,8 +578,7 @@ func (s *Server) handleLogoutToken(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleDeveloper(w http.ResponseWriter, r *http.Request) {
-	// API token management now lives on the settings page.
-	http.Redirect(w, r, "/settings#api-tokens", http.StatusTemporaryRedirect)
+	serveDeveloper(w, r, developerPage{})
 }
 
 func (s *Server) handleTos(w http.ResponseWriter, r *http.Request) {
@@ -439,7 +601,7 @@ func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
 	}
 	serveStats(w, r, statsPage{
 		Groups:      groupStatWidgets(resp.Widgets),
-		LastUpdated: formatLastUpdated(resp.ComputedAt),
+		LastUpdated: formatLastUpdated(resp.ComputedAt, s.userLocation(r)),
 	})
 }
 
@@ -1,11 +1,16 @@
 package server
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/baely/officetracker/internal/config"
 	"github.com/baely/officetracker/internal/database/dbtest"
@@ -90,6 +95,169 @@ func TestServerStateRoundTrip(t *testing.T) {
 	}
 }
 
+// The bulk endpoint writes several months at once and the range endpoint
+// lists what was written.
+func TestServerStateRange(t *testing.T) {
+	h, _ := newStandaloneServer(t)
+
+	body := `{"data":{"entries":[{"date":"2024-02-29","state":2}],"range":{"from":"2024-03-01","to":"2024-03-04","state":1,"weekdays_only":true}}}`
+	res := do(t, h, http.MethodPatch, "/api/v1/state", body)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("PATCH state status = %d, want 200", res.StatusCode)
+	}
+	if b := bodyString(t, res); !strings.Contains(b, `"updated":3`) {
+		t.Errorf("PATCH state body = %s, want 3 updated", b)
+	}
+
+	res = do(t, h, http.MethodGet, "/api/v1/state?from=2024-02-29&to=2024-03-04", "")
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("GET state range status = %d", res.StatusCode)
+	}
+	b := bodyString(t, res)
+	if !strings.Contains(b, `"date":"2024-02-29","state":2`) || !strings.Contains(b, `"date":"2024-03-04","state":1`) || strings.Contains(b, "2024-03-02") {
+		t.Errorf("GET state range body = %s", b)
+	}
+
+	if res := do(t, h, http.MethodPatch, "/api/v1/state", `{"data":{"entries":[{"date":"2024-02-31","state":2}]}}`); res.StatusCode == http.StatusOK {
+		t.Error("PATCH with 31 February should fail")
+	}
+	if res := do(t, h, http.MethodPut, "/api/v1/state/2024/2/31", `{"data":{"state":2}}`); res.StatusCode == http.StatusOK {
+		t.Error("PUT 31 February should fail")
+	}
+}
+
+// Invalid input comes back as a 400 naming each bad field.
+func TestServerValidationErrors(t *testing.T) {
+	h, db := newStandaloneServer(t)
+
+	cases := []struct {
+		method, target, body string
+		fields               []string
+	}{
+		{http.MethodPut, "/api/v1/state/2024/13/1", `{"data":{"state":2}}`, []string{"month"}},
+		{http.MethodPut, "/api/v1/state/2024/2/31", `{"data":{"state":9}}`, []string{"day", "data.state"}},
+		{http.MethodPut, "/api/v1/state/2024/2", `{"data":{"days":{"1":{"state":2},"30":{"state":2}}}}`, []string{"data.days.30"}},
+		{http.MethodPatch, "/api/v1/state", `{"data":{"entries":[{"date":"2024-02-30","state":2}]}}`, []string{"data.entries[0].date"}},
+		{http.MethodGet, "/api/v1/state?from=2024-03-02&to=2024-02-28", "", []string{"to"}},
+		{http.MethodPut, "/api/v1/settings/calendar", `{"data":{"tracking_year_start_month":10,"timezone":"Mars/Olympus"}}`, []string{"data.timezone"}},
+	}
+	for _, c := range cases {
+		res := do(t, h, c.method, c.target, c.body)
+		if res.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s %s status = %d, want 400", c.method, c.target, res.StatusCode)
+			continue
+		}
+		var e model.Error
+		if err := json.NewDecoder(res.Body).Decode(&e); err != nil {
+			t.Fatalf("%s %s: decode error body: %v", c.method, c.target, err)
+		}
+		if e.ErrorCode != model.ErrorCodeValidation {
+			t.Errorf("%s %s error code = %q, want %q", c.method, c.target, e.ErrorCode, model.ErrorCodeValidation)
+		}
+		var fields []string
+		for _, fe := range e.Errors {
+			fields = append(fields, fe.Field)
+			if fe.Reason == "" {
+				t.Errorf("%s %s: field %s has no reason", c.method, c.target, fe.Field)
+			}
+		}
+		if !slices.Equal(fields, c.fields) {
+			t.Errorf("%s %s fields = %v, want %v", c.method, c.target, fields, c.fields)
+		}
+	}
+	if got, _ := db.GetDay(1, 1, 2, 2024); got.State != model.StateUntracked {
+		t.Errorf("1 February = %+v, want nothing saved", got)
+	}
+}
+
+// Errors carry a stable code alongside their status.
+func TestServerErrorCodes(t *testing.T) {
+	h, db := newStandaloneServer(t)
+	db.Errs = map[string]error{"GetDay": errors.New("database down")}
+
+	if res := do(t, h, http.MethodPost, "/api/v1/settings/locations", `{"data":{"name":"HQ"}}`); res.StatusCode != http.StatusOK {
+		t.Fatalf("create location status = %d", res.StatusCode)
+	}
+
+	cases := []struct {
+		method, target, body string
+		status               int
+		code                 model.ErrorCode
+	}{
+		{http.MethodPut, "/api/v1/state/2024/3/5", `{"data":`, http.StatusBadRequest, model.ErrorCodeBadRequest},
+		{http.MethodPut, "/api/v1/state/2024/13/5", `{"data":{"state":1}}`, http.StatusBadRequest, model.ErrorCodeValidation},
+		{http.MethodGet, "/api/v1/developer/tokens", "", http.StatusForbidden, model.ErrorCodeForbidden},
+		{http.MethodDelete, "/api/v1/settings/locations/99", "", http.StatusNotFound, model.ErrorCodeNotFound},
+		{http.MethodPut, "/api/v1/settings/locations/0", `{"data":{"name":"Sydney"}}`, http.StatusNotFound, model.ErrorCodeNotFound},
+		{http.MethodPut, "/api/v1/settings/schedule/rules/0", `{"data":{"rrule":"FREQ=DAILY","start":"2024-01-01"}}`, http.StatusNotFound, model.ErrorCodeNotFound},
+		{http.MethodPut, "/api/v1/settings/networks/0", `{"data":{"kind":"ssid","value":"Office"}}`, http.StatusNotFound, model.ErrorCodeNotFound},
+		{http.MethodPut, "/api/v1/settings/states/abc", `{"data":{"name":"Travel","color":"#000000","attendance":"absent"}}`, http.StatusNotFound, model.ErrorCodeNotFound},
+		{http.MethodPost, "/api/v1/settings/locations", `{"data":{"name":"hq"}}`, http.StatusConflict, model.ErrorCodeConflict},
+		{http.MethodGet, "/api/v1/nothing-here", "", http.StatusNotFound, model.ErrorCodeNotFound},
+		{http.MethodGet, "/api/v1/state/2024/3/5", "", http.StatusInternalServerError, model.ErrorCodeInternal},
+	}
+	for _, c := range cases {
+		res := do(t, h, c.method, c.target, c.body)
+		var e model.Error
+		if err := json.NewDecoder(res.Body).Decode(&e); err != nil {
+			t.Fatalf("%s %s: decode error body: %v", c.method, c.target, err)
+		}
+		if res.StatusCode != c.status || e.Code != c.status || e.ErrorCode != c.code {
+			t.Errorf("%s %s = %d %+v, want %d %s", c.method, c.target, res.StatusCode, e, c.status, c.code)
+		}
+	}
+}
+
+// Week endpoints address days by ISO week and weekday.
+func TestServerWeekRoundTrip(t *testing.T) {
+	h, db := newStandaloneServer(t)
+
+	if res := do(t, h, http.MethodPut, "/api/v1/state/week/2024-W05", `{"data":{"days":{"1":{"state":2},"5":{"state":1}}}}`); res.StatusCode != http.StatusOK {
+		t.Fatalf("PUT week status = %d, want 200", res.StatusCode)
+	}
+	if got, _ := db.GetDay(1, 2, 2, 2024); got.State != model.StateWorkFromHome {
+		t.Errorf("2 February = %+v, want home", got)
+	}
+
+	res := do(t, h, http.MethodGet, "/api/v1/state/week/2024-W05", "")
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("GET week status = %d", res.StatusCode)
+	}
+	if b := bodyString(t, res); !strings.Contains(b, `"week":"2024-W05"`) || !strings.Contains(b, `"office_days":1`) {
+		t.Errorf("GET week body = %s", b)
+	}
+
+	if res := do(t, h, http.MethodGet, "/api/v1/state/week/2024-W53", ""); res.StatusCode == http.StatusOK {
+		t.Error("GET 2024-W53 should fail")
+	}
+}
+
+// Each change to a day is listed, oldest first, by the history endpoint.
+func TestServerDayHistory(t *testing.T) {
+	h, _ := newStandaloneServer(t)
+
+	do(t, h, http.MethodPut, "/api/v1/state/2024/3/5", `{"data":{"state":2}}`)
+	do(t, h, http.MethodPut, "/api/v1/state/2024/3/5", `{"data":{"state":1}}`)
+
+	res := do(t, h, http.MethodGet, "/api/v1/state/2024/3/5/history", "")
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("GET history status = %d", res.StatusCode)
+	}
+	var body model.GetDayHistoryResponse
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatalf("decode history: %v", err)
+	}
+	if len(body.Data) != 2 {
+		t.Fatalf("got %d changes, want 2", len(body.Data))
+	}
+	if body.Data[1].OldState != model.StateWorkFromOffice || body.Data[1].NewState != model.StateWorkFromHome {
+		t.Errorf("second change = %+v", body.Data[1])
+	}
+	if body.Data[0].AuthMethod != "excluded" {
+		t.Errorf("auth method = %q, want excluded", body.Data[0].AuthMethod)
+	}
+}
+
 func TestServerNotesRoundTrip(t *testing.T) {
 	h, _ := newStandaloneServer(t)
 
@@ -102,6 +270,25 @@ func TestServerNotesRoundTrip(t *testing.T) {
 	}
 }
 
+// Day notes live on the state entry and are managed through their own route.
+func TestServerDayNoteRoundTrip(t *testing.T) {
+	h, _ := newStandaloneServer(t)
+
+	do(t, h, http.MethodPut, "/api/v1/state/2024/3/5", `{"data":{"state":2}}`)
+	if res := do(t, h, http.MethodPut, "/api/v1/note/2024/3/5", `{"data":{"note":"client site"}}`); res.StatusCode != http.StatusOK {
+		t.Fatalf("PUT day note status = %d", res.StatusCode)
+	}
+
+	res := do(t, h, http.MethodGet, "/api/v1/note/2024/3/5", "")
+	if b := bodyString(t, res); !strings.Contains(b, "client site") {
+		t.Errorf("GET day note body = %s", b)
+	}
+	res = do(t, h, http.MethodGet, "/api/v1/state/2024/3", "")
+	if b := bodyString(t, res); !strings.Contains(b, `"note":"client site"`) || !strings.Contains(b, `"state":2`) {
+		t.Errorf("GET month body = %s", b)
+	}
+}
+
 func TestServerSettingsRoundTrip(t *testing.T) {
 	h, _ := newStandaloneServer(t)
 
@@ -119,6 +306,451 @@ func TestServerSettingsRoundTrip(t *testing.T) {
 	}
 }
 
+// A day-based target set through the API is measured by the compliance
+// endpoint; unknown policies are rejected.
+func TestServerTargetCompliance(t *testing.T) {
+	h, _ := newStandaloneServer(t)
+
+	if res := do(t, h, http.MethodGet, "/api/v1/settings/target/compliance", ""); res.StatusCode != http.StatusOK {
+		t.Fatalf("GET compliance status = %d", res.StatusCode)
+	} else if b := bodyString(t, res); !strings.Contains(b, `"data":null`) {
+		t.Errorf("compliance without a target = %s", b)
+	}
+
+	if res := do(t, h, http.MethodPut, "/api/v1/settings/target", `{"data":{"policy":"hours"}}`); res.StatusCode == http.StatusOK {
+		t.Error("PUT target with an unknown policy succeeded")
+	}
+	if res := do(t, h, http.MethodPut, "/api/v1/settings/target", `{"data":{"policy":"days_per_week","target_days":3,"window":"quarter"}}`); res.StatusCode != http.StatusOK {
+		t.Fatalf("PUT target status = %d", res.StatusCode)
+	}
+	if res := do(t, h, http.MethodPut, "/api/v1/state/2024/3/4", `{"data":{"state":2}}`); res.StatusCode != http.StatusOK {
+		t.Fatalf("PUT state status = %d", res.StatusCode)
+	}
+
+	res := do(t, h, http.MethodGet, "/api/v1/settings/target/compliance?date=2024-03-15", "")
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("GET compliance status = %d", res.StatusCode)
+	}
+	var body model.GetComplianceResponse
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if c := body.Data; c == nil || c.Start != "2024-01-01" || c.End != "2024-03-31" || c.Present != 1 || c.Required != 39 {
+		t.Errorf("compliance = %+v", c)
+	}
+}
+
+// The forecast endpoint projects the target window from the given date.
+func TestServerTargetForecast(t *testing.T) {
+	h, _ := newStandaloneServer(t)
+
+	if res := do(t, h, http.MethodPut, "/api/v1/settings/target", `{"data":{"target_percent":50}}`); res.StatusCode != http.StatusOK {
+		t.Fatalf("PUT target status = %d", res.StatusCode)
+	}
+	if res := do(t, h, http.MethodPut, "/api/v1/state/2024/3/4", `{"data":{"state":2}}`); res.StatusCode != http.StatusOK {
+		t.Fatalf("PUT state status = %d", res.StatusCode)
+	}
+
+	res := do(t, h, http.MethodGet, "/api/v1/settings/target/forecast?date=2024-03-04&until=2024-03-31", "")
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("GET forecast status = %d", res.StatusCode)
+	}
+	var body model.GetForecastResponse
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	// 19 weekdays remain after 4 March, so 10 of 20 work days are needed.
+	if f := body.Data; f == nil || f.Present != 1 || f.RemainingWorkDays != 19 || f.Required != 10 || f.Needed != 9 || !f.Achievable {
+		t.Errorf("forecast = %+v", f)
+	}
+
+	if res := do(t, h, http.MethodGet, "/api/v1/settings/target/forecast?date=2024-03-04&until=2024-03-01", ""); res.StatusCode == http.StatusOK {
+		t.Error("GET forecast with until before date succeeded")
+	}
+}
+
+// Custom states are managed under /settings/states, can be used for days and
+// are archived rather than deleted.
+func TestServerCustomStates(t *testing.T) {
+	h, db := newStandaloneServer(t)
+
+	res := do(t, h, http.MethodPost, "/api/v1/settings/states", `{"data":{"name":"Client site","color":"#ff9800","attendance":"present"}}`)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("POST state status = %d", res.StatusCode)
+	}
+	var created model.CreateCustomStateResponse
+	if err := json.NewDecoder(res.Body).Decode(&created); err != nil {
+		t.Fatalf("decode state: %v", err)
+	}
+	if created.Data.ID != model.CustomStateBase {
+		t.Fatalf("created ID = %d, want %d", created.Data.ID, model.CustomStateBase)
+	}
+
+	if res := do(t, h, http.MethodPut, "/api/v1/state/2024/3/5", `{"data":{"state":100}}`); res.StatusCode != http.StatusOK {
+		t.Errorf("PUT custom day status = %d", res.StatusCode)
+	}
+	if res := do(t, h, http.MethodPut, "/api/v1/state/2024/3/6", `{"data":{"state":101}}`); res.StatusCode == http.StatusOK {
+		t.Error("PUT with an undefined custom state should fail")
+	}
+
+	if res := do(t, h, http.MethodPut, "/api/v1/settings/states/100", `{"data":{"name":"Client site","color":"#ff9800","attendance":"absent"}}`); res.StatusCode != http.StatusOK {
+		t.Errorf("PUT state status = %d", res.StatusCode)
+	}
+	if res := do(t, h, http.MethodDelete, "/api/v1/settings/states/100", ""); res.StatusCode != http.StatusOK {
+		t.Errorf("DELETE state status = %d", res.StatusCode)
+	}
+
+	res = do(t, h, http.MethodGet, "/api/v1/settings/states", "")
+	if b := bodyString(t, res); !strings.Contains(b, `"attendance":"absent"`) || !strings.Contains(b, `"archived":true`) {
+		t.Errorf("GET states body = %s", b)
+	}
+	if day, _ := db.GetDay(1, 5, 3, 2024); day.State != created.Data.ID {
+		t.Errorf("day state = %d, want %d", day.State, created.Data.ID)
+	}
+}
+
+// Locations are managed under /settings/locations and set on office days
+// through their own endpoint.
+func TestServerLocations(t *testing.T) {
+	h, db := newStandaloneServer(t)
+
+	res := do(t, h, http.MethodPost, "/api/v1/settings/locations", `{"data":{"name":"Melbourne","latitude":-37.8136,"longitude":144.9631,"radius":150}}`)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("POST location status = %d", res.StatusCode)
+	}
+	var created model.CreateLocationResponse
+	if err := json.NewDecoder(res.Body).Decode(&created); err != nil {
+		t.Fatalf("decode location: %v", err)
+	}
+	if created.Data.ID != 1 {
+		t.Fatalf("created ID = %d, want 1", created.Data.ID)
+	}
+
+	if res := do(t, h, http.MethodPut, "/api/v1/state/2024/3/5", `{"data":{"state":2,"location_id":1}}`); res.StatusCode != http.StatusOK {
+		t.Errorf("PUT day with location status = %d", res.StatusCode)
+	}
+	if res := do(t, h, http.MethodPut, "/api/v1/state/2024/3/6", `{"data":{"state":1,"location_id":1}}`); res.StatusCode == http.StatusOK {
+		t.Error("PUT a location on a day at home should fail")
+	}
+	if res := do(t, h, http.MethodPut, "/api/v1/state/2024/3/5/location", `{"data":{"location_id":0}}`); res.StatusCode != http.StatusOK {
+		t.Errorf("PUT day location status = %d", res.StatusCode)
+	}
+	if day, _ := db.GetDay(1, 5, 3, 2024); day.State != model.StateWorkFromOffice || day.LocationID != 0 {
+		t.Errorf("day = %+v, want office with no location", day)
+	}
+
+	if res := do(t, h, http.MethodDelete, "/api/v1/settings/locations/1", ""); res.StatusCode != http.StatusOK {
+		t.Errorf("DELETE location status = %d", res.StatusCode)
+	}
+	res = do(t, h, http.MethodGet, "/api/v1/settings/locations", "")
+	if b := bodyString(t, res); !strings.Contains(b, `"name":"Melbourne"`) || !strings.Contains(b, `"archived":true`) {
+		t.Errorf("GET locations body = %s", b)
+	}
+}
+
+// Check-ins are matched against the user's locations on the server.
+func TestServerCheckIn(t *testing.T) {
+	h, db := newStandaloneServer(t)
+	lat, lng := -37.8136, 144.9631
+	db.SaveLocation(1, model.Location{Name: "Melbourne", Latitude: &lat, Longitude: &lng})
+
+	if res := do(t, h, http.MethodPost, "/api/v1/checkin", `{"data":{"latitude":-37.8140}}`); res.StatusCode != http.StatusBadRequest {
+		t.Errorf("POST check-in without a longitude status = %d, want 400", res.StatusCode)
+	}
+	res := do(t, h, http.MethodPost, "/api/v1/checkin", `{"data":{"latitude":-37.8140,"longitude":144.9630,"source":"extension"}}`)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("POST check-in status = %d: %s", res.StatusCode, bodyString(t, res))
+	}
+	var got model.CheckInResponse
+	if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+		t.Fatalf("decode check-in: %v", err)
+	}
+	if !got.Data.Recorded || got.Data.Day.Source != model.SourceExtension || got.Data.Day.LocationID != 1 {
+		t.Errorf("check-in = %+v", got.Data)
+	}
+
+	res = do(t, h, http.MethodPost, "/api/v1/checkin", `{"data":{"latitude":-37.8140,"longitude":144.9630}}`)
+	if b := bodyString(t, res); !strings.Contains(b, `"reason":"already_set"`) {
+		t.Errorf("second check-in body = %s", b)
+	}
+}
+
+// Network rules are managed under /settings/networks and matched against
+// the address a check-in comes from.
+func TestServerNetworkRules(t *testing.T) {
+	h, _ := newStandaloneServer(t)
+
+	if res := do(t, h, http.MethodPost, "/api/v1/settings/networks", `{"data":{"kind":"cidr","value":"not an ip"}}`); res.StatusCode != http.StatusBadRequest {
+		t.Errorf("POST invalid network rule status = %d, want 400", res.StatusCode)
+	}
+	// httptest requests come from 192.0.2.1.
+	res := do(t, h, http.MethodPost, "/api/v1/settings/networks", `{"data":{"kind":"cidr","value":"192.0.2.0/24"}}`)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("POST network rule status = %d: %s", res.StatusCode, bodyString(t, res))
+	}
+	if body := bodyString(t, do(t, h, http.MethodGet, "/api/v1/settings/networks", "")); !strings.Contains(body, `"value":"192.0.2.0/24"`) {
+		t.Errorf("GET network rules = %s", body)
+	}
+
+	// Without a trusted proxy a forged X-Forwarded-For doesn't move the
+	// client off the office network.
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/checkin", strings.NewReader(`{"data":{"source":"extension"}}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if b := bodyString(t, w.Result()); !strings.Contains(b, `"recorded":true`) || !strings.Contains(b, `"kind":"cidr"`) {
+		t.Errorf("check-in from the office network = %s", b)
+	}
+
+	if res := do(t, h, http.MethodDelete, "/api/v1/settings/networks/1", ""); res.StatusCode != http.StatusOK {
+		t.Errorf("DELETE network rule status = %d", res.StatusCode)
+	}
+	if res := do(t, h, http.MethodDelete, "/api/v1/settings/networks/1", ""); res.StatusCode != http.StatusNotFound {
+		t.Errorf("DELETE deleted network rule status = %d, want 404", res.StatusCode)
+	}
+}
+
+// Behind a trusted proxy the check-in is matched against the address the
+// proxy reports.
+func TestServerNetworkRulesTrustedProxy(t *testing.T) {
+	db := dbtest.New()
+	srv, err := NewServer(config.StandaloneApp{App: config.App{TrustProxy: true}}, db, nil, report.New(db))
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	db.SaveNetworkRule(1, model.NetworkRule{Kind: model.NetworkRuleCIDR, Value: "198.51.100.0/24"})
+
+	checkIn := func(xff string) string {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/checkin", strings.NewReader(`{"data":{"source":"extension"}}`))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("X-Forwarded-For", xff)
+		w := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(w, r)
+		return bodyString(t, w.Result())
+	}
+	if b := checkIn("192.0.2.1"); strings.Contains(b, `"recorded":true`) {
+		t.Errorf("check-in from outside the office network = %s", b)
+	}
+	if b := checkIn("203.0.113.9, 198.51.100.7"); !strings.Contains(b, `"recorded":true`) {
+		t.Errorf("check-in from the office network = %s", b)
+	}
+}
+
+func TestServerScheduleRules(t *testing.T) {
+	h, db := newStandaloneServer(t)
+
+	res := do(t, h, http.MethodPost, "/api/v1/settings/schedule/rules", `{"data":{"state":2,"rrule":"FREQ=MONTHLY;BYDAY=1TU","start":"2024-01-01"}}`)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("POST schedule rule status = %d", res.StatusCode)
+	}
+	if res := do(t, h, http.MethodPost, "/api/v1/settings/schedule/rules", `{"data":{"state":2,"rrule":"FREQ=YEARLY","start":"2024-01-01"}}`); res.StatusCode == http.StatusOK {
+		t.Error("POST an unsupported rule should fail")
+	}
+
+	res = do(t, h, http.MethodGet, "/api/v1/state/2024", "")
+	var year model.GetYearResponse
+	if err := json.NewDecoder(res.Body).Decode(&year); err != nil {
+		t.Fatalf("decode year: %v", err)
+	}
+	if got := year.Data.Months[1].Days[2].State; got != model.StateScheduledWorkFromOffice {
+		t.Errorf("2 Jan state = %d, want scheduled office", got)
+	}
+
+	if res := do(t, h, http.MethodPut, "/api/v1/settings/schedule/rules/1", `{"data":{"state":1,"rrule":"FREQ=MONTHLY;BYDAY=1TU","start":"2024-01-01","end":"2024-03-31"}}`); res.StatusCode != http.StatusOK {
+		t.Errorf("PUT schedule rule status = %d", res.StatusCode)
+	}
+	res = do(t, h, http.MethodGet, "/api/v1/settings/schedule/rules", "")
+	if b := bodyString(t, res); !strings.Contains(b, `"end":"2024-03-31"`) || !strings.Contains(b, `"state":1`) {
+		t.Errorf("GET schedule rules body = %s", b)
+	}
+
+	if res := do(t, h, http.MethodDelete, "/api/v1/settings/schedule/rules/1", ""); res.StatusCode != http.StatusOK {
+		t.Errorf("DELETE schedule rule status = %d", res.StatusCode)
+	}
+	if rules, _ := db.GetScheduleRules(1); len(rules) != 0 {
+		t.Errorf("rules after delete = %+v", rules)
+	}
+}
+
+func TestServerScheduleHistory(t *testing.T) {
+	h, _ := newStandaloneServer(t)
+
+	if res := do(t, h, http.MethodPut, "/api/v1/settings/schedule", `{"data":{"monday":2},"effective_from":"2024-03-01"}`); res.StatusCode != http.StatusOK {
+		t.Fatalf("PUT schedule status = %d", res.StatusCode)
+	}
+	if res := do(t, h, http.MethodPut, "/api/v1/settings/schedule", `{"data":{"monday":2},"effective_from":"soon"}`); res.StatusCode == http.StatusOK {
+		t.Error("PUT schedule with an invalid effective date should fail")
+	}
+
+	res := do(t, h, http.MethodGet, "/api/v1/settings/schedule/history", "")
+	var history model.GetScheduleHistoryResponse
+	if err := json.NewDecoder(res.Body).Decode(&history); err != nil {
+		t.Fatalf("decode history: %v", err)
+	}
+	if len(history.Data) != 2 || history.Data[1].EffectiveFrom != "2024-03-01" || history.Data[1].Schedule.Monday != model.StateWorkFromOffice {
+		t.Errorf("history = %+v", history.Data)
+	}
+
+	res = do(t, h, http.MethodGet, "/api/v1/state/2024", "")
+	var year model.GetYearResponse
+	if err := json.NewDecoder(res.Body).Decode(&year); err != nil {
+		t.Fatalf("decode year: %v", err)
+	}
+	if _, ok := year.Data.Months[2].Days[26]; ok {
+		t.Error("26 February is before the schedule took effect")
+	}
+	if got := year.Data.Months[3].Days[4].State; got != model.StateScheduledWorkFromOffice {
+		t.Errorf("4 March state = %d, want scheduled office", got)
+	}
+}
+
+func TestServerNotifications(t *testing.T) {
+	h, db := newStandaloneServer(t)
+
+	if res := do(t, h, http.MethodPut, "/api/v1/settings/notifications", `{"data":{"email":"alex@example.com","daily_reminder":true}}`); res.StatusCode != http.StatusOK {
+		t.Fatalf("PUT notifications status = %d", res.StatusCode)
+	}
+	if res := do(t, h, http.MethodPut, "/api/v1/settings/notifications", `{"data":{"email":"","weekly_digest":true}}`); res.StatusCode != http.StatusBadRequest {
+		t.Errorf("PUT notifications without an email status = %d, want 400", res.StatusCode)
+	}
+	want := model.NotificationPreferences{Email: "alex@example.com", DailyReminder: true}
+	if prefs, _ := db.GetNotificationPreferences(1); prefs != want {
+		t.Errorf("preferences = %+v, want %+v", prefs, want)
+	}
+
+	res := do(t, h, http.MethodGet, "/api/v1/settings/", "")
+	var settings model.GetSettingsResponse
+	if err := json.NewDecoder(res.Body).Decode(&settings); err != nil {
+		t.Fatalf("decode settings: %v", err)
+	}
+	if settings.NotificationPreferences != want {
+		t.Errorf("settings notifications = %+v, want %+v", settings.NotificationPreferences, want)
+	}
+}
+
+func TestServerPushSubscriptions(t *testing.T) {
+	h, db := newStandaloneServer(t)
+
+	sub := `{"data":{"endpoint":"https://push.example/abc","device":"Firefox on Linux","keys":{` +
+		`"p256dh":"BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4",` +
+		`"auth":"BTBZMqHH6r4Tts7J_aSIgg"}}}`
+	res := do(t, h, http.MethodPost, "/api/v1/settings/push/subscriptions", sub)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("POST push subscription status = %d: %s", res.StatusCode, bodyString(t, res))
+	}
+	var created model.CreatePushSubscriptionResponse
+	if err := json.NewDecoder(res.Body).Decode(&created); err != nil {
+		t.Fatalf("decode subscription: %v", err)
+	}
+	if res := do(t, h, http.MethodPost, "/api/v1/settings/push/subscriptions", `{"data":{"endpoint":"http://push.example/abc"}}`); res.StatusCode != http.StatusBadRequest {
+		t.Errorf("POST invalid push subscription status = %d, want 400", res.StatusCode)
+	}
+
+	res = do(t, h, http.MethodGet, "/api/v1/settings/push/subscriptions", "")
+	if body := bodyString(t, res); !strings.Contains(body, "Firefox on Linux") || strings.Contains(body, "p256dh") {
+		t.Errorf("GET push subscriptions = %s, want the browser without its keys", body)
+	}
+	if res := do(t, h, http.MethodGet, "/settings", ""); !strings.Contains(bodyString(t, res), "Firefox on Linux") {
+		t.Error("settings page doesn't list the subscribed browser")
+	}
+
+	target := fmt.Sprintf("/api/v1/settings/push/subscriptions/%d", created.Data.ID)
+	if res := do(t, h, http.MethodDelete, target, ""); res.StatusCode != http.StatusOK {
+		t.Errorf("DELETE push subscription status = %d", res.StatusCode)
+	}
+	if res := do(t, h, http.MethodDelete, target, ""); res.StatusCode != http.StatusNotFound {
+		t.Errorf("DELETE deleted push subscription status = %d, want 404", res.StatusCode)
+	}
+	if subs, _ := db.GetPushSubscriptions(1); len(subs) != 0 {
+		t.Errorf("subscriptions = %+v, want none", subs)
+	}
+}
+
+// The mobile app registers its device, and a day its geofence records is
+// confirmed through a fake Expo endpoint.
+func TestServerPushTokens(t *testing.T) {
+	sent := make(chan string, 1)
+	expo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		sent <- string(b)
+		io.WriteString(w, `{"data":[{"status":"ok","id":"ticket"}]}`)
+	}))
+	defer expo.Close()
+	db := dbtest.New()
+	srv, err := NewServer(config.StandaloneApp{Expo: config.Expo{PushURL: expo.URL}}, db, nil, report.New(db))
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	h := srv.Handler
+
+	res := do(t, h, http.MethodPost, "/api/v1/settings/push/tokens", `{"data":{"token":"ExponentPushToken[abc]","device":"Pixel 8","check_in":true}}`)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("POST push token status = %d: %s", res.StatusCode, bodyString(t, res))
+	}
+	if res := do(t, h, http.MethodPost, "/api/v1/settings/push/tokens", `{"data":{"token":"not a token"}}`); res.StatusCode != http.StatusBadRequest {
+		t.Errorf("POST invalid push token status = %d, want 400", res.StatusCode)
+	}
+	if body := bodyString(t, do(t, h, http.MethodGet, "/api/v1/settings/push/tokens", "")); !strings.Contains(body, "Pixel 8") {
+		t.Errorf("GET push tokens = %s", body)
+	}
+
+	if res := do(t, h, http.MethodPut, "/api/v1/state/2024/5/7", `{"data":{"state":2,"source":"geofence"}}`); res.StatusCode != http.StatusOK {
+		t.Fatalf("PUT day status = %d", res.StatusCode)
+	}
+	select {
+	case body := <-sent:
+		if !strings.Contains(body, `"to":"ExponentPushToken[abc]"`) || !strings.Contains(body, "Checked in at the office") {
+			t.Errorf("sent %s", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no check-in confirmation sent")
+	}
+
+	if res := do(t, h, http.MethodDelete, "/api/v1/settings/push/tokens/1", ""); res.StatusCode != http.StatusOK {
+		t.Errorf("DELETE push token status = %d", res.StatusCode)
+	}
+	if res := do(t, h, http.MethodDelete, "/api/v1/settings/push/tokens/1", ""); res.StatusCode != http.StatusNotFound {
+		t.Errorf("DELETE deleted push token status = %d, want 404", res.StatusCode)
+	}
+}
+
+func TestServerMaterialise(t *testing.T) {
+	h, db := newStandaloneServer(t)
+	db.SaveDay(1, 4, 3, 2024, model.DayState{State: model.StateWorkFromOffice, Source: model.SourceSchedule, Unconfirmed: true})
+
+	if res := do(t, h, http.MethodPut, "/api/v1/settings/materialise", `{"data":{"mode":"unconfirmed"}}`); res.StatusCode != http.StatusOK {
+		t.Fatalf("PUT materialise status = %d", res.StatusCode)
+	}
+	if res := do(t, h, http.MethodPut, "/api/v1/settings/materialise", `{"data":{"mode":"sometimes"}}`); res.StatusCode == http.StatusOK {
+		t.Error("PUT materialise with an unknown mode should fail")
+	}
+	if prefs, _ := db.GetMaterialisePreferences(1); prefs.Mode != model.MaterialiseUnconfirmed {
+		t.Errorf("mode = %q, want unconfirmed", prefs.Mode)
+	}
+
+	res := do(t, h, http.MethodGet, "/api/v1/state/unconfirmed?date=2024-03-05", "")
+	var unconfirmed model.GetUnconfirmedDaysResponse
+	if err := json.NewDecoder(res.Body).Decode(&unconfirmed); err != nil {
+		t.Fatalf("decode unconfirmed: %v", err)
+	}
+	if len(unconfirmed.Data) != 1 || unconfirmed.Data[0].Date != "2024-03-04" {
+		t.Errorf("unconfirmed = %+v, want 4 March", unconfirmed.Data)
+	}
+
+	res = do(t, h, http.MethodPost, "/api/v1/state/confirm", `{"data":{"dates":["2024-03-04"]}}`)
+	var confirmed model.ConfirmDaysResponse
+	if err := json.NewDecoder(res.Body).Decode(&confirmed); err != nil {
+		t.Fatalf("decode confirm: %v", err)
+	}
+	if confirmed.Confirmed != 1 {
+		t.Errorf("confirmed = %d, want 1", confirmed.Confirmed)
+	}
+	if day, _ := db.GetDay(1, 4, 3, 2024); day.Unconfirmed {
+		t.Errorf("day = %+v, want it confirmed", day)
+	}
+}
+
 func TestServerReportEndpoints(t *testing.T) {
 	h, db := newStandaloneServer(t)
 	db.SaveDay(1, 2, 1, 2024, model.DayState{State: model.StateWorkFromOffice})
@@ -130,7 +762,7 @@ func TestServerReportEndpoints(t *testing.T) {
 	if ct := csv.Header.Get("Content-Type"); !strings.Contains(ct, "text/csv") {
 		t.Errorf("CSV content-type = %q", ct)
 	}
-	if b := bodyString(t, csv); !strings.HasPrefix(b, "Date,State") {
+	if b := bodyString(t, csv); !strings.HasPrefix(b, "Date,State\n") {
 		t.Errorf("CSV body = %q", b[:min(20, len(b))])
 	}
 
@@ -143,6 +775,21 @@ func TestServerReportEndpoints(t *testing.T) {
 	}
 }
 
+func TestServerReportSourceFilter(t *testing.T) {
+	h, db := newStandaloneServer(t)
+	db.SaveDay(1, 2, 1, 2024, model.DayState{State: model.StateWorkFromOffice, Source: model.SourceGeofence})
+	db.SaveDay(1, 3, 1, 2024, model.DayState{State: model.StateWorkFromOffice, Source: model.SourceManual})
+
+	res := do(t, h, http.MethodGet, "/api/v1/report/csv/2024-attendance?source=geofence&detail=true", "")
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("CSV status = %d", res.StatusCode)
+	}
+	b := bodyString(t, res)
+	if !strings.Contains(b, "2024-01-02,Office,") || !strings.Contains(b, "2024-01-03,,,,2024-W01\n") {
+		t.Errorf("filtered CSV = %q", b)
+	}
+}
+
 func TestServerStatsEndpoint(t *testing.T) {
 	h, _ := newStandaloneServer(t)
 	res := do(t, h, http.MethodGet, "/api/v1/stats", "")
@@ -152,7 +799,7 @@ func TestServerStatsEndpoint(t *testing.T) {
 }
 
 // Developer endpoints require SSO/Secret; a standalone (Excluded) session is
-// rejected with 401.
+// signed in, so it's rejected with 403.
 func TestServerDeveloperEndpointsRejectExcluded(t *testing.T) {
 	h, _ := newStandaloneServer(t)
 	for _, tc := range []struct{ method, path, body string }{
@@ -160,8 +807,8 @@ func TestServerDeveloperEndpointsRejectExcluded(t *testing.T) {
 		{http.MethodPost, "/api/v1/developer/secret", `{"data":{"name":"x"}}`},
 	} {
 		res := do(t, h, tc.method, tc.path, tc.body)
-		if res.StatusCode != http.StatusUnauthorized {
-			t.Errorf("%s %s status = %d, want 401", tc.method, tc.path, res.StatusCode)
+		if res.StatusCode != http.StatusForbidden {
+			t.Errorf("%s %s status = %d, want 403", tc.method, tc.path, res.StatusCode)
 		}
 	}
 }
@@ -170,8 +817,8 @@ func TestServerDeveloperEndpointsRejectExcluded(t *testing.T) {
 func TestServerHealthAuthRejectsExcluded(t *testing.T) {
 	h, _ := newStandaloneServer(t)
 	res := do(t, h, http.MethodGet, "/api/v1/health/auth", "")
-	if res.StatusCode != http.StatusUnauthorized {
-		t.Errorf("health/auth status = %d, want 401", res.StatusCode)
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("health/auth status = %d, want 403", res.StatusCode)
 	}
 }
 
@@ -195,10 +842,30 @@ func TestServerIndexRedirect(t *testing.T) {
 	}
 }
 
+// The index opens on the current month in the user's timezone, which can be a
+// day either side of the server's.
+func TestServerIndexRedirectTimezone(t *testing.T) {
+	h, _ := newStandaloneServer(t)
+	if res := do(t, h, http.MethodPut, "/api/v1/settings/calendar", `{"data":{"tracking_year_start_month":10,"timezone":"Mars/Olympus"}}`); res.StatusCode != http.StatusBadRequest {
+		t.Errorf("PUT calendar with an unknown timezone status = %d, want 400", res.StatusCode)
+	}
+	for _, zone := range []string{"Pacific/Kiritimati", "Etc/GMT+12"} {
+		body := `{"data":{"tracking_year_start_month":10,"timezone":"` + zone + `"}}`
+		if res := do(t, h, http.MethodPut, "/api/v1/settings/calendar", body); res.StatusCode != http.StatusOK {
+			t.Fatalf("PUT calendar status = %d", res.StatusCode)
+		}
+		tz, _ := time.LoadLocation(zone)
+		want := "/" + time.Now().In(tz).Format("2006-01")
+		if loc := do(t, h, http.MethodGet, "/", "").Header.Get("Location"); loc != want {
+			t.Errorf("%s: index redirect = %q, want %q", zone, loc, want)
+		}
+	}
+}
+
 // HTML pages render in standalone mode.
 func TestServerHTMLPages(t *testing.T) {
 	h, _ := newStandaloneServer(t)
-	for _, path := range []string{"/2024-03", "/settings", "/stats"} {
+	for _, path := range []string{"/2024-03", "/settings", "/stats", "/developer"} {
 		res := do(t, h, http.MethodGet, path, "")
 		if res.StatusCode != http.StatusOK {
 			t.Errorf("GET %s status = %d, want 200", path, res.StatusCode)
@@ -219,4 +886,9 @@ func TestServerStaticAssets(t *testing.T) {
 	if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != "text/css" {
 		t.Errorf("themes.css = %d %q", res.StatusCode, res.Header.Get("Content-Type"))
 	}
+	res = do(t, h, http.MethodGet, "/sw.js", "")
+	if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != "text/javascript" ||
+		!strings.Contains(bodyString(t, res), "showNotification") {
+		t.Errorf("sw.js = %d %q", res.StatusCode, res.Header.Get("Content-Type"))
+	}
 }
@@ -5,10 +5,12 @@ import (
 	"html/template"
 	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/baely/officetracker/internal/auth"
 	"github.com/baely/officetracker/internal/embed"
+	"github.com/baely/officetracker/internal/report"
 	"github.com/baely/officetracker/internal/util"
 	"github.com/baely/officetracker/pkg/model"
 )
@@ -23,7 +25,9 @@ type formPage struct {
 	YearlyState        template.JS
 	YearlyNotes        template.JS
 	TrackingStartMonth int
-	TargetPercent      int
+	Target             template.JS
+	CustomStates       template.JS
+	Locations          template.JS
 }
 
 func serveForm(w http.ResponseWriter, r *http.Request, page formPage) {
@@ -48,12 +52,67 @@ func serveHero(w http.ResponseWriter, r *http.Request, page heroPage) {
 
 type settingsPage struct {
 	basePage
-	LinkedAccounts      []model.LinkedAccount
-	Auth0AuthURL        string
-	ThemePreferences    model.ThemePreferences
-	SchedulePreferences model.SchedulePreferences
-	CalendarPreferences model.CalendarPreferences
-	TargetPreferences   model.TargetPreferences
+	LinkedAccounts         []model.LinkedAccount
+	Auth0AuthURL           string
+	ThemePreferences       model.ThemePreferences
+	SchedulePreferences    model.SchedulePreferences
+	CalendarPreferences    model.CalendarPreferences
+	TargetPreferences      model.TargetPreferences
+	CustomStates           model.CustomStates
+	Locations              model.Locations
+	NetworkRules           model.NetworkRules
+	ScheduleRules          model.ScheduleRules
+	ScheduleVersions       []scheduleVersionRow
+	MaterialisePreferences model.MaterialisePreferences
+	// NotificationPreferences are the user's emails. EmailAvailable is false
+	// when the server has no mail server to send them with.
+	NotificationPreferences model.NotificationPreferences
+	EmailAvailable          bool
+	// PushKey is the server's VAPID public key, empty when push notifications
+	// aren't set up. PushSubscriptions are the browsers subscribed to them.
+	PushKey           string
+	PushSubscriptions model.PushSubscriptions
+}
+
+type scheduleVersionRow struct {
+	From string
+	Days string
+}
+
+// buildScheduleVersions lists schedule versions newest first, describing each
+// by its scheduled weekdays, e.g. "Mon office, Fri home".
+func buildScheduleVersions(history model.ScheduleHistory) []scheduleVersionRow {
+	names := map[model.State]string{
+		model.StateWorkFromHome:   "home",
+		model.StateWorkFromOffice: "office",
+		model.StateOther:          "other",
+	}
+	rows := make([]scheduleVersionRow, 0, len(history))
+	for i := len(history) - 1; i >= 0; i-- {
+		v := history[i]
+		s := v.Schedule
+		var days []string
+		for _, d := range []struct {
+			name  string
+			state model.State
+		}{
+			{"Mon", s.Monday}, {"Tue", s.Tuesday}, {"Wed", s.Wednesday}, {"Thu", s.Thursday},
+			{"Fri", s.Friday}, {"Sat", s.Saturday}, {"Sun", s.Sunday},
+		} {
+			if name, ok := names[d.state]; ok {
+				days = append(days, d.name+" "+name)
+			}
+		}
+		row := scheduleVersionRow{From: v.EffectiveFrom, Days: strings.Join(days, ", ")}
+		if row.From == "" {
+			row.From = "Earlier"
+		}
+		if row.Days == "" {
+			row.Days = "Nothing scheduled"
+		}
+		rows = append(rows, row)
+	}
+	return rows
 }
 
 func serveSettings(w http.ResponseWriter, r *http.Request, page settingsPage) {
@@ -107,11 +166,22 @@ type reportRow struct {
 	Percent string
 }
 
+type locationRow struct {
+	Name    string
+	Days    int
+	Percent string
+}
+
 type reportPage struct {
 	basePage
-	Year     int
-	Rows     []reportRow
-	Headline string
+	Year      int
+	Rows      []reportRow
+	Locations []locationRow
+	Headline  string
+	// Target and Compliance describe the attendance target and progress in
+	// its window; both are empty when no target is set.
+	Target     string
+	Compliance string
 }
 
 func serveReport(w http.ResponseWriter, r *http.Request, page reportPage) {
@@ -125,13 +195,16 @@ func serveReport(w http.ResponseWriter, r *http.Request, page reportPage) {
 // buildReportSummary computes the per-month attendance breakdown for a tracking
 // year, mirroring how the form page's summary counted days: "present" is office
 // days (actual + scheduled), "total" is all work days (WFH + office, actual +
-// scheduled). Months with no work days are omitted.
-func buildReportSummary(state model.YearState, year, startMonth int) ([]reportRow, string) {
+// scheduled). Custom states count according to their configured attendance.
+// Months with no work days are omitted. Office days are also broken down by
+// location, which is empty when no day in the year has a location.
+func buildReportSummary(state model.YearState, customStates model.CustomStates, locations model.Locations, year, startMonth int) ([]reportRow, []locationRow, string) {
 	startMonth = util.NormaliseStartMonth(startMonth)
 	firstYear, secondYear := util.TrackingYearCalendarYears(year, startMonth)
 
 	var rows []reportRow
 	var totalPresent, totalDays int
+	counter := report.NewLocationCounter(customStates, locations)
 	for offset := 0; offset < 12; offset++ {
 		month := (startMonth-1+offset)%12 + 1
 		monthYear := secondYear
@@ -141,11 +214,12 @@ func buildReportSummary(state model.YearState, year, startMonth int) ([]reportRo
 
 		var present, total int
 		for _, day := range state.Months[month].Days {
-			switch day.State {
-			case model.StateWorkFromOffice, model.StateScheduledWorkFromOffice:
+			counter.Add(day)
+			switch customStates.Attendance(day.State) {
+			case model.AttendancePresent:
 				present++
 				total++
-			case model.StateWorkFromHome, model.StateScheduledWorkFromHome:
+			case model.AttendanceAbsent:
 				total++
 			}
 		}
@@ -167,7 +241,59 @@ func buildReportSummary(state model.YearState, year, startMonth int) ([]reportRo
 		percent = float64(totalPresent) / float64(totalDays) * 100
 	}
 	headline := fmt.Sprintf("Present in office for %d out of %d days. (%.2f%%)", totalPresent, totalDays, percent)
-	return rows, headline
+
+	var locationRows []locationRow
+	for _, count := range counter.Counts() {
+		locationRows = append(locationRows, locationRow{
+			Name:    count.Name,
+			Days:    count.Days,
+			Percent: fmt.Sprintf("%.2f%%", float64(count.Days)/float64(totalPresent)*100),
+		})
+	}
+	return rows, locationRows, headline
+}
+
+type developerPage struct {
+	basePage
+}
+
+func serveDeveloper(w http.ResponseWriter, r *http.Request, page developerPage) {
+	page.basePage = getBasePageData(r)
+	if err := embed.Developer.Execute(w, page); err != nil {
+		err = fmt.Errorf("failed to execute developer template: %w", err)
+		errorPage(w, r, err, internalErrorMsg, http.StatusInternalServerError)
+	}
+}
+
+type chatLinkPage struct {
+	basePage
+	Account model.ChatAccount
+	Code    string
+	Linked  bool
+}
+
+// PlatformName is the chat platform as users know it.
+func (p chatLinkPage) PlatformName() string {
+	if p.Account.Platform == model.ChatPlatformTeams {
+		return "Microsoft Teams"
+	}
+	return "Slack"
+}
+
+// TeamLabel is what the platform calls the group the account belongs to.
+func (p chatLinkPage) TeamLabel() string {
+	if p.Account.Platform == model.ChatPlatformTeams {
+		return "tenant"
+	}
+	return "workspace"
+}
+
+func serveChatLink(w http.ResponseWriter, r *http.Request, page chatLinkPage) {
+	page.basePage = getBasePageData(r)
+	if err := embed.ChatLink.Execute(w, page); err != nil {
+		err = fmt.Errorf("failed to execute chat link template: %w", err)
+		errorPage(w, r, err, internalErrorMsg, http.StatusInternalServerError)
+	}
 }
 
 type statWidgetGroup struct {
@@ -206,9 +332,9 @@ func groupStatWidgets(widgets []model.StatWidget) []statWidgetGroup {
 	return groups
 }
 
-// formatLastUpdated renders the snapshot timestamp for display, or a fallback
-// when no snapshot exists yet.
-func formatLastUpdated(computedAt string) string {
+// formatLastUpdated renders the snapshot timestamp for display in loc, or a
+// fallback when no snapshot exists yet.
+func formatLastUpdated(computedAt string, loc *time.Location) string {
 	if computedAt == "" {
 		return ""
 	}
@@ -216,11 +342,14 @@ func formatLastUpdated(computedAt string) string {
 	if err != nil {
 		return computedAt
 	}
-	// Render in Melbourne time. The container runs in UTC, so t.Local() would
-	// show UTC; fall back to UTC only if the tz database is unavailable.
-	loc, err := time.LoadLocation("Australia/Melbourne")
-	if err != nil {
-		loc = time.UTC
+	if loc == nil {
+		// Without a viewer's timezone, render in Melbourne time. The container
+		// runs in UTC, so t.Local() would show UTC; fall back to UTC only if
+		// the tz database is unavailable.
+		loc, err = time.LoadLocation("Australia/Melbourne")
+		if err != nil {
+			loc = time.UTC
+		}
 	}
 	return t.In(loc).Format("2 Jan 2006, 3:04 PM MST")
 }
@@ -2,8 +2,10 @@ package server
 
 import (
 	"io"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/baely/officetracker/internal/embed"
 	"github.com/baely/officetracker/pkg/model"
@@ -39,3 +41,187 @@ func TestSettingsTemplateRendersCalendarPreference(t *testing.T) {
 		t.Fatalf("failed to execute settings template: %v", err)
 	}
 }
+
+// TestSettingsTemplateRendersCustomStates ensures each custom state is listed
+// with its attendance selected and archived states lose their archive button.
+func TestSettingsTemplateRendersCustomStates(t *testing.T) {
+	var buf strings.Builder
+	err := embed.Settings.Execute(&buf, settingsPage{
+		CustomStates: model.CustomStates{
+			{ID: 100, Name: "Client site", Color: "#ff9800", Attendance: model.AttendancePresent},
+			{ID: 101, Name: "Leave", Color: "#9e9e9e", Attendance: model.AttendanceExcluded, Archived: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to execute settings template: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{`data-state-id="100"`, `value="Client site"`, `value="excluded" selected`, `custom-state-row archived`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("rendered settings missing %q", want)
+		}
+	}
+	if n := strings.Count(out, `class="archive-state-btn"`); n != 1 {
+		t.Errorf("archive buttons = %d, want 1", n)
+	}
+}
+
+// TestSettingsTemplateRendersLocations ensures each location is listed with its
+// optional coordinates and archived locations lose their archive button.
+func TestSettingsTemplateRendersLocations(t *testing.T) {
+	lat, lng := -37.8136, 144.9631
+	var buf strings.Builder
+	err := embed.Settings.Execute(&buf, settingsPage{
+		Locations: model.Locations{
+			{ID: 1, Name: "Melbourne", Latitude: &lat, Longitude: &lng, Radius: 150},
+			{ID: 2, Name: "Sydney", Archived: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to execute settings template: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{`data-location-id="1"`, `value="Melbourne"`, `value="-37.8136"`, `value="144.9631"`, `value="150"`, `location-row archived`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("rendered settings missing %q", want)
+		}
+	}
+	if strings.Contains(out, "&lt;nil&gt;") || strings.Contains(out, "<nil>") {
+		t.Errorf("rendered settings shows a nil coordinate")
+	}
+	if n := strings.Count(out, `class="archive-location-btn"`); n != 1 {
+		t.Errorf("archive buttons = %d, want 1", n)
+	}
+}
+
+// TestSettingsTemplateRendersNetworkRules ensures each network is listed with
+// its kind and location selected, and archived locations are only offered to
+// the rules that already use them.
+func TestSettingsTemplateRendersNetworkRules(t *testing.T) {
+	var buf strings.Builder
+	err := embed.Settings.Execute(&buf, settingsPage{
+		Locations: model.Locations{
+			{ID: 1, Name: "Melbourne"},
+			{ID: 2, Name: "Sydney", Archived: true},
+		},
+		NetworkRules: model.NetworkRules{
+			{ID: 4, Kind: model.NetworkRuleCIDR, Value: "203.0.113.0/24", LocationID: 2},
+			{ID: 5, Kind: model.NetworkRuleSSID, Value: "Office Wi-Fi"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to execute settings template: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{`data-network-rule-id="4"`, `value="203.0.113.0/24"`, `<option value="cidr" selected>`, `<option value="2" selected>Sydney</option>`, `value="Office Wi-Fi"`, `<option value="ssid" selected>`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("rendered settings missing %q", want)
+		}
+	}
+	if n := strings.Count(out, `>Sydney</option>`); n != 1 {
+		t.Errorf("archived location offered %d times, want once", n)
+	}
+	if n := strings.Count(out, `>Melbourne</option>`); n != 3 {
+		t.Errorf("active location offered %d times, want 3", n)
+	}
+}
+
+// TestSettingsTemplateRendersScheduleRules ensures each rule is listed with its
+// state selected and its dates filled in.
+func TestSettingsTemplateRendersScheduleRules(t *testing.T) {
+	var buf strings.Builder
+	err := embed.Settings.Execute(&buf, settingsPage{
+		ScheduleRules: model.ScheduleRules{
+			{ID: 3, State: model.StateWorkFromHome, RRule: "FREQ=MONTHLY;BYDAY=-1FR", Start: "2024-01-01", End: "2024-06-30"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to execute settings template: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{`data-rule-id="3"`, `value="FREQ=MONTHLY;BYDAY=-1FR"`, `value="2024-01-01"`, `value="2024-06-30"`, `<option value="1" selected>`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("rendered settings missing %q", want)
+		}
+	}
+}
+
+func TestSettingsTemplateRendersMaterialiseMode(t *testing.T) {
+	var buf strings.Builder
+	err := embed.Settings.Execute(&buf, settingsPage{
+		MaterialisePreferences: model.MaterialisePreferences{Mode: model.MaterialiseUnconfirmed},
+	})
+	if err != nil {
+		t.Fatalf("failed to execute settings template: %v", err)
+	}
+	if want := `<option value="unconfirmed" selected>`; !strings.Contains(buf.String(), want) {
+		t.Errorf("rendered settings missing %q", want)
+	}
+}
+
+func TestSettingsTemplateRendersNotifications(t *testing.T) {
+	var buf strings.Builder
+	err := embed.Settings.Execute(&buf, settingsPage{
+		NotificationPreferences: model.NotificationPreferences{Email: "alex@example.com", WeeklyDigest: true},
+	})
+	if err != nil {
+		t.Fatalf("failed to execute settings template: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{`value="alex@example.com"`, `id="weekly-digest" checked`, "doesn't have a mail server"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("rendered settings missing %q", want)
+		}
+	}
+	if strings.Contains(out, `id="daily-reminder" checked`) {
+		t.Error("rendered the daily reminder as on")
+	}
+	if !strings.Contains(out, "doesn't have push notifications set up") || strings.Contains(out, `id="push-subscribe-btn"`) {
+		t.Error("rendered push notifications as available")
+	}
+}
+
+func TestSettingsTemplateRendersPush(t *testing.T) {
+	var buf strings.Builder
+	err := embed.Settings.Execute(&buf, settingsPage{
+		NotificationPreferences: model.NotificationPreferences{PushTargetWarning: true},
+		PushKey:                 "BPub",
+		PushSubscriptions: model.PushSubscriptions{
+			{ID: 3, Endpoint: "https://push.example/abc", Device: "Firefox on Linux", CreatedAt: time.Date(2024, time.May, 7, 9, 0, 0, 0, time.UTC)},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to execute settings template: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{`data-key="BPub"`, `id="push-target-warning" checked`, `data-subscription-id="3"`, "Firefox on Linux, added 7 May 2024"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("rendered settings missing %q", want)
+		}
+	}
+	if strings.Contains(out, `id="push-reminder" checked`) {
+		t.Error("rendered the push reminder as on")
+	}
+}
+
+func TestBuildScheduleVersions(t *testing.T) {
+	rows := buildScheduleVersions(model.ScheduleHistory{
+		{},
+		{EffectiveFrom: "2024-03-01", Schedule: model.SchedulePreferences{Monday: model.StateWorkFromOffice, Friday: model.StateWorkFromHome}},
+	})
+	want := []scheduleVersionRow{
+		{From: "2024-03-01", Days: "Mon office, Fri home"},
+		{From: "Earlier", Days: "Nothing scheduled"},
+	}
+	if !slices.Equal(rows, want) {
+		t.Errorf("buildScheduleVersions = %+v, want %+v", rows, want)
+	}
+
+	var buf strings.Builder
+	if err := embed.Settings.Execute(&buf, settingsPage{ScheduleVersions: rows}); err != nil {
+		t.Fatalf("failed to execute settings template: %v", err)
+	}
+	if out := buf.String(); !strings.Contains(out, "Mon office, Fri home") || !strings.Contains(out, `id="schedule-effective-from"`) {
+		t.Errorf("rendered settings missing the schedule history")
+	}
+}
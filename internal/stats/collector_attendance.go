@@ -34,7 +34,8 @@ func (c TrackedDaysCollector) Collect(_ context.Context) ([]model.StatWidget, er
 
 // AverageOfficeAttendanceCollector reports the share of home/office days spent
 // in the office: office / (home + office). "Other" days are excluded as they
-// don't represent a home-vs-office choice. Aggregate only.
+// don't represent a home-vs-office choice. Custom states count as office or
+// home according to their configured attendance. Aggregate only.
 type AverageOfficeAttendanceCollector struct {
 	DB database.Databaser
 }
@@ -47,8 +48,13 @@ func (c AverageOfficeAttendanceCollector) Collect(_ context.Context) ([]model.St
 		return nil, fmt.Errorf("count entries by state: %w", err)
 	}
 
-	home := counts[model.StateWorkFromHome]
-	office := counts[model.StateWorkFromOffice]
+	custom, err := c.DB.CountCustomEntriesByAttendance()
+	if err != nil {
+		return nil, fmt.Errorf("count custom entries by attendance: %w", err)
+	}
+
+	home := counts[model.StateWorkFromHome] + custom[model.AttendanceAbsent]
+	office := counts[model.StateWorkFromOffice] + custom[model.AttendancePresent]
 	if home+office == 0 {
 		return nil, nil
 	}
@@ -121,6 +121,27 @@ func TestAverageOfficeAttendanceCollector(t *testing.T) {
 	}
 }
 
+// Custom states count as office or home according to their attendance.
+func TestAverageOfficeAttendanceCollectorCustomStates(t *testing.T) {
+	db := dbtest.New()
+	site, _ := db.SaveCustomState(1, model.CustomState{Name: "Client site", Attendance: model.AttendancePresent})
+	leave, _ := db.SaveCustomState(1, model.CustomState{Name: "Leave", Attendance: model.AttendanceExcluded})
+	// 1 office + 1 client site, 2 home, 1 leave (excluded) -> 50%.
+	db.SaveDay(1, 1, 1, 2024, model.DayState{State: model.StateWorkFromOffice})
+	db.SaveDay(1, 2, 1, 2024, model.DayState{State: site.ID})
+	db.SaveDay(1, 3, 1, 2024, model.DayState{State: model.StateWorkFromHome})
+	db.SaveDay(1, 4, 1, 2024, model.DayState{State: model.StateWorkFromHome})
+	db.SaveDay(1, 5, 1, 2024, model.DayState{State: leave.ID})
+
+	widgets, err := AverageOfficeAttendanceCollector{DB: db}.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if w, _ := widgetByKey(widgets, "avg_office_attendance"); w.Value != "50" {
+		t.Errorf("office attendance = %q, want 50", w.Value)
+	}
+}
+
 func TestFixedCostCollector(t *testing.T) {
 	widgets, err := FixedCostCollector{Config: FixedCostConfig{Supabase: 25, Redis: 5, Auth0: 0}}.Collect(context.Background())
 	if err != nil {
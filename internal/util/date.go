@@ -0,0 +1,21 @@
+package util
+
+import (
+	"fmt"
+	"time"
+)
+
+// ValidDate reports an error unless year, month and day name a real calendar
+// date, so 31 February or month 13 are rejected rather than stored.
+func ValidDate(year, month, day int) error {
+	if month < 1 || month > 12 {
+		return fmt.Errorf("invalid month %d", month)
+	}
+	if year < 1 || year > 9999 {
+		return fmt.Errorf("invalid year %d", year)
+	}
+	if last := time.Date(year, time.Month(month)+1, 0, 0, 0, 0, 0, time.UTC).Day(); day < 1 || day > last {
+		return fmt.Errorf("invalid date %04d-%02d-%02d", year, month, day)
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+package util
+
+import "testing"
+
+func TestValidDate(t *testing.T) {
+	for _, d := range [][3]int{{2024, 2, 29}, {2023, 12, 31}, {2024, 1, 1}} {
+		if err := ValidDate(d[0], d[1], d[2]); err != nil {
+			t.Errorf("ValidDate(%v) = %v, want nil", d, err)
+		}
+	}
+	for _, d := range [][3]int{{2023, 2, 29}, {2024, 2, 31}, {2024, 4, 31}, {2024, 13, 1}, {2024, 0, 1}, {2024, 1, 0}, {0, 1, 1}} {
+		if err := ValidDate(d[0], d[1], d[2]); err == nil {
+			t.Errorf("ValidDate(%v) = nil, want an error", d)
+		}
+	}
+}
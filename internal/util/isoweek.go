@@ -0,0 +1,41 @@
+package util
+
+import (
+	"fmt"
+	"time"
+)
+
+// ISO week helpers.
+//
+// ISO 8601 weeks run Monday to Sunday and belong to the year their Thursday
+// falls in, so 30 December 2024 is in 2025-W01. A year has 52 or 53 weeks.
+
+// ISOWeekLabel formats the ISO week t falls in, e.g. "2024-W10".
+func ISOWeekLabel(t time.Time) string {
+	year, week := t.ISOWeek()
+	return fmt.Sprintf("%04d-W%02d", year, week)
+}
+
+// ISOWeeksInYear returns how many ISO weeks year has (52 or 53).
+func ISOWeeksInYear(year int) int {
+	// 28 December is always in the last week of its ISO year.
+	_, week := time.Date(year, time.December, 28, 0, 0, 0, 0, time.UTC).ISOWeek()
+	return week
+}
+
+// ISOWeekStart returns midnight in loc on the Monday that starts ISO week
+// week of year, or an error when the year has no such week.
+func ISOWeekStart(year, week int, loc *time.Location) (time.Time, error) {
+	if week < 1 || week > ISOWeeksInYear(year) {
+		return time.Time{}, fmt.Errorf("%04d has no week %d", year, week)
+	}
+	// 4 January is always in week 1.
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, loc)
+	offset := (int(jan4.Weekday()) + 6) % 7 // days since Monday
+	return jan4.AddDate(0, 0, (week-1)*7-offset), nil
+}
+
+// ISOWeekday numbers t's weekday from 1 (Monday) to 7 (Sunday).
+func ISOWeekday(t time.Time) int {
+	return (int(t.Weekday())+6)%7 + 1
+}
@@ -0,0 +1,62 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestISOWeekLabel(t *testing.T) {
+	cases := map[time.Time]string{
+		day(2024, 3, 4):   "2024-W10",
+		day(2024, 12, 30): "2025-W01",
+		day(2021, 1, 3):   "2020-W53",
+	}
+	for d, want := range cases {
+		if got := ISOWeekLabel(d); got != want {
+			t.Errorf("ISOWeekLabel(%s) = %s, want %s", d.Format("2006-01-02"), got, want)
+		}
+	}
+}
+
+func TestISOWeeksInYear(t *testing.T) {
+	for year, want := range map[int]int{2020: 53, 2024: 52, 2026: 53} {
+		if got := ISOWeeksInYear(year); got != want {
+			t.Errorf("ISOWeeksInYear(%d) = %d, want %d", year, got, want)
+		}
+	}
+}
+
+func TestISOWeekStart(t *testing.T) {
+	cases := []struct {
+		year, week int
+		want       time.Time
+	}{
+		{2024, 10, day(2024, 3, 4)},
+		{2025, 1, day(2024, 12, 30)},
+		{2020, 53, day(2020, 12, 28)},
+		{2026, 1, day(2025, 12, 29)},
+	}
+	for _, c := range cases {
+		got, err := ISOWeekStart(c.year, c.week, time.UTC)
+		if err != nil || !got.Equal(c.want) {
+			t.Errorf("ISOWeekStart(%d, %d) = (%v, %v), want %v", c.year, c.week, got, err, c.want)
+		}
+		if y, w := got.ISOWeek(); y != c.year || w != c.week {
+			t.Errorf("ISOWeekStart(%d, %d) is in %d-W%d", c.year, c.week, y, w)
+		}
+	}
+	for _, c := range [][2]int{{2024, 0}, {2024, 53}, {2020, 54}} {
+		if _, err := ISOWeekStart(c[0], c[1], time.UTC); err == nil {
+			t.Errorf("ISOWeekStart(%d, %d) should fail", c[0], c[1])
+		}
+	}
+}
+
+func TestISOWeekday(t *testing.T) {
+	if got := ISOWeekday(day(2024, 3, 4)); got != 1 {
+		t.Errorf("Monday = %d, want 1", got)
+	}
+	if got := ISOWeekday(day(2024, 3, 10)); got != 7 {
+		t.Errorf("Sunday = %d, want 7", got)
+	}
+}
@@ -0,0 +1,343 @@
+package util
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/baely/officetracker/pkg/model"
+)
+
+// Recurrence rules.
+//
+// Schedule rules use a subset of RFC 5545 RRULE syntax, e.g.
+// "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE" (every other Monday and Wednesday) or
+// "FREQ=MONTHLY;BYDAY=1MO" (the first Monday of each month). Supported parts
+// are FREQ (DAILY, WEEKLY or MONTHLY), INTERVAL, BYDAY (with an ordinal such
+// as 1MO or -1FR for monthly rules) and BYMONTHDAY. A rule's start date plays
+// the part of DTSTART: intervals count from it, and weeks start on Monday.
+
+// Frequency is how often a recurrence repeats.
+type Frequency string
+
+const (
+	FrequencyDaily   = Frequency("DAILY")
+	FrequencyWeekly  = Frequency("WEEKLY")
+	FrequencyMonthly = Frequency("MONTHLY")
+)
+
+// WeekdayNum is a BYDAY entry. N is the ordinal within the month for monthly
+// rules (1 = first, -1 = last), or 0 for every such weekday.
+type WeekdayNum struct {
+	N       int
+	Weekday time.Weekday
+}
+
+// Recurrence is a parsed recurrence rule.
+type Recurrence struct {
+	Freq       Frequency
+	Interval   int
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+}
+
+var weekdayCodes = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// ParseRecurrence parses an RRULE string. An "RRULE:" prefix is allowed.
+func ParseRecurrence(rule string) (Recurrence, error) {
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	r := Recurrence{Interval: 1}
+	for _, part := range strings.Split(rule, ";") {
+		if part == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return Recurrence{}, fmt.Errorf("malformed rule part %q", part)
+		}
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			r.Freq = Frequency(strings.ToUpper(value))
+			if r.Freq != FrequencyDaily && r.Freq != FrequencyWeekly && r.Freq != FrequencyMonthly {
+				return Recurrence{}, fmt.Errorf("unsupported FREQ %q", value)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return Recurrence{}, fmt.Errorf("INTERVAL must be a positive number, got %q", value)
+			}
+			r.Interval = n
+		case "BYDAY":
+			for _, code := range strings.Split(strings.ToUpper(value), ",") {
+				day, err := parseWeekdayNum(code)
+				if err != nil {
+					return Recurrence{}, err
+				}
+				r.ByDay = append(r.ByDay, day)
+			}
+		case "BYMONTHDAY":
+			for _, v := range strings.Split(value, ",") {
+				n, err := strconv.Atoi(v)
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return Recurrence{}, fmt.Errorf("invalid BYMONTHDAY %q", v)
+				}
+				r.ByMonthDay = append(r.ByMonthDay, n)
+			}
+		default:
+			return Recurrence{}, fmt.Errorf("unsupported rule part %q", name)
+		}
+	}
+
+	if r.Freq == "" {
+		return Recurrence{}, fmt.Errorf("FREQ is required")
+	}
+	if r.Freq != FrequencyMonthly {
+		if len(r.ByMonthDay) > 0 {
+			return Recurrence{}, fmt.Errorf("BYMONTHDAY is only supported for monthly rules")
+		}
+		for _, day := range r.ByDay {
+			if day.N != 0 {
+				return Recurrence{}, fmt.Errorf("BYDAY ordinals are only supported for monthly rules")
+			}
+		}
+	}
+	return r, nil
+}
+
+// parseWeekdayNum parses a BYDAY entry such as "MO", "2TU" or "-1FR".
+func parseWeekdayNum(code string) (WeekdayNum, error) {
+	code = strings.TrimSpace(code)
+	if len(code) < 2 {
+		return WeekdayNum{}, fmt.Errorf("invalid BYDAY %q", code)
+	}
+	weekday, ok := weekdayCodes[code[len(code)-2:]]
+	if !ok {
+		return WeekdayNum{}, fmt.Errorf("invalid BYDAY %q", code)
+	}
+	var n int
+	if prefix := code[:len(code)-2]; prefix != "" {
+		var err error
+		n, err = strconv.Atoi(prefix)
+		if err != nil || n == 0 || n < -5 || n > 5 {
+			return WeekdayNum{}, fmt.Errorf("invalid BYDAY %q", code)
+		}
+	}
+	return WeekdayNum{N: n, Weekday: weekday}, nil
+}
+
+// Matches reports whether day is an occurrence of r starting from start. Only
+// the dates matter; days before start never match.
+func (r Recurrence) Matches(start, day time.Time) bool {
+	start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	if day.Before(start) {
+		return false
+	}
+	interval := max(r.Interval, 1)
+
+	switch r.Freq {
+	case FrequencyDaily:
+		days := int(day.Sub(start).Hours() / 24)
+		return days%interval == 0 && r.matchesWeekday(day)
+	case FrequencyWeekly:
+		weeks := int(weekStart(day).Sub(weekStart(start)).Hours() / 24 / 7)
+		if weeks%interval != 0 {
+			return false
+		}
+		if len(r.ByDay) == 0 {
+			return day.Weekday() == start.Weekday()
+		}
+		return r.matchesWeekday(day)
+	case FrequencyMonthly:
+		months := (day.Year()-start.Year())*12 + int(day.Month()) - int(start.Month())
+		if months%interval != 0 {
+			return false
+		}
+		if len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 {
+			return day.Day() == start.Day()
+		}
+		return (len(r.ByDay) == 0 || r.matchesMonthWeekday(day)) &&
+			(len(r.ByMonthDay) == 0 || r.matchesMonthDay(day))
+	}
+	return false
+}
+
+// matchesWeekday reports whether day falls on one of the BYDAY weekdays, or
+// true when there are none.
+func (r Recurrence) matchesWeekday(day time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, d := range r.ByDay {
+		if d.Weekday == day.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesMonthWeekday checks BYDAY within the month, honouring ordinals.
+func (r Recurrence) matchesMonthWeekday(day time.Time) bool {
+	daysInMonth := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	fromStart := (day.Day()-1)/7 + 1
+	fromEnd := -((daysInMonth-day.Day())/7 + 1)
+	for _, d := range r.ByDay {
+		if d.Weekday != day.Weekday() {
+			continue
+		}
+		if d.N == 0 || d.N == fromStart || d.N == fromEnd {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesMonthDay checks BYMONTHDAY, where negative days count from the end of
+// the month.
+func (r Recurrence) matchesMonthDay(day time.Time) bool {
+	daysInMonth := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	for _, n := range r.ByMonthDay {
+		if n == day.Day() || n < 0 && daysInMonth+n+1 == day.Day() {
+			return true
+		}
+	}
+	return false
+}
+
+// weekStart returns the Monday on or before day.
+func weekStart(day time.Time) time.Time {
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+// Schedule works out the state scheduled for a day from the weekly template
+// in force on that day and any recurrence rules. Rules take precedence over
+// the template, and later rules over earlier ones, so a rule can add days,
+// change them or clear them with the untracked state.
+type Schedule struct {
+	weekly   model.SchedulePreferences
+	versions []scheduleVersion
+	rules    []scheduleRule
+}
+
+type scheduleVersion struct {
+	from   time.Time
+	weekly model.SchedulePreferences
+}
+
+type scheduleRule struct {
+	state      model.State
+	recurrence Recurrence
+	start, end time.Time
+}
+
+// NewSchedule builds a schedule. weekly applies to every day when there is no
+// history, and otherwise to days before the first version. Versions and rules
+// that don't parse are skipped; they are validated when saved.
+func NewSchedule(weekly model.SchedulePreferences, history model.ScheduleHistory, rules model.ScheduleRules) Schedule {
+	s := Schedule{weekly: weekly}
+	for _, version := range history {
+		var from time.Time
+		if version.EffectiveFrom != "" {
+			var err error
+			from, err = time.Parse("2006-01-02", version.EffectiveFrom)
+			if err != nil {
+				continue
+			}
+		}
+		s.versions = append(s.versions, scheduleVersion{from: from, weekly: version.Schedule})
+	}
+	slices.SortStableFunc(s.versions, func(a, b scheduleVersion) int {
+		return a.from.Compare(b.from)
+	})
+	for _, rule := range rules {
+		parsed, start, end, err := ParseScheduleRule(rule)
+		if err != nil {
+			continue
+		}
+		s.rules = append(s.rules, scheduleRule{state: rule.State, recurrence: parsed, start: start, end: end})
+	}
+	return s
+}
+
+// ParseScheduleRule parses a rule's recurrence and effective dates. end is
+// zero when the rule has no end date.
+func ParseScheduleRule(rule model.ScheduleRule) (r Recurrence, start, end time.Time, err error) {
+	r, err = ParseRecurrence(rule.RRule)
+	if err != nil {
+		return Recurrence{}, time.Time{}, time.Time{}, err
+	}
+	start, err = time.Parse("2006-01-02", rule.Start)
+	if err != nil {
+		return Recurrence{}, time.Time{}, time.Time{}, fmt.Errorf("invalid start date %q", rule.Start)
+	}
+	if rule.End != "" {
+		end, err = time.Parse("2006-01-02", rule.End)
+		if err != nil {
+			return Recurrence{}, time.Time{}, time.Time{}, fmt.Errorf("invalid end date %q", rule.End)
+		}
+		if end.Before(start) {
+			return Recurrence{}, time.Time{}, time.Time{}, fmt.Errorf("end date %s is before start date %s", rule.End, rule.Start)
+		}
+	}
+	return r, start, end, nil
+}
+
+// State returns the state scheduled for day, which is untracked when nothing
+// is scheduled.
+func (s Schedule) State(day time.Time) model.State {
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	for i := len(s.rules) - 1; i >= 0; i-- {
+		rule := s.rules[i]
+		if !rule.end.IsZero() && day.After(rule.end) {
+			continue
+		}
+		if rule.recurrence.Matches(rule.start, day) {
+			return rule.state
+		}
+	}
+	return weeklyState(s.Weekly(day), day.Weekday())
+}
+
+// Weekly returns the weekly template in force on day.
+func (s Schedule) Weekly(day time.Time) model.SchedulePreferences {
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	weekly := s.weekly
+	for _, version := range s.versions {
+		if version.from.After(day) {
+			break
+		}
+		weekly = version.weekly
+	}
+	return weekly
+}
+
+// weeklyState returns the weekly template's state for weekday.
+func weeklyState(prefs model.SchedulePreferences, weekday time.Weekday) model.State {
+	switch weekday {
+	case time.Monday:
+		return prefs.Monday
+	case time.Tuesday:
+		return prefs.Tuesday
+	case time.Wednesday:
+		return prefs.Wednesday
+	case time.Thursday:
+		return prefs.Thursday
+	case time.Friday:
+		return prefs.Friday
+	case time.Saturday:
+		return prefs.Saturday
+	default:
+		return prefs.Sunday
+	}
+}
@@ -0,0 +1,173 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/baely/officetracker/pkg/model"
+)
+
+func day(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestParseRecurrenceErrors(t *testing.T) {
+	for _, rule := range []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=YEARLY",
+		"FREQ=WEEKLY;INTERVAL=0",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=WEEKLY;BYMONTHDAY=1",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=MONTHLY;BYDAY=6MO",
+		"FREQ=WEEKLY;COUNT=3",
+		"FREQ",
+	} {
+		if _, err := ParseRecurrence(rule); err == nil {
+			t.Errorf("ParseRecurrence(%q) should fail", rule)
+		}
+	}
+
+	r, err := ParseRecurrence("RRULE:FREQ=MONTHLY;INTERVAL=3;BYDAY=1MO,-1FR")
+	if err != nil {
+		t.Fatalf("ParseRecurrence: %v", err)
+	}
+	if r.Freq != FrequencyMonthly || r.Interval != 3 || len(r.ByDay) != 2 ||
+		r.ByDay[0] != (WeekdayNum{N: 1, Weekday: time.Monday}) || r.ByDay[1] != (WeekdayNum{N: -1, Weekday: time.Friday}) {
+		t.Errorf("ParseRecurrence = %+v", r)
+	}
+}
+
+func TestRecurrenceMatches(t *testing.T) {
+	cases := map[string]struct {
+		rule  string
+		start time.Time
+		yes   []time.Time
+		no    []time.Time
+	}{
+		// 1 January 2024 is a Monday.
+		"every other Monday and Wednesday": {
+			rule:  "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE",
+			start: day(2024, 1, 3),
+			yes:   []time.Time{day(2024, 1, 3), day(2024, 1, 15), day(2024, 1, 17)},
+			no:    []time.Time{day(2024, 1, 1), day(2024, 1, 8), day(2024, 1, 10), day(2024, 1, 16)},
+		},
+		"weekly on the start weekday": {
+			rule:  "FREQ=WEEKLY",
+			start: day(2024, 1, 4),
+			yes:   []time.Time{day(2024, 1, 4), day(2024, 1, 11)},
+			no:    []time.Time{day(2024, 1, 5), day(2023, 12, 28)},
+		},
+		"every third day": {
+			rule:  "FREQ=DAILY;INTERVAL=3",
+			start: day(2024, 1, 30),
+			yes:   []time.Time{day(2024, 1, 30), day(2024, 2, 2)},
+			no:    []time.Time{day(2024, 1, 31), day(2024, 2, 1)},
+		},
+		"first Monday and last Friday": {
+			rule:  "FREQ=MONTHLY;BYDAY=1MO,-1FR",
+			start: day(2024, 1, 1),
+			yes:   []time.Time{day(2024, 1, 1), day(2024, 1, 26), day(2024, 2, 5), day(2024, 3, 29)},
+			no:    []time.Time{day(2024, 1, 8), day(2024, 1, 19), day(2024, 2, 16)},
+		},
+		"15th and last day of every other month": {
+			rule:  "FREQ=MONTHLY;INTERVAL=2;BYMONTHDAY=15,-1",
+			start: day(2024, 1, 1),
+			yes:   []time.Time{day(2024, 1, 15), day(2024, 1, 31), day(2024, 3, 15), day(2024, 3, 31)},
+			no:    []time.Time{day(2024, 2, 15), day(2024, 2, 29), day(2024, 1, 30)},
+		},
+	}
+	for name, c := range cases {
+		r, err := ParseRecurrence(c.rule)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for _, d := range c.yes {
+			if !r.Matches(c.start, d) {
+				t.Errorf("%s: %s should match", name, d.Format("2006-01-02"))
+			}
+		}
+		for _, d := range c.no {
+			if r.Matches(c.start, d) {
+				t.Errorf("%s: %s should not match", name, d.Format("2006-01-02"))
+			}
+		}
+	}
+}
+
+func TestParseScheduleRule(t *testing.T) {
+	cases := map[string]model.ScheduleRule{
+		"bad start":        {RRule: "FREQ=DAILY", Start: "01/02/2024"},
+		"bad end":          {RRule: "FREQ=DAILY", Start: "2024-01-02", End: "soon"},
+		"end before start": {RRule: "FREQ=DAILY", Start: "2024-01-02", End: "2024-01-01"},
+		"bad rule":         {RRule: "FREQ=HOURLY", Start: "2024-01-02"},
+	}
+	for name, rule := range cases {
+		if _, _, _, err := ParseScheduleRule(rule); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+// Rules override the weekly template, later rules override earlier ones, and
+// each rule only applies between its start and end dates.
+func TestScheduleState(t *testing.T) {
+	s := NewSchedule(model.SchedulePreferences{
+		Monday:  model.StateWorkFromOffice,
+		Tuesday: model.StateWorkFromHome,
+	}, nil, model.ScheduleRules{
+		{State: model.StateWorkFromOffice, RRule: "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU", Start: "2024-01-02", End: "2024-01-31"},
+		{State: model.StateUntracked, RRule: "FREQ=MONTHLY;BYDAY=1MO", Start: "2024-01-01"},
+		{State: model.StateOther, RRule: "FREQ=NEVER", Start: "2024-01-01"},
+	})
+
+	cases := map[time.Time]model.State{
+		day(2024, 1, 1):  model.StateUntracked,      // first Monday cleared
+		day(2024, 1, 8):  model.StateWorkFromOffice, // weekly template
+		day(2024, 1, 2):  model.StateWorkFromOffice, // fortnightly rule
+		day(2024, 1, 9):  model.StateWorkFromHome,   // off week
+		day(2024, 1, 16): model.StateWorkFromOffice,
+		day(2024, 2, 13): model.StateWorkFromHome, // rule has ended
+		day(2024, 1, 3):  model.StateUntracked,
+	}
+	for d, want := range cases {
+		if got := s.State(d); got != want {
+			t.Errorf("State(%s) = %d, want %d", d.Format("2006-01-02"), got, want)
+		}
+	}
+}
+
+// Each day uses the weekly template in force on it, falling back to the
+// current template before the first version.
+func TestScheduleHistory(t *testing.T) {
+	office := model.SchedulePreferences{Monday: model.StateWorkFromOffice}
+	home := model.SchedulePreferences{Monday: model.StateWorkFromHome}
+	s := NewSchedule(model.SchedulePreferences{Monday: model.StateOther}, model.ScheduleHistory{
+		{EffectiveFrom: "2024-03-01", Schedule: home},
+		{EffectiveFrom: "2024-01-08", Schedule: office},
+		{EffectiveFrom: "garbage", Schedule: model.SchedulePreferences{}},
+	}, nil)
+
+	cases := map[time.Time]model.State{
+		day(2024, 1, 1):  model.StateOther,
+		day(2024, 1, 8):  model.StateWorkFromOffice,
+		day(2024, 2, 26): model.StateWorkFromOffice,
+		day(2024, 3, 4):  model.StateWorkFromHome,
+	}
+	for d, want := range cases {
+		if got := s.State(d); got != want {
+			t.Errorf("State(%s) = %d, want %d", d.Format("2006-01-02"), got, want)
+		}
+	}
+
+	// The open-ended version covers every day before the dated ones.
+	s = NewSchedule(model.SchedulePreferences{}, model.ScheduleHistory{
+		{Schedule: office},
+		{EffectiveFrom: "2024-03-01", Schedule: home},
+	}, nil)
+	if got := s.State(day(2020, 6, 1)); got != model.StateWorkFromOffice {
+		t.Errorf("State before any dated version = %d, want office", got)
+	}
+}
@@ -1,5 +1,7 @@
 package util
 
+import "github.com/baely/officetracker/pkg/model"
+
 // ClampTargetPercent normalises an attendance target percentage to the valid
 // 0-100 range, where 0 means no target is set.
 func ClampTargetPercent(percent int) int {
@@ -11,3 +13,41 @@ func ClampTargetPercent(percent int) int {
 	}
 	return percent
 }
+
+// NormaliseTargetPreferences fills in the percent policy and calendar month
+// window when they're missing or unknown, and clamps each value to what its
+// policy and window allow. Values for other policies are cleared, so clients
+// that only know percentages don't show a stale target.
+func NormaliseTargetPreferences(prefs model.TargetPreferences) model.TargetPreferences {
+	if !prefs.Policy.Valid() {
+		prefs.Policy = model.TargetPolicyPercent
+	}
+	if !prefs.Window.Valid() {
+		prefs.Window = model.TargetWindowMonth
+	}
+	prefs.TargetPercent = ClampTargetPercent(prefs.TargetPercent)
+	if prefs.Policy != model.TargetPolicyPercent {
+		prefs.TargetPercent = 0
+	}
+
+	maxDays := 7.0
+	if prefs.Policy == model.TargetPolicyDaysPerMonth {
+		maxDays = 31
+	}
+	switch {
+	case prefs.Policy == model.TargetPolicyPercent || prefs.TargetDays < 0:
+		prefs.TargetDays = 0
+	case prefs.TargetDays > maxDays:
+		prefs.TargetDays = maxDays
+	}
+
+	switch {
+	case prefs.Window != model.TargetWindowRollingWeeks:
+		prefs.WindowWeeks = 0
+	case prefs.WindowWeeks < 1:
+		prefs.WindowWeeks = model.DefaultTargetWindowWeeks
+	case prefs.WindowWeeks > 52:
+		prefs.WindowWeeks = 52
+	}
+	return prefs
+}
@@ -1,6 +1,10 @@
 package util
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/baely/officetracker/pkg/model"
+)
 
 func TestClampTargetPercent(t *testing.T) {
 	cases := []struct {
@@ -20,3 +24,39 @@ func TestClampTargetPercent(t *testing.T) {
 		}
 	}
 }
+
+func TestNormaliseTargetPreferences(t *testing.T) {
+	cases := map[string]struct {
+		in, want model.TargetPreferences
+	}{
+		"legacy percent": {
+			in:   model.TargetPreferences{TargetPercent: 60},
+			want: model.TargetPreferences{TargetPercent: 60, Policy: model.TargetPolicyPercent, Window: model.TargetWindowMonth},
+		},
+		"unknown policy and window": {
+			in:   model.TargetPreferences{TargetPercent: 150, Policy: "hours", Window: "fortnight", TargetDays: 3},
+			want: model.TargetPreferences{TargetPercent: 100, Policy: model.TargetPolicyPercent, Window: model.TargetWindowMonth},
+		},
+		"days per week rolling default": {
+			in:   model.TargetPreferences{TargetPercent: 60, Policy: model.TargetPolicyDaysPerWeek, TargetDays: 3, Window: model.TargetWindowRollingWeeks},
+			want: model.TargetPreferences{Policy: model.TargetPolicyDaysPerWeek, TargetDays: 3, Window: model.TargetWindowRollingWeeks, WindowWeeks: 12},
+		},
+		"days per week clamped": {
+			in:   model.TargetPreferences{Policy: model.TargetPolicyDaysPerWeek, TargetDays: 9, Window: model.TargetWindowRollingWeeks, WindowWeeks: 80},
+			want: model.TargetPreferences{Policy: model.TargetPolicyDaysPerWeek, TargetDays: 7, Window: model.TargetWindowRollingWeeks, WindowWeeks: 52},
+		},
+		"days per month drops weeks": {
+			in:   model.TargetPreferences{Policy: model.TargetPolicyDaysPerMonth, TargetDays: 8, Window: model.TargetWindowQuarter, WindowWeeks: 4},
+			want: model.TargetPreferences{Policy: model.TargetPolicyDaysPerMonth, TargetDays: 8, Window: model.TargetWindowQuarter},
+		},
+		"negative days": {
+			in:   model.TargetPreferences{Policy: model.TargetPolicyDaysPerMonth, TargetDays: -2, Window: model.TargetWindowTrackingYear},
+			want: model.TargetPreferences{Policy: model.TargetPolicyDaysPerMonth, Window: model.TargetWindowTrackingYear},
+		},
+	}
+	for name, c := range cases {
+		if got := NormaliseTargetPreferences(c.in); got != c.want {
+			t.Errorf("%s: NormaliseTargetPreferences(%+v) = %+v, want %+v", name, c.in, got, c.want)
+		}
+	}
+}
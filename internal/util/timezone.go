@@ -0,0 +1,48 @@
+package util
+
+import (
+	"fmt"
+	"time"
+)
+
+// Timezone helpers.
+//
+// Users pick an IANA timezone that their days start and end in. Everything that
+// works out "today" (redirects, report ranges, targets, the evening job) goes
+// through these, so users far from the server aren't on the wrong date around
+// midnight. An unset timezone uses the server's.
+
+// ValidateTimezone reports an error when name is neither empty nor a known
+// IANA timezone.
+func ValidateTimezone(name string) error {
+	if name == "" {
+		return nil
+	}
+	if _, err := time.LoadLocation(name); err != nil || name == "Local" {
+		return fmt.Errorf("unknown timezone %q", name)
+	}
+	return nil
+}
+
+// Location returns the named timezone, falling back to the server's when name
+// is empty or unknown.
+func Location(name string) *time.Location {
+	if name == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// Today returns midnight at the start of the current day in loc.
+func Today(loc *time.Location) time.Time {
+	return StartOfDay(time.Now().In(loc))
+}
+
+// StartOfDay returns midnight at the start of t's day in t's location.
+func StartOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
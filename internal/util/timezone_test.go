@@ -0,0 +1,44 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateTimezone(t *testing.T) {
+	for _, name := range []string{"", "UTC", "Europe/London", "Australia/Melbourne"} {
+		if err := ValidateTimezone(name); err != nil {
+			t.Errorf("ValidateTimezone(%q) = %v, want nil", name, err)
+		}
+	}
+	for _, name := range []string{"Mars/Olympus", "Local", "+10:00"} {
+		if err := ValidateTimezone(name); err == nil {
+			t.Errorf("ValidateTimezone(%q) = nil, want an error", name)
+		}
+	}
+}
+
+func TestLocation(t *testing.T) {
+	if got := Location(""); got != time.Local {
+		t.Errorf("Location(\"\") = %v, want local", got)
+	}
+	if got := Location("Mars/Olympus"); got != time.Local {
+		t.Errorf("Location(unknown) = %v, want local", got)
+	}
+	if got := Location("Europe/London"); got.String() != "Europe/London" {
+		t.Errorf("Location(Europe/London) = %v", got)
+	}
+}
+
+// The same instant falls on different days either side of the date line.
+func TestStartOfDay(t *testing.T) {
+	instant := time.Date(2024, time.March, 4, 20, 0, 0, 0, time.UTC)
+	melbourne := StartOfDay(instant.In(Location("Australia/Melbourne")))
+	if melbourne.Day() != 5 || melbourne.Hour() != 0 {
+		t.Errorf("Melbourne day = %v, want midnight on 5 March", melbourne)
+	}
+	london := StartOfDay(instant.In(Location("Europe/London")))
+	if london.Day() != 4 || london.Hour() != 0 {
+		t.Errorf("London day = %v, want midnight on 4 March", london)
+	}
+}
@@ -48,11 +48,11 @@ func TrackingYearCalendarYears(ty, startMonth int) (firstYear, secondYear int) {
 }
 
 // TrackingYearRange returns the half-open [start, end) date range covering the
-// tracking year labelled ty.
-func TrackingYearRange(ty, startMonth int) (start, end time.Time) {
+// tracking year labelled ty, with days starting at midnight in loc.
+func TrackingYearRange(ty, startMonth int, loc *time.Location) (start, end time.Time) {
 	startMonth = NormaliseStartMonth(startMonth)
 	firstYear, _ := TrackingYearCalendarYears(ty, startMonth)
-	start = time.Date(firstYear, time.Month(startMonth), 1, 0, 0, 0, 0, time.Local)
+	start = time.Date(firstYear, time.Month(startMonth), 1, 0, 0, 0, 0, loc)
 	end = start.AddDate(1, 0, 0)
 	return start, end
 }
@@ -61,7 +61,7 @@ func TestTrackingYearRange(t *testing.T) {
 		{2024, 7, time.Date(2023, time.July, 1, 0, 0, 0, 0, time.Local), time.Date(2024, time.July, 1, 0, 0, 0, 0, time.Local)},
 	}
 	for _, c := range cases {
-		start, end := TrackingYearRange(c.ty, c.startMonth)
+		start, end := TrackingYearRange(c.ty, c.startMonth, time.Local)
 		if !start.Equal(c.wantStart) || !end.Equal(c.wantEnd) {
 			t.Errorf("TrackingYearRange(%d,%d)=(%v,%v) want (%v,%v)", c.ty, c.startMonth, start, end, c.wantStart, c.wantEnd)
 		}
@@ -12,6 +12,15 @@ interface NotesPayload {
   data: Record<string, { note: string }>;
 }
 
+// Where an entry came from (model.Source on the server).
+export type EntrySource =
+  | 'manual'
+  | 'geofence'
+  | 'extension'
+  | 'schedule'
+  | 'import'
+  | 'mcp';
+
 // month (1-12) -> day (1-31) -> state
 export type MonthDays = Record<number, AttendanceState>;
 
@@ -44,6 +53,36 @@ export interface Settings {
   targetPercent: number;
 }
 
+// A named office from the user's settings (model.Location on the server).
+// Coordinates are optional; only offices that have them get a geofence.
+export interface OfficeLocation {
+  id: number;
+  name: string;
+  latitude?: number;
+  longitude?: number;
+  // Geofence radius in metres (0 = use the app default).
+  radius: number;
+  archived: boolean;
+}
+
+// What a check-in did (model.CheckInResult on the server). reason says why
+// nothing was recorded: 'not_at_office' or 'already_set'.
+export interface CheckInResult {
+  date: string;
+  recorded: boolean;
+  reason?: 'not_at_office' | 'already_set';
+  locationId?: number;
+}
+
+// Which push notifications a registered device gets (model.PushToken on the
+// server).
+export interface PushOptions {
+  // The 5pm reminder on a weekday that isn't filled in.
+  reminder: boolean;
+  // A confirmation each time the geofence records a day in the office.
+  checkIn: boolean;
+}
+
 export interface TokenInfo {
   tokenId: number;
   name: string;
@@ -66,16 +105,43 @@ export class ApiError extends Error {
   constructor(
     message: string,
     public status?: number,
+    // The server's machine-readable error code, e.g. 'not_found' or
+    // 'validation_failed'. Missing for older servers and network failures.
+    public code?: string,
   ) {
     super(message);
     this.name = 'ApiError';
   }
 }
 
+// The JSON body the server sends with an error. Servers that predate error
+// codes only send code and message.
+interface ErrorBody {
+  code?: number;
+  error_code?: string;
+  message?: string;
+  errors?: { field: string; reason: string }[];
+}
+
+async function readErrorBody(res: Response): Promise<ErrorBody> {
+  try {
+    return (await res.json()) as ErrorBody;
+  } catch {
+    return {};
+  }
+}
+
+// True when a response means the stored token is no longer valid (expired or
+// revoked). A 403 with the forbidden code means the token is fine but can't be
+// used for that request; older servers sent a bare 403 for a bad token.
+function tokenRejected(status: number | undefined, code: string | undefined): boolean {
+  return status === 401 || (status === 403 && code !== 'forbidden');
+}
+
 // True when an error means the stored token is no longer valid (expired or
 // revoked) — the app should sign out rather than keep retrying.
 export function isUnauthorized(e: unknown): boolean {
-  return e instanceof ApiError && (e.status === 401 || e.status === 403);
+  return e instanceof ApiError && tokenRejected(e.status, e.code);
 }
 
 function normaliseBase(baseUrl: string): string {
@@ -174,15 +240,19 @@ export class Api {
       throw new ApiError('This server is read-only.', 405);
     }
     const res = await rawFetch(this.url(path), init);
-    if (res.status === 401 || res.status === 403) {
+    if (res.ok) return res;
+    const body = await readErrorBody(res);
+    if (tokenRejected(res.status, body.error_code)) {
       // Token expired or revoked — trigger a sign-out.
       this.onUnauthorized?.();
-      throw new ApiError('Your session has expired. Please sign in again.', res.status);
-    }
-    if (!res.ok) {
-      throw new ApiError(`Server returned ${res.status}.`, res.status);
+      throw new ApiError('Your session has expired. Please sign in again.', res.status, body.error_code);
     }
-    return res;
+    // Validation errors list a reason per field, which says more than the
+    // generic message.
+    const message = body.errors?.length
+      ? body.errors.map((e) => e.reason).join('; ')
+      : body.message || `Server returned ${res.status}.`;
+    throw new ApiError(message, res.status, body.error_code);
   }
 
   // Verifies the URL is an Office Tracker server. Does not require auth.
@@ -211,19 +281,51 @@ export class Api {
   }
 
   // Saves a single day. year/month/day are calendar values (not fiscal).
+  // source records which part of the app set the day (the server defaults to
+  // 'manual'); locationId optionally records which office it was.
   async putDay(
     year: number,
     month: number,
     day: number,
     state: AttendanceState,
+    source?: EntrySource,
+    locationId?: number,
   ): Promise<void> {
     await this.request(`/api/v1/state/${year}/${month}/${day}`, {
       method: 'PUT',
       headers: this.headers(true),
-      body: JSON.stringify({ data: { state } }),
+      body: JSON.stringify({ data: { state, source, location_id: locationId } }),
     });
   }
 
+  // Asks the server whether the coordinates are at one of the user's offices,
+  // recording today as Office if so and the user hasn't set it themselves.
+  async checkIn(
+    latitude: number,
+    longitude: number,
+    source: EntrySource = 'geofence',
+  ): Promise<CheckInResult> {
+    const res = await this.request('/api/v1/checkin', {
+      method: 'POST',
+      headers: this.headers(true),
+      body: JSON.stringify({ data: { latitude, longitude, source } }),
+    });
+    const p = (await res.json()) as {
+      data?: {
+        date: string;
+        recorded: boolean;
+        reason?: 'not_at_office' | 'already_set';
+        location?: { id: number };
+      };
+    };
+    return {
+      date: p.data?.date ?? '',
+      recorded: !!p.data?.recorded,
+      reason: p.data?.reason,
+      locationId: p.data?.location?.id,
+    };
+  }
+
   async getNotes(fiscalYear: number): Promise<Record<number, string>> {
     const res = await this.request(`/api/v1/note/${fiscalYear}`, {
       headers: this.headers(),
@@ -272,6 +374,31 @@ export class Api {
     return { linkedAccounts, schedule, trackingYearStartMonth: startMonth, targetPercent };
   }
 
+  // The user's named offices, including archived ones.
+  async getLocations(): Promise<OfficeLocation[]> {
+    const res = await this.request('/api/v1/settings/locations', {
+      headers: this.headers(),
+    });
+    const p = (await res.json()) as {
+      data?: {
+        id: number;
+        name: string;
+        latitude?: number;
+        longitude?: number;
+        radius?: number;
+        archived?: boolean;
+      }[];
+    };
+    return (p.data ?? []).map((l) => ({
+      id: l.id,
+      name: l.name,
+      latitude: l.latitude,
+      longitude: l.longitude,
+      radius: l.radius ?? 0,
+      archived: !!l.archived,
+    }));
+  }
+
   async updateSchedule(schedule: SchedulePreferences): Promise<void> {
     await this.request('/api/v1/settings/schedule', {
       method: 'PUT',
@@ -307,6 +434,32 @@ export class Api {
     return p.url;
   }
 
+  // ---- Push notifications ----
+
+  // Registers this device's Expo push token with the server, returning its ID
+  // for unregisterPushToken. The server ties it to the signed-in API token, so
+  // signing out stops notifications too. Registering again replaces the
+  // earlier registration and its options.
+  async registerPushToken(token: string, device: string, options: PushOptions): Promise<number> {
+    const res = await this.request('/api/v1/settings/push/tokens', {
+      method: 'POST',
+      headers: this.headers(true),
+      body: JSON.stringify({
+        data: { token, device, reminder: options.reminder, check_in: options.checkIn },
+      }),
+    });
+    const p = (await res.json()) as { data?: { id?: number } };
+    if (!p.data?.id) throw new ApiError('Server did not register the device.');
+    return p.data.id;
+  }
+
+  async unregisterPushToken(id: number): Promise<void> {
+    await this.request(`/api/v1/settings/push/tokens/${id}`, {
+      method: 'DELETE',
+      headers: this.headers(),
+    });
+  }
+
   // ---- Developer tokens ----
 
   async listTokens(): Promise<TokenInfo[]> {
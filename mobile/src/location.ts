@@ -5,13 +5,17 @@
 // survives app restarts and works when the app is backgrounded/terminated)
 // rather than a continuous location stream, plus a one-shot foreground check to
 // catch the case where they're already inside the region when it's configured.
+//
+// Offices the user named in their settings with coordinates are watched too;
+// arriving at one also records which office the day was spent at.
 import * as Location from 'expo-location';
 import * as TaskManager from 'expo-task-manager';
-import { Api } from './api';
+import { Api, OfficeLocation } from './api';
 import { DEFAULT_TRACKING_YEAR_START_MONTH, trackingYear } from './dates';
 import { AttendanceState } from './states';
 import {
   clearWorkLocation,
+  DEFAULT_WORK_RADIUS,
   getAutoOfficeHandledDate,
   getCachedStartMonth,
   loadConnection,
@@ -23,6 +27,33 @@ import {
 
 export const GEOFENCE_TASK = 'officetracker-work-geofence';
 const REGION_ID = 'work';
+// Server office regions are identified as `location-<id>`.
+const OFFICE_REGION_PREFIX = 'location-';
+
+// The server location id for a region, or undefined for the local work region.
+function regionLocationId(identifier?: string): number | undefined {
+  if (!identifier?.startsWith(OFFICE_REGION_PREFIX)) return undefined;
+  const id = Number(identifier.slice(OFFICE_REGION_PREFIX.length));
+  return Number.isInteger(id) && id > 0 ? id : undefined;
+}
+
+// Active offices that have coordinates, i.e. the ones worth a geofence.
+// Best effort: a network error just means only the work region is armed.
+async function loadOffices(): Promise<
+  (OfficeLocation & { latitude: number; longitude: number })[]
+> {
+  const conn = await loadConnection();
+  if (!conn) return [];
+  try {
+    const offices = await new Api(conn).getLocations();
+    return offices.filter(
+      (o): o is OfficeLocation & { latitude: number; longitude: number } =>
+        !o.archived && o.latitude !== undefined && o.longitude !== undefined,
+    );
+  } catch {
+    return [];
+  }
+}
 
 // Device-local YYYY-MM-DD for the given date.
 function localDateKey(d = new Date()): string {
@@ -60,9 +91,10 @@ function isUserSet(state: AttendanceState): boolean {
 }
 
 // If today hasn't been set by the user (untracked or only planned), mark it as
-// Office. Guarded so it touches the server at most once per day. Safe to call
-// from the background task or the foreground.
-export async function markOfficeForToday(): Promise<void> {
+// Office, at locationId when the trigger was a named office. Guarded so it
+// touches the server at most once per day. Safe to call from the background
+// task or the foreground.
+export async function markOfficeForToday(locationId?: number): Promise<void> {
   const now = new Date();
   const dateKey = localDateKey(now);
   if ((await getAutoOfficeHandledDate()) === dateKey) return;
@@ -88,21 +120,42 @@ export async function markOfficeForToday(): Promise<void> {
       await setAutoOfficeHandledDate(dateKey);
       return;
     }
-    await api.putDay(year, month, day, AttendanceState.Office);
+    await api.putDay(year, month, day, AttendanceState.Office, 'geofence', locationId);
     await setAutoOfficeHandledDate(dateKey);
   } catch {
     // Network/auth error — leave today unhandled so a later trigger retries.
   }
 }
 
+// Lets the server match a position fix against the user's offices and decide
+// whether today can be recorded. True when it matched one, whether or not the
+// day was already set; false when the fix isn't at an office or the server
+// predates check-ins, leaving the local work location to be checked.
+async function checkInOnServer(coords: {
+  latitude: number;
+  longitude: number;
+}): Promise<boolean> {
+  const conn = await loadConnection();
+  if (!conn) return false;
+  try {
+    const result = await new Api(conn).checkIn(coords.latitude, coords.longitude);
+    if (result.reason === 'not_at_office') return false;
+    await setAutoOfficeHandledDate(localDateKey());
+    return true;
+  } catch {
+    return false;
+  }
+}
+
 // Geofence handler. Fires on region enter even when the app is backgrounded.
 TaskManager.defineTask(GEOFENCE_TASK, async ({ data, error }) => {
   if (error) return;
-  const { eventType } = (data ?? {}) as {
+  const { eventType, region } = (data ?? {}) as {
     eventType?: Location.GeofencingEventType;
+    region?: Location.LocationRegion;
   };
   if (eventType === Location.GeofencingEventType.Enter) {
-    await markOfficeForToday();
+    await markOfficeForToday(regionLocationId(region?.identifier));
   }
 });
 
@@ -126,6 +179,7 @@ async function startGeofence(loc: WorkLocation): Promise<void> {
       // not running — ignore
     }
   }
+  const offices = await loadOffices();
   await Location.startGeofencingAsync(GEOFENCE_TASK, [
     {
       identifier: REGION_ID,
@@ -135,6 +189,14 @@ async function startGeofence(loc: WorkLocation): Promise<void> {
       notifyOnEnter: true,
       notifyOnExit: false,
     },
+    ...offices.map((o) => ({
+      identifier: `${OFFICE_REGION_PREFIX}${o.id}`,
+      latitude: o.latitude,
+      longitude: o.longitude,
+      radius: o.radius || DEFAULT_WORK_RADIUS,
+      notifyOnEnter: true,
+      notifyOnExit: false,
+    })),
   ]);
 }
 
@@ -161,7 +223,13 @@ export async function checkProximityNow(): Promise<void> {
     const pos = await Location.getCurrentPositionAsync({
       accuracy: Location.Accuracy.Balanced,
     });
-    if (distanceMeters(pos.coords, loc) <= loc.radius) {
+    if (await checkInOnServer(pos.coords)) return;
+    const office = (await loadOffices()).find(
+      (o) => distanceMeters(pos.coords, o) <= (o.radius || DEFAULT_WORK_RADIUS),
+    );
+    if (office) {
+      await markOfficeForToday(office.id);
+    } else if (distanceMeters(pos.coords, loc) <= loc.radius) {
       await markOfficeForToday();
     }
   } catch {
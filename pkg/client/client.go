@@ -0,0 +1,286 @@
+// Package client is a Go client for the Officetracker v1 API.
+//
+// Methods mirror the server's: each takes the request type from pkg/model,
+// with path parameters set on its Meta, and returns the matching response
+// type.
+//
+//	c, err := client.New("https://officetracker.example.com", client.WithSecret(token))
+//	resp, err := c.GetDay(ctx, model.GetDayRequest{
+//		Meta: model.GetDayRequestMeta{Year: 2024, Month: 3, Day: 5},
+//	})
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/baely/officetracker/pkg/model"
+)
+
+const (
+	apiPath = "/api/v1"
+
+	// DefaultRetries is how many times a rate limited request is retried.
+	DefaultRetries = 3
+	// maxRetryWait is the longest Retry-After the client will sleep through.
+	// Longer waits, such as for an hourly cap, are returned as errors instead.
+	maxRetryWait = time.Minute
+	// defaultRetryWait is used when a rate limited response has no
+	// Retry-After.
+	defaultRetryWait = time.Second
+)
+
+// Client calls the Officetracker API. It is safe for concurrent use.
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	secret     string
+	retries    int
+}
+
+type Option func(*Client)
+
+// WithSecret authenticates requests with an API token.
+func WithSecret(secret string) Option {
+	return func(c *Client) {
+		c.secret = secret
+	}
+}
+
+// WithHTTPClient sends requests with hc instead of http.DefaultClient.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithRetries sets how many times a rate limited request is retried. 0
+// turns retries off.
+func WithRetries(n int) Option {
+	return func(c *Client) {
+		c.retries = n
+	}
+}
+
+// New returns a client for the Officetracker instance at baseURL, e.g.
+// https://officetracker.example.com.
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid base URL %q: want an http or https URL", baseURL)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+
+	c := &Client{
+		baseURL:    u,
+		httpClient: http.DefaultClient,
+		retries:    DefaultRetries,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// Error is an error response from the API.
+type Error struct {
+	// StatusCode is the HTTP status of the response.
+	StatusCode int
+	// Code says what went wrong. It is empty if the response had no error
+	// body, e.g. from a proxy in front of the API.
+	Code    model.ErrorCode
+	Message string
+	// Fields lists the invalid fields of a rejected request.
+	Fields []model.FieldError
+	// RetryAfter is how long the server asked the client to wait before
+	// trying again. Only set for rate limited requests.
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("officetracker: %d", e.StatusCode)
+	if e.Code != "" {
+		msg += " " + string(e.Code)
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	for _, f := range e.Fields {
+		msg += fmt.Sprintf("; %s: %s", f.Field, f.Reason)
+	}
+	return msg
+}
+
+// IsCode reports whether err is an API error with the given code.
+func IsCode(err error, code model.ErrorCode) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Code == code
+}
+
+// call sends req to the route with the given method and pattern and decodes
+// the JSON response.
+func call[U, T any](ctx context.Context, c *Client, method, pattern string, req T) (U, error) {
+	var resp U
+	b, _, err := c.send(ctx, method, pattern, req)
+	if err != nil {
+		return resp, err
+	}
+	if err = json.Unmarshal(b, &resp); err != nil {
+		return resp, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return resp, nil
+}
+
+// send sends req and returns the body of a successful response. Path
+// parameters come from req's meta fields and query parameters from its
+// schema-tagged fields. The rest of req is the JSON body of methods that
+// take one.
+func (c *Client) send(ctx context.Context, method, pattern string, req any) ([]byte, string, error) {
+	target, err := c.url(pattern, req)
+	if err != nil {
+		return nil, "", err
+	}
+	var body []byte
+	if method != http.MethodGet && method != http.MethodDelete {
+		if body, err = json.Marshal(req); err != nil {
+			return nil, "", fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		b, contentType, err := c.do(ctx, method, target, body)
+		var apiErr *Error
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests ||
+			attempt >= c.retries || apiErr.RetryAfter > maxRetryWait {
+			return b, contentType, err
+		}
+		wait := apiErr.RetryAfter
+		if wait == 0 {
+			wait = defaultRetryWait
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, "", ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) do(ctx context.Context, method, target string, body []byte) ([]byte, string, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	r, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	r.Header.Set("Accept", "application/json")
+	if c.secret != "" {
+		r.Header.Set("Authorization", "Bearer "+c.secret)
+	}
+
+	res, err := c.httpClient.Do(r)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read response: %w", err)
+	}
+	if res.StatusCode >= 300 {
+		return nil, "", responseError(res, b)
+	}
+	return b, res.Header.Get("Content-Type"), nil
+}
+
+func responseError(res *http.Response, body []byte) *Error {
+	apiErr := &Error{StatusCode: res.StatusCode}
+	var e model.Error
+	if err := json.Unmarshal(body, &e); err == nil && e.ErrorCode != "" {
+		apiErr.Code = e.ErrorCode
+		apiErr.Message = e.Message
+		apiErr.Fields = e.Errors
+	} else {
+		apiErr.Message = http.StatusText(res.StatusCode)
+	}
+	apiErr.RetryAfter = retryAfter(res.Header.Get("Retry-After"), time.Now())
+	return apiErr
+}
+
+// retryAfter parses a Retry-After header, given either in seconds or as an
+// HTTP date.
+func retryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(header); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// url builds the URL of the route with the given pattern for req.
+func (c *Client) url(pattern string, req any) (string, error) {
+	path := pattern
+	query := url.Values{}
+
+	v := reflect.ValueOf(req)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Tag.Get("meta") == "meta" {
+			meta := v.Field(i)
+			for j := 0; j < meta.NumField(); j++ {
+				name := meta.Type().Field(j).Tag.Get("meta")
+				if name == "" || !strings.Contains(path, "{"+name+"}") {
+					continue
+				}
+				path = strings.ReplaceAll(path, "{"+name+"}", fmt.Sprint(meta.Field(j).Interface()))
+			}
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("schema"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		value := v.Field(i)
+		if value.Kind() == reflect.Slice {
+			for j := 0; j < value.Len(); j++ {
+				query.Add(name, fmt.Sprint(value.Index(j).Interface()))
+			}
+		} else if !value.IsZero() {
+			query.Set(name, fmt.Sprint(value.Interface()))
+		}
+	}
+	if strings.Contains(path, "{") {
+		return "", fmt.Errorf("request %T doesn't fill the path %s", req, pattern)
+	}
+
+	u := *c.baseURL
+	u.Path += apiPath + path
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
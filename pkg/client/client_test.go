@@ -0,0 +1,301 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/baely/officetracker/internal/config"
+	"github.com/baely/officetracker/internal/database/dbtest"
+	"github.com/baely/officetracker/internal/openapi"
+	"github.com/baely/officetracker/internal/report"
+	"github.com/baely/officetracker/internal/server"
+	"github.com/baely/officetracker/pkg/model"
+)
+
+func newClient(t *testing.T, h http.Handler, opts ...Option) *Client {
+	t.Helper()
+	ts := httptest.NewServer(h)
+	t.Cleanup(ts.Close)
+	c, err := New(ts.URL, opts...)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return c
+}
+
+// newStandaloneClient returns a client for a standalone server backed by an
+// in-memory database.
+func newStandaloneClient(t *testing.T) *Client {
+	t.Helper()
+	db := dbtest.New()
+	srv, err := server.NewServer(config.StandaloneApp{}, db, nil, report.New(db))
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	return newClient(t, srv.Handler)
+}
+
+func TestNewRejectsBadURL(t *testing.T) {
+	for _, u := range []string{"", "officetracker.example.com", "ftp://example.com", "http://[::1"} {
+		if _, err := New(u); err == nil {
+			t.Errorf("New(%q) succeeded", u)
+		}
+	}
+}
+
+func TestClientStateRoundTrip(t *testing.T) {
+	c := newStandaloneClient(t)
+	ctx := context.Background()
+
+	_, err := c.PutDay(ctx, model.PutDayRequest{
+		Meta: model.PutDayRequestMeta{Year: 2024, Month: 3, Day: 5},
+		Data: model.DayState{State: model.StateWorkFromOffice, Note: "client site"},
+	})
+	if err != nil {
+		t.Fatalf("PutDay: %v", err)
+	}
+	day, err := c.GetDay(ctx, model.GetDayRequest{Meta: model.GetDayRequestMeta{Year: 2024, Month: 3, Day: 5}})
+	if err != nil {
+		t.Fatalf("GetDay: %v", err)
+	}
+	if day.Data.State != model.StateWorkFromOffice || day.Data.Note != "client site" {
+		t.Errorf("GetDay = %+v", day.Data)
+	}
+
+	_, err = c.PutWeek(ctx, model.PutWeekRequest{
+		Meta: model.PutWeekRequestMeta{ISOYear: 2024, Week: 10},
+		Data: model.WeekState{Days: map[int]model.DayState{1: {State: model.StateWorkFromHome}}},
+	})
+	if err != nil {
+		t.Fatalf("PutWeek: %v", err)
+	}
+	week, err := c.GetWeek(ctx, model.GetWeekRequest{Meta: model.GetWeekRequestMeta{ISOYear: 2024, Week: 10}})
+	if err != nil {
+		t.Fatalf("GetWeek: %v", err)
+	}
+	if week.Data.Days[1].State != model.StateWorkFromHome || week.Data.Days[2].State != model.StateWorkFromOffice {
+		t.Errorf("GetWeek = %+v", week.Data.Days)
+	}
+
+	entries, err := c.GetStateRange(ctx, model.GetStateRangeRequest{From: "2024-03-01", To: "2024-03-31"})
+	if err != nil {
+		t.Fatalf("GetStateRange: %v", err)
+	}
+	if len(entries.Data) != 2 || entries.Data[0].Date != "2024-03-04" {
+		t.Errorf("GetStateRange = %+v", entries.Data)
+	}
+
+	csv, err := c.GetReportCSV(ctx, model.GetReportCSVRequest{
+		Meta:   model.GetReportCSVRequestMeta{Year: 2024},
+		Source: []model.Source{model.SourceManual},
+	})
+	if err != nil {
+		t.Fatalf("GetReportCSV: %v", err)
+	}
+	if !strings.HasPrefix(csv.ContentType, "text/csv") || !strings.Contains(string(csv.Data.([]byte)), "2024-03-05") {
+		t.Errorf("GetReportCSV = %q %q", csv.ContentType, csv.Data)
+	}
+}
+
+func TestClientNotes(t *testing.T) {
+	c := newStandaloneClient(t)
+	ctx := context.Background()
+
+	_, err := c.PutNote(ctx, model.PutNoteRequest{
+		Meta: model.PutNoteRequestMeta{Year: 2024, Month: 3},
+		Data: model.Note{Note: "quarter end"},
+	})
+	if err != nil {
+		t.Fatalf("PutNote: %v", err)
+	}
+	notes, err := c.GetNotes(ctx, model.GetNotesRequest{Meta: model.GetNotesRequestMeta{Year: 2024}})
+	if err != nil {
+		t.Fatalf("GetNotes: %v", err)
+	}
+	if notes.Data[3].Note != "quarter end" {
+		t.Errorf("GetNotes = %+v", notes.Data)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	c := newStandaloneClient(t)
+	ctx := context.Background()
+
+	_, err := c.PutDay(ctx, model.PutDayRequest{
+		Meta: model.PutDayRequestMeta{Year: 2024, Month: 2, Day: 30},
+		Data: model.DayState{State: model.StateWorkFromOffice},
+	})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("PutDay on an impossible date: err = %v, want an *Error", err)
+	}
+	if apiErr.StatusCode != http.StatusBadRequest || apiErr.Code != model.ErrorCodeValidation ||
+		len(apiErr.Fields) != 1 || apiErr.Fields[0].Field != "day" {
+		t.Errorf("PutDay error = %+v", apiErr)
+	}
+
+	_, err = c.DeleteLocation(ctx, model.DeleteLocationRequest{Meta: model.DeleteLocationRequestMeta{LocationID: 99}})
+	if !IsCode(err, model.ErrorCodeNotFound) {
+		t.Errorf("DeleteLocation on an unknown location: err = %v, want not_found", err)
+	}
+
+	// Standalone sessions can't manage tokens.
+	_, err = c.ListTokens(ctx, model.ListTokensRequest{})
+	if !IsCode(err, model.ErrorCodeForbidden) {
+		t.Errorf("ListTokens: err = %v, want forbidden", err)
+	}
+}
+
+// The client covers every operation in the areas it supports, so routes added
+// to the server don't go missing from it.
+func TestClientCoversOperations(t *testing.T) {
+	db := dbtest.New()
+	srv, err := server.NewServer(config.StandaloneApp{}, db, nil, report.New(db))
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	res := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil))
+	var doc openapi.Document
+	if err := json.NewDecoder(res.Body).Decode(&doc); err != nil {
+		t.Fatalf("decode OpenAPI document: %v", err)
+	}
+
+	areas := map[string]bool{"State": true, "Note": true, "Settings": true, "Developer": true, "Report": true, "Health": true}
+	client := reflect.TypeFor[*Client]()
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			if !areas[op.Tags[0]] || op.OperationID == "GetOpenAPI" {
+				continue
+			}
+			if _, ok := client.MethodByName(op.OperationID); !ok {
+				t.Errorf("no client method for %s %s (%s)", strings.ToUpper(method), path, op.OperationID)
+			}
+		}
+	}
+}
+
+func TestClientSendsSecret(t *testing.T) {
+	var auth string
+	c := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		w.Write([]byte(`{"status":"ok"}`))
+	}), WithSecret("s3cret"))
+	if _, err := c.ValidateAuth(context.Background(), model.ValidateAuthRequest{}); err != nil {
+		t.Fatalf("ValidateAuth: %v", err)
+	}
+	if auth != "Bearer s3cret" {
+		t.Errorf("Authorization = %q", auth)
+	}
+}
+
+func rateLimited(w http.ResponseWriter, retryAfter string) {
+	w.Header().Set("Retry-After", retryAfter)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	w.Write([]byte(`{"code":429,"error_code":"rate_limited","message":"rate limit exceeded"}`))
+}
+
+func TestClientRetriesRateLimited(t *testing.T) {
+	var calls atomic.Int32
+	c := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			rateLimited(w, "1")
+			return
+		}
+		w.Write([]byte(`{"data":{"state":2}}`))
+	}))
+
+	start := time.Now()
+	day, err := c.GetDay(context.Background(), model.GetDayRequest{Meta: model.GetDayRequestMeta{Year: 2024, Month: 3, Day: 5}})
+	if err != nil {
+		t.Fatalf("GetDay: %v", err)
+	}
+	if day.Data.State != model.StateWorkFromOffice || calls.Load() != 2 {
+		t.Errorf("GetDay = %+v after %d calls", day.Data, calls.Load())
+	}
+	if waited := time.Since(start); waited < time.Second {
+		t.Errorf("retried after %v, want at least the Retry-After of 1s", waited)
+	}
+}
+
+func TestClientRetryLimits(t *testing.T) {
+	var calls atomic.Int32
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		rateLimited(w, "3600")
+	})
+
+	// A wait longer than the client will sleep through is returned at once.
+	c := newClient(t, h)
+	_, err := c.GetDay(context.Background(), model.GetDayRequest{Meta: model.GetDayRequestMeta{Year: 2024, Month: 3, Day: 5}})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Code != model.ErrorCodeRateLimited || apiErr.RetryAfter != time.Hour {
+		t.Fatalf("err = %v, want rate_limited with an hour to wait", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("%d calls, want 1", calls.Load())
+	}
+
+	calls.Store(0)
+	c = newClient(t, h, WithRetries(0))
+	if _, err := c.GetDay(context.Background(), model.GetDayRequest{}); !IsCode(err, model.ErrorCodeRateLimited) {
+		t.Errorf("err = %v, want rate_limited", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("%d calls with retries off, want 1", calls.Load())
+	}
+}
+
+func TestClientCancelsRetryWait(t *testing.T) {
+	c := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rateLimited(w, "30")
+	}))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := c.GetDay(ctx, model.GetDayRequest{Meta: model.GetDayRequestMeta{Year: 2024, Month: 3, Day: 5}})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the context's deadline", err)
+	}
+	if waited := time.Since(start); waited > 5*time.Second {
+		t.Errorf("waited %v after the context ended", waited)
+	}
+}
+
+func TestClientNonJSONError(t *testing.T) {
+	c := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "<html>bad gateway</html>", http.StatusBadGateway)
+	}))
+	_, err := c.GetSettings(context.Background(), model.GetSettingsRequest{})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadGateway || apiErr.Code != "" || apiErr.Message != "Bad Gateway" {
+		t.Errorf("err = %#v", err)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC)
+	cases := map[string]time.Duration{
+		"":                              0,
+		"12":                            12 * time.Second,
+		"-1":                            0,
+		"soon":                          0,
+		"Tue, 05 Mar 2024 09:00:30 GMT": 30 * time.Second,
+		"Tue, 05 Mar 2024 08:00:00 GMT": 0,
+	}
+	for header, want := range cases {
+		if got := retryAfter(header, now); got != want {
+			t.Errorf("retryAfter(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
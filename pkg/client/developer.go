@@ -0,0 +1,58 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/baely/officetracker/pkg/model"
+)
+
+// PostSecret creates an API token. Its secret is only returned here.
+func (c *Client) PostSecret(ctx context.Context, req model.PostSecretRequest) (model.PostSecretResponse, error) {
+	return call[model.PostSecretResponse](ctx, c, http.MethodPost, "/developer/secret", req)
+}
+
+// ListTokens lists the user's active API tokens.
+func (c *Client) ListTokens(ctx context.Context, req model.ListTokensRequest) (model.ListTokensResponse, error) {
+	return call[model.ListTokensResponse](ctx, c, http.MethodGet, "/developer/tokens", req)
+}
+
+// RevokeToken revokes an API token.
+func (c *Client) RevokeToken(ctx context.Context, req model.RevokeTokenRequest) (model.RevokeTokenResponse, error) {
+	return call[model.RevokeTokenResponse](ctx, c, http.MethodDelete, "/developer/tokens/{token_id}", req)
+}
+
+// ValidateAuth checks that the client's API token is valid.
+func (c *Client) ValidateAuth(ctx context.Context, req model.ValidateAuthRequest) (model.ValidateAuthResponse, error) {
+	return call[model.ValidateAuthResponse](ctx, c, http.MethodGet, "/health/auth", req)
+}
+
+// Healthcheck checks that the API is up. It needs no authentication.
+func (c *Client) Healthcheck(ctx context.Context, req model.HealthCheckRequest) (model.HealthCheckResponse, error) {
+	return call[model.HealthCheckResponse](ctx, c, http.MethodGet, "/health/check", req)
+}
+
+// ListWebhooks lists the user's webhooks, without their secrets.
+func (c *Client) ListWebhooks(ctx context.Context, req model.ListWebhooksRequest) (model.ListWebhooksResponse, error) {
+	return call[model.ListWebhooksResponse](ctx, c, http.MethodGet, "/developer/webhooks", req)
+}
+
+// CreateWebhook creates a webhook. Its signing secret is only returned here.
+func (c *Client) CreateWebhook(ctx context.Context, req model.CreateWebhookRequest) (model.CreateWebhookResponse, error) {
+	return call[model.CreateWebhookResponse](ctx, c, http.MethodPost, "/developer/webhooks", req)
+}
+
+// UpdateWebhook changes a webhook's URL, events or disabled flag.
+func (c *Client) UpdateWebhook(ctx context.Context, req model.UpdateWebhookRequest) (model.UpdateWebhookResponse, error) {
+	return call[model.UpdateWebhookResponse](ctx, c, http.MethodPut, "/developer/webhooks/{webhook_id}", req)
+}
+
+// DeleteWebhook deletes a webhook and its deliveries.
+func (c *Client) DeleteWebhook(ctx context.Context, req model.DeleteWebhookRequest) (model.DeleteWebhookResponse, error) {
+	return call[model.DeleteWebhookResponse](ctx, c, http.MethodDelete, "/developer/webhooks/{webhook_id}", req)
+}
+
+// ListWebhookDeliveries lists a webhook's latest deliveries, newest first.
+func (c *Client) ListWebhookDeliveries(ctx context.Context, req model.ListWebhookDeliveriesRequest) (model.ListWebhookDeliveriesResponse, error) {
+	return call[model.ListWebhookDeliveriesResponse](ctx, c, http.MethodGet, "/developer/webhooks/{webhook_id}/deliveries", req)
+}
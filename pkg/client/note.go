@@ -0,0 +1,33 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/baely/officetracker/pkg/model"
+)
+
+// GetNotes returns a year's monthly notes, keyed by month.
+func (c *Client) GetNotes(ctx context.Context, req model.GetNotesRequest) (model.GetNotesResponse, error) {
+	return call[model.GetNotesResponse](ctx, c, http.MethodGet, "/note/{year}", req)
+}
+
+// GetNote returns a month's note.
+func (c *Client) GetNote(ctx context.Context, req model.GetNoteRequest) (model.GetNoteResponse, error) {
+	return call[model.GetNoteResponse](ctx, c, http.MethodGet, "/note/{year}/{month}", req)
+}
+
+// PutNote sets a month's note.
+func (c *Client) PutNote(ctx context.Context, req model.PutNoteRequest) (model.PutNoteResponse, error) {
+	return call[model.PutNoteResponse](ctx, c, http.MethodPut, "/note/{year}/{month}", req)
+}
+
+// GetDayNote returns the note left on a day.
+func (c *Client) GetDayNote(ctx context.Context, req model.GetDayNoteRequest) (model.GetDayNoteResponse, error) {
+	return call[model.GetDayNoteResponse](ctx, c, http.MethodGet, "/note/{year}/{month}/{day}", req)
+}
+
+// PutDayNote sets or clears the note on a day without changing its state.
+func (c *Client) PutDayNote(ctx context.Context, req model.PutDayNoteRequest) (model.PutDayNoteResponse, error) {
+	return call[model.PutDayNoteResponse](ctx, c, http.MethodPut, "/note/{year}/{month}/{day}", req)
+}
@@ -0,0 +1,27 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/baely/officetracker/pkg/model"
+)
+
+// GetReport returns a tracking year's attendance report as a PDF.
+func (c *Client) GetReport(ctx context.Context, req model.GetReportRequest) (model.Response, error) {
+	return raw(ctx, c, "/report/pdf/{year}-attendance", req)
+}
+
+// GetReportCSV returns a tracking year's attendance as CSV.
+func (c *Client) GetReportCSV(ctx context.Context, req model.GetReportCSVRequest) (model.Response, error) {
+	return raw(ctx, c, "/report/csv/{year}-attendance", req)
+}
+
+// raw gets a route that responds with a file. Data holds its bytes.
+func raw(ctx context.Context, c *Client, pattern string, req any) (model.Response, error) {
+	b, contentType, err := c.send(ctx, http.MethodGet, pattern, req)
+	if err != nil {
+		return model.Response{}, err
+	}
+	return model.Response{ContentType: contentType, Data: b}, nil
+}
@@ -0,0 +1,173 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/baely/officetracker/pkg/model"
+)
+
+// GetSettings returns the user's settings.
+func (c *Client) GetSettings(ctx context.Context, req model.GetSettingsRequest) (model.GetSettingsResponse, error) {
+	return call[model.GetSettingsResponse](ctx, c, http.MethodGet, "/settings", req)
+}
+
+// UpdateThemePreferences sets the user's theme.
+func (c *Client) UpdateThemePreferences(ctx context.Context, req model.UpdateThemePreferencesRequest) (model.UpdateThemePreferencesResponse, error) {
+	return call[model.UpdateThemePreferencesResponse](ctx, c, http.MethodPut, "/settings/theme", req)
+}
+
+// UpdateSchedulePreferences saves a new version of the weekly schedule.
+func (c *Client) UpdateSchedulePreferences(ctx context.Context, req model.UpdateSchedulePreferencesRequest) (model.UpdateSchedulePreferencesResponse, error) {
+	return call[model.UpdateSchedulePreferencesResponse](ctx, c, http.MethodPut, "/settings/schedule", req)
+}
+
+// GetScheduleHistory lists the versions of the weekly schedule, oldest first.
+func (c *Client) GetScheduleHistory(ctx context.Context, req model.GetScheduleHistoryRequest) (model.GetScheduleHistoryResponse, error) {
+	return call[model.GetScheduleHistoryResponse](ctx, c, http.MethodGet, "/settings/schedule/history", req)
+}
+
+// ListScheduleRules lists the recurring schedule rules in the order they
+// apply.
+func (c *Client) ListScheduleRules(ctx context.Context, req model.ListScheduleRulesRequest) (model.ListScheduleRulesResponse, error) {
+	return call[model.ListScheduleRulesResponse](ctx, c, http.MethodGet, "/settings/schedule/rules", req)
+}
+
+// CreateScheduleRule adds a recurring schedule rule after the existing ones.
+func (c *Client) CreateScheduleRule(ctx context.Context, req model.CreateScheduleRuleRequest) (model.CreateScheduleRuleResponse, error) {
+	return call[model.CreateScheduleRuleResponse](ctx, c, http.MethodPost, "/settings/schedule/rules", req)
+}
+
+// UpdateScheduleRule changes a schedule rule.
+func (c *Client) UpdateScheduleRule(ctx context.Context, req model.UpdateScheduleRuleRequest) (model.UpdateScheduleRuleResponse, error) {
+	return call[model.UpdateScheduleRuleResponse](ctx, c, http.MethodPut, "/settings/schedule/rules/{rule_id}", req)
+}
+
+// DeleteScheduleRule deletes a schedule rule.
+func (c *Client) DeleteScheduleRule(ctx context.Context, req model.DeleteScheduleRuleRequest) (model.DeleteScheduleRuleResponse, error) {
+	return call[model.DeleteScheduleRuleResponse](ctx, c, http.MethodDelete, "/settings/schedule/rules/{rule_id}", req)
+}
+
+// UpdateMaterialisePreferences sets whether scheduled days are filled in each
+// evening.
+func (c *Client) UpdateMaterialisePreferences(ctx context.Context, req model.UpdateMaterialisePreferencesRequest) (model.UpdateMaterialisePreferencesResponse, error) {
+	return call[model.UpdateMaterialisePreferencesResponse](ctx, c, http.MethodPut, "/settings/materialise", req)
+}
+
+// UpdateNotificationPreferences sets which emails and push notifications are
+// sent, and where emails go.
+func (c *Client) UpdateNotificationPreferences(ctx context.Context, req model.UpdateNotificationPreferencesRequest) (model.UpdateNotificationPreferencesResponse, error) {
+	return call[model.UpdateNotificationPreferencesResponse](ctx, c, http.MethodPut, "/settings/notifications", req)
+}
+
+// ListPushSubscriptions lists the browsers subscribed to push notifications.
+func (c *Client) ListPushSubscriptions(ctx context.Context, req model.ListPushSubscriptionsRequest) (model.ListPushSubscriptionsResponse, error) {
+	return call[model.ListPushSubscriptionsResponse](ctx, c, http.MethodGet, "/settings/push/subscriptions", req)
+}
+
+// CreatePushSubscription subscribes a browser to push notifications.
+func (c *Client) CreatePushSubscription(ctx context.Context, req model.CreatePushSubscriptionRequest) (model.CreatePushSubscriptionResponse, error) {
+	return call[model.CreatePushSubscriptionResponse](ctx, c, http.MethodPost, "/settings/push/subscriptions", req)
+}
+
+// DeletePushSubscription unsubscribes a browser.
+func (c *Client) DeletePushSubscription(ctx context.Context, req model.DeletePushSubscriptionRequest) (model.DeletePushSubscriptionResponse, error) {
+	return call[model.DeletePushSubscriptionResponse](ctx, c, http.MethodDelete, "/settings/push/subscriptions/{subscription_id}", req)
+}
+
+// ListPushTokens lists the mobile devices registered for push notifications.
+func (c *Client) ListPushTokens(ctx context.Context, req model.ListPushTokensRequest) (model.ListPushTokensResponse, error) {
+	return call[model.ListPushTokensResponse](ctx, c, http.MethodGet, "/settings/push/tokens", req)
+}
+
+// CreatePushToken registers a mobile device's Expo push token against the
+// client's API token.
+func (c *Client) CreatePushToken(ctx context.Context, req model.CreatePushTokenRequest) (model.CreatePushTokenResponse, error) {
+	return call[model.CreatePushTokenResponse](ctx, c, http.MethodPost, "/settings/push/tokens", req)
+}
+
+// DeletePushToken removes a mobile device.
+func (c *Client) DeletePushToken(ctx context.Context, req model.DeletePushTokenRequest) (model.DeletePushTokenResponse, error) {
+	return call[model.DeletePushTokenResponse](ctx, c, http.MethodDelete, "/settings/push/tokens/{push_token_id}", req)
+}
+
+// UpdateCalendarPreferences sets the tracking year start month and timezone.
+func (c *Client) UpdateCalendarPreferences(ctx context.Context, req model.UpdateCalendarPreferencesRequest) (model.UpdateCalendarPreferencesResponse, error) {
+	return call[model.UpdateCalendarPreferencesResponse](ctx, c, http.MethodPut, "/settings/calendar", req)
+}
+
+// UpdateTargetPreferences sets the attendance target.
+func (c *Client) UpdateTargetPreferences(ctx context.Context, req model.UpdateTargetPreferencesRequest) (model.UpdateTargetPreferencesResponse, error) {
+	return call[model.UpdateTargetPreferencesResponse](ctx, c, http.MethodPut, "/settings/target", req)
+}
+
+// GetCompliance measures attendance against the target.
+func (c *Client) GetCompliance(ctx context.Context, req model.GetComplianceRequest) (model.GetComplianceResponse, error) {
+	return call[model.GetComplianceResponse](ctx, c, http.MethodGet, "/settings/target/compliance", req)
+}
+
+// GetForecast projects the target window to its end.
+func (c *Client) GetForecast(ctx context.Context, req model.GetForecastRequest) (model.GetForecastResponse, error) {
+	return call[model.GetForecastResponse](ctx, c, http.MethodGet, "/settings/target/forecast", req)
+}
+
+// ListCustomStates lists the user's custom states, including archived ones.
+func (c *Client) ListCustomStates(ctx context.Context, req model.ListCustomStatesRequest) (model.ListCustomStatesResponse, error) {
+	return call[model.ListCustomStatesResponse](ctx, c, http.MethodGet, "/settings/states", req)
+}
+
+// CreateCustomState adds a custom state.
+func (c *Client) CreateCustomState(ctx context.Context, req model.CreateCustomStateRequest) (model.CreateCustomStateResponse, error) {
+	return call[model.CreateCustomStateResponse](ctx, c, http.MethodPost, "/settings/states", req)
+}
+
+// UpdateCustomState changes a custom state.
+func (c *Client) UpdateCustomState(ctx context.Context, req model.UpdateCustomStateRequest) (model.UpdateCustomStateResponse, error) {
+	return call[model.UpdateCustomStateResponse](ctx, c, http.MethodPut, "/settings/states/{state_id}", req)
+}
+
+// DeleteCustomState archives a custom state.
+func (c *Client) DeleteCustomState(ctx context.Context, req model.DeleteCustomStateRequest) (model.DeleteCustomStateResponse, error) {
+	return call[model.DeleteCustomStateResponse](ctx, c, http.MethodDelete, "/settings/states/{state_id}", req)
+}
+
+// ListLocations lists the user's office locations, including archived ones.
+func (c *Client) ListLocations(ctx context.Context, req model.ListLocationsRequest) (model.ListLocationsResponse, error) {
+	return call[model.ListLocationsResponse](ctx, c, http.MethodGet, "/settings/locations", req)
+}
+
+// CreateLocation adds an office location.
+func (c *Client) CreateLocation(ctx context.Context, req model.CreateLocationRequest) (model.CreateLocationResponse, error) {
+	return call[model.CreateLocationResponse](ctx, c, http.MethodPost, "/settings/locations", req)
+}
+
+// UpdateLocation changes an office location.
+func (c *Client) UpdateLocation(ctx context.Context, req model.UpdateLocationRequest) (model.UpdateLocationResponse, error) {
+	return call[model.UpdateLocationResponse](ctx, c, http.MethodPut, "/settings/locations/{location_id}", req)
+}
+
+// DeleteLocation archives an office location.
+func (c *Client) DeleteLocation(ctx context.Context, req model.DeleteLocationRequest) (model.DeleteLocationResponse, error) {
+	return call[model.DeleteLocationResponse](ctx, c, http.MethodDelete, "/settings/locations/{location_id}", req)
+}
+
+// ListNetworkRules lists the networks the user's check-ins count as in the
+// office from.
+func (c *Client) ListNetworkRules(ctx context.Context, req model.ListNetworkRulesRequest) (model.ListNetworkRulesResponse, error) {
+	return call[model.ListNetworkRulesResponse](ctx, c, http.MethodGet, "/settings/networks", req)
+}
+
+// CreateNetworkRule adds an office Wi-Fi network, IP range or VPN hostname.
+func (c *Client) CreateNetworkRule(ctx context.Context, req model.CreateNetworkRuleRequest) (model.CreateNetworkRuleResponse, error) {
+	return call[model.CreateNetworkRuleResponse](ctx, c, http.MethodPost, "/settings/networks", req)
+}
+
+// UpdateNetworkRule changes a network rule.
+func (c *Client) UpdateNetworkRule(ctx context.Context, req model.UpdateNetworkRuleRequest) (model.UpdateNetworkRuleResponse, error) {
+	return call[model.UpdateNetworkRuleResponse](ctx, c, http.MethodPut, "/settings/networks/{network_rule_id}", req)
+}
+
+// DeleteNetworkRule deletes a network rule.
+func (c *Client) DeleteNetworkRule(ctx context.Context, req model.DeleteNetworkRuleRequest) (model.DeleteNetworkRuleResponse, error) {
+	return call[model.DeleteNetworkRuleResponse](ctx, c, http.MethodDelete, "/settings/networks/{network_rule_id}", req)
+}
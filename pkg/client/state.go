@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/baely/officetracker/pkg/model"
+)
+
+// GetDay returns a day's state.
+func (c *Client) GetDay(ctx context.Context, req model.GetDayRequest) (model.GetDayResponse, error) {
+	return call[model.GetDayResponse](ctx, c, http.MethodGet, "/state/{year}/{month}/{day}", req)
+}
+
+// PutDay sets a day's state.
+func (c *Client) PutDay(ctx context.Context, req model.PutDayRequest) (model.PutDayResponse, error) {
+	return call[model.PutDayResponse](ctx, c, http.MethodPut, "/state/{year}/{month}/{day}", req)
+}
+
+// GetDayHistory lists the changes made to a day, oldest first.
+func (c *Client) GetDayHistory(ctx context.Context, req model.GetDayHistoryRequest) (model.GetDayHistoryResponse, error) {
+	return call[model.GetDayHistoryResponse](ctx, c, http.MethodGet, "/state/{year}/{month}/{day}/history", req)
+}
+
+// PutDayLocation sets or clears a day's office location without changing its
+// state.
+func (c *Client) PutDayLocation(ctx context.Context, req model.PutDayLocationRequest) (model.PutDayLocationResponse, error) {
+	return call[model.PutDayLocationResponse](ctx, c, http.MethodPut, "/state/{year}/{month}/{day}/location", req)
+}
+
+// GetMonth returns a month's states.
+func (c *Client) GetMonth(ctx context.Context, req model.GetMonthRequest) (model.GetMonthResponse, error) {
+	return call[model.GetMonthResponse](ctx, c, http.MethodGet, "/state/{year}/{month}", req)
+}
+
+// PutMonth sets the states of the given days of a month.
+func (c *Client) PutMonth(ctx context.Context, req model.PutMonthRequest) (model.PutMonthResponse, error) {
+	return call[model.PutMonthResponse](ctx, c, http.MethodPut, "/state/{year}/{month}", req)
+}
+
+// GetYear returns a tracking year's states, with scheduled days filled in.
+func (c *Client) GetYear(ctx context.Context, req model.GetYearRequest) (model.GetYearResponse, error) {
+	return call[model.GetYearResponse](ctx, c, http.MethodGet, "/state/{year}", req)
+}
+
+// GetWeek returns an ISO week's states.
+func (c *Client) GetWeek(ctx context.Context, req model.GetWeekRequest) (model.GetWeekResponse, error) {
+	return call[model.GetWeekResponse](ctx, c, http.MethodGet, "/state/week/{iso_year}-W{week}", req)
+}
+
+// PutWeek sets the states of the given days of an ISO week.
+func (c *Client) PutWeek(ctx context.Context, req model.PutWeekRequest) (model.PutWeekResponse, error) {
+	return call[model.PutWeekResponse](ctx, c, http.MethodPut, "/state/week/{iso_year}-W{week}", req)
+}
+
+// GetStateRange lists the entries from one date to another, both inclusive.
+func (c *Client) GetStateRange(ctx context.Context, req model.GetStateRangeRequest) (model.GetStateRangeResponse, error) {
+	return call[model.GetStateRangeResponse](ctx, c, http.MethodGet, "/state", req)
+}
+
+// PatchState writes many days in one transaction.
+func (c *Client) PatchState(ctx context.Context, req model.PatchStateRequest) (model.PatchStateResponse, error) {
+	return call[model.PatchStateResponse](ctx, c, http.MethodPatch, "/state", req)
+}
+
+// GetUnconfirmedDays lists the days filled in from the schedule that still
+// need confirming.
+func (c *Client) GetUnconfirmedDays(ctx context.Context, req model.GetUnconfirmedDaysRequest) (model.GetUnconfirmedDaysResponse, error) {
+	return call[model.GetUnconfirmedDaysResponse](ctx, c, http.MethodGet, "/state/unconfirmed", req)
+}
+
+// ConfirmDays confirms days filled in from the schedule.
+func (c *Client) ConfirmDays(ctx context.Context, req model.ConfirmDaysRequest) (model.ConfirmDaysResponse, error) {
+	return call[model.ConfirmDaysResponse](ctx, c, http.MethodPost, "/state/confirm", req)
+}
+
+// CheckIn records today as in the office if the coordinates given are at one
+// of the user's locations, or the network matches one of their network rules,
+// and the day hasn't been entered.
+func (c *Client) CheckIn(ctx context.Context, req model.CheckInRequest) (model.CheckInResponse, error) {
+	return call[model.CheckInResponse](ctx, c, http.MethodPost, "/checkin", req)
+}
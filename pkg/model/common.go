@@ -1,5 +1,10 @@
 package model
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type State int
 
 const (
@@ -13,8 +18,268 @@ const (
 	StateScheduledOther
 )
 
+// CustomStateBase is the first State value given to user-defined states.
+// Lower values are reserved for the built-in states.
+const CustomStateBase = State(100)
+
+// IsCustom reports whether s is a user-defined state.
+func (s State) IsCustom() bool {
+	return s >= CustomStateBase
+}
+
+// Valid reports whether s can be stored on a day: a built-in state up to
+// StateOther, or a custom state. The scheduled states are only shown, never
+// stored.
+func (s State) Valid() bool {
+	return (s >= StateUntracked && s <= StateOther) || s.IsCustom()
+}
+
+// Attendance is how a state counts towards attendance percentages.
+type Attendance string
+
+const (
+	// AttendancePresent days count as in the office.
+	AttendancePresent = Attendance("present")
+	// AttendanceAbsent days count as work days away from the office.
+	AttendanceAbsent = Attendance("absent")
+	// AttendanceExcluded days are left out of percentages altogether.
+	AttendanceExcluded = Attendance("excluded")
+)
+
+// Valid reports whether a is a recognised attendance type.
+func (a Attendance) Valid() bool {
+	return a == AttendancePresent || a == AttendanceAbsent || a == AttendanceExcluded
+}
+
+// CustomState is a user-defined attendance state such as "client site" or
+// "leave".
+type CustomState struct {
+	ID State `json:"id"`
+	// Name is shown on the form and in reports, and accepted by the MCP tools.
+	Name string `json:"name"`
+	// Color is a CSS hex colour, e.g. "#9C27B0".
+	Color      string     `json:"color"`
+	Attendance Attendance `json:"attendance"`
+	// Archived states can no longer be chosen but still describe entries that
+	// use them.
+	Archived bool `json:"archived,omitempty"`
+}
+
+// CustomStates is a user's registry of custom states.
+type CustomStates []CustomState
+
+// Get returns the custom state with the given ID.
+func (c CustomStates) Get(id State) (CustomState, bool) {
+	for _, state := range c {
+		if state.ID == id {
+			return state, true
+		}
+	}
+	return CustomState{}, false
+}
+
+// Attendance reports how s counts towards attendance. Office days (actual or
+// scheduled) are present, home days are absent, and everything else is
+// excluded. Custom states count however they are configured; unknown custom
+// states are excluded.
+func (c CustomStates) Attendance(s State) Attendance {
+	switch s {
+	case StateWorkFromOffice, StateScheduledWorkFromOffice:
+		return AttendancePresent
+	case StateWorkFromHome, StateScheduledWorkFromHome:
+		return AttendanceAbsent
+	}
+	if custom, ok := c.Get(s); ok {
+		return custom.Attendance
+	}
+	return AttendanceExcluded
+}
+
+// Location is a named place a user works from, such as one of several offices
+// or a client site. Coordinates are optional; when set the mobile app
+// geofences the location.
+type Location struct {
+	ID        int      `json:"id"`
+	Name      string   `json:"name"`
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+	// Radius is the geofence radius in metres. 0 leaves it to the client.
+	Radius   int  `json:"radius,omitempty"`
+	Archived bool `json:"archived,omitempty"`
+}
+
+// Locations is a user's list of locations.
+type Locations []Location
+
+// Get returns the location with the given ID.
+func (l Locations) Get(id int) (Location, bool) {
+	for _, location := range l {
+		if location.ID == id {
+			return location, true
+		}
+	}
+	return Location{}, false
+}
+
+// NetworkRuleKind is what a network rule matches a check-in on.
+type NetworkRuleKind string
+
+const (
+	// NetworkRuleSSID matches the name of the Wi-Fi network the client says
+	// it's on.
+	NetworkRuleSSID = NetworkRuleKind("ssid")
+	// NetworkRuleCIDR matches a request coming from an IP range, such as the
+	// office's egress addresses, written like "203.0.113.0/24".
+	NetworkRuleCIDR = NetworkRuleKind("cidr")
+	// NetworkRuleVPNHost matches a request coming from one of the addresses a
+	// hostname resolves to, such as a corporate VPN's exit point.
+	NetworkRuleVPNHost = NetworkRuleKind("vpn_host")
+)
+
+// NetworkRuleKinds lists every kind of network rule.
+var NetworkRuleKinds = []NetworkRuleKind{NetworkRuleSSID, NetworkRuleCIDR, NetworkRuleVPNHost}
+
+// Valid reports whether k is a recognised kind of network rule.
+func (k NetworkRuleKind) Valid() bool {
+	for _, known := range NetworkRuleKinds {
+		if k == known {
+			return true
+		}
+	}
+	return false
+}
+
+// NetworkRule marks a network as the office's, so a check-in from it records
+// the day as in the office. LocationID is the user's location the network
+// belongs to, or 0 if it doesn't belong to one.
+type NetworkRule struct {
+	ID         int             `json:"id"`
+	Kind       NetworkRuleKind `json:"kind"`
+	Value      string          `json:"value"`
+	LocationID int             `json:"location_id,omitempty"`
+}
+
+// NetworkRules is a user's list of network rules.
+type NetworkRules []NetworkRule
+
+// Get returns the rule with the given ID.
+func (r NetworkRules) Get(id int) (NetworkRule, bool) {
+	for _, rule := range r {
+		if rule.ID == id {
+			return rule, true
+		}
+	}
+	return NetworkRule{}, false
+}
+
+// ScheduleRule schedules a state on the days matched by a recurrence rule,
+// written in RFC 5545 RRULE syntax such as "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE"
+// for every other Monday and Wednesday. Start (YYYY-MM-DD) anchors the
+// recurrence and is the first day the rule applies; End, when set, is the
+// last. Rules override the weekly schedule, and an untracked State clears it.
+type ScheduleRule struct {
+	ID    int    `json:"id"`
+	State State  `json:"state"`
+	RRule string `json:"rrule"`
+	Start string `json:"start"`
+	End   string `json:"end,omitempty"`
+}
+
+// ScheduleRules is a user's list of schedule rules, in the order they apply:
+// later rules win where rules overlap.
+type ScheduleRules []ScheduleRule
+
+// Get returns the rule with the given ID.
+func (r ScheduleRules) Get(id int) (ScheduleRule, bool) {
+	for _, rule := range r {
+		if rule.ID == id {
+			return rule, true
+		}
+	}
+	return ScheduleRule{}, false
+}
+
+// ScheduleVersion is the weekly schedule in force from EffectiveFrom
+// (YYYY-MM-DD) until the next version takes over. An empty EffectiveFrom is
+// the schedule a user had before versions were kept, and applies to all
+// earlier days.
+type ScheduleVersion struct {
+	EffectiveFrom string              `json:"effective_from"`
+	Schedule      SchedulePreferences `json:"schedule"`
+}
+
+// ScheduleHistory is a user's schedule versions, oldest first.
+type ScheduleHistory []ScheduleVersion
+
+// Source records which client produced an entry.
+type Source string
+
+const (
+	// SourceManual is an entry set by hand, e.g. on the form. Writes that don't
+	// specify a source are treated as manual.
+	SourceManual = Source("manual")
+	// SourceGeofence is an entry recorded by the mobile app's geofence.
+	SourceGeofence = Source("geofence")
+	// SourceExtension is an entry recorded by the browser extension.
+	SourceExtension = Source("extension")
+	// SourceSchedule is a day derived from the user's schedule.
+	SourceSchedule = Source("schedule")
+	// SourceImport is an entry loaded in bulk from another system.
+	SourceImport = Source("import")
+	// SourceMCP is an entry written through the MCP tools.
+	SourceMCP = Source("mcp")
+	// SourceChat is an entry recorded by a Slack or Teams slash command.
+	SourceChat = Source("chat")
+)
+
+// Sources lists every recognised entry source.
+var Sources = []Source{SourceManual, SourceGeofence, SourceExtension, SourceSchedule, SourceImport, SourceMCP, SourceChat}
+
+// Valid reports whether s is a recognised source.
+func (s Source) Valid() bool {
+	for _, known := range Sources {
+		if s == known {
+			return true
+		}
+	}
+	return false
+}
+
 type DayState struct {
 	State State `json:"state"`
+	// Source is where the entry came from. Empty for entries written before
+	// sources were recorded.
+	Source Source `json:"source,omitempty"`
+	// UpdatedAt is when the entry was last written. Set by the server; zero for
+	// entries written before timestamps were recorded.
+	UpdatedAt time.Time `json:"updated_at,omitzero"`
+	// Note is a free-text note for the day, e.g. "client site". Writes that
+	// leave it empty keep the day's existing note; use the day note endpoint
+	// to clear one.
+	Note string `json:"note,omitempty"`
+	// LocationID is the user's location the day was spent at. Only days that
+	// count as present can have one; 0 means no location. Writes that leave it
+	// unset keep the location when the state is unchanged.
+	LocationID int `json:"location_id,omitempty"`
+	// Unconfirmed marks an entry filled in from the schedule that the user
+	// hasn't confirmed yet. Writes through the API are always confirmed.
+	Unconfirmed bool `json:"unconfirmed,omitempty"`
+}
+
+// DatedDayState is a day's state with its date (YYYY-MM-DD), as used by the
+// date-range and bulk state endpoints.
+type DatedDayState struct {
+	Date string `json:"date"`
+	DayState
+}
+
+// RangeState sets every day from From to To (YYYY-MM-DD, both inclusive) to
+// State. WeekdaysOnly skips Saturdays and Sundays.
+type RangeState struct {
+	From         string `json:"from"`
+	To           string `json:"to"`
+	State        State  `json:"state"`
+	WeekdaysOnly bool   `json:"weekdays_only,omitempty"`
 }
 
 type MonthState struct {
@@ -23,6 +288,26 @@ type MonthState struct {
 
 type YearState struct {
 	Months map[int]MonthState `json:"months"`
+	// Weeks counts office days in each ISO week of the tracking year. Only
+	// days actually recorded count; scheduled days don't.
+	Weeks []WeekSummary `json:"weeks,omitempty"`
+}
+
+// WeekState holds the days of an ISO week, keyed by ISO weekday from 1
+// (Monday) to 7 (Sunday).
+type WeekState struct {
+	Week       string           `json:"week"`
+	Start      string           `json:"start"`
+	Days       map[int]DayState `json:"days"`
+	OfficeDays int              `json:"office_days"`
+}
+
+// WeekSummary is the number of office days recorded in an ISO week. Week is
+// labelled like "2024-W10" and Start is its Monday.
+type WeekSummary struct {
+	Week       string `json:"week"`
+	Start      string `json:"start"`
+	OfficeDays int    `json:"office_days"`
 }
 
 type Note struct {
@@ -32,3 +317,204 @@ type Note struct {
 // DefaultTrackingYearStartMonth is the month (1-12) a tracking year starts on by
 // default. October matches the original hardcoded behaviour.
 const DefaultTrackingYearStartMonth = 10
+
+// DayChange is a single entry in a day's append-only attendance history. One is
+// recorded whenever a write changes the stored state of a day.
+type DayChange struct {
+	OldState State `json:"old_state"`
+	NewState State `json:"new_state"`
+	// ChangedAt is the RFC3339 timestamp of the write.
+	ChangedAt string `json:"changed_at"`
+	// AuthMethod is how the writer authenticated ("sso", "secret" or
+	// "excluded" for standalone mode), or the chat platform for slash
+	// commands.
+	AuthMethod string `json:"auth_method"`
+	// TokenID identifies the API token used for secret-authenticated writes.
+	// 0 when no token was involved.
+	TokenID int `json:"token_id,omitempty"`
+	// Via is the surface the write arrived through ("api", "mcp" or "chat"),
+	// or "schedule" for days filled in from the schedule.
+	Via string `json:"via"`
+}
+
+// WebhookEvent names something a webhook can be told about.
+type WebhookEvent string
+
+const (
+	// WebhookEventDayUpdated fires when a single day's state changes. Its data
+	// is a DayUpdatedEvent.
+	WebhookEventDayUpdated = WebhookEvent("day.updated")
+	// WebhookEventMonthUpdated fires when a month written at once changes any
+	// of its days. Its data is a MonthUpdatedEvent.
+	WebhookEventMonthUpdated = WebhookEvent("month.updated")
+	// WebhookEventTargetMissed fires when a change makes the attendance target
+	// for the current window unachievable. Its data is the Forecast.
+	WebhookEventTargetMissed = WebhookEvent("target.missed")
+)
+
+// WebhookEvents lists every event a webhook can subscribe to.
+var WebhookEvents = []WebhookEvent{WebhookEventDayUpdated, WebhookEventMonthUpdated, WebhookEventTargetMissed}
+
+// Valid reports whether e is a recognised event.
+func (e WebhookEvent) Valid() bool {
+	for _, event := range WebhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Webhook is a URL that is sent a signed POST when one of its events happens.
+type Webhook struct {
+	ID     int            `json:"id"`
+	URL    string         `json:"url"`
+	Events []WebhookEvent `json:"events"`
+	// Disabled webhooks are kept but not called.
+	Disabled bool `json:"disabled,omitempty"`
+	// Secret signs deliveries. It is only returned when the webhook is
+	// created.
+	Secret string `json:"secret,omitempty"`
+}
+
+// Subscribes reports whether w is enabled and wants to hear about e.
+func (w Webhook) Subscribes(e WebhookEvent) bool {
+	if w.Disabled {
+		return false
+	}
+	for _, event := range w.Events {
+		if event == e {
+			return true
+		}
+	}
+	return false
+}
+
+// Webhooks is a user's list of webhooks.
+type Webhooks []Webhook
+
+// Get returns the webhook with the given ID.
+func (w Webhooks) Get(id int) (Webhook, bool) {
+	for _, webhook := range w {
+		if webhook.ID == id {
+			return webhook, true
+		}
+	}
+	return Webhook{}, false
+}
+
+// PushSubscription is a browser subscribed to push notifications, as returned
+// by the browser's PushManager.
+type PushSubscription struct {
+	ID       int    `json:"id"`
+	Endpoint string `json:"endpoint"`
+	// Keys encrypt messages for the browser. They are only sent when
+	// subscribing.
+	Keys PushKeys `json:"keys,omitzero"`
+	// Device describes the browser, to tell subscriptions apart.
+	Device string `json:"device,omitempty"`
+	// ExpiresAt is when the push service will drop the subscription, if it
+	// said.
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PushKeys are a browser's base64url encoded P-256 public key and auth
+// secret.
+type PushKeys struct {
+	P256dh string `json:"p256dh"`
+	Auth   string `json:"auth"`
+}
+
+// PushSubscriptions is a user's list of subscribed browsers.
+type PushSubscriptions []PushSubscription
+
+// PushToken is the Expo push token of a device running the mobile app. It is
+// registered with the API token the app signed in with, and removed when that
+// is revoked. Reminder and CheckIn choose which notifications it gets.
+type PushToken struct {
+	ID    int    `json:"id"`
+	Token string `json:"token"`
+	// Device describes the phone, to tell devices apart.
+	Device string `json:"device,omitempty"`
+	// Reminder is the evening reminder on a weekday that isn't filled in.
+	Reminder bool `json:"reminder"`
+	// CheckIn confirms each day the app records from a geofence.
+	CheckIn   bool      `json:"check_in"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PushTokens is a user's list of devices registered for push notifications.
+type PushTokens []PushToken
+
+// WebhookDeliveryStatus is where a delivery is up to.
+type WebhookDeliveryStatus string
+
+const (
+	// WebhookDeliveryPending deliveries are waiting for their first attempt
+	// or a retry.
+	WebhookDeliveryPending = WebhookDeliveryStatus("pending")
+	// WebhookDeliveryDelivered deliveries got a 2xx response.
+	WebhookDeliveryDelivered = WebhookDeliveryStatus("delivered")
+	// WebhookDeliveryFailed deliveries ran out of retries.
+	WebhookDeliveryFailed = WebhookDeliveryStatus("failed")
+)
+
+// WebhookDelivery is one event sent, or to be sent, to a webhook.
+type WebhookDelivery struct {
+	ID        int                   `json:"id"`
+	WebhookID int                   `json:"webhook_id"`
+	Event     WebhookEvent          `json:"event"`
+	Status    WebhookDeliveryStatus `json:"status"`
+	Attempts  int                   `json:"attempts"`
+	// ResponseCode is the HTTP status of the last attempt, or 0 if it got no
+	// response.
+	ResponseCode int `json:"response_code,omitempty"`
+	// Error says why the last attempt failed.
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// NextAttemptAt is when a pending delivery is next tried.
+	NextAttemptAt time.Time `json:"next_attempt_at,omitzero"`
+	// Payload is the body sent, a WebhookPayload.
+	Payload json.RawMessage `json:"payload"`
+}
+
+// WebhookPayload is the body of a webhook delivery.
+type WebhookPayload struct {
+	Event     WebhookEvent `json:"event"`
+	CreatedAt time.Time    `json:"created_at"`
+	Data      any          `json:"data"`
+}
+
+// DayUpdatedEvent is the data of a day.updated delivery. Date is YYYY-MM-DD.
+type DayUpdatedEvent struct {
+	Date     string   `json:"date"`
+	Previous DayState `json:"previous"`
+	State    DayState `json:"state"`
+}
+
+// MonthUpdatedEvent is the data of a month.updated delivery. Days holds the
+// days whose state changed.
+type MonthUpdatedEvent struct {
+	Year  int              `json:"year"`
+	Month int              `json:"month"`
+	Days  map[int]DayState `json:"days"`
+}
+
+// ChatPlatform is a chat service that can send slash commands.
+type ChatPlatform string
+
+const (
+	ChatPlatformSlack = ChatPlatform("slack")
+	ChatPlatformTeams = ChatPlatform("teams")
+)
+
+// ChatAccount is a chat user that can be linked to an Officetracker user.
+// TeamID is the Slack workspace or Teams tenant the user belongs to.
+type ChatAccount struct {
+	Platform ChatPlatform `json:"platform"`
+	TeamID   string       `json:"team_id"`
+	UserID   string       `json:"user_id"`
+	// Name is the chat user's display name, shown when linking.
+	Name string `json:"name,omitempty"`
+}
@@ -38,14 +38,86 @@ type PutMonthRequest struct {
 }
 
 type PutMonthRequestMeta struct {
-	UserID int `meta:"user_id"`
-	Year   int `meta:"year"`
-	Month  int `meta:"month"`
+	UserID      int    `meta:"user_id"`
+	Year        int    `meta:"year"`
+	Month       int    `meta:"month"`
+	AuthMethod  string `meta:"auth_method"`
+	AuthTokenID int    `meta:"auth_token_id"`
+	Via         string `meta:"via"`
 }
 
 type PutMonthResponse struct {
 }
 
+type GetStateRangeRequest struct {
+	Meta GetStateRangeRequestMeta `meta:"meta" json:"-"`
+	// From and To (YYYY-MM-DD) bound the days listed, both inclusive.
+	From string `schema:"from"`
+	To   string `schema:"to"`
+}
+
+type GetStateRangeRequestMeta struct {
+	UserID int `meta:"user_id"`
+}
+
+type GetStateRangeResponse struct {
+	Data []DatedDayState `json:"data"`
+}
+
+type PatchStateRequest struct {
+	Meta PatchStateRequestMeta `meta:"meta" json:"-"`
+	Data PatchStateRequestData `json:"data"`
+}
+
+type PatchStateRequestMeta struct {
+	UserID      int    `meta:"user_id"`
+	AuthMethod  string `meta:"auth_method"`
+	AuthTokenID int    `meta:"auth_token_id"`
+	Via         string `meta:"via"`
+}
+
+// PatchStateRequestData lists days to write, a range to fill, or both. A date
+// may only appear once across the two.
+type PatchStateRequestData struct {
+	Entries []DatedDayState `json:"entries,omitempty"`
+	Range   *RangeState     `json:"range,omitempty"`
+}
+
+type PatchStateResponse struct {
+	Updated int `json:"updated"`
+}
+
+type GetWeekRequest struct {
+	Meta GetWeekRequestMeta `meta:"meta" json:"-"`
+}
+
+type GetWeekRequestMeta struct {
+	UserID  int `meta:"user_id"`
+	ISOYear int `meta:"iso_year"`
+	Week    int `meta:"week"`
+}
+
+type GetWeekResponse struct {
+	Data WeekState `json:"data"`
+}
+
+type PutWeekRequest struct {
+	Meta PutWeekRequestMeta `meta:"meta" json:"-"`
+	Data WeekState          `json:"data"`
+}
+
+type PutWeekRequestMeta struct {
+	UserID      int    `meta:"user_id"`
+	ISOYear     int    `meta:"iso_year"`
+	Week        int    `meta:"week"`
+	AuthMethod  string `meta:"auth_method"`
+	AuthTokenID int    `meta:"auth_token_id"`
+	Via         string `meta:"via"`
+}
+
+type PutWeekResponse struct {
+}
+
 type GetDayRequest struct {
 	Meta GetDayRequestMeta `meta:"meta" json:"-"`
 }
@@ -62,18 +134,112 @@ type GetDayResponse struct {
 }
 
 type PutDayRequest struct {
-	Meta PutDayRequestMeta `meta:"meta"`
+	Meta PutDayRequestMeta `meta:"meta" json:"-"`
 	Data DayState          `json:"data"`
 }
 
 type PutDayRequestMeta struct {
+	UserID      int    `meta:"user_id"`
+	Year        int    `meta:"year"`
+	Month       int    `meta:"month"`
+	Day         int    `meta:"day"`
+	AuthMethod  string `meta:"auth_method"`
+	AuthTokenID int    `meta:"auth_token_id"`
+	Via         string `meta:"via"`
+}
+
+type PutDayResponse struct {
+}
+
+type GetDayHistoryRequest struct {
+	Meta GetDayHistoryRequestMeta `meta:"meta" json:"-"`
+}
+
+type GetDayHistoryRequestMeta struct {
 	UserID int `meta:"user_id"`
 	Year   int `meta:"year"`
 	Month  int `meta:"month"`
 	Day    int `meta:"day"`
 }
 
-type PutDayResponse struct {
+type GetDayHistoryResponse struct {
+	Data []DayChange `json:"data"`
+}
+
+type PutDayLocationRequest struct {
+	Meta PutDayLocationRequestMeta `meta:"meta" json:"-"`
+	Data DayLocation               `json:"data"`
+}
+
+type PutDayLocationRequestMeta struct {
+	UserID int `meta:"user_id"`
+	Year   int `meta:"year"`
+	Month  int `meta:"month"`
+	Day    int `meta:"day"`
+}
+
+// DayLocation sets the location of a day. A zero LocationID clears it.
+type DayLocation struct {
+	LocationID int `json:"location_id"`
+}
+
+type PutDayLocationResponse struct {
+}
+
+type CheckInRequest struct {
+	Meta CheckInRequestMeta `meta:"meta" json:"-"`
+	Data CheckIn            `json:"data"`
+}
+
+type CheckInRequestMeta struct {
+	UserID      int    `meta:"user_id"`
+	AuthMethod  string `meta:"auth_method"`
+	AuthTokenID int    `meta:"auth_token_id"`
+	Via         string `meta:"via"`
+	// ClientIP is the address the request came from, checked against the
+	// user's network rules.
+	ClientIP string `meta:"client_ip"`
+}
+
+// CheckIn is a sign from a client that detects attendance by itself that the
+// user might be at one of their offices. The server decides whether they are,
+// from the coordinates if given, then the Wi-Fi network and the address the
+// request came from.
+type CheckIn struct {
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+	// SSID is the name of the Wi-Fi network the client is on, if it can tell.
+	SSID string `json:"ssid,omitempty"`
+	// Source is the client checking in: geofence, the default, or extension.
+	Source Source `json:"source,omitempty"`
+}
+
+type CheckInResponse struct {
+	Data CheckInResult `json:"data"`
+}
+
+// CheckInReason is why a check-in didn't record the day.
+type CheckInReason string
+
+const (
+	// CheckInNotAtOffice means the check-in didn't match any of the user's
+	// locations or network rules.
+	CheckInNotAtOffice = CheckInReason("not_at_office")
+	// CheckInAlreadySet means the user had already entered the day, which a
+	// check-in never overwrites.
+	CheckInAlreadySet = CheckInReason("already_set")
+)
+
+// CheckInResult is what a check-in did to the user's day. Location is the
+// location matched, if any, and Rule the network rule, if it matched on the
+// network. Day is the day as it now stands.
+type CheckInResult struct {
+	Date     string        `json:"date"`
+	Recorded bool          `json:"recorded"`
+	Reason   CheckInReason `json:"reason,omitempty"`
+	Location *Location     `json:"location,omitempty"`
+	Rule     *NetworkRule  `json:"rule,omitempty"`
+	Day      DayState      `json:"day"`
 }
 
 type McpGetMonthRequest struct {
@@ -85,6 +251,7 @@ type McpGetMonthResponse struct {
 	Dates []struct {
 		Date  int
 		State string
+		Note  string `json:",omitempty"`
 	}
 }
 
@@ -93,10 +260,34 @@ type McpPutDayRequest struct {
 	Month int
 	Date  int
 	State string
+	Note  string `json:",omitempty" jsonschema:"optional note for the day, e.g. client site"`
 }
 
 type McpPutDayResponse struct{}
 
+type McpGetComplianceRequest struct {
+	Date string `json:",omitempty" jsonschema:"day to measure the target window around, as YYYY-MM-DD; defaults to today"`
+}
+
+type McpGetComplianceResponse struct {
+	// Summary describes the result in words, e.g. for a user who hasn't set a
+	// target.
+	Summary    string
+	Compliance *Compliance `json:",omitempty"`
+}
+
+type McpGetForecastRequest struct {
+	Date  string `json:",omitempty" jsonschema:"last day to count as past, as YYYY-MM-DD; defaults to today"`
+	Until string `json:",omitempty" jsonschema:"day in the target window to forecast, or the day a rolling window should end on, as YYYY-MM-DD; defaults to date"`
+}
+
+type McpGetForecastResponse struct {
+	// Summary describes the result in words, e.g. for a user who hasn't set a
+	// target.
+	Summary  string
+	Forecast *Forecast `json:",omitempty"`
+}
+
 type GetNoteRequest struct {
 	Meta GetNoteRequestMeta `meta:"meta" json:"-"`
 }
@@ -125,6 +316,36 @@ type PutNoteRequestMeta struct {
 type PutNoteResponse struct {
 }
 
+type GetDayNoteRequest struct {
+	Meta GetDayNoteRequestMeta `meta:"meta" json:"-"`
+}
+
+type GetDayNoteRequestMeta struct {
+	UserID int `meta:"user_id"`
+	Year   int `meta:"year"`
+	Month  int `meta:"month"`
+	Day    int `meta:"day"`
+}
+
+type GetDayNoteResponse struct {
+	Data Note `json:"data"`
+}
+
+type PutDayNoteRequest struct {
+	Meta PutDayNoteRequestMeta `meta:"meta" json:"-"`
+	Data Note                  `json:"data"`
+}
+
+type PutDayNoteRequestMeta struct {
+	UserID int `meta:"user_id"`
+	Year   int `meta:"year"`
+	Month  int `meta:"month"`
+	Day    int `meta:"day"`
+}
+
+type PutDayNoteResponse struct {
+}
+
 type GetNotesRequest struct {
 	Meta GetNotesRequestMeta `meta:"meta" json:"-"`
 }
@@ -168,14 +389,184 @@ type SchedulePreferences struct {
 type CalendarPreferences struct {
 	// TrackingYearStartMonth is the month (1-12) the tracking year starts on.
 	TrackingYearStartMonth int `json:"tracking_year_start_month"`
-}
-
-// TargetPreferences holds the user's monthly attendance target.
-// Targets are optional: a zero TargetPercent means no target is set.
+	// Timezone is the IANA timezone (e.g. "Europe/London") the user's days
+	// start and end in. Empty uses the server's timezone.
+	Timezone string `json:"timezone"`
+}
+
+// MaterialiseMode is what the evening job does with a scheduled day that
+// hasn't been entered.
+type MaterialiseMode string
+
+const (
+	// MaterialiseOff leaves scheduled days as they are.
+	MaterialiseOff = MaterialiseMode("")
+	// MaterialiseEntry saves the scheduled state as an entry.
+	MaterialiseEntry = MaterialiseMode("entry")
+	// MaterialiseUnconfirmed saves the scheduled state as an entry that is
+	// flagged until the user confirms it.
+	MaterialiseUnconfirmed = MaterialiseMode("unconfirmed")
+)
+
+// Valid reports whether m is a known mode.
+func (m MaterialiseMode) Valid() bool {
+	switch m {
+	case MaterialiseOff, MaterialiseEntry, MaterialiseUnconfirmed:
+		return true
+	}
+	return false
+}
+
+// MaterialisePreferences opts a user in to having each day's scheduled state
+// saved as an entry in the evening.
+type MaterialisePreferences struct {
+	Mode MaterialiseMode `json:"mode"`
+}
+
+// NotificationPreferences opts a user in to emails, sent to Email at times in
+// their timezone, and push notifications, sent to each subscribed browser.
+type NotificationPreferences struct {
+	Email string `json:"email"`
+	// DailyReminder is sent on weekday evenings when the day is untracked.
+	DailyReminder bool `json:"daily_reminder"`
+	// WeeklyDigest summarises the previous week on Monday mornings.
+	WeeklyDigest bool `json:"weekly_digest"`
+	// MonthlySummary sends the previous month's report on the 1st.
+	MonthlySummary bool `json:"monthly_summary"`
+	// PushReminder pushes the daily reminder.
+	PushReminder bool `json:"push_reminder"`
+	// PushTargetWarning pushes a warning once the attendance target can no
+	// longer be met in its window.
+	PushTargetWarning bool `json:"push_target_warning"`
+}
+
+// Enabled reports whether any email is switched on.
+func (p NotificationPreferences) Enabled() bool {
+	return p.DailyReminder || p.WeeklyDigest || p.MonthlySummary
+}
+
+// PushEnabled reports whether any push notification is switched on.
+func (p NotificationPreferences) PushEnabled() bool {
+	return p.PushReminder || p.PushTargetWarning
+}
+
+// TargetPolicy is how an attendance target is measured.
+type TargetPolicy string
+
+const (
+	// TargetPolicyPercent is a share of work days spent in the office.
+	TargetPolicyPercent = TargetPolicy("percent")
+	// TargetPolicyDaysPerWeek is an average number of office days a week.
+	TargetPolicyDaysPerWeek = TargetPolicy("days_per_week")
+	// TargetPolicyDaysPerMonth is an average number of office days a month.
+	TargetPolicyDaysPerMonth = TargetPolicy("days_per_month")
+)
+
+// Valid reports whether p is a recognised policy.
+func (p TargetPolicy) Valid() bool {
+	return p == TargetPolicyPercent || p == TargetPolicyDaysPerWeek || p == TargetPolicyDaysPerMonth
+}
+
+// TargetWindow is the period a target is measured over.
+type TargetWindow string
+
+const (
+	// TargetWindowMonth is the calendar month.
+	TargetWindowMonth = TargetWindow("month")
+	// TargetWindowRollingWeeks is the WindowWeeks weeks up to and including
+	// today.
+	TargetWindowRollingWeeks = TargetWindow("rolling_weeks")
+	// TargetWindowQuarter is a quarter of the tracking year.
+	TargetWindowQuarter = TargetWindow("quarter")
+	// TargetWindowTrackingYear is the whole tracking year.
+	TargetWindowTrackingYear = TargetWindow("tracking_year")
+)
+
+// Valid reports whether w is a recognised window.
+func (w TargetWindow) Valid() bool {
+	return w == TargetWindowMonth || w == TargetWindowRollingWeeks || w == TargetWindowQuarter || w == TargetWindowTrackingYear
+}
+
+// DefaultTargetWindowWeeks is the rolling window used when none is given.
+const DefaultTargetWindowWeeks = 12
+
+// TargetPreferences holds the user's attendance target.
+// Targets are optional: a zero TargetPercent (for the percent policy) or
+// TargetDays (for the others) means no target is set. An empty Policy and
+// Window mean percent per calendar month, which is how targets started out.
 type TargetPreferences struct {
-	// TargetPercent is the attendance target (1-100) applied to each month.
+	// TargetPercent is the attendance target (1-100) for the percent policy.
 	// 0 = no target.
-	TargetPercent int `json:"target_percent"`
+	TargetPercent int          `json:"target_percent"`
+	Policy        TargetPolicy `json:"policy,omitempty"`
+	// TargetDays is the office days per week or month for the day policies.
+	TargetDays  float64      `json:"target_days,omitempty"`
+	Window      TargetWindow `json:"window,omitempty"`
+	WindowWeeks int          `json:"window_weeks,omitempty"`
+}
+
+// IsSet reports whether the preferences describe a target.
+func (t TargetPreferences) IsSet() bool {
+	if t.Policy == "" || t.Policy == TargetPolicyPercent {
+		return t.TargetPercent > 0
+	}
+	return t.TargetDays > 0
+}
+
+// Compliance is how a user is tracking against their target over the window
+// containing a given day.
+type Compliance struct {
+	Policy TargetPolicy `json:"policy"`
+	Window TargetWindow `json:"window"`
+	// Start and End are the first and last days of the window (YYYY-MM-DD).
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// Present and Total count office days and work days tracked so far.
+	Present int `json:"present"`
+	Total   int `json:"total"`
+	// Actual is the attendance so far in the policy's unit: a percentage of
+	// work days, or office days per week or month.
+	Actual float64 `json:"actual"`
+	Target float64 `json:"target"`
+	// Required is the office days the window needs to meet the target and
+	// Remaining how many more of them are still to come.
+	Required  int  `json:"required"`
+	Remaining int  `json:"remaining"`
+	Met       bool `json:"met"`
+}
+
+// Forecast projects a target window to its end from the days recorded so far,
+// the days already planned and the work days still to come.
+type Forecast struct {
+	Policy TargetPolicy `json:"policy"`
+	Window TargetWindow `json:"window"`
+	// Start and End are the first and last days of the window and AsOf the
+	// last day counted as past (YYYY-MM-DD).
+	Start string `json:"start"`
+	End   string `json:"end"`
+	AsOf  string `json:"as_of"`
+	// Present and Total count office days and work days so far.
+	Present int `json:"present"`
+	Total   int `json:"total"`
+	// Required is the office days the window needs to meet the target and
+	// Needed how many of them are still to come.
+	Required int `json:"required"`
+	Needed   int `json:"needed"`
+	// RemainingWorkDays counts the work days left after AsOf: days recorded
+	// or scheduled as work days, and untracked weekdays. Planned counts
+	// those already recorded or scheduled in the office.
+	RemainingWorkDays int `json:"remaining_work_days"`
+	Planned           int `json:"planned"`
+	// ProjectedPresent and ProjectedPercent assume the planned office days
+	// happen and the other remaining work days are away from the office.
+	ProjectedPresent int     `json:"projected_present"`
+	ProjectedPercent float64 `json:"projected_percent"`
+	// ProjectedMet reports whether the planned days meet the target;
+	// Additional is how many more office days are needed on top of them.
+	ProjectedMet bool `json:"projected_met"`
+	Additional   int  `json:"additional"`
+	// Achievable reports whether enough work days remain to meet the target.
+	Achievable bool `json:"achievable"`
 }
 
 type LinkedAccount struct {
@@ -190,8 +581,216 @@ type GetSettingsResponse struct {
 	SchedulePreferences SchedulePreferences `json:"schedule_preferences"`
 	CalendarPreferences CalendarPreferences `json:"calendar_preferences"`
 	TargetPreferences   TargetPreferences   `json:"target_preferences"`
+	CustomStates        CustomStates        `json:"custom_states"`
+	Locations           Locations           `json:"locations"`
+	ScheduleRules       ScheduleRules       `json:"schedule_rules"`
+	NetworkRules        NetworkRules        `json:"network_rules"`
+	// MaterialisePreferences is whether scheduled days become entries.
+	MaterialisePreferences MaterialisePreferences `json:"materialise_preferences"`
+	// NotificationPreferences is which emails the user gets.
+	NotificationPreferences NotificationPreferences `json:"notification_preferences"`
+}
+
+type ListCustomStatesRequest struct {
+	Meta ListCustomStatesRequestMeta `meta:"meta" json:"-"`
+}
+
+type ListCustomStatesRequestMeta struct {
+	UserID int `meta:"user_id"`
+}
+
+type ListCustomStatesResponse struct {
+	Data CustomStates `json:"data"`
+}
+
+type CreateCustomStateRequest struct {
+	Meta CreateCustomStateRequestMeta `meta:"meta" json:"-"`
+	Data CustomState                  `json:"data"`
+}
+
+type CreateCustomStateRequestMeta struct {
+	UserID int `meta:"user_id"`
+}
+
+type CreateCustomStateResponse struct {
+	Data CustomState `json:"data"`
+}
+
+type UpdateCustomStateRequest struct {
+	Meta UpdateCustomStateRequestMeta `meta:"meta" json:"-"`
+	Data CustomState                  `json:"data"`
+}
+
+type UpdateCustomStateRequestMeta struct {
+	UserID  int `meta:"user_id"`
+	StateID int `meta:"state_id"`
+}
+
+type UpdateCustomStateResponse struct {
+	Data CustomState `json:"data"`
+}
+
+type DeleteCustomStateRequest struct {
+	Meta DeleteCustomStateRequestMeta `meta:"meta" json:"-"`
+}
+
+type DeleteCustomStateRequestMeta struct {
+	UserID  int `meta:"user_id"`
+	StateID int `meta:"state_id"`
+}
+
+type DeleteCustomStateResponse struct{}
+
+type ListLocationsRequest struct {
+	Meta ListLocationsRequestMeta `meta:"meta" json:"-"`
+}
+
+type ListLocationsRequestMeta struct {
+	UserID int `meta:"user_id"`
+}
+
+type ListLocationsResponse struct {
+	Data Locations `json:"data"`
+}
+
+type CreateLocationRequest struct {
+	Meta CreateLocationRequestMeta `meta:"meta" json:"-"`
+	Data Location                  `json:"data"`
+}
+
+type CreateLocationRequestMeta struct {
+	UserID int `meta:"user_id"`
+}
+
+type CreateLocationResponse struct {
+	Data Location `json:"data"`
+}
+
+type UpdateLocationRequest struct {
+	Meta UpdateLocationRequestMeta `meta:"meta" json:"-"`
+	Data Location                  `json:"data"`
+}
+
+type UpdateLocationRequestMeta struct {
+	UserID     int `meta:"user_id"`
+	LocationID int `meta:"location_id"`
+}
+
+type UpdateLocationResponse struct {
+	Data Location `json:"data"`
+}
+
+type DeleteLocationRequest struct {
+	Meta DeleteLocationRequestMeta `meta:"meta" json:"-"`
+}
+
+type DeleteLocationRequestMeta struct {
+	UserID     int `meta:"user_id"`
+	LocationID int `meta:"location_id"`
+}
+
+type DeleteLocationResponse struct{}
+
+type ListScheduleRulesRequest struct {
+	Meta ListScheduleRulesRequestMeta `meta:"meta" json:"-"`
+}
+
+type ListScheduleRulesRequestMeta struct {
+	UserID int `meta:"user_id"`
+}
+
+type ListScheduleRulesResponse struct {
+	Data ScheduleRules `json:"data"`
+}
+
+type CreateScheduleRuleRequest struct {
+	Meta CreateScheduleRuleRequestMeta `meta:"meta" json:"-"`
+	Data ScheduleRule                  `json:"data"`
+}
+
+type CreateScheduleRuleRequestMeta struct {
+	UserID int `meta:"user_id"`
+}
+
+type CreateScheduleRuleResponse struct {
+	Data ScheduleRule `json:"data"`
+}
+
+type UpdateScheduleRuleRequest struct {
+	Meta UpdateScheduleRuleRequestMeta `meta:"meta" json:"-"`
+	Data ScheduleRule                  `json:"data"`
+}
+
+type UpdateScheduleRuleRequestMeta struct {
+	UserID int `meta:"user_id"`
+	RuleID int `meta:"rule_id"`
+}
+
+type UpdateScheduleRuleResponse struct {
+	Data ScheduleRule `json:"data"`
 }
 
+type DeleteScheduleRuleRequest struct {
+	Meta DeleteScheduleRuleRequestMeta `meta:"meta" json:"-"`
+}
+
+type DeleteScheduleRuleRequestMeta struct {
+	UserID int `meta:"user_id"`
+	RuleID int `meta:"rule_id"`
+}
+
+type DeleteScheduleRuleResponse struct{}
+
+type ListNetworkRulesRequest struct {
+	Meta ListNetworkRulesRequestMeta `meta:"meta" json:"-"`
+}
+
+type ListNetworkRulesRequestMeta struct {
+	UserID int `meta:"user_id"`
+}
+
+type ListNetworkRulesResponse struct {
+	Data NetworkRules `json:"data"`
+}
+
+type CreateNetworkRuleRequest struct {
+	Meta CreateNetworkRuleRequestMeta `meta:"meta" json:"-"`
+	Data NetworkRule                  `json:"data"`
+}
+
+type CreateNetworkRuleRequestMeta struct {
+	UserID int `meta:"user_id"`
+}
+
+type CreateNetworkRuleResponse struct {
+	Data NetworkRule `json:"data"`
+}
+
+type UpdateNetworkRuleRequest struct {
+	Meta UpdateNetworkRuleRequestMeta `meta:"meta" json:"-"`
+	Data NetworkRule                  `json:"data"`
+}
+
+type UpdateNetworkRuleRequestMeta struct {
+	UserID int `meta:"user_id"`
+	RuleID int `meta:"network_rule_id"`
+}
+
+type UpdateNetworkRuleResponse struct {
+	Data NetworkRule `json:"data"`
+}
+
+type DeleteNetworkRuleRequest struct {
+	Meta DeleteNetworkRuleRequestMeta `meta:"meta" json:"-"`
+}
+
+type DeleteNetworkRuleRequestMeta struct {
+	UserID int `meta:"user_id"`
+	RuleID int `meta:"network_rule_id"`
+}
+
+type DeleteNetworkRuleResponse struct{}
+
 type UpdateThemePreferencesRequest struct {
 	Meta UpdateThemePreferencesRequestMeta `meta:"meta" json:"-"`
 	Data ThemePreferences                  `json:"data"`
@@ -206,6 +805,9 @@ type UpdateThemePreferencesResponse struct{}
 type UpdateSchedulePreferencesRequest struct {
 	Meta UpdateSchedulePreferencesRequestMeta `meta:"meta" json:"-"`
 	Data SchedulePreferences                  `json:"data"`
+	// EffectiveFrom (YYYY-MM-DD) is the first day the schedule applies.
+	// Defaults to today; earlier days keep the schedule in force at the time.
+	EffectiveFrom string `json:"effective_from,omitempty"`
 }
 
 type UpdateSchedulePreferencesRequestMeta struct {
@@ -214,6 +816,161 @@ type UpdateSchedulePreferencesRequestMeta struct {
 
 type UpdateSchedulePreferencesResponse struct{}
 
+type UpdateMaterialisePreferencesRequest struct {
+	Meta UpdateMaterialisePreferencesRequestMeta `meta:"meta" json:"-"`
+	Data MaterialisePreferences                  `json:"data"`
+}
+
+type UpdateMaterialisePreferencesRequestMeta struct {
+	UserID int `meta:"user_id"`
+}
+
+type UpdateMaterialisePreferencesResponse struct{}
+
+type UpdateNotificationPreferencesRequest struct {
+	Meta UpdateNotificationPreferencesRequestMeta `meta:"meta" json:"-"`
+	Data NotificationPreferences                  `json:"data"`
+}
+
+type UpdateNotificationPreferencesRequestMeta struct {
+	UserID int `meta:"user_id"`
+}
+
+type UpdateNotificationPreferencesResponse struct{}
+
+type ListPushSubscriptionsRequest struct {
+	Meta ListPushSubscriptionsRequestMeta `meta:"meta" json:"-"`
+}
+
+type ListPushSubscriptionsRequestMeta struct {
+	UserID int `meta:"user_id"`
+}
+
+type ListPushSubscriptionsResponse struct {
+	Data PushSubscriptions `json:"data"`
+}
+
+// CreatePushSubscriptionRequest subscribes a browser. Subscribing an endpoint
+// again replaces its earlier subscription.
+type CreatePushSubscriptionRequest struct {
+	Meta CreatePushSubscriptionRequestMeta `meta:"meta" json:"-"`
+	Data PushSubscription                  `json:"data"`
+}
+
+type CreatePushSubscriptionRequestMeta struct {
+	UserID int `meta:"user_id"`
+}
+
+type CreatePushSubscriptionResponse struct {
+	Data PushSubscription `json:"data"`
+}
+
+type DeletePushSubscriptionRequest struct {
+	Meta DeletePushSubscriptionRequestMeta `meta:"meta" json:"-"`
+}
+
+type DeletePushSubscriptionRequestMeta struct {
+	UserID         int `meta:"user_id"`
+	SubscriptionID int `meta:"subscription_id"`
+}
+
+type DeletePushSubscriptionResponse struct{}
+
+type ListPushTokensRequest struct {
+	Meta ListPushTokensRequestMeta `meta:"meta" json:"-"`
+}
+
+type ListPushTokensRequestMeta struct {
+	UserID int `meta:"user_id"`
+}
+
+type ListPushTokensResponse struct {
+	Data PushTokens `json:"data"`
+}
+
+// CreatePushTokenRequest registers the mobile app's device. Registering a
+// token again replaces its earlier registration.
+type CreatePushTokenRequest struct {
+	Meta CreatePushTokenRequestMeta `meta:"meta" json:"-"`
+	Data PushToken                  `json:"data"`
+}
+
+type CreatePushTokenRequestMeta struct {
+	UserID      int `meta:"user_id"`
+	AuthTokenID int `meta:"auth_token_id"`
+}
+
+type CreatePushTokenResponse struct {
+	Data PushToken `json:"data"`
+}
+
+type DeletePushTokenRequest struct {
+	Meta DeletePushTokenRequestMeta `meta:"meta" json:"-"`
+}
+
+type DeletePushTokenRequestMeta struct {
+	UserID      int `meta:"user_id"`
+	PushTokenID int `meta:"push_token_id"`
+}
+
+type DeletePushTokenResponse struct{}
+
+// UnconfirmedDay is an entry filled in from the schedule awaiting
+// confirmation.
+type UnconfirmedDay struct {
+	Date  string `json:"date"`
+	State State  `json:"state"`
+}
+
+type GetUnconfirmedDaysRequest struct {
+	Meta GetUnconfirmedDaysRequestMeta `meta:"meta" json:"-"`
+	// Date is the last day to look at (YYYY-MM-DD), defaulting to today.
+	// Unconfirmed days from the week up to and including it are listed.
+	Date string `schema:"date"`
+}
+
+type GetUnconfirmedDaysRequestMeta struct {
+	UserID int `meta:"user_id"`
+}
+
+type GetUnconfirmedDaysResponse struct {
+	Data []UnconfirmedDay `json:"data"`
+}
+
+type ConfirmDaysRequest struct {
+	Meta ConfirmDaysRequestMeta `meta:"meta" json:"-"`
+	Data ConfirmDaysRequestData `json:"data"`
+}
+
+type ConfirmDaysRequestMeta struct {
+	UserID      int    `meta:"user_id"`
+	AuthMethod  string `meta:"auth_method"`
+	AuthTokenID int    `meta:"auth_token_id"`
+	Via         string `meta:"via"`
+}
+
+type ConfirmDaysRequestData struct {
+	// Dates (YYYY-MM-DD) to confirm, at most 366. Days that aren't unconfirmed
+	// are skipped.
+	Dates []string `json:"dates"`
+}
+
+type ConfirmDaysResponse struct {
+	Confirmed int `json:"confirmed"`
+}
+
+type GetScheduleHistoryRequest struct {
+	Meta GetScheduleHistoryRequestMeta `meta:"meta" json:"-"`
+}
+
+type GetScheduleHistoryRequestMeta struct {
+	UserID int `meta:"user_id"`
+}
+
+type GetScheduleHistoryResponse struct {
+	Data ScheduleHistory `json:"data"`
+}
+
 type UpdateCalendarPreferencesRequest struct {
 	Meta UpdateCalendarPreferencesRequestMeta `meta:"meta" json:"-"`
 	Data CalendarPreferences                  `json:"data"`
@@ -236,6 +993,40 @@ type UpdateTargetPreferencesRequestMeta struct {
 
 type UpdateTargetPreferencesResponse struct{}
 
+type GetComplianceRequest struct {
+	Meta GetComplianceRequestMeta `meta:"meta" json:"-"`
+	// Date picks the window containing that day (YYYY-MM-DD). Defaults to
+	// today.
+	Date string `schema:"date"`
+}
+
+type GetComplianceRequestMeta struct {
+	UserID int `meta:"user_id"`
+}
+
+// GetComplianceResponse has no data when the user hasn't set a target.
+type GetComplianceResponse struct {
+	Data *Compliance `json:"data"`
+}
+
+type GetForecastRequest struct {
+	Meta GetForecastRequestMeta `meta:"meta" json:"-"`
+	// Date is the last day counted as past (YYYY-MM-DD). Defaults to today.
+	Date string `schema:"date"`
+	// Until picks the window containing that day, or for a rolling window
+	// the one ending on it (YYYY-MM-DD). Defaults to Date.
+	Until string `schema:"until"`
+}
+
+type GetForecastRequestMeta struct {
+	UserID int `meta:"user_id"`
+}
+
+// GetForecastResponse has no data when the user hasn't set a target.
+type GetForecastResponse struct {
+	Data *Forecast `json:"data"`
+}
+
 // Token management models
 type PostSecretRequest struct {
 	Meta PostSecretRequestMeta `meta:"meta" json:"-"`
@@ -285,9 +1076,125 @@ type RevokeTokenResponse struct {
 	Success bool `json:"success"`
 }
 
+type ListWebhooksRequest struct {
+	Meta ListWebhooksRequestMeta `meta:"meta" json:"-"`
+}
+
+type ListWebhooksRequestMeta struct {
+	UserID int `meta:"user_id"`
+}
+
+type ListWebhooksResponse struct {
+	Data Webhooks `json:"data"`
+}
+
+type CreateWebhookRequest struct {
+	Meta CreateWebhookRequestMeta `meta:"meta" json:"-"`
+	Data Webhook                  `json:"data"`
+}
+
+type CreateWebhookRequestMeta struct {
+	UserID int `meta:"user_id"`
+}
+
+// CreateWebhookResponse includes the webhook's signing secret, which isn't
+// shown again.
+type CreateWebhookResponse struct {
+	Data Webhook `json:"data"`
+}
+
+type UpdateWebhookRequest struct {
+	Meta UpdateWebhookRequestMeta `meta:"meta" json:"-"`
+	Data Webhook                  `json:"data"`
+}
+
+type UpdateWebhookRequestMeta struct {
+	UserID    int `meta:"user_id"`
+	WebhookID int `meta:"webhook_id"`
+}
+
+type UpdateWebhookResponse struct {
+	Data Webhook `json:"data"`
+}
+
+type DeleteWebhookRequest struct {
+	Meta DeleteWebhookRequestMeta `meta:"meta" json:"-"`
+}
+
+type DeleteWebhookRequestMeta struct {
+	UserID    int `meta:"user_id"`
+	WebhookID int `meta:"webhook_id"`
+}
+
+type DeleteWebhookResponse struct{}
+
+type ListWebhookDeliveriesRequest struct {
+	Meta ListWebhookDeliveriesRequestMeta `meta:"meta" json:"-"`
+}
+
+type ListWebhookDeliveriesRequestMeta struct {
+	UserID    int `meta:"user_id"`
+	WebhookID int `meta:"webhook_id"`
+}
+
+// ListWebhookDeliveriesResponse lists a webhook's most recent deliveries,
+// newest first.
+type ListWebhookDeliveriesResponse struct {
+	Data []WebhookDelivery `json:"data"`
+}
+
+// ChatCommandRequest is a verified slash command from Slack or Teams.
+type ChatCommandRequest struct {
+	Meta ChatCommandRequestMeta `meta:"meta" json:"-"`
+	// Text is what the user typed after the command, e.g. "in tomorrow".
+	Text string `json:"text"`
+}
+
+type ChatCommandRequestMeta struct {
+	Account ChatAccount `meta:"account"`
+	// LinkURL is the page an unlinked chat user is sent to, to link their
+	// account.
+	LinkURL string `meta:"link_url"`
+}
+
+// ChatCommandResponse is the plain text reply to a slash command.
+type ChatCommandResponse struct {
+	Text string `json:"text"`
+}
+
+type GetChatLinkRequest struct {
+	Meta GetChatLinkRequestMeta `meta:"meta" json:"-"`
+}
+
+type GetChatLinkRequestMeta struct {
+	Code string `meta:"code"`
+}
+
+// GetChatLinkResponse is the chat account a pending link is for.
+type GetChatLinkResponse struct {
+	Data ChatAccount `json:"data"`
+}
+
+type LinkChatAccountRequest struct {
+	Meta LinkChatAccountRequestMeta `meta:"meta" json:"-"`
+}
+
+type LinkChatAccountRequestMeta struct {
+	UserID int    `meta:"user_id"`
+	Code   string `meta:"code"`
+}
+
+// LinkChatAccountResponse is the chat account now linked to the user.
+type LinkChatAccountResponse struct {
+	Data ChatAccount `json:"data"`
+}
+
 type GetReportRequest struct {
 	Meta GetReportRequestMeta `meta:"meta" json:"-"`
 	Name string               `schema:"name"`
+	// Source limits the report to entries from the given sources. Repeat the
+	// parameter to include several.
+	Source []Source `schema:"source"`
 }
 
 type GetReportRequestMeta struct {
@@ -296,7 +1203,10 @@ type GetReportRequestMeta struct {
 }
 
 type GetReportCSVRequest struct {
-	Meta GetReportCSVRequestMeta `meta:"meta" json:"-"`
+	Meta   GetReportCSVRequestMeta `meta:"meta" json:"-"`
+	Source []Source                `schema:"source"`
+	// Detail adds Note, Location and Week columns after Date and State.
+	Detail bool `schema:"detail"`
 }
 
 type GetReportCSVRequestMeta struct {
@@ -324,6 +1234,49 @@ type ValidateAuthResponse struct {
 }
 
 type Error struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+	Code int `json:"code"`
+	// ErrorCode says what went wrong in a form clients can switch on. Unlike
+	// Message it won't change.
+	ErrorCode ErrorCode `json:"error_code"`
+	Message   string    `json:"message"`
+	// Errors lists the invalid fields of a rejected request.
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// ErrorCode is the machine-readable kind of an API error. Each maps to a
+// single HTTP status.
+type ErrorCode string
+
+const (
+	// ErrorCodeBadRequest means the request couldn't be read, e.g. malformed
+	// JSON. (400)
+	ErrorCodeBadRequest ErrorCode = "bad_request"
+	// ErrorCodeValidation means the request was read but some fields are
+	// invalid; Errors lists them. (400)
+	ErrorCodeValidation ErrorCode = "validation_failed"
+	// ErrorCodeUnauthorized means the request isn't signed in. (401)
+	ErrorCodeUnauthorized ErrorCode = "unauthorized"
+	// ErrorCodeForbidden means the caller is signed in but can't use this
+	// endpoint that way, e.g. with an API token on an SSO-only route. (403)
+	ErrorCodeForbidden ErrorCode = "forbidden"
+	// ErrorCodeNotFound means the route or the thing it names doesn't exist.
+	// (404)
+	ErrorCodeNotFound ErrorCode = "not_found"
+	// ErrorCodeConflict means the write clashes with existing data, e.g. a
+	// name already in use. (409)
+	ErrorCodeConflict ErrorCode = "conflict"
+	// ErrorCodeRateLimited means too many requests; retry later. (429)
+	ErrorCodeRateLimited ErrorCode = "rate_limited"
+	// ErrorCodeInternal means the server failed. (500)
+	ErrorCodeInternal ErrorCode = "internal_error"
+	// ErrorCodeNotImplemented means the feature isn't available on this
+	// server. (501)
+	ErrorCodeNotImplemented ErrorCode = "not_implemented"
+)
+
+// FieldError says why a request field is invalid. Field is its path in the
+// request, e.g. "data.days.31" or "month".
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
 }
@@ -29,6 +29,50 @@ func TestStateEnumValues(t *testing.T) {
 	}
 }
 
+// Built-in states have fixed attendance; custom states use their configured
+// value and unknown ones are excluded.
+func TestCustomStatesAttendance(t *testing.T) {
+	customs := CustomStates{
+		{ID: CustomStateBase, Name: "Client site", Attendance: AttendancePresent},
+		{ID: CustomStateBase + 1, Name: "Travel", Attendance: AttendanceAbsent, Archived: true},
+	}
+	cases := []struct {
+		state State
+		want  Attendance
+	}{
+		{StateWorkFromOffice, AttendancePresent},
+		{StateScheduledWorkFromOffice, AttendancePresent},
+		{StateWorkFromHome, AttendanceAbsent},
+		{StateScheduledWorkFromHome, AttendanceAbsent},
+		{StateOther, AttendanceExcluded},
+		{StateUntracked, AttendanceExcluded},
+		{CustomStateBase, AttendancePresent},
+		{CustomStateBase + 1, AttendanceAbsent}, // archived still counts
+		{CustomStateBase + 2, AttendanceExcluded},
+	}
+	for _, c := range cases {
+		if got := customs.Attendance(c.state); got != c.want {
+			t.Errorf("Attendance(%d) = %q, want %q", c.state, got, c.want)
+		}
+	}
+	if StateScheduledOther.IsCustom() || !CustomStateBase.IsCustom() {
+		t.Error("IsCustom should only hold from CustomStateBase")
+	}
+}
+
+func TestStateValid(t *testing.T) {
+	for _, s := range []State{StateUntracked, StateWorkFromHome, StateWorkFromOffice, StateOther, CustomStateBase, CustomStateBase + 5} {
+		if !s.Valid() {
+			t.Errorf("State(%d).Valid() = false, want true", s)
+		}
+	}
+	for _, s := range []State{-1, StateScheduledWorkFromHome, StateScheduledOther, 7, CustomStateBase - 1} {
+		if s.Valid() {
+			t.Errorf("State(%d).Valid() = true, want false", s)
+		}
+	}
+}
+
 func TestDefaultTrackingYearStartMonth(t *testing.T) {
 	if DefaultTrackingYearStartMonth != 10 {
 		t.Fatalf("DefaultTrackingYearStartMonth = %d, want 10 (October)", DefaultTrackingYearStartMonth)
@@ -0,0 +1,75 @@
+// Package webhook signs and verifies Officetracker webhook deliveries.
+//
+// A delivery is a POST of a JSON model.WebhookPayload with the headers below.
+// The signature header looks like "t=1712300000,v1=5257a8...": t is when the
+// delivery was signed, in Unix seconds, and v1 is the hex HMAC-SHA256 of
+// "<t>.<body>" keyed with the webhook's secret. Receivers should check it with
+// Verify before trusting a delivery.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// EventHeader carries the delivery's event, e.g. "day.updated".
+	EventHeader = "X-Officetracker-Event"
+	// DeliveryHeader carries the delivery's ID, which stays the same across
+	// retries.
+	DeliveryHeader = "X-Officetracker-Delivery"
+	// SignatureHeader carries the signature.
+	SignatureHeader = "X-Officetracker-Signature"
+
+	// DefaultTolerance is how far a signature's time may be from now before
+	// Verify rejects it, to limit replays.
+	DefaultTolerance = 5 * time.Minute
+)
+
+// ErrInvalidSignature is returned by Verify for a missing, malformed, stale or
+// wrong signature.
+var ErrInvalidSignature = errors.New("webhook: invalid signature")
+
+// Sign returns the signature header for body, signed with secret at t.
+func Sign(secret string, t time.Time, body []byte) string {
+	return fmt.Sprintf("t=%d,v1=%s", t.Unix(), hex.EncodeToString(mac(secret, t.Unix(), body)))
+}
+
+// Verify checks that header is a signature of body made with secret within
+// tolerance of now.
+func Verify(secret, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	var ts int64
+	var sig []byte
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			ts, _ = strconv.ParseInt(value, 10, 64)
+		case "v1":
+			sig, _ = hex.DecodeString(value)
+		}
+	}
+	if ts == 0 || sig == nil {
+		return fmt.Errorf("%w: malformed header", ErrInvalidSignature)
+	}
+	if age := now.Sub(time.Unix(ts, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("%w: signed %s ago", ErrInvalidSignature, age.Round(time.Second))
+	}
+	if !hmac.Equal(sig, mac(secret, ts, body)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func mac(secret string, ts int64, body []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(h, "%d.", ts)
+	h.Write(body)
+	return h.Sum(nil)
+}
@@ -0,0 +1,43 @@
+package webhook
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	signedAt := time.Unix(1712300000, 0)
+	body := []byte(`{"event":"day.updated"}`)
+	header := Sign("secret", signedAt, body)
+
+	// Matches openssl, so receivers in other languages can check theirs:
+	//	printf '1712300000.{"event":"day.updated"}' | openssl dgst -sha256 -hmac secret
+	want := "t=1712300000,v1=c34ecf86fc0f46932a77d3f95f76659ca8856f2b6ca2ff382e331a68d0bffafe"
+	if header != want {
+		t.Errorf("Sign = %q, want %q", header, want)
+	}
+
+	if err := Verify("secret", header, body, signedAt.Add(time.Minute), DefaultTolerance); err != nil {
+		t.Errorf("Verify = %v, want nil", err)
+	}
+
+	cases := map[string]struct {
+		secret, header string
+		body           []byte
+		now            time.Time
+	}{
+		"wrong secret": {"other", header, body, signedAt},
+		"changed body": {"secret", header, []byte(`{"event":"month.updated"}`), signedAt},
+		"stale":        {"secret", header, body, signedAt.Add(DefaultTolerance + time.Second)},
+		"from future":  {"secret", header, body, signedAt.Add(-DefaultTolerance - time.Second)},
+		"empty":        {"secret", "", body, signedAt},
+		"no signature": {"secret", "t=1712300000", body, signedAt},
+		"bad hex":      {"secret", "t=1712300000,v1=zz", body, signedAt},
+	}
+	for name, c := range cases {
+		if err := Verify(c.secret, c.header, c.body, c.now, DefaultTolerance); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s: Verify = %v, want ErrInvalidSignature", name, err)
+		}
+	}
+}
@@ -3,10 +3,16 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"net/http"
+	"os"
 
 	"github.com/baely/officetracker/internal/config"
 	"github.com/baely/officetracker/internal/database"
+	v1 "github.com/baely/officetracker/internal/implementation/v1"
+	"github.com/baely/officetracker/internal/mail"
+	"github.com/baely/officetracker/internal/push"
 	"github.com/baely/officetracker/internal/report"
 	"github.com/baely/officetracker/internal/server"
 )
@@ -14,15 +20,47 @@ import (
 func main() {
 	port := flag.String("port", "8080", "port to run the server on")
 	dbLoc := flag.String("database", "officetracker.db", "database to use")
+	slackSecret := flag.String("slack-signing-secret", "", "Slack app signing secret, to enable Slack slash commands")
+	teamsSecret := flag.String("teams-secret", "", "Teams outgoing webhook security token, to enable Teams commands")
+	smtpHost := flag.String("smtp-host", "", "mail server to send email notifications through, to enable them")
+	smtpPort := flag.String("smtp-port", "587", "mail server port")
+	smtpUsername := flag.String("smtp-username", "", "mail server username, if it needs signing in to")
+	smtpPassword := flag.String("smtp-password", os.Getenv("SMTP_PASSWORD"), "mail server password (defaults to $SMTP_PASSWORD)")
+	smtpFrom := flag.String("smtp-from", "", "address email notifications are sent from")
+	vapidPublicKey := flag.String("vapid-public-key", "", "VAPID public key, to enable push notifications")
+	vapidPrivateKey := flag.String("vapid-private-key", os.Getenv("WEBPUSH_PRIVATE_KEY"), "VAPID private key (defaults to $WEBPUSH_PRIVATE_KEY)")
+	vapidSubject := flag.String("vapid-subject", "", "mailto: or https: contact for push services")
+	expoPush := flag.Bool("expo-push", false, "send the mobile app's push notifications through Expo")
+	trustProxy := flag.Bool("trust-proxy", false, "match office networks against X-Forwarded-For, when behind a proxy that appends it")
 	flag.Parse()
 
 	cfg := config.StandaloneApp{
 		App: config.App{
-			Port: *port,
+			Port:       *port,
+			TrustProxy: *trustProxy,
 		},
 		SQLite: config.SQLite{
 			Location: *dbLoc,
 		},
+		Chat: config.Chat{
+			SlackSigningSecret: *slackSecret,
+			TeamsSecret:        *teamsSecret,
+		},
+		SMTP: config.SMTP{
+			Host:     *smtpHost,
+			Port:     *smtpPort,
+			Username: *smtpUsername,
+			Password: *smtpPassword,
+			From:     *smtpFrom,
+		},
+		WebPush: config.WebPush{
+			PublicKey:  *vapidPublicKey,
+			PrivateKey: *vapidPrivateKey,
+			Subject:    *vapidSubject,
+		},
+	}
+	if *expoPush {
+		cfg.Expo.PushURL = push.ExpoURL
 	}
 
 	db, err := database.NewSQLiteClient(cfg.SQLite)
@@ -32,6 +70,31 @@ func main() {
 
 	reporter := report.New(db)
 
+	// Standalone has no scheduled jobs, so fill in scheduled days in-process.
+	service := v1.New(db, reporter)
+	go service.MaterialiseEvenings(context.Background())
+	// Standalone runs on the user's own network, so webhooks may go to it.
+	go service.DeliverWebhooks(context.Background(), v1.NewWebhookClient(true))
+	var notifiers v1.Notifiers
+	if cfg.SMTP.Host != "" {
+		notifiers.Mail, err = mail.NewSMTP(cfg.SMTP)
+		if err != nil {
+			panic(err)
+		}
+	}
+	if cfg.WebPush.PrivateKey != "" {
+		notifiers.Push, err = push.NewVAPID(cfg.WebPush, v1.NewWebhookClient(true))
+		if err != nil {
+			panic(err)
+		}
+	}
+	if cfg.Expo.PushURL != "" {
+		notifiers.Mobile = push.NewExpo(cfg.Expo, http.DefaultClient)
+	}
+	if notifiers.Mail != nil || notifiers.Push != nil || notifiers.Mobile != nil {
+		go service.NotifyHourly(context.Background(), notifiers, "http://localhost:"+cfg.App.Port+"/")
+	}
+
 	s, err := server.NewServer(cfg, db, nil, reporter)
 	if err != nil {
 		panic(err)
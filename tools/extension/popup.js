@@ -80,7 +80,7 @@ async function fetchTodayStatus() {
 async function updateTodayStatus(state) {
     const { year, month, day } = getCurrentDate();
     const url = `${API_BASE_URL}/api/v1/state/${year}/${month}/${day}`;
-    const payload = { data: { state: state } };
+    const payload = { data: { state: state, source: "extension" } };
 
     console.log(`[Officetracker] Updating status to: ${state} (${STATE_MAP[state].display})`);
     console.log(`[Officetracker] PUT ${url}`);